@@ -0,0 +1,207 @@
+// Package accesslog 访问日志：每个 HTTP 请求结束时记录一行（播放会话在断开时记录，包含时长和字节数），
+// 以 Apache combined 或 JSON 格式输出到单独的轮转文件，便于流量分析和 fail2ban 等工具使用
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 输出格式
+const (
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+)
+
+// Entry 一条访问记录
+type Entry struct {
+	Time      time.Time // 请求开始时间
+	ClientIP  string    // 客户端 IP，已按可信代理解析 X-Forwarded-For
+	Method    string
+	URI       string // 请求路径与参数
+	Proto     string
+	Host      string
+	Status    int
+	Bytes     int64         // 发送给客户端的字节数（不含响应头）
+	Duration  time.Duration // 从收到请求到响应结束
+	Referer   string
+	UserAgent string
+}
+
+// Logger 访问日志输出
+type Logger struct {
+	mu      sync.RWMutex
+	cfg     config.AccessLogConfig
+	out     io.Writer
+	closer  io.Closer
+	exclude []string
+}
+
+// New 创建未启用的访问日志
+func New() *Logger {
+	return &Logger{}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.AccessLogConfig) { Default.Configure(cfg) }
+
+// Configure 应用配置，文件或轮转参数变化时重新打开输出
+func (l *Logger) Configure(cfg config.AccessLogConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reopen := !cfg.Enabled || cfg.File != l.cfg.File || cfg.MaxSizeMB != l.cfg.MaxSizeMB ||
+		cfg.MaxBackups != l.cfg.MaxBackups || cfg.MaxAgeDays != l.cfg.MaxAgeDays || cfg.Compress != l.cfg.Compress
+	if reopen && l.closer != nil {
+		l.closer.Close()
+		l.out, l.closer = nil, nil
+	}
+	l.cfg = cfg
+	l.exclude = l.exclude[:0]
+	for _, p := range cfg.Exclude {
+		if p = strings.TrimSpace(p); p != "" {
+			l.exclude = append(l.exclude, p)
+		}
+	}
+	if !cfg.Enabled || l.out != nil {
+		return
+	}
+	if cfg.File == "" {
+		l.out = os.Stdout
+		return
+	}
+	lj := &lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}
+	l.out, l.closer = lj, lj
+}
+
+// Enabled 是否记录该路径的访问日志
+func (l *Logger) Enabled(path string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.cfg.Enabled || l.out == nil {
+		return false
+	}
+	for _, p := range l.exclude {
+		if strings.HasPrefix(path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Write 输出一条记录
+func (l *Logger) Write(e Entry) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.cfg.Enabled || l.out == nil {
+		return
+	}
+	var line []byte
+	if l.cfg.Format == FormatJSON {
+		line = formatJSON(e)
+	} else {
+		line = formatCombined(e)
+	}
+	l.out.Write(line)
+}
+
+// formatCombined Apache combined 格式，末尾附加耗时秒数与 Host：
+// 1.2.3.4 - - [02/Jan/2006:15:04:05 +0800] "GET /udp/239.0.0.1:2000 HTTP/1.1" 200 123456 "-" "VLC/3.0" 12.345 "example.com"
+func formatCombined(e Entry) []byte {
+	var b strings.Builder
+	b.Grow(256)
+	b.WriteString(orDash(e.ClientIP))
+	b.WriteString(" - - [")
+	b.WriteString(e.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	b.WriteString("] \"")
+	b.WriteString(escape(e.Method))
+	b.WriteByte(' ')
+	b.WriteString(escape(e.URI))
+	b.WriteByte(' ')
+	b.WriteString(escape(e.Proto))
+	b.WriteString("\" ")
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+	if e.Bytes > 0 {
+		b.WriteString(strconv.FormatInt(e.Bytes, 10))
+	} else {
+		b.WriteByte('-')
+	}
+	fmt.Fprintf(&b, " \"%s\" \"%s\" %.3f \"%s\"\n",
+		escape(orDash(e.Referer)), escape(orDash(e.UserAgent)), e.Duration.Seconds(), escape(orDash(e.Host)))
+	return []byte(b.String())
+}
+
+func formatJSON(e Entry) []byte {
+	line, _ := json.Marshal(struct {
+		Time      string  `json:"time"`
+		ClientIP  string  `json:"client_ip"`
+		Method    string  `json:"method"`
+		URI       string  `json:"uri"`
+		Proto     string  `json:"proto"`
+		Host      string  `json:"host"`
+		Status    int     `json:"status"`
+		Bytes     int64   `json:"bytes"`
+		Duration  float64 `json:"duration"` // 秒
+		Referer   string  `json:"referer,omitempty"`
+		UserAgent string  `json:"user_agent,omitempty"`
+	}{
+		Time:      e.Time.Format(time.RFC3339Nano),
+		ClientIP:  e.ClientIP,
+		Method:    e.Method,
+		URI:       e.URI,
+		Proto:     e.Proto,
+		Host:      e.Host,
+		Status:    e.Status,
+		Bytes:     e.Bytes,
+		Duration:  e.Duration.Seconds(),
+		Referer:   e.Referer,
+		UserAgent: e.UserAgent,
+	})
+	return append(line, '\n')
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escape 转义引号、反斜杠和控制字符，避免伪造日志行
+func escape(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return r == '"' || r == '\\' || r < 0x20 || r == 0x7f }) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,344 @@
+// Package alert 告警：定时检查频道、代理组与系统资源，异常持续超过 for 后发送通知，
+// 同一异常未恢复前不重复通知（可按 repeat 定期提醒），恢复时发送恢复通知
+package alert
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// 告警规则
+const (
+	RuleChannelStall   = "channel_stall"
+	RuleProxyGroupDown = "proxy_group_down"
+	RuleMemory         = "memory"
+	RuleDisk           = "disk"
+	RuleTest           = "test"
+)
+
+// 通知状态
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusTest     = "test"
+)
+
+// Alert 当前存在的异常
+type Alert struct {
+	Rule     string    `json:"rule"`
+	Target   string    `json:"target"` // 频道、代理组或磁盘分区
+	Message  string    `json:"message"`
+	Since    time.Time `json:"since"`    // 异常开始时间
+	Firing   bool      `json:"firing"`   // 已持续超过 for 并发送了通知
+	Notified time.Time `json:"notified"` // 最近一次通知时间
+}
+
+func (a *Alert) key() string { return a.Rule + "|" + a.Target }
+
+// Manager 告警检查与通知
+type Manager struct {
+	mu       sync.Mutex
+	cfg      config.AlertConfig
+	stop     chan struct{}
+	interval time.Duration
+	alerts   map[string]*Alert
+	packets  map[string]uint64 // 上次检查时各频道的累计包数
+}
+
+// New 创建未启用的告警管理器
+func New() *Manager {
+	return &Manager{alerts: make(map[string]*Alert)}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.AlertConfig) { Default.Configure(cfg) }
+
+// Configure 应用配置：启用时按检查间隔启动检查，禁用时停止并清空当前告警
+func (m *Manager) Configure(cfg config.AlertConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	if !cfg.Enabled {
+		if m.stop != nil {
+			close(m.stop)
+			m.stop = nil
+		}
+		m.alerts = make(map[string]*Alert)
+		m.packets = nil
+		return
+	}
+	if m.stop == nil || m.interval != cfg.Interval {
+		if m.stop != nil {
+			close(m.stop)
+		}
+		m.stop = make(chan struct{})
+		m.interval = cfg.Interval
+		go m.run(m.stop, cfg.Interval)
+	}
+}
+
+func (m *Manager) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check(stop, time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// condition 一次检查发现的异常
+type condition struct {
+	rule, target, message string
+}
+
+// check 检查一次：新出现的异常开始计时，持续超过 for 的发送告警，消失的异常发送恢复通知
+func (m *Manager) check(stop chan struct{}, now time.Time) {
+	hubs := monitor.GetTopHubs("", 0)
+
+	m.mu.Lock()
+	if m.stop != stop {
+		m.mu.Unlock()
+		return
+	}
+	cfg := m.cfg
+	conds := m.channelStalls(cfg.Rules, hubs)
+	m.mu.Unlock()
+
+	if cfg.Rules.ProxyGroupDown {
+		conds = append(conds, proxyGroupsDown()...)
+	}
+	conds = append(conds, resourceUsage(cfg.Rules)...)
+
+	m.mu.Lock()
+	var notes []Notification
+	seen := make(map[string]bool, len(conds))
+	for _, c := range conds {
+		a := &Alert{Rule: c.rule, Target: c.target}
+		key := a.key()
+		seen[key] = true
+		if prev, ok := m.alerts[key]; ok {
+			a = prev
+		} else {
+			a.Since = now
+			m.alerts[key] = a
+		}
+		a.Message = c.message
+		switch {
+		case !a.Firing && now.Sub(a.Since) >= cfg.For:
+			a.Firing, a.Notified = true, now
+			notes = append(notes, newNotification(StatusFiring, a, now))
+		case a.Firing && cfg.Repeat > 0 && now.Sub(a.Notified) >= cfg.Repeat:
+			a.Notified = now
+			notes = append(notes, newNotification(StatusFiring, a, now))
+		}
+	}
+	for key, a := range m.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(m.alerts, key)
+		if a.Firing {
+			notes = append(notes, newNotification(StatusResolved, a, now))
+		}
+	}
+	sinks := cfg.Sinks
+	m.mu.Unlock()
+
+	for _, n := range notes {
+		if n.Status == StatusFiring {
+			logger.LogPrintf("🚨 告警: %s", n.Message)
+		} else {
+			logger.LogPrintf("✅ 告警恢复: %s", n.Message)
+		}
+		go sendAll(sinks, n)
+	}
+}
+
+// channelStalls 有客户端的频道在两次检查之间没有收到任何数据包；调用方需持有 m.mu
+func (m *Manager) channelStalls(rules config.AlertRules, hubs []monitor.HubUsage) []condition {
+	if !rules.ChannelStall {
+		m.packets = nil
+		return nil
+	}
+	var conds []condition
+	packets := make(map[string]uint64, len(hubs))
+	for _, h := range hubs {
+		packets[h.Name] = h.Packets
+		prev, ok := m.packets[h.Name]
+		if ok && h.Clients > 0 && h.Packets == prev {
+			conds = append(conds, condition{
+				rule:    RuleChannelStall,
+				target:  h.Name,
+				message: fmt.Sprintf("频道 %s 停止收到数据（%d 个客户端在观看）", h.Name, h.Clients),
+			})
+		}
+	}
+	m.packets = packets
+	return conds
+}
+
+// proxyGroupsDown 组内全部代理均不可用的代理组
+func proxyGroupsDown() []condition {
+	config.CfgMu.RLock()
+	groups := make(map[string]*config.ProxyGroupConfig, len(config.Cfg.ProxyGroups))
+	for name, g := range config.Cfg.ProxyGroups {
+		groups[name] = g
+	}
+	config.CfgMu.RUnlock()
+
+	var conds []condition
+	for name, g := range groups {
+		if g == nil || g.Stats == nil || len(g.Proxies) == 0 {
+			continue
+		}
+		down := 0
+		g.Stats.RLock()
+		for _, p := range g.Proxies {
+			ps := g.Stats.ProxyStats[p.Name]
+			if ps == nil {
+				continue
+			}
+			tested := ps.ResponseTime > 0 || ps.FailCount > 0
+			if ps.IsHealthDown() || (tested && !ps.Alive) {
+				down++
+			}
+		}
+		g.Stats.RUnlock()
+		if down == len(g.Proxies) {
+			conds = append(conds, condition{
+				rule:    RuleProxyGroupDown,
+				target:  name,
+				message: fmt.Sprintf("代理组 %s 的 %d 个代理全部不可用", name, down),
+			})
+		}
+	}
+	return conds
+}
+
+// resourceUsage 内存与磁盘使用率超过阈值
+func resourceUsage(rules config.AlertRules) []condition {
+	if rules.MemoryPercent <= 0 && rules.DiskPercent <= 0 {
+		return nil
+	}
+	ts := monitor.GlobalTrafficStats.GetTrafficStats()
+	var conds []condition
+	if rules.MemoryPercent > 0 && ts.MemoryTotal > 0 {
+		used := float64(ts.MemoryUsage) * 100 / float64(ts.MemoryTotal)
+		if used >= rules.MemoryPercent {
+			conds = append(conds, condition{
+				rule:    RuleMemory,
+				target:  "memory",
+				message: fmt.Sprintf("内存使用率 %.1f%% 超过 %.0f%%", used, rules.MemoryPercent),
+			})
+		}
+	}
+	if rules.DiskPercent > 0 {
+		for _, p := range ts.DiskPartitions {
+			if p.UsedPercent >= rules.DiskPercent {
+				conds = append(conds, condition{
+					rule:    RuleDisk,
+					target:  p.MountPoint,
+					message: fmt.Sprintf("磁盘分区 %s 使用率 %.1f%% 超过 %.0f%%", p.MountPoint, p.UsedPercent, rules.DiskPercent),
+				})
+			}
+		}
+	}
+	return conds
+}
+
+// Active 返回当前存在的异常，按开始时间排序
+func (m *Manager) Active() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Alert, 0, len(m.alerts))
+	for _, a := range m.alerts {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Since.Equal(list[j].Since) {
+			return list[i].Since.Before(list[j].Since)
+		}
+		return list[i].key() < list[j].key()
+	})
+	return list
+}
+
+// Test 向所有通知方式发送一条测试消息，返回各通知方式的错误
+func (m *Manager) Test() map[string]error {
+	m.mu.Lock()
+	sinks := m.cfg.Sinks
+	m.mu.Unlock()
+	now := time.Now()
+	return sendAll(sinks, newNotification(StatusTest, &Alert{
+		Rule: RuleTest, Target: "test", Message: "TVGate 告警测试消息", Since: now,
+	}, now))
+}
+
+// Notification 发送给通知方式的内容，webhook 以 JSON 发送
+type Notification struct {
+	Status   string    `json:"status"` // firing / resolved / test
+	Rule     string    `json:"rule"`
+	Target   string    `json:"target"`
+	Message  string    `json:"message"`
+	Host     string    `json:"host"`
+	Since    time.Time `json:"since"`
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration"` // 异常已持续的秒数
+}
+
+var hostname atomic.Value
+
+func host() string {
+	if h, ok := hostname.Load().(string); ok {
+		return h
+	}
+	h, _ := os.Hostname()
+	hostname.Store(h)
+	return h
+}
+
+func newNotification(status string, a *Alert, now time.Time) Notification {
+	return Notification{
+		Status:   status,
+		Rule:     a.Rule,
+		Target:   a.Target,
+		Message:  a.Message,
+		Host:     host(),
+		Since:    a.Since,
+		Time:     now,
+		Duration: now.Sub(a.Since).Round(time.Second).Seconds(),
+	}
+}
+
+// Text 通知的文本内容，用于 Telegram 与邮件
+func (n Notification) Text() string {
+	var title string
+	switch n.Status {
+	case StatusFiring:
+		title = "🚨 [TVGate 告警] "
+	case StatusResolved:
+		title = "✅ [TVGate 恢复] "
+	default:
+		title = "🔔 [TVGate 测试] "
+	}
+	text := title + n.Message + "\n主机: " + n.Host + "\n开始: " + n.Since.Format("2006-01-02 15:04:05")
+	if n.Status != StatusTest {
+		text += "\n持续: " + (time.Duration(n.Duration) * time.Second).String()
+	}
+	return text
+}
@@ -0,0 +1,187 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// sendTimeout 单个通知方式的发送超时
+const sendTimeout = 15 * time.Second
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// sendAll 并发发送到所有通知方式，返回失败的通知方式及错误
+func sendAll(sinks []config.AlertSink, n Notification) map[string]error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+	for i, sink := range sinks {
+		name := sink.Name
+		if name == "" {
+			name = fmt.Sprintf("%s#%d", sink.Type, i+1)
+		}
+		wg.Add(1)
+		go func(sink config.AlertSink) {
+			defer wg.Done()
+			if err := send(sink, n); err != nil {
+				logger.LogPrintf("❌ 告警通知发送失败 [%s]: %v", name, err)
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}(sink)
+	}
+	wg.Wait()
+	return errs
+}
+
+func send(sink config.AlertSink, n Notification) error {
+	switch strings.ToLower(sink.Type) {
+	case "webhook":
+		return sendWebhook(sink, n)
+	case "telegram":
+		return sendTelegram(sink, n)
+	case "email":
+		return sendEmail(sink, n)
+	default:
+		return fmt.Errorf("未知的通知方式 %q", sink.Type)
+	}
+}
+
+func sendWebhook(sink config.AlertSink, n Notification) error {
+	body, _ := json.Marshal(n)
+	req, err := http.NewRequest(http.MethodPost, sink.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range sink.Headers {
+		req.Header.Set(k, v)
+	}
+	return doRequest(req)
+}
+
+func sendTelegram(sink config.AlertSink, n Notification) error {
+	api := strings.TrimSuffix(sink.APIURL, "/")
+	if api == "" {
+		api = "https://api.telegram.org"
+	}
+	body, _ := json.Marshal(map[string]string{"chat_id": sink.ChatID, "text": n.Text()})
+	req, err := http.NewRequest(http.MethodPost, api+"/bot"+sink.BotToken+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(req)
+}
+
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 错误信息中的地址可能包含 Bot token，只返回底层错误
+		if ue, ok := err.(*url.Error); ok {
+			return fmt.Errorf("%s 请求失败: %w", req.URL.Host, ue.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sendEmail 465 端口直接使用 TLS，其他端口在服务器支持时使用 STARTTLS
+func sendEmail(sink config.AlertSink, n Notification) error {
+	if len(sink.To) == 0 {
+		return fmt.Errorf("未配置收件人")
+	}
+	host, port, err := net.SplitHostPort(sink.SMTP)
+	if err != nil {
+		return fmt.Errorf("smtp 应为 host:port: %w", err)
+	}
+	from := sink.From
+	if from == "" {
+		from = sink.Username
+	}
+
+	text := n.Text()
+	subject, _, _ := strings.Cut(text, "\n")
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: =?UTF-8?B?%s?=\r\nDate: %s\r\n",
+		from, strings.Join(sink.To, ", "), b64(subject), n.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+	body := b64(strings.ReplaceAll(text, "\n", "\r\n"))
+	for len(body) > 76 {
+		msg.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	msg.WriteString(body + "\r\n")
+
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", sink.SMTP, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", sink.SMTP)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if sink.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", sink.Username, sink.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range sink.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func b64(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
@@ -0,0 +1,324 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// 事件类型
+const (
+	EventStart = "start"
+	EventStop  = "stop"
+)
+
+// 查询默认返回条数
+const defaultQueryLimit = 100
+
+// Event token 播放事件
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"` // start / stop
+	Token     string    `json:"token"`
+	ConnID    string    `json:"conn_id"`
+	Channel   string    `json:"channel"` // 路径最后一段去掉扩展名
+	URL       string    `json:"url"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Bytes     int64     `json:"bytes,omitempty"`    // stop 事件：本次连接发送给客户端的字节数
+	Duration  float64   `json:"duration,omitempty"` // stop 事件：连接时长（秒）
+}
+
+// Filter 查询条件，零值字段不过滤
+type Filter struct {
+	Token   string
+	IP      string
+	Channel string
+	Type    string
+	Since   time.Time
+	Until   time.Time
+	Limit   int // 默认 100
+}
+
+func (f *Filter) match(ev *Event) bool {
+	return (f.Token == "" || ev.Token == f.Token) &&
+		(f.IP == "" || ev.ClientIP == f.IP) &&
+		(f.Channel == "" || ev.Channel == f.Channel) &&
+		(f.Type == "" || ev.Type == f.Type) &&
+		(f.Since.IsZero() || !ev.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || ev.Time.Before(f.Until))
+}
+
+// TokenSummary 单个 token 的使用汇总，IP 与 UA 数量多通常意味着 token 被共享
+type TokenSummary struct {
+	Token      string    `json:"token"`
+	Sessions   int       `json:"sessions"`
+	IPs        int       `json:"ips"`
+	UserAgents int       `json:"user_agents"`
+	Channels   int       `json:"channels"`
+	Bytes      int64     `json:"bytes"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Store 内存中的审计事件，可选以 JSON Lines 持久化到文件
+type Store struct {
+	mu       sync.RWMutex
+	cfg      config.AuditConfig
+	events   []Event // 按时间从早到晚
+	file     *os.File
+	appended int // 上次整理后追加到文件的事件数
+}
+
+// Default 全局审计存储
+var Default = &Store{}
+
+// Configure 应用配置；持久化文件变化时重新加载
+func (s *Store) Configure(cfg config.AuditConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fileChanged := cfg.File != s.cfg.File || !cfg.Enabled
+	s.cfg = cfg
+	if fileChanged && s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if !cfg.Enabled {
+		s.events = nil
+		return
+	}
+	if fileChanged && cfg.File != "" {
+		s.events = loadEvents(cfg.File)
+		s.prune(time.Now())
+		s.compact()
+	} else {
+		s.prune(time.Now())
+	}
+}
+
+// Record 记录事件，未启用时忽略
+func (s *Store) Record(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Enabled {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Channel == "" {
+		ev.Channel = channelOf(ev.URL)
+	}
+	s.events = append(s.events, ev)
+	s.prune(ev.Time)
+
+	if s.file == nil {
+		return
+	}
+	if data, err := json.Marshal(ev); err == nil {
+		if _, err := s.file.Write(append(data, '\n')); err != nil {
+			logger.LogPrintf("❌ 写入审计日志失败: %v", err)
+		}
+	}
+	s.appended++
+	// 文件中已过期的事件超过保留条数时整理一次
+	if s.appended > s.cfg.MaxEvents {
+		s.compact()
+	}
+}
+
+// Query 按条件查询，结果按时间从新到旧
+func (s *Store) Query(f Filter) []Event {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Event, 0)
+	for i := len(s.events) - 1; i >= 0 && len(list) < limit; i-- {
+		if f.match(&s.events[i]) {
+			list = append(list, s.events[i])
+		}
+	}
+	return list
+}
+
+// Summary 汇总 since 之后每个 token 的使用情况，按 IP 数从多到少排序
+func (s *Store) Summary(since time.Time) []TokenSummary {
+	type acc struct {
+		sum      TokenSummary
+		ips      map[string]struct{}
+		uas      map[string]struct{}
+		channels map[string]struct{}
+	}
+	byToken := make(map[string]*acc)
+
+	s.mu.RLock()
+	for i := range s.events {
+		ev := &s.events[i]
+		if ev.Time.Before(since) {
+			continue
+		}
+		a, ok := byToken[ev.Token]
+		if !ok {
+			a = &acc{
+				sum:      TokenSummary{Token: ev.Token},
+				ips:      make(map[string]struct{}),
+				uas:      make(map[string]struct{}),
+				channels: make(map[string]struct{}),
+			}
+			byToken[ev.Token] = a
+		}
+		switch ev.Type {
+		case EventStart:
+			a.sum.Sessions++
+		case EventStop:
+			a.sum.Bytes += ev.Bytes
+		}
+		a.ips[ev.ClientIP] = struct{}{}
+		if ev.UserAgent != "" {
+			a.uas[ev.UserAgent] = struct{}{}
+		}
+		if ev.Channel != "" {
+			a.channels[ev.Channel] = struct{}{}
+		}
+		if ev.Time.After(a.sum.LastSeen) {
+			a.sum.LastSeen = ev.Time
+		}
+	}
+	s.mu.RUnlock()
+
+	list := make([]TokenSummary, 0, len(byToken))
+	for _, a := range byToken {
+		a.sum.IPs = len(a.ips)
+		a.sum.UserAgents = len(a.uas)
+		a.sum.Channels = len(a.channels)
+		list = append(list, a.sum)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].IPs != list[j].IPs {
+			return list[i].IPs > list[j].IPs
+		}
+		return list[i].Token < list[j].Token
+	})
+	return list
+}
+
+// prune 按保留时长与条数删除最早的事件，调用方持有锁
+func (s *Store) prune(now time.Time) {
+	drop := 0
+	if s.cfg.Retention > 0 {
+		cutoff := now.Add(-s.cfg.Retention)
+		for drop < len(s.events) && s.events[drop].Time.Before(cutoff) {
+			drop++
+		}
+	}
+	if s.cfg.MaxEvents > 0 && len(s.events)-drop > s.cfg.MaxEvents {
+		drop = len(s.events) - s.cfg.MaxEvents
+	}
+	if drop > 0 {
+		s.events = s.events[drop:]
+	}
+}
+
+// compact 用内存中的事件重写持久化文件并重新打开，调用方持有锁
+func (s *Store) compact() {
+	if s.cfg.File == "" {
+		return
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	tmp := s.cfg.File + ".tmp"
+	if err := writeEvents(tmp, s.events); err != nil {
+		logger.LogPrintf("❌ 整理审计日志失败: %v", err)
+	} else if err := os.Rename(tmp, s.cfg.File); err != nil {
+		logger.LogPrintf("❌ 整理审计日志失败: %v", err)
+	}
+	f, err := os.OpenFile(s.cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.LogPrintf("❌ 打开审计日志文件失败: %v", err)
+		return
+	}
+	s.file = f
+	s.appended = 0
+}
+
+func writeEvents(name string, events []Event) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadEvents 读取持久化文件，损坏的行跳过
+func loadEvents(name string) []Event {
+	f, err := os.Open(name)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取审计日志失败: %v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var ev Event
+		if json.Unmarshal(scanner.Bytes(), &ev) == nil {
+			events = append(events, ev)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+// channelOf 取 URL 路径最后一段去掉扩展名作为频道名
+func channelOf(raw string) string {
+	p := raw
+	if u, err := url.Parse(raw); err == nil {
+		p = u.Path
+	}
+	base := path.Base(strings.TrimRight(p, "/"))
+	if base == "." || base == "/" {
+		return ""
+	}
+	// 只去掉文件扩展名，保留 239.1.1.1:5000 这类地址
+	if ext := path.Ext(base); strings.IndexFunc(ext, unicode.IsLetter) >= 0 {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return base
+}
+
+// Configure 更新全局审计存储配置
+func Configure(cfg config.AuditConfig) { Default.Configure(cfg) }
+
+// Record 记录事件到全局审计存储
+func Record(ev Event) { Default.Record(ev) }
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"path"
+	"strings"
+
+	"github.com/qist/tvgate/logger"
+)
+
+// TokenACL token 的访问控制列表。条目含 / 时按路径匹配（前缀，或含 * ? [ 时按通配符），
+// 否则按频道名（路径最后一段去掉扩展名）匹配，频道名同样支持通配符
+type TokenACL struct {
+	Allow []string `json:"allow,omitempty"` // 为空不限制
+	Deny  []string `json:"deny,omitempty"`  // 优先于 Allow
+}
+
+// Allows 判断是否允许访问该路径
+func (a TokenACL) Allows(urlPath string) bool {
+	if len(a.Allow) == 0 && len(a.Deny) == 0 {
+		return true
+	}
+	channel := channelName(urlPath)
+	for _, entry := range a.Deny {
+		if matchACLEntry(entry, urlPath, channel) {
+			return false
+		}
+	}
+	if len(a.Allow) == 0 {
+		return true
+	}
+	for _, entry := range a.Allow {
+		if matchACLEntry(entry, urlPath, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchACLEntry(entry, urlPath, channel string) bool {
+	if entry == "" {
+		return false
+	}
+	hasGlob := strings.ContainsAny(entry, "*?[")
+	if !strings.Contains(entry, "/") {
+		if hasGlob {
+			ok, _ := path.Match(entry, channel)
+			return ok
+		}
+		return entry == channel
+	}
+	if hasGlob {
+		ok, _ := path.Match(entry, urlPath)
+		return ok
+	}
+	return strings.HasPrefix(urlPath, entry)
+}
+
+// channelName 取路径最后一段作为频道名，如 /live/cctv1.m3u8 -> cctv1
+func channelName(p string) string {
+	base := path.Base(strings.TrimRight(p, "/"))
+	if base == "." || base == "/" {
+		return ""
+	}
+	if ext := path.Ext(base); ext != "" {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return base
+}
+
+// aclAllows 检查 ACL，拒绝时记录日志
+func aclAllows(acl TokenACL, token, urlPath string) bool {
+	if acl.Allows(urlPath) {
+		return true
+	}
+	logger.LogPrintf("🚫 token无权访问: %s, url: %s", logger.MaskToken(token), urlPath)
+	return false
+}
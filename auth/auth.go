@@ -8,14 +8,17 @@ import (
 	"encoding/base64"
 	"errors"
 	"io"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/qist/tvgate/bruteforce"
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/quota"
 )
 
 // 全局Token管理器
@@ -96,6 +99,15 @@ func ReloadGlobalTokenManager(cfg *config.AuthConfig) {
 		// logger.LogPrintf("没有旧的Token管理器或旧管理器中没有静态token")
 	}
 
+	// 保留通过接口签发的 token
+	if GlobalTokenManager != nil {
+		GlobalTokenManager.mu.RLock()
+		for token, it := range GlobalTokenManager.issued {
+			newTM.issued[token] = it
+		}
+		GlobalTokenManager.mu.RUnlock()
+	}
+
 	// 替换全局管理器
 	GlobalTokenManager = newTM
 	// logger.LogPrintf("✅ 全局TokenManager已热更新完成，最终静态tokens数量: %d", len(GlobalTokenManager.StaticTokens))
@@ -128,6 +140,14 @@ type TokenManager struct {
 	StaticTokens  map[string]*SessionInfo
 	DynamicTokens map[string]*SessionInfo
 	DynamicConfig *DynamicTokenConfig
+	JWT           *jwtVerifier // JWT 校验，未启用时为 nil
+	Signed        *urlSigner   // 签名 URL 校验，未启用时为 nil
+
+	// 通过接口签发的 token
+	issued map[string]*IssuedToken
+
+	// 同时会话数限制，未配置时为 nil
+	sessions *sessionLimit
 
 	// 记录token类型，避免在错误的映射中查找
 	tokenTypes map[string]string // "static" or "dynamic"
@@ -143,6 +163,7 @@ type SessionInfo struct {
 	IP             string
 	URL            string
 	OriginalURL    string // 记录首次访问的URL
+	ACL            TokenACL // 允许/禁止访问的频道或路径
 }
 
 // DynamicTokenConfig 动态 token 配置
@@ -150,6 +171,7 @@ type DynamicTokenConfig struct {
 	Secret string
 	Salt   string
 	TTL    time.Duration
+	ACL    TokenACL
 }
 
 // ---------------------------
@@ -173,6 +195,8 @@ func NewTokenManagerFromConfig(cfg *DomainMapConfig) *TokenManager {
 		StaticTokens:   make(map[string]*SessionInfo),
 		DynamicTokens:  make(map[string]*SessionInfo),
 		tokenTypes:     make(map[string]string),
+		issued:         make(map[string]*IssuedToken),
+		sessions:       newSessionLimit(cfg.Auth.MaxSessions, cfg.Auth.MaxSessionsPerIP, cfg.Auth.SessionLimitAction),
 	}
 
 	// 处理静态 token
@@ -181,7 +205,8 @@ func NewTokenManagerFromConfig(cfg *DomainMapConfig) *TokenManager {
 		staticTokenStatesMutex.Lock()
 		// 检查是否已经存在该token的状态
 		if existingSession, exists := staticTokenStates[st.Token]; exists {
-			// 复用已存在的会话信息
+			// 复用已存在的会话信息，ACL 以最新配置为准
+			existingSession.ACL = TokenACL{Allow: st.Allow, Deny: st.Deny}
 			tm.StaticTokens[st.Token] = existingSession
 			// logger.LogPrintf("复用已存在的静态token会话信息: %s", st.Token)
 		} else {
@@ -189,6 +214,7 @@ func NewTokenManagerFromConfig(cfg *DomainMapConfig) *TokenManager {
 			newSession := &SessionInfo{
 				Token:          st.Token,
 				ExpireDuration: st.ExpireHours,
+				ACL:            TokenACL{Allow: st.Allow, Deny: st.Deny},
 			}
 			tm.StaticTokens[st.Token] = newSession
 			staticTokenStates[st.Token] = newSession
@@ -206,10 +232,29 @@ func NewTokenManagerFromConfig(cfg *DomainMapConfig) *TokenManager {
 			Secret: cfg.Auth.DynamicTokens.Secret,
 			Salt:   cfg.Auth.DynamicTokens.Salt,
 			TTL:    cfg.Auth.DynamicTokens.DynamicTTL,
+			ACL:    TokenACL{Allow: cfg.Auth.DynamicTokens.Allow, Deny: cfg.Auth.DynamicTokens.Deny},
 		}
 		// logger.LogPrintf("已配置动态token")
 	}
 
+	// 处理 JWT token 配置
+	if cfg.Auth.JWTTokens.EnableJWT {
+		if v, err := newJWTVerifier(cfg.Auth.JWTTokens); err != nil {
+			logger.LogPrintf("❌ JWT token 配置错误: %v", err)
+		} else {
+			tm.JWT = v
+		}
+	}
+
+	// 处理签名 URL 配置
+	if cfg.Auth.SignedURL.EnableSigned {
+		if cfg.Auth.SignedURL.Secret == "" {
+			logger.LogPrintf("❌ 签名 URL 配置错误: 未配置 secret")
+		} else {
+			tm.Signed = newURLSigner(cfg.Auth.SignedURL)
+		}
+	}
+
 	// logger.LogPrintf("Token管理器创建完成")
 	return tm
 }
@@ -257,9 +302,44 @@ func (tm *TokenManager) KeepAlive(token, connID, ip, urlPath string) {
 }
 
 // ---------------------------
-// 验证 token（结合在线状态）
+// 验证 token（结合在线状态）及同时会话数限制
+// 封禁、JWT 的 IP 绑定与按 IP 的会话数限制按 monitor.TrustedClientIP 判断，客户端不能通过伪造 X-Forwarded-For 绕过或嫁祸他人
 // ---------------------------
-func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
+func (tm *TokenManager) ValidateToken(r *http.Request, token, connID, clientIP string) bool {
+	urlPath := r.URL.Path
+	trustedIP := monitor.TrustedClientIP(r)
+	if tm.Enabled {
+		if remaining, banned := bruteforce.Banned(trustedIP); banned {
+			logger.LogPrintf("🚫 IP已被封禁: %s, 剩余 %s, url: %s", trustedIP, remaining.Round(time.Second), urlPath)
+			return false
+		}
+	}
+	if tm.Enabled {
+		// 其它实例签发、尚未同步到本实例的 token
+		tm.loadIssued(token)
+	}
+	if !tm.validateToken(token, urlPath, connID, trustedIP) && !tm.validateRemote(token, urlPath, trustedIP) {
+		// 只统计携带了错误 token 的请求，未带 token 的普通访问不计入；
+		// 同一个 token 在时间窗口内只计一次，播放器用过期 token 连续请求 HLS 分片不会被当作暴力破解
+		if tm.Enabled && token != "" {
+			bruteforce.FailOnce(trustedIP, bruteforce.ReasonToken, token)
+		}
+		return false
+	}
+	if !tm.Enabled || token == "" {
+		return true
+	}
+	if quota.Exceeded(token) {
+		logger.LogPrintf("🚫 token流量配额已用完: %s, ip: %s, url: %s", logger.MaskToken(token), clientIP, urlPath)
+		return false
+	}
+	if tm.sessions == nil {
+		return true
+	}
+	return tm.sessions.allow(token, connID, trustedIP)
+}
+
+func (tm *TokenManager) validateToken(token, urlPath, connID, clientIP string) bool {
 	// logger.LogPrintf("开始验证token: %s, url: %s, connID: %s", token, urlPath, connID)
 	// logger.LogPrintf("Token管理器启用状态: %v", tm.Enabled)
 	// logger.LogPrintf("Token管理器地址: %p", tm)
@@ -334,6 +414,17 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 		return true // 未过期
 	}
 
+	// ---------------------------
+	// 通过接口签发的 token，按到期时间校验
+	// ---------------------------
+	if it, ok := tm.issued[token]; ok {
+		if it.expired(now) {
+			logger.LogPrintf("签发token已过期: %s, url: %s, connID: %s", logger.MaskToken(token), urlPath, connID)
+			return false
+		}
+		return aclAllows(it.TokenACL, token, urlPath)
+	}
+
 	// ---------------------------
 	// 检查token类型（如果已记录）
 	// ---------------------------
@@ -366,7 +457,7 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 
 				logger.LogPrintf(
 					"静态token验证结果: %s, url: %s, originalURL: %s, ip: %s, connID: %s, ExpireDuration: %s, FirstAccessAt: %s, LastActiveAt: %s, expired: %v",
-					token,
+					logger.MaskToken(token),
 					urlPath,
 					sess.OriginalURL,
 					sess.IP,
@@ -376,7 +467,7 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 					lastActiveAt.Format("2006-01-02 15:04:05"),
 					expired,
 				)
-				return !expired
+				return !expired && aclAllows(sess.ACL, token, urlPath)
 			} else {
 				// logger.LogPrintf("静态token未找到: %s", token)
 				// 打印所有可用的静态token用于调试
@@ -387,6 +478,13 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 		}
 	}
 
+	// ---------------------------
+	// JWT token 无需服务端记录，每次请求校验签名与声明
+	// ---------------------------
+	if tm.JWT != nil && isJWT(token) {
+		return tm.validateJWT(token, urlPath, connID, clientIP)
+	}
+
 	// ---------------------------
 	// 如果记录为动态token或未记录类型，尝试验证动态 token
 	// ---------------------------
@@ -433,7 +531,7 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 
 								expired := !checkSession(&SessionInfo{FirstAccessAt: time.Unix(tsUnix, 0), ExpireDuration: tm.DynamicConfig.TTL})
 								// logger.LogPrintf("动态token验证成功: %s, url: %s, expired: %v", token, urlPath, expired)
-								return !expired
+								return !expired && aclAllows(tm.DynamicConfig.ACL, token, urlPath)
 							} else {
 								logger.LogPrintf("动态token已过期: 时间戳 %s, TTL %s", time.Unix(tsUnix, 0).Format("2006-01-02 15:04:05"), tm.DynamicConfig.TTL)
 							}
@@ -441,10 +539,10 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 							logger.LogPrintf("动态token时间戳解析失败: %v, 时间戳字符串: %s", err, tsStr)
 						}
 					} else {
-						logger.LogPrintf("动态token salt不匹配: 期望 %s, 实际 %s", tm.DynamicConfig.Salt, salt)
+						logger.LogPrintf("动态token salt不匹配: 期望 %s, 实际 %s", logger.MaskToken(tm.DynamicConfig.Salt), logger.MaskToken(salt))
 					}
 				} else {
-					logger.LogPrintf("动态token格式错误: %s", plain)
+					logger.LogPrintf("动态token格式错误: %s", logger.MaskToken(plain))
 				}
 			} else {
 				logger.LogPrintf("动态token解密结果为空")
@@ -455,7 +553,7 @@ func (tm *TokenManager) ValidateToken(token, urlPath, connID string) bool {
 	// ---------------------------
 	// token 验证失败
 	// ---------------------------
-	logger.LogPrintf("Token验证失败: %s, url: %s, connID: %s", token, urlPath, connID)
+	logger.LogPrintf("Token验证失败: %s, url: %s, connID: %s", logger.MaskToken(token), urlPath, connID)
 	return false
 }
 
@@ -533,6 +631,18 @@ func (tm *TokenManager) CleanupExpiredSessions() {
 			delete(tm.DynamicTokens, token)
 		}
 	}
+
+	// 清理过期的签发 token
+	for token, it := range tm.issued {
+		if it.expired(now) {
+			delete(tm.issued, token)
+		}
+	}
+
+	// 清理 JWT 已断开的连接记录
+	if tm.JWT != nil {
+		tm.JWT.cleanupConns()
+	}
 }
 
 // ---------------------------
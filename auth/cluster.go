@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/quota"
+)
+
+// 集群签名参数，与签名 URL 使用相同的 HMAC 算法，但独立于 signed_url 配置
+const (
+	clusterSigParam     = "cluster_sig"
+	clusterExpiresParam = "cluster_expires"
+)
+
+var (
+	clusterMu     sync.RWMutex
+	clusterSecret []byte
+	// remoteValidator 本地校验失败时复核 token，边缘节点设置为向源站查询
+	remoteValidator func(token, urlPath, clientIP string) bool
+)
+
+// SetCluster 设置集群共享密钥与远程 token 校验，secret 为空时不接受集群签名，validate 为 nil 时不复核
+func SetCluster(secret string, validate func(token, urlPath, clientIP string) bool) {
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	clusterSecret = []byte(secret)
+	remoteValidator = validate
+}
+
+// clusterMAC 集群签名覆盖路径与除签名外的全部查询参数（按参数名排序编码），查询参数不能被篡改
+func clusterMAC(secret []byte, u *url.URL, expires int64) []byte {
+	q := u.Query()
+	q.Del(clusterSigParam)
+	return urlMAC(secret, u.Path+"?"+q.Encode(), expires, "")
+}
+
+// SignClusterURL 为回源地址添加集群签名，源站据此放行不带 token 的请求
+func SignClusterURL(u *url.URL, ttl time.Duration) {
+	clusterMu.RLock()
+	secret := clusterSecret
+	clusterMu.RUnlock()
+	if len(secret) == 0 {
+		return
+	}
+	expires := time.Now().Add(ttl).Unix()
+	q := u.Query()
+	q.Del(clusterSigParam)
+	q.Set(clusterExpiresParam, strconv.FormatInt(expires, 10))
+	u.RawQuery = q.Encode()
+	q.Set(clusterSigParam, base64.RawURLEncoding.EncodeToString(clusterMAC(secret, u, expires)))
+	u.RawQuery = q.Encode()
+}
+
+// VerifyClusterURL 校验请求是否由持有集群密钥的节点签名
+func VerifyClusterURL(u *url.URL) bool {
+	clusterMu.RLock()
+	secret := clusterSecret
+	clusterMu.RUnlock()
+	if len(secret) == 0 {
+		return false
+	}
+	q := u.Query()
+	sig, expiresStr := q.Get(clusterSigParam), q.Get(clusterExpiresParam)
+	if sig == "" || expiresStr == "" {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	return err == nil && hmac.Equal(got, clusterMAC(secret, u, expires))
+}
+
+// CheckToken 校验 token 是否有效且允许访问 urlPath，不记录会话与失败次数，供源站替边缘节点校验
+func (tm *TokenManager) CheckToken(token, urlPath, clientIP string) bool {
+	if !tm.Enabled {
+		return true
+	}
+	return tm.validateToken(token, urlPath, "", clientIP) && !quota.Exceeded(token)
+}
+
+// validateRemote 全局 token 本地校验失败时交给远程复核
+func (tm *TokenManager) validateRemote(token, urlPath, clientIP string) bool {
+	if !tm.Enabled || token == "" || tm != GetGlobalTokenManager() {
+		return false
+	}
+	clusterMu.RLock()
+	validate := remoteValidator
+	clusterMu.RUnlock()
+	return validate != nil && validate(token, urlPath, clientIP)
+}
+
+// StripClusterParams 去掉查询字符串中的集群签名参数，源站转发到上游前使用，避免同一频道因签名不同重复拉流
+func StripClusterParams(rawQuery string) string {
+	if !strings.Contains(rawQuery, clusterSigParam+"=") {
+		return rawQuery
+	}
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, kv := range parts {
+		if !strings.HasPrefix(kv, clusterSigParam+"=") && !strings.HasPrefix(kv, clusterExpiresParam+"=") {
+			kept = append(kept, kv)
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -7,13 +7,27 @@ import (
 
 // DomainMapConfig 域名映射配置结构
 type DomainMapConfig struct {
-	Name          string            `yaml:"name"`           // 配置名称
-	Source        string            `yaml:"source"`         // 源域名
-	Target        string            `yaml:"target"`         // 目标域名
-	Protocol      string            `yaml:"protocol"`       // 协议 http/https
-	Auth          config.AuthConfig `yaml:"auth"`           // 动态/静态 token 配置
-	ClientHeaders map[string]string `yaml:"client_headers"` // 前端请求使用
-	ServerHeaders map[string]string `yaml:"server_headers"` // 后端请求使用
+	Name             string              `yaml:"name"`              // 配置名称
+	Source           string              `yaml:"source"`            // 源域名
+	Target           string              `yaml:"target"`            // 目标域名
+	Targets          []string            `yaml:"targets"`           // 备用目标
+	FailoverCooldown time.Duration       `yaml:"failover_cooldown"` // 失败目标的下线时长
+	Protocol         string              `yaml:"protocol"`          // 协议 http/https
+	Auth             config.AuthConfig   `yaml:"auth"`              // 动态/静态 token 配置
+	ClientHeaders    map[string]string   `yaml:"client_headers"`    // 前端请求使用
+	ServerHeaders    map[string]string   `yaml:"server_headers"`    // 后端请求使用
+	HeaderRules      []config.HeaderRule `yaml:"header_rules"`      // 该映射的头部改写规则
+	PathPrefix       string              `yaml:"path_prefix"`       // 源路径前缀，为空匹配全部路径；同一域名下最长前缀优先
+	StripPrefix      bool                `yaml:"strip_prefix"`      // 转发时去掉 path_prefix
+	TargetPath       string              `yaml:"target_path"`       // 转发时拼接到路径前的前缀
+
+	TLS                   *config.DomainMapTLSConfig `yaml:"tls,omitempty"`           // 后端 TLS 配置
+	ConnectTimeout        time.Duration              `yaml:"connect_timeout"`         // 建立连接超时
+	ResponseHeaderTimeout time.Duration              `yaml:"response_header_timeout"` // 等待响应头超时
+	Timeout               time.Duration              `yaml:"timeout"`                 // 整个请求超时
+	Retries               int                        `yaml:"retries"`                 // 请求失败重试次数
+	RetryDelay            time.Duration              `yaml:"retry_delay"`             // 重试间隔
+	BodyRewrite           []config.BodyRewriteRule   `yaml:"body_rewrite"`            // 响应体改写规则
 }
 
 // AuthConfig 授权 token 配置
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/quota"
+)
+
+// IssuedToken 运行时通过接口签发的 token
+type IssuedToken struct {
+	Token     string    `json:"token"`
+	Note      string    `json:"note,omitempty"` // 备注，如计费系统中的用户ID
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // 为零值表示永不过期
+	TokenACL
+	DailyMB   int64 `json:"daily_mb,omitempty"`   // 每日流量配额(MB)，0 使用 quota 中的配额；quota.tokens 中单独配置的优先
+	MonthlyMB int64 `json:"monthly_mb,omitempty"` // 每月流量配额(MB)，同上
+}
+
+func init() {
+	quota.SetTokenLimits(issuedQuota)
+}
+
+// issuedQuota 签发 token 自带的流量配额，需启用 quota
+func issuedQuota(token string) (config.TokenQuota, bool) {
+	tm := GetGlobalTokenManager()
+	if tm == nil {
+		return config.TokenQuota{}, false
+	}
+	tm.mu.RLock()
+	it, ok := tm.issued[token]
+	tm.mu.RUnlock()
+	if !ok || it.DailyMB <= 0 && it.MonthlyMB <= 0 {
+		return config.TokenQuota{}, false
+	}
+	return config.TokenQuota{DailyMB: it.DailyMB, MonthlyMB: it.MonthlyMB}, true
+}
+
+func (it *IssuedToken) expired(now time.Time) bool {
+	return !it.ExpiresAt.IsZero() && now.After(it.ExpiresAt)
+}
+
+// ErrTokenNotFound token 不存在或已过期
+var ErrTokenNotFound = errors.New("token 不存在")
+
+// IssueToken 签发 token，配置了共享存储时同时写入，其它实例可以立即使用；token 为空时随机生成；ttl<=0 表示永不过期，acl 限制可访问的频道或路径
+func (tm *TokenManager) IssueToken(token, note string, ttl time.Duration, acl TokenACL) (IssuedToken, error) {
+	if token == "" {
+		var err error
+		if token, err = randomToken(); err != nil {
+			return IssuedToken{}, err
+		}
+	}
+
+	tm.mu.Lock()
+	if _, exists := tm.StaticTokens[token]; exists {
+		tm.mu.Unlock()
+		return IssuedToken{}, errors.New("token 与静态 token 冲突")
+	}
+	now := time.Now()
+	it := &IssuedToken{Token: token, Note: note, CreatedAt: now, TokenACL: acl}
+	if ttl > 0 {
+		it.ExpiresAt = now.Add(ttl)
+	}
+	tm.issued[token] = it
+	tm.mu.Unlock()
+	storeIssued(*it)
+	return *it, nil
+}
+
+// randomToken 随机生成 32 位十六进制 token
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ExtendToken 延长 token 有效期：从当前到期时间（已过期则从现在）起再延长 ttl；ttl<=0 表示改为永不过期
+func (tm *TokenManager) ExtendToken(token string, ttl time.Duration) (IssuedToken, error) {
+	tm.loadIssued(token)
+	tm.mu.Lock()
+	it, ok := tm.issued[token]
+	if !ok {
+		tm.mu.Unlock()
+		return IssuedToken{}, ErrTokenNotFound
+	}
+	now := time.Now()
+	switch {
+	case ttl <= 0:
+		it.ExpiresAt = time.Time{}
+	case it.ExpiresAt.IsZero():
+		// 永不过期的 token 保持不变
+	case it.ExpiresAt.Before(now):
+		it.ExpiresAt = now.Add(ttl)
+	default:
+		it.ExpiresAt = it.ExpiresAt.Add(ttl)
+	}
+	res := *it
+	tm.mu.Unlock()
+	storeIssued(res)
+	return res, nil
+}
+
+// RevokeToken 吊销签发的 token，立即生效
+func (tm *TokenManager) RevokeToken(token string) error {
+	tm.mu.Lock()
+	_, ok := tm.issued[token]
+	delete(tm.issued, token)
+	tm.mu.Unlock()
+	// 其它实例签发、尚未同步到本实例的 token 也可以吊销
+	if shared := deleteIssued(token); !ok && !shared {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// IssuedTokens 返回所有未过期的签发 token，按签发时间排序
+func (tm *TokenManager) IssuedTokens() []IssuedToken {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := time.Now()
+	list := make([]IssuedToken, 0, len(tm.issued))
+	for _, it := range tm.issued {
+		if !it.expired(now) {
+			list = append(list, *it)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// HasIssuedTokens 是否存在签发的 token
+func (tm *TokenManager) HasIssuedTokens() bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return len(tm.issued) > 0
+}
+
+// RestoreIssuedTokens 从备份恢复签发的 token，已过期或与静态 token 冲突的跳过，同名 token 被覆盖；
+// 返回恢复的数量
+func (tm *TokenManager) RestoreIssuedTokens(list []IssuedToken) int {
+	tm.mu.Lock()
+	now := time.Now()
+	var restored []IssuedToken
+	for i := range list {
+		it := list[i]
+		if it.Token == "" || it.expired(now) {
+			continue
+		}
+		if _, exists := tm.StaticTokens[it.Token]; exists {
+			continue
+		}
+		tm.issued[it.Token] = &it
+		restored = append(restored, it)
+	}
+	tm.mu.Unlock()
+	for _, it := range restored {
+		storeIssued(it)
+	}
+	return len(restored)
+}
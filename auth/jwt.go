@@ -0,0 +1,382 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// JWTClaims JWT 中识别的声明
+type JWTClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt int64       `json:"exp"` // 过期时间（Unix 秒），为 0 不过期
+	NotBefore int64       `json:"nbf"` // 生效时间（Unix 秒）
+	ID        string      `json:"jti"`
+
+	Channels     []string `json:"channels"`      // 允许访问的频道名或路径模式，为空不限制
+	DenyChannels []string `json:"deny_channels"` // 禁止访问的频道名或路径模式
+	MaxConns     int      `json:"max_conns"`     // 最大并发连接数，0 不限制
+	IP           string   `json:"ip"`            // 绑定的客户端 IP 或网段，为空不限制
+}
+
+// jwtAudience aud 既可以是字符串也可以是字符串数组
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// jwtVerifier 校验 JWT 签名与声明
+type jwtVerifier struct {
+	alg      string
+	secret   []byte
+	pubKey   *rsa.PublicKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+
+	// 并发连接按 jti（其次 sub，再次 token 本身）统计：key -> connID
+	connsMu sync.Mutex
+	conns   map[string]map[string]struct{}
+}
+
+func newJWTVerifier(cfg config.JWTToken) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		alg:      strings.ToUpper(cfg.Algorithm),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway,
+		conns:    make(map[string]map[string]struct{}),
+	}
+	if v.alg == "" {
+		v.alg = "HS256"
+	}
+	switch v.alg {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("HS256 需要配置 secret")
+		}
+		v.secret = []byte(cfg.Secret)
+	case "RS256":
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取公钥失败: %w", err)
+		}
+		key, err := parseRSAPublicKey(data)
+		if err != nil {
+			return nil, err
+		}
+		v.pubKey = key
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", cfg.Algorithm)
+	}
+	return v, nil
+}
+
+// parseRSAPublicKey 支持 PKIX 公钥、PKCS1 公钥与证书
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("公钥不是 PEM 格式")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("公钥不是 RSA 公钥")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		if rsaKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+	}
+	return nil, errors.New("无法解析 RSA 公钥")
+}
+
+// parseRSAPrivateKey 支持 PKCS1 与 PKCS8 私钥
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("私钥不是 PEM 格式")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("无法解析 RSA 私钥")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥不是 RSA 私钥")
+	}
+	return rsaKey, nil
+}
+
+// SignJWT 按配置的算法签发 JWT：HS256 使用配置的 secret，RS256 使用 privateKey（PEM）；
+// 未设置的声明不写入，iss/aud 为空时使用配置中校验的值
+func SignJWT(cfg config.JWTToken, claims JWTClaims, privateKey []byte) (string, error) {
+	alg := strings.ToUpper(cfg.Algorithm)
+	if alg == "" {
+		alg = "HS256"
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = cfg.Issuer
+	}
+	if len(claims.Audience) == 0 && cfg.Audience != "" {
+		claims.Audience = jwtAudience{cfg.Audience}
+	}
+
+	payload := map[string]any{}
+	set := func(key string, v any, ok bool) {
+		if ok {
+			payload[key] = v
+		}
+	}
+	set("sub", claims.Subject, claims.Subject != "")
+	set("iss", claims.Issuer, claims.Issuer != "")
+	set("aud", []string(claims.Audience), len(claims.Audience) > 0)
+	set("exp", claims.ExpiresAt, claims.ExpiresAt > 0)
+	set("nbf", claims.NotBefore, claims.NotBefore > 0)
+	set("iat", time.Now().Unix(), true)
+	set("jti", claims.ID, claims.ID != "")
+	set("channels", claims.Channels, len(claims.Channels) > 0)
+	set("deny_channels", claims.DenyChannels, len(claims.DenyChannels) > 0)
+	set("max_conns", claims.MaxConns, claims.MaxConns > 0)
+	set("ip", claims.IP, claims.IP != "")
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		if cfg.Secret == "" {
+			return "", errors.New("HS256 需要配置 secret")
+		}
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		key, err := parseRSAPrivateKey(privateKey)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("不支持的签名算法: %s", cfg.Algorithm)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// isJWT 粗略判断 token 是否为 JWT 格式
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// parse 校验签名与时间、签发方声明，返回声明内容
+func (v *jwtVerifier) parse(token string, now time.Time) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("格式错误")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("header 解码失败")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.New("header 解析失败")
+	}
+	// 只接受配置的算法，防止算法替换攻击
+	if header.Alg != v.alg {
+		return nil, fmt.Errorf("签名算法不匹配: %s", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("签名解码失败")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch v.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errors.New("签名无效")
+		}
+	case "RS256":
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(v.pubKey, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, errors.New("签名无效")
+		}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("payload 解码失败")
+	}
+	var claims JWTClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("payload 解析失败")
+	}
+
+	if claims.ExpiresAt > 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return nil, errors.New("已过期")
+	}
+	if claims.NotBefore > 0 && now.Add(v.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("尚未生效")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("iss 不匹配: %s", claims.Issuer)
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return nil, errors.New("aud 不匹配")
+	}
+	return &claims, nil
+}
+
+func (c *JWTClaims) hasAudience(aud string) bool {
+	for _, a := range c.Audience {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// allowIP 判断客户端 IP 是否与绑定的 IP/网段一致
+func (c *JWTClaims) allowIP(clientIP string) bool {
+	if c.IP == "" {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	if strings.Contains(c.IP, "/") {
+		_, ipNet, err := net.ParseCIDR(c.IP)
+		return err == nil && ipNet.Contains(ip)
+	}
+	bound := net.ParseIP(c.IP)
+	return bound != nil && bound.Equal(ip)
+}
+
+// connKey 并发连接的统计维度
+func (c *JWTClaims) connKey(token string) string {
+	if c.ID != "" {
+		return "jti:" + c.ID
+	}
+	if c.Subject != "" {
+		return "sub:" + c.Subject
+	}
+	return "token:" + token
+}
+
+// acquireConn 记录连接并检查并发数；已断开的连接不计入
+func (v *jwtVerifier) acquireConn(claims *JWTClaims, token, connID string) bool {
+	if claims.MaxConns <= 0 || connID == "" {
+		return true
+	}
+	key := claims.connKey(token)
+
+	v.connsMu.Lock()
+	defer v.connsMu.Unlock()
+	conns := v.conns[key]
+	if conns == nil {
+		conns = make(map[string]struct{})
+		v.conns[key] = conns
+	}
+	if _, ok := conns[connID]; ok {
+		return true
+	}
+	for id := range conns {
+		if monitor.ActiveClients.GetConnectionByID(id) == nil {
+			delete(conns, id)
+		}
+	}
+	if len(conns) >= claims.MaxConns {
+		return false
+	}
+	conns[connID] = struct{}{}
+	return true
+}
+
+// cleanupConns 清理已断开的连接记录
+func (v *jwtVerifier) cleanupConns() {
+	v.connsMu.Lock()
+	defer v.connsMu.Unlock()
+	for key, conns := range v.conns {
+		for id := range conns {
+			if monitor.ActiveClients.GetConnectionByID(id) == nil {
+				delete(conns, id)
+			}
+		}
+		if len(conns) == 0 {
+			delete(v.conns, key)
+		}
+	}
+}
+
+// validateJWT 校验 JWT 及其频道、IP 绑定和并发数限制
+func (tm *TokenManager) validateJWT(token, urlPath, connID, clientIP string) bool {
+	claims, err := tm.JWT.parse(token, time.Now())
+	if err != nil {
+		logger.LogPrintf("JWT token验证失败: %v, url: %s, connID: %s", err, urlPath, connID)
+		return false
+	}
+	if !(TokenACL{Allow: claims.Channels, Deny: claims.DenyChannels}).Allows(urlPath) {
+		logger.LogPrintf("JWT token无权访问该频道: sub: %s, url: %s", claims.Subject, urlPath)
+		return false
+	}
+	if !claims.allowIP(clientIP) {
+		logger.LogPrintf("JWT token绑定IP不匹配: sub: %s, 绑定: %s, 实际: %s", claims.Subject, claims.IP, clientIP)
+		return false
+	}
+	if !tm.JWT.acquireConn(claims, token, connID) {
+		logger.LogPrintf("JWT token超过最大并发连接数: sub: %s, max_conns: %d", claims.Subject, claims.MaxConns)
+		return false
+	}
+	return true
+}
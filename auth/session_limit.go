@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// 超出会话数限制时的处理方式
+const (
+	SessionLimitReject     = "reject"
+	SessionLimitKickOldest = "kick_oldest"
+)
+
+// 被踢掉的连接在该时间内再次请求会被拒绝，避免两个播放器互相踢
+const kickedBlockDuration = time.Minute
+
+// sessionLimit 同时会话数限制，会话即 monitor 中使用该 token 的活跃连接
+type sessionLimit struct {
+	maxPerToken int
+	maxPerIP    int
+	kickOldest  bool
+
+	mu     sync.Mutex
+	kicked map[string]time.Time // connID -> 解除时间
+}
+
+func newSessionLimit(maxPerToken, maxPerIP int, action string) *sessionLimit {
+	if maxPerToken <= 0 && maxPerIP <= 0 {
+		return nil
+	}
+	if action != "" && action != SessionLimitReject && action != SessionLimitKickOldest {
+		logger.LogPrintf("⚠️ 未知的 session_limit_action: %s，使用 reject", action)
+	}
+	return &sessionLimit{
+		maxPerToken: maxPerToken,
+		maxPerIP:    maxPerIP,
+		kickOldest:  action == SessionLimitKickOldest,
+		kicked:      make(map[string]time.Time),
+	}
+}
+
+// allow 检查新会话是否允许建立；kick_oldest 时踢掉最早的会话为新会话腾出位置。
+// clientIP 为 monitor.TrustedClientIP，客户端不能通过每次伪造不同的 X-Forwarded-For 绕过按 IP 的限制
+func (l *sessionLimit) allow(token, connID, clientIP string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	if until, ok := l.kicked[connID]; ok {
+		if now.Before(until) {
+			l.mu.Unlock()
+			logger.LogPrintf("🚫 会话已被踢出: token: %s, connID: %s", logger.MaskToken(token), connID)
+			return false
+		}
+		delete(l.kicked, connID)
+	}
+	l.mu.Unlock()
+
+	// 其它实例上的会话占用名额，kick_oldest 只能踢掉本实例的会话
+	if l.maxPerToken > 0 {
+		others := otherSessions(monitor.ActiveClients.GetConnectionsByToken(token), connID)
+		if !l.makeRoom(others, l.maxPerToken-remoteSessions(sessionTokenKey+token), now) {
+			logger.LogPrintf("🚫 token会话数已达上限 %d: %s, ip: %s", l.maxPerToken, logger.MaskToken(token), clientIP)
+			return false
+		}
+	}
+	if l.maxPerIP > 0 && clientIP != "" {
+		others := otherSessions(monitor.ActiveClients.GetConnectionsByTrustedIP(clientIP), connID)
+		if !l.makeRoom(others, l.maxPerIP-remoteSessions(sessionIPKey+clientIP), now) {
+			logger.LogPrintf("🚫 IP会话数已达上限 %d: %s, token: %s", l.maxPerIP, clientIP, logger.MaskToken(token))
+			return false
+		}
+	}
+	return true
+}
+
+// makeRoom 已有会话数未达上限时返回 true；否则 kick_oldest 时踢掉最早的会话
+func (l *sessionLimit) makeRoom(others []*monitor.ClientConnection, max int, now time.Time) bool {
+	if len(others) < max {
+		return true
+	}
+	if !l.kickOldest || max <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range others[:len(others)-max+1] {
+		monitor.ActiveClients.Kick(c.ID)
+		l.kicked[c.ID] = now.Add(kickedBlockDuration)
+		logger.LogPrintf("⚠️ 会话数超限，踢出最早的会话: ip: %s, url: %s", c.IP, c.URL)
+	}
+	for id, until := range l.kicked {
+		if now.After(until) {
+			delete(l.kicked, id)
+		}
+	}
+	return true
+}
+
+// otherSessions 排除当前连接及未携带 token 的连接，按连接时间从早到晚排序
+func otherSessions(conns []*monitor.ClientConnection, connID string) []*monitor.ClientConnection {
+	list := make([]*monitor.ClientConnection, 0, len(conns))
+	for _, c := range conns {
+		if c.ID == connID || c.Token == "" {
+			continue
+		}
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ConnectedAt.Before(list[j].ConnectedAt) })
+	return list
+}
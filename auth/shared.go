@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/kvstore"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// 共享存储中的键
+const (
+	issuedKey       = "tokens"          // 签发的 token：token -> IssuedToken JSON
+	sessionTokenKey = "sessions:token:" // 每个 token 在各实例的会话数：实例 -> 会话数|更新时间
+	sessionIPKey    = "sessions:ip:"    // 每个 IP 在各实例的会话数
+)
+
+// issuedMissTTL 共享存储中查不到的 token 在这段时间内不再查询，避免错误 token 的每个请求都访问一次共享存储
+const issuedMissTTL = 5 * time.Second
+
+var (
+	issuedMissMu sync.Mutex
+	issuedMisses = make(map[string]time.Time) // token -> 不再查询的截止时间
+	issuedSweep  time.Time                    // 上次清理过期记录的时间
+)
+
+func init() {
+	kvstore.OnSync(syncShared)
+}
+
+// syncShared 从共享存储刷新签发的 token，并发布本实例的会话数
+func syncShared(s kvstore.Store) {
+	tm := GetGlobalTokenManager()
+	if tm == nil || !tm.Enabled {
+		return
+	}
+	tm.syncIssued(s)
+	if tm.sessions != nil {
+		publishSessions(s)
+	}
+}
+
+// syncIssued 以共享存储中的签发 token 替换本地列表，并清理已过期的记录
+func (tm *TokenManager) syncIssued(s kvstore.Store) {
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	all, err := s.HGetAll(ctx, issuedKey)
+	if err != nil {
+		logger.LogPrintf("⚠️ 读取共享存储中的签发 token 失败: %v", err)
+		return
+	}
+	now := time.Now()
+	issued := make(map[string]*IssuedToken, len(all))
+	var expired []string
+	for token, raw := range all {
+		var it IssuedToken
+		if json.Unmarshal([]byte(raw), &it) != nil || it.expired(now) {
+			expired = append(expired, token)
+			continue
+		}
+		issued[token] = &it
+	}
+	_ = s.HDel(ctx, issuedKey, expired...)
+
+	tm.mu.Lock()
+	tm.issued = issued
+	tm.mu.Unlock()
+}
+
+// storeIssued 把签发的 token 写入共享存储，未配置共享存储时忽略
+func storeIssued(it IssuedToken) {
+	s := kvstore.Shared()
+	if s == nil {
+		return
+	}
+	b, err := json.Marshal(it)
+	if err != nil {
+		return
+	}
+	forgetIssuedMiss(it.Token)
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	if err := s.HSet(ctx, issuedKey, it.Token, string(b)); err != nil {
+		logger.LogPrintf("⚠️ 写入共享存储失败: %v", err)
+	}
+}
+
+// deleteIssued 从共享存储中删除签发的 token，返回是否存在
+func deleteIssued(token string) bool {
+	s := kvstore.Shared()
+	if s == nil {
+		return false
+	}
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	_, ok, err := s.HGet(ctx, issuedKey, token)
+	if err != nil || !ok {
+		return false
+	}
+	if err := s.HDel(ctx, issuedKey, token); err != nil {
+		logger.LogPrintf("⚠️ 写入共享存储失败: %v", err)
+	}
+	return true
+}
+
+// loadIssued 本地没有的 token 从共享存储读取，其它实例刚签发、尚未同步的 token 也能立即使用。
+// JWT 与动态 token 不是签发的 token，直接跳过；查不到的 token 在 issuedMissTTL 内不再查询
+func (tm *TokenManager) loadIssued(token string) {
+	s := kvstore.Shared()
+	if s == nil || token == "" || isJWT(token) {
+		return
+	}
+	tm.mu.RLock()
+	_, issued := tm.issued[token]
+	_, static := tm.StaticTokens[token]
+	tm.mu.RUnlock()
+	if issued || static || tm.isDynamicToken(token) {
+		return
+	}
+	now := time.Now()
+	issuedMissMu.Lock()
+	until, missed := issuedMisses[token]
+	issuedMissMu.Unlock()
+	if missed && now.Before(until) {
+		return
+	}
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	raw, ok, err := s.HGet(ctx, issuedKey, token)
+	if err != nil {
+		return
+	}
+	var it IssuedToken
+	if !ok || json.Unmarshal([]byte(raw), &it) != nil || it.expired(now) {
+		rememberIssuedMiss(token, now)
+		return
+	}
+	tm.mu.Lock()
+	tm.issued[token] = &it
+	tm.mu.Unlock()
+}
+
+// rememberIssuedMiss 记录共享存储中查不到的 token，每隔 issuedMissTTL 顺带清理过期记录
+func rememberIssuedMiss(token string, now time.Time) {
+	issuedMissMu.Lock()
+	defer issuedMissMu.Unlock()
+	if now.Sub(issuedSweep) > issuedMissTTL {
+		issuedSweep = now
+		for t, until := range issuedMisses {
+			if now.After(until) {
+				delete(issuedMisses, t)
+			}
+		}
+	}
+	issuedMisses[token] = now.Add(issuedMissTTL)
+}
+
+// forgetIssuedMiss 签发 token 后清除其未命中记录
+func forgetIssuedMiss(token string) {
+	issuedMissMu.Lock()
+	delete(issuedMisses, token)
+	issuedMissMu.Unlock()
+}
+
+// isDynamicToken 判断 token 是否为本实例可解密的动态 token
+func (tm *TokenManager) isDynamicToken(token string) bool {
+	tm.mu.RLock()
+	typ := tm.tokenTypes[token]
+	cfg := tm.DynamicConfig
+	tm.mu.RUnlock()
+	if typ == "dynamic" {
+		return true
+	}
+	if cfg == nil {
+		return false
+	}
+	plain, err := aesDecryptBase64(strings.ReplaceAll(token, " ", "+"), cfg.Secret)
+	return err == nil && strings.HasPrefix(plain, cfg.Salt+"|")
+}
+
+var (
+	publishedMu sync.Mutex
+	published   = make(map[string]bool) // 上次发布过会话数的键
+)
+
+// publishSessions 发布本实例每个 token 与 IP 的会话数，会话已结束的键删除本实例的记录
+func publishSessions(s kvstore.Store) {
+	counts := make(map[string]int)
+	for _, c := range monitor.ActiveClients.GetAll() {
+		if c.Token == "" {
+			continue
+		}
+		counts[sessionTokenKey+c.Token]++
+		if c.TrustedIP != "" {
+			counts[sessionIPKey+c.TrustedIP]++
+		}
+	}
+
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	ttl := 3 * kvstore.Interval()
+	value := "|" + strconv.FormatInt(time.Now().Unix(), 10)
+	for key, n := range counts {
+		if err := s.HSet(ctx, key, kvstore.InstanceID(), strconv.Itoa(n)+value); err != nil {
+			logger.LogPrintf("⚠️ 发布会话数失败: %v", err)
+			return
+		}
+		_ = s.Expire(ctx, key, ttl)
+	}
+
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+	for key := range published {
+		if _, ok := counts[key]; !ok {
+			_ = s.HDel(ctx, key, kvstore.InstanceID())
+		}
+	}
+	published = make(map[string]bool, len(counts))
+	for key := range counts {
+		published[key] = true
+	}
+}
+
+type remoteCount struct {
+	n       int
+	expires time.Time
+}
+
+var (
+	remoteMu     sync.Mutex
+	remoteCounts = make(map[string]remoteCount)
+)
+
+// remoteSessions 其它实例上的会话数，其它实例按同步间隔发布，结果在一个同步间隔内复用
+func remoteSessions(key string) int {
+	s := kvstore.Shared()
+	if s == nil {
+		return 0
+	}
+	now := time.Now()
+	remoteMu.Lock()
+	rc, ok := remoteCounts[key]
+	remoteMu.Unlock()
+	if ok && now.Before(rc.expires) {
+		return rc.n
+	}
+
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	all, err := s.HGetAll(ctx, key)
+	if err != nil {
+		logger.LogPrintf("⚠️ 读取共享会话数失败: %v", err)
+		return 0
+	}
+	stale := now.Add(-3 * kvstore.Interval()).Unix()
+	n := 0
+	for instance, v := range all {
+		count, ts, _ := strings.Cut(v, "|")
+		c, _ := strconv.Atoi(count)
+		t, _ := strconv.ParseInt(ts, 10, 64)
+		if instance != kvstore.InstanceID() && t >= stale {
+			n += c
+		}
+	}
+
+	remoteMu.Lock()
+	for k, rc := range remoteCounts {
+		if now.After(rc.expires) {
+			delete(remoteCounts, k)
+		}
+	}
+	remoteCounts[key] = remoteCount{n: n, expires: now.Add(kvstore.Interval())}
+	remoteMu.Unlock()
+	return n
+}
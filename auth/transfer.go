@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 批量导入导出的格式
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// csvColumns 导出的 CSV 列；导入时按表头匹配，列的顺序不限，只有 token 列是必需的
+var csvColumns = []string{"token", "note", "created_at", "expires_at", "allow", "deny", "daily_mb", "monthly_mb"}
+
+// aclSep CSV 中 allow、deny 多个条目的分隔符
+const aclSep = ";"
+
+// ImportResult 批量导入结果
+type ImportResult struct {
+	Total    int      `json:"total"`
+	Imported int      `json:"imported"`
+	Skipped  []string `json:"skipped,omitempty"` // 跳过的 token 及原因
+}
+
+// FormatOf 按名称或文件扩展名确定格式，无法识别时返回空
+func FormatOf(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == FormatCSV || strings.HasSuffix(name, ".csv"):
+		return FormatCSV
+	case name == FormatJSON || strings.HasSuffix(name, ".json"):
+		return FormatJSON
+	}
+	return ""
+}
+
+// WriteTokens 按格式写出签发的 token，JSON 与 GET /tokens 及备份包中的 tokens.json 相同
+func WriteTokens(w io.Writer, format string, list []IssuedToken) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(csvColumns)
+		for _, it := range list {
+			var expires string
+			if !it.ExpiresAt.IsZero() {
+				expires = it.ExpiresAt.Format(time.RFC3339)
+			}
+			cw.Write([]string{
+				it.Token,
+				it.Note,
+				it.CreatedAt.Format(time.RFC3339),
+				expires,
+				strings.Join(it.Allow, aclSep),
+				strings.Join(it.Deny, aclSep),
+				formatMB(it.DailyMB),
+				formatMB(it.MonthlyMB),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("不支持的格式: %s", format)
+}
+
+func formatMB(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// ReadTokens 解析 WriteTokens 写出的内容，有任何一行无效时返回错误，不导入部分数据
+func ReadTokens(r io.Reader, format string) ([]IssuedToken, error) {
+	switch format {
+	case FormatJSON:
+		var list []IssuedToken
+		if err := json.NewDecoder(r).Decode(&list); err != nil {
+			return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+		}
+		return list, nil
+	case FormatCSV:
+		return readCSV(r)
+	}
+	return nil, fmt.Errorf("不支持的格式: %s", format)
+}
+
+// readCSV 第一行为表头（可带 UTF-8 BOM，如 Excel 导出的文件），不认识的列忽略
+func readCSV(r io.Reader) ([]IssuedToken, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV 为空")
+		}
+		return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		cols[name] = i
+	}
+	if _, ok := cols["token"]; !ok {
+		return nil, errors.New("CSV 表头缺少 token 列")
+	}
+
+	var list []IssuedToken
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return list, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		it := IssuedToken{
+			Token: get("token"),
+			Note:  get("note"),
+			TokenACL: TokenACL{
+				Allow: splitACL(get("allow")),
+				Deny:  splitACL(get("deny")),
+			},
+		}
+		if it.CreatedAt, err = parseTime(get("created_at")); err != nil {
+			return nil, fmt.Errorf("第 %d 行 created_at 格式错误: %w", line, err)
+		}
+		if it.ExpiresAt, err = parseTime(get("expires_at")); err != nil {
+			return nil, fmt.Errorf("第 %d 行 expires_at 格式错误: %w", line, err)
+		}
+		if it.DailyMB, err = parseMB(get("daily_mb")); err != nil {
+			return nil, fmt.Errorf("第 %d 行 daily_mb 格式错误: %w", line, err)
+		}
+		if it.MonthlyMB, err = parseMB(get("monthly_mb")); err != nil {
+			return nil, fmt.Errorf("第 %d 行 monthly_mb 格式错误: %w", line, err)
+		}
+		list = append(list, it)
+	}
+}
+
+func splitACL(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, aclSep) {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseTime 支持 RFC3339、本地时间 2006-01-02 15:04:05 / 2006-01-02 15:04 / 2006-01-02 与 Unix 秒，空值为零值
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间 %q", s)
+}
+
+func parseMB(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("应为非负整数: %q", s)
+	}
+	return n, nil
+}
+
+// ImportTokens 批量导入 token：token 为空时随机生成，签发时间为空时为当前时间；
+// 已过期、与静态 token 冲突或重复的跳过，已存在的 token 在 replace 为 true 时覆盖，否则跳过
+func (tm *TokenManager) ImportTokens(list []IssuedToken, replace bool) (ImportResult, error) {
+	res := ImportResult{Total: len(list)}
+	now := time.Now()
+	seen := make(map[string]bool, len(list))
+	var imported []IssuedToken
+
+	tm.mu.Lock()
+	for i := range list {
+		it := list[i]
+		if it.Token == "" {
+			token, err := randomToken()
+			if err != nil {
+				tm.mu.Unlock()
+				return res, err
+			}
+			it.Token = token
+		}
+		skip := ""
+		switch {
+		case seen[it.Token]:
+			skip = "重复"
+		case it.expired(now):
+			skip = "已过期"
+		case tm.StaticTokens[it.Token] != nil:
+			skip = "与静态 token 冲突"
+		case tm.issued[it.Token] != nil && !replace:
+			skip = "已存在"
+		}
+		seen[it.Token] = true
+		if skip != "" {
+			res.Skipped = append(res.Skipped, it.Token+": "+skip)
+			continue
+		}
+		if it.CreatedAt.IsZero() {
+			it.CreatedAt = now
+		}
+		tm.issued[it.Token] = &it
+		imported = append(imported, it)
+	}
+	tm.mu.Unlock()
+
+	for _, it := range imported {
+		storeIssued(it)
+	}
+	res.Imported = len(imported)
+	return res, nil
+}
@@ -0,0 +1,315 @@
+package bruteforce
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/ipacl"
+)
+
+// 失败原因
+const (
+	ReasonWebLogin = "web_login"
+	ReasonAPIToken = "api_token"
+	ReasonToken    = "token"
+	ReasonManual   = "manual" // 管理员手动封禁
+)
+
+// 清理空闲记录的间隔
+const pruneInterval = time.Minute
+
+// Ban 封禁信息
+type Ban struct {
+	IP       string    `json:"ip"`
+	Reason   string    `json:"reason"`    // 最后一次失败的原因
+	BanCount int       `json:"ban_count"` // 累计封禁次数
+	BannedAt time.Time `json:"banned_at"`
+	Until    time.Time `json:"until"`
+	Manual   bool      `json:"manual"` // 管理员手动封禁，不受 brute_force.enabled 影响
+}
+
+type record struct {
+	failures    []time.Time // 时间窗口内的失败时间
+	reason      string
+	banCount    int
+	bannedAt    time.Time
+	bannedUntil time.Time
+	manual      bool                 // 当前封禁为手动封禁
+	counted     map[string]time.Time // FailOnce 在时间窗口内已计数的 key
+}
+
+// Guard 按 IP 统计失败次数并临时封禁
+type Guard struct {
+	mu        sync.Mutex
+	cfg       config.BruteForceConfig
+	whitelist ipacl.List
+	records   map[string]*record
+	lastPrune time.Time
+	remote    map[string]Ban // 共享存储中其它实例的封禁，定期同步
+}
+
+// New 创建未启用的 Guard，需调用 Configure 启用
+func New() *Guard {
+	return &Guard{records: make(map[string]*record)}
+}
+
+// Default 全局实例，Web 登录与 token 校验共用
+var Default = New()
+
+// Configure 更新配置，已有的失败记录与封禁保留；禁用时仅保留手动封禁
+func (g *Guard) Configure(cfg config.BruteForceConfig) {
+	whitelist, err := ipacl.Parse(cfg.Whitelist)
+	if err != nil {
+		logger.LogPrintf("❌ brute_force.whitelist 配置错误: %v", err)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = cfg
+	g.whitelist = whitelist
+	if !cfg.Enabled {
+		now := time.Now()
+		for ip, rec := range g.records {
+			if !rec.manual || !now.Before(rec.bannedUntil) {
+				delete(g.records, ip)
+			}
+		}
+	}
+}
+
+func (g *Guard) exempt(ip string) bool {
+	return !g.cfg.Enabled || ip == "" || g.whitelist.ContainsString(ip)
+}
+
+// Banned 返回 IP 是否处于封禁中及剩余时长
+func (g *Guard) Banned(ip string) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.whitelist.ContainsString(ip) {
+		return 0, false
+	}
+	until := time.Time{}
+	if rec, ok := g.records[ip]; ok && (g.cfg.Enabled || rec.manual) {
+		until = rec.bannedUntil
+	}
+	if ban, ok := g.remote[ip]; ok && (g.cfg.Enabled || ban.Manual) && ban.Until.After(until) {
+		until = ban.Until
+	}
+	remaining := time.Until(until)
+	return remaining, remaining > 0
+}
+
+// Blocked 返回 IP 是否处于手动封禁中，手动封禁的 IP 不能访问任何地址
+func (g *Guard) Blocked(ip string) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.whitelist.ContainsString(ip) {
+		return 0, false
+	}
+	until := time.Time{}
+	if rec, ok := g.records[ip]; ok && rec.manual {
+		until = rec.bannedUntil
+	}
+	if ban, ok := g.remote[ip]; ok && ban.Manual && ban.Until.After(until) {
+		until = ban.Until
+	}
+	remaining := time.Until(until)
+	return remaining, remaining > 0
+}
+
+// Ban 手动封禁 IP，不受 brute_force.enabled 影响，白名单中的 IP 不能封禁
+func (g *Guard) Ban(ip string, duration time.Duration) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("无效的 IP: %q", ip)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("封禁时长必须大于 0")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.whitelist.ContainsString(ip) {
+		return fmt.Errorf("IP %s 在 brute_force.whitelist 中", ip)
+	}
+	now := time.Now()
+	rec, ok := g.records[ip]
+	if !ok {
+		rec = &record{}
+		g.records[ip] = rec
+	}
+	rec.reason = ReasonManual
+	rec.manual = true
+	rec.banCount++
+	rec.bannedAt = now
+	rec.bannedUntil = now.Add(duration)
+	rec.failures = nil
+	logger.LogPrintf("🚫 IP %s 已被手动封禁 %s", ip, duration)
+	go publish(rec.ban(ip))
+	return nil
+}
+
+// Fail 记录一次失败，时间窗口内失败次数达到阈值时封禁该 IP
+func (g *Guard) Fail(ip, reason string) { g.fail(ip, reason, "") }
+
+// FailOnce 同 Fail，但同一 key（如同一个错误的 token）在时间窗口内只计一次
+func (g *Guard) FailOnce(ip, reason, key string) { g.fail(ip, reason, key) }
+
+func (g *Guard) fail(ip, reason, key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exempt(ip) {
+		return
+	}
+	now := time.Now()
+	g.prune(now)
+
+	rec, ok := g.records[ip]
+	if !ok {
+		rec = &record{}
+		g.records[ip] = rec
+	}
+	if now.Before(rec.bannedUntil) {
+		return
+	}
+	if key != "" {
+		if at, ok := rec.counted[key]; ok && now.Sub(at) <= g.cfg.Window {
+			return
+		}
+		if rec.counted == nil {
+			rec.counted = make(map[string]time.Time)
+		}
+		rec.counted[key] = now
+	}
+	rec.reason = reason
+	rec.failures = append(recentFailures(rec.failures, now, g.cfg.Window), now)
+	if len(rec.failures) < g.cfg.MaxFailures {
+		return
+	}
+
+	// 上次解封后长时间未再被封禁，重新从 ban_duration 计
+	if rec.banCount > 0 && now.Sub(rec.bannedUntil) > g.cfg.MaxBanDuration {
+		rec.banCount = 0
+	}
+	duration := g.cfg.BanDuration << rec.banCount
+	if duration <= 0 || duration > g.cfg.MaxBanDuration {
+		duration = g.cfg.MaxBanDuration
+	}
+	rec.banCount++
+	rec.manual = false
+	rec.bannedAt = now
+	rec.bannedUntil = now.Add(duration)
+	logger.LogPrintf("🚫 IP %s 连续失败 %d 次（%s），封禁 %s", ip, len(rec.failures), reason, duration)
+	rec.failures = nil
+	go publish(rec.ban(ip))
+}
+
+// Success 登录成功后清除失败计数，不影响封禁历史
+func (g *Guard) Success(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if rec, ok := g.records[ip]; ok {
+		rec.failures = nil
+		rec.counted = nil
+	}
+}
+
+// Unban 解除封禁并清除该 IP 的记录
+func (g *Guard) Unban(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	rec, local := g.records[ip]
+	local = local && now.Before(rec.bannedUntil)
+	ban, shared := g.remote[ip]
+	shared = shared && now.Before(ban.Until)
+	if !local && !shared {
+		return false
+	}
+	delete(g.records, ip)
+	delete(g.remote, ip)
+	go unpublish(ip)
+	logger.LogPrintf("✅ 已解除封禁: %s", ip)
+	return true
+}
+
+// Bans 返回当前封禁列表，按解封时间排序
+func (g *Guard) Bans() []Ban {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	list := make([]Ban, 0)
+	for ip, rec := range g.records {
+		if now.Before(rec.bannedUntil) {
+			list = append(list, rec.ban(ip))
+		}
+	}
+	for ip, ban := range g.remote {
+		if rec, ok := g.records[ip]; (!ok || !now.Before(rec.bannedUntil)) && now.Before(ban.Until) {
+			list = append(list, ban)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Until.Before(list[j].Until) })
+	return list
+}
+
+func (rec *record) ban(ip string) Ban {
+	return Ban{
+		IP:       ip,
+		Reason:   rec.reason,
+		BanCount: rec.banCount,
+		BannedAt: rec.bannedAt,
+		Until:    rec.bannedUntil,
+		Manual:   rec.manual,
+	}
+}
+
+// prune 清理没有近期失败、未被封禁且封禁历史已失效的记录
+func (g *Guard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < pruneInterval {
+		return
+	}
+	g.lastPrune = now
+	for ip, rec := range g.records {
+		rec.failures = recentFailures(rec.failures, now, g.cfg.Window)
+		for key, at := range rec.counted {
+			if now.Sub(at) > g.cfg.Window {
+				delete(rec.counted, key)
+			}
+		}
+		if len(rec.failures) == 0 && now.Sub(rec.bannedUntil) > g.cfg.MaxBanDuration {
+			delete(g.records, ip)
+		}
+	}
+}
+
+func recentFailures(failures []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for i < len(failures) && now.Sub(failures[i]) > window {
+		i++
+	}
+	return failures[i:]
+}
+
+// Configure 更新全局实例配置
+func Configure(cfg config.BruteForceConfig) { Default.Configure(cfg) }
+
+// Banned 查询全局实例
+func Banned(ip string) (time.Duration, bool) { return Default.Banned(ip) }
+
+// Blocked 查询全局实例中的手动封禁
+func Blocked(ip string) (time.Duration, bool) { return Default.Blocked(ip) }
+
+// BanIP 在全局实例中手动封禁 IP
+func BanIP(ip string, duration time.Duration) error { return Default.Ban(ip, duration) }
+
+// Fail 记录失败到全局实例
+func Fail(ip, reason string) { Default.Fail(ip, reason) }
+
+// FailOnce 记录失败到全局实例，同一 key 在时间窗口内只计一次
+func FailOnce(ip, reason, key string) { Default.FailOnce(ip, reason, key) }
+
+// Success 清除全局实例中的失败计数
+func Success(ip string) { Default.Success(ip) }
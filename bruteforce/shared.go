@@ -0,0 +1,73 @@
+package bruteforce
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/qist/tvgate/kvstore"
+	"github.com/qist/tvgate/logger"
+)
+
+// bansKey 共享存储中的封禁记录：IP -> Ban JSON
+const bansKey = "bans"
+
+func init() {
+	kvstore.OnSync(Default.sync)
+}
+
+// publish 把封禁写入共享存储，其它实例在下次同步时生效；失败次数只在各实例内统计
+func publish(ban Ban) {
+	s := kvstore.Shared()
+	if s == nil {
+		return
+	}
+	b, err := json.Marshal(ban)
+	if err != nil {
+		return
+	}
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	if err := s.HSet(ctx, bansKey, ban.IP, string(b)); err != nil {
+		logger.LogPrintf("⚠️ 写入共享存储失败: %v", err)
+	}
+}
+
+// unpublish 从共享存储中解除封禁
+func unpublish(ip string) {
+	s := kvstore.Shared()
+	if s == nil {
+		return
+	}
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	if err := s.HDel(ctx, bansKey, ip); err != nil {
+		logger.LogPrintf("⚠️ 写入共享存储失败: %v", err)
+	}
+}
+
+// sync 从共享存储读取所有实例的封禁，并清理已解封的记录
+func (g *Guard) sync(s kvstore.Store) {
+	ctx, cancel := kvstore.Context()
+	defer cancel()
+	all, err := s.HGetAll(ctx, bansKey)
+	if err != nil {
+		logger.LogPrintf("⚠️ 读取共享存储中的封禁失败: %v", err)
+		return
+	}
+	now := time.Now()
+	remote := make(map[string]Ban, len(all))
+	var expired []string
+	for ip, raw := range all {
+		var ban Ban
+		if json.Unmarshal([]byte(raw), &ban) != nil || !now.Before(ban.Until) {
+			expired = append(expired, ip)
+			continue
+		}
+		remote[ip] = ban
+	}
+	_ = s.HDel(ctx, bansKey, expired...)
+
+	g.mu.Lock()
+	g.remote = remote
+	g.mu.Unlock()
+}
@@ -0,0 +1,343 @@
+// Package cast 投屏：通过 mDNS 发现局域网内的 Chromecast 与 AirPlay 设备，让设备播放经 TVGate 代理的频道地址，
+// 启用全局 token 认证时为每次投屏签发只能访问该频道的临时 token，投屏结束时吊销
+package cast
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+var (
+	// ErrDisabled 未启用投屏
+	ErrDisabled = errors.New("未启用投屏")
+	// ErrNoDevice 设备不在最近一次搜索结果中
+	ErrNoDevice = errors.New("设备不存在，请重新搜索设备")
+	// ErrNoSession 投屏会话不存在或已结束
+	ErrNoSession = errors.New("投屏会话不存在")
+)
+
+// receiver 设备上的一次播放
+type receiver interface {
+	Load(ctx context.Context, url, contentType, title string) error
+	SetVolume(ctx context.Context, level float64) error
+	Stop(ctx context.Context) error
+	Close()
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Session 投屏会话
+type Session struct {
+	ID      string    `json:"id"`
+	Device  Device    `json:"device"`
+	Src     string    `json:"src"`   // 投屏的频道
+	Title   string    `json:"title"` // 设备上显示的标题
+	URL     string    `json:"url"`   // 设备播放的地址，不含 token
+	Started time.Time `json:"started"`
+	Volume  *float64  `json:"volume,omitempty"` // 最近一次设置的音量，未设置时为空
+
+	token string
+	recv  receiver
+}
+
+// settings 生效的配置，设备访问地址需要代理端口与 token 参数名
+type settings struct {
+	config.CastConfig
+	port       int
+	tokenParam string
+}
+
+// Manager 设备发现与投屏会话
+type Manager struct {
+	mu       sync.Mutex
+	s        settings
+	devices  map[string]Device
+	sessions map[string]*Session
+}
+
+// New 创建未启用的投屏管理器
+func New() *Manager {
+	return &Manager{devices: make(map[string]Device), sessions: make(map[string]*Session)}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg *config.Config) { Default.Configure(cfg) }
+
+// Configure 应用配置，禁用时停止所有投屏
+func (m *Manager) Configure(cfg *config.Config) {
+	s := settings{CastConfig: cfg.Cast, port: cfg.Server.Port, tokenParam: cfg.GlobalAuth.TokenParamName}
+	if cfg.Server.HTTPPort > 0 {
+		s.port = cfg.Server.HTTPPort
+	}
+	if s.tokenParam == "" {
+		s.tokenParam = "my_token"
+	}
+	m.mu.Lock()
+	m.s = s
+	var stop []*Session
+	if !s.Enabled {
+		for _, sess := range m.sessions {
+			stop = append(stop, sess)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sess := range stop {
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		_ = sess.recv.Stop(ctx)
+		cancel()
+	}
+}
+
+func (m *Manager) current() (settings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.s.Enabled {
+		return m.s, ErrDisabled
+	}
+	return m.s, nil
+}
+
+// Devices 搜索局域网内的设备
+func (m *Manager) Devices(ctx context.Context) ([]Device, error) {
+	s, err := m.current()
+	if err != nil {
+		return nil, err
+	}
+	list, err := discover(ctx, s.Discovery)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.devices = make(map[string]Device, len(list))
+	for _, d := range list {
+		m.devices[d.ID] = d
+	}
+	m.mu.Unlock()
+	return list, nil
+}
+
+// Start 在设备上播放频道，src 为播放器中的频道：组播地址、rtp:// 等上游地址或 TVGate 上的路径
+func (m *Manager) Start(ctx context.Context, deviceID, src, title string) (Session, error) {
+	s, err := m.current()
+	if err != nil {
+		return Session{}, err
+	}
+	m.mu.Lock()
+	dev, ok := m.devices[deviceID]
+	m.mu.Unlock()
+	if !ok {
+		return Session{}, ErrNoDevice
+	}
+	p, err := mediaPath(src)
+	if err != nil {
+		return Session{}, err
+	}
+	if title == "" {
+		title = src
+	}
+	base := strings.TrimSuffix(s.BaseURL, "/")
+	if base == "" {
+		ip, err := localIP(dev.Addr)
+		if err != nil {
+			return Session{}, err
+		}
+		base = "http://" + net.JoinHostPort(ip, strconv.Itoa(s.port))
+	}
+
+	sess := &Session{ID: newID(), Device: dev, Src: src, Title: title, URL: base + p, Started: time.Now()}
+	playURL := sess.URL
+	if tm := auth.GetGlobalTokenManager(); tm != nil && tm.Enabled {
+		// 只允许访问该频道；HLS 还需要访问同目录下的分片
+		allow := p
+		if u, err := url.Parse(p); err == nil && strings.EqualFold(path.Ext(u.Path), ".m3u8") {
+			allow = path.Dir(u.Path) + "/"
+		} else if err == nil {
+			allow = u.Path
+		}
+		it, err := tm.IssueToken("", "投屏: "+dev.Name, s.TokenTTL, auth.TokenACL{Allow: []string{allow}})
+		if err != nil {
+			return Session{}, err
+		}
+		sess.token = it.Token
+		sep := "?"
+		if strings.Contains(playURL, "?") {
+			sep = "&"
+		}
+		playURL += sep + s.tokenParam + "=" + url.QueryEscape(it.Token)
+	}
+
+	switch dev.Type {
+	case Chromecast:
+		c, err := dialChromecast(ctx, dev.Addr, s.Timeout)
+		if err != nil {
+			revoke(sess.token)
+			return Session{}, fmt.Errorf("连接设备失败: %w", err)
+		}
+		sess.recv = c
+	default:
+		sess.recv = newAirPlay(dev.Addr, s.Timeout)
+	}
+	if err := sess.recv.Load(ctx, playURL, contentType(p), title); err != nil {
+		sess.recv.Close()
+		revoke(sess.token)
+		return Session{}, err
+	}
+
+	m.mu.Lock()
+	m.sessions[sess.ID] = sess
+	m.mu.Unlock()
+	go m.watch(sess)
+	logger.LogPrintf("📺 投屏到 %s (%s): %s", dev.Name, dev.Type, sess.URL)
+	return *sess, nil
+}
+
+// watch 设备结束播放或会话停止后移除会话并吊销 token
+func (m *Manager) watch(sess *Session) {
+	<-sess.recv.Done()
+	m.mu.Lock()
+	delete(m.sessions, sess.ID)
+	m.mu.Unlock()
+	revoke(sess.token)
+	if err := sess.recv.Err(); err != nil {
+		logger.LogPrintf("📺 投屏已结束 %s: %v", sess.Device.Name, err)
+	}
+}
+
+// Sessions 正在进行的投屏，按开始时间排序
+func (m *Manager) Sessions() []Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		list = append(list, *sess)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+func (m *Manager) session(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNoSession
+	}
+	return sess, nil
+}
+
+// Stop 停止投屏，通知设备失败时仍然结束会话并吊销 token
+func (m *Manager) Stop(ctx context.Context, id string) error {
+	sess, err := m.session(id)
+	if err != nil {
+		return err
+	}
+	if err := sess.recv.Stop(ctx); err != nil {
+		logger.LogPrintf("⚠️ 通知设备 %s 停止播放失败: %v", sess.Device.Name, err)
+	}
+	return nil
+}
+
+// SetVolume 设置设备音量，level 为 0 到 1
+func (m *Manager) SetVolume(ctx context.Context, id string, level float64) error {
+	if level < 0 || level > 1 {
+		return errors.New("音量应在 0 到 1 之间")
+	}
+	sess, err := m.session(id)
+	if err != nil {
+		return err
+	}
+	if err := sess.recv.SetVolume(ctx, level); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	sess.Volume = &level
+	m.mu.Unlock()
+	return nil
+}
+
+// mediaPath 把播放器中的频道转换为 TVGate 上的路径：
+// 239.0.0.1:2000 与状态页中的 239.0.0.1:2000@eth0 -> /rtp/239.0.0.1:2000（?iface=eth0），
+// rtp:// 等上游地址按播放列表的规则改写，以 / 开头的路径保持不变
+func mediaPath(src string) (string, error) {
+	src = strings.TrimSpace(src)
+	switch {
+	case src == "":
+		return "", errors.New("缺少频道")
+	case strings.HasPrefix(src, "/"):
+		return src, nil
+	case strings.Contains(src, "://"):
+		if p := m3u.ProxyPath(src); strings.HasPrefix(p, "/") {
+			return p, nil
+		}
+		return "", fmt.Errorf("不支持投屏该地址: %s", src)
+	}
+	addr, iface, _ := strings.Cut(src, "@")
+	addr, _, _ = strings.Cut(addr, ",")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("不支持投屏该地址: %s", src)
+	}
+	p := "/rtp/" + addr
+	if iface != "" {
+		p += "?iface=" + url.QueryEscape(iface)
+	}
+	return p, nil
+}
+
+// contentType 设备需要的内容类型，除 HLS、MP4 外按 MPEG-TS 处理
+func contentType(p string) string {
+	if u, err := url.Parse(p); err == nil {
+		p = u.Path
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".m3u8":
+		return "application/x-mpegURL"
+	case ".mp4":
+		return "video/mp4"
+	}
+	return "video/mp2t"
+}
+
+// localIP 访问设备时使用的本机地址
+func localIP(addr string) (string, error) {
+	c, err := net.Dial("udp4", addr)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+func revoke(token string) {
+	if token == "" {
+		return
+	}
+	if tm := auth.GetGlobalTokenManager(); tm != nil {
+		_ = tm.RevokeToken(token)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,327 @@
+package cast
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Cast V2 协议的命名空间
+const (
+	nsConnection = "urn:x-cast:com.google.cast.tp.connection"
+	nsHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	nsReceiver   = "urn:x-cast:com.google.cast.receiver"
+	nsMedia      = "urn:x-cast:com.google.cast.media"
+
+	senderID   = "sender-0"
+	receiverID = "receiver-0"
+	// defaultReceiverApp Google 的默认媒体接收器
+	defaultReceiverApp = "CC1AD845"
+	// heartbeatInterval 设备超过约 10 秒收不到 PING 会断开连接
+	heartbeatInterval = 5 * time.Second
+)
+
+// castMessage Cast V2 的 CastMessage，只使用字符串负载
+type castMessage struct {
+	source, destination, namespace, payload string
+}
+
+// encode 按 protobuf 编码，前置 4 字节大端长度
+func (m castMessage) encode() []byte {
+	var b []byte
+	b = append(b, 0x08, 0x00) // protocol_version = CASTV2_1_0
+	for _, f := range []struct {
+		tag byte
+		s   string
+	}{{0x12, m.source}, {0x1a, m.destination}, {0x22, m.namespace}} {
+		b = append(b, f.tag)
+		b = binary.AppendUvarint(b, uint64(len(f.s)))
+		b = append(b, f.s...)
+	}
+	b = append(b, 0x28, 0x00) // payload_type = STRING
+	b = append(b, 0x32)
+	b = binary.AppendUvarint(b, uint64(len(m.payload)))
+	b = append(b, m.payload...)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(b))), b...)
+}
+
+// readMessage 读取一条消息，忽略未使用的字段
+func readMessage(r io.Reader) (castMessage, error) {
+	var m castMessage
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return m, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > 64<<10 {
+		return m, fmt.Errorf("消息过大: %d 字节", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return m, err
+	}
+	for len(b) > 0 {
+		key, k := binary.Uvarint(b)
+		if k <= 0 {
+			return m, errors.New("消息格式错误")
+		}
+		b = b[k:]
+		switch key & 7 {
+		case 0: // varint
+			_, k = binary.Uvarint(b)
+			if k <= 0 {
+				return m, errors.New("消息格式错误")
+			}
+			b = b[k:]
+		case 2: // length-delimited
+			l, k := binary.Uvarint(b)
+			if k <= 0 || uint64(len(b)-k) < l {
+				return m, errors.New("消息格式错误")
+			}
+			v := string(b[k : k+int(l)])
+			b = b[k+int(l):]
+			switch key >> 3 {
+			case 2:
+				m.source = v
+			case 3:
+				m.destination = v
+			case 4:
+				m.namespace = v
+			case 6:
+				m.payload = v
+			}
+		default:
+			return m, errors.New("不支持的字段类型")
+		}
+	}
+	return m, nil
+}
+
+// chromecast Cast V2 连接：启动默认媒体接收器并加载地址
+type chromecast struct {
+	conn    net.Conn
+	timeout time.Duration
+	wmu     sync.Mutex
+
+	mu        sync.Mutex
+	reqID     int
+	pending   map[int]chan map[string]any
+	transport string // 接收器应用的 transportId
+	session   string // 接收器应用的 sessionId
+	err       error  // 连接断开或设备结束会话的原因
+	done      chan struct{}
+}
+
+func dialChromecast(ctx context.Context, addr string, timeout time.Duration) (*chromecast, error) {
+	d := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		// 设备使用自签名证书，无法校验
+		Config: &tls.Config{InsecureSkipVerify: true},
+	}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &chromecast{conn: conn, timeout: timeout, pending: make(map[int]chan map[string]any), done: make(chan struct{})}
+	go c.readLoop()
+	go c.heartbeat()
+	if err := c.send(receiverID, nsConnection, map[string]any{"type": "CONNECT"}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *chromecast) send(dst, ns string, payload map[string]any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err = c.conn.Write(castMessage{senderID, dst, ns, string(b)}.encode())
+	return err
+}
+
+// request 发送带 requestId 的消息并等待对应的响应
+func (c *chromecast) request(ctx context.Context, dst, ns string, payload map[string]any) (map[string]any, error) {
+	c.mu.Lock()
+	c.reqID++
+	id := c.reqID
+	ch := make(chan map[string]any, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	payload["requestId"] = id
+	if err := c.send(dst, ns, payload); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		return nil, c.Err()
+	case <-ctx.Done():
+		return nil, errors.New("等待设备响应超时")
+	}
+}
+
+func (c *chromecast) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			c.fail(fmt.Errorf("连接已断开: %w", err))
+			return
+		}
+		var payload map[string]any
+		if json.Unmarshal([]byte(m.payload), &payload) != nil {
+			continue
+		}
+		typ, _ := payload["type"].(string)
+		switch {
+		case m.namespace == nsHeartbeat && typ == "PING":
+			_ = c.send(m.source, nsHeartbeat, map[string]any{"type": "PONG"})
+			continue
+		case m.namespace == nsConnection && typ == "CLOSE":
+			c.mu.Lock()
+			closed := m.source == c.transport
+			c.mu.Unlock()
+			if closed {
+				c.fail(errors.New("设备已结束播放"))
+				return
+			}
+		}
+		if id, ok := payload["requestId"].(float64); ok && id > 0 {
+			c.mu.Lock()
+			ch := c.pending[int(id)]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- payload
+			}
+		}
+	}
+}
+
+func (c *chromecast) heartbeat() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.send(receiverID, nsHeartbeat, map[string]any{"type": "PING"}); err != nil {
+				c.fail(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *chromecast) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	}
+}
+
+// Done 会话结束时关闭
+func (c *chromecast) Done() <-chan struct{} { return c.done }
+
+// Err 会话结束的原因，仍在播放时为 nil
+func (c *chromecast) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Load 启动默认媒体接收器并以直播方式加载地址
+func (c *chromecast) Load(ctx context.Context, url, contentType, title string) error {
+	resp, err := c.request(ctx, receiverID, nsReceiver, map[string]any{"type": "LAUNCH", "appId": defaultReceiverApp})
+	if err != nil {
+		return err
+	}
+	transport, session := receiverApp(resp)
+	if transport == "" {
+		return fmt.Errorf("启动接收器失败: %v", resp["type"])
+	}
+	c.mu.Lock()
+	c.transport, c.session = transport, session
+	c.mu.Unlock()
+	if err := c.send(transport, nsConnection, map[string]any{"type": "CONNECT"}); err != nil {
+		return err
+	}
+	resp, err = c.request(ctx, transport, nsMedia, map[string]any{
+		"type":     "LOAD",
+		"autoplay": true,
+		"media": map[string]any{
+			"contentId":   url,
+			"contentType": contentType,
+			"streamType":  "LIVE",
+			"metadata":    map[string]any{"metadataType": 0, "title": title},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if typ, _ := resp["type"].(string); typ != "MEDIA_STATUS" {
+		return fmt.Errorf("设备无法播放该地址: %s", typ)
+	}
+	return nil
+}
+
+// receiverApp 从 RECEIVER_STATUS 中取默认媒体接收器的 transportId 与 sessionId
+func receiverApp(resp map[string]any) (string, string) {
+	status, _ := resp["status"].(map[string]any)
+	apps, _ := status["applications"].([]any)
+	for _, a := range apps {
+		app, _ := a.(map[string]any)
+		if app["appId"] == defaultReceiverApp {
+			transport, _ := app["transportId"].(string)
+			session, _ := app["sessionId"].(string)
+			return transport, session
+		}
+	}
+	return "", ""
+}
+
+// SetVolume 设置设备音量，level 为 0 到 1
+func (c *chromecast) SetVolume(ctx context.Context, level float64) error {
+	_, err := c.request(ctx, receiverID, nsReceiver, map[string]any{"type": "SET_VOLUME", "volume": map[string]any{"level": level}})
+	return err
+}
+
+// Stop 关闭接收器应用并断开连接
+func (c *chromecast) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	var err error
+	if session != "" && c.Err() == nil {
+		_, err = c.request(ctx, receiverID, nsReceiver, map[string]any{"type": "STOP", "sessionId": session})
+	}
+	c.Close()
+	return err
+}
+
+func (c *chromecast) Close() { c.fail(errors.New("已停止")) }
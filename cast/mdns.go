@@ -0,0 +1,225 @@
+package cast
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+)
+
+// 设备类型
+const (
+	Chromecast = "chromecast"
+	AirPlay    = "airplay"
+)
+
+// services mDNS 服务名到设备类型与默认端口
+var services = map[string]struct {
+	typ  string
+	port int
+}{
+	"_googlecast._tcp.local.": {Chromecast, 8009},
+	"_airplay._tcp.local.":    {AirPlay, 7000},
+}
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Device 局域网内发现的投屏设备
+type Device struct {
+	ID    string `json:"id"` // 类型:设备 ID
+	Name  string `json:"name"`
+	Type  string `json:"type"` // chromecast 或 airplay
+	Model string `json:"model,omitempty"`
+	Addr  string `json:"addr"` // 主机:端口
+}
+
+// instance 一个服务实例在多条记录中的信息
+type instance struct {
+	name   string // 实例名，如 "Living Room._googlecast._tcp.local."
+	typ    string
+	target string
+	port   int
+	txt    map[string]string
+	src    net.IP // 响应来源，缺少 A 记录时使用
+}
+
+// discover 通过 mDNS 查询 Chromecast 与 AirPlay 服务，等待 wait 后返回收到的设备。
+// 从非 5353 端口发送查询，设备按 RFC 6762 的传统单播查询直接回复到该端口，不需要占用 5353
+func discover(ctx context.Context, wait time.Duration) ([]Device, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	q := new(dns.Msg)
+	for name := range services {
+		q.Question = append(q.Question, dns.Question{Name: name, Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+	}
+	q.RecursionDesired = false
+	packet, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+	_ = pc.SetMulticastTTL(255)
+	sent := false
+	ifaces, _ := net.Interfaces()
+	for i := range ifaces {
+		ifi := &ifaces[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if pc.SetMulticastInterface(ifi) != nil {
+			continue
+		}
+		if _, err := pc.WriteTo(packet, nil, mdnsAddr); err == nil {
+			sent = true
+		}
+	}
+	if !sent {
+		if _, err := conn.WriteToUDP(packet, mdnsAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	instances := make(map[string]*instance)
+	addrs := make(map[string]net.IP)
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		var m dns.Msg
+		if m.Unpack(buf[:n]) != nil {
+			continue
+		}
+		collect(&m, src.IP, instances, addrs)
+	}
+	return devices(instances, addrs), nil
+}
+
+// collect 记录响应中的 PTR、SRV、TXT 与 A 记录
+func collect(m *dns.Msg, src net.IP, instances map[string]*instance, addrs map[string]net.IP) {
+	get := func(name string) *instance {
+		key := strings.ToLower(name)
+		in, ok := instances[key]
+		if !ok {
+			in = &instance{name: name, src: src}
+			for svc, info := range services {
+				if strings.HasSuffix(key, "."+svc) {
+					in.typ, in.port = info.typ, info.port
+				}
+			}
+			instances[key] = in
+		}
+		return in
+	}
+	for _, rr := range append(append(m.Answer, m.Ns...), m.Extra...) {
+		switch r := rr.(type) {
+		case *dns.PTR:
+			if _, ok := services[strings.ToLower(r.Hdr.Name)]; ok {
+				get(r.Ptr)
+			}
+		case *dns.SRV:
+			in := get(r.Hdr.Name)
+			in.target, in.port = strings.ToLower(r.Target), int(r.Port)
+		case *dns.TXT:
+			in := get(r.Hdr.Name)
+			in.txt = make(map[string]string)
+			for _, kv := range r.Txt {
+				k, v, _ := strings.Cut(kv, "=")
+				in.txt[strings.ToLower(k)] = unescape(v)
+			}
+		case *dns.A:
+			addrs[strings.ToLower(r.Hdr.Name)] = r.A
+		}
+	}
+}
+
+// devices 把服务实例整理为设备列表，按名称排序
+func devices(instances map[string]*instance, addrs map[string]net.IP) []Device {
+	seen := make(map[string]bool)
+	list := []Device{}
+	for _, in := range instances {
+		if in.typ == "" {
+			continue
+		}
+		label, _, _ := strings.Cut(in.name, "._")
+		label = unescape(label)
+		d := Device{Type: in.typ, Name: label}
+		id := label
+		switch in.typ {
+		case Chromecast:
+			if fn := in.txt["fn"]; fn != "" {
+				d.Name = fn
+			}
+			d.Model = in.txt["md"]
+			if v := in.txt["id"]; v != "" {
+				id = v
+			}
+		case AirPlay:
+			d.Model = in.txt["model"]
+			if v := in.txt["deviceid"]; v != "" {
+				id = v
+			}
+		}
+		ip := addrs[in.target]
+		if ip == nil {
+			ip = in.src
+		}
+		d.ID = in.typ + ":" + strings.ToLower(id)
+		d.Addr = net.JoinHostPort(ip.String(), strconv.Itoa(in.port))
+		if seen[d.ID] {
+			continue
+		}
+		seen[d.ID] = true
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// unescape 还原 DNS 名称中转义的字符，如 "Living\ Room" 与 "\228\189\160"
+func unescape(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 < len(s) {
+			if n, err := strconv.Atoi(s[i+1 : i+4]); err == nil && n < 256 {
+				b = append(b, byte(n))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i+1])
+		i++
+	}
+	return string(b)
+}
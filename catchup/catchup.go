@@ -0,0 +1,244 @@
+// Package catchup 回看地址：把 Flussonic / Xtream 风格的回看请求映射到 publisher 本地缓存的 HLS 分片
+// 或频道配置的上游回看地址，以重定向的方式交给对应的处理路径
+package catchup
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+// playbackZone publisher 回放按该时区解析 playseek，本地缓存回看必须按此时区生成
+const playbackZone = "Asia/Shanghai"
+
+var (
+	errFormat   = errors.New("无法解析回看地址")
+	errDuration = errors.New("回看时长无效")
+	errFuture   = errors.New("回看开始时间晚于当前时间")
+)
+
+// request 一次回看请求
+type request struct {
+	channel  string
+	start    time.Time
+	duration time.Duration
+}
+
+func (r request) end() time.Time { return r.start.Add(r.duration) }
+
+// Handler 回看请求处理
+type Handler struct {
+	prefix        string
+	loc           *time.Location
+	maxDuration   time.Duration
+	publisherPath string
+	channels      map[string]config.CatchupChannel
+}
+
+// NewHandler 按配置创建回看处理，配置变化时随路由一起重建
+func NewHandler(cfg *config.Config) *Handler {
+	c := cfg.Catchup
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		logger.LogPrintf("⚠️ 回看时区 %s 无效，使用本地时区: %v", c.Timezone, err)
+		loc = time.Local
+	}
+	h := &Handler{
+		prefix:      strings.TrimSuffix(c.Path, "/") + "/",
+		loc:         loc,
+		maxDuration: c.MaxDuration,
+		channels:    make(map[string]config.CatchupChannel, len(c.Channels)),
+	}
+	if cfg.Publisher != nil {
+		h.publisherPath = strings.Trim(cfg.Publisher.Path, "/")
+	}
+	for _, ch := range c.Channels {
+		h.channels[ch.Name] = ch
+	}
+	return h
+}
+
+// ServeHTTP 解析回看地址并重定向，原请求的查询参数（如 token）附加到目标地址
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := h.parse(strings.TrimPrefix(r.URL.Path, h.prefix), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ch, ok := h.channels[req.channel]
+	if !ok {
+		http.Error(w, "频道不支持回看", http.StatusNotFound)
+		return
+	}
+	target := h.target(ch, req)
+	if target == "" {
+		http.Error(w, "频道未配置回看来源", http.StatusNotFound)
+		return
+	}
+	if r.URL.RawQuery != "" {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// parse 支持以下格式（扩展名可省略）：
+//
+//	<频道>/<开始>-<时长>.m3u8                    开始为 Unix 秒或 20060102150405，时长为秒
+//	<频道>/index-<开始>-<时长>.m3u8              Flussonic，archive-、video- 等前缀同样支持
+//	<用户>/<密码>/<分钟>/<2006-01-02:15-04>/<频道>.ts  Xtream
+func (h *Handler) parse(p string, now time.Time) (request, error) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	var req request
+	switch len(parts) {
+	case 2:
+		req.channel = parts[0]
+		spec := trimExt(parts[1])
+		// Flussonic 的 index-、archive- 等前缀
+		if i := strings.IndexByte(spec, '-'); i > 0 && !isDigits(spec[:i]) {
+			spec = spec[i+1:]
+		}
+		start, dur, ok := strings.Cut(spec, "-")
+		if !ok {
+			return req, errFormat
+		}
+		t, err := h.parseTime(start)
+		if err != nil {
+			return req, errFormat
+		}
+		secs, err := strconv.Atoi(dur)
+		if err != nil {
+			return req, errFormat
+		}
+		req.start, req.duration = t, time.Duration(secs)*time.Second
+	case 5:
+		req.channel = trimExt(parts[4])
+		minutes, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return req, errFormat
+		}
+		t, err := time.ParseInLocation("2006-01-02:15-04", parts[3], h.loc)
+		if err != nil {
+			return req, errFormat
+		}
+		req.start, req.duration = t, time.Duration(minutes)*time.Minute
+	default:
+		return req, errFormat
+	}
+	if req.channel == "" {
+		return req, errFormat
+	}
+	if req.duration <= 0 || (h.maxDuration > 0 && req.duration > h.maxDuration) {
+		return req, errDuration
+	}
+	if req.start.After(now) {
+		return req, errFuture
+	}
+	return req, nil
+}
+
+// parseTime 14 位数字按 20060102150405 在配置的时区解析，其余按 Unix 秒
+func (h *Handler) parseTime(s string) (time.Time, error) {
+	if len(s) == 14 {
+		return time.ParseInLocation("20060102150405", s, h.loc)
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// target 回看的目标地址：优先使用 publisher 本地缓存，其次为上游模板
+func (h *Handler) target(ch config.CatchupChannel, req request) string {
+	if ch.Stream != "" && h.publisherPath != "" {
+		loc, err := time.LoadLocation(playbackZone)
+		if err != nil {
+			loc = h.loc
+		}
+		return "/" + h.publisherPath + "/play/" + url.PathEscape(ch.Stream) + "/index.m3u8?playseek=" +
+			req.start.In(loc).Format("20060102150405") + "-" + req.end().In(loc).Format("20060102150405")
+	}
+	if ch.Template == "" {
+		return ""
+	}
+	u := expand(ch.Template, req, h.loc, time.Now())
+	if ch.Direct {
+		return u
+	}
+	return m3u.ProxyPath(u)
+}
+
+// placeholder 模板占位符：${(b)格式} ${(e)格式} ${名称} {名称}
+var placeholder = regexp.MustCompile(`\$\{\(([be])\)([^}]+)\}|\$\{(\w+)\}|\{(\w+)\}`)
+
+// javaLayout 常见 m3u catchup-source 中的日期格式到 Go 布局的转换
+var javaLayout = strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02", "HH", "15", "mm", "04", "ss", "05")
+
+// expand 替换模板占位符，未知的占位符原样保留
+func expand(tmpl string, req request, loc *time.Location, now time.Time) string {
+	start, end := req.start.In(loc), req.end().In(loc)
+	return placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		sub := placeholder.FindStringSubmatch(m)
+		if sub[1] != "" {
+			t := start
+			if sub[1] == "e" {
+				t = end
+			}
+			return t.Format(javaLayout.Replace(sub[2]))
+		}
+		name := sub[3] + sub[4]
+		switch name {
+		case "start", "utc":
+			return strconv.FormatInt(start.Unix(), 10)
+		case "end", "utcend":
+			return strconv.FormatInt(end.Unix(), 10)
+		case "duration":
+			return strconv.Itoa(int(req.duration.Seconds()))
+		case "offset":
+			return strconv.Itoa(int(now.Sub(req.start).Seconds()))
+		case "timestamp", "lutc":
+			return strconv.FormatInt(now.Unix(), 10)
+		case "Y":
+			return start.Format("2006")
+		case "m":
+			return start.Format("01")
+		case "d":
+			return start.Format("02")
+		case "H":
+			return start.Format("15")
+		case "M":
+			return start.Format("04")
+		case "S":
+			return start.Format("05")
+		}
+		return m
+	})
+}
+
+func trimExt(s string) string {
+	if i := strings.LastIndexByte(s, '.'); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func benchCommand() *Command {
+	return &Command{
+		Name:    "bench",
+		Usage:   "<url>",
+		Summary: "并发拉流压测：统计首字节时间、吞吐量与断流次数",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			conns := fs.Int("c", 10, "并发连接数")
+			duration := fs.Duration("d", 30*time.Second, "压测时长")
+			ramp := fs.Duration("ramp", 0, "在这段时间内逐步建立全部连接，0 表示同时建立")
+			timeout := fs.Duration("timeout", 10*time.Second, "连接与等待响应头的超时时间")
+			ua := fs.String("ua", "TVGate-Bench", "User-Agent")
+			return func(args []string) int {
+				if len(args) == 0 {
+					fmt.Fprintln(os.Stderr, "❌ 需要指定压测地址，如 http://127.0.0.1:8888/udp/239.0.0.1:2000")
+					return 2
+				}
+				if *conns <= 0 || *duration <= 0 {
+					fmt.Fprintln(os.Stderr, "❌ -c 与 -d 必须大于 0")
+					return 2
+				}
+				b := &bench{url: args[0], conns: *conns, duration: *duration, ramp: *ramp, timeout: *timeout, ua: *ua}
+				return b.run()
+			}
+		},
+	}
+}
+
+// bench 每个连接持续读取响应直到压测结束，提前结束（断流）时重新连接
+type bench struct {
+	url      string
+	conns    int
+	duration time.Duration
+	ramp     time.Duration
+	timeout  time.Duration
+	ua       string
+
+	bytes    atomic.Uint64
+	requests atomic.Uint64
+	failures atomic.Uint64 // 连接失败或状态码不是 2xx
+	drops    atomic.Uint64 // 压测结束前断开
+
+	mu     sync.Mutex
+	ttfb   []time.Duration
+	errors map[string]int
+}
+
+func (b *bench) run() int {
+	ctx, cancel := context.WithTimeout(context.Background(), b.duration)
+	defer cancel()
+	// Ctrl+C 提前结束并输出结果
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	b.errors = make(map[string]int)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost:   b.conns,
+		ResponseHeaderTimeout: b.timeout,
+		DisableCompression:    true,
+	}}
+
+	fmt.Printf("🚀 压测 %s：%d 个连接，时长 %s\n", b.url, b.conns, b.duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < b.conns; i++ {
+		if b.ramp > 0 && i > 0 {
+			select {
+			case <-time.After(b.ramp / time.Duration(b.conns)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.worker(ctx, client)
+		}()
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	last, lastAt := uint64(0), start
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case now := <-ticker.C:
+			total := b.bytes.Load()
+			fmt.Printf("  %5.0fs  %8.2f Mbps  请求 %d  失败 %d  断流 %d\n", now.Sub(start).Seconds(),
+				mbps(total-last, now.Sub(lastAt)), b.requests.Load(), b.failures.Load(), b.drops.Load())
+			last, lastAt = total, now
+		}
+	}
+	return b.report(time.Since(start))
+}
+
+func (b *bench) worker(ctx context.Context, client *http.Client) {
+	buf := make([]byte, 32<<10)
+	for ctx.Err() == nil {
+		b.requests.Add(1)
+		reqStart := time.Now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+		if err != nil {
+			b.fail(err.Error())
+			return
+		}
+		req.Header.Set("User-Agent", b.ua)
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() == nil {
+				b.fail(err.Error())
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			b.fail(resp.Status)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		first := true
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				if first {
+					b.recordTTFB(time.Since(reqStart))
+					first = false
+				}
+				b.bytes.Add(uint64(n))
+			}
+			if err != nil {
+				// 压测结束时的断开不计入断流；HLS 等短响应读完后重新请求
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					b.drops.Add(1)
+				}
+				break
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func (b *bench) fail(reason string) {
+	b.failures.Add(1)
+	b.mu.Lock()
+	b.errors[reason]++
+	b.mu.Unlock()
+}
+
+func (b *bench) recordTTFB(d time.Duration) {
+	b.mu.Lock()
+	b.ttfb = append(b.ttfb, d)
+	b.mu.Unlock()
+}
+
+// report 输出汇总结果，所有请求都失败时退出码为 1
+func (b *bench) report(elapsed time.Duration) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := b.bytes.Load()
+	fmt.Printf("\n📊 压测结果（%s）\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  请求数: %d  失败: %d  断流: %d\n", b.requests.Load(), b.failures.Load(), b.drops.Load())
+	fmt.Printf("  总流量: %.2f MB  总吞吐: %.2f Mbps  每连接平均: %.2f Mbps\n",
+		float64(total)/1024/1024, mbps(total, elapsed), mbps(total, elapsed)/float64(b.conns))
+	if len(b.ttfb) > 0 {
+		sort.Slice(b.ttfb, func(i, j int) bool { return b.ttfb[i] < b.ttfb[j] })
+		pct := func(p float64) time.Duration { return b.ttfb[int(float64(len(b.ttfb)-1)*p)] }
+		fmt.Printf("  首字节时间: 最小 %s  P50 %s  P95 %s  最大 %s\n",
+			b.ttfb[0].Round(time.Millisecond), pct(0.5).Round(time.Millisecond),
+			pct(0.95).Round(time.Millisecond), b.ttfb[len(b.ttfb)-1].Round(time.Millisecond))
+	}
+	if len(b.errors) > 0 {
+		reasons := make([]string, 0, len(b.errors))
+		for r := range b.errors {
+			reasons = append(reasons, r)
+		}
+		sort.Slice(reasons, func(i, j int) bool { return b.errors[reasons[i]] > b.errors[reasons[j]] })
+		fmt.Println("  失败原因:")
+		for _, r := range reasons {
+			fmt.Printf("    %6d  %s\n", b.errors[r], r)
+		}
+	}
+	if len(b.ttfb) == 0 {
+		return 1
+	}
+	return 0
+}
+
+func mbps(bytes uint64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / d.Seconds() / 1e6
+}
@@ -0,0 +1,121 @@
+// Package cli 命令行子命令：serve、check、version、token、playlist、bench 等，
+// 不需要启动服务或调用 HTTP 接口即可完成的运维操作
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RunFunc 执行命令，args 为解析选项后剩余的参数，返回进程退出码
+type RunFunc func(args []string) int
+
+// Command 命令，可包含子命令；Setup 为 nil 的命令只用于组织子命令
+type Command struct {
+	Name        string
+	Usage       string // 选项之后的参数说明，如 "<url>"
+	Summary     string // 一行说明
+	Setup       func(fs *flag.FlagSet) RunFunc
+	Subcommands []*Command
+}
+
+// sharedFlags 所有命令共用的全局选项，可写在子命令之前或之后
+var sharedFlags = []string{"config", "remote-config", "remote-interval"}
+
+// Execute 按参数选择子命令并执行，返回进程退出码
+func (c *Command) Execute(args []string) int {
+	return c.execute(c.Name, args)
+}
+
+func (c *Command) execute(path string, args []string) int {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") && len(c.Subcommands) > 0 {
+		name := args[0]
+		if name == "help" {
+			if len(args) > 1 {
+				if sub := c.find(args[1]); sub != nil {
+					return sub.execute(path+" "+sub.Name, []string{"-h"})
+				}
+			}
+			c.printUsage(os.Stdout, path, nil)
+			return 0
+		}
+		if sub := c.find(name); sub != nil {
+			return sub.execute(path+" "+sub.Name, args[1:])
+		}
+		fmt.Fprintf(os.Stderr, "❌ 未知命令: %s\n\n", name)
+		c.printUsage(os.Stderr, path, nil)
+		return 2
+	}
+	if c.Setup == nil {
+		c.printUsage(os.Stderr, path, nil)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	for _, name := range sharedFlags {
+		if f := flag.CommandLine.Lookup(name); f != nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	}
+	run := c.Setup(fs)
+	fs.Usage = func() { c.printUsage(fs.Output(), path, fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	return run(fs.Args())
+}
+
+// PrintUsage 输出命令用法与 flag.CommandLine 中的全局选项，用于程序的 -h
+func (c *Command) PrintUsage() {
+	w := flag.CommandLine.Output()
+	c.printUsage(w, c.Name, nil)
+	fmt.Fprintf(w, "\n全局选项:\n")
+	flag.PrintDefaults()
+}
+
+func (c *Command) find(name string) *Command {
+	for _, sub := range c.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// printUsage 输出用法、子命令列表与选项说明
+func (c *Command) printUsage(w io.Writer, path string, fs *flag.FlagSet) {
+	if c.Summary != "" {
+		fmt.Fprintf(w, "%s\n\n", c.Summary)
+	}
+	fmt.Fprintf(w, "用法:\n")
+	if c.Setup != nil {
+		line := "  " + path + " [选项]"
+		if c.Usage != "" {
+			line += " " + c.Usage
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(c.Subcommands) > 0 {
+		fmt.Fprintf(w, "  %s <命令> [选项]\n\n命令:\n", path)
+		width := 0
+		for _, sub := range c.Subcommands {
+			width = max(width, len(sub.Name))
+		}
+		for _, sub := range c.Subcommands {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, sub.Name, sub.Summary)
+		}
+		fmt.Fprintf(w, "\n执行 \"%s help <命令>\" 查看命令的选项\n", path)
+	}
+	if fs != nil {
+		fmt.Fprintf(w, "\n选项:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
@@ -0,0 +1,337 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+func playlistCommand() *Command {
+	return &Command{
+		Name:        "playlist",
+		Summary:     "生成经 TVGate 访问的播放列表",
+		Subcommands: []*Command{playlistExportCommand()},
+	}
+}
+
+// playlistExportCommand 把内网播放列表（m3u 或 频道名,地址 格式的 txt）中的 rtp/udp/rtsp/http 地址
+// 改写为经 TVGate 访问的地址，并加入 publisher 中启用了本地播放的流，配置了 channels 时按分组与频道号排列
+func playlistExportCommand() *Command {
+	return &Command{
+		Name:    "export",
+		Usage:   "[播放列表文件或 URL]",
+		Summary: "改写内网播放列表中的地址并导出，同时加入 publisher 的本地播放地址",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			base := fs.String("base", "", "TVGate 的访问地址，如 http://111.222.111.222:8888（必填）")
+			token := fs.String("token", "", "在每个地址上附加的 token")
+			output := fs.String("o", "", "输出文件，默认输出到标准输出")
+			withPublisher := fs.Bool("publisher", true, "加入 publisher 中启用了本地播放的流")
+			return func(args []string) int {
+				if *base == "" {
+					fmt.Fprintln(os.Stderr, "❌ 需要用 -base 指定 TVGate 的访问地址")
+					return 2
+				}
+				if err := loadConfig(); err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️ 读取配置文件失败: %v\n", err)
+				}
+				tokenParam := config.Cfg.GlobalAuth.TokenParamName
+				if tokenParam == "" {
+					tokenParam = "my_token"
+				}
+				rw := &playlistRewriter{base: strings.TrimSuffix(*base, "/"), tokenParam: tokenParam, token: *token}
+
+				var lines []string
+				if len(args) > 0 {
+					src, err := readPlaylist(args[0])
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "❌ 读取播放列表失败: %v\n", err)
+						return 1
+					}
+					lines = rw.rewrite(src)
+				}
+				if *withPublisher {
+					lines = rw.appendPublisher(lines, config.Cfg.Publisher)
+				}
+				if len(lines) == 0 {
+					fmt.Fprintln(os.Stderr, "❌ 没有可导出的频道，请指定播放列表或配置 publisher")
+					return 1
+				}
+				lines = arrangePlaylist(lines, &config.Cfg.Channels)
+				if config.Cfg.EPG.Enabled {
+					lines = rw.withEPG(lines, config.Cfg.EPG.Path)
+				}
+
+				out := strings.Join(lines, "\n") + "\n"
+				if *output == "" {
+					fmt.Print(out)
+					return 0
+				}
+				if err := os.WriteFile(*output, []byte(out), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 写入 %s 失败: %v\n", *output, err)
+					return 1
+				}
+				fmt.Fprintf(os.Stderr, "✅ 已导出到 %s\n", *output)
+				return 0
+			}
+		},
+	}
+}
+
+// readPlaylist 读取本地文件或 http(s) 地址
+func readPlaylist(src string) ([]string, error) {
+	var r io.Reader
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("返回错误状态码 %d", resp.StatusCode)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), "\r"))
+	}
+	return lines, sc.Err()
+}
+
+type playlistRewriter struct {
+	base       string
+	tokenParam string
+	token      string
+}
+
+// rewrite 改写 m3u 中的地址行与 txt 中 "频道名,地址" 的地址部分，其余行保持不变
+func (rw *playlistRewriter) rewrite(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if i == 0 {
+			trimmed = strings.TrimPrefix(trimmed, "\ufeff")
+		}
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			out = append(out, trimmed)
+		case strings.Contains(trimmed, "://"):
+			if name, addr, ok := strings.Cut(trimmed, ","); ok && !strings.Contains(name, "://") {
+				out = append(out, name+","+rw.url(addr))
+			} else {
+				out = append(out, rw.url(trimmed))
+			}
+		default:
+			// txt 格式的分组行（如 央视,#genre#）
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// url 按 TVGate 的访问规则改写单个地址（见 m3u.ProxyPath），不支持的协议保持不变
+func (rw *playlistRewriter) url(raw string) string {
+	raw = strings.TrimSpace(raw)
+	path := m3u.ProxyPath(raw)
+	if path == raw {
+		return raw
+	}
+	return rw.withToken(rw.base + path)
+}
+
+// withToken 附加 token 参数
+func (rw *playlistRewriter) withToken(u string) string {
+	if rw.token == "" {
+		return u
+	}
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + rw.tokenParam + "=" + url.QueryEscape(rw.token)
+}
+
+// tvgURLAttr 匹配 m3u 头中已有的节目单地址属性
+var tvgURLAttr = regexp.MustCompile(`\s+(x-tvg-url|url-tvg)="[^"]*"`)
+
+// withEPG 在 m3u 头中指定 TVGate 提供的节目单地址，替换原有的 x-tvg-url / url-tvg
+func (rw *playlistRewriter) withEPG(lines []string, epgPath string) []string {
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "#EXTM3U") {
+		return lines
+	}
+	if epgPath == "" {
+		epgPath = "/xmltv.xml"
+	}
+	header := tvgURLAttr.ReplaceAllString(lines[0], "")
+	lines[0] = header + fmt.Sprintf(` x-tvg-url="%s"`, rw.withToken(rw.base+epgPath))
+	return lines
+}
+
+// appendPublisher 加入 publisher 中启用了本地播放的流，按流名称排序
+func (rw *playlistRewriter) appendPublisher(lines []string, pub *config.PublisherConfig) []string {
+	if pub == nil || pub.Path == "" {
+		return lines
+	}
+	names := make([]string, 0, len(pub.Streams))
+	for name := range pub.Streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prefix := rw.base + "/" + strings.Trim(pub.Path, "/") + "/play/"
+	// 与输入的格式一致：m3u 或 频道名,地址 格式的 txt
+	m3u := len(lines) == 0 || strings.HasPrefix(lines[0], "#EXTM3U")
+	grouped := false
+	for _, name := range names {
+		item := pub.Streams[name]
+		if item == nil || !item.Enabled {
+			continue
+		}
+		for _, play := range item.Stream.LocalPlayUrls {
+			if !play.Enabled {
+				continue
+			}
+			var u string
+			switch strings.ToLower(play.Protocol) {
+			case "flv":
+				u = prefix + name + ".flv"
+			case "hls":
+				u = prefix + name + "/index.m3u8"
+			default:
+				continue
+			}
+			u = rw.withToken(u)
+			switch {
+			case len(lines) == 0:
+				lines = append(lines, "#EXTM3U")
+				fallthrough
+			case m3u:
+				lines = append(lines, fmt.Sprintf("#EXTINF:-1 group-title=\"publisher\",%s", name), u)
+			default:
+				if !grouped {
+					lines = append(lines, "publisher,#genre#")
+					grouped = true
+				}
+				lines = append(lines, name+","+u)
+			}
+		}
+	}
+	return lines
+}
+
+// arrangePlaylist 按 channels 配置补全频道的分组、频道号、节目单 ID 与台标，并按分组顺序与频道号重新排列
+func arrangePlaylist(lines []string, ch *config.ChannelsConfig) []string {
+	if len(lines) == 0 || len(ch.Groups) == 0 && len(ch.List) == 0 {
+		return lines
+	}
+	if strings.HasPrefix(lines[0], "#EXTM3U") {
+		return arrangeM3U(lines, ch)
+	}
+	return arrangeTXT(lines, ch)
+}
+
+// arrangeM3U 以地址行为界把 m3u 划分为频道，频道之前的注释与 #EXTVLCOPT 等行随频道一起移动
+func arrangeM3U(lines []string, ch *config.ChannelsConfig) []string {
+	var blocks [][]string
+	var pending []string
+	for _, line := range lines[1:] {
+		pending = append(pending, line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			blocks = append(blocks, pending)
+			pending = nil
+		}
+	}
+	entries := make([]m3u.Entry, len(blocks))
+	for i, block := range blocks {
+		if parsed, _ := m3u.Parse(strings.NewReader(strings.Join(block, "\n"))); len(parsed) == 1 {
+			entries[i] = parsed[0]
+		}
+	}
+
+	out := append(make([]string, 0, len(lines)), lines[0])
+	for _, i := range m3u.Order(entries, ch) {
+		for _, line := range blocks[i] {
+			if strings.HasPrefix(line, "#EXTINF") {
+				for _, key := range []string{"tvg-id", "tvg-chno", "tvg-logo", "group-title"} {
+					if v := entries[i].Attrs[key]; v != "" {
+						line = setExtinfAttr(line, key, v)
+					}
+				}
+			}
+			out = append(out, line)
+		}
+	}
+	return append(out, pending...)
+}
+
+// setExtinfAttr 设置 #EXTINF 行中的属性，没有时加在频道名前
+func setExtinfAttr(line, key, value string) string {
+	attr := fmt.Sprintf(` %s="%s"`, key, strings.ReplaceAll(value, `"`, "'"))
+	re := regexp.MustCompile(`(?i)\s+` + regexp.QuoteMeta(key) + `="[^"]*"`)
+	if loc := re.FindStringIndex(line); loc != nil {
+		return line[:loc[0]] + attr + line[loc[1]:]
+	}
+	i := strings.LastIndex(line, ",")
+	if i < 0 {
+		return line
+	}
+	return line[:i] + attr + line[i:]
+}
+
+// arrangeTXT 按分组重新输出 频道名,地址 格式的 txt，注释等其它行放在最前面
+func arrangeTXT(lines []string, ch *config.ChannelsConfig) []string {
+	var entries []m3u.Entry
+	var out []string
+	group := ""
+	for _, line := range lines {
+		name, addr, ok := strings.Cut(line, ",")
+		name, addr = strings.TrimSpace(name), strings.TrimSpace(addr)
+		switch {
+		case ok && addr == "#genre#":
+			group = name
+		case ok && strings.Contains(addr, "://"):
+			entries = append(entries, m3u.Entry{Name: name, URL: addr, Group: group, Attrs: map[string]string{}})
+		case line != "":
+			out = append(out, line)
+		}
+	}
+
+	started := false
+	current := ""
+	for _, i := range m3u.Order(entries, ch) {
+		e := entries[i]
+		if !started || e.Group != current {
+			// 没有分组的频道排在分组之后时需要单独的分组行，否则会归入前一个分组
+			switch {
+			case e.Group != "":
+				out = append(out, e.Group+",#genre#")
+			case started:
+				out = append(out, "未分组,#genre#")
+			}
+			started, current = true, e.Group
+		}
+		out = append(out, e.Name+","+e.URL)
+	}
+	return out
+}
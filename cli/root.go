@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/check"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/config/schema"
+)
+
+// Root 返回根命令，未指定子命令时执行 serve
+func Root(serve RunFunc) *Command {
+	serveCmd := &Command{
+		Name:    "serve",
+		Summary: "启动服务（默认）",
+		Setup:   func(fs *flag.FlagSet) RunFunc { return serve },
+	}
+	return &Command{
+		Name:    filepath.Base(os.Args[0]),
+		Summary: "TVGate " + config.Version + " - IPTV 转发 / 代理工具",
+		Setup:   serveCmd.Setup,
+		Subcommands: []*Command{
+			serveCmd,
+			checkCommand(),
+			versionCommand(),
+			tokenCommand(),
+			playlistCommand(),
+			benchCommand(),
+			upgradeCommand(),
+			schemaCommand(),
+			dumpDefaultCommand(),
+		},
+	}
+}
+
+func checkCommand() *Command {
+	return &Command{
+		Name:    "check",
+		Summary: "校验配置文件，有错误时退出码为 1",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			return func(args []string) int { return check.Run(*config.ConfigFilePath) }
+		},
+	}
+}
+
+func versionCommand() *Command {
+	return &Command{
+		Name:    "version",
+		Summary: "显示程序版本",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			return func(args []string) int {
+				fmt.Println("程序版本:", config.Version)
+				fmt.Printf("Go 版本: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+				return 0
+			}
+		},
+	}
+}
+
+func schemaCommand() *Command {
+	return &Command{
+		Name:    "schema",
+		Summary: "输出配置文件的 JSON Schema",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			return func(args []string) int {
+				data, err := schema.JSON()
+				if err != nil {
+					log.Printf("生成配置 Schema 失败: %v", err)
+					return 1
+				}
+				os.Stdout.Write(data)
+				return 0
+			}
+		},
+	}
+}
+
+func dumpDefaultCommand() *Command {
+	return &Command{
+		Name:    "dump-default",
+		Summary: "输出包含全部配置项、默认值和说明的配置",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			return func(args []string) int {
+				data, err := schema.DumpDefault()
+				if err != nil {
+					log.Printf("生成默认配置失败: %v", err)
+					return 1
+				}
+				os.Stdout.Write(data)
+				return 0
+			}
+		},
+	}
+}
+
+// loadConfig 读取 -config 指定的配置文件（目录按其中的 config.yaml 处理）并补全默认值
+func loadConfig() error {
+	path := *config.ConfigFilePath
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "config.yaml")
+	}
+	err := load.LoadConfig(path)
+	config.Cfg.SetDefaults()
+	return err
+}
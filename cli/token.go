@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+)
+
+// token 类型
+const (
+	tokenJWT     = "jwt"
+	tokenDynamic = "dynamic"
+	tokenSigned  = "signed"
+)
+
+func tokenCommand() *Command {
+	return &Command{
+		Name:        "token",
+		Summary:     "离线生成 token，批量导入导出签发的 token",
+		Subcommands: []*Command{tokenCreateCommand(), tokenExportCommand(), tokenImportCommand()},
+	}
+}
+
+// tokenCreateCommand 按 global_auth 中的密钥生成 JWT、动态 token 或签名 URL，
+// 这几种 token 由服务端按密钥校验，无需保存，生成后立即可用
+func tokenCreateCommand() *Command {
+	return &Command{
+		Name:    "create",
+		Usage:   "[路径]",
+		Summary: "生成 JWT、动态 token 或签名 URL（动态 token 与签名 URL 需要指定路径）",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			typ := fs.String("type", "", "token 类型 jwt、dynamic、signed，默认为 global_auth 中第一个启用的类型")
+			ttl := fs.Duration("ttl", 24*time.Hour, "有效期，jwt 与 signed 使用，0 表示 JWT 不过期")
+			sub := fs.String("sub", "", "JWT 用户标识（sub）")
+			id := fs.String("jti", "", "JWT 编号（jti），默认随机生成，并发连接数按此统计")
+			channels := fs.String("channels", "", "JWT 允许访问的频道名或路径模式，逗号分隔")
+			deny := fs.String("deny", "", "JWT 禁止访问的频道名或路径模式，逗号分隔")
+			maxConns := fs.Int("max-conns", 0, "JWT 最大并发连接数，0 不限制")
+			ip := fs.String("ip", "", "绑定的客户端 IP 或网段（jwt），signed 启用 bind_ip 时为客户端 IP")
+			key := fs.String("key", "", "RS256 私钥文件（PEM）")
+			return func(args []string) int {
+				if err := loadConfig(); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 读取配置文件失败: %v\n", err)
+					return 1
+				}
+				ga := config.Cfg.GlobalAuth
+				t := *typ
+				if t == "" {
+					switch {
+					case ga.JWTTokens.EnableJWT:
+						t = tokenJWT
+					case ga.DynamicTokens.EnableDynamic:
+						t = tokenDynamic
+					case ga.SignedURL.EnableSigned:
+						t = tokenSigned
+					default:
+						fmt.Fprintln(os.Stderr, "❌ global_auth 未启用 jwt_tokens、dynamic_tokens 或 signed_url")
+						return 1
+					}
+				}
+				path := ""
+				if len(args) > 0 {
+					path = args[0]
+				}
+
+				var out string
+				var err error
+				switch t {
+				case tokenJWT:
+					claims := auth.JWTClaims{
+						Subject:      *sub,
+						ID:           *id,
+						Channels:     splitList(*channels),
+						DenyChannels: splitList(*deny),
+						MaxConns:     *maxConns,
+						IP:           *ip,
+					}
+					if claims.ID == "" {
+						buf := make([]byte, 8)
+						rand.Read(buf)
+						claims.ID = hex.EncodeToString(buf)
+					}
+					if *ttl > 0 {
+						claims.ExpiresAt = time.Now().Add(*ttl).Unix()
+					}
+					var pem []byte
+					if *key != "" {
+						if pem, err = os.ReadFile(*key); err != nil {
+							break
+						}
+					}
+					out, err = auth.SignJWT(ga.JWTTokens, claims, pem)
+				case tokenDynamic:
+					out, err = createDynamicToken(&ga, path)
+				case tokenSigned:
+					out, err = createSignedURL(ga.SignedURL, path, *ttl, *ip)
+				default:
+					err = fmt.Errorf("不支持的 token 类型: %s", t)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 生成 token 失败: %v\n", err)
+					return 1
+				}
+				fmt.Println(out)
+				return 0
+			}
+		},
+	}
+}
+
+// createDynamicToken 动态 token 与路径绑定，有效期为 dynamic_tokens.dynamic_ttl；返回带 token 参数的路径
+func createDynamicToken(ga *config.AuthConfig, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("动态 token 需要指定路径，如 /udp/239.0.0.1:2000")
+	}
+	if !ga.DynamicTokens.EnableDynamic {
+		return "", fmt.Errorf("未启用 global_auth.dynamic_tokens")
+	}
+	token, err := auth.NewGlobalTokenManagerFromConfig(ga).GenerateDynamicToken(path)
+	if err != nil {
+		return "", err
+	}
+	param := ga.TokenParamName
+	if param == "" {
+		param = "my_token"
+	}
+	return path + "?" + param + "=" + url.QueryEscape(token), nil
+}
+
+// createSignedURL 返回带过期时间与签名参数的路径
+func createSignedURL(cfg config.SignedURL, path string, ttl time.Duration, ip string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("签名 URL 需要指定路径，如 /udp/239.0.0.1:2000")
+	}
+	if cfg.Secret == "" {
+		return "", fmt.Errorf("未配置 global_auth.signed_url.secret")
+	}
+	if cfg.BindIP && ip == "" {
+		return "", fmt.Errorf("signed_url 启用了 bind_ip，需要用 -ip 指定客户端 IP")
+	}
+	if !cfg.BindIP {
+		ip = ""
+	}
+	sigParam, expiresParam := cfg.SigParam, cfg.ExpiresParam
+	if sigParam == "" {
+		sigParam = "sig"
+	}
+	if expiresParam == "" {
+		expiresParam = "expires"
+	}
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set(expiresParam, strconv.FormatInt(expires, 10))
+	q.Set(sigParam, auth.SignURL(cfg.Secret, path, expires, ip))
+	return path + "?" + q.Encode(), nil
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// tokenAPIFlags 批量导入导出通过运行中实例的管理接口完成，签发的 token 保存在服务进程（及共享存储）中
+type tokenAPIFlags struct {
+	server *string
+	key    *string
+}
+
+func newTokenAPIFlags(fs *flag.FlagSet) tokenAPIFlags {
+	return tokenAPIFlags{
+		server: fs.String("server", "", "TVGate Web 管理地址，如 http://127.0.0.1:8888/web/，默认按配置文件中的端口与 web.path"),
+		key:    fs.String("key", "", "接口密钥（web.api_keys 中 admin 角色的密钥），默认使用配置文件中的 web.api_token"),
+	}
+}
+
+// do 调用 /api/v1/tokens/ 下的接口，非 2xx 时返回接口的错误信息
+func (f tokenAPIFlags) do(method, endpoint string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	server, key := *f.server, *f.key
+	if server == "" || key == "" {
+		if err := loadConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ 读取配置文件失败: %v\n", err)
+		}
+	}
+	if server == "" {
+		port := config.Cfg.Server.Port
+		if config.Cfg.Server.HTTPPort > 0 {
+			port = config.Cfg.Server.HTTPPort
+		}
+		webPath := config.Cfg.Web.Path
+		if webPath == "" {
+			webPath = "/web/"
+		}
+		server = "http://127.0.0.1:" + strconv.Itoa(port) + "/" + strings.Trim(webPath, "/")
+	}
+	if key == "" {
+		key = config.Cfg.Web.APIToken
+	}
+	if key == "" {
+		return nil, fmt.Errorf("需要用 -key 指定接口密钥")
+	}
+
+	u := strings.TrimSuffix(server, "/") + "/api/v1/tokens/" + endpoint
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Minute}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, e.Error)
+		}
+		return nil, fmt.Errorf("返回错误状态码 %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// tokenExportCommand 导出运行中实例签发的全部未过期 token
+func tokenExportCommand() *Command {
+	return &Command{
+		Name:    "export",
+		Summary: "导出运行中实例签发的 token（JSON 或 CSV，含有效期、流量配额与访问控制）",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			api := newTokenAPIFlags(fs)
+			format := fs.String("format", "", "json 或 csv，默认按 -o 的扩展名，否则为 json")
+			output := fs.String("o", "", "输出文件，默认输出到标准输出")
+			return func(args []string) int {
+				f := auth.FormatOf(*format)
+				if f == "" && *format == "" {
+					if f = auth.FormatOf(*output); f == "" {
+						f = auth.FormatJSON
+					}
+				}
+				if f == "" {
+					fmt.Fprintf(os.Stderr, "❌ 不支持的格式: %s\n", *format)
+					return 2
+				}
+				resp, err := api.do(http.MethodGet, "export", url.Values{"format": {f}}, nil, "")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 导出 token 失败: %v\n", err)
+					return 1
+				}
+				defer resp.Body.Close()
+				if *output == "" {
+					if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+						fmt.Fprintf(os.Stderr, "❌ 导出 token 失败: %v\n", err)
+						return 1
+					}
+					return 0
+				}
+				data, err := io.ReadAll(resp.Body)
+				if err == nil {
+					err = os.WriteFile(*output, data, 0600)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 写入 %s 失败: %v\n", *output, err)
+					return 1
+				}
+				fmt.Fprintf(os.Stderr, "✅ 已导出到 %s\n", *output)
+				return 0
+			}
+		},
+	}
+}
+
+// tokenImportCommand 把 JSON 或 CSV 文件中的 token 导入运行中实例，导入前在本地校验文件格式
+func tokenImportCommand() *Command {
+	return &Command{
+		Name:    "import",
+		Usage:   "<文件>",
+		Summary: "把 JSON 或 CSV 文件中的 token 导入运行中实例",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			api := newTokenAPIFlags(fs)
+			format := fs.String("format", "", "json 或 csv，默认按文件扩展名")
+			replace := fs.Bool("replace", false, "覆盖已存在的 token，默认跳过")
+			return func(args []string) int {
+				if len(args) != 1 {
+					fmt.Fprintln(os.Stderr, "❌ 需要指定要导入的文件")
+					return 2
+				}
+				f := *format
+				if f == "" {
+					f = args[0]
+				}
+				if f = auth.FormatOf(f); f == "" {
+					fmt.Fprintln(os.Stderr, "❌ 无法确定文件格式，请用 -format 指定 json 或 csv")
+					return 2
+				}
+				data, err := os.ReadFile(args[0])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 读取 %s 失败: %v\n", args[0], err)
+					return 1
+				}
+				list, err := auth.ReadTokens(bytes.NewReader(data), f)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ %s: %v\n", args[0], err)
+					return 1
+				}
+
+				contentType := "application/json"
+				if f == auth.FormatCSV {
+					contentType = "text/csv"
+				}
+				q := url.Values{"format": {f}, "replace": {strconv.FormatBool(*replace)}}
+				resp, err := api.do(http.MethodPost, "import", q, bytes.NewReader(data), contentType)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 导入 token 失败: %v\n", err)
+					return 1
+				}
+				defer resp.Body.Close()
+				var res auth.ImportResult
+				if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 解析导入结果失败: %v\n", err)
+					return 1
+				}
+				for _, s := range res.Skipped {
+					fmt.Fprintf(os.Stderr, "⚠️ 跳过 %s\n", s)
+				}
+				fmt.Fprintf(os.Stderr, "✅ 已导入 %d/%d 个 token\n", res.Imported, len(list))
+				return 0
+			}
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/updater"
+)
+
+// upgradeCommand 检查 GitHub 上的版本，下载并校验当前平台的发布包，原子替换程序文件，
+// 然后向正在运行的进程发送 SIGHUP，由其热更新监听平滑重启
+func upgradeCommand() *Command {
+	return &Command{
+		Name:    "upgrade",
+		Summary: "从 GitHub 下载新版本，替换程序文件并平滑重启正在运行的服务",
+		Setup: func(fs *flag.FlagSet) RunFunc {
+			version := fs.String("version", "", "升级到指定版本，默认为最新的正式版本")
+			checkOnly := fs.Bool("check", false, "只检查是否有新版本，不升级")
+			force := fs.Bool("force", false, "版本不比当前新时也重新安装")
+			skipVerify := fs.Bool("skip-verify", false, "跳过签名校验（发布包没有 .sig 文件或程序没有内置公钥时使用）")
+			noRestart := fs.Bool("no-restart", false, "只替换程序文件，不通知正在运行的进程")
+			pid := fs.Int("pid", 0, "需要平滑重启的进程 PID，默认查找运行同一程序文件的进程")
+			return func(args []string) int {
+				return runUpgrade(*version, *checkOnly, *force, *skipVerify, *noRestart, *pid)
+			}
+		},
+	}
+}
+
+func runUpgrade(version string, checkOnly, force, skipVerify, noRestart bool, pid int) int {
+	// 读取配置中的 GitHub 加速地址，配置不可用时直接访问 GitHub
+	if err := loadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ 读取配置文件失败，直接访问 GitHub: %v\n", err)
+	}
+	cfg := config.Cfg.Github
+
+	target := version
+	if target == "" {
+		latest, err := updater.LatestRelease(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ 获取版本列表失败: %v\n", err)
+			return 1
+		}
+		target = latest
+	}
+	fmt.Printf("当前版本: %s，目标版本: %s\n", config.Version, target)
+
+	newer := updater.CompareVersions(target, config.Version) > 0
+	if checkOnly {
+		if newer {
+			fmt.Printf("🆕 发现新版本 %s\n", target)
+		} else {
+			fmt.Println("✅ 已是最新版本")
+		}
+		return 0
+	}
+	if !newer && !force && version == "" {
+		fmt.Println("✅ 已是最新版本")
+		return 0
+	}
+
+	execPath, err := updater.ExecutablePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 获取程序路径失败: %v\n", err)
+		return 1
+	}
+	// 替换前查找正在运行的进程，替换后 Linux 上的进程路径会变为 "(deleted)"
+	pids := []int{pid}
+	if pid == 0 {
+		pids = updater.RunningProcesses(execPath)
+	}
+
+	fmt.Printf("📦 下载 %s ...\n", target)
+	newExecPath, tmpDir, err := updater.PrepareBinary(cfg, target, skipVerify)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := updater.Install(newExecPath, execPath); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 替换程序文件失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("✅ 已安装 %s 到 %s，旧版本备份为 %s.bak\n", target, execPath, execPath)
+
+	if noRestart {
+		return 0
+	}
+	if len(pids) == 0 {
+		fmt.Println("⚠️ 没有找到正在运行的进程，请手动重启服务（如 systemctl restart TVGate）")
+		return 0
+	}
+	code := 0
+	for _, p := range pids {
+		proc, err := os.FindProcess(p)
+		if err == nil {
+			err = proc.Signal(syscall.SIGHUP)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ 通知进程 %d 平滑重启失败，请手动重启服务: %v\n", p, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("🔄 已通知进程 %d 平滑重启\n", p)
+	}
+	return code
+}
@@ -0,0 +1,327 @@
+// Package cluster 源站/边缘节点集群：边缘节点把同一频道的所有观看者合并为一条到源站的内部连接并在本地分发，
+// 源站按共享密钥放行边缘节点的回源请求，并替边缘节点校验它不认识的 token
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	httpclient "github.com/qist/tvgate/utils/http"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ErrUnavailable 所有源站都无法提供该频道
+var ErrUnavailable = errors.New("没有可用的源站")
+
+// signTTL 回源地址签名的有效期，只需覆盖发起请求的时间
+const signTTL = 5 * time.Minute
+
+// settings 生效的配置，回源时需要去掉客户端的 token 参数
+type settings struct {
+	config.ClusterConfig
+	tokenParam string
+}
+
+// origin 一个源站及其健康状态
+type origin struct {
+	base    string
+	healthy atomic.Bool
+}
+
+// tokenResult 源站 token 校验结果的缓存
+type tokenResult struct {
+	valid   bool
+	expires time.Time
+}
+
+// Manager 集群节点：边缘节点的源站健康检查、回源分发与远程 token 校验，源站的内部接口
+type Manager struct {
+	mu      sync.Mutex
+	s       settings
+	origins []*origin
+	client  *http.Client
+	stop    chan struct{}
+	relays  map[string]*relay
+	tokens  map[string]tokenResult
+}
+
+// New 创建未启用的集群节点
+func New() *Manager {
+	return &Manager{relays: make(map[string]*relay), tokens: make(map[string]tokenResult)}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg *config.Config) { Default.Configure(cfg) }
+
+// Configure 应用配置，配置变化时重建源站列表与回源连接，正在分发的频道继续使用原连接直到观看者离开
+func (m *Manager) Configure(cfg *config.Config) {
+	s := settings{ClusterConfig: cfg.Cluster, tokenParam: cfg.GlobalAuth.TokenParamName}
+	if s.tokenParam == "" {
+		s.tokenParam = "my_token"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reflect.DeepEqual(m.s, s) {
+		return
+	}
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.s = s
+	m.origins = nil
+	m.tokens = make(map[string]tokenResult)
+
+	switch s.Role {
+	case config.ClusterOrigin:
+		auth.SetCluster(s.Secret, nil)
+		logger.LogPrintf("✅ 集群模式: 源站，接口 %s", s.Path)
+	case config.ClusterEdge:
+		auth.SetCluster(s.Secret, m.validateToken)
+		for _, base := range s.Origins {
+			o := &origin{base: strings.TrimSuffix(strings.TrimSpace(base), "/")}
+			o.healthy.Store(true)
+			m.origins = append(m.origins, o)
+		}
+		m.client = newClient(s.Protocol, s.Timeout)
+		m.stop = make(chan struct{})
+		go m.healthLoop(m.stop, m.origins, m.client, s)
+		logger.LogPrintf("✅ 集群模式: 边缘节点，源站 %s", strings.Join(s.Origins, ", "))
+	default:
+		auth.SetCluster("", nil)
+	}
+}
+
+// newClient 回源客户端，HTTP/2 与 HTTP/3 下所有频道复用同一条连接。
+// 不设置整体超时，响应头与读取数据的超时由调用方控制
+func newClient(protocol string, timeout time.Duration) *http.Client {
+	proto := httpclient.NormalizeProtocol(protocol)
+	if proto == httpclient.ProtoH3 {
+		return &http.Client{Transport: &http3.Transport{}}
+	}
+	tr := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout: timeout,
+		IdleConnTimeout:     90 * time.Second,
+		ForceAttemptHTTP2:   true,
+	}
+	tr.Protocols = new(http.Protocols)
+	switch proto {
+	case httpclient.ProtoH1:
+		tr.Protocols.SetHTTP1(true)
+	case httpclient.ProtoH2:
+		tr.Protocols.SetHTTP1(true)
+		tr.Protocols.SetHTTP2(true)
+	default:
+		tr.Protocols.SetHTTP2(true)
+		tr.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return &http.Client{Transport: tr}
+}
+
+func (m *Manager) current() (settings, []*origin, *http.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.s, m.origins, m.client
+}
+
+// Relays 边缘节点是否经源站拉取该路径
+func (m *Manager) Relays(path string) bool {
+	s, origins, _ := m.current()
+	if s.Role != config.ClusterEdge || len(origins) == 0 {
+		return false
+	}
+	for _, prefix := range s.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fallback 所有源站都不可用时是否由边缘节点自己回源
+func (m *Manager) Fallback() bool {
+	s, _, _ := m.current()
+	return s.Fallback
+}
+
+// candidates 回源顺序：健康的源站按配置顺序在前，不健康的源站作为最后的尝试
+func candidates(origins []*origin) []*origin {
+	list := make([]*origin, 0, len(origins))
+	for _, o := range origins {
+		if o.healthy.Load() {
+			list = append(list, o)
+		}
+	}
+	for _, o := range origins {
+		if !o.healthy.Load() {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// apiURL 源站内部接口的签名地址
+func apiURL(s settings, o *origin, name string, query url.Values) string {
+	u, err := url.Parse(o.base + "/" + strings.Trim(s.Path, "/") + "/" + name)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = query.Encode()
+	auth.SignClusterURL(u, signTTL)
+	return u.String()
+}
+
+// healthLoop 定期检查源站，状态变化时记录日志
+func (m *Manager) healthLoop(stop chan struct{}, origins []*origin, client *http.Client, s settings) {
+	ticker := time.NewTicker(s.HealthInterval)
+	defer ticker.Stop()
+	for {
+		for _, o := range origins {
+			err := checkOrigin(client, s, o)
+			if healthy := err == nil; o.healthy.Swap(healthy) != healthy {
+				if healthy {
+					logger.LogPrintf("✅ 源站 %s 已恢复", o.base)
+				} else {
+					logger.LogPrintf("⚠️ 源站 %s 不可用: %v", o.base, err)
+				}
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func checkOrigin(client *http.Client, s settings, o *origin) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL(s, o, "health", nil), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// unreachableCache 所有源站都无法校验时，拒绝结果的缓存时长，避免源站故障期间每个请求都去重试
+const unreachableCache = 5 * time.Second
+
+// validateToken 边缘节点本地校验失败的 token 交给源站校验，有效与无效的结果都缓存 token_cache，
+// 所有源站都无法校验时按无效缓存 unreachableCache
+func (m *Manager) validateToken(token, urlPath, clientIP string) bool {
+	s, origins, client := m.current()
+	key := token + "\x00" + urlPath + "\x00" + clientIP
+	now := time.Now()
+	m.mu.Lock()
+	res, ok := m.tokens[key]
+	m.mu.Unlock()
+	if ok && now.Before(res.expires) {
+		return res.valid
+	}
+
+	q := url.Values{"token": {token}, "path": {urlPath}, "ip": {clientIP}}
+	for _, o := range candidates(origins) {
+		valid, err := checkToken(client, s, o, q)
+		if err != nil {
+			// 请求地址中带有完整 token，只记录底层错误
+			var ue *url.Error
+			if errors.As(err, &ue) {
+				err = ue.Err
+			}
+			logger.LogPrintf("⚠️ 源站 %s 校验 token 失败: %v", o.base, err)
+			continue
+		}
+		m.cacheToken(key, valid, now, s.TokenCache)
+		return valid
+	}
+	m.cacheToken(key, false, now, min(unreachableCache, s.TokenCache))
+	return false
+}
+
+// cacheToken 缓存源站校验结果，顺带清理过期项
+func (m *Manager) cacheToken(key string, valid bool, now time.Time, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, r := range m.tokens {
+		if now.After(r.expires) {
+			delete(m.tokens, k)
+		}
+	}
+	m.tokens[key] = tokenResult{valid: valid, expires: now.Add(ttl)}
+}
+
+func checkToken(client *http.Client, s settings, o *origin, q url.Values) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL(s, o, "token", q), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	var body struct {
+		Valid bool `json:"valid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, err
+	}
+	return body.Valid, nil
+}
+
+// Handler 源站的内部接口：health 供边缘节点检查，token 替边缘节点校验 token，只接受带集群签名的请求
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _, _ := m.current()
+		if s.Role != config.ClusterOrigin || !auth.VerifyClusterURL(r.URL) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(r.URL.Path, "/"+strings.Trim(s.Path, "/")+"/") {
+		case "health":
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "version": config.Version})
+		case "token":
+			q := r.URL.Query()
+			valid := true
+			if tm := auth.GetGlobalTokenManager(); tm != nil {
+				valid = tm.CheckToken(q.Get("token"), q.Get("path"), q.Get("ip"))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]bool{"valid": valid})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
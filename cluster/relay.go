@@ -0,0 +1,276 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/buffer/ringbuffer"
+)
+
+// relay 一个频道到源站的连接，收到的数据分发给本节点的所有观看者
+type relay struct {
+	m      *Manager
+	key    string
+	path   string
+	query  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	ready  chan struct{} // 收到源站响应或失败后关闭
+
+	mu          sync.Mutex
+	clients     map[*ringbuffer.RingBuffer]struct{}
+	contentType string
+	err         error
+}
+
+// Serve 经源站拉取请求的频道并写给客户端，同一频道的观看者共用一条回源连接。
+// 返回 ErrUnavailable 时尚未向客户端写出任何内容，调用方可以改为本地回源
+func (m *Manager) Serve(w http.ResponseWriter, r *http.Request, updateActive func()) error {
+	s, _, _ := m.current()
+	q := r.URL.Query()
+	q.Del(s.tokenParam)
+	query := q.Encode()
+	key := r.URL.Path
+	if query != "" {
+		key += "?" + query
+	}
+
+	ch, err := ringbuffer.New(2048)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	rl := m.relays[key]
+	if rl == nil {
+		rl = &relay{m: m, key: key, path: r.URL.Path, query: query, ready: make(chan struct{}),
+			clients: make(map[*ringbuffer.RingBuffer]struct{})}
+		rl.ctx, rl.cancel = context.WithCancel(context.Background())
+		m.relays[key] = rl
+		go rl.run()
+	}
+	rl.mu.Lock()
+	rl.clients[ch] = struct{}{}
+	rl.mu.Unlock()
+	m.mu.Unlock()
+	defer m.leave(rl, ch)
+
+	select {
+	case <-rl.ready:
+	case <-r.Context().Done():
+		return nil
+	}
+	rl.mu.Lock()
+	err, contentType := rl.err, rl.contentType
+	rl.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 客户端断开时唤醒阻塞的读取
+	stop := context.AfterFunc(r.Context(), ch.Close)
+	defer stop()
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	lastActive := time.Now()
+	for {
+		data, ok := ch.Pull()
+		if !ok {
+			return nil
+		}
+		if _, err := w.Write(data.([]byte)); err != nil {
+			return nil
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if updateActive != nil && time.Since(lastActive) >= 5*time.Second {
+			updateActive()
+			lastActive = time.Now()
+		}
+	}
+}
+
+// leave 观看者离开，最后一个观看者离开时断开回源连接
+func (m *Manager) leave(rl *relay, ch *ringbuffer.RingBuffer) {
+	ch.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rl.mu.Lock()
+	delete(rl.clients, ch)
+	empty := len(rl.clients) == 0
+	rl.mu.Unlock()
+	if empty {
+		rl.cancel()
+		if m.relays[rl.key] == rl {
+			delete(m.relays, rl.key)
+		}
+	}
+}
+
+// run 连接源站并分发数据，源站中断时切换到其它源站，直到没有观看者或所有源站都不可用
+func (rl *relay) run() {
+	defer rl.close()
+	started := false
+	for rl.ctx.Err() == nil {
+		resp, o, cancel, err := rl.open()
+		if err != nil {
+			if !started {
+				rl.finish("", err)
+			} else {
+				logger.LogPrintf("❌ 集群回源 %s 中断: %v", rl.key, err)
+			}
+			return
+		}
+		if !started {
+			started = true
+			rl.finish(resp.Header.Get("Content-Type"), nil)
+			logger.LogPrintf("🔗 集群回源 %s ← %s", rl.key, o.base)
+		} else {
+			logger.LogPrintf("🔄 集群回源 %s 切换到 %s", rl.key, o.base)
+		}
+
+		begin := time.Now()
+		err = rl.copy(resp.Body, cancel)
+		resp.Body.Close()
+		cancel()
+		if rl.ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			o.healthy.Store(false)
+		}
+		logger.LogPrintf("⚠️ 源站 %s 的 %s 已中断: %v", o.base, rl.key, err)
+		// 源站连上后立即断开时避免空转
+		if time.Since(begin) < time.Second {
+			select {
+			case <-time.After(time.Second):
+			case <-rl.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// open 按健康状态依次请求源站，返回的 cancel 在读取结束后调用
+func (rl *relay) open() (*http.Response, *origin, context.CancelFunc, error) {
+	s, origins, client := rl.m.current()
+	if len(origins) == 0 {
+		return nil, nil, nil, ErrUnavailable
+	}
+	var lastErr error
+	for _, o := range candidates(origins) {
+		target, err := originURL(s.URLTemplate, o.base, rl.path, rl.query)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ctx, cancel := context.WithCancel(rl.ctx)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			cancel()
+			return nil, nil, nil, err
+		}
+		timer := time.AfterFunc(s.Timeout, cancel)
+		resp, err := client.Do(req)
+		timer.Stop()
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp, o, cancel, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("状态码 %d", resp.StatusCode)
+		} else if rl.ctx.Err() == nil {
+			o.healthy.Store(false)
+		}
+		cancel()
+		if rl.ctx.Err() != nil {
+			return nil, nil, nil, rl.ctx.Err()
+		}
+		lastErr = fmt.Errorf("%s: %w", o.base, err)
+		logger.LogPrintf("⚠️ 集群回源 %s 失败: %v", rl.key, lastErr)
+	}
+	return nil, nil, nil, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+// copy 读取源站数据分发给观看者，超过 timeout 没有数据视为源站中断
+func (rl *relay) copy(body io.Reader, cancel context.CancelFunc) error {
+	s, _, _ := rl.m.current()
+	timer := time.AfterFunc(s.Timeout, cancel)
+	defer timer.Stop()
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			timer.Reset(s.Timeout)
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			rl.mu.Lock()
+			for ch := range rl.clients {
+				ch.Push(data)
+			}
+			rl.mu.Unlock()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// finish 记录源站的响应结果并唤醒等待的观看者
+func (rl *relay) finish(contentType string, err error) {
+	if contentType == "" {
+		contentType = "video/mp2t"
+	}
+	rl.mu.Lock()
+	rl.contentType, rl.err = contentType, err
+	rl.mu.Unlock()
+	close(rl.ready)
+}
+
+// close 回源结束，断开所有观看者
+func (rl *relay) close() {
+	rl.m.mu.Lock()
+	if rl.m.relays[rl.key] == rl {
+		delete(rl.m.relays, rl.key)
+	}
+	rl.m.mu.Unlock()
+	rl.cancel()
+	rl.mu.Lock()
+	for ch := range rl.clients {
+		ch.Close()
+	}
+	rl.mu.Unlock()
+}
+
+// originURL 按模板生成回源地址并添加集群签名：{origin} 源站地址，{path} 请求路径，
+// {addr} 路径中协议前缀之后的部分（如 /rtp/239.0.0.1:5000 中的 239.0.0.1:5000），{query} 去掉 token 的查询参数。
+// 模板中没有 {query} 时查询参数附加在末尾
+func originURL(template, base, path, query string) (string, error) {
+	addr := strings.TrimPrefix(path, "/")
+	if _, rest, ok := strings.Cut(addr, "/"); ok {
+		addr = rest
+	}
+	raw := strings.NewReplacer("{origin}", base, "{path}", path, "{addr}", addr, "{query}", query).Replace(template)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if query != "" && !strings.Contains(template, "{query}") {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += query
+	}
+	auth.SignClusterURL(u, signTTL)
+	return u.String(), nil
+}
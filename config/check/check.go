@@ -0,0 +1,1544 @@
+package check
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/i18n"
+	"github.com/qist/tvgate/jx"
+	"github.com/qist/tvgate/logger"
+	httpclient "github.com/qist/tvgate/utils/http"
+	"github.com/qist/tvgate/utils/ipacl"
+	"gopkg.in/yaml.v3"
+)
+
+// Issue 校验发现的问题
+type Issue struct {
+	File    string `json:"file,omitempty"`  // 所在文件，为空表示主配置文件
+	Line    int    `json:"line,omitempty"`  // 所在行号，未知时为 0
+	Field   string `json:"field,omitempty"` // 配置项，如 domainmap[2].target
+	Message string `json:"message"`
+	Warning bool   `json:"warning"` // 警告不影响启动
+}
+
+// HasErrors 是否存在错误（不含警告）
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+func (i Issue) String() string {
+	s := "❌ "
+	if i.Warning {
+		s = "⚠️ "
+	}
+	if i.Field != "" {
+		s += i.Field + ": "
+	}
+	return s + i.Message
+}
+
+// Run 校验配置文件并打印结果，返回进程退出码：无错误为 0，否则为 1
+func Run(path string) int {
+	// 与启动时一致，目录按其中的 config.yaml 处理
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "config.yaml")
+	}
+	issues, err := File(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 读取配置文件失败: %v\n", err)
+		return 1
+	}
+	errCount := 0
+	for _, issue := range issues {
+		if !issue.Warning {
+			errCount++
+		}
+		// 与编译器一致的 文件:行号: 格式，便于编辑器跳转
+		file := path
+		if issue.File != "" {
+			file = issue.File
+		}
+		fmt.Printf("%s:%d: %s\n", file, issue.Line, issue)
+	}
+	if errCount > 0 {
+		fmt.Printf("❌ 配置校验失败: %d 个错误, %d 个警告\n", errCount, len(issues)-errCount)
+		return 1
+	}
+	fmt.Printf("✅ 配置校验通过: %s（%d 个警告）\n", path, len(issues))
+	return 0
+}
+
+// File 校验配置文件（包括 includes 引入的文件），返回按行号排序的问题列表；仅在无法读取文件时返回 error
+func File(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, owner, err := load.ReadDocument(path)
+	if err != nil {
+		return []Issue{yamlIssue(err.Error())}, nil
+	}
+	if load.Format(path) != load.FormatTOML {
+		return document(data, doc, owner, true), nil
+	}
+
+	// TOML 转换为 YAML 后检查，主配置文件中的问题没有行号
+	if data, err = yaml.Marshal(doc); err != nil {
+		return []Issue{yamlIssue(err.Error())}, nil
+	}
+	issues := document(data, doc, owner, true)
+	for i := range issues {
+		if issues[i].File == "" {
+			issues[i].Line = 0
+		}
+	}
+	return issues, nil
+}
+
+// Bytes 校验 YAML 配置内容，不处理 includes
+func Bytes(data []byte) []Issue {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Issue{yamlIssue(err.Error())}
+	}
+	return document(data, &doc, nil, true)
+}
+
+// Untrusted 校验不可信来源（非 admin）提交的配置内容：不处理 includes，也不替换 ${...} 引用，
+// 避免通过校验结果读取服务器上的文件和环境变量；引用按原值检查
+func Untrusted(path string, data []byte) []Issue {
+	var doc yaml.Node
+	if err := load.UnmarshalNode(path, data, &doc); err != nil {
+		return []Issue{yamlIssue(err.Error())}
+	}
+	if load.Format(path) != load.FormatTOML {
+		return document(data, &doc, nil, false)
+	}
+	if data, err := yaml.Marshal(&doc); err == nil {
+		issues := document(data, &doc, nil, false)
+		for i := range issues {
+			issues[i].Line = 0
+		}
+		return issues
+	}
+	return []Issue{yamlIssue("无法转换 TOML 配置")}
+}
+
+// document 校验已解析的文档，data 为主配置文件内容，interpolate 为 false 时不替换引用
+func document(data []byte, doc *yaml.Node, owner map[*yaml.Node]string, interpolate bool) []Issue {
+	c := &checker{owner: owner}
+	if len(doc.Content) == 0 {
+		return []Issue{{Message: "配置文件为空"}}
+	}
+	c.root = doc.Content[0]
+
+	// 未知配置项不会导致加载失败，仅提示；按原始内容检查，其余类型错误以替换引用后的解码结果为准
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var raw config.Config
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(&raw); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			issue := yamlIssue(msg)
+			if m := unknownFieldRe.FindStringSubmatch(issue.Message); m != nil {
+				issue.Message = "未知配置项 " + m[1] + "，可能拼写错误"
+				issue.Warning = true
+				c.issues = append(c.issues, issue)
+			}
+		}
+	}
+
+	// ${ENV} ${file:...} 引用，解析失败时以原值继续检查
+	if interpolate {
+		if err := load.Interpolate(doc); err != nil {
+			for _, msg := range strings.Split(err.Error(), "\n") {
+				c.issues = append(c.issues, yamlIssue(msg))
+			}
+		}
+	}
+
+	var cfg config.Config
+	if err := c.root.Decode(&cfg); err != nil {
+		if errors.As(err, &typeErr) {
+			for _, msg := range typeErr.Errors {
+				c.issues = append(c.issues, yamlIssue(msg))
+			}
+		} else {
+			c.issues = append(c.issues, yamlIssue(err.Error()))
+			return c.issues
+		}
+	}
+
+	c.checkServer(&cfg)
+	c.checkPaths(&cfg)
+	c.checkDomainMap(&cfg)
+	c.checkProxyGroups(&cfg)
+	c.checkURLs(&cfg)
+	c.checkIPLists(&cfg)
+	c.checkAPIKeys(&cfg)
+	c.checkLanguage(&cfg)
+	c.checkConnLimit(&cfg)
+	c.checkLog(&cfg)
+	c.checkAccessLog(&cfg)
+	c.checkAlert(&cfg)
+	c.checkTracing(&cfg)
+	c.checkMetrics(&cfg)
+	c.checkEPG(&cfg)
+	c.checkCatchup(&cfg)
+	c.checkLogo(&cfg)
+	c.checkDLNA(&cfg)
+	c.checkCast(&cfg)
+	c.checkCluster(&cfg)
+	c.checkStore(&cfg)
+	c.checkRemux(&cfg)
+	c.checkIngest(&cfg)
+	c.checkChannels(&cfg)
+	c.checkUpstreamProfiles(&cfg)
+	c.checkCookieJar(&cfg)
+	c.checkRefresh(&cfg)
+	c.checkJX(&cfg)
+	c.checkTimeouts(&cfg)
+	c.checkLimits(&cfg)
+	c.checkCORS(&cfg)
+	c.checkDVR(&cfg)
+
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		if c.issues[i].File != c.issues[j].File {
+			return c.issues[i].File < c.issues[j].File
+		}
+		return c.issues[i].Line < c.issues[j].Line
+	})
+	return c.issues
+}
+
+var (
+	lineRe         = regexp.MustCompile(`line (\d+): `)
+	unknownFieldRe = regexp.MustCompile(`^field (\S+) not found in type`)
+)
+
+// yamlIssue 从 yaml 错误信息中提取行号
+func yamlIssue(msg string) Issue {
+	msg = strings.TrimPrefix(msg, "yaml: ")
+	issue := Issue{Message: msg}
+	if m := lineRe.FindStringSubmatchIndex(msg); m != nil {
+		issue.Line, _ = strconv.Atoi(msg[m[2]:m[3]])
+		issue.Message = msg[:m[0]] + msg[m[1]:]
+	}
+	return issue
+}
+
+// checker 语义校验，root 用于按配置项路径查找行号
+type checker struct {
+	root   *yaml.Node
+	owner  map[*yaml.Node]string // 来自 includes 引入文件的节点
+	issues []Issue
+}
+
+func (c *checker) add(warning bool, path []any, format string, args ...any) {
+	file, line := c.line(path)
+	c.issues = append(c.issues, Issue{
+		File:    file,
+		Line:    line,
+		Field:   fieldName(path),
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+func (c *checker) errorf(path []any, format string, args ...any) { c.add(false, path, format, args...) }
+
+func (c *checker) warnf(path []any, format string, args ...any) { c.add(true, path, format, args...) }
+
+// line 返回配置项所在文件与行，路径中 string 为映射键、int 为列表下标；找不到时返回最近的上级所在行
+func (c *checker) line(path []any) (string, int) {
+	node := c.root
+	var found *yaml.Node
+	for _, p := range path {
+		if node == nil {
+			break
+		}
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == key {
+						found = node.Content[i]
+						next = node.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+				next = node.Content[key]
+				found = next
+			}
+		}
+		node = next
+	}
+	if found == nil {
+		return "", 0
+	}
+	return c.owner[found], found.Line
+}
+
+// fieldName 将配置项路径格式化为 domainmap[2].target
+func fieldName(path []any) string {
+	var sb strings.Builder
+	for _, p := range path {
+		switch v := p.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(v)
+		case int:
+			fmt.Fprintf(&sb, "[%d]", v)
+		}
+	}
+	return sb.String()
+}
+
+// at 返回下级配置项路径，不修改 path
+func at(path []any, elems ...any) []any {
+	return append(append(make([]any, 0, len(path)+len(elems)), path...), elems...)
+}
+
+func validPort(port int) bool { return port > 0 && port <= 65535 }
+
+// checkServer 端口与多播网卡
+func (c *checker) checkServer(cfg *config.Config) {
+	ports := []struct {
+		key  []any
+		port int
+	}{
+		{[]any{"server", "port"}, cfg.Server.Port},
+		{[]any{"server", "http_port"}, cfg.Server.HTTPPort},
+		{[]any{"server", "tls", "https_port"}, cfg.Server.TLS.HTTPSPort},
+	}
+	for _, p := range ports {
+		if p.port != 0 && !validPort(p.port) {
+			c.errorf(p.key, "端口超出范围: %d", p.port)
+		}
+	}
+	if cfg.Server.HTTPPort > 0 && cfg.Server.HTTPPort == cfg.Server.TLS.HTTPSPort {
+		c.errorf([]any{"server", "tls", "https_port"}, "与 server.http_port 使用了相同端口 %d", cfg.Server.HTTPPort)
+	}
+	acme := cfg.Server.TLS.ACME
+	if cfg.Server.TLS.HTTPSPort > 0 && !acme.Enabled && (cfg.Server.TLS.CertFile == "" || cfg.Server.TLS.KeyFile == "") {
+		c.warnf([]any{"server", "tls"}, "配置了 https_port 但未配置 certfile/keyfile，将以 HTTP 提供服务")
+	}
+	if acme.Enabled {
+		key := []any{"server", "tls", "acme"}
+		if cfg.Server.TLS.HTTPSPort == 0 {
+			c.errorf(at(key, "enabled"), "自动证书需要配置 server.tls.https_port")
+		}
+		if len(acme.Domains) == 0 {
+			c.errorf(at(key, "domains"), "未配置申请证书的域名")
+		}
+		for i, domain := range acme.Domains {
+			switch {
+			case strings.Contains(domain, "*"):
+				c.errorf(at(key, "domains", i), "不支持通配符域名 %q（HTTP-01、TLS-ALPN-01 验证无法签发）", domain)
+			case strings.ContainsAny(domain, ":/ ") || net.ParseIP(domain) != nil:
+				c.errorf(at(key, "domains", i), "无效的域名 %q，只填写主机名", domain)
+			}
+		}
+		if acme.DirectoryURL != "" {
+			c.checkURL(at(key, "directory_url"), acme.DirectoryURL, "https")
+		}
+		if cfg.Server.TLS.CertFile != "" || cfg.Server.TLS.KeyFile != "" {
+			c.warnf(key, "已启用自动证书，server.tls.certfile/keyfile 将被忽略")
+		}
+	}
+
+	c.checkListeners(cfg)
+
+	for i, name := range cfg.Server.MulticastIfaces {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := []any{"server", "multicast_ifaces", i}
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			c.errorf(key, "网卡 %s 不存在", name)
+			continue
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			c.warnf(key, "网卡 %s 未启用", name)
+		} else if iface.Flags&net.FlagMulticast == 0 {
+			c.warnf(key, "网卡 %s 不支持多播", name)
+		}
+	}
+}
+
+// checkListeners 额外监听地址：格式、与其他端口冲突、证书与功能名称
+func (c *checker) checkListeners(cfg *config.Config) {
+	used := make(map[string]string)
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"server.port", cfg.Server.Port},
+		{"server.http_port", cfg.Server.HTTPPort},
+		{"server.tls.https_port", cfg.Server.TLS.HTTPSPort},
+	} {
+		if p.port > 0 {
+			used[fmt.Sprintf(":%d", p.port)] = p.name
+		}
+	}
+
+	for i, l := range cfg.Server.Listeners {
+		key := []any{"server", "listeners", i}
+		addr := strings.TrimSpace(l.Listen)
+		path, unix := strings.CutPrefix(addr, "unix:")
+		switch {
+		case addr == "":
+			c.errorf(at(key, "listen"), "监听地址不能为空")
+			continue
+		case unix:
+			if path == "" {
+				c.errorf(at(key, "listen"), "Unix Socket 路径不能为空")
+			}
+		default:
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				c.errorf(at(key, "listen"), "监听地址格式错误 %q，应为 :8080、192.168.1.2:8080 或 unix:/path", addr)
+				continue
+			}
+			if n, err := strconv.Atoi(port); err != nil || !validPort(n) {
+				c.errorf(at(key, "listen"), "端口超出范围: %s", port)
+			}
+		}
+		if name, ok := used[addr]; ok {
+			c.errorf(at(key, "listen"), "与 %s 使用了相同的监听地址 %s", name, addr)
+		}
+		used[addr] = fmt.Sprintf("server.listeners[%d]", i)
+
+		if (l.CertFile == "") != (l.KeyFile == "") {
+			c.errorf(key, "certfile 与 keyfile 需要同时配置")
+		}
+		if l.ACME && !cfg.Server.TLS.ACME.Enabled {
+			c.errorf(at(key, "acme"), "未启用 server.tls.acme")
+		}
+		if l.EnableH3 && (unix || (l.CertFile == "" && !l.ACME)) {
+			c.warnf(at(key, "enable_h3"), "HTTP/3 只能用于配置了证书的 TCP 地址，将被忽略")
+		}
+		for j, f := range l.Features {
+			if !slices.Contains(config.ListenerFeatures, f) {
+				c.errorf(at(key, "features", j), "未知的功能 %q，可选 %s", f, strings.Join(config.ListenerFeatures, "、"))
+			}
+		}
+	}
+}
+
+// 默认代理处理的路径前缀，注册在其下的路由会使对应代理失效
+var proxyPrefixes = []string{"/udp/", "/rtp/", "/rtsp/"}
+
+// checkPaths 检查 monitor/web/jx/publisher 路由是否重复或占用代理路径
+func (c *checker) checkPaths(cfg *config.Config) {
+	type route struct {
+		key  []any
+		path string
+	}
+	normalize := func(p, def string, dir bool) string {
+		if p == "" {
+			p = def
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		if dir && !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+		return p
+	}
+	routes := []route{
+		{[]any{"monitor", "path"}, normalize(cfg.Monitor.Path, "/status", false)},
+		{[]any{"jx", "path"}, normalize(cfg.JX.Path, "/jx", false)},
+	}
+	if cfg.Web.Enabled {
+		routes = append(routes, route{[]any{"web", "path"}, normalize(cfg.Web.Path, "/web/", true)})
+	}
+	if cfg.Health.Enabled {
+		for _, h := range []struct {
+			key, path, def string
+		}{{"liveness_path", cfg.Health.LivenessPath, "/healthz"}, {"readiness_path", cfg.Health.ReadinessPath, "/readyz"}} {
+			if h.path != "" && !strings.HasPrefix(h.path, "/") {
+				c.errorf([]any{"health", h.key}, "路径应以 / 开头")
+			}
+			routes = append(routes, route{[]any{"health", h.key}, normalize(h.path, h.def, false)})
+		}
+	}
+	if cfg.EPG.Enabled {
+		if cfg.EPG.Path != "" && !strings.HasPrefix(cfg.EPG.Path, "/") {
+			c.errorf([]any{"epg", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"epg", "path"}, normalize(cfg.EPG.Path, "/xmltv.xml", false)})
+	}
+	if cfg.Logo.Enabled {
+		if cfg.Logo.Path != "" && !strings.HasPrefix(cfg.Logo.Path, "/") {
+			c.errorf([]any{"logo", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"logo", "path"}, normalize(cfg.Logo.Path, "/logo", true)})
+	}
+	if cfg.Catchup.Enabled {
+		if cfg.Catchup.Path != "" && !strings.HasPrefix(cfg.Catchup.Path, "/") {
+			c.errorf([]any{"catchup", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"catchup", "path"}, normalize(cfg.Catchup.Path, "/timeshift", true)})
+	}
+	if cfg.DLNA.Enabled {
+		if cfg.DLNA.Path != "" && !strings.HasPrefix(cfg.DLNA.Path, "/") {
+			c.errorf([]any{"dlna", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"dlna", "path"}, normalize(cfg.DLNA.Path, "/dlna", true)})
+	}
+	if cfg.DVR.Enabled && cfg.DVR.VOD.Enabled {
+		if cfg.DVR.VOD.Path != "" && !strings.HasPrefix(cfg.DVR.VOD.Path, "/") {
+			c.errorf([]any{"dvr", "vod", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"dvr", "vod", "path"}, normalize(cfg.DVR.VOD.Path, "/vod/", true)})
+	}
+	if cfg.Cluster.Role == config.ClusterOrigin {
+		if cfg.Cluster.Path != "" && !strings.HasPrefix(cfg.Cluster.Path, "/") {
+			c.errorf([]any{"cluster", "path"}, "路径应以 / 开头")
+		}
+		routes = append(routes, route{[]any{"cluster", "path"}, normalize(cfg.Cluster.Path, "/cluster/", true)})
+	}
+	if cfg.Publisher != nil && cfg.Publisher.Path != "" {
+		p := normalize(cfg.Publisher.Path, "", true)
+		if p == "/" {
+			c.warnf([]any{"publisher", "path"}, "为 / 时不会注册推流路由")
+		} else {
+			routes = append(routes, route{[]any{"publisher", "path"}, p})
+		}
+	}
+
+	for i, a := range routes {
+		for _, b := range routes[:i] {
+			if strings.TrimSuffix(a.path, "/") == strings.TrimSuffix(b.path, "/") {
+				c.errorf(a.key, "路径 %s 与 %s 冲突", a.path, fieldName(b.key))
+			}
+		}
+		for _, prefix := range proxyPrefixes {
+			if strings.HasPrefix(a.path+"/", prefix) {
+				c.errorf(a.key, "路径 %s 占用了代理路径 %s", a.path, prefix)
+			}
+		}
+	}
+}
+
+// checkDomainMap 检查域名映射的目标与重复项
+func (c *checker) checkDomainMap(cfg *config.Config) {
+	seen := make(map[string]int)
+	for i, m := range cfg.DomainMap {
+		if m == nil {
+			continue
+		}
+		key := []any{"domainmap", i}
+		if m.Source == "" {
+			c.errorf(at(key, "source"), "不能为空")
+		} else if strings.HasPrefix(m.Source, "~") {
+			if _, err := regexp.Compile(strings.TrimPrefix(m.Source, "~")); err != nil {
+				c.errorf(at(key, "source"), "正则表达式错误: %v", err)
+			}
+		}
+		if m.Target == "" && len(m.Targets) == 0 {
+			c.errorf(at(key, "target"), "不能为空")
+		}
+		targets := append([]string{m.Target}, m.Targets...)
+		for j, t := range targets {
+			tkey := at(key, "target")
+			if j > 0 {
+				tkey = at(key, "targets", j-1)
+			}
+			if strings.Contains(t, "://") || strings.ContainsAny(t, " /") {
+				c.errorf(tkey, "只填写域名[:端口]，协议使用 protocol 配置: %s", t)
+			}
+		}
+		switch strings.ToLower(m.Protocol) {
+		case "", "http", "https", "rtsp":
+		default:
+			c.errorf(at(key, "protocol"), "不支持的协议: %s", m.Protocol)
+		}
+
+		dup := strings.ToLower(m.Source) + "|" + m.PathPrefix
+		if first, ok := seen[dup]; ok {
+			c.errorf(key, "与 domainmap[%d] 的 source/path_prefix 重复，只有第一条会生效", first)
+		} else {
+			seen[dup] = i
+		}
+	}
+}
+
+// checkProxyGroups 检查代理组中的代理地址
+func (c *checker) checkProxyGroups(cfg *config.Config) {
+	names := make([]string, 0, len(cfg.ProxyGroups))
+	for name := range cfg.ProxyGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		group := cfg.ProxyGroups[name]
+		key := []any{"proxygroups", name}
+		if group == nil {
+			c.errorf(key, "代理组为空")
+			continue
+		}
+		if len(group.Proxies) == 0 {
+			c.errorf(at(key, "proxies"), "未配置代理")
+		}
+		seen := make(map[string]int)
+		for i, p := range group.Proxies {
+			pkey := at(key, "proxies", i)
+			if p == nil {
+				c.errorf(pkey, "代理为空")
+				continue
+			}
+			if p.Name == "" {
+				c.errorf(pkey, "代理名称为空")
+			} else if first, ok := seen[p.Name]; ok {
+				c.errorf(at(pkey, "name"), "代理名称 %s 与第 %d 个代理重复", p.Name, first)
+			} else {
+				seen[p.Name] = i
+			}
+			switch strings.ToLower(p.Type) {
+			case "http", "https", "socks5", "socks4", "socks4a":
+			default:
+				c.errorf(at(pkey, "type"), "不支持的代理类型: %s", p.Type)
+			}
+			if p.Server == "" {
+				c.errorf(at(pkey, "server"), "代理地址为空")
+			} else if strings.Contains(p.Server, "://") || strings.ContainsAny(p.Server, " /") {
+				c.errorf(at(pkey, "server"), "只填写 IP 或域名: %s", p.Server)
+			}
+			if !validPort(p.Port) {
+				c.errorf(at(pkey, "port"), "端口超出范围: %d", p.Port)
+			}
+		}
+		for i, hop := range group.Fallback {
+			if hop.Group == config.FallbackDirect {
+				continue
+			}
+			if _, ok := cfg.ProxyGroups[hop.Group]; !ok {
+				c.errorf(at(key, "fallback", i, "group"), "代理组 %s 不存在", hop.Group)
+			}
+		}
+		if group.HealthCheck != nil && group.HealthCheck.Enabled {
+			c.checkURL(at(key, "health_check", "url"), group.HealthCheck.URL, "http", "https")
+		}
+	}
+}
+
+// checkURL 检查 URL 格式与协议，为空时不检查
+func (c *checker) checkURL(key []any, raw string, schemes ...string) {
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		c.errorf(key, "URL 格式错误: %v", err)
+		return
+	}
+	if u.Host == "" {
+		c.errorf(key, "URL 缺少主机: %s", raw)
+		return
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) {
+			return
+		}
+	}
+	c.errorf(key, "不支持的 URL 协议 %q，应为 %s", u.Scheme, strings.Join(schemes, "/"))
+}
+
+// checkURLs 检查其余 URL 类配置
+func (c *checker) checkURLs(cfg *config.Config) {
+	if cfg.Github.Enabled {
+		c.checkURL([]any{"github", "url"}, cfg.Github.URL, "http", "https")
+		for i, u := range cfg.Github.BackupURLs {
+			c.checkURL([]any{"github", "backup_urls", i}, u, "http", "https")
+		}
+	}
+	if cfg.Github.AutoCheck && cfg.Github.CheckInterval > 0 && cfg.Github.CheckInterval < time.Hour {
+		c.warnf([]any{"github", "check_interval"}, "检查新版本间隔 %s 过短，可能触发 GitHub API 访问频率限制", cfg.Github.CheckInterval)
+	}
+	groups := make([]string, 0, len(cfg.JX.APIGroups))
+	for name := range cfg.JX.APIGroups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		if g := cfg.JX.APIGroups[name]; g != nil {
+			for i, u := range g.Endpoints {
+				c.checkURL([]any{"jx", "api_groups", name, "endpoints", i}, u, "http", "https")
+			}
+		}
+	}
+}
+
+// checkJX 检查视频解析的缓存与限速，解析器的类型与对应的地址或命令，以及无头浏览器的地址
+func (c *checker) checkJX(cfg *config.Config) {
+	if cfg.JX.CacheTTL < 0 {
+		c.errorf([]any{"jx", "cache_ttl"}, "不能为负数")
+	}
+	if cfg.JX.RateLimit.Client < 0 {
+		c.errorf([]any{"jx", "rate_limit", "client"}, "不能为负数")
+	}
+	if cfg.JX.RateLimit.Site < 0 {
+		c.errorf([]any{"jx", "rate_limit", "site"}, "不能为负数")
+	}
+	names := make([]string, 0, len(cfg.JX.Resolvers))
+	for name := range cfg.JX.Resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	browser := false
+	for _, name := range names {
+		rc := cfg.JX.Resolvers[name]
+		if rc == nil || rc.Disabled {
+			continue
+		}
+		key := []any{"jx", "resolvers", name}
+		switch strings.ToLower(rc.Type) {
+		case "":
+			if !jx.Builtin(name) {
+				c.errorf(append(key, "type"), "没有名为 %s 的内置解析器，外部解析器需要设置 type 为 http 或 exec", name)
+			}
+		case "http":
+			c.checkURL(append(key, "url"), rc.URL, "http", "https")
+			if len(rc.Domains) == 0 {
+				c.errorf(append(key, "domains"), "外部解析器需要设置处理的网站域名")
+			}
+		case "exec":
+			if len(rc.Command) == 0 || rc.Command[0] == "" {
+				c.errorf(append(key, "command"), "不能为空")
+			}
+			if len(rc.Domains) == 0 {
+				c.errorf(append(key, "domains"), "外部解析器需要设置处理的网站域名")
+			}
+		case "browser":
+			if rc.Match != "" {
+				if _, err := regexp.Compile(rc.Match); err != nil {
+					c.errorf(append(key, "match"), "不是有效的正则表达式: %v", err)
+				}
+			}
+			if len(rc.Domains) == 0 {
+				c.errorf(append(key, "domains"), "外部解析器需要设置处理的网站域名")
+			}
+			browser = true
+		default:
+			c.errorf(append(key, "type"), "未知的解析器类型 %s，可选 http、exec、browser 或留空使用内置解析器", rc.Type)
+		}
+	}
+	if browser && cfg.JX.Browser.Endpoint != "" {
+		c.checkURL([]any{"jx", "browser", "endpoint"}, cfg.JX.Browser.Endpoint, "http", "https", "ws", "wss")
+	}
+	if browser && cfg.JX.Browser.Endpoint == "" && cfg.JX.Browser.Path != "" {
+		if _, err := exec.LookPath(cfg.JX.Browser.Path); err != nil {
+			c.warnf([]any{"jx", "browser", "path"}, "找不到浏览器 %s: %v", cfg.JX.Browser.Path, err)
+		}
+	}
+}
+
+// checkCORS 检查跨域策略
+func (c *checker) checkCORS(cfg *config.Config) {
+	cors := cfg.Middleware.CORS
+	checkPolicy := func(key []any, p config.CORSPolicy, credentials bool) {
+		for i, o := range p.AllowOrigins {
+			if strings.Contains(o, "*") && o != "*" {
+				if _, err := path.Match(o, ""); err != nil {
+					c.errorf(append(key, "allow_origins", i), "通配符格式错误: %v", err)
+				}
+			}
+			if o == "*" && credentials {
+				c.warnf(append(key, "allow_origins", i), "按 * 允许的来源不会携带凭据，allow_credentials 只对列出的具体来源生效")
+			}
+		}
+		if p.MaxAge < 0 {
+			c.errorf(append(key, "max_age"), "不能为负数")
+		}
+	}
+	checkPolicy([]any{"middleware", "cors"}, cors.CORSPolicy, cors.AllowCredentials)
+	for i, pp := range cors.Paths {
+		key := []any{"middleware", "cors", "paths", i}
+		if !strings.HasPrefix(pp.PathPrefix, "/") {
+			c.errorf(append(key, "path_prefix"), "必须以 / 开头")
+		}
+		checkPolicy(key, pp.CORSPolicy, cors.AllowCredentials || pp.AllowCredentials)
+	}
+}
+
+// checkDVR 检查定时录制
+func (c *checker) checkDVR(cfg *config.Config) {
+	d := cfg.DVR
+	if d.MaxConcurrent <= 0 {
+		c.errorf([]any{"dvr", "max_concurrent"}, "必须大于 0")
+	}
+	if d.MinFreeMB < 0 {
+		c.errorf([]any{"dvr", "min_free_mb"}, "不能为负数")
+	}
+	if d.PrePadding < 0 {
+		c.errorf([]any{"dvr", "pre_padding"}, "不能为负数")
+	}
+	if d.PostPadding < 0 {
+		c.errorf([]any{"dvr", "post_padding"}, "不能为负数")
+	}
+	if d.Retry <= 0 {
+		c.errorf([]any{"dvr", "retry"}, "必须大于 0")
+	}
+	if d.BaseURL != "" {
+		if u, err := url.Parse(d.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf([]any{"dvr", "base_url"}, "应为 http(s)://主机:端口 形式的地址")
+		}
+	} else if d.Enabled && cfg.Server.HTTPPort == 0 && cfg.Server.TLS.HTTPSPort > 0 {
+		c.warnf([]any{"dvr", "base_url"}, "未配置 server.http_port，频道仅在 HTTPS 端口提供，需要配置 base_url")
+	}
+	if d.Dir != "" {
+		if fi, err := os.Stat(d.Dir); err == nil && !fi.IsDir() {
+			c.errorf([]any{"dvr", "dir"}, "不是目录")
+		}
+	}
+
+	post := d.Post
+	if d.Enabled && (post.Remux || post.Thumbnail) {
+		bin := post.FFmpeg
+		if bin == "" {
+			bin = "ffmpeg"
+		}
+		if _, err := exec.LookPath(bin); err != nil {
+			c.warnf([]any{"dvr", "post", "ffmpeg"}, "找不到 ffmpeg，无法转封装与生成缩略图: %v", err)
+		}
+	}
+	if post.KeepTS && !post.Remux {
+		c.warnf([]any{"dvr", "post", "keep_ts"}, "未启用 remux 时不起作用")
+	}
+	if len(post.Command) > 0 && post.Command[0] == "" {
+		c.errorf([]any{"dvr", "post", "command", 0}, "缺少程序")
+	}
+	c.checkURL([]any{"dvr", "post", "webhook"}, post.Webhook, "http", "https")
+	if d.VOD.Enabled && !d.Enabled {
+		c.warnf([]any{"dvr", "vod", "enabled"}, "未启用定时录制时不起作用")
+	}
+	if d.VOD.SegmentDuration > 0 && d.VOD.SegmentDuration < time.Second {
+		c.errorf([]any{"dvr", "vod", "segment_duration"}, "不能小于 1s")
+	}
+}
+
+// checkLimits 检查请求大小限制与错误页模板
+func (c *checker) checkLimits(cfg *config.Config) {
+	if cfg.Server.Limits.MaxBodyKB < 0 {
+		c.errorf([]any{"server", "limits", "max_body_kb"}, "不能为负数")
+	}
+	for i, rt := range cfg.Server.Limits.Routes {
+		key := []any{"server", "limits", "routes", i}
+		if !strings.HasPrefix(rt.PathPrefix, "/") {
+			c.errorf(append(key, "path_prefix"), "必须以 / 开头")
+		}
+		if rt.MaxBodyKB < 0 {
+			c.errorf(append(key, "max_body_kb"), "不能为负数")
+		}
+	}
+	if cfg.ErrorPages.Template != "" {
+		if _, err := os.Stat(cfg.ErrorPages.Template); err != nil {
+			c.errorf([]any{"error_pages", "template"}, "模板文件不可用: %v", err)
+		}
+	}
+	for code, file := range cfg.ErrorPages.Pages {
+		if code < 400 || code > 599 {
+			c.errorf([]any{"error_pages", "pages", strconv.Itoa(code)}, "状态码应在 400-599 之间")
+		}
+		if _, err := os.Stat(file); err != nil {
+			c.errorf([]any{"error_pages", "pages", strconv.Itoa(code)}, "模板文件不可用: %v", err)
+		}
+	}
+}
+
+// checkTimeouts 检查按路由的超时
+func (c *checker) checkTimeouts(cfg *config.Config) {
+	for i, rt := range cfg.Server.Timeouts.Routes {
+		key := []any{"server", "timeouts", "routes", i}
+		if rt.PathPrefix != "" && !strings.HasPrefix(rt.PathPrefix, "/") {
+			c.errorf(append(key, "path_prefix"), "必须以 / 开头")
+		}
+		if rt.Read < 0 {
+			c.errorf(append(key, "read"), "不能为负数")
+		}
+		if rt.Write < 0 {
+			c.errorf(append(key, "write"), "不能为负数")
+		}
+		if rt.Idle < 0 {
+			c.errorf(append(key, "idle"), "不能为负数")
+		}
+		if rt.Idle > 0 && !rt.Stream {
+			c.warnf(append(key, "idle"), "只对 stream: true 的路由生效")
+		}
+	}
+}
+
+// checkIPLists 检查 IP/网段列表
+func (c *checker) checkIPLists(cfg *config.Config) {
+	type ipList struct {
+		key     []any
+		entries []string
+	}
+	lists := []ipList{
+		{[]any{"access", "trusted_proxies"}, cfg.Access.TrustedProxies},
+		{[]any{"access", "allow"}, cfg.Access.Allow},
+		{[]any{"access", "deny"}, cfg.Access.Deny},
+		{[]any{"brute_force", "whitelist"}, cfg.BruteForce.Whitelist},
+	}
+	for i, rule := range cfg.Access.Rules {
+		lists = append(lists,
+			ipList{[]any{"access", "rules", i, "allow"}, rule.Allow},
+			ipList{[]any{"access", "rules", i, "deny"}, rule.Deny},
+		)
+	}
+	for i, key := range cfg.Web.APIKeys {
+		lists = append(lists, ipList{[]any{"web", "api_keys", i, "allow"}, key.Allow})
+	}
+	for _, l := range lists {
+		for i, entry := range l.entries {
+			if _, err := ipacl.Parse([]string{entry}); err != nil {
+				c.errorf(at(l.key, i), "%v", err)
+			}
+		}
+	}
+}
+
+// checkAPIKeys 接口密钥不能为空或重复，角色须为 admin/operator/viewer
+func (c *checker) checkAPIKeys(cfg *config.Config) {
+	seen := make(map[string]int)
+	for i, key := range cfg.Web.APIKeys {
+		path := []any{"web", "api_keys", i}
+		switch {
+		case key.Key == "":
+			c.errorf(at(path, "key"), "密钥不能为空")
+		case key.Key == cfg.Web.APIToken:
+			c.errorf(at(path, "key"), "与 web.api_token 相同")
+		default:
+			if j, ok := seen[key.Key]; ok {
+				c.errorf(at(path, "key"), "与 web.api_keys[%d] 的密钥重复", j)
+			}
+			seen[key.Key] = i
+			if len(key.Key) < 16 {
+				c.warnf(at(path, "key"), "密钥过短，建议至少 16 个字符")
+			}
+		}
+		switch key.Role {
+		case "", "admin", "operator", "viewer":
+		default:
+			c.errorf(at(path, "role"), "未知的角色 %q，应为 admin、operator 或 viewer", key.Role)
+		}
+	}
+}
+
+// checkLanguage 检查界面默认语言
+func (c *checker) checkLanguage(cfg *config.Config) {
+	if cfg.Language != "" && !i18n.Supported(i18n.Normalize(cfg.Language)) {
+		c.warnf([]any{"language"}, "不支持的语言 %q，将按浏览器语言显示，可选 zh、en", cfg.Language)
+	}
+}
+
+// checkConnLimit 检查并发连接数限制
+func (c *checker) checkConnLimit(cfg *config.Config) {
+	cl := cfg.ConnLimit
+	if cl.MaxConns < 0 {
+		c.errorf([]any{"conn_limit", "max_conns"}, "不能为负数")
+	}
+	if cl.PerChannel < 0 {
+		c.errorf([]any{"conn_limit", "per_channel"}, "不能为负数")
+	}
+	for prefix, n := range cl.Channels {
+		if !strings.HasPrefix(prefix, "/") {
+			c.errorf([]any{"conn_limit", "channels", prefix}, "路径前缀应以 / 开头")
+		}
+		if n < 0 {
+			c.errorf([]any{"conn_limit", "channels", prefix}, "不能为负数")
+		}
+		if cl.MaxConns > 0 && n > cl.MaxConns {
+			c.warnf([]any{"conn_limit", "channels", prefix}, "频道上限 %d 大于全局上限 %d", n, cl.MaxConns)
+		}
+	}
+	switch cl.Action {
+	case "", "reject", "queue":
+	default:
+		c.errorf([]any{"conn_limit", "action"}, "未知的处理方式 %q，应为 reject 或 queue", cl.Action)
+	}
+}
+
+// checkLog 检查日志级别与输出格式
+func (c *checker) checkLog(cfg *config.Config) {
+	if _, err := logger.ParseLevel(cfg.Log.Level); err != nil {
+		c.errorf([]any{"log", "level"}, "%v", err)
+	}
+	for module, level := range cfg.Log.Modules {
+		if _, err := logger.ParseLevel(level); err != nil {
+			c.errorf([]any{"log", "modules", module}, "%v", err)
+		}
+	}
+	switch cfg.Log.Format {
+	case "", "text", "json":
+	default:
+		c.errorf([]any{"log", "format"}, "未知的输出格式 %q，应为 text 或 json", cfg.Log.Format)
+	}
+}
+
+// checkAccessLog 检查访问日志格式与排除的路径
+func (c *checker) checkAccessLog(cfg *config.Config) {
+	al := cfg.AccessLog
+	switch al.Format {
+	case "", "combined", "json":
+	default:
+		c.errorf([]any{"access_log", "format"}, "未知的输出格式 %q，应为 combined 或 json", al.Format)
+	}
+	for i, p := range al.Exclude {
+		if !strings.HasPrefix(p, "/") {
+			c.errorf([]any{"access_log", "exclude", i}, "路径前缀应以 / 开头")
+		}
+	}
+	if al.Enabled && al.File != "" && al.File == cfg.Log.File {
+		c.errorf([]any{"access_log", "file"}, "不能与 log.file 相同，两者分别轮转会互相覆盖")
+	}
+}
+
+// checkAlert 检查告警阈值与通知方式的必填项
+func (c *checker) checkAlert(cfg *config.Config) {
+	a := cfg.Alert
+	for _, r := range []struct {
+		key   string
+		value float64
+	}{{"memory_percent", a.Rules.MemoryPercent}, {"disk_percent", a.Rules.DiskPercent}} {
+		if r.value < 0 || r.value > 100 {
+			c.errorf([]any{"alert", "rules", r.key}, "应在 0 到 100 之间")
+		}
+	}
+	if a.Enabled && len(a.Sinks) == 0 {
+		c.warnf([]any{"alert", "sinks"}, "已启用告警但未配置通知方式，告警只记录在日志中")
+	}
+	for i, s := range a.Sinks {
+		path := func(key string) []any { return []any{"alert", "sinks", i, key} }
+		switch s.Type {
+		case "webhook":
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				c.errorf(path("url"), "webhook 地址应为 http(s) URL")
+			}
+		case "telegram":
+			if s.BotToken == "" {
+				c.errorf(path("bot_token"), "telegram 需要配置 bot_token")
+			}
+			if s.ChatID == "" {
+				c.errorf(path("chat_id"), "telegram 需要配置 chat_id")
+			}
+		case "email":
+			if _, _, err := net.SplitHostPort(s.SMTP); err != nil {
+				c.errorf(path("smtp"), "SMTP 服务器应为 host:port")
+			}
+			if len(s.To) == 0 {
+				c.errorf(path("to"), "email 需要配置收件人")
+			}
+			if s.From == "" && s.Username == "" {
+				c.errorf(path("from"), "email 需要配置 from 或 username")
+			}
+		default:
+			c.errorf(path("type"), "未知的通知方式 %q，应为 webhook、telegram 或 email", s.Type)
+		}
+	}
+}
+
+// checkTracing 检查链路追踪的导出地址与采样率
+func (c *checker) checkTracing(cfg *config.Config) {
+	t := cfg.Tracing
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		c.errorf([]any{"tracing", "sample_ratio"}, "应在 0 到 1 之间")
+	}
+	if t.Endpoint != "" {
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf([]any{"tracing", "endpoint"}, "应为 http(s) URL，如 http://127.0.0.1:4318/v1/traces")
+		} else if t.Enabled && !strings.HasSuffix(u.Path, "/v1/traces") {
+			c.warnf([]any{"tracing", "endpoint"}, "OTLP/HTTP 的 traces 地址通常以 /v1/traces 结尾")
+		}
+	}
+	for i, p := range t.Exclude {
+		if !strings.HasPrefix(p, "/") {
+			c.errorf([]any{"tracing", "exclude", i}, "路径前缀应以 / 开头")
+		}
+	}
+}
+
+// checkEPG 检查节目单源与频道
+func (c *checker) checkEPG(cfg *config.Config) {
+	e := cfg.EPG
+	if e.Enabled && len(e.Sources) == 0 {
+		c.errorf([]any{"epg", "sources"}, "启用节目单时至少需要一个 XMLTV 源")
+	}
+	for i, src := range e.Sources {
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			if u, err := url.Parse(src); err != nil || u.Host == "" {
+				c.errorf([]any{"epg", "sources", i}, "无效的地址")
+			}
+		} else if _, err := os.Stat(src); err != nil {
+			c.warnf([]any{"epg", "sources", i}, "本地文件不可读: %v", err)
+		}
+	}
+	ids := make(map[string]int)
+	for i, ch := range e.Channels {
+		if ch.ID == "" {
+			c.errorf([]any{"epg", "channels", i, "id"}, "频道 id 不能为空")
+			continue
+		}
+		if j, dup := ids[ch.ID]; dup {
+			c.errorf([]any{"epg", "channels", i, "id"}, "与 epg.channels[%d] 重复", j)
+		}
+		ids[ch.ID] = i
+	}
+}
+
+// checkLogo 检查频道图标来源
+func (c *checker) checkLogo(cfg *config.Config) {
+	l := cfg.Logo
+	if l.Enabled && len(l.Logos) == 0 && len(l.Playlists) == 0 && !cfg.EPG.Enabled {
+		c.warnf([]any{"logo"}, "未配置 logos、playlists 且未启用节目单，没有可用的图标来源")
+	}
+	for i, src := range l.Playlists {
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			c.warnf([]any{"logo", "playlists", i}, "本地文件不可读: %v", err)
+		}
+	}
+}
+
+// checkDLNA 检查 DLNA 的频道来源与访问地址
+func (c *checker) checkDLNA(cfg *config.Config) {
+	d := cfg.DLNA
+	if !d.Enabled {
+		return
+	}
+	if len(d.Playlists) == 0 {
+		c.warnf([]any{"dlna", "playlists"}, "未配置播放列表，客户端中没有可浏览的频道")
+	}
+	for i, src := range d.Playlists {
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			c.warnf([]any{"dlna", "playlists", i}, "本地文件不可读: %v", err)
+		}
+	}
+	if d.BaseURL != "" {
+		if u, err := url.Parse(d.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf([]any{"dlna", "base_url"}, "应为 http(s)://主机:端口 形式的地址")
+		}
+	} else if cfg.Server.HTTPPort == 0 && cfg.Server.TLS.HTTPSPort > 0 {
+		c.warnf([]any{"dlna", "base_url"}, "未配置 server.http_port，代理功能仅在 HTTPS 端口提供，需要配置 base_url")
+	}
+	if d.UUID != "" && len(strings.TrimPrefix(d.UUID, "uuid:")) != 36 {
+		c.warnf([]any{"dlna", "uuid"}, "UUID 格式应为 xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx")
+	}
+	for i, name := range d.Interfaces {
+		if _, err := net.InterfaceByName(name); err != nil {
+			c.warnf([]any{"dlna", "interfaces", i}, "网卡不存在: %v", err)
+		}
+	}
+}
+
+// checkCast 检查投屏设备访问 TVGate 的地址
+func (c *checker) checkCast(cfg *config.Config) {
+	ca := cfg.Cast
+	if !ca.Enabled {
+		return
+	}
+	if ca.BaseURL != "" {
+		if u, err := url.Parse(ca.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf([]any{"cast", "base_url"}, "应为 http(s)://主机:端口 形式的地址")
+		}
+	} else if cfg.Server.HTTPPort == 0 && cfg.Server.TLS.HTTPSPort > 0 {
+		c.warnf([]any{"cast", "base_url"}, "未配置 server.http_port，代理功能仅在 HTTPS 端口提供，需要配置 base_url")
+	}
+	if !cfg.Web.Enabled {
+		c.warnf([]any{"cast"}, "投屏在 Web 播放器中使用，需要启用 web")
+	}
+}
+
+// checkCluster 检查集群角色、共享密钥与源站地址
+func (c *checker) checkCluster(cfg *config.Config) {
+	cl := cfg.Cluster
+	switch cl.Role {
+	case "":
+		return
+	case config.ClusterOrigin, config.ClusterEdge:
+	default:
+		c.errorf([]any{"cluster", "role"}, "应为 origin 或 edge")
+		return
+	}
+	if cl.Secret == "" {
+		c.errorf([]any{"cluster", "secret"}, "不能为空，源站凭共享密钥放行边缘节点")
+	} else if len(cl.Secret) < 16 {
+		c.warnf([]any{"cluster", "secret"}, "长度不足 16 位，容易被猜测")
+	}
+	if cl.Role == config.ClusterOrigin {
+		if !cfg.GlobalAuth.TokensEnabled {
+			c.warnf([]any{"cluster"}, "未启用全局 token 认证，任何人都可以像边缘节点一样访问源站")
+		}
+		return
+	}
+	if len(cl.Origins) == 0 {
+		c.errorf([]any{"cluster", "origins"}, "边缘节点至少需要一个源站")
+	}
+	for i, o := range cl.Origins {
+		if u, err := url.Parse(o); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf([]any{"cluster", "origins", i}, "应为 http(s)://主机:端口 形式的地址")
+		}
+	}
+	switch strings.ToLower(cl.Protocol) {
+	case "", "h1", "h2", "h2c", "h3":
+	case "srt":
+		c.errorf([]any{"cluster", "protocol"}, "暂不支持 SRT，请使用 h2c、h2 或 h3")
+	default:
+		c.errorf([]any{"cluster", "protocol"}, "应为 h1、h2、h2c 或 h3")
+	}
+	if !strings.Contains(cl.URLTemplate, "{origin}") {
+		c.warnf([]any{"cluster", "url_template"}, "不包含 {origin}，所有源站将使用同一地址，健康检查切换不起作用")
+	}
+	for i, p := range cl.Paths {
+		if !strings.HasPrefix(p, "/") {
+			c.errorf([]any{"cluster", "paths", i}, "路径应以 / 开头")
+		}
+	}
+}
+
+// checkStore 检查共享存储的类型与地址
+func (c *checker) checkStore(cfg *config.Config) {
+	st := cfg.Store
+	switch strings.ToLower(st.Type) {
+	case "", "memory":
+		return
+	case "redis":
+	default:
+		c.errorf([]any{"store", "type"}, "应为 memory 或 redis")
+		return
+	}
+	if _, _, err := net.SplitHostPort(st.Addr); err != nil {
+		c.errorf([]any{"store", "addr"}, "应为 主机:端口 形式的地址")
+	}
+	if st.DB < 0 {
+		c.errorf([]any{"store", "db"}, "不能为负数")
+	}
+	if !cfg.GlobalAuth.TokensEnabled && !cfg.BruteForce.Enabled {
+		c.warnf([]any{"store"}, "未启用全局 token 认证与暴力破解防护，共享存储不起作用")
+	}
+}
+
+// checkRemux 检查 TS 重封装规则的匹配地址与 PID 映射
+func (c *checker) checkRemux(cfg *config.Config) {
+	for i, rule := range cfg.Remux {
+		key := []any{"remux", i}
+		if len(rule.Match) == 0 {
+			c.errorf(append(key, "match"), "至少需要一个组播地址，* 匹配所有")
+		}
+		targets := make(map[int]int)
+		for from, to := range rule.PIDMap {
+			if from < 0x10 || from > 0x1FFE || to < 0x10 || to > 0x1FFE {
+				c.errorf(append(key, "pid_map"), "PID %#x -> %#x 超出范围，应在 0x10-0x1FFE 之间", from, to)
+				continue
+			}
+			if prev, dup := targets[to]; dup {
+				c.errorf(append(key, "pid_map"), "PID %#x 与 %#x 映射到同一个 PID %#x", prev, from, to)
+			}
+			targets[to] = from
+		}
+		if rule.Program < 0 || rule.Program > 0xFFFF {
+			c.errorf(append(key, "program"), "节目号应在 0-65535 之间")
+		}
+		if len(rule.PIDMap) == 0 && !rule.StripCA && rule.ServiceName == "" && rule.Provider == "" {
+			c.warnf(key, "没有需要改写的内容，规则不起作用")
+		}
+	}
+}
+
+// checkIngest 检查推流点的名称、密钥与转发地址
+func (c *checker) checkIngest(cfg *config.Config) {
+	in := cfg.Ingest
+	if !in.Enabled {
+		return
+	}
+	if !strings.HasPrefix(in.Path, "/") {
+		c.errorf([]any{"ingest", "path"}, "路径应以 / 开头")
+	}
+	if in.RTMPListen != "" {
+		if _, _, err := net.SplitHostPort(in.RTMPListen); err != nil {
+			c.errorf([]any{"ingest", "rtmp_listen"}, "应为 地址:端口 形式，如 :1935")
+		}
+	}
+	if len(in.Streams) == 0 {
+		c.warnf([]any{"ingest", "streams"}, "没有配置推流点")
+	}
+	names := make(map[string]int)
+	for i, st := range in.Streams {
+		key := []any{"ingest", "streams", i}
+		if st.Name == "" || strings.ContainsAny(st.Name, "/?") {
+			c.errorf(append(key, "name"), "名称不能为空或包含 / ?")
+		} else if j, dup := names[st.Name]; dup {
+			c.errorf(append(key, "name"), "与 ingest.streams[%d] 重复", j)
+		} else {
+			names[st.Name] = i
+		}
+		if st.Token == "" {
+			c.errorf(append(key, "token"), "不能为空，推流需要密钥认证")
+		} else if len(st.Token) < 16 {
+			c.warnf(append(key, "token"), "长度不足 16 位，容易被猜测")
+		}
+		host, _, err := net.SplitHostPort(st.Addr)
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil {
+			c.errorf(append(key, "addr"), "应为 ip:端口 形式的地址")
+		} else if !ip.IsMulticast() && st.TTL > 0 {
+			c.warnf(append(key, "ttl"), "不是组播地址，ttl 不起作用")
+		}
+		if st.TTL < 0 || st.TTL > 255 {
+			c.errorf(append(key, "ttl"), "应在 0-255 之间")
+		}
+	}
+}
+
+// checkChannels 检查频道信息的名称、频道号与分组
+func (c *checker) checkChannels(cfg *config.Config) {
+	ch := cfg.Channels
+	groups := make(map[string]bool)
+	for i, g := range ch.Groups {
+		if groups[g] {
+			c.warnf([]any{"channels", "groups", i}, "分组 %s 重复", g)
+		}
+		groups[g] = true
+	}
+	names := make(map[string]int)
+	addrs := make(map[string]int)
+	for i, info := range ch.List {
+		key := []any{"channels", "list", i}
+		if info.Name == "" {
+			c.errorf(append(key, "name"), "频道名不能为空")
+		} else if j, dup := names[info.Name]; dup {
+			c.warnf(append(key, "name"), "与 channels.list[%d] 重复，只有前一个生效", j)
+		} else {
+			names[info.Name] = i
+		}
+		if info.Addr != "" {
+			if j, dup := addrs[info.Addr]; dup {
+				c.warnf(append(key, "addr"), "与 channels.list[%d] 重复，状态页只按前一个显示", j)
+			} else {
+				addrs[info.Addr] = i
+			}
+		}
+		if info.Number < 0 {
+			c.errorf(append(key, "number"), "频道号不能为负数")
+		}
+		addr := strings.ToLower(info.Addr)
+		multicast := addr != "" && (!strings.Contains(addr, "://") || strings.HasPrefix(addr, "rtp://") || strings.HasPrefix(addr, "udp://"))
+		if info.Resume < 0 {
+			c.errorf(append(key, "resume"), "不能为负数")
+		} else if info.Resume > 0 && !multicast {
+			c.warnf(append(key, "resume"), "断线续播只对组播频道生效，需要配置 rtp:// 或 udp:// 组播地址")
+		}
+		if info.Group != "" && len(ch.Groups) > 0 && !groups[info.Group] {
+			c.warnf(append(key, "group"), "分组 %s 不在 channels.groups 中，将排在列出的分组之后", info.Group)
+		}
+	}
+}
+
+// checkUpstreamProfiles 检查上游请求头模板的预设名称与匹配条件
+func (c *checker) checkUpstreamProfiles(cfg *config.Config) {
+	for i, p := range cfg.HTTP.Profiles {
+		key := []any{"http", "profiles", i}
+		if p.Preset != "" {
+			if _, ok := httpclient.Presets[strings.ToLower(p.Preset)]; !ok {
+				names := make([]string, 0, len(httpclient.Presets))
+				for name := range httpclient.Presets {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				c.errorf(append(key, "preset"), "未知的预设 %s，可选 %s", p.Preset, strings.Join(names, "、"))
+			}
+		}
+		if p.Preset == "" && p.UserAgent == "" && p.Referer == "" && p.Origin == "" && p.Cookies == "" && len(p.Headers) == 0 {
+			c.warnf(key, "没有设置任何请求头")
+		}
+		if len(p.Domains) == 0 && len(p.Paths) == 0 && i < len(cfg.HTTP.Profiles)-1 {
+			c.warnf(key, "匹配所有上游，之后的模板不会生效")
+		}
+		for j, prefix := range p.Paths {
+			if !strings.HasPrefix(prefix, "/") {
+				c.errorf(append(key, "paths", j), "路径前缀应以 / 开头")
+			}
+		}
+	}
+}
+
+// checkRefresh 检查需要刷新地址的频道的获取方式与地址模板
+func (c *checker) checkRefresh(cfg *config.Config) {
+	rf := cfg.Refresh
+	if !rf.Enabled {
+		return
+	}
+	if !strings.HasPrefix(rf.Path, "/") {
+		c.errorf([]any{"refresh", "path"}, "路径应以 / 开头")
+	}
+	if len(rf.Channels) == 0 {
+		c.warnf([]any{"refresh", "channels"}, "没有配置频道")
+	}
+	names := make(map[string]int)
+	for i, ch := range rf.Channels {
+		key := []any{"refresh", "channels", i}
+		if ch.Name == "" || strings.ContainsAny(ch.Name, "/?") {
+			c.errorf(append(key, "name"), "名称不能为空或包含 / ?")
+		} else if j, dup := names[ch.Name]; dup {
+			c.errorf(append(key, "name"), "与 refresh.channels[%d] 重复", j)
+		} else {
+			names[ch.Name] = i
+		}
+		switch {
+		case ch.API != "" && len(ch.Command) > 0:
+			c.errorf(key, "api 与 command 只能设置一个")
+		case len(ch.Command) > 0:
+			if ch.Command[0] == "" {
+				c.errorf(append(key, "command", 0), "命令不能为空")
+			}
+		case ch.API != "":
+			if !strings.HasPrefix(ch.API, "http://") && !strings.HasPrefix(ch.API, "https://") {
+				c.errorf(append(key, "api"), "应为 http(s) 地址")
+			}
+		default:
+			c.errorf(key, "需要设置 api 或 command")
+		}
+		if ch.URL != "" && !strings.Contains(ch.URL, "{value}") {
+			c.warnf(append(key, "url"), "没有 {value}，获取的值不会用到")
+		}
+		if ch.JSONPath != "" && (strings.HasPrefix(ch.JSONPath, ".") || strings.HasSuffix(ch.JSONPath, ".") || strings.Contains(ch.JSONPath, "..")) {
+			c.errorf(append(key, "json_path"), "应为 data.url 形式的路径")
+		}
+	}
+}
+
+// checkCookieJar 检查上游 Cookie 保存的文件与域名
+func (c *checker) checkCookieJar(cfg *config.Config) {
+	jar := cfg.HTTP.CookieJar
+	if !jar.Enabled {
+		return
+	}
+	for i, d := range jar.Domains {
+		if d == "" || strings.ContainsAny(d, "/:") {
+			c.errorf([]any{"http", "cookie_jar", "domains", i}, "应为域名，如 cdn.example.com")
+		}
+	}
+	if jar.File != "" && strings.HasSuffix(jar.File, "/") {
+		c.errorf([]any{"http", "cookie_jar", "file"}, "应为文件路径")
+	}
+}
+
+// checkCatchup 检查回看频道的来源
+func (c *checker) checkCatchup(cfg *config.Config) {
+	cu := cfg.Catchup
+	if cu.Timezone != "" {
+		if _, err := time.LoadLocation(cu.Timezone); err != nil {
+			c.errorf([]any{"catchup", "timezone"}, "无效的时区: %v", err)
+		}
+	}
+	names := make(map[string]int)
+	for i, ch := range cu.Channels {
+		key := []any{"catchup", "channels", i}
+		if ch.Name == "" || strings.Contains(ch.Name, "/") {
+			c.errorf(append(key, "name"), "频道名不能为空或包含 /")
+		} else if j, dup := names[ch.Name]; dup {
+			c.errorf(append(key, "name"), "与 catchup.channels[%d] 重复", j)
+		} else {
+			names[ch.Name] = i
+		}
+		switch {
+		case ch.Stream == "" && ch.Template == "":
+			c.errorf(key, "需要配置 stream 或 template")
+		case ch.Stream != "":
+			if cfg.Publisher == nil || cfg.Publisher.Path == "" {
+				c.errorf(append(key, "stream"), "使用本地缓存回看需要配置 publisher.path")
+			} else if !hlsPlayback(cfg.Publisher, ch.Stream) {
+				c.warnf(append(key, "stream"), "publisher 中的流 %s 未开启 HLS 回放（hls_enable_playback）", ch.Stream)
+			}
+		default:
+			if u, err := url.Parse(ch.Template); err != nil || u.Scheme == "" {
+				c.errorf(append(key, "template"), "应为完整的回看地址，如 rtsp://host/path?playseek=${(b)yyyyMMddHHmmss}-${(e)yyyyMMddHHmmss}")
+			}
+		}
+	}
+}
+
+// hlsPlayback publisher 中的流是否启用了 HLS 回放
+func hlsPlayback(pub *config.PublisherConfig, name string) bool {
+	item := pub.Streams[name]
+	if item == nil {
+		return false
+	}
+	for _, play := range item.Stream.LocalPlayUrls {
+		if play.Enabled && strings.EqualFold(play.Protocol, "hls") && play.HlsEnablePlayback {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMetrics 检查指标推送的格式与地址
+func (c *checker) checkMetrics(cfg *config.Config) {
+	m := cfg.Metrics
+	if m.Format != "influx" && m.Format != "graphite" {
+		c.errorf([]any{"metrics", "format"}, "不支持的格式 %q，可选 influx、graphite", m.Format)
+	}
+	if m.Address == "" {
+		if m.Enabled {
+			c.errorf([]any{"metrics", "address"}, "启用指标推送时必须设置推送地址")
+		}
+		return
+	}
+	u, err := url.Parse(m.Address)
+	if err != nil || u.Host == "" {
+		c.errorf([]any{"metrics", "address"}, "应为 http(s)://、tcp:// 或 udp:// 地址")
+		return
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if m.Format == "graphite" {
+			c.errorf([]any{"metrics", "address"}, "graphite 格式只支持 tcp:// 或 udp:// 地址")
+		}
+	case "tcp", "udp":
+		if u.Port() == "" {
+			c.errorf([]any{"metrics", "address"}, "缺少端口")
+		}
+	default:
+		c.errorf([]any{"metrics", "address"}, "不支持的协议 %q，可选 http、https、tcp、udp", u.Scheme)
+	}
+	if m.Interval < time.Second {
+		c.warnf([]any{"metrics", "interval"}, "推送间隔过短，建议不小于 1s")
+	}
+}
@@ -5,6 +5,9 @@ import (
 	_ "embed"
 	"errors"
 	"flag"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,6 +15,11 @@ import (
 var (
 	ConfigFilePath *string
 	VersionFlag    *bool
+	CheckFlag      *bool
+	SchemaFlag     *bool
+	DumpDefault    *bool
+	RemoteConfig   *string        // 远程配置地址
+	RemoteInterval *time.Duration // 远程配置轮询间隔
 	ServerCtx      context.Context
 	Cancel         context.CancelFunc
 	LogConfigMutex sync.Mutex
@@ -19,33 +27,59 @@ var (
 	CfgMu          sync.RWMutex
 	StartTime      time.Time // 程序启动时间
 	initOnce       sync.Once
+
+	// ReloadCh 请求立即重新加载配置文件（如通过接口应用配置后），由配置监控处理
+	ReloadCh = make(chan struct{}, 1)
 )
 
 func init() {
 	ConfigFilePath = flag.String("config", "config.yaml", "YAML配置文件路径")
 	VersionFlag = flag.Bool("version", false, "显示程序版本")
+	CheckFlag = flag.Bool("check", false, "校验配置文件后退出，有错误时退出码为 1")
+	SchemaFlag = flag.Bool("schema", false, "输出配置文件的 JSON Schema 后退出")
+	DumpDefault = flag.Bool("dump-default", false, "输出包含全部配置项、默认值和说明的配置后退出")
+	RemoteConfig = flag.String("remote-config", "", "远程配置地址：http(s)://...、etcd://host:2379/key、consul://host:8500/key，本地配置文件作为缓存")
+	RemoteInterval = flag.Duration("remote-interval", 30*time.Second, "远程配置轮询间隔")
 	ServerCtx, Cancel = context.WithCancel(context.Background())
 	StartTime = time.Now()
 }
 
+// RequestReload 请求立即重新加载配置文件，已有未处理的请求时忽略
+func RequestReload() {
+	select {
+	case ReloadCh <- struct{}{}:
+	default:
+	}
+}
+
 // Config 主配置结构
 type Config struct {
+	Includes []string `yaml:"includes"` // 引入的其它配置文件，支持通配符，相对路径相对于当前文件所在目录
+
 	Server struct {
-		Port                int           `yaml:"port"`                  // 旧端口
-		HTTPPort            int           `yaml:"http_port"`             // HTTP 可配置端口
-		CertFile            string        `yaml:"certfile"`              // TLS证书文件
-		KeyFile             string        `yaml:"keyfile"`               // TLS私钥文件
-		SSLProtocols        string        `yaml:"ssl_protocols"`         // 支持的TLS协议版本
-		SSLCiphers          string        `yaml:"ssl_ciphers"`           // 支持的TLS加密算法
-		SSLECDHCurve        string        `yaml:"ssl_ecdh_curve"`        // 支持的TLS曲线
-		TLS                 TLSConfig     `yaml:"tls"`                   // TLS 配置
-		HTTPToHTTPS         bool          `yaml:"http_to_https"`         // HTTP 跳转 HTTPS
-		MulticastIfaces     []string      `yaml:"multicast_ifaces"`      // 多播网卡
-		McastRejoinInterval time.Duration `yaml:"mcast_rejoin_interval"` // 多播重连间隔时间
-		FccType             string        `yaml:"fcc_type"`              // FCC类型: telecom, huawei
-		FccCacheSize        int           `yaml:"fcc_cache_size"`        // FCC缓存大小，默认16384
-		FccListenPortMin    int           `yaml:"fcc_listen_port_min"`   // FCC监听端口范围最小值
-		FccListenPortMax    int           `yaml:"fcc_listen_port_max"`   // FCC监听端口范围最大值
+		Port                int              `yaml:"port"`                  // 旧端口
+		HTTPPort            int              `yaml:"http_port"`             // HTTP 可配置端口
+		CertFile            string           `yaml:"certfile"`              // TLS证书文件
+		KeyFile             string           `yaml:"keyfile"`               // TLS私钥文件
+		SSLProtocols        string           `yaml:"ssl_protocols"`         // 支持的TLS协议版本
+		SSLCiphers          string           `yaml:"ssl_ciphers"`           // 支持的TLS加密算法
+		SSLECDHCurve        string           `yaml:"ssl_ecdh_curve"`        // 支持的TLS曲线
+		TLS                 TLSConfig        `yaml:"tls"`                   // TLS 配置
+		Listeners           []ListenerConfig `yaml:"listeners"`             // 额外的监听地址，可分别指定提供的功能
+		HTTPToHTTPS         bool             `yaml:"http_to_https"`         // HTTP 跳转 HTTPS
+		MulticastIfaces     []string         `yaml:"multicast_ifaces"`      // 多播网卡
+		McastRejoinInterval time.Duration    `yaml:"mcast_rejoin_interval"` // 多播重连间隔时间
+		McastIdleTimeout    time.Duration    `yaml:"mcast_idle_timeout"`    // 组播源超过该时长未收到数据时重新加入组播组，0 表示禁用
+		FccType             string           `yaml:"fcc_type"`              // FCC类型: telecom, huawei
+		FccCacheSize        int              `yaml:"fcc_cache_size"`        // FCC缓存大小，默认16384
+		FccListenPortMin    int              `yaml:"fcc_listen_port_min"`   // FCC监听端口范围最小值
+		FccListenPortMax    int              `yaml:"fcc_listen_port_max"`   // FCC监听端口范围最大值
+		CacheMemoryMB       int              `yaml:"cache_memory_mb"`       // 所有 Hub 缓存共用的内存上限(MB)，默认 512
+		WriteBatchKB        int              `yaml:"write_batch_kb"`        // 组播转 HTTP 时合并写出的大小(KB)，默认 64
+		WriteBatchLatency   time.Duration    `yaml:"write_batch_latency"`   // 未凑满一批时最长等待时间，默认 50ms
+		RawRTP              []string         `yaml:"raw_rtp"`               // 原样转发 RTP 包的组播地址 ip:port 或 ip，* 匹配所有
+		Timeouts            ServerTimeouts   `yaml:"timeouts"`              // 服务端超时
+		Limits              RequestLimits    `yaml:"limits"`                // 请求大小限制
 	} `yaml:"server"`
 
 	Log struct {
@@ -55,6 +89,17 @@ type Config struct {
 		MaxBackups int    `yaml:"maxbackups"` // 最大备份数量
 		MaxAgeDays int    `yaml:"maxage"`     // 最大保留天数
 		Compress   bool   `yaml:"compress"`   // 启用压缩
+		Level      string `yaml:"level"`      // 日志级别：debug、info、warn、error，默认 info
+		Format     string `yaml:"format"`     // 输出格式：text 或 json（每行一个 JSON 对象），默认 text
+		// 按模块覆盖日志级别，模块为包路径，如 stream: debug、config/remote: warn；子模块继承上级设置
+		Modules map[string]string `yaml:"modules"`
+		// 重复日志合并：窗口内与上一条完全相同的日志省略，同一格式（如不同组播源的读取错误）最多输出 burst 条，
+		// 窗口结束时输出一条"已省略 N 条"的汇总，避免组播源中断等持续出错时日志文件很快写满
+		Dedup struct {
+			Disabled bool          `yaml:"disabled"` // 关闭合并，每条日志都输出
+			Window   time.Duration `yaml:"window"`   // 合并窗口，默认 1m
+			Burst    int           `yaml:"burst"`    // 同一格式的日志每个窗口最多输出条数，默认 10
+		} `yaml:"dedup"`
 	} `yaml:"log"`
 
 	HTTP struct {
@@ -70,17 +115,28 @@ type Config struct {
 		MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数
 		MaxConnsPerHost     int  `yaml:"max_conns_per_host"`      // 每个主机的最大连接数
 		DisableKeepAlives   bool `yaml:"disable_keepalives"`      // 禁用keepalive
+
+		Protocols map[string]string `yaml:"protocols"` // 按域名指定上游协议 h1/h2/h2c/h3，匹配子域名
+
+		Profiles []UpstreamProfile `yaml:"profiles"` // 按域名/路径设置上游请求头，按顺序第一个匹配的生效
+
+		CookieJar CookieJarConfig `yaml:"cookie_jar"` // 保存上游下发的 Cookie
 	} `yaml:"http"`
 
 	Monitor struct {
 		Path string `yaml:"path"` // 监控路径
 	} `yaml:"monitor"`
 
+	Language string `yaml:"language"` // Web 界面、状态页与接口错误信息的语言 zh/en，为空按浏览器 Accept-Language 选择
+
 	Web struct {
-		Enabled  bool   `yaml:"enabled"`  // 启用Web管理界面
-		Username string `yaml:"username"` // Web管理用户名
-		Password string `yaml:"password"` // Web管理密码
-		Path     string `yaml:"path"`     // Web管理路径，默认为/web/
+		Enabled  bool      `yaml:"enabled"`   // 启用Web管理界面
+		Username string    `yaml:"username"`  // Web管理用户名
+		Password string    `yaml:"password"`  // Web管理密码
+		Path     string    `yaml:"path"`      // Web管理路径，默认为/web/
+		APIToken string    `yaml:"api_token"` // 接口访问令牌，外部系统通过 Authorization: Bearer 调用 token 管理接口
+		Users    []WebUser `yaml:"users"`     // 多账号，username/password 仍作为管理员账号保留
+		APIKeys  []APIKey  `yaml:"api_keys"`  // /api/v1 接口密钥，与 Web 登录账号分开管理
 	} `yaml:"web"`
 
 	// DNS配置
@@ -100,6 +156,681 @@ type Config struct {
 
 	// Publisher配置 - 修改为直接包含streams
 	Publisher *PublisherConfig `yaml:"publisher"` // 推流配置
+
+	HLS HLSConfig `yaml:"hls"` // HLS 代理配置
+
+	Middleware MiddlewareConfig `yaml:"middleware"` // 中间件链配置
+
+	Bandwidth BandwidthConfig `yaml:"bandwidth"` // 客户端带宽限制
+
+	HeaderRules []HeaderRule `yaml:"header_rules"` // 全局请求/响应头改写规则
+
+	Access AccessConfig `yaml:"access"` // 客户端 IP 访问控制
+
+	BruteForce BruteForceConfig `yaml:"brute_force"` // 暴力破解防护
+
+	Audit AuditConfig `yaml:"audit"` // token 使用审计日志
+
+	Quota QuotaConfig `yaml:"quota"` // token 流量配额
+
+	History HistoryConfig `yaml:"history"` // 配置版本历史
+
+	Stats StatsConfig `yaml:"stats"` // 频道与代理组历史统计
+
+	ConnLimit ConnLimitConfig `yaml:"conn_limit"` // 流媒体并发连接数限制
+
+	AccessLog AccessLogConfig `yaml:"access_log"` // 访问日志，与程序日志分开输出
+
+	Alert AlertConfig `yaml:"alert"` // 告警通知
+
+	Tracing TracingConfig `yaml:"tracing"` // 链路追踪
+
+	Health HealthConfig `yaml:"health"` // 容器编排健康检查接口
+
+	Debug DebugConfig `yaml:"debug"` // 运行时诊断接口
+
+	Metrics MetricsConfig `yaml:"metrics"` // 指标推送（InfluxDB / Graphite）
+
+	EPG EPGConfig `yaml:"epg"` // XMLTV 节目单
+
+	Catchup CatchupConfig `yaml:"catchup"` // 回看地址
+
+	Logo LogoConfig `yaml:"logo"` // 频道图标缓存
+
+	DLNA DLNAConfig `yaml:"dlna"` // DLNA/UPnP 媒体服务器
+
+	Cast CastConfig `yaml:"cast"` // 投屏到 Chromecast / AirPlay
+
+	Cluster ClusterConfig `yaml:"cluster"` // 源站/边缘节点集群
+
+	Store StoreConfig `yaml:"store"` // 多实例共享的 token、会话与封禁存储
+
+	Remux []RemuxRule `yaml:"remux"` // 组播频道的 TS 重封装规则
+
+	Probe ProbeConfig `yaml:"probe"` // 频道音视频参数探测
+
+	Ingest IngestConfig `yaml:"ingest"` // HTTP / RTMP 推流接入
+
+	Channels ChannelsConfig `yaml:"channels"` // 频道分组与频道信息
+
+	Refresh RefreshConfig `yaml:"refresh"` // 定期获取上游播放地址
+
+	ErrorPages ErrorPagesConfig `yaml:"error_pages"` // 错误响应页面
+
+	DVR DVRConfig `yaml:"dvr"` // 定时录制
+}
+
+// DVRConfig 定时录制：按 cron 表达式、指定时间或节目单中的节目录制频道，保存为 TS 文件；
+// 定时任务通过管理接口与 Web 页面增删改，保存在 file 中而不是配置文件
+type DVRConfig struct {
+	Enabled       bool          `yaml:"enabled"`        // 是否启用
+	Dir           string        `yaml:"dir"`            // 录制文件目录，默认为配置文件所在目录下的 recordings
+	File          string        `yaml:"file"`           // 定时任务与录制记录的保存文件，默认为配置文件所在目录下的 dvr.json
+	BaseURL       string        `yaml:"base_url"`       // 拉取频道使用的 TVGate 地址，默认 http://127.0.0.1:代理端口
+	MaxConcurrent int           `yaml:"max_concurrent"` // 同时进行的录制数上限，时间重叠超出上限时优先录制 priority 大的，默认 2
+	MinFreeMB     int64         `yaml:"min_free_mb"`    // 录制目录所在磁盘的最小剩余空间（MB），低于时不开始新录制并停止进行中的录制，默认 1024
+	PrePadding    time.Duration `yaml:"pre_padding"`    // 按节目录制时提前开始的时长，默认 1m
+	PostPadding   time.Duration `yaml:"post_padding"`   // 按节目录制时延后结束的时长，默认 2m
+	Retry         time.Duration `yaml:"retry"`          // 录制中断后重新连接的间隔，默认 5s
+	Post          DVRPostConfig `yaml:"post"`           // 录制完成后的处理
+	VOD           DVRVODConfig  `yaml:"vod"`            // 录制点播
+}
+
+// DVRVODConfig 录制点播：在代理端口上列出已结束的录制，支持 Range 请求，TS 录制可按 HLS 播放，token 校验与直播相同
+type DVRVODConfig struct {
+	Enabled         bool          `yaml:"enabled"`          // 是否启用
+	Path            string        `yaml:"path"`             // 访问路径，默认 /vod/
+	SegmentDuration time.Duration `yaml:"segment_duration"` // HLS 分片目标时长，默认 6s
+}
+
+// DVRPostConfig 录制完成后依次转封装、生成缩略图、移动文件、执行命令与调用 webhook，便于加入 Jellyfin/Plex 媒体库；
+// 只处理录到结束时间的录制，某一步失败时记录错误并继续后续步骤
+type DVRPostConfig struct {
+	FFmpeg      string            `yaml:"ffmpeg"`       // ffmpeg 程序，默认在 PATH 中查找
+	Remux       bool              `yaml:"remux"`        // 无损转封装为 MP4
+	KeepTS      bool              `yaml:"keep_ts"`      // 转封装后保留原 TS 文件
+	Thumbnail   bool              `yaml:"thumbnail"`    // 生成与录制文件同名的 .jpg 缩略图
+	ThumbnailAt time.Duration     `yaml:"thumbnail_at"` // 截取缩略图的时间点，默认 1m，录制短于该时长时取中间
+	MoveTo      string            `yaml:"move_to"`      // 移动到的目录，可用 {name} {channel} {title} {date}，如 /media/tv/{name}
+	Command     []string          `yaml:"command"`      // 外部命令及参数，可用 {file} {thumbnail} {name} {channel} {title} {id}，录制信息 JSON 从标准输入传入
+	Webhook     string            `yaml:"webhook"`      // POST 录制信息 JSON 的地址
+	Headers     map[string]string `yaml:"headers"`      // webhook 附加的请求头，如 Authorization
+	Timeout     time.Duration     `yaml:"timeout"`      // 每一步的超时，默认 30m
+}
+
+// ErrorPagesConfig 错误响应：请求 JSON 的客户端返回 {"error","status"}，浏览器返回 HTML 页面，
+// 播放器等其它客户端返回纯文本
+type ErrorPagesConfig struct {
+	Template string         `yaml:"template"` // HTML 错误页模板文件，可用 {{.Status}} {{.StatusText}} {{.Message}} {{.Path}}，为空使用内置页面
+	Pages    map[int]string `yaml:"pages"`    // 按状态码的模板文件，优先于 template，如 404: /etc/tvgate/404.html
+}
+
+// RefreshConfig 上游地址刷新：部分上游的播放地址带有会过期的鉴权参数，需要定期调用接口获取新地址。
+// 访问 路径前缀+频道名 时用获取的地址经 TVGate 代理播放，到期前在后台重新获取
+type RefreshConfig struct {
+	Enabled  bool             `yaml:"enabled"`  // 是否启用
+	Path     string           `yaml:"path"`     // 访问路径前缀，默认 /refresh/
+	Timeout  time.Duration    `yaml:"timeout"`  // 调用接口或外部命令的超时，默认 10s
+	Channels []RefreshChannel `yaml:"channels"` // 需要刷新地址的频道
+}
+
+// RefreshChannel 一个需要刷新地址的频道，api 与 command 二选一
+type RefreshChannel struct {
+	Name     string            `yaml:"name"`      // 频道名，访问地址为 路径前缀+频道名
+	URL      string            `yaml:"url"`       // 上游地址模板，{value} 替换为获取的值，为空时获取的值即为上游地址
+	API      string            `yaml:"api"`       // 获取地址的接口，可用 {name} {timestamp}
+	Method   string            `yaml:"method"`    // 接口请求方法，默认 GET，设置了 body 时默认 POST
+	Body     string            `yaml:"body"`      // 接口请求体，可用 {name} {timestamp}
+	Headers  map[string]string `yaml:"headers"`   // 接口请求头
+	Command  []string          `yaml:"command"`   // 外部命令及参数，以标准输出为结果，参数可用 {name} {timestamp}
+	JSONPath string            `yaml:"json_path"` // 从 JSON 结果中取值的路径，如 data.url 或 data.list.0.url，为空使用整个结果
+	TTL      time.Duration     `yaml:"ttl"`       // 获取的值的有效期，默认 10m，剩余不到 1/5 时在后台重新获取
+}
+
+// IngestConfig 推流接入：编码器以 HTTP POST/PUT 持续上传 TS 流或以 RTMP 发布，tvgate 转发到 UDP 地址，
+// 观看者与普通组播频道一样通过 /udp/地址 播放（共用 Hub），也可以同时发到网络上的组播
+type IngestConfig struct {
+	Enabled     bool           `yaml:"enabled"`      // 是否启用
+	Path        string         `yaml:"path"`         // 推流路径前缀，默认 /ingest/，推流地址为 前缀+名称
+	IdleTimeout time.Duration  `yaml:"idle_timeout"` // 超过该时长未收到数据时断开推流，默认 10s
+	RTMPListen  string         `yaml:"rtmp_listen"`  // RTMP 推流监听地址，如 :1935，留空不接受 RTMP
+	Streams     []IngestStream `yaml:"streams"`      // 推流点
+}
+
+// IngestStream 一个推流点
+type IngestStream struct {
+	Name  string `yaml:"name"`  // 名称，用于推流地址
+	Token string `yaml:"token"` // 推流密钥，请求头 Authorization: Bearer 密钥 或参数 token
+	Addr  string `yaml:"addr"`  // 转发到的 UDP 地址 ip:port，组播或单播
+	Iface string `yaml:"iface"` // 组播出口网卡，留空按路由选择
+	TTL   int    `yaml:"ttl"`   // 组播 TTL，默认 0 只在本机分发，大于 0 时同时发到网络
+}
+
+// UpstreamProfile 上游请求头模板：很多 IPTV CDN 只允许特定播放器的 User-Agent 或校验 Referer，
+// 由 HTTP 客户端在发往匹配的上游时设置，覆盖客户端原有的同名头
+type UpstreamProfile struct {
+	Domains   []string          `yaml:"domains"`    // 上游域名，匹配子域名，为空匹配全部
+	Paths     []string          `yaml:"paths"`      // 上游路径前缀（如某个频道），为空匹配全部
+	Preset    string            `yaml:"preset"`     // 预设的播放器请求头：aptv、tivimate、kodi、vlc、okhttp、exoplayer、chrome、safari
+	UserAgent string            `yaml:"user_agent"` // User-Agent，覆盖预设
+	Referer   string            `yaml:"referer"`    // Referer
+	Origin    string            `yaml:"origin"`     // Origin
+	Cookies   string            `yaml:"cookies"`    // 附加的 Cookie，如 a=1; b=2，与客户端的 Cookie 合并
+	Headers   map[string]string `yaml:"headers"`    // 其它请求头，覆盖同名头
+}
+
+// CookieJarConfig 上游 Cookie 保存：部分上游在鉴权后通过 Cookie 下发会话，之后的分片请求需要带上，
+// 开启后 HTTP 客户端按域名保存上游设置的 Cookie 并写入文件，重启后继续使用
+type CookieJarConfig struct {
+	Enabled bool     `yaml:"enabled"` // 是否启用
+	File    string   `yaml:"file"`    // 保存文件，默认为配置文件所在目录下的 upstream_cookies.json
+	Domains []string `yaml:"domains"` // 只保存这些上游域名的 Cookie，匹配子域名，为空保存全部
+}
+
+// ChannelsConfig 频道分组：按频道名或上游地址为频道指定分组、频道号、节目单 ID 与台标，
+// 用于导出的播放列表与 DLNA 目录（按分组顺序与频道号排列）以及状态页的分组视图
+type ChannelsConfig struct {
+	Groups []string      `yaml:"groups"` // 分组顺序，未列出的分组按出现顺序排在后面
+	List   []ChannelInfo `yaml:"list"`   // 频道信息
+}
+
+// ChannelInfo 一个频道的分组与展示信息
+type ChannelInfo struct {
+	Name   string        `yaml:"name"`   // 频道名，与播放列表中的频道名对应
+	Addr   string        `yaml:"addr"`   // 上游地址，如 rtp://239.3.1.1:8000 或 rtsp://…，状态页据此识别正在播放的频道
+	Group  string        `yaml:"group"`  // 分组，写入 group-title
+	Number int           `yaml:"number"` // 频道号，写入 tvg-chno，同一分组内按频道号排序
+	EPGID  string        `yaml:"epg_id"` // 节目单 ID，写入 tvg-id
+	Logo   string        `yaml:"logo"`   // 台标地址，写入 tvg-logo
+	Resume time.Duration `yaml:"resume"` // 断线续播窗口：携带同一 token 在该时间内重新连接时从断开处继续播放，未携带 token 的请求不续播，0 关闭；仅组播频道
+}
+
+// Lookup 按频道名查找频道信息，先精确匹配再忽略大小写，没有时返回 nil
+func (c *ChannelsConfig) Lookup(name string) *ChannelInfo {
+	for i := range c.List {
+		if c.List[i].Name == name {
+			return &c.List[i]
+		}
+	}
+	for i := range c.List {
+		if strings.EqualFold(c.List[i].Name, name) {
+			return &c.List[i]
+		}
+	}
+	return nil
+}
+
+// ByAddr 按上游地址查找频道信息，addr 为状态页中的频道标识（如 239.3.1.1:8000@eth0 或 RTSP 地址），
+// 多个组播地址以逗号分隔时任一地址匹配即可
+func (c *ChannelsConfig) ByAddr(addr string) *ChannelInfo {
+	keys := []string{channelAddrKey(addr)}
+	if !strings.Contains(addr, "://") {
+		keys = keys[:0]
+		for _, a := range strings.Split(addr, ",") {
+			keys = append(keys, channelAddrKey(a))
+		}
+	}
+	for i := range c.List {
+		if c.List[i].Addr != "" && slices.Contains(keys, channelAddrKey(c.List[i].Addr)) {
+			return &c.List[i]
+		}
+	}
+	return nil
+}
+
+// channelAddrKey 组播地址去掉 rtp:// udp:// 前缀、源地址与网卡（@ 前后不含端口的部分），其它地址去掉末尾的 /
+func channelAddrKey(addr string) string {
+	addr = strings.TrimSpace(addr)
+	for _, scheme := range []string{"rtp://", "udp://"} {
+		if len(addr) > len(scheme) && strings.EqualFold(addr[:len(scheme)], scheme) {
+			addr = addr[len(scheme):]
+			break
+		}
+	}
+	if strings.Contains(addr, "://") {
+		return strings.TrimSuffix(addr, "/")
+	}
+	if i := strings.IndexAny(addr, "/?"); i >= 0 {
+		addr = addr[:i]
+	}
+	for _, part := range strings.Split(addr, "@") {
+		if strings.Contains(part, ":") {
+			return part
+		}
+	}
+	return addr
+}
+
+// GroupRank 返回分组在 groups 中的位置，未列出时为 len(groups)
+func (c *ChannelsConfig) GroupRank(group string) int {
+	for i, g := range c.Groups {
+		if g == group {
+			return i
+		}
+	}
+	return len(c.Groups)
+}
+
+// ProbeConfig 频道探测：定期旁听正在播放的频道，解析 PAT/PMT 与 SPS、音频帧头，
+// 在状态接口与 DLNA 节目信息中报告编码、分辨率、帧率与声道，不需要 ffprobe
+type ProbeConfig struct {
+	Enabled  bool          `yaml:"enabled"`  // 是否启用
+	Interval time.Duration `yaml:"interval"` // 同一频道重新探测的间隔，默认 10m
+	Timeout  time.Duration `yaml:"timeout"`  // 单次探测的最长时间，默认 10s
+}
+
+// RemuxRule TS 重封装规则：按组播地址匹配，在 Hub 中改写后再分发给所有客户端，
+// 用于对 PID 布局有要求的下游设备。多条规则匹配同一地址时使用第一条
+type RemuxRule struct {
+	Match       []string    `yaml:"match"`        // 组播地址 ip:port 或 ip，* 匹配所有
+	PIDMap      map[int]int `yaml:"pid_map"`      // PID 重映射：原 PID -> 新 PID，PAT 与 PMT 中的引用同步改写
+	StripCA     bool        `yaml:"strip_ca"`     // 删除 PMT 中的 CA 描述符，并清除 SDT 的 free_CA_mode
+	Program     int         `yaml:"program"`      // service_name / provider 只改写该节目号，0 表示所有节目
+	ServiceName string      `yaml:"service_name"` // 改写 SDT 中的节目名称
+	Provider    string      `yaml:"provider"`     // 改写 SDT 中的提供商名称
+}
+
+// MatchRemux 返回匹配组播地址的重封装规则，没有匹配时返回 nil
+func (c *Config) MatchRemux(addr string) *RemuxRule {
+	for i := range c.Remux {
+		if matchAddr(c.Remux[i].Match, addr) {
+			return &c.Remux[i]
+		}
+	}
+	return nil
+}
+
+// IsRawRTP 组播地址是否配置为原样转发 RTP 包
+func (c *Config) IsRawRTP(addr string) bool {
+	return matchAddr(c.Server.RawRTP, addr)
+}
+
+// matchAddr 组播地址 ip:port 是否匹配列表中的 ip:port、ip 或 *
+func matchAddr(list []string, addr string) bool {
+	host, _, _ := net.SplitHostPort(addr)
+	for _, m := range list {
+		if m == "*" || m == addr || (host != "" && m == host) {
+			return true
+		}
+	}
+	return false
+}
+
+// StoreConfig 共享存储：多个实例部署在负载均衡之后时，签发的 token、每个 token/IP 的会话数与封禁记录
+// 保存在共享存储中，任一实例签发或封禁后其它实例在 sync_interval 内生效
+type StoreConfig struct {
+	Type         string        `yaml:"type"`          // 留空只在本实例内保存；redis
+	Addr         string        `yaml:"addr"`          // Redis 地址，默认 127.0.0.1:6379
+	Username     string        `yaml:"username"`      // Redis 6 ACL 用户名，可选
+	Password     string        `yaml:"password"`      // Redis 密码，可选
+	DB           int           `yaml:"db"`            // Redis 数据库编号
+	Prefix       string        `yaml:"prefix"`        // 键名前缀，默认 tvgate:，多套部署共用 Redis 时用于区分
+	PoolSize     int           `yaml:"pool_size"`     // 最大空闲连接数，默认 8
+	Timeout      time.Duration `yaml:"timeout"`       // 连接与单条命令的超时，默认 3s
+	SyncInterval time.Duration `yaml:"sync_interval"` // 与共享存储同步的间隔，默认 5s
+}
+
+// 集群角色
+const (
+	ClusterOrigin = "origin"
+	ClusterEdge   = "edge"
+)
+
+// ClusterConfig 集群模式：边缘节点把同一频道的所有观看者合并为一条到源站的内部连接，在本地分发，
+// 源站凭共享密钥放行边缘节点的回源请求，边缘节点不认识的 token 交给源站校验，便于多地部署
+type ClusterConfig struct {
+	Role           string        `yaml:"role"`            // origin 源站、edge 边缘节点，留空不启用
+	Secret         string        `yaml:"secret"`          // 集群共享密钥，源站与边缘节点必须相同
+	Path           string        `yaml:"path"`            // 源站上供边缘节点健康检查与校验 token 的接口前缀，默认 /cluster/
+	Origins        []string      `yaml:"origins"`         // 边缘节点：源站地址，按顺序优先，如 http://origin.example.com:8888
+	Protocol       string        `yaml:"protocol"`        // 回源协议 h2c/h2/h3/h1，默认 h2c，HTTP/2 与 HTTP/3 下所有频道复用一条连接
+	URLTemplate    string        `yaml:"url_template"`    // 回源地址模板，默认 {origin}{path}，可用 {origin} {path} {addr} {query}
+	Paths          []string      `yaml:"paths"`           // 边缘节点经源站拉取的路径前缀，默认 /rtp/ /udp/ /rtsp/
+	HealthInterval time.Duration `yaml:"health_interval"` // 源站健康检查间隔，默认 10s
+	Timeout        time.Duration `yaml:"timeout"`         // 连接源站与等待响应头的超时，默认 10s
+	Fallback       bool          `yaml:"fallback"`        // 所有源站都不可用时由边缘节点自己回源
+	TokenCache     time.Duration `yaml:"token_cache"`     // 边缘节点缓存源站 token 校验结果的时长，默认 1m
+}
+
+// CastConfig 投屏：在 Web 播放器中发现局域网内的 Chromecast / AirPlay 设备，把频道地址投到设备上播放，
+// 启用全局 token 认证时为每次投屏签发只能访问该频道的临时 token，停止投屏时吊销
+type CastConfig struct {
+	Enabled   bool          `yaml:"enabled"`   // 是否启用
+	BaseURL   string        `yaml:"base_url"`  // 设备访问 TVGate 的地址，如 http://192.168.1.2:8888，默认使用本机地址与代理端口
+	TokenTTL  time.Duration `yaml:"token_ttl"` // 投屏 token 的有效期，默认 12h
+	Discovery time.Duration `yaml:"discovery"` // 发现设备的等待时间，默认 3s
+	Timeout   time.Duration `yaml:"timeout"`   // 连接设备与发送指令的超时，默认 10s
+}
+
+// DLNAConfig DLNA/UPnP 媒体服务器：通过 SSDP 在局域网内广播，并提供最小的 ContentDirectory 服务，
+// 智能电视等 DLNA 客户端无需安装播放器即可发现 TVGate 并按分组浏览播放列表中的频道
+type DLNAConfig struct {
+	Enabled      bool          `yaml:"enabled"`       // 是否启用
+	FriendlyName string        `yaml:"friendly_name"` // 客户端中显示的服务器名称，默认 TVGate
+	UUID         string        `yaml:"uuid"`          // 设备 UUID，默认按主机名生成，保持不变以免客户端出现重复的设备
+	Path         string        `yaml:"path"`          // 设备描述与控制接口的路径前缀，默认 /dlna
+	BaseURL      string        `yaml:"base_url"`      // 客户端访问 TVGate 的地址，如 http://192.168.1.2:8888，默认使用本机地址与代理端口
+	Playlists    []string      `yaml:"playlists"`     // 频道来源，m3u 或 txt 播放列表（http(s) 地址或本地文件），地址改写为经 TVGate 代理访问
+	Token        string        `yaml:"token"`         // 附加到频道地址的 token，启用访问认证时需要配置
+	Interfaces   []string      `yaml:"interfaces"`    // 收发 SSDP 的网卡，默认所有支持组播的网卡
+	Refresh      time.Duration `yaml:"refresh"`       // 重新读取播放列表的间隔，默认 1h
+	Timeout      time.Duration `yaml:"timeout"`       // 读取播放列表超时，默认 30s
+}
+
+// LogoConfig 频道图标缓存：在 /logo/<频道>.png 提供缩放并缓存后的频道图标，
+// 图标地址来自 logos、播放列表中的 tvg-logo 或节目单中的 icon，源站慢或无法访问时不影响播放器界面
+type LogoConfig struct {
+	Enabled   bool              `yaml:"enabled"`   // 是否启用
+	Path      string            `yaml:"path"`      // 访问路径前缀，默认 /logo
+	Logos     map[string]string `yaml:"logos"`     // 频道名到图标地址，优先于播放列表和节目单；只有这里的地址可以是本地文件或内网地址
+	Playlists []string          `yaml:"playlists"` // 读取 tvg-logo 的 m3u 播放列表（http(s) 地址或本地文件），按 tvg-id、tvg-name 和频道名匹配
+	Size      int               `yaml:"size"`      // 缩放后的最大边长（像素），默认 256，小于该尺寸的图标不放大
+	Dir       string            `yaml:"dir"`       // 缓存目录，默认为配置文件所在目录下的 logo_cache
+	TTL       time.Duration     `yaml:"ttl"`       // 缓存有效期，过期后重新拉取，拉取失败时继续使用旧缓存，默认 168h
+	Timeout   time.Duration     `yaml:"timeout"`   // 拉取超时，默认 10s
+}
+
+// CatchupConfig 回看：支持 /timeshift/<频道>/<开始时间>-<时长> 等 Flussonic / Xtream 风格的回看地址，
+// 映射到 publisher 本地缓存的 HLS 分片或频道配置的上游回看地址，带回看界面的播放器可直接使用
+type CatchupConfig struct {
+	Enabled     bool             `yaml:"enabled"`      // 是否启用
+	Path        string           `yaml:"path"`         // 访问路径前缀，默认 /timeshift
+	Timezone    string           `yaml:"timezone"`     // 解析和生成 20060102150405 格式时间使用的时区，默认 Asia/Shanghai
+	MaxDuration time.Duration    `yaml:"max_duration"` // 单次回看的最大时长，默认 24h
+	Channels    []CatchupChannel `yaml:"channels"`     // 支持回看的频道
+}
+
+// CatchupChannel 单个频道的回看来源，stream 与 template 二选一，同时配置时优先使用本地缓存
+type CatchupChannel struct {
+	Name     string `yaml:"name"`     // 回看地址中的频道名
+	Stream   string `yaml:"stream"`   // publisher 中开启了 hls_enable_playback 的流名称，使用本地缓存的分片回看
+	Template string `yaml:"template"` // 上游回看地址模板，支持 ${start} ${end} ${duration} ${(b)yyyyMMddHHmmss} ${(e)yyyyMMddHHmmss} 等占位符
+	Direct   bool   `yaml:"direct"`   // 直接重定向到上游地址，默认经 TVGate 代理访问
+}
+
+// EPGConfig XMLTV 节目单：定时拉取一个或多个 XMLTV 源，合并后按配置的频道过滤并改写 tvg-id，
+// 缓存后在 path 提供给播放器（path 加 .gz 为压缩版本）
+type EPGConfig struct {
+	Enabled  bool              `yaml:"enabled"`  // 是否启用
+	Path     string            `yaml:"path"`     // 访问路径，默认 /xmltv.xml
+	Sources  []string          `yaml:"sources"`  // XMLTV 源：http(s) 地址或本地文件，支持 gzip 压缩，同一频道以靠前的源为准
+	Channels []EPGChannel      `yaml:"channels"` // 输出的频道，留空输出全部频道
+	Headers  map[string]string `yaml:"headers"`  // 拉取时附加的请求头
+	Refresh  time.Duration     `yaml:"refresh"`  // 刷新间隔，默认 6h
+	Timeout  time.Duration     `yaml:"timeout"`  // 单个源的拉取超时，默认 60s
+	File     string            `yaml:"file"`     // 缓存文件，重启后先使用缓存，默认为配置文件所在目录下的 epg.xml.gz
+}
+
+// EPGChannel 节目单中的一个频道
+type EPGChannel struct {
+	ID    string   `yaml:"id"`    // 输出的频道 id，与播放列表中的 tvg-id 一致
+	Name  string   `yaml:"name"`  // 显示名称，留空沿用源中的名称
+	Match []string `yaml:"match"` // 匹配源中的频道 id 或显示名称（不区分大小写），留空按 id 匹配
+}
+
+// MetricsConfig 指标推送：定时将系统、频道与代理组指标以 InfluxDB 行协议或 Graphite 文本协议推送出去，
+// 适用于不使用 Prometheus 拉取的监控系统
+type MetricsConfig struct {
+	Enabled  bool              `yaml:"enabled"`  // 是否启用
+	Format   string            `yaml:"format"`   // influx 或 graphite，默认 influx
+	Address  string            `yaml:"address"`  // 推送地址：http(s)://（InfluxDB 写入接口）、tcp://host:port 或 udp://host:port
+	Headers  map[string]string `yaml:"headers"`  // HTTP 推送时附加的请求头，如 InfluxDB 2.x 的 Authorization: Token xxx
+	Interval time.Duration     `yaml:"interval"` // 推送间隔，默认 10s
+	Prefix   string            `yaml:"prefix"`   // 指标名前缀，默认 tvgate
+	Tags     map[string]string `yaml:"tags"`     // 附加到所有指标的标签，如 host、region
+	Timeout  time.Duration     `yaml:"timeout"`  // 推送超时，默认 5s
+}
+
+// DebugConfig 运行时诊断：在 Web 管理路径下提供 pprof、expvar 与 goroutine 堆栈，仅管理员可访问，
+// 用于排查长时间运行后的内存、CPU 问题，不需要重新编译
+type DebugConfig struct {
+	Enabled              bool `yaml:"enabled"`                // 是否开启诊断接口
+	BlockProfileRate     int  `yaml:"block_profile_rate"`     // 阻塞采样率（纳秒），>0 时 block 分析有数据，会增加开销，0 关闭
+	MutexProfileFraction int  `yaml:"mutex_profile_fraction"` // 锁竞争采样比例 1/n，>0 时 mutex 分析有数据，0 关闭
+}
+
+// HealthConfig 供 Kubernetes 等容器编排探测的存活与就绪接口，所有端口均可访问，不受 IP 访问控制限制，
+// 与给人看的 /status 状态页分开
+type HealthConfig struct {
+	Enabled        bool          `yaml:"enabled"`          // 是否启用
+	LivenessPath   string        `yaml:"liveness_path"`    // 存活检查路径，默认 /healthz，主流程卡住（如死锁）时返回 503
+	ReadinessPath  string        `yaml:"readiness_path"`   // 就绪检查路径，默认 /readyz，启动完成、全部端口已监听且 Hub 数满足要求时返回 200
+	MinHealthyHubs int           `yaml:"min_healthy_hubs"` // 就绪需要的最少健康 Hub 数（hub_idle 内收到过数据），0 不检查
+	HubIdle        time.Duration `yaml:"hub_idle"`         // Hub 超过该时长没有收到数据视为不健康，默认 10s
+}
+
+// TracingConfig 链路追踪：记录请求在 handler、代理组选择、上游请求、组播 Hub 各阶段的耗时，
+// 以 OTLP/HTTP（JSON）格式导出到 OpenTelemetry Collector、Jaeger、Tempo 等
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`      // 是否启用
+	Endpoint    string            `yaml:"endpoint"`     // OTLP/HTTP 导出地址，默认 http://127.0.0.1:4318/v1/traces
+	Headers     map[string]string `yaml:"headers"`      // 导出时附加的请求头，如认证信息
+	ServiceName string            `yaml:"service_name"` // 服务名，默认 tvgate，多实例时可区分
+	SampleRatio float64           `yaml:"sample_ratio"` // 新链路的采样率 0~1，默认 1；请求带 traceparent 时按调用方的采样标记
+	Propagate   bool              `yaml:"propagate"`    // 向上游请求附加 traceparent 头，上游也接入追踪时可串起完整链路
+	Exclude     []string          `yaml:"exclude"`      // 不追踪的路径前缀，如 /web/
+	Timeout     time.Duration     `yaml:"timeout"`      // 导出超时，默认 10s
+}
+
+// AlertConfig 告警：定时检查频道、代理组与系统资源，异常持续超过 for 后通知，恢复时发送恢复通知
+type AlertConfig struct {
+	Enabled  bool          `yaml:"enabled"`  // 是否启用
+	Interval time.Duration `yaml:"interval"` // 检查间隔，默认 30s
+	For      time.Duration `yaml:"for"`      // 异常持续多久才告警，避免短暂波动，默认 1m
+	Repeat   time.Duration `yaml:"repeat"`   // 告警未恢复时重复通知的间隔，0 只通知一次
+	Rules    AlertRules    `yaml:"rules"`    // 检查项
+	Sinks    []AlertSink   `yaml:"sinks"`    // 通知方式，可配置多个
+}
+
+// AlertRules 告警检查项
+type AlertRules struct {
+	ChannelStall   bool    `yaml:"channel_stall"`    // 有客户端观看的频道停止收到数据（如组播源中断）
+	ProxyGroupDown bool    `yaml:"proxy_group_down"` // 代理组内全部代理不可用
+	MemoryPercent  float64 `yaml:"memory_percent"`   // 系统内存使用率超过该百分比，0 不检查
+	DiskPercent    float64 `yaml:"disk_percent"`     // 任一磁盘分区使用率超过该百分比，0 不检查
+}
+
+// AlertSink 告警通知方式
+type AlertSink struct {
+	Name string `yaml:"name"` // 名称，仅用于日志
+	Type string `yaml:"type"` // webhook、telegram 或 email
+
+	// webhook：POST JSON {"status","rule","target","message","host","since","time","duration"}
+	URL     string            `yaml:"url"`     // webhook 地址
+	Headers map[string]string `yaml:"headers"` // 附加的请求头，如 Authorization
+
+	// telegram
+	BotToken string `yaml:"bot_token"` // Bot token
+	ChatID   string `yaml:"chat_id"`   // 接收消息的会话 ID
+	APIURL   string `yaml:"api_url"`   // Bot API 地址，默认 https://api.telegram.org，可改为反向代理地址
+
+	// email
+	SMTP     string   `yaml:"smtp"`     // SMTP 服务器 host:port，465 端口使用 TLS，其他端口支持时使用 STARTTLS
+	Username string   `yaml:"username"` // SMTP 用户名，为空不认证
+	Password string   `yaml:"password"` // SMTP 密码
+	From     string   `yaml:"from"`     // 发件人，默认为 username
+	To       []string `yaml:"to"`       // 收件人
+}
+
+// AccessLogConfig 访问日志：每个 HTTP 请求结束时记录一行，播放会话在断开时记录，包含时长和发送的字节数
+type AccessLogConfig struct {
+	Enabled    bool     `yaml:"enabled"`    // 是否启用
+	File       string   `yaml:"file"`       // 日志文件，为空输出到标准输出
+	Format     string   `yaml:"format"`     // combined（Apache combined 格式，末尾附加耗时秒数）或 json，默认 combined
+	MaxSizeMB  int      `yaml:"maxsize"`    // 单个文件最大大小(MB)，默认 100
+	MaxBackups int      `yaml:"maxbackups"` // 最多保留的轮转文件数，0 不限制
+	MaxAgeDays int      `yaml:"maxage"`     // 轮转文件最多保留天数，0 不限制
+	Compress   bool     `yaml:"compress"`   // 压缩轮转文件
+	Exclude    []string `yaml:"exclude"`    // 不记录的路径前缀，如 /status
+}
+
+// ConnLimitConfig 流媒体并发连接数限制，避免小型设备被过多连接拖垮
+type ConnLimitConfig struct {
+	MaxConns     int            `yaml:"max_conns"`     // 全局最大并发连接数，0 不限制
+	PerChannel   int            `yaml:"per_channel"`   // 单个频道默认最大连接数，0 不限制；HLS/DASH 的播放列表与分片按所在目录计为一个频道
+	Channels     map[string]int `yaml:"channels"`      // 按请求路径前缀设置单个频道的上限，最长前缀优先，覆盖 per_channel
+	Action       string         `yaml:"action"`        // 超出限制时的处理：reject 返回 503（默认），queue 排队等待空位
+	QueueTimeout time.Duration  `yaml:"queue_timeout"` // 排队等待的最长时间，超时返回 503，默认 10s
+}
+
+// StatsConfig 频道与代理组历史统计：定时采样观看人数、码率和错误数，保存在内置数据库中，用于查看趋势
+type StatsConfig struct {
+	Enabled   bool          `yaml:"enabled"`   // 是否启用
+	File      string        `yaml:"file"`      // 数据库文件，默认为配置文件所在目录下的 stats.db
+	Interval  time.Duration `yaml:"interval"`  // 采样间隔，默认 1m
+	Retention time.Duration `yaml:"retention"` // 保留时长，默认 168h
+}
+
+// HistoryConfig 配置版本历史：每次成功加载的配置保存一份副本，可查看差异并回滚
+type HistoryConfig struct {
+	Dir         string `yaml:"dir"`          // 保存目录，默认为配置文件所在目录下的 config_history
+	MaxVersions int    `yaml:"max_versions"` // 最多保留版本数，默认 50，-1 表示不记录
+}
+
+// QuotaConfig token 流量配额，用完后拒绝该 token 的新请求并断开正在播放的连接，跨日/跨月自动重置
+type QuotaConfig struct {
+	Enabled   bool                  `yaml:"enabled"`    // 是否启用
+	DailyMB   int64                 `yaml:"daily_mb"`   // 每个 token 默认每日配额(MB)，0 不限制
+	MonthlyMB int64                 `yaml:"monthly_mb"` // 每个 token 默认每月配额(MB)，0 不限制
+	Tokens    map[string]TokenQuota `yaml:"tokens"`     // 按 token 单独配置，覆盖默认配额
+}
+
+// TokenQuota 单个 token 的流量配额
+type TokenQuota struct {
+	DailyMB   int64 `yaml:"daily_mb"`   // 每日配额(MB)，0 不限制
+	MonthlyMB int64 `yaml:"monthly_mb"` // 每月配额(MB)，0 不限制
+}
+
+// AuditConfig token 使用审计日志，记录携带 token 的连接开始/结束事件
+type AuditConfig struct {
+	Enabled   bool          `yaml:"enabled"`    // 是否启用
+	File      string        `yaml:"file"`       // 持久化文件（JSON Lines），为空只保存在内存中
+	MaxEvents int           `yaml:"max_events"` // 最多保留事件数，默认 100000
+	Retention time.Duration `yaml:"retention"`  // 保留时长，默认 168h
+}
+
+// BruteForceConfig 暴力破解防护：按 IP 统计 Web 登录失败与无效 token 请求，
+// 超过阈值后临时封禁，同一 IP 再次被封禁时封禁时长翻倍
+type BruteForceConfig struct {
+	Enabled        bool          `yaml:"enabled"`          // 是否启用
+	MaxFailures    int           `yaml:"max_failures"`     // 时间窗口内允许的失败次数，默认 5
+	Window         time.Duration `yaml:"window"`           // 失败计数时间窗口，默认 10m
+	BanDuration    time.Duration `yaml:"ban_duration"`     // 首次封禁时长，默认 1m
+	MaxBanDuration time.Duration `yaml:"max_ban_duration"` // 最长封禁时长，默认 24h；解封后超过该时长未再被封禁则重新从 ban_duration 计
+	Whitelist      []string      `yaml:"whitelist"`        // 不受限制的 IP/网段
+}
+
+// AccessConfig 客户端 IP 访问控制，在认证之前执行。
+// 先检查全局 allow/deny，再检查匹配的规则（host 匹配且路径前缀最长者）
+type AccessConfig struct {
+	TrustedProxies []string     `yaml:"trusted_proxies"` // 可信反向代理 IP/网段，仅信任来自这些地址的 X-Forwarded-For / X-Real-IP；为空时访问控制与封禁只使用直连地址
+	Allow          []string     `yaml:"allow"`           // 允许的 IP/网段，为空不限制
+	Deny           []string     `yaml:"deny"`            // 拒绝的 IP/网段，优先于 allow
+	Rules          []AccessRule `yaml:"rules"`           // 按域名/路径前缀的规则
+}
+
+// AccessRule 按域名/路径前缀的 IP 访问规则
+type AccessRule struct {
+	Host       string   `yaml:"host,omitempty"`        // 请求域名，为空匹配全部
+	PathPrefix string   `yaml:"path_prefix,omitempty"` // 路径前缀，为空匹配全部
+	Allow      []string `yaml:"allow,omitempty"`       // 允许的 IP/网段
+	Deny       []string `yaml:"deny,omitempty"`        // 拒绝的 IP/网段，优先于 allow
+}
+
+// WebUser Web 管理账号
+type WebUser struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Role     string `yaml:"role"` // admin 全部权限；operator 可修改除认证外的配置；viewer 只读，默认 viewer
+}
+
+// APIKey 接口密钥，请求时通过 X-API-Key 或 Authorization: Bearer 携带
+type APIKey struct {
+	Name  string   `yaml:"name"`  // 名称，用于日志和配置版本历史的来源
+	Key   string   `yaml:"key"`   // 密钥
+	Role  string   `yaml:"role"`  // 权限，同 users 的角色，默认 viewer
+	Allow []string `yaml:"allow"` // 允许使用该密钥的 IP/CIDR，为空不限制
+}
+
+// BandwidthConfig 客户端下行带宽限制，单位 kbit/s，0 表示不限速。
+// 优先级：token > 频道 > 全局
+type BandwidthConfig struct {
+	PerClientKbps int            `yaml:"per_client_kbps"` // 每个客户端默认限速
+	Tokens        map[string]int `yaml:"tokens"`          // 按 token 限速
+	Channels      map[string]int `yaml:"channels"`        // 按请求路径前缀限速，如 /udp/239.1.1.1:5000，最长前缀优先
+}
+
+// HeaderRule 请求/响应头改写规则，值中可使用 ${client_ip} ${token} ${channel} ${host} ${path}
+type HeaderRule struct {
+	Path     string           `yaml:"path,omitempty"`     // 请求路径前缀，为空匹配全部
+	Request  HeaderOperations `yaml:"request,omitempty"`  // 发往上游的请求头
+	Response HeaderOperations `yaml:"response,omitempty"` // 返回客户端的响应头
+}
+
+// HeaderOperations 头部操作，按 删除 -> 设置 -> 追加 的顺序执行
+type HeaderOperations struct {
+	Set    map[string]string `yaml:"set,omitempty"`    // 设置（覆盖同名头）
+	Append map[string]string `yaml:"append,omitempty"` // 追加（保留同名头）
+	Remove []string          `yaml:"remove,omitempty"` // 删除
+}
+
+// MiddlewareConfig 中间件链配置
+type MiddlewareConfig struct {
+	Global    []string             `yaml:"global"`     // 对所有路径生效的中间件，在 routes 的链之前执行
+	Routes    []MiddlewareRoute    `yaml:"routes"`     // 按路径前缀配置的中间件链，最长前缀优先
+	RateLimit RateLimitConfig      `yaml:"rate_limit"` // rate_limit 中间件参数
+	CORS      MiddlewareCORSConfig `yaml:"cors"`       // cors 中间件参数
+}
+
+// MiddlewareRoute 路径前缀对应的中间件链
+type MiddlewareRoute struct {
+	PathPrefix string   `yaml:"path_prefix"` // 路径前缀，如 / 、/jx
+	Chain      []string `yaml:"chain"`       // 按顺序执行的中间件名称
+}
+
+// RateLimitConfig 请求频率限制
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 每个客户端 IP 每秒请求数
+	Burst             int     `yaml:"burst"`               // 突发请求数
+}
+
+// MiddlewareCORSConfig 跨域配置：enabled 时对所有请求生效，否则只在 routes 的 chain 中加入 cors 的路径生效
+type MiddlewareCORSConfig struct {
+	Enabled    bool             `yaml:"enabled"` // 对所有路径启用，预检请求在 token 校验等中间件之前应答
+	CORSPolicy `yaml:",inline"` // 全局策略
+	Paths      []CORSPathPolicy `yaml:"paths"` // 按路径前缀覆盖，最长前缀优先，未设置的项沿用全局策略
+}
+
+// CORSPolicy 跨域策略
+type CORSPolicy struct {
+	AllowOrigins     []string      `yaml:"allow_origins"`     // 允许的来源，默认 *，支持通配如 https://*.example.com
+	AllowMethods     []string      `yaml:"allow_methods"`     // 允许的方法，默认 GET, HEAD, POST, PUT, DELETE, OPTIONS
+	AllowHeaders     []string      `yaml:"allow_headers"`     // 允许的请求头，默认允许预检请求中列出的请求头
+	ExposeHeaders    []string      `yaml:"expose_headers"`    // 页面脚本可读取的响应头，如 Content-Length、Content-Range
+	AllowCredentials bool          `yaml:"allow_credentials"` // 允许携带 Cookie 等凭据，只对 allow_origins 中列出的具体来源生效，按 * 允许时不返回凭据
+	MaxAge           time.Duration `yaml:"max_age"`           // 浏览器缓存预检结果的时间，默认 10m
+}
+
+// CORSPathPolicy 路径前缀对应的跨域策略
+type CORSPathPolicy struct {
+	PathPrefix string           `yaml:"path_prefix"` // 路径前缀，如 /web/api/
+	Disabled   bool             `yaml:"disabled"`    // 该路径不返回跨域头
+	CORSPolicy `yaml:",inline"` // 覆盖的策略
+}
+
+// HLSConfig HLS 代理配置
+type HLSConfig struct {
+	PlaylistCacheTTL time.Duration      `yaml:"playlist_cache_ttl"` // 改写后 m3u8 的缓存时间，负数表示关闭
+	SegmentCache     SegmentCacheConfig `yaml:"segment_cache"`      // 分片缓存
+}
+
+// SegmentCacheConfig HLS 分片缓存配置
+type SegmentCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`      // 启用分片缓存
+	MaxSizeMB  int           `yaml:"max_size_mb"`  // 缓存总大小上限(MB)
+	MaxEntryMB int           `yaml:"max_entry_mb"` // 单个分片大小上限(MB)，超过则不缓存
+	TTL        time.Duration `yaml:"ttl"`          // 分片缓存时间
+	DiskPath   string        `yaml:"disk_path"`    // 磁盘缓存目录，留空表示仅使用内存
 }
 
 // PublisherConfig represents the publisher configuration structure
@@ -211,27 +942,128 @@ type DNSConfig struct {
 	Servers  []string      `yaml:"servers"`   // DNS服务器列表
 	Timeout  time.Duration `yaml:"timeout"`   // DNS查询超时时间
 	MaxConns int           `yaml:"max_conns"` // 最大连接数
+	// 静态 hosts，优先于 DNS 查询，如 {"example.com": ["1.2.3.4"]}
+	Hosts     map[string][]string `yaml:"hosts"`
+	CacheSize int                 `yaml:"cache_size"` // 解析缓存条目数，默认 4096，负数关闭缓存
+	MinTTL    time.Duration       `yaml:"min_ttl"`    // 缓存最短时间，默认 5s
+	MaxTTL    time.Duration       `yaml:"max_ttl"`    // 缓存最长时间，默认 10m
 }
 
 type TLSConfig struct {
-	HTTPSPort int    `yaml:"https_port"`
-	CertFile  string `yaml:"certfile"`
-	KeyFile   string `yaml:"keyfile"`
-	Protocols string `yaml:"ssl_protocols"`
-	Ciphers   string `yaml:"ssl_ciphers"`
-	ECDHCurve string `yaml:"ssl_ecdh_curve"`
-	EnableH3  bool   `yaml:"enable_h3"` // 新增 HTTP/3 开关
+	HTTPSPort int        `yaml:"https_port"`
+	CertFile  string     `yaml:"certfile"`
+	KeyFile   string     `yaml:"keyfile"`
+	Protocols string     `yaml:"ssl_protocols"`
+	Ciphers   string     `yaml:"ssl_ciphers"`
+	ECDHCurve string     `yaml:"ssl_ecdh_curve"`
+	EnableH3  bool       `yaml:"enable_h3"` // 新增 HTTP/3 开关
+	ACME      ACMEConfig `yaml:"acme"`      // 自动申请证书，启用后忽略 certfile、keyfile
+}
+
+// 监听地址可提供的功能
+const (
+	FeatureMonitor = "monitor" // 状态页
+	FeatureWeb     = "web"     // Web 管理
+	FeatureJX      = "jx"      // 视频解析
+	FeatureProxy   = "proxy"   // 流媒体代理、域名映射与推流
+)
+
+// ListenerFeatures 全部功能
+var ListenerFeatures = []string{FeatureMonitor, FeatureWeb, FeatureJX, FeatureProxy}
+
+// ServerTimeouts 服务端超时：read_header 与 idle 作用于连接，修改后重启生效；
+// routes 按路径前缀设置请求的超时，最长前缀优先，未匹配的请求不限制
+type ServerTimeouts struct {
+	ReadHeader time.Duration  `yaml:"read_header"` // 读取请求头的超时，默认 10s
+	Idle       time.Duration  `yaml:"idle"`        // keep-alive 连接等待下一个请求的超时，默认 60s
+	Routes     []RouteTimeout `yaml:"routes"`      // 按路径前缀的请求超时
+}
+
+// RequestLimits 请求大小限制，超出时分别返回 413、414、431
+type RequestLimits struct {
+	MaxBodyKB    int64            `yaml:"max_body_kb"`    // 请求体最大大小(KB)，默认 0 不限制，各接口另有自己的上限
+	MaxURLLength int              `yaml:"max_url_length"` // 请求地址（路径与查询参数）最大长度，默认 8192
+	MaxHeaderKB  int              `yaml:"max_header_kb"`  // 请求头最大大小(KB)，默认 1024，修改后重启生效
+	MaxHeaders   int              `yaml:"max_headers"`    // 请求头最多条数，默认 100
+	Routes       []BodyLimitRoute `yaml:"routes"`         // 按路径前缀覆盖请求体大小，最长前缀优先
+}
+
+// BodyLimitRoute 路径前缀对应的请求体大小上限
+type BodyLimitRoute struct {
+	PathPrefix string `yaml:"path_prefix"` // 路径前缀，如 /web/
+	MaxBodyKB  int64  `yaml:"max_body_kb"` // 请求体最大大小(KB)，0 不限制
+}
+
+// RouteTimeout 路径前缀对应的请求超时，0 表示不限制
+type RouteTimeout struct {
+	PathPrefix string        `yaml:"path_prefix"` // 路径前缀，如 /udp/、/jx
+	Stream     bool          `yaml:"stream"`      // 流式路由：write 为单次写入的超时，不限制总时长
+	Read       time.Duration `yaml:"read"`        // 读取请求体的超时，从收到请求头开始计算，读完后不再限制
+	Write      time.Duration `yaml:"write"`       // 非流式：从收到请求头到响应完成的总时长；流式：客户端接收一次写入的最长时间
+	Idle       time.Duration `yaml:"idle"`        // 流式：超过该时长没有数据写给客户端时断开
+}
+
+// ListenerConfig 额外的监听地址，如局域网明文端口、公网 TLS 端口或供本机反向代理使用的 Unix Socket
+type ListenerConfig struct {
+	Name     string   `yaml:"name"`      // 名称，仅用于日志
+	Listen   string   `yaml:"listen"`    // 监听地址，如 :8080、192.168.1.2:8080、unix:/run/tvgate.sock
+	CertFile string   `yaml:"certfile"`  // 证书路径，与 keyfile 同时配置时使用 HTTPS
+	KeyFile  string   `yaml:"keyfile"`   // 密钥路径
+	ACME     bool     `yaml:"acme"`      // 使用 server.tls.acme 自动申请的证书
+	EnableH3 bool     `yaml:"enable_h3"` // 同时提供 HTTP/3（仅 TLS 的 TCP 地址）
+	Features []string `yaml:"features"`  // 提供的功能：monitor、web、jx、proxy，留空为全部
+}
+
+// ACMEConfig 通过 ACME（Let's Encrypt 等）自动申请和续期 https_port 使用的证书，
+// 支持 HTTP-01（需外部 80 端口转发到 port 或 http_port）与 TLS-ALPN-01（需外部 443 端口转发到 https_port）验证
+type ACMEConfig struct {
+	Enabled      bool     `yaml:"enabled"`       // 启用自动证书
+	Domains      []string `yaml:"domains"`       // 申请证书的域名，只为这些域名签发
+	Email        string   `yaml:"email"`         // 账号联系邮箱，用于接收证书到期提醒
+	CacheDir     string   `yaml:"cache_dir"`     // 证书与账号密钥缓存目录，默认配置文件目录下的 acme
+	DirectoryURL string   `yaml:"directory_url"` // ACME 服务地址，默认 Let's Encrypt 正式环境
 }
 
 // DomainMapConfig 域名映射配置结构
 type DomainMapConfig struct {
-	Name          string            `yaml:"name"`           // 配置名称
-	Source        string            `yaml:"source"`         // 源域名
-	Target        string            `yaml:"target"`         // 目标域名
-	Protocol      string            `yaml:"protocol"`       // 协议 http/https
-	Auth          AuthConfig        `yaml:"auth"`           // 动态/静态 token 配置
-	ClientHeaders map[string]string `yaml:"client_headers"` // 前端请求使用
-	ServerHeaders map[string]string `yaml:"server_headers"` // 后端请求使用
+	Name             string            `yaml:"name"`              // 配置名称
+	Source           string            `yaml:"source"`            // 源域名
+	Target           string            `yaml:"target"`            // 目标域名
+	Targets          []string          `yaml:"targets"`           // 备用目标，主目标失败（连接错误或 5xx）时按顺序切换
+	FailoverCooldown time.Duration     `yaml:"failover_cooldown"` // 失败目标的下线时长，默认 30s
+	Protocol         string            `yaml:"protocol"`          // 协议 http/https
+	Auth             AuthConfig        `yaml:"auth"`              // 动态/静态 token 配置
+	ClientHeaders    map[string]string `yaml:"client_headers"`    // 前端请求使用
+	ServerHeaders    map[string]string `yaml:"server_headers"`    // 后端请求使用
+	HeaderRules      []HeaderRule      `yaml:"header_rules"`      // 该映射的头部改写规则，在全局规则之后执行
+	PathPrefix       string            `yaml:"path_prefix"`       // 源路径前缀，为空匹配全部路径；同一域名下最长前缀优先
+	StripPrefix      bool              `yaml:"strip_prefix"`      // 转发时去掉 path_prefix
+	TargetPath       string            `yaml:"target_path"`       // 转发时拼接到路径前的前缀
+
+	TLS                   *DomainMapTLSConfig `yaml:"tls,omitempty"`           // 后端 TLS 配置
+	ConnectTimeout        time.Duration       `yaml:"connect_timeout"`         // 建立连接超时，默认使用 http.connect_timeout
+	ResponseHeaderTimeout time.Duration       `yaml:"response_header_timeout"` // 等待响应头超时，默认使用 http.response_header_timeout
+	Timeout               time.Duration       `yaml:"timeout"`                 // 整个请求超时，默认使用 http.timeout
+	Retries               int                 `yaml:"retries"`                 // 请求失败重试次数，经由代理组时覆盖组的 max_retries
+	RetryDelay            time.Duration       `yaml:"retry_delay"`             // 重试间隔
+	BodyRewrite           []BodyRewriteRule   `yaml:"body_rewrite"`            // 响应体改写规则（文本/JSON/m3u8）
+}
+
+// BodyRewriteRule 响应体改写规则，replace 中可用 ${scheme} ${host} 表示客户端访问 tvgate 的协议和域名
+type BodyRewriteRule struct {
+	Match        string   `yaml:"match"`         // 匹配内容（字符串或正则）
+	Replace      string   `yaml:"replace"`       // 替换内容，正则时可用 $1 引用捕获组
+	Regex        bool     `yaml:"regex"`         // match 是否为正则
+	ContentTypes []string `yaml:"content_types"` // 生效的内容类型（包含匹配），默认 text/ json javascript xml mpegurl
+}
+
+// DomainMapTLSConfig 域名映射访问后端时的 TLS 配置
+type DomainMapTLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // 跳过证书校验
+	CAFile             string `yaml:"ca_file"`              // 自定义 CA 证书（PEM）
+	ServerName         string `yaml:"server_name"`          // 覆盖 SNI 与证书校验使用的域名
+	CertFile           string `yaml:"cert_file"`            // 客户端证书（双向 TLS）
+	KeyFile            string `yaml:"key_file"`             // 客户端证书私钥
 }
 
 // GithubConfig GitHub 加速配置
@@ -241,6 +1073,9 @@ type GithubConfig struct {
 	BackupURLs []string      `yaml:"backup_urls"` // 备用加速地址
 	Timeout    time.Duration `yaml:"timeout"`     // 请求超时时间
 	Retry      int           `yaml:"retry"`       // 最大重试次数
+
+	AutoCheck     bool          `yaml:"auto_check"`     // 定期检查新版本，发现后在管理界面提示
+	CheckInterval time.Duration `yaml:"check_interval"` // 检查间隔，默认 24h
 }
 
 // AuthConfig 授权 token 配置
@@ -249,6 +1084,12 @@ type AuthConfig struct {
 	TokenParamName string       `yaml:"token_param_name"` // token 参数名
 	DynamicTokens  DynamicToken `yaml:"dynamic_tokens"`   // 动态 token 配置
 	StaticTokens   StaticToken  `yaml:"static_tokens"`    // 静态 token 列表
+	JWTTokens      JWTToken     `yaml:"jwt_tokens"`       // JWT token 配置
+	SignedURL      SignedURL    `yaml:"signed_url"`       // 签名 URL 配置
+
+	MaxSessions        int    `yaml:"max_sessions"`         // 每个 token 最大同时会话数，0 不限制
+	MaxSessionsPerIP   int    `yaml:"max_sessions_per_ip"`  // 每个 IP 最大同时会话数，0 不限制
+	SessionLimitAction string `yaml:"session_limit_action"` // 超出限制时的处理：reject 拒绝新会话（默认），kick_oldest 踢掉最早的会话
 }
 
 // DynamicTokenConfig 动态 token 配置
@@ -257,6 +1098,8 @@ type DynamicToken struct {
 	DynamicTTL    time.Duration `yaml:"dynamic_ttl"`    // 动态 token 有效期，例如 1h
 	Secret        string        `yaml:"secret"`         // AES key
 	Salt          string        `yaml:"salt"`           // salt
+	Allow         []string      `yaml:"allow"`          // 允许访问的频道名或路径模式，为空不限制
+	Deny          []string      `yaml:"deny"`           // 禁止访问的频道名或路径模式，优先于 allow
 }
 
 // StaticToken 静态 token 配置
@@ -264,11 +1107,85 @@ type StaticToken struct {
 	Token        string        `yaml:"token"`         // token 值
 	ExpireHours  time.Duration `yaml:"expire_hours"`  // 例如 30s, 24h
 	EnableStatic bool          `yaml:"enable_static"` // 是否启用静态 token
+	Allow        []string      `yaml:"allow"`         // 允许访问的频道名或路径模式，为空不限制
+	Deny         []string      `yaml:"deny"`          // 禁止访问的频道名或路径模式，优先于 allow
+}
+
+// JWTToken JWT token 配置，签名与声明在每次请求时校验，无需服务端保存 token
+type JWTToken struct {
+	EnableJWT     bool          `yaml:"enable_jwt"`      // 是否启用 JWT token
+	Algorithm     string        `yaml:"algorithm"`       // 签名算法 HS256/RS256，默认 HS256
+	Secret        string        `yaml:"secret"`          // HS256 密钥
+	PublicKeyFile string        `yaml:"public_key_file"` // RS256 公钥文件（PEM）
+	Issuer        string        `yaml:"issuer"`          // 校验 iss，为空不校验
+	Audience      string        `yaml:"audience"`        // 校验 aud，为空不校验
+	Leeway        time.Duration `yaml:"leeway"`          // exp/nbf 允许的时钟偏差
+}
+
+// SignedURL 带过期时间的签名 URL（类似 nginx secure_link），由外部系统生成，无需下发长期 token。
+// 签名 = base64url(HMAC-SHA256(secret, expires + path [+ 客户端IP]))，不带填充
+type SignedURL struct {
+	EnableSigned bool   `yaml:"enable_signed"` // 是否启用签名 URL
+	Secret       string `yaml:"secret"`        // 共享密钥
+	SigParam     string `yaml:"sig_param"`     // 签名参数名，默认 sig
+	ExpiresParam string `yaml:"expires_param"` // 过期时间参数名（Unix 秒），默认 expires
+	BindIP       bool   `yaml:"bind_ip"`       // 签名是否包含客户端 IP
 }
 type JXConfig struct {
 	Path      string                          `yaml:"path"`       // 视频解析路径
 	DefaultID string                          `yaml:"default_id"` // 默认视频ID
 	APIGroups map[string]*VideoAPIGroupConfig `yaml:"api_groups"` // 视频API组配置
+	Resolvers map[string]*JXResolverConfig    `yaml:"resolvers"`  // 按名称配置视频网站解析器，与内置解析器同名时覆盖其配置
+	CacheTTL  time.Duration                   `yaml:"cache_ttl"`  // 成功的解析结果缓存时长，0 不缓存，解析器可单独设置
+	RateLimit JXRateLimit                     `yaml:"rate_limit"` // 解析频率限制
+	Batch     JXBatchConfig                   `yaml:"batch"`      // 批量解析接口 路径/batch
+	Browser   JXBrowserConfig                 `yaml:"browser"`    // type 为 browser 的解析器使用的无头浏览器
+}
+
+// JXBrowserConfig 无头 Chromium：需要执行 JavaScript 才能得到播放地址的网站由浏览器打开页面，
+// 通过 Chrome DevTools 协议截取页面请求的地址或执行脚本取值。浏览器按需启动，空闲后关闭
+type JXBrowserConfig struct {
+	Path              string        `yaml:"path"`               // Chromium 可执行文件，默认在 PATH 中查找 chromium、chromium-browser、google-chrome
+	Endpoint          string        `yaml:"endpoint"`           // 使用已运行的浏览器的调试地址，如 http://127.0.0.1:9222，设置后不启动浏览器
+	Args              []string      `yaml:"args"`               // 启动浏览器的其它参数，如 --proxy-server=socks5://127.0.0.1:1080
+	Instances         int           `yaml:"instances"`          // 浏览器进程数，默认 1
+	MaxPages          int           `yaml:"max_pages"`          // 每个进程同时打开的页面数，默认 2，超出时排队
+	MaxUses           int           `yaml:"max_uses"`           // 每个进程打开多少个页面后重启以释放内存，默认 100
+	MaxMemoryMB       int           `yaml:"max_memory_mb"`      // 页面 JavaScript 堆内存上限(MB)，默认 512
+	NavigationTimeout time.Duration `yaml:"navigation_timeout"` // 打开页面到取得结果的超时，默认 30s
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`       // 浏览器空闲多久后关闭，默认 5m
+	BlockURLs         []string      `yaml:"block_urls"`         // 不加载的资源，支持 * 通配，默认不加载图片、字体与样式表
+}
+
+// JXBatchConfig 批量解析：播放列表生成工具一次提交多个播放页地址，并发解析后汇总返回
+type JXBatchConfig struct {
+	Concurrency int `yaml:"concurrency"` // 同时解析的数量，默认 4
+	MaxItems    int `yaml:"max_items"`   // 单次最多提交的数量，默认 100
+}
+
+// JXRateLimit 解析频率限制，避免频繁请求第三方接口导致服务器 IP 被封
+type JXRateLimit struct {
+	Client int `yaml:"client"` // 每个客户端 IP 每分钟最多解析次数（命中缓存的不计），0 不限制
+	Site   int `yaml:"site"`   // 每个解析器 / API 组每分钟最多请求次数，0 不限制，解析器可单独设置
+}
+
+// JXResolverConfig 视频网站解析器：从播放页地址得到剧名与集数（再由 api_groups 查询播放地址）或直接得到播放地址
+type JXResolverConfig struct {
+	Type     string            `yaml:"type"`     // 留空使用同名的内置解析器（youku、qq、iqiyi、mgtv、migu）；http 调用外部解析服务；exec 执行外部程序；browser 由无头浏览器打开页面
+	Domains  []string          `yaml:"domains"`  // 处理的网站域名，匹配子域名，内置解析器默认使用自带的域名
+	Disabled bool              `yaml:"disabled"` // 停用该解析器
+	URL      string            `yaml:"url"`      // http：解析服务地址，可用 {url} {id}
+	Method   string            `yaml:"method"`   // http：请求方法，默认 GET，POST 时请求体为 {"url","id","options"} JSON
+	Headers  map[string]string `yaml:"headers"`  // http：请求头；browser：页面请求头，User-Agent 同时用于浏览器标识
+	Command  []string          `yaml:"command"`  // exec：程序及参数，可用 {url} {id}（占位参数前加 "--"，id 只允许字母、数字、下划线和点），options 以 JX_ 开头的环境变量传入
+	Timeout  time.Duration     `yaml:"timeout"`  // 解析超时，默认 10s，browser 默认为 jx.browser.navigation_timeout
+	Options  map[string]string `yaml:"options"`  // 传给解析器的其它参数
+
+	Match  string `yaml:"match"`  // browser：页面请求的地址匹配该正则时作为播放地址，未设置 script 时默认匹配 m3u8/mp4/flv
+	Script string `yaml:"script"` // browser：页面加载后执行的 JavaScript 表达式，返回播放地址或 {name,id,url,title}，可用 {url} {id}
+
+	CacheTTL  time.Duration `yaml:"cache_ttl"`  // 该网站解析结果的缓存时长，覆盖 jx.cache_ttl，小于 0 不缓存
+	RateLimit int           `yaml:"rate_limit"` // 该解析器每分钟最多请求次数，覆盖 jx.rate_limit.site，小于 0 不限制
 }
 
 // VideoAPIGroupConfig 视频解析接口组配置
@@ -285,17 +1202,53 @@ type VideoAPIGroupConfig struct {
 
 // ProxyGroupConfig 代理组配置
 type ProxyGroupConfig struct {
-	Proxies     []*ProxyConfig `yaml:"proxies"`     // 代理服务器列表
-	Domains     []string       `yaml:"domains"`     // 域名和IP规则列表(包含IPv4和IPv6)
-	IPv6        bool           `yaml:"ipv6"`        // IPv6 开关
-	Interval    time.Duration  `yaml:"interval"`    // 检查间隔时间(秒)
-	LoadBalance string         `yaml:"loadbalance"` // 负载均衡方式
-	MaxRetries  int            `yaml:"max_retries"` // 最大重试次数
-	RetryDelay  time.Duration  `yaml:"retry_delay"` // 重试延迟(秒)
-	MaxRT       time.Duration  `yaml:"max_rt"`      // 最大响应时间
-	Stats       *GroupStats    `yaml:"-"`           // 运行时统计信息
+	Proxies      []*ProxyConfig     `yaml:"proxies"`                // 代理服务器列表
+	Domains      []string           `yaml:"domains"`                // 域名和IP规则列表(包含IPv4和IPv6)
+	IPv6         bool               `yaml:"ipv6"`                   // IPv6 开关
+	Interval     time.Duration      `yaml:"interval"`               // 检查间隔时间(秒)
+	LoadBalance  string             `yaml:"loadbalance"`            // 负载均衡方式
+	MaxRetries   int                `yaml:"max_retries"`            // 最大重试次数
+	RetryDelay   time.Duration      `yaml:"retry_delay"`            // 重试延迟(秒)
+	MaxRT        time.Duration      `yaml:"max_rt"`                 // 最大响应时间
+	HealthCheck  *HealthCheckConfig `yaml:"health_check,omitempty"` // 主动健康检查
+	Fallback     []FallbackHop      `yaml:"fallback,omitempty"`     // 回源降级链，按顺序尝试
+	Protocol     string             `yaml:"protocol"`               // 上游协议 h1/h2/h2c/h3，默认自动协商
+	DNS          []string           `yaml:"dns"`                    // 代理组专用 DNS 服务器，格式同 dns.servers，为空使用全局 DNS
+	DailyCapMB   int64              `yaml:"daily_cap_mb"`           // 每日流量上限(MB)，超出后当日跳过该组，0 不限制
+	MonthlyCapMB int64              `yaml:"monthly_cap_mb"`         // 每月流量上限(MB)，超出后当月跳过该组，0 不限制
+	StickyTTL    time.Duration      `yaml:"sticky_ttl"`             // 粘性绑定时长，期间同一客户端固定使用同一代理，0 关闭
+	StickyBy     string             `yaml:"sticky_by"`              // 粘性绑定依据 ip/token，默认 ip
+	Stats        *GroupStats        `yaml:"-"`                      // 运行时统计信息
+}
+
+// FallbackHop 回源降级链中的一跳
+type FallbackHop struct {
+	Group      string        `yaml:"group"`       // 代理组名称，direct 表示直连
+	Retries    int           `yaml:"retries"`     // 本跳重试次数，默认1
+	Timeout    time.Duration `yaml:"timeout"`     // 本跳单次请求等待响应头超时，默认10s
+	RetryDelay time.Duration `yaml:"retry_delay"` // 本跳重试间隔
 }
 
+// FallbackDirect 降级链中表示直连的组名
+const FallbackDirect = "direct"
+
+// HealthCheckConfig 代理主动健康检查配置
+type HealthCheckConfig struct {
+	Enabled  bool          `yaml:"enabled"`  // 是否启用
+	URL      string        `yaml:"url"`      // 检查地址，通过代理访问
+	Interval time.Duration `yaml:"interval"` // 检查间隔
+	Timeout  time.Duration `yaml:"timeout"`  // 单次检查超时
+	Rise     int           `yaml:"rise"`     // 连续成功多少次标记为健康
+	Fall     int           `yaml:"fall"`     // 连续失败多少次标记为不健康
+}
+
+// 健康检查状态
+const (
+	HealthUnknown = ""     // 未检查
+	HealthUp      = "up"   // 健康
+	HealthDown    = "down" // 不健康，不参与选择
+)
+
 // GroupStats 代理组运行时统计
 type GroupStats struct {
 	LastCheck       time.Time
@@ -315,6 +1268,8 @@ type ProxyConfig struct {
 	Username string            `yaml:"username"` // 代理用户名 (可选)
 	Password string            `yaml:"password"` // 代理密码 (可选)
 	Headers  map[string]string `yaml:"headers"`  // 添加自定义headers支持
+	Weight   int               `yaml:"weight"`   // 权重 (weighted 策略使用，默认1)
+	Disabled bool              `yaml:"disabled"` // 停用，保留配置但不参与选择和健康检查
 }
 
 // ProxyStats 代理统计信息
@@ -326,6 +1281,22 @@ type ProxyStats struct {
 	FailCount     int           // 测速失败次数
 	CooldownUntil time.Time     // 冷却时间，防止频繁重试
 	StatusCode    int           // 测试返回状态码（HTTP/自定义）
+
+	// 主动健康检查状态
+	HealthStatus    string        // up / down / 空表示未检查
+	HealthSuccesses int           // 连续成功次数
+	HealthFailures  int           // 连续失败次数
+	HealthLastCheck time.Time     // 上次健康检查时间
+	HealthRT        time.Duration // 上次健康检查耗时
+	HealthError     string        // 上次健康检查错误
+
+	ActiveConns   int64 // 当前经由该代理的连接数（原子操作）
+	WeightCurrent int   // 平滑加权轮询当前权重
+}
+
+// IsHealthDown 是否被健康检查标记为不可用
+func (s *ProxyStats) IsHealthDown() bool {
+	return s != nil && s.HealthStatus == HealthDown
 }
 
 // 全局定义测速结果结构体
@@ -442,6 +1413,21 @@ func (c *Config) SetDefaults() {
 	if c.Server.FccCacheSize <= 0 {
 		c.Server.FccCacheSize = 16384
 	}
+	if c.Server.Timeouts.ReadHeader <= 0 {
+		c.Server.Timeouts.ReadHeader = 10 * time.Second
+	}
+	if c.Server.Timeouts.Idle <= 0 {
+		c.Server.Timeouts.Idle = 60 * time.Second
+	}
+	if c.Server.Limits.MaxURLLength <= 0 {
+		c.Server.Limits.MaxURLLength = 8192
+	}
+	if c.Server.Limits.MaxHeaderKB <= 0 {
+		c.Server.Limits.MaxHeaderKB = 1024
+	}
+	if c.Server.Limits.MaxHeaders <= 0 {
+		c.Server.Limits.MaxHeaders = 100
+	}
 	// DNS 默认值
 	if c.DNS.Timeout == 0 {
 		c.DNS.Timeout = 5 * time.Second
@@ -450,6 +1436,319 @@ func (c *Config) SetDefaults() {
 		c.DNS.MaxConns = 10
 	}
 
+	// HLS 默认值
+	if c.HLS.PlaylistCacheTTL == 0 {
+		c.HLS.PlaylistCacheTTL = 1 * time.Second
+	}
+	if c.HLS.SegmentCache.MaxSizeMB <= 0 {
+		c.HLS.SegmentCache.MaxSizeMB = 256
+	}
+	if c.HLS.SegmentCache.MaxEntryMB <= 0 {
+		c.HLS.SegmentCache.MaxEntryMB = 16
+	}
+	if c.HLS.SegmentCache.TTL <= 0 {
+		c.HLS.SegmentCache.TTL = 30 * time.Second
+	}
+
+	// 暴力破解防护默认值
+	if c.BruteForce.MaxFailures <= 0 {
+		c.BruteForce.MaxFailures = 5
+	}
+	if c.BruteForce.Window <= 0 {
+		c.BruteForce.Window = 10 * time.Minute
+	}
+	if c.BruteForce.BanDuration <= 0 {
+		c.BruteForce.BanDuration = time.Minute
+	}
+	if c.BruteForce.MaxBanDuration <= 0 {
+		c.BruteForce.MaxBanDuration = 24 * time.Hour
+	}
+
+	// 重复日志合并默认值
+	if c.Log.Dedup.Window <= 0 {
+		c.Log.Dedup.Window = time.Minute
+	}
+	if c.Log.Dedup.Burst <= 0 {
+		c.Log.Dedup.Burst = 10
+	}
+
+	// 审计日志默认值
+	if c.Audit.MaxEvents <= 0 {
+		c.Audit.MaxEvents = 100000
+	}
+	if c.Audit.Retention <= 0 {
+		c.Audit.Retention = 7 * 24 * time.Hour
+	}
+
+	// 配置版本历史默认值
+	if c.History.MaxVersions == 0 {
+		c.History.MaxVersions = 50
+	}
+
+	// 历史统计默认值
+	if c.Stats.Interval <= 0 {
+		c.Stats.Interval = time.Minute
+	}
+	if c.Stats.Retention <= 0 {
+		c.Stats.Retention = 7 * 24 * time.Hour
+	}
+
+	// 并发连接数限制默认值
+	if c.ConnLimit.Action == "" {
+		c.ConnLimit.Action = "reject"
+	}
+	if c.ConnLimit.QueueTimeout <= 0 {
+		c.ConnLimit.QueueTimeout = 10 * time.Second
+	}
+
+	// 告警默认值
+	if c.Alert.Interval <= 0 {
+		c.Alert.Interval = 30 * time.Second
+	}
+	if c.Alert.For <= 0 {
+		c.Alert.For = time.Minute
+	}
+
+	// 链路追踪默认值
+	if c.Tracing.Endpoint == "" {
+		c.Tracing.Endpoint = "http://127.0.0.1:4318/v1/traces"
+	}
+	if c.Tracing.SampleRatio <= 0 {
+		c.Tracing.SampleRatio = 1
+	}
+	if c.Tracing.Timeout <= 0 {
+		c.Tracing.Timeout = 10 * time.Second
+	}
+
+	// 健康检查默认值
+	if c.Health.LivenessPath == "" {
+		c.Health.LivenessPath = "/healthz"
+	}
+	if c.Health.ReadinessPath == "" {
+		c.Health.ReadinessPath = "/readyz"
+	}
+	if c.Health.HubIdle <= 0 {
+		c.Health.HubIdle = 10 * time.Second
+	}
+
+	// DLNA 默认值
+	if c.DLNA.FriendlyName == "" {
+		c.DLNA.FriendlyName = "TVGate"
+	}
+	if c.DLNA.Path == "" {
+		c.DLNA.Path = "/dlna"
+	}
+	if c.DLNA.Refresh <= 0 {
+		c.DLNA.Refresh = time.Hour
+	}
+	if c.DLNA.Timeout <= 0 {
+		c.DLNA.Timeout = 30 * time.Second
+	}
+
+	// 投屏默认值
+	if c.Cast.TokenTTL <= 0 {
+		c.Cast.TokenTTL = 12 * time.Hour
+	}
+	if c.Cast.Discovery <= 0 {
+		c.Cast.Discovery = 3 * time.Second
+	}
+	if c.Cast.Timeout <= 0 {
+		c.Cast.Timeout = 10 * time.Second
+	}
+
+	// 共享存储默认值
+	if c.Store.Addr == "" {
+		c.Store.Addr = "127.0.0.1:6379"
+	}
+	if c.Store.Prefix == "" {
+		c.Store.Prefix = "tvgate:"
+	}
+	if c.Store.PoolSize <= 0 {
+		c.Store.PoolSize = 8
+	}
+	if c.Store.Timeout <= 0 {
+		c.Store.Timeout = 3 * time.Second
+	}
+	if c.Store.SyncInterval <= 0 {
+		c.Store.SyncInterval = 5 * time.Second
+	}
+
+	// 频道探测默认值
+	if c.Probe.Interval <= 0 {
+		c.Probe.Interval = 10 * time.Minute
+	}
+	if c.Probe.Timeout <= 0 {
+		c.Probe.Timeout = 10 * time.Second
+	}
+
+	// Hub 缓存内存预算默认值
+	if c.Server.CacheMemoryMB <= 0 {
+		c.Server.CacheMemoryMB = 512
+	}
+
+	// 组播转 HTTP 批量写出默认值
+	if c.Server.WriteBatchKB <= 0 {
+		c.Server.WriteBatchKB = 64
+	}
+	if c.Server.WriteBatchLatency <= 0 {
+		c.Server.WriteBatchLatency = 50 * time.Millisecond
+	}
+
+	// 推流接入默认值
+	if c.Ingest.Path == "" {
+		c.Ingest.Path = "/ingest/"
+	}
+	if c.Ingest.IdleTimeout <= 0 {
+		c.Ingest.IdleTimeout = 10 * time.Second
+	}
+
+	// 批量解析与无头浏览器默认值
+	if c.JX.Batch.Concurrency <= 0 {
+		c.JX.Batch.Concurrency = 4
+	}
+	if c.JX.Batch.MaxItems <= 0 {
+		c.JX.Batch.MaxItems = 100
+	}
+
+	if c.JX.Browser.Instances <= 0 {
+		c.JX.Browser.Instances = 1
+	}
+	if c.JX.Browser.MaxPages <= 0 {
+		c.JX.Browser.MaxPages = 2
+	}
+	if c.JX.Browser.MaxUses <= 0 {
+		c.JX.Browser.MaxUses = 100
+	}
+	if c.JX.Browser.MaxMemoryMB <= 0 {
+		c.JX.Browser.MaxMemoryMB = 512
+	}
+	if c.JX.Browser.NavigationTimeout <= 0 {
+		c.JX.Browser.NavigationTimeout = 30 * time.Second
+	}
+	if c.JX.Browser.IdleTimeout <= 0 {
+		c.JX.Browser.IdleTimeout = 5 * time.Minute
+	}
+
+	// 定时录制默认值
+	if c.DVR.MaxConcurrent <= 0 {
+		c.DVR.MaxConcurrent = 2
+	}
+	if c.DVR.MinFreeMB <= 0 {
+		c.DVR.MinFreeMB = 1024
+	}
+	if c.DVR.PrePadding <= 0 {
+		c.DVR.PrePadding = time.Minute
+	}
+	if c.DVR.PostPadding <= 0 {
+		c.DVR.PostPadding = 2 * time.Minute
+	}
+	if c.DVR.Retry <= 0 {
+		c.DVR.Retry = 5 * time.Second
+	}
+	if c.DVR.Post.ThumbnailAt <= 0 {
+		c.DVR.Post.ThumbnailAt = time.Minute
+	}
+	if c.DVR.Post.Timeout <= 0 {
+		c.DVR.Post.Timeout = 30 * time.Minute
+	}
+	if c.DVR.VOD.Path == "" {
+		c.DVR.VOD.Path = "/vod/"
+	}
+	if c.DVR.VOD.SegmentDuration <= 0 {
+		c.DVR.VOD.SegmentDuration = 6 * time.Second
+	}
+
+	// 上游地址刷新默认值
+	if c.Refresh.Path == "" {
+		c.Refresh.Path = "/refresh/"
+	}
+	if c.Refresh.Timeout <= 0 {
+		c.Refresh.Timeout = 10 * time.Second
+	}
+	for i := range c.Refresh.Channels {
+		if c.Refresh.Channels[i].TTL <= 0 {
+			c.Refresh.Channels[i].TTL = 10 * time.Minute
+		}
+	}
+
+	// 集群默认值
+	if c.Cluster.Path == "" {
+		c.Cluster.Path = "/cluster/"
+	}
+	if c.Cluster.Protocol == "" {
+		c.Cluster.Protocol = "h2c"
+	}
+	if c.Cluster.URLTemplate == "" {
+		c.Cluster.URLTemplate = "{origin}{path}"
+	}
+	if len(c.Cluster.Paths) == 0 {
+		c.Cluster.Paths = []string{"/rtp/", "/udp/", "/rtsp/"}
+	}
+	if c.Cluster.HealthInterval <= 0 {
+		c.Cluster.HealthInterval = 10 * time.Second
+	}
+	if c.Cluster.Timeout <= 0 {
+		c.Cluster.Timeout = 10 * time.Second
+	}
+	if c.Cluster.TokenCache <= 0 {
+		c.Cluster.TokenCache = time.Minute
+	}
+
+	// 频道图标默认值
+	if c.Logo.Path == "" {
+		c.Logo.Path = "/logo"
+	}
+	if c.Logo.Size <= 0 {
+		c.Logo.Size = 256
+	}
+	if c.Logo.TTL <= 0 {
+		c.Logo.TTL = 168 * time.Hour
+	}
+	if c.Logo.Timeout <= 0 {
+		c.Logo.Timeout = 10 * time.Second
+	}
+
+	// 回看默认值
+	if c.Catchup.Path == "" {
+		c.Catchup.Path = "/timeshift"
+	}
+	if c.Catchup.Timezone == "" {
+		c.Catchup.Timezone = "Asia/Shanghai"
+	}
+	if c.Catchup.MaxDuration <= 0 {
+		c.Catchup.MaxDuration = 24 * time.Hour
+	}
+
+	// 节目单默认值
+	if c.EPG.Path == "" {
+		c.EPG.Path = "/xmltv.xml"
+	}
+	if c.EPG.Refresh <= 0 {
+		c.EPG.Refresh = 6 * time.Hour
+	}
+	if c.EPG.Timeout <= 0 {
+		c.EPG.Timeout = 60 * time.Second
+	}
+
+	// 指标推送默认值
+	if c.Metrics.Format == "" {
+		c.Metrics.Format = "influx"
+	}
+	if c.Metrics.Interval <= 0 {
+		c.Metrics.Interval = 10 * time.Second
+	}
+	if c.Metrics.Prefix == "" {
+		c.Metrics.Prefix = "tvgate"
+	}
+	if c.Metrics.Timeout <= 0 {
+		c.Metrics.Timeout = 5 * time.Second
+	}
+
+	// 访问日志默认值
+	if c.AccessLog.Format == "" {
+		c.AccessLog.Format = "combined"
+	}
+
 	// GitHub 默认值
 	if c.Github.Timeout == 0 {
 		c.Github.Timeout = 10 * time.Second
@@ -457,6 +1756,9 @@ func (c *Config) SetDefaults() {
 	if c.Github.Retry == 0 {
 		c.Github.Retry = 3
 	}
+	if c.Github.CheckInterval == 0 {
+		c.Github.CheckInterval = 24 * time.Hour
+	}
 }
 
 // InitStartTime 初始化程序启动时间
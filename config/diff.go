@@ -0,0 +1,29 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangedSections 按顶层配置项比较新旧配置，返回发生变化的配置项名（yaml 键名）
+// 按 YAML 序列化结果比较，忽略 yaml:"-" 的运行时字段（如代理组测速统计）
+func ChangedSections(oldCfg, newCfg *Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(oldCfg).Elem(), reflect.ValueOf(newCfg).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		a, errA := yaml.Marshal(ov.Field(i).Interface())
+		b, errB := yaml.Marshal(nv.Field(i).Interface())
+		if errA != nil || errB != nil || !bytes.Equal(a, b) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
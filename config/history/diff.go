@@ -0,0 +1,172 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差异两侧的行数乘积超过该值时不再逐行比较，整体显示为删除后新增
+const maxDiffCells = 4 << 20
+
+// 统一格式差异中的上下文行数
+const contextLines = 3
+
+// diffOp 一行差异：' ' 相同，'-' 删除，'+' 新增
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines 计算逐行差异：先去掉相同的首尾行，中间部分用最长公共子序列比较
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		ops = append(ops, lcsDiff(ma, mb)...)
+	}
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+func lcsDiff(a, b []string) []diffOp {
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	w := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+				lcs[i*w+j] = lcs[(i+1)*w+j]
+			} else {
+				lcs[i*w+j] = lcs[i*w+j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// Diff 返回从 a 到 b 的统一格式差异（与 diff -u 相同），内容相同时返回空字符串
+func Diff(a, b []byte, nameA, nameB string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	lineA, lineB := 1, 1 // ops[i] 对应的行号
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+
+		// 找到本段差异的范围：相邻差异之间的相同行不超过 2*contextLines 时合并
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			same := 0
+			for end+same < len(ops) && ops[end+same].kind == ' ' {
+				same++
+			}
+			if end+same == len(ops) || same > 2*contextLines {
+				end += min(same, contextLines)
+				break
+			}
+			end += same
+		}
+
+		// 计算段头的起始行与行数
+		startA, startB := lineA-(i-start), lineB-(i-start)
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				lineA++
+			}
+			if op.kind != '-' {
+				lineB++
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange 段头中的行范围，空范围的起始行为前一行
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,276 @@
+package history
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// ErrNotFound 指定的版本不存在
+var ErrNotFound = errors.New("配置版本不存在")
+
+// 版本索引文件名
+const indexFile = "index.json"
+
+// Version 一次成功加载的配置
+type Version struct {
+	ID      string    `json:"id"` // 时间戳，同时是副本文件名
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // startup / reload / web / api / rollback:<id>
+	Size    int       `json:"size"`
+	Hash    string    `json:"hash"`              // 内容 sha256
+	Changed []string  `json:"changed,omitempty"` // 与上一版本相比变化的顶层配置项
+	Added   int       `json:"added"`             // 与上一版本相比新增的行数
+	Removed int       `json:"removed"`           // 与上一版本相比删除的行数
+}
+
+// Store 配置版本历史，副本保存在目录中，索引记录元数据
+type Store struct {
+	mu         sync.Mutex
+	dir        string
+	max        int
+	versions   []Version // 按时间从早到晚
+	nextSource string
+}
+
+// Default 全局实例
+var Default = &Store{}
+
+// defaultDir 默认保存目录，与配置文件放在同一目录
+func defaultDir() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "config_history")
+}
+
+// Configure 应用配置，目录变化时重新读取索引
+func (s *Store) Configure(cfg config.HistoryConfig) {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultDir()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = cfg.MaxVersions
+	if dir != s.dir {
+		s.dir = dir
+		s.versions = loadIndex(dir)
+	}
+	if s.prune() {
+		s.saveIndex()
+	}
+}
+
+// SetNextSource 设置下一次记录的版本来源，用于标记由接口写入或回滚产生的配置
+func (s *Store) SetNextSource(source string) {
+	s.mu.Lock()
+	s.nextSource = source
+	s.mu.Unlock()
+}
+
+// Record 记录配置内容，与最新版本相同时忽略；返回新版本
+func (s *Store) Record(source string, data []byte, changed []string) (*Version, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max < 0 || s.dir == "" {
+		return nil, nil
+	}
+	if s.nextSource != "" {
+		source = s.nextSource
+		s.nextSource = ""
+	}
+
+	var prev []byte
+	if n := len(s.versions); n > 0 {
+		last := s.versions[n-1]
+		if last.Hash == hash {
+			return nil, nil
+		}
+		prev, _ = os.ReadFile(s.path(last.ID))
+	}
+
+	now := time.Now()
+	v := Version{
+		ID:      now.Format("20060102-150405.000"),
+		Time:    now,
+		Source:  source,
+		Size:    len(data),
+		Hash:    hash,
+		Changed: changed,
+	}
+	// 同一毫秒内多次记录时避免覆盖
+	if n := len(s.versions); n > 0 && s.versions[n-1].ID >= v.ID {
+		v.ID = s.versions[n-1].ID + "-1"
+	}
+	v.Added, v.Removed = CountChanges(prev, data)
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.path(v.ID), data, 0644); err != nil {
+		return nil, err
+	}
+	s.versions = append(s.versions, v)
+	s.prune()
+	if err := s.saveIndex(); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RecordFile 读取配置文件并记录
+func (s *Store) RecordFile(source, path string, changed []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.LogPrintf("⚠️ 读取配置文件失败，未记录版本: %v", err)
+		return
+	}
+	v, err := s.Record(source, data, changed)
+	if err != nil {
+		logger.LogPrintf("❌ 保存配置版本失败: %v", err)
+		return
+	}
+	if v != nil {
+		logger.LogPrintf("📄 已保存配置版本 %s (%s, +%d -%d)", v.ID, v.Source, v.Added, v.Removed)
+	}
+}
+
+// List 返回全部版本，按时间从新到旧
+func (s *Store) List() []Version {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Version, len(s.versions))
+	for i, v := range s.versions {
+		list[len(s.versions)-1-i] = v
+	}
+	return list
+}
+
+// Get 返回指定版本及其内容
+func (s *Store) Get(id string) (Version, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.versions {
+		if v.ID == id {
+			data, err := os.ReadFile(s.path(id))
+			return v, data, err
+		}
+	}
+	return Version{}, nil, ErrNotFound
+}
+
+// Previous 返回指定版本的上一个版本
+func (s *Store) Previous(id string) (Version, []byte, error) {
+	s.mu.Lock()
+	var prev *Version
+	for i, v := range s.versions {
+		if v.ID == id && i > 0 {
+			p := s.versions[i-1]
+			prev = &p
+			break
+		}
+	}
+	s.mu.Unlock()
+	if prev == nil {
+		return Version{}, nil, ErrNotFound
+	}
+	return s.Get(prev.ID)
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".yaml")
+}
+
+// prune 删除超出保留数的最早版本，调用方持有锁
+func (s *Store) prune() bool {
+	if s.max <= 0 || len(s.versions) <= s.max {
+		return false
+	}
+	drop := len(s.versions) - s.max
+	for _, v := range s.versions[:drop] {
+		os.Remove(s.path(v.ID))
+	}
+	s.versions = append([]Version(nil), s.versions[drop:]...)
+	return true
+}
+
+// saveIndex 写入索引（先写临时文件再重命名），调用方持有锁
+func (s *Store) saveIndex() error {
+	data, err := json.MarshalIndent(s.versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(s.dir, indexFile)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// loadIndex 读取索引，副本文件已不存在的版本跳过
+func loadIndex(dir string) []Version {
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取配置版本索引失败: %v", err)
+		}
+		return nil
+	}
+	var list []Version
+	if err := json.Unmarshal(data, &list); err != nil {
+		logger.LogPrintf("⚠️ 解析配置版本索引失败: %v", err)
+		return nil
+	}
+	versions := list[:0]
+	for _, v := range list {
+		if _, err := os.Stat(filepath.Join(dir, v.ID+".yaml")); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// CountChanges 统计从 a 到 b 新增与删除的行数
+func CountChanges(a, b []byte) (added, removed int) {
+	for _, op := range diffLines(splitLines(a), splitLines(b)) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func splitLines(data []byte) []string {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return nil
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	list := make([]string, len(lines))
+	for i, l := range lines {
+		list[i] = string(bytes.TrimSuffix(l, []byte("\r")))
+	}
+	return list
+}
+
+// Configure 更新全局实例配置
+func Configure(cfg config.HistoryConfig) { Default.Configure(cfg) }
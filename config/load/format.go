@@ -0,0 +1,239 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件格式，按扩展名识别，其余扩展名按 YAML 处理
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// Format 返回配置文件格式
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// ContentType 返回配置文件格式对应的 Content-Type
+func ContentType(path string) string {
+	switch Format(path) {
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatTOML:
+		return "application/toml; charset=utf-8"
+	}
+	return "application/yaml; charset=utf-8"
+}
+
+// UnmarshalNode 按文件格式将配置内容解析为 YAML 文档节点，后续的 includes、引用替换和校验都基于该节点。
+// JSON 基本是 YAML 的子集，优先按 YAML 解析以保留行号；TOML 转换而来，没有行号
+func UnmarshalNode(path string, data []byte, doc *yaml.Node) error {
+	var value map[string]interface{}
+	switch Format(path) {
+	case FormatYAML:
+		return yaml.Unmarshal(data, doc)
+	case FormatJSON:
+		err := yaml.Unmarshal(data, doc)
+		if err == nil {
+			return nil
+		}
+		// 少数 JSON 写法（如 \/ 转义）YAML 不支持，改用 JSON 解析，此时没有行号
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if dec.Decode(&value) != nil {
+			return err
+		}
+		numbersToValues(value)
+	case FormatTOML:
+		if _, err := toml.Decode(string(data), &value); err != nil {
+			var perr toml.ParseError
+			if errors.As(err, &perr) {
+				// 与 yaml 错误一致使用 "line N: " 格式，便于提取行号
+				return fmt.Errorf("toml: line %d: %s", perr.Position.Line, perr.Message)
+			}
+			return err
+		}
+	}
+
+	*doc = yaml.Node{}
+	if len(value) == 0 {
+		return nil
+	}
+	var root yaml.Node
+	if err := root.Encode(value); err != nil {
+		return err
+	}
+	clearLines(&root)
+	*doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}
+	return nil
+}
+
+// clearLines 清除编码时生成的行号，避免指向不存在的 YAML 行
+func clearLines(n *yaml.Node) {
+	n.Line, n.Column = 0, 0
+	for _, c := range n.Content {
+		clearLines(c)
+	}
+}
+
+// MarshalNode 按文件格式序列化文档节点；JSON 保持键的顺序，TOML 按键排序，两者都不保留注释
+func MarshalNode(path string, doc *yaml.Node) ([]byte, error) {
+	switch Format(path) {
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, doc, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case FormatTOML:
+		var value map[string]interface{}
+		if err := doc.Decode(&value); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(dropNil(value)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return yaml.Marshal(doc)
+}
+
+// Convert 将 YAML 内容转换为 path 对应的格式，用于生成默认配置文件
+func Convert(path string, data []byte) ([]byte, error) {
+	if Format(path) == FormatYAML {
+		return data, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return MarshalNode(path, &doc)
+}
+
+// writeJSON 按节点顺序输出缩进的 JSON
+func writeJSON(buf *bytes.Buffer, n *yaml.Node, indent string) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		return writeJSON(buf, n.Content[0], indent)
+	case yaml.AliasNode:
+		return writeJSON(buf, n.Alias, indent)
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, end, step := byte('{'), byte('}'), 2
+		if n.Kind == yaml.SequenceNode {
+			open, end, step = '[', ']', 1
+		}
+		if len(n.Content) == 0 {
+			buf.WriteByte(open)
+			buf.WriteByte(end)
+			return nil
+		}
+		inner := indent + "  "
+		buf.WriteByte(open)
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString("\n" + inner)
+			value := n.Content[i]
+			if step == 2 {
+				key, err := jsonValue(n.Content[i].Value)
+				if err != nil {
+					return err
+				}
+				buf.Write(key)
+				buf.WriteString(": ")
+				value = n.Content[i+1]
+			}
+			if err := writeJSON(buf, value, inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent)
+		buf.WriteByte(end)
+		return nil
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	data, err := jsonValue(v)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	buf.Write(data)
+	return nil
+}
+
+// jsonValue 序列化单个值，不转义 HTML 字符
+func jsonValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// numbersToValues 将 json.Number 转换为整数或浮点数
+func numbersToValues(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = numbersToValues(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = numbersToValues(item)
+		}
+	}
+	return v
+}
+
+// dropNil 删除值为空的键，TOML 没有 null
+func dropNil(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if item == nil {
+				delete(val, k)
+				continue
+			}
+			val[k] = dropNil(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = dropNil(item)
+		}
+	}
+	return v
+}
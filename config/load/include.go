@@ -0,0 +1,268 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 引入其它配置文件的配置项
+const includesKey = "includes"
+
+// 最大嵌套引入层数
+const maxIncludeDepth = 8
+
+// 最近一次成功加载时引入的文件与匹配模式（绝对路径），用于监控文件变更
+var (
+	includeMu       sync.RWMutex
+	includeFiles    []string
+	includePatterns []string
+)
+
+// Included 返回最近一次加载时引入的文件及 includes 中的匹配模式
+func Included() (files, patterns []string) {
+	includeMu.RLock()
+	defer includeMu.RUnlock()
+	return append([]string(nil), includeFiles...), append([]string(nil), includePatterns...)
+}
+
+// IsIncluded 判断文件是否为引入的配置文件，或匹配 includes 中的模式（新建的文件）
+func IsIncluded(name string) bool {
+	name = filepath.Clean(name)
+	includeMu.RLock()
+	defer includeMu.RUnlock()
+	for _, f := range includeFiles {
+		if f == name {
+			return true
+		}
+	}
+	for _, p := range includePatterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Stamp 返回主配置文件及引入文件的修改时间与大小摘要，摘要变化说明需要重新加载
+func Stamp(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	files := []string{abs}
+	_, patterns := Included()
+	for _, p := range patterns {
+		matches, _ := filepath.Glob(p)
+		files = append(files, matches...)
+	}
+
+	var sb strings.Builder
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&sb, "%s|%d|%d;", f, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return sb.String()
+}
+
+// ReadDocument 读取配置文件并合并 includes 引入的文件，返回合并后的文档（未替换 ${...} 引用）
+// 及来自引入文件的节点所属文件，用于定位行号
+func ReadDocument(path string) (*yaml.Node, map[*yaml.Node]string, error) {
+	r, doc, err := readDocument(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, r.owner, nil
+}
+
+func readDocument(path string) (*includeReader, *yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := &includeReader{seen: map[string]bool{abs: true}, owner: make(map[*yaml.Node]string)}
+	doc, err := r.read(abs, 0)
+	return r, doc, err
+}
+
+// includeReader 递归读取 includes，记录引入的文件与模式
+type includeReader struct {
+	seen     map[string]bool
+	files    []string
+	patterns []string
+	owner    map[*yaml.Node]string // 引入文件中的节点 -> 文件路径
+}
+
+// setOwner 记录节点所属文件，已记录的（更深层引入的）保持不变
+func (r *includeReader) setOwner(n *yaml.Node, file string) {
+	if _, ok := r.owner[n]; !ok {
+		r.owner[n] = file
+	}
+	for _, c := range n.Content {
+		r.setOwner(c, file)
+	}
+}
+
+func (r *includeReader) read(path string, depth int) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := UnmarshalNode(path, data, &doc); err != nil {
+		if depth == 0 {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return &doc, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: 顶层必须是键值映射", path)
+	}
+
+	patterns, err := includeList(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		r.patterns = append(r.patterns, pattern)
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: includes 格式错误 %s: %w", path, pattern, err)
+		}
+		// 通配符没有匹配到文件时忽略，直接写明的文件必须存在
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("%s: 引入的文件不存在: %s", path, pattern)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if r.seen[m] {
+				return nil, fmt.Errorf("%s: 重复或循环引入: %s", path, m)
+			}
+			if depth+1 > maxIncludeDepth {
+				return nil, fmt.Errorf("%s: 引入层数超过 %d", path, maxIncludeDepth)
+			}
+			r.seen[m] = true
+			r.files = append(r.files, m)
+
+			sub, err := r.read(m, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub.Content) > 0 {
+				subRoot := sub.Content[0]
+				r.setOwner(subRoot, m)
+				removeKey(subRoot, includesKey)
+				mergeNode(root, subRoot)
+			}
+		}
+	}
+	return &doc, nil
+}
+
+// includeList 读取映射中的 includes，支持单个字符串或字符串列表
+func includeList(root *yaml.Node) ([]string, error) {
+	node := mappingValue(root, includesKey)
+	if node == nil || node.Tag == "!!null" {
+		return nil, nil
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		list := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: includes 只能包含文件路径", item.Line)
+			}
+			list = append(list, item.Value)
+		}
+		return list, nil
+	}
+	return nil, errors.New("includes 必须是文件路径或路径列表")
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func removeKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// mergeNode 将 src 合并到 dst：映射按键递归合并，列表追加（跳过完全相同的项），
+// 其余情况以 dst（先加载的文件）为准；dst 为空值时直接使用 src
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind == yaml.ScalarNode && dst.Tag == "!!null" {
+		*dst = *src
+		return
+	}
+	if dst.Kind != src.Kind {
+		return
+	}
+	switch dst.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				mergeNode(existing, value)
+			} else {
+				dst.Content = append(dst.Content, key, value)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range src.Content {
+			if !containsNode(dst.Content, item) {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+	}
+}
+
+func containsNode(list []*yaml.Node, n *yaml.Node) bool {
+	for _, item := range list {
+		if nodeEqual(item, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeEqual 比较两个节点的内容，忽略行号、注释与引号风格
+func nodeEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && (a.Value != b.Value || a.ShortTag() != b.ShortTag()) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodeEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+	"gopkg.in/yaml.v3"
+)
+
+// 文件引用前缀，${file:/run/secrets/x} 读取文件内容
+const filePrefix = "file:"
+
+// Parse 按 path 的格式解析配置内容，先替换其中的 ${...} 引用再解码；不处理 includes
+func Parse(path string, data []byte, cfg *config.Config) error {
+	var doc yaml.Node
+	if err := UnmarshalNode(path, data, &doc); err != nil {
+		return err
+	}
+	return decodeDocument(&doc, cfg)
+}
+
+// ParseRaw 同 Parse，但不替换 ${...} 引用，用于校验不可信来源提交的配置，避免读取环境变量和服务器上的文件
+func ParseRaw(path string, data []byte, cfg *config.Config) error {
+	var doc yaml.Node
+	if err := UnmarshalNode(path, data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Decode(cfg)
+}
+
+// decodeDocument 替换引用后解码到配置结构
+func decodeDocument(doc *yaml.Node, cfg *config.Config) error {
+	if err := Interpolate(doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Decode(cfg)
+}
+
+// Interpolate 替换节点中所有标量值里的引用：
+//
+//	${NAME}             环境变量，未设置时报错
+//	${NAME:-default}    环境变量，未设置或为空时使用 default
+//	${file:/path}       文件内容，去掉末尾换行
+//	$${                 原样输出 ${
+//
+// 只替换值，不改变 YAML 结构，因此密码中包含特殊字符也不需要转义。
+// 错误信息与 yaml 一致使用 "line N: " 前缀，多个错误以换行分隔
+func Interpolate(n *yaml.Node) error {
+	var errs []error
+	interpolateNode(n, &errs)
+	return errors.Join(errs...)
+}
+
+func interpolateNode(n *yaml.Node, errs *[]error) {
+	if n == nil {
+		return
+	}
+	if n.Kind == yaml.ScalarNode {
+		if !strings.Contains(n.Value, "${") {
+			return
+		}
+		value, err := expand(n.Value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("line %d: %w", n.Line, err))
+			return
+		}
+		n.Value = value
+		// 未加引号的值按替换后的内容重新推断类型，使 port: ${PORT} 能解码为整数
+		if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Tag = ""
+		}
+		return
+	}
+	for _, c := range n.Content {
+		interpolateNode(c, errs)
+	}
+}
+
+// expand 替换字符串中的全部引用
+func expand(s string) (string, error) {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		// $${ 转义
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1])
+			sb.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("引用缺少右括号: %s", s[i:])
+		}
+		value, err := lookup(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s[:i])
+		sb.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// lookup 解析单个引用
+func lookup(ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, filePrefix); ok {
+		if path == "" {
+			return "", errors.New("${file:} 未指定文件路径")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("读取 ${%s} 失败: %w", ref, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("无效的引用 ${%s}", ref)
+	}
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", fmt.Errorf("环境变量 %s 未设置", name)
+}
+
+// MarshalEdited 序列化网页编辑器修改后的配置文档，old 为修改前的配置文件内容。
+// 编辑器显示的是替换引用后的值，原样提交时恢复为原来的 ${...}，避免把密钥以明文写入配置文件；
+// 其余新写入的 ${ 转义为 $${，网页提交的内容不能读取环境变量和服务器上的文件
+func MarshalEdited(path string, old []byte, doc *yaml.Node) ([]byte, error) {
+	var oldDoc yaml.Node
+	if err := UnmarshalNode(path, old, &oldDoc); err != nil {
+		return nil, err
+	}
+	keepRefs(doc, &oldDoc)
+	return MarshalNode(path, doc)
+}
+
+// keepRefs 按键与下标对照修改前的节点处理引用，old 为 nil 表示新增的节点
+func keepRefs(n, old *yaml.Node) {
+	if n == nil {
+		return
+	}
+	if old != nil && old.Kind != n.Kind {
+		old = nil
+	}
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i, c := range n.Content {
+			var oc *yaml.Node
+			if old != nil && i < len(old.Content) {
+				oc = old.Content[i]
+			}
+			keepRefs(c, oc)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			var oldKey, oldValue *yaml.Node
+			if old != nil {
+				oldKey, oldValue = editedKey(old, n.Content[i].Value)
+			}
+			keepRefs(n.Content[i], oldKey)
+			keepRefs(n.Content[i+1], oldValue)
+		}
+	case yaml.ScalarNode:
+		if old != nil && strings.Contains(old.Value, "${") {
+			if old.Value == n.Value {
+				return
+			}
+			if v, err := expand(old.Value); err == nil && v == n.Value {
+				n.Value, n.Tag, n.Style = old.Value, old.Tag, old.Style
+				return
+			}
+		}
+		if strings.Contains(n.Value, "${") {
+			n.Value = strings.ReplaceAll(n.Value, "${", "$${")
+		}
+	}
+}
+
+// editedKey 在修改前的映射中查找键，键本身是引用时按替换后的值匹配
+func editedKey(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k := m.Content[i].Value
+		if k == key {
+			return m.Content[i], m.Content[i+1]
+		}
+		if strings.Contains(k, "${") {
+			if v, err := expand(k); err == nil && v == key {
+				return m.Content[i], m.Content[i+1]
+			}
+		}
+	}
+	return nil, nil
+}
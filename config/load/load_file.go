@@ -2,24 +2,33 @@ package load
 
 import (
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/logger"
-	"gopkg.in/yaml.v3"
+	"github.com/qist/tvgate/utils/ipacl"
 )
 
+// ParseFile 读取配置文件（合并 includes、替换 ${...} 引用）并解码，不影响当前运行的配置
+func ParseFile(path string, cfg *config.Config) error {
+	_, doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+	return decodeDocument(doc, cfg)
+}
+
 func LoadConfig(configPath string) error {
-	yamlData, err := os.ReadFile(configPath)
+	r, doc, err := readDocument(configPath)
 	if err != nil {
 		return err
 	}
+	files, patterns := r.files, r.patterns
 
 	var newCfg config.Config
-	err = yaml.Unmarshal(yamlData, &newCfg)
-	if err != nil {
+	if err := decodeDocument(doc, &newCfg); err != nil {
 		return err
 	}
 
@@ -27,16 +36,20 @@ func LoadConfig(configPath string) error {
 	if err := groupstats.ValidateConfig(newCfg.ProxyGroups); err != nil {
 		return fmt.Errorf("配置校验失败: %w", err)
 	}
+	// 访问控制列表无效时拒绝加载，避免规则被忽略后放行所有地址
+	if err := validateAccess(&newCfg.Access); err != nil {
+		return fmt.Errorf("配置校验失败: %w", err)
+	}
 
 	// trim iface names
-	cleaned := make([]string, 0, len(config.Cfg.Server.MulticastIfaces))
-	for _, n := range config.Cfg.Server.MulticastIfaces {
+	cleaned := make([]string, 0, len(newCfg.Server.MulticastIfaces))
+	for _, n := range newCfg.Server.MulticastIfaces {
 		n = strings.TrimSpace(n)
 		if n != "" {
 			cleaned = append(cleaned, n)
 		}
 	}
-	config.Cfg.Server.MulticastIfaces = cleaned
+	newCfg.Server.MulticastIfaces = cleaned
 
 	config.LogConfigMutex.Lock()
 	defer config.LogConfigMutex.Unlock()
@@ -45,22 +58,68 @@ func LoadConfig(configPath string) error {
 	groupstats.MergeProxyStats(config.Cfg.ProxyGroups, newCfg.ProxyGroups)
 	config.Cfg = newCfg
 
+	includeMu.Lock()
+	includeFiles, includePatterns = files, patterns
+	includeMu.Unlock()
+
 	// 初始化统计结构
 	groupstats.InitProxyGroups()
 
 	// 打印基本加载信息
 	logger.LogPrintf("✅ 配置文件已加载，代理组数量: %d", len(config.Cfg.ProxyGroups))
+	if len(files) > 0 {
+		logger.LogPrintf("📄 已引入配置文件: %v", files)
+	}
 	for groupName, group := range config.Cfg.ProxyGroups {
 		logger.LogPrintf("🔧 代理组: %s, 域名列表: %v", groupName, group.Domains)
 	}
 
-	logger.SetupLogger(logger.LogConfig{
-		Enabled:    config.Cfg.Log.Enabled,
-		File:       config.Cfg.Log.File,
-		MaxSizeMB:  config.Cfg.Log.MaxSizeMB,
-		MaxBackups: config.Cfg.Log.MaxBackups,
-		MaxAgeDays: config.Cfg.Log.MaxAgeDays,
-		Compress:   config.Cfg.Log.Compress,
-	})
+	SetupLogger()
+	return nil
+}
+
+// SetupLogger 按 config.Cfg.Log 设置程序日志；加载配置后尚未补全默认值，重复日志合并的默认值在这里处理
+func SetupLogger() {
+	lc := config.Cfg.Log
+	window, burst := lc.Dedup.Window, lc.Dedup.Burst
+	if window <= 0 {
+		window = time.Minute
+	}
+	if burst <= 0 {
+		burst = 10
+	}
+	if lc.Dedup.Disabled {
+		window = 0
+	}
+	if err := logger.SetupLogger(logger.LogConfig{
+		Enabled:     lc.Enabled,
+		File:        lc.File,
+		MaxSizeMB:   lc.MaxSizeMB,
+		MaxBackups:  lc.MaxBackups,
+		MaxAgeDays:  lc.MaxAgeDays,
+		Compress:    lc.Compress,
+		Level:       lc.Level,
+		Format:      lc.Format,
+		Modules:     lc.Modules,
+		DedupWindow: window,
+		DedupBurst:  burst,
+	}); err != nil {
+		logger.LogPrintf("⚠️ 日志级别配置无效，使用 info: %v", err)
+	}
+}
+
+// validateAccess 检查 access 中的 IP/网段列表
+func validateAccess(access *config.AccessConfig) error {
+	if _, err := ipacl.Parse(access.TrustedProxies); err != nil {
+		return fmt.Errorf("access.trusted_proxies: %w", err)
+	}
+	if _, err := ipacl.NewACL(access.Allow, access.Deny); err != nil {
+		return fmt.Errorf("access: %w", err)
+	}
+	for i, r := range access.Rules {
+		if _, err := ipacl.NewACL(r.Allow, r.Deny); err != nil {
+			return fmt.Errorf("access.rules[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
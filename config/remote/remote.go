@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/check"
+	"github.com/qist/tvgate/config/history"
+	"github.com/qist/tvgate/logger"
+)
+
+// errNotModified 远程配置自上次获取后没有变化
+var errNotModified = errors.New("not modified")
+
+// 单次请求超时，consul 阻塞查询另外加上等待时长
+const requestTimeout = 15 * time.Second
+
+// source 远程配置来源
+type source interface {
+	// fetch 获取配置内容及版本标识，版本与 last 相同时返回 errNotModified
+	fetch(ctx context.Context, last string) ([]byte, string, error)
+	// blocking 是否为阻塞查询（有变化或超时才返回），是则无需再按间隔等待
+	blocking() bool
+	String() string
+}
+
+// parse 解析远程配置地址：
+//
+//	http(s)://host/path          HTTP 地址，使用 ETag/Last-Modified 判断变化，?token= 作为 Bearer 令牌
+//	etcd://host:2379/key         etcd v3 HTTP 接口，用户名密码写在地址中，?tls=1 使用 HTTPS
+//	consul://host:8500/key       consul KV 阻塞查询，?token= 为 ACL token，?dc= 指定数据中心，?tls=1 使用 HTTPS
+func parse(raw string, interval time.Duration) (source, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	scheme := "http"
+	if q.Get("tls") == "1" || q.Get("tls") == "true" {
+		scheme = "https"
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	switch u.Scheme {
+	case "http", "https":
+		token := q.Get("token")
+		if token != "" {
+			q.Del("token")
+			u.RawQuery = q.Encode()
+		}
+		return &httpSource{url: u.String(), token: token}, nil
+	case "etcd":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("etcd 地址格式应为 etcd://host:2379/key")
+		}
+		return &etcdSource{endpoint: scheme + "://" + u.Host, key: "/" + key, user: u.User}, nil
+	case "consul":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("consul 地址格式应为 consul://host:8500/key")
+		}
+		return &consulSource{endpoint: scheme + "://" + u.Host, key: key, token: q.Get("token"), dc: q.Get("dc"), wait: interval}, nil
+	}
+	return nil, fmt.Errorf("不支持的远程配置类型: %s", u.Scheme)
+}
+
+// Sync 启动时获取一次远程配置，有变化且校验通过时写入本地缓存文件，返回是否写入；
+// 出错时调用方继续使用本地文件。此时日志尚未初始化，由调用方输出结果
+func Sync(raw, path string, interval time.Duration) (bool, error) {
+	src, err := parse(raw, interval)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	data, _, err := src.fetch(ctx, "")
+	if err != nil {
+		return false, fmt.Errorf("获取 %s 失败: %w", src, err)
+	}
+	return store(data, path)
+}
+
+// Watch 按间隔（consul 为阻塞查询）获取远程配置，有变化且校验通过时写入本地文件并立即重新加载
+func Watch(raw, path string, interval time.Duration, stopCh <-chan struct{}) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	src, err := parse(raw, interval)
+	if err != nil {
+		logger.LogPrintf("❌ 远程配置地址无效: %v", err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	logger.LogPrintf("🔄 开始监控远程配置: %s", src)
+	last := ""
+	failing := false
+	for {
+		wait := interval
+		data, version, err := src.fetch(ctx, last)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, errNotModified):
+			if src.blocking() {
+				wait = time.Second
+			}
+		case err != nil:
+			// 连续失败只记录一次，恢复后再提示
+			if !failing {
+				logger.LogPrintf("⚠️ 获取远程配置失败，继续使用本地缓存: %v", err)
+				failing = true
+			}
+		default:
+			if failing {
+				logger.LogPrintf("✅ 远程配置已恢复: %s", src)
+				failing = false
+			}
+			last = version
+			written, err := store(data, path)
+			if err != nil {
+				logger.LogPrintf("❌ 远程配置 %s 未应用: %v", src, err)
+			}
+			if written {
+				logger.LogPrintf("📦 远程配置已更新 (%s)，重新加载", version)
+				history.Default.SetNextSource("remote")
+				config.RequestReload()
+			}
+			if src.blocking() {
+				wait = time.Second
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// store 远程配置与本地文件不同时写入临时文件校验（includes 按本地路径解析），通过后替换本地文件，返回是否写入
+func store(data []byte, path string) (bool, error) {
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return false, nil
+	}
+	// 临时文件保持扩展名，按配置文件格式校验
+	f, err := os.CreateTemp(filepath.Dir(path), ".remote-*"+filepath.Ext(path))
+	if err != nil {
+		return false, fmt.Errorf("写入本地缓存失败: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("写入本地缓存失败: %w", err)
+	}
+	issues, err := check.File(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if check.HasErrors(issues) {
+		os.Remove(tmp)
+		for _, issue := range issues {
+			if !issue.Warning {
+				msg := issue.Message
+				if issue.Field != "" {
+					msg = issue.Field + ": " + msg
+				}
+				return false, fmt.Errorf("配置校验失败: 第 %d 行 %s", issue.Line, msg)
+			}
+		}
+	}
+
+	os.Chmod(tmp, 0644)
+	if err := os.Rename(tmp, path); err != nil {
+		// 单文件挂载（如 Docker）时无法重命名，直接覆盖写入
+		os.Remove(tmp)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return false, fmt.Errorf("写入本地缓存失败: %w", err)
+		}
+	}
+	return true, nil
+}
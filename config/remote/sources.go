@@ -0,0 +1,229 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 远程配置内容大小上限
+const maxConfigSize = 4 << 20
+
+var client = &http.Client{}
+
+// readBody 读取响应体，非 2xx 时返回包含状态码的错误
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("配置内容超过 %d 字节", maxConfigSize)
+	}
+	return data, nil
+}
+
+// httpSource 普通 HTTP(S) 地址
+type httpSource struct {
+	url   string
+	token string
+}
+
+func (s *httpSource) String() string { return s.url }
+
+func (s *httpSource) blocking() bool { return false }
+
+func (s *httpSource) fetch(ctx context.Context, last string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	// 版本为 ETag 或 Last-Modified，服务端不支持时为内容哈希
+	switch {
+	case strings.HasPrefix(last, "etag:"):
+		req.Header.Set("If-None-Match", strings.TrimPrefix(last, "etag:"))
+	case strings.HasPrefix(last, "modified:"):
+		req.Header.Set("If-Modified-Since", strings.TrimPrefix(last, "modified:"))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, last, errNotModified
+	}
+	data, err := readBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	version := ""
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		version = "etag:" + etag
+	} else if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		version = "modified:" + modified
+	} else {
+		sum := sha256.Sum256(data)
+		version = "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	if version == last {
+		return nil, last, errNotModified
+	}
+	return data, version, nil
+}
+
+// etcdSource etcd v3 的 HTTP 网关（/v3/kv/range），版本为键的 mod_revision
+type etcdSource struct {
+	endpoint string
+	key      string
+	user     *url.Userinfo
+}
+
+func (s *etcdSource) String() string { return "etcd " + s.endpoint + s.key }
+
+func (s *etcdSource) blocking() bool { return false }
+
+// post 调用 etcd 接口，返回 JSON 响应
+func (s *etcdSource) post(ctx context.Context, path, token string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (s *etcdSource) fetch(ctx context.Context, last string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// 开启认证时先用用户名密码换取 token
+	token := ""
+	if s.user != nil {
+		password, _ := s.user.Password()
+		var auth struct {
+			Token string `json:"token"`
+		}
+		body := map[string]string{"name": s.user.Username(), "password": password}
+		if err := s.post(ctx, "/v3/auth/authenticate", "", body, &auth); err != nil {
+			return nil, "", fmt.Errorf("etcd 认证失败: %w", err)
+		}
+		token = auth.Token
+	}
+
+	var result struct {
+		Kvs []struct {
+			Value       string `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	body := map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(s.key))}
+	if err := s.post(ctx, "/v3/kv/range", token, body, &result); err != nil {
+		return nil, "", err
+	}
+	if len(result.Kvs) == 0 {
+		return nil, "", fmt.Errorf("etcd 键 %s 不存在", s.key)
+	}
+	kv := result.Kvs[0]
+	if kv.ModRevision == last {
+		return nil, last, errNotModified
+	}
+	data, err := base64.StdEncoding.DecodeString(kv.Value)
+	if err != nil {
+		return nil, "", fmt.Errorf("解析 etcd 值失败: %w", err)
+	}
+	return data, kv.ModRevision, nil
+}
+
+// consulSource consul KV，使用阻塞查询（index/wait）在键变化时立即返回，版本为 X-Consul-Index
+type consulSource struct {
+	endpoint string
+	key      string
+	token    string
+	dc       string
+	wait     time.Duration
+}
+
+func (s *consulSource) String() string { return "consul " + s.endpoint + "/" + s.key }
+
+func (s *consulSource) blocking() bool { return true }
+
+func (s *consulSource) fetch(ctx context.Context, last string) ([]byte, string, error) {
+	q := url.Values{}
+	q.Set("raw", "")
+	if s.dc != "" {
+		q.Set("dc", s.dc)
+	}
+	timeout := requestTimeout
+	if last != "" {
+		q.Set("index", last)
+		q.Set("wait", strconv.Itoa(int(s.wait/time.Second))+"s")
+		timeout += s.wait
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint+"/v1/kv/"+s.key+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", fmt.Errorf("consul 键 %s 不存在", s.key)
+	}
+	data, err := readBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	index := resp.Header.Get("X-Consul-Index")
+	if index != "" && index == last {
+		return nil, last, errNotModified
+	}
+	return data, index, nil
+}
@@ -0,0 +1,613 @@
+// Code generated by go generate; DO NOT EDIT.
+
+package schema
+
+// descriptions 字段说明，键为 类型名.字段名（匿名结构体继续追加字段名）
+var descriptions = map[string]string{
+	"ACMEConfig.CacheDir":                   "证书与账号密钥缓存目录，默认配置文件目录下的 acme",
+	"ACMEConfig.DirectoryURL":               "ACME 服务地址，默认 Let's Encrypt 正式环境",
+	"ACMEConfig.Domains":                    "申请证书的域名，只为这些域名签发",
+	"ACMEConfig.Email":                      "账号联系邮箱，用于接收证书到期提醒",
+	"ACMEConfig.Enabled":                    "启用自动证书",
+	"APIKey.Allow":                          "允许使用该密钥的 IP/CIDR，为空不限制",
+	"APIKey.Key":                            "密钥",
+	"APIKey.Name":                           "名称，用于日志和配置版本历史的来源",
+	"APIKey.Role":                           "权限，同 users 的角色，默认 viewer",
+	"AccessConfig.Allow":                    "允许的 IP/网段，为空不限制",
+	"AccessConfig.Deny":                     "拒绝的 IP/网段，优先于 allow",
+	"AccessConfig.Rules":                    "按域名/路径前缀的规则",
+	"AccessConfig.TrustedProxies":           "可信反向代理 IP/网段，仅信任来自这些地址的 X-Forwarded-For / X-Real-IP；为空时访问控制与封禁只使用直连地址",
+	"AccessLogConfig.Compress":              "压缩轮转文件",
+	"AccessLogConfig.Enabled":               "是否启用",
+	"AccessLogConfig.Exclude":               "不记录的路径前缀，如 /status",
+	"AccessLogConfig.File":                  "日志文件，为空输出到标准输出",
+	"AccessLogConfig.Format":                "combined（Apache combined 格式，末尾附加耗时秒数）或 json，默认 combined",
+	"AccessLogConfig.MaxAgeDays":            "轮转文件最多保留天数，0 不限制",
+	"AccessLogConfig.MaxBackups":            "最多保留的轮转文件数，0 不限制",
+	"AccessLogConfig.MaxSizeMB":             "单个文件最大大小(MB)，默认 100",
+	"AccessRule.Allow":                      "允许的 IP/网段",
+	"AccessRule.Deny":                       "拒绝的 IP/网段，优先于 allow",
+	"AccessRule.Host":                       "请求域名，为空匹配全部",
+	"AccessRule.PathPrefix":                 "路径前缀，为空匹配全部",
+	"AlertConfig.Enabled":                   "是否启用",
+	"AlertConfig.For":                       "异常持续多久才告警，避免短暂波动，默认 1m",
+	"AlertConfig.Interval":                  "检查间隔，默认 30s",
+	"AlertConfig.Repeat":                    "告警未恢复时重复通知的间隔，0 只通知一次",
+	"AlertConfig.Rules":                     "检查项",
+	"AlertConfig.Sinks":                     "通知方式，可配置多个",
+	"AlertRules.ChannelStall":               "有客户端观看的频道停止收到数据（如组播源中断）",
+	"AlertRules.DiskPercent":                "任一磁盘分区使用率超过该百分比，0 不检查",
+	"AlertRules.MemoryPercent":              "系统内存使用率超过该百分比，0 不检查",
+	"AlertRules.ProxyGroupDown":             "代理组内全部代理不可用",
+	"AlertSink.APIURL":                      "Bot API 地址，默认 https://api.telegram.org，可改为反向代理地址",
+	"AlertSink.BotToken":                    "Bot token",
+	"AlertSink.ChatID":                      "接收消息的会话 ID",
+	"AlertSink.From":                        "发件人，默认为 username",
+	"AlertSink.Headers":                     "附加的请求头，如 Authorization",
+	"AlertSink.Name":                        "名称，仅用于日志",
+	"AlertSink.Password":                    "SMTP 密码",
+	"AlertSink.SMTP":                        "SMTP 服务器 host:port，465 端口使用 TLS，其他端口支持时使用 STARTTLS",
+	"AlertSink.To":                          "收件人",
+	"AlertSink.Type":                        "webhook、telegram 或 email",
+	"AlertSink.URL":                         "webhook 地址",
+	"AlertSink.Username":                    "SMTP 用户名，为空不认证",
+	"AuditConfig.Enabled":                   "是否启用",
+	"AuditConfig.File":                      "持久化文件（JSON Lines），为空只保存在内存中",
+	"AuditConfig.MaxEvents":                 "最多保留事件数，默认 100000",
+	"AuditConfig.Retention":                 "保留时长，默认 168h",
+	"AuthConfig.DynamicTokens":              "动态 token 配置",
+	"AuthConfig.JWTTokens":                  "JWT token 配置",
+	"AuthConfig.MaxSessions":                "每个 token 最大同时会话数，0 不限制",
+	"AuthConfig.MaxSessionsPerIP":           "每个 IP 最大同时会话数，0 不限制",
+	"AuthConfig.SessionLimitAction":         "超出限制时的处理：reject 拒绝新会话（默认），kick_oldest 踢掉最早的会话",
+	"AuthConfig.SignedURL":                  "签名 URL 配置",
+	"AuthConfig.StaticTokens":               "静态 token 列表",
+	"AuthConfig.TokenParamName":             "token 参数名",
+	"AuthConfig.TokensEnabled":              "是否启用 token",
+	"BandwidthConfig.Channels":              "按请求路径前缀限速，如 /udp/239.1.1.1:5000，最长前缀优先",
+	"BandwidthConfig.PerClientKbps":         "每个客户端默认限速",
+	"BandwidthConfig.Tokens":                "按 token 限速",
+	"BodyLimitRoute.MaxBodyKB":              "请求体最大大小(KB)，0 不限制",
+	"BodyLimitRoute.PathPrefix":             "路径前缀，如 /web/",
+	"BodyRewriteRule.ContentTypes":          "生效的内容类型（包含匹配），默认 text/ json javascript xml mpegurl",
+	"BodyRewriteRule.Match":                 "匹配内容（字符串或正则）",
+	"BodyRewriteRule.Regex":                 "match 是否为正则",
+	"BodyRewriteRule.Replace":               "替换内容，正则时可用 $1 引用捕获组",
+	"BruteForceConfig.BanDuration":          "首次封禁时长，默认 1m",
+	"BruteForceConfig.Enabled":              "是否启用",
+	"BruteForceConfig.MaxBanDuration":       "最长封禁时长，默认 24h；解封后超过该时长未再被封禁则重新从 ban_duration 计",
+	"BruteForceConfig.MaxFailures":          "时间窗口内允许的失败次数，默认 5",
+	"BruteForceConfig.Whitelist":            "不受限制的 IP/网段",
+	"BruteForceConfig.Window":               "失败计数时间窗口，默认 10m",
+	"CORSPathPolicy.Disabled":               "该路径不返回跨域头",
+	"CORSPathPolicy.PathPrefix":             "路径前缀，如 /web/api/",
+	"CORSPolicy.AllowCredentials":           "允许携带 Cookie 等凭据，只对 allow_origins 中列出的具体来源生效，按 * 允许时不返回凭据",
+	"CORSPolicy.AllowHeaders":               "允许的请求头，默认允许预检请求中列出的请求头",
+	"CORSPolicy.AllowMethods":               "允许的方法，默认 GET, HEAD, POST, PUT, DELETE, OPTIONS",
+	"CORSPolicy.AllowOrigins":               "允许的来源，默认 *，支持通配如 https://*.example.com",
+	"CORSPolicy.ExposeHeaders":              "页面脚本可读取的响应头，如 Content-Length、Content-Range",
+	"CORSPolicy.MaxAge":                     "浏览器缓存预检结果的时间，默认 10m",
+	"CastConfig.BaseURL":                    "设备访问 TVGate 的地址，如 http://192.168.1.2:8888，默认使用本机地址与代理端口",
+	"CastConfig.Discovery":                  "发现设备的等待时间，默认 3s",
+	"CastConfig.Enabled":                    "是否启用",
+	"CastConfig.Timeout":                    "连接设备与发送指令的超时，默认 10s",
+	"CastConfig.TokenTTL":                   "投屏 token 的有效期，默认 12h",
+	"CatchupChannel.Direct":                 "直接重定向到上游地址，默认经 TVGate 代理访问",
+	"CatchupChannel.Name":                   "回看地址中的频道名",
+	"CatchupChannel.Stream":                 "publisher 中开启了 hls_enable_playback 的流名称，使用本地缓存的分片回看",
+	"CatchupChannel.Template":               "上游回看地址模板，支持 ${start} ${end} ${duration} ${(b)yyyyMMddHHmmss} ${(e)yyyyMMddHHmmss} 等占位符",
+	"CatchupConfig.Channels":                "支持回看的频道",
+	"CatchupConfig.Enabled":                 "是否启用",
+	"CatchupConfig.MaxDuration":             "单次回看的最大时长，默认 24h",
+	"CatchupConfig.Path":                    "访问路径前缀，默认 /timeshift",
+	"CatchupConfig.Timezone":                "解析和生成 20060102150405 格式时间使用的时区，默认 Asia/Shanghai",
+	"ChannelInfo.Addr":                      "上游地址，如 rtp://239.3.1.1:8000 或 rtsp://…，状态页据此识别正在播放的频道",
+	"ChannelInfo.EPGID":                     "节目单 ID，写入 tvg-id",
+	"ChannelInfo.Group":                     "分组，写入 group-title",
+	"ChannelInfo.Logo":                      "台标地址，写入 tvg-logo",
+	"ChannelInfo.Name":                      "频道名，与播放列表中的频道名对应",
+	"ChannelInfo.Number":                    "频道号，写入 tvg-chno，同一分组内按频道号排序",
+	"ChannelInfo.Resume":                    "断线续播窗口：携带同一 token 在该时间内重新连接时从断开处继续播放，未携带 token 的请求不续播，0 关闭；仅组播频道",
+	"ChannelsConfig.Groups":                 "分组顺序，未列出的分组按出现顺序排在后面",
+	"ChannelsConfig.List":                   "频道信息",
+	"ClusterConfig.Fallback":                "所有源站都不可用时由边缘节点自己回源",
+	"ClusterConfig.HealthInterval":          "源站健康检查间隔，默认 10s",
+	"ClusterConfig.Origins":                 "边缘节点：源站地址，按顺序优先，如 http://origin.example.com:8888",
+	"ClusterConfig.Path":                    "源站上供边缘节点健康检查与校验 token 的接口前缀，默认 /cluster/",
+	"ClusterConfig.Paths":                   "边缘节点经源站拉取的路径前缀，默认 /rtp/ /udp/ /rtsp/",
+	"ClusterConfig.Protocol":                "回源协议 h2c/h2/h3/h1，默认 h2c，HTTP/2 与 HTTP/3 下所有频道复用一条连接",
+	"ClusterConfig.Role":                    "origin 源站、edge 边缘节点，留空不启用",
+	"ClusterConfig.Secret":                  "集群共享密钥，源站与边缘节点必须相同",
+	"ClusterConfig.Timeout":                 "连接源站与等待响应头的超时，默认 10s",
+	"ClusterConfig.TokenCache":              "边缘节点缓存源站 token 校验结果的时长，默认 1m",
+	"ClusterConfig.URLTemplate":             "回源地址模板，默认 {origin}{path}，可用 {origin} {path} {addr} {query}",
+	"Config.Access":                         "客户端 IP 访问控制",
+	"Config.AccessLog":                      "访问日志，与程序日志分开输出",
+	"Config.Alert":                          "告警通知",
+	"Config.Audit":                          "token 使用审计日志",
+	"Config.Bandwidth":                      "客户端带宽限制",
+	"Config.BruteForce":                     "暴力破解防护",
+	"Config.Cast":                           "投屏到 Chromecast / AirPlay",
+	"Config.Catchup":                        "回看地址",
+	"Config.Channels":                       "频道分组与频道信息",
+	"Config.Cluster":                        "源站/边缘节点集群",
+	"Config.ConnLimit":                      "流媒体并发连接数限制",
+	"Config.DLNA":                           "DLNA/UPnP 媒体服务器",
+	"Config.DNS":                            "DNS配置",
+	"Config.DVR":                            "定时录制",
+	"Config.Debug":                          "运行时诊断接口",
+	"Config.DomainMap":                      "域名映射配置",
+	"Config.EPG":                            "XMLTV 节目单",
+	"Config.ErrorPages":                     "错误响应页面",
+	"Config.Github":                         "GitHub 加速配置",
+	"Config.GlobalAuth":                     "全局认证配置",
+	"Config.HLS":                            "HLS 代理配置",
+	"Config.HTTP.ConnectTimeout":            "TCP连接超时",
+	"Config.HTTP.CookieJar":                 "保存上游下发的 Cookie",
+	"Config.HTTP.DisableKeepAlives":         "禁用keepalive",
+	"Config.HTTP.ExpectContinueTimeout":     "100-continue 超时",
+	"Config.HTTP.IdleConnTimeout":           "空闲连接超时",
+	"Config.HTTP.KeepAlive":                 "TCP保活",
+	"Config.HTTP.MaxConnsPerHost":           "每个主机的最大连接数",
+	"Config.HTTP.MaxIdleConns":              "最大空闲连接数",
+	"Config.HTTP.MaxIdleConnsPerHost":       "每个主机的最大空闲连接数",
+	"Config.HTTP.Profiles":                  "按域名/路径设置上游请求头，按顺序第一个匹配的生效",
+	"Config.HTTP.Protocols":                 "按域名指定上游协议 h1/h2/h2c/h3，匹配子域名",
+	"Config.HTTP.ResponseHeaderTimeout":     "等响应头超时",
+	"Config.HTTP.TLSHandshakeTimeout":       "TLS握手超时",
+	"Config.HTTP.Timeout":                   "整体请求超时 (0 = 不限制)",
+	"Config.HeaderRules":                    "全局请求/响应头改写规则",
+	"Config.Health":                         "容器编排健康检查接口",
+	"Config.History":                        "配置版本历史",
+	"Config.Includes":                       "引入的其它配置文件，支持通配符，相对路径相对于当前文件所在目录",
+	"Config.Ingest":                         "HTTP / RTMP 推流接入",
+	"Config.JX":                             "视频解析配置",
+	"Config.Language":                       "Web 界面、状态页与接口错误信息的语言 zh/en，为空按浏览器 Accept-Language 选择",
+	"Config.Log.Compress":                   "启用压缩",
+	"Config.Log.Dedup":                      "重复日志合并：窗口内与上一条完全相同的日志省略，同一格式（如不同组播源的读取错误）最多输出 burst 条， 窗口结束时输出一条\"已省略 N 条\"的汇总，避免组播源中断等持续出错时日志文件很快写满",
+	"Config.Log.Dedup.Burst":                "同一格式的日志每个窗口最多输出条数，默认 10",
+	"Config.Log.Dedup.Disabled":             "关闭合并，每条日志都输出",
+	"Config.Log.Dedup.Window":               "合并窗口，默认 1m",
+	"Config.Log.Enabled":                    "启用日志",
+	"Config.Log.File":                       "日志文件",
+	"Config.Log.Format":                     "输出格式：text 或 json（每行一个 JSON 对象），默认 text",
+	"Config.Log.Level":                      "日志级别：debug、info、warn、error，默认 info",
+	"Config.Log.MaxAgeDays":                 "最大保留天数",
+	"Config.Log.MaxBackups":                 "最大备份数量",
+	"Config.Log.MaxSizeMB":                  "日志文件最大大小",
+	"Config.Log.Modules":                    "按模块覆盖日志级别，模块为包路径，如 stream: debug、config/remote: warn；子模块继承上级设置",
+	"Config.Logo":                           "频道图标缓存",
+	"Config.Metrics":                        "指标推送（InfluxDB / Graphite）",
+	"Config.Middleware":                     "中间件链配置",
+	"Config.Monitor.Path":                   "监控路径",
+	"Config.Probe":                          "频道音视频参数探测",
+	"Config.ProxyGroups":                    "代理组配置",
+	"Config.Publisher":                      "推流配置",
+	"Config.Quota":                          "token 流量配额",
+	"Config.Refresh":                        "定期获取上游播放地址",
+	"Config.Reload":                         "添加 Reload 字段",
+	"Config.Remux":                          "组播频道的 TS 重封装规则",
+	"Config.Server.CacheMemoryMB":           "所有 Hub 缓存共用的内存上限(MB)，默认 512",
+	"Config.Server.CertFile":                "TLS证书文件",
+	"Config.Server.FccCacheSize":            "FCC缓存大小，默认16384",
+	"Config.Server.FccListenPortMax":        "FCC监听端口范围最大值",
+	"Config.Server.FccListenPortMin":        "FCC监听端口范围最小值",
+	"Config.Server.FccType":                 "FCC类型: telecom, huawei",
+	"Config.Server.HTTPPort":                "HTTP 可配置端口",
+	"Config.Server.HTTPToHTTPS":             "HTTP 跳转 HTTPS",
+	"Config.Server.KeyFile":                 "TLS私钥文件",
+	"Config.Server.Limits":                  "请求大小限制",
+	"Config.Server.Listeners":               "额外的监听地址，可分别指定提供的功能",
+	"Config.Server.McastIdleTimeout":        "组播源超过该时长未收到数据时重新加入组播组，0 表示禁用",
+	"Config.Server.McastRejoinInterval":     "多播重连间隔时间",
+	"Config.Server.MulticastIfaces":         "多播网卡",
+	"Config.Server.Port":                    "旧端口",
+	"Config.Server.RawRTP":                  "原样转发 RTP 包的组播地址 ip:port 或 ip，* 匹配所有",
+	"Config.Server.SSLCiphers":              "支持的TLS加密算法",
+	"Config.Server.SSLECDHCurve":            "支持的TLS曲线",
+	"Config.Server.SSLProtocols":            "支持的TLS协议版本",
+	"Config.Server.TLS":                     "TLS 配置",
+	"Config.Server.Timeouts":                "服务端超时",
+	"Config.Server.WriteBatchKB":            "组播转 HTTP 时合并写出的大小(KB)，默认 64",
+	"Config.Server.WriteBatchLatency":       "未凑满一批时最长等待时间，默认 50ms",
+	"Config.Stats":                          "频道与代理组历史统计",
+	"Config.Store":                          "多实例共享的 token、会话与封禁存储",
+	"Config.Tracing":                        "链路追踪",
+	"Config.Web.APIKeys":                    "/api/v1 接口密钥，与 Web 登录账号分开管理",
+	"Config.Web.APIToken":                   "接口访问令牌，外部系统通过 Authorization: Bearer 调用 token 管理接口",
+	"Config.Web.Enabled":                    "启用Web管理界面",
+	"Config.Web.Password":                   "Web管理密码",
+	"Config.Web.Path":                       "Web管理路径，默认为/web/",
+	"Config.Web.Username":                   "Web管理用户名",
+	"Config.Web.Users":                      "多账号，username/password 仍作为管理员账号保留",
+	"ConnLimitConfig.Action":                "超出限制时的处理：reject 返回 503（默认），queue 排队等待空位",
+	"ConnLimitConfig.Channels":              "按请求路径前缀设置单个频道的上限，最长前缀优先，覆盖 per_channel",
+	"ConnLimitConfig.MaxConns":              "全局最大并发连接数，0 不限制",
+	"ConnLimitConfig.PerChannel":            "单个频道默认最大连接数，0 不限制；HLS/DASH 的播放列表与分片按所在目录计为一个频道",
+	"ConnLimitConfig.QueueTimeout":          "排队等待的最长时间，超时返回 503，默认 10s",
+	"CookieJarConfig.Domains":               "只保存这些上游域名的 Cookie，匹配子域名，为空保存全部",
+	"CookieJarConfig.Enabled":               "是否启用",
+	"CookieJarConfig.File":                  "保存文件，默认为配置文件所在目录下的 upstream_cookies.json",
+	"DLNAConfig.BaseURL":                    "客户端访问 TVGate 的地址，如 http://192.168.1.2:8888，默认使用本机地址与代理端口",
+	"DLNAConfig.Enabled":                    "是否启用",
+	"DLNAConfig.FriendlyName":               "客户端中显示的服务器名称，默认 TVGate",
+	"DLNAConfig.Interfaces":                 "收发 SSDP 的网卡，默认所有支持组播的网卡",
+	"DLNAConfig.Path":                       "设备描述与控制接口的路径前缀，默认 /dlna",
+	"DLNAConfig.Playlists":                  "频道来源，m3u 或 txt 播放列表（http(s) 地址或本地文件），地址改写为经 TVGate 代理访问",
+	"DLNAConfig.Refresh":                    "重新读取播放列表的间隔，默认 1h",
+	"DLNAConfig.Timeout":                    "读取播放列表超时，默认 30s",
+	"DLNAConfig.Token":                      "附加到频道地址的 token，启用访问认证时需要配置",
+	"DLNAConfig.UUID":                       "设备 UUID，默认按主机名生成，保持不变以免客户端出现重复的设备",
+	"DNSConfig.CacheSize":                   "解析缓存条目数，默认 4096，负数关闭缓存",
+	"DNSConfig.Hosts":                       "静态 hosts，优先于 DNS 查询，如 {\"example.com\": [\"1.2.3.4\"]}",
+	"DNSConfig.MaxConns":                    "最大连接数",
+	"DNSConfig.MaxTTL":                      "缓存最长时间，默认 10m",
+	"DNSConfig.MinTTL":                      "缓存最短时间，默认 5s",
+	"DNSConfig.Servers":                     "DNS服务器列表",
+	"DNSConfig.Timeout":                     "DNS查询超时时间",
+	"DVRConfig.BaseURL":                     "拉取频道使用的 TVGate 地址，默认 http://127.0.0.1:代理端口",
+	"DVRConfig.Dir":                         "录制文件目录，默认为配置文件所在目录下的 recordings",
+	"DVRConfig.Enabled":                     "是否启用",
+	"DVRConfig.File":                        "定时任务与录制记录的保存文件，默认为配置文件所在目录下的 dvr.json",
+	"DVRConfig.MaxConcurrent":               "同时进行的录制数上限，时间重叠超出上限时优先录制 priority 大的，默认 2",
+	"DVRConfig.MinFreeMB":                   "录制目录所在磁盘的最小剩余空间（MB），低于时不开始新录制并停止进行中的录制，默认 1024",
+	"DVRConfig.Post":                        "录制完成后的处理",
+	"DVRConfig.PostPadding":                 "按节目录制时延后结束的时长，默认 2m",
+	"DVRConfig.PrePadding":                  "按节目录制时提前开始的时长，默认 1m",
+	"DVRConfig.Retry":                       "录制中断后重新连接的间隔，默认 5s",
+	"DVRConfig.VOD":                         "录制点播",
+	"DVRPostConfig.Command":                 "外部命令及参数，可用 {file} {thumbnail} {name} {channel} {title} {id}，录制信息 JSON 从标准输入传入",
+	"DVRPostConfig.FFmpeg":                  "ffmpeg 程序，默认在 PATH 中查找",
+	"DVRPostConfig.Headers":                 "webhook 附加的请求头，如 Authorization",
+	"DVRPostConfig.KeepTS":                  "转封装后保留原 TS 文件",
+	"DVRPostConfig.MoveTo":                  "移动到的目录，可用 {name} {channel} {title} {date}，如 /media/tv/{name}",
+	"DVRPostConfig.Remux":                   "无损转封装为 MP4",
+	"DVRPostConfig.Thumbnail":               "生成与录制文件同名的 .jpg 缩略图",
+	"DVRPostConfig.ThumbnailAt":             "截取缩略图的时间点，默认 1m，录制短于该时长时取中间",
+	"DVRPostConfig.Timeout":                 "每一步的超时，默认 30m",
+	"DVRPostConfig.Webhook":                 "POST 录制信息 JSON 的地址",
+	"DVRVODConfig.Enabled":                  "是否启用",
+	"DVRVODConfig.Path":                     "访问路径，默认 /vod/",
+	"DVRVODConfig.SegmentDuration":          "HLS 分片目标时长，默认 6s",
+	"DebugConfig.BlockProfileRate":          "阻塞采样率（纳秒），>0 时 block 分析有数据，会增加开销，0 关闭",
+	"DebugConfig.Enabled":                   "是否开启诊断接口",
+	"DebugConfig.MutexProfileFraction":      "锁竞争采样比例 1/n，>0 时 mutex 分析有数据，0 关闭",
+	"DomainMapConfig.Auth":                  "动态/静态 token 配置",
+	"DomainMapConfig.BodyRewrite":           "响应体改写规则（文本/JSON/m3u8）",
+	"DomainMapConfig.ClientHeaders":         "前端请求使用",
+	"DomainMapConfig.ConnectTimeout":        "建立连接超时，默认使用 http.connect_timeout",
+	"DomainMapConfig.FailoverCooldown":      "失败目标的下线时长，默认 30s",
+	"DomainMapConfig.HeaderRules":           "该映射的头部改写规则，在全局规则之后执行",
+	"DomainMapConfig.Name":                  "配置名称",
+	"DomainMapConfig.PathPrefix":            "源路径前缀，为空匹配全部路径；同一域名下最长前缀优先",
+	"DomainMapConfig.Protocol":              "协议 http/https",
+	"DomainMapConfig.ResponseHeaderTimeout": "等待响应头超时，默认使用 http.response_header_timeout",
+	"DomainMapConfig.Retries":               "请求失败重试次数，经由代理组时覆盖组的 max_retries",
+	"DomainMapConfig.RetryDelay":            "重试间隔",
+	"DomainMapConfig.ServerHeaders":         "后端请求使用",
+	"DomainMapConfig.Source":                "源域名",
+	"DomainMapConfig.StripPrefix":           "转发时去掉 path_prefix",
+	"DomainMapConfig.TLS":                   "后端 TLS 配置",
+	"DomainMapConfig.Target":                "目标域名",
+	"DomainMapConfig.TargetPath":            "转发时拼接到路径前的前缀",
+	"DomainMapConfig.Targets":               "备用目标，主目标失败（连接错误或 5xx）时按顺序切换",
+	"DomainMapConfig.Timeout":               "整个请求超时，默认使用 http.timeout",
+	"DomainMapTLSConfig.CAFile":             "自定义 CA 证书（PEM）",
+	"DomainMapTLSConfig.CertFile":           "客户端证书（双向 TLS）",
+	"DomainMapTLSConfig.InsecureSkipVerify": "跳过证书校验",
+	"DomainMapTLSConfig.KeyFile":            "客户端证书私钥",
+	"DomainMapTLSConfig.ServerName":         "覆盖 SNI 与证书校验使用的域名",
+	"DynamicToken.Allow":                    "允许访问的频道名或路径模式，为空不限制",
+	"DynamicToken.Deny":                     "禁止访问的频道名或路径模式，优先于 allow",
+	"DynamicToken.DynamicTTL":               "动态 token 有效期，例如 1h",
+	"DynamicToken.EnableDynamic":            "是否启用动态 token",
+	"DynamicToken.Salt":                     "salt",
+	"DynamicToken.Secret":                   "AES key",
+	"EPGChannel.ID":                         "输出的频道 id，与播放列表中的 tvg-id 一致",
+	"EPGChannel.Match":                      "匹配源中的频道 id 或显示名称（不区分大小写），留空按 id 匹配",
+	"EPGChannel.Name":                       "显示名称，留空沿用源中的名称",
+	"EPGConfig.Channels":                    "输出的频道，留空输出全部频道",
+	"EPGConfig.Enabled":                     "是否启用",
+	"EPGConfig.File":                        "缓存文件，重启后先使用缓存，默认为配置文件所在目录下的 epg.xml.gz",
+	"EPGConfig.Headers":                     "拉取时附加的请求头",
+	"EPGConfig.Path":                        "访问路径，默认 /xmltv.xml",
+	"EPGConfig.Refresh":                     "刷新间隔，默认 6h",
+	"EPGConfig.Sources":                     "XMLTV 源：http(s) 地址或本地文件，支持 gzip 压缩，同一频道以靠前的源为准",
+	"EPGConfig.Timeout":                     "单个源的拉取超时，默认 60s",
+	"ErrorPagesConfig.Pages":                "按状态码的模板文件，优先于 template，如 404: /etc/tvgate/404.html",
+	"ErrorPagesConfig.Template":             "HTML 错误页模板文件，可用 {{.Status}} {{.StatusText}} {{.Message}} {{.Path}}，为空使用内置页面",
+	"FFmpegOptions.AudioBitrate":            "音频码率",
+	"FFmpegOptions.AudioCodec":              "音频编码器",
+	"FFmpegOptions.CRF":                     "CRF值",
+	"FFmpegOptions.CustomArgs":              "自定义参数",
+	"FFmpegOptions.Filters":                 "滤镜配置",
+	"FFmpegOptions.GlobalArgs":              "全局参数",
+	"FFmpegOptions.GopSize":                 "GOP大小",
+	"FFmpegOptions.Headers":                 "自定义请求头",
+	"FFmpegOptions.InputPostArgs":           "输入后参数",
+	"FFmpegOptions.InputPreArgs":            "输入前参数",
+	"FFmpegOptions.OutputFormat":            "封装格式",
+	"FFmpegOptions.OutputPostArgs":          "输出后参数",
+	"FFmpegOptions.OutputPreArgs":           "输出前参数",
+	"FFmpegOptions.PixFmt":                  "像素格式，如 yuv420p",
+	"FFmpegOptions.Preset":                  "编码预设",
+	"FFmpegOptions.StreamCopy":              "流复制模式（不重新编码）",
+	"FFmpegOptions.UseReFlag":               "是否使用-re参数（以本地帧速率读取输入）",
+	"FFmpegOptions.UserAgent":               "User-Agent",
+	"FFmpegOptions.VideoBitrate":            "视频码率",
+	"FFmpegOptions.VideoCodec":              "视频编码器",
+	"FallbackHop.Group":                     "代理组名称，direct 表示直连",
+	"FallbackHop.Retries":                   "本跳重试次数，默认1",
+	"FallbackHop.RetryDelay":                "本跳重试间隔",
+	"FallbackHop.Timeout":                   "本跳单次请求等待响应头超时，默认10s",
+	"FilterOptions.AudioFilters":            "音频滤镜链",
+	"FilterOptions.VideoFilters":            "视频滤镜链",
+	"GithubConfig.AutoCheck":                "定期检查新版本，发现后在管理界面提示",
+	"GithubConfig.BackupURLs":               "备用加速地址",
+	"GithubConfig.CheckInterval":            "检查间隔，默认 24h",
+	"GithubConfig.Enabled":                  "是否启用",
+	"GithubConfig.Retry":                    "最大重试次数",
+	"GithubConfig.Timeout":                  "请求超时时间",
+	"GithubConfig.URL":                      "主加速地址",
+	"GroupStats.CurrentIndex":               "可用于未来其他策略",
+	"HLSConfig.PlaylistCacheTTL":            "改写后 m3u8 的缓存时间，负数表示关闭",
+	"HLSConfig.SegmentCache":                "分片缓存",
+	"HeaderOperations.Append":               "追加（保留同名头）",
+	"HeaderOperations.Remove":               "删除",
+	"HeaderOperations.Set":                  "设置（覆盖同名头）",
+	"HeaderRule.Path":                       "请求路径前缀，为空匹配全部",
+	"HeaderRule.Request":                    "发往上游的请求头",
+	"HeaderRule.Response":                   "返回客户端的响应头",
+	"HealthCheckConfig.Enabled":             "是否启用",
+	"HealthCheckConfig.Fall":                "连续失败多少次标记为不健康",
+	"HealthCheckConfig.Interval":            "检查间隔",
+	"HealthCheckConfig.Rise":                "连续成功多少次标记为健康",
+	"HealthCheckConfig.Timeout":             "单次检查超时",
+	"HealthCheckConfig.URL":                 "检查地址，通过代理访问",
+	"HealthConfig.Enabled":                  "是否启用",
+	"HealthConfig.HubIdle":                  "Hub 超过该时长没有收到数据视为不健康，默认 10s",
+	"HealthConfig.LivenessPath":             "存活检查路径，默认 /healthz，主流程卡住（如死锁）时返回 503",
+	"HealthConfig.MinHealthyHubs":           "就绪需要的最少健康 Hub 数（hub_idle 内收到过数据），0 不检查",
+	"HealthConfig.ReadinessPath":            "就绪检查路径，默认 /readyz，启动完成、全部端口已监听且 Hub 数满足要求时返回 200",
+	"HistoryConfig.Dir":                     "保存目录，默认为配置文件所在目录下的 config_history",
+	"HistoryConfig.MaxVersions":             "最多保留版本数，默认 50，-1 表示不记录",
+	"IngestConfig.Enabled":                  "是否启用",
+	"IngestConfig.IdleTimeout":              "超过该时长未收到数据时断开推流，默认 10s",
+	"IngestConfig.Path":                     "推流路径前缀，默认 /ingest/，推流地址为 前缀+名称",
+	"IngestConfig.RTMPListen":               "RTMP 推流监听地址，如 :1935，留空不接受 RTMP",
+	"IngestConfig.Streams":                  "推流点",
+	"IngestStream.Addr":                     "转发到的 UDP 地址 ip:port，组播或单播",
+	"IngestStream.Iface":                    "组播出口网卡，留空按路由选择",
+	"IngestStream.Name":                     "名称，用于推流地址",
+	"IngestStream.TTL":                      "组播 TTL，默认 0 只在本机分发，大于 0 时同时发到网络",
+	"IngestStream.Token":                    "推流密钥，请求头 Authorization: Bearer 密钥 或参数 token",
+	"JWTToken.Algorithm":                    "签名算法 HS256/RS256，默认 HS256",
+	"JWTToken.Audience":                     "校验 aud，为空不校验",
+	"JWTToken.EnableJWT":                    "是否启用 JWT token",
+	"JWTToken.Issuer":                       "校验 iss，为空不校验",
+	"JWTToken.Leeway":                       "exp/nbf 允许的时钟偏差",
+	"JWTToken.PublicKeyFile":                "RS256 公钥文件（PEM）",
+	"JWTToken.Secret":                       "HS256 密钥",
+	"JXBatchConfig.Concurrency":             "同时解析的数量，默认 4",
+	"JXBatchConfig.MaxItems":                "单次最多提交的数量，默认 100",
+	"JXBrowserConfig.Args":                  "启动浏览器的其它参数，如 --proxy-server=socks5://127.0.0.1:1080",
+	"JXBrowserConfig.BlockURLs":             "不加载的资源，支持 * 通配，默认不加载图片、字体与样式表",
+	"JXBrowserConfig.Endpoint":              "使用已运行的浏览器的调试地址，如 http://127.0.0.1:9222，设置后不启动浏览器",
+	"JXBrowserConfig.IdleTimeout":           "浏览器空闲多久后关闭，默认 5m",
+	"JXBrowserConfig.Instances":             "浏览器进程数，默认 1",
+	"JXBrowserConfig.MaxMemoryMB":           "页面 JavaScript 堆内存上限(MB)，默认 512",
+	"JXBrowserConfig.MaxPages":              "每个进程同时打开的页面数，默认 2，超出时排队",
+	"JXBrowserConfig.MaxUses":               "每个进程打开多少个页面后重启以释放内存，默认 100",
+	"JXBrowserConfig.NavigationTimeout":     "打开页面到取得结果的超时，默认 30s",
+	"JXBrowserConfig.Path":                  "Chromium 可执行文件，默认在 PATH 中查找 chromium、chromium-browser、google-chrome",
+	"JXConfig.APIGroups":                    "视频API组配置",
+	"JXConfig.Batch":                        "批量解析接口 路径/batch",
+	"JXConfig.Browser":                      "type 为 browser 的解析器使用的无头浏览器",
+	"JXConfig.CacheTTL":                     "成功的解析结果缓存时长，0 不缓存，解析器可单独设置",
+	"JXConfig.DefaultID":                    "默认视频ID",
+	"JXConfig.Path":                         "视频解析路径",
+	"JXConfig.RateLimit":                    "解析频率限制",
+	"JXConfig.Resolvers":                    "按名称配置视频网站解析器，与内置解析器同名时覆盖其配置",
+	"JXRateLimit.Client":                    "每个客户端 IP 每分钟最多解析次数（命中缓存的不计），0 不限制",
+	"JXRateLimit.Site":                      "每个解析器 / API 组每分钟最多请求次数，0 不限制，解析器可单独设置",
+	"JXResolverConfig.CacheTTL":             "该网站解析结果的缓存时长，覆盖 jx.cache_ttl，小于 0 不缓存",
+	"JXResolverConfig.Command":              "exec：程序及参数，可用 {url} {id}（占位参数前加 \"--\"，id 只允许字母、数字、下划线和点），options 以 JX_ 开头的环境变量传入",
+	"JXResolverConfig.Disabled":             "停用该解析器",
+	"JXResolverConfig.Domains":              "处理的网站域名，匹配子域名，内置解析器默认使用自带的域名",
+	"JXResolverConfig.Headers":              "http：请求头；browser：页面请求头，User-Agent 同时用于浏览器标识",
+	"JXResolverConfig.Match":                "browser：页面请求的地址匹配该正则时作为播放地址，未设置 script 时默认匹配 m3u8/mp4/flv",
+	"JXResolverConfig.Method":               "http：请求方法，默认 GET，POST 时请求体为 {\"url\",\"id\",\"options\"} JSON",
+	"JXResolverConfig.Options":              "传给解析器的其它参数",
+	"JXResolverConfig.RateLimit":            "该解析器每分钟最多请求次数，覆盖 jx.rate_limit.site，小于 0 不限制",
+	"JXResolverConfig.Script":               "browser：页面加载后执行的 JavaScript 表达式，返回播放地址或 {name,id,url,title}，可用 {url} {id}",
+	"JXResolverConfig.Timeout":              "解析超时，默认 10s，browser 默认为 jx.browser.navigation_timeout",
+	"JXResolverConfig.Type":                 "留空使用同名的内置解析器（youku、qq、iqiyi、mgtv、migu）；http 调用外部解析服务；exec 执行外部程序；browser 由无头浏览器打开页面",
+	"JXResolverConfig.URL":                  "http：解析服务地址，可用 {url} {id}",
+	"ListenerConfig.ACME":                   "使用 server.tls.acme 自动申请的证书",
+	"ListenerConfig.CertFile":               "证书路径，与 keyfile 同时配置时使用 HTTPS",
+	"ListenerConfig.EnableH3":               "同时提供 HTTP/3（仅 TLS 的 TCP 地址）",
+	"ListenerConfig.Features":               "提供的功能：monitor、web、jx、proxy，留空为全部",
+	"ListenerConfig.KeyFile":                "密钥路径",
+	"ListenerConfig.Listen":                 "监听地址，如 :8080、192.168.1.2:8080、unix:/run/tvgate.sock",
+	"ListenerConfig.Name":                   "名称，仅用于日志",
+	"LogoConfig.Dir":                        "缓存目录，默认为配置文件所在目录下的 logo_cache",
+	"LogoConfig.Enabled":                    "是否启用",
+	"LogoConfig.Logos":                      "频道名到图标地址，优先于播放列表和节目单；只有这里的地址可以是本地文件或内网地址",
+	"LogoConfig.Path":                       "访问路径前缀，默认 /logo",
+	"LogoConfig.Playlists":                  "读取 tvg-logo 的 m3u 播放列表（http(s) 地址或本地文件），按 tvg-id、tvg-name 和频道名匹配",
+	"LogoConfig.Size":                       "缩放后的最大边长（像素），默认 256，小于该尺寸的图标不放大",
+	"LogoConfig.TTL":                        "缓存有效期，过期后重新拉取，拉取失败时继续使用旧缓存，默认 168h",
+	"LogoConfig.Timeout":                    "拉取超时，默认 10s",
+	"MetricsConfig.Address":                 "推送地址：http(s)://（InfluxDB 写入接口）、tcp://host:port 或 udp://host:port",
+	"MetricsConfig.Enabled":                 "是否启用",
+	"MetricsConfig.Format":                  "influx 或 graphite，默认 influx",
+	"MetricsConfig.Headers":                 "HTTP 推送时附加的请求头，如 InfluxDB 2.x 的 Authorization: Token xxx",
+	"MetricsConfig.Interval":                "推送间隔，默认 10s",
+	"MetricsConfig.Prefix":                  "指标名前缀，默认 tvgate",
+	"MetricsConfig.Tags":                    "附加到所有指标的标签，如 host、region",
+	"MetricsConfig.Timeout":                 "推送超时，默认 5s",
+	"MiddlewareCORSConfig.Enabled":          "对所有路径启用，预检请求在 token 校验等中间件之前应答",
+	"MiddlewareCORSConfig.Paths":            "按路径前缀覆盖，最长前缀优先，未设置的项沿用全局策略",
+	"MiddlewareConfig.CORS":                 "cors 中间件参数",
+	"MiddlewareConfig.Global":               "对所有路径生效的中间件，在 routes 的链之前执行",
+	"MiddlewareConfig.RateLimit":            "rate_limit 中间件参数",
+	"MiddlewareConfig.Routes":               "按路径前缀配置的中间件链，最长前缀优先",
+	"MiddlewareRoute.Chain":                 "按顺序执行的中间件名称",
+	"MiddlewareRoute.PathPrefix":            "路径前缀，如 / 、/jx",
+	"PlayOutput.HlsEnablePlayback":          "是否开启回放模式",
+	"PlayOutput.HlsPath":                    "HLS文件存储路径",
+	"PlayOutput.HlsRetentionDays":           "TS 文件保留天数",
+	"PlayOutput.HlsSegmentCount":            "保留的HLS片段数量",
+	"PlayOutput.HlsSegmentDuration":         "HLS片段时长（秒）",
+	"PlayOutput.Protocol":                   "flv/hls/…",
+	"PlayOutput.TSFilenameTemplate":         "TS 文件名模板",
+	"ProbeConfig.Enabled":                   "是否启用",
+	"ProbeConfig.Interval":                  "同一频道重新探测的间隔，默认 10m",
+	"ProbeConfig.Timeout":                   "单次探测的最长时间，默认 10s",
+	"ProxyConfig.Disabled":                  "停用，保留配置但不参与选择和健康检查",
+	"ProxyConfig.Headers":                   "添加自定义headers支持",
+	"ProxyConfig.Name":                      "代理名称",
+	"ProxyConfig.Password":                  "代理密码 (可选)",
+	"ProxyConfig.Port":                      "代理端口",
+	"ProxyConfig.Server":                    "代理地址 (IP或域名)",
+	"ProxyConfig.Type":                      "代理类型 (http, https, socks5, socks4)",
+	"ProxyConfig.UDP":                       "UDP支持 (仅socks5)",
+	"ProxyConfig.Username":                  "代理用户名 (可选)",
+	"ProxyConfig.Weight":                    "权重 (weighted 策略使用，默认1)",
+	"ProxyGroupConfig.DNS":                  "代理组专用 DNS 服务器，格式同 dns.servers，为空使用全局 DNS",
+	"ProxyGroupConfig.DailyCapMB":           "每日流量上限(MB)，超出后当日跳过该组，0 不限制",
+	"ProxyGroupConfig.Domains":              "域名和IP规则列表(包含IPv4和IPv6)",
+	"ProxyGroupConfig.Fallback":             "回源降级链，按顺序尝试",
+	"ProxyGroupConfig.HealthCheck":          "主动健康检查",
+	"ProxyGroupConfig.IPv6":                 "IPv6 开关",
+	"ProxyGroupConfig.Interval":             "检查间隔时间(秒)",
+	"ProxyGroupConfig.LoadBalance":          "负载均衡方式",
+	"ProxyGroupConfig.MaxRT":                "最大响应时间",
+	"ProxyGroupConfig.MaxRetries":           "最大重试次数",
+	"ProxyGroupConfig.MonthlyCapMB":         "每月流量上限(MB)，超出后当月跳过该组，0 不限制",
+	"ProxyGroupConfig.Protocol":             "上游协议 h1/h2/h2c/h3，默认自动协商",
+	"ProxyGroupConfig.Proxies":              "代理服务器列表",
+	"ProxyGroupConfig.RetryDelay":           "重试延迟(秒)",
+	"ProxyGroupConfig.Stats":                "运行时统计信息",
+	"ProxyGroupConfig.StickyBy":             "粘性绑定依据 ip/token，默认 ip",
+	"ProxyGroupConfig.StickyTTL":            "粘性绑定时长，期间同一客户端固定使用同一代理，0 关闭",
+	"ProxyStats.ActiveConns":                "当前经由该代理的连接数（原子操作）",
+	"ProxyStats.Alive":                      "代理是否可用",
+	"ProxyStats.CooldownUntil":              "冷却时间，防止频繁重试",
+	"ProxyStats.FailCount":                  "测速失败次数",
+	"ProxyStats.HealthError":                "上次健康检查错误",
+	"ProxyStats.HealthFailures":             "连续失败次数",
+	"ProxyStats.HealthLastCheck":            "上次健康检查时间",
+	"ProxyStats.HealthRT":                   "上次健康检查耗时",
+	"ProxyStats.HealthStatus":               "up / down / 空表示未检查",
+	"ProxyStats.HealthSuccesses":            "连续成功次数",
+	"ProxyStats.LastCheck":                  "仅测速时更新",
+	"ProxyStats.LastUsed":                   "代理被用时更新",
+	"ProxyStats.ResponseTime":               "响应时间",
+	"ProxyStats.StatusCode":                 "测试返回状态码（HTTP/自定义）",
+	"ProxyStats.WeightCurrent":              "平滑加权轮询当前权重",
+	"PublisherConfig.Streams":               "注意：这里直接包含streams而不是嵌套在Streams字段中",
+	"QuotaConfig.DailyMB":                   "每个 token 默认每日配额(MB)，0 不限制",
+	"QuotaConfig.Enabled":                   "是否启用",
+	"QuotaConfig.MonthlyMB":                 "每个 token 默认每月配额(MB)，0 不限制",
+	"QuotaConfig.Tokens":                    "按 token 单独配置，覆盖默认配额",
+	"RateLimitConfig.Burst":                 "突发请求数",
+	"RateLimitConfig.RequestsPerSecond":     "每个客户端 IP 每秒请求数",
+	"ReceiverItem.FFmpegOptions":            "独立推流参数",
+	"RefreshChannel.API":                    "获取地址的接口，可用 {name} {timestamp}",
+	"RefreshChannel.Body":                   "接口请求体，可用 {name} {timestamp}",
+	"RefreshChannel.Command":                "外部命令及参数，以标准输出为结果，参数可用 {name} {timestamp}",
+	"RefreshChannel.Headers":                "接口请求头",
+	"RefreshChannel.JSONPath":               "从 JSON 结果中取值的路径，如 data.url 或 data.list.0.url，为空使用整个结果",
+	"RefreshChannel.Method":                 "接口请求方法，默认 GET，设置了 body 时默认 POST",
+	"RefreshChannel.Name":                   "频道名，访问地址为 路径前缀+频道名",
+	"RefreshChannel.TTL":                    "获取的值的有效期，默认 10m，剩余不到 1/5 时在后台重新获取",
+	"RefreshChannel.URL":                    "上游地址模板，{value} 替换为获取的值，为空时获取的值即为上游地址",
+	"RefreshConfig.Channels":                "需要刷新地址的频道",
+	"RefreshConfig.Enabled":                 "是否启用",
+	"RefreshConfig.Path":                    "访问路径前缀，默认 /refresh/",
+	"RefreshConfig.Timeout":                 "调用接口或外部命令的超时，默认 10s",
+	"RemuxRule.Match":                       "组播地址 ip:port 或 ip，* 匹配所有",
+	"RemuxRule.PIDMap":                      "PID 重映射：原 PID -> 新 PID，PAT 与 PMT 中的引用同步改写",
+	"RemuxRule.Program":                     "service_name / provider 只改写该节目号，0 表示所有节目",
+	"RemuxRule.Provider":                    "改写 SDT 中的提供商名称",
+	"RemuxRule.ServiceName":                 "改写 SDT 中的节目名称",
+	"RemuxRule.StripCA":                     "删除 PMT 中的 CA 描述符，并清除 SDT 的 free_CA_mode",
+	"RequestLimits.MaxBodyKB":               "请求体最大大小(KB)，默认 0 不限制，各接口另有自己的上限",
+	"RequestLimits.MaxHeaderKB":             "请求头最大大小(KB)，默认 1024，修改后重启生效",
+	"RequestLimits.MaxHeaders":              "请求头最多条数，默认 100",
+	"RequestLimits.MaxURLLength":            "请求地址（路径与查询参数）最大长度，默认 8192",
+	"RequestLimits.Routes":                  "按路径前缀覆盖请求体大小，最长前缀优先",
+	"RouteTimeout.Idle":                     "流式：超过该时长没有数据写给客户端时断开",
+	"RouteTimeout.PathPrefix":               "路径前缀，如 /udp/、/jx",
+	"RouteTimeout.Read":                     "读取请求体的超时，从收到请求头开始计算，读完后不再限制",
+	"RouteTimeout.Stream":                   "流式路由：write 为单次写入的超时，不限制总时长",
+	"RouteTimeout.Write":                    "非流式：从收到请求头到响应完成的总时长；流式：客户端接收一次写入的最长时间",
+	"SegmentCacheConfig.DiskPath":           "磁盘缓存目录，留空表示仅使用内存",
+	"SegmentCacheConfig.Enabled":            "启用分片缓存",
+	"SegmentCacheConfig.MaxEntryMB":         "单个分片大小上限(MB)，超过则不缓存",
+	"SegmentCacheConfig.MaxSizeMB":          "缓存总大小上限(MB)",
+	"SegmentCacheConfig.TTL":                "分片缓存时间",
+	"ServerTimeouts.Idle":                   "keep-alive 连接等待下一个请求的超时，默认 60s",
+	"ServerTimeouts.ReadHeader":             "读取请求头的超时，默认 10s",
+	"ServerTimeouts.Routes":                 "按路径前缀的请求超时",
+	"SignedURL.BindIP":                      "签名是否包含客户端 IP",
+	"SignedURL.EnableSigned":                "是否启用签名 URL",
+	"SignedURL.ExpiresParam":                "过期时间参数名（Unix 秒），默认 expires",
+	"SignedURL.Secret":                      "共享密钥",
+	"SignedURL.SigParam":                    "签名参数名，默认 sig",
+	"SourceData.FFmpegOptions":              "独立推流参数",
+	"StaticToken.Allow":                     "允许访问的频道名或路径模式，为空不限制",
+	"StaticToken.Deny":                      "禁止访问的频道名或路径模式，优先于 allow",
+	"StaticToken.EnableStatic":              "是否启用静态 token",
+	"StaticToken.ExpireHours":               "例如 30s, 24h",
+	"StaticToken.Token":                     "token 值",
+	"StatsConfig.Enabled":                   "是否启用",
+	"StatsConfig.File":                      "数据库文件，默认为配置文件所在目录下的 stats.db",
+	"StatsConfig.Interval":                  "采样间隔，默认 1m",
+	"StatsConfig.Retention":                 "保留时长，默认 168h",
+	"StoreConfig.Addr":                      "Redis 地址，默认 127.0.0.1:6379",
+	"StoreConfig.DB":                        "Redis 数据库编号",
+	"StoreConfig.Password":                  "Redis 密码，可选",
+	"StoreConfig.PoolSize":                  "最大空闲连接数，默认 8",
+	"StoreConfig.Prefix":                    "键名前缀，默认 tvgate:，多套部署共用 Redis 时用于区分",
+	"StoreConfig.SyncInterval":              "与共享存储同步的间隔，默认 5s",
+	"StoreConfig.Timeout":                   "连接与单条命令的超时，默认 3s",
+	"StoreConfig.Type":                      "留空只在本实例内保存；redis",
+	"StoreConfig.Username":                  "Redis 6 ACL 用户名，可选",
+	"StreamData.LocalPlayUrls":              "flv hls",
+	"StreamData.Mode":                       "\"primary-backup\" or \"all\"",
+	"StreamKey.Expiration":                  "过期时间（支持字符串格式，如\"24h\"）",
+	"StreamKey.Length":                      "for random type",
+	"StreamKey.Type":                        "\"random\", \"fixed\" or \"external\"",
+	"StreamKey.Value":                       "for fixed type",
+	"TLSConfig.ACME":                        "自动申请证书，启用后忽略 certfile、keyfile",
+	"TLSConfig.EnableH3":                    "新增 HTTP/3 开关",
+	"TokenQuota.DailyMB":                    "每日配额(MB)，0 不限制",
+	"TokenQuota.MonthlyMB":                  "每月配额(MB)，0 不限制",
+	"TracingConfig.Enabled":                 "是否启用",
+	"TracingConfig.Endpoint":                "OTLP/HTTP 导出地址，默认 http://127.0.0.1:4318/v1/traces",
+	"TracingConfig.Exclude":                 "不追踪的路径前缀，如 /web/",
+	"TracingConfig.Headers":                 "导出时附加的请求头，如认证信息",
+	"TracingConfig.Propagate":               "向上游请求附加 traceparent 头，上游也接入追踪时可串起完整链路",
+	"TracingConfig.SampleRatio":             "新链路的采样率 0~1，默认 1；请求带 traceparent 时按调用方的采样标记",
+	"TracingConfig.ServiceName":             "服务名，默认 tvgate，多实例时可区分",
+	"TracingConfig.Timeout":                 "导出超时，默认 10s",
+	"UpstreamProfile.Cookies":               "附加的 Cookie，如 a=1; b=2，与客户端的 Cookie 合并",
+	"UpstreamProfile.Domains":               "上游域名，匹配子域名，为空匹配全部",
+	"UpstreamProfile.Headers":               "其它请求头，覆盖同名头",
+	"UpstreamProfile.Origin":                "Origin",
+	"UpstreamProfile.Paths":                 "上游路径前缀（如某个频道），为空匹配全部",
+	"UpstreamProfile.Preset":                "预设的播放器请求头：aptv、tivimate、kodi、vlc、okhttp、exoplayer、chrome、safari",
+	"UpstreamProfile.Referer":               "Referer",
+	"UpstreamProfile.UserAgent":             "User-Agent，覆盖预设",
+	"VideoAPIGroupConfig.Endpoints":         "API接口列表",
+	"VideoAPIGroupConfig.Fallback":          "备用API标记",
+	"VideoAPIGroupConfig.Filters":           "过滤条件",
+	"VideoAPIGroupConfig.MaxRetries":        "最大重试次数",
+	"VideoAPIGroupConfig.Primary":           "主API标记",
+	"VideoAPIGroupConfig.QueryTemplate":     "查询模板",
+	"VideoAPIGroupConfig.Timeout":           "请求超时",
+	"VideoAPIGroupConfig.Weight":            "权重",
+	"WebUser.Role":                          "admin 全部权限；operator 可修改除认证外的配置；viewer 只读，默认 viewer",
+}
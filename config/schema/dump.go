@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"gopkg.in/yaml.v3"
+)
+
+// 结构体嵌套超过该层数时不再展开（避免自引用的类型无限展开）
+const maxDumpDepth = 12
+
+// DumpDefault 返回包含全部配置项的默认配置：值为 SetDefaults 填充后的默认值，每项上方附带说明；
+// 列表和映射为空时以注释给出一个元素的示例
+func DumpDefault() ([]byte, error) {
+	var cfg config.Config
+	cfg.SetDefaults()
+
+	d := &dumper{}
+	root := d.value(reflect.ValueOf(cfg), "Config", 0)
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: "TVGate 完整配置，由 dump-default 命令生成，值为默认值\n未列出的代理组、域名映射等示例以注释给出，按需取消注释后修改",
+		Content:     []*yaml.Node{root},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type dumper struct {
+	inExample bool // 正在生成示例，示例中不再嵌套示例
+}
+
+// value 将值转换为 YAML 节点，key 用于查找字段说明
+func (d *dumper) value(v reflect.Value, key string, depth int) *yaml.Node {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if v.Type().Elem().Kind() != reflect.Struct {
+				return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+			}
+			// 未设置的结构体也展开，显示其中的配置项
+			v = reflect.New(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == durationType {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: time.Duration(v.Int()).String()}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			break
+		}
+		m := &yaml.Node{Kind: yaml.MappingNode}
+		if depth >= maxDumpDepth {
+			m.Style = yaml.FlowStyle
+			return m
+		}
+		if v.Type().Name() != "" {
+			key = v.Type().Name()
+		}
+		d.fields(m, v, key, depth)
+		if len(m.Content) == 0 {
+			m.Style = yaml.FlowStyle
+		}
+		return m
+	case reflect.Map:
+		m := &yaml.Node{Kind: yaml.MappingNode}
+		if v.Len() == 0 {
+			m.Style = yaml.FlowStyle
+			return m
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			m.Content = append(m.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(k)},
+				d.value(v.MapIndex(k), key, depth+1))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := &yaml.Node{Kind: yaml.SequenceNode}
+		if v.Len() == 0 {
+			s.Style = yaml.FlowStyle
+			return s
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.Content = append(s.Content, d.value(v.Index(i), key, depth+1))
+		}
+		return s
+	case reflect.Interface:
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		return d.value(v.Elem(), key, depth)
+	}
+
+	n := &yaml.Node{}
+	if err := n.Encode(v.Interface()); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+	return n
+}
+
+// fields 按 yaml 标签输出结构体字段，规则与 structSchema 一致
+func (d *dumper) fields(m *yaml.Node, v reflect.Value, key string, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldKey := key + "." + f.Name
+		fv := v.Field(i)
+
+		if strings.Contains(","+opts+",", ",inline,") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch ft.Kind() {
+			case reflect.Struct:
+				for fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						fv = reflect.New(fv.Type().Elem())
+					}
+					fv = fv.Elem()
+				}
+				d.fields(m, fv, ft.Name(), depth)
+			case reflect.Map:
+				for _, k := range fv.MapKeys() {
+					m.Content = append(m.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(k)},
+						d.value(fv.MapIndex(k), fieldKey, depth+1))
+				}
+				// 内联映射为空时在最后一项后给出示例
+				if fv.Len() == 0 && len(m.Content) > 0 {
+					if ex := d.example(ft, fieldKey, depth); ex != "" {
+						last := m.Content[len(m.Content)-2]
+						last.FootComment = "其余的键为名称，值的格式如下:\n" + ex
+					}
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		k := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+		var comment []string
+		if desc := descriptions[fieldKey]; desc != "" {
+			comment = append(comment, desc)
+		}
+		if isEmptyContainer(fv) {
+			if ex := d.example(f.Type, fieldKey, depth); ex != "" {
+				comment = append(comment, "示例:\n"+ex)
+			}
+		}
+		k.HeadComment = strings.Join(comment, "\n")
+		m.Content = append(m.Content, k, d.value(fv, fieldKey, depth+1))
+	}
+}
+
+// example 为空的列表或映射生成一个元素的示例（缩进两格的 YAML 文本），元素不是结构体时返回空
+func (d *dumper) example(t reflect.Type, key string, depth int) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return ""
+	}
+	elem := t.Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if d.inExample || elem.Kind() != reflect.Struct || elem == timeType || depth >= maxDumpDepth {
+		return ""
+	}
+
+	d.inExample = true
+	item := d.value(reflect.New(elem).Elem(), key, depth+1)
+	d.inExample = false
+	var wrapped *yaml.Node
+	if t.Kind() == reflect.Slice {
+		wrapped = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{item}}
+	} else {
+		wrapped = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "name"}, item}}
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(wrapped); err != nil {
+		return ""
+	}
+	enc.Close()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "  " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isEmptyContainer 值为空的列表或映射（包括空指针指向的）
+func isEmptyContainer(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+}
@@ -0,0 +1,91 @@
+// gen 从 config 包源码中提取结构体字段注释，生成 JSON Schema 的字段说明（descriptions.go）
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, "..", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	desc := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						collect(desc, ts.Name.Name, st)
+					}
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(desc))
+	for k := range desc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go generate; DO NOT EDIT.\n\npackage schema\n\n")
+	buf.WriteString("// descriptions 字段说明，键为 类型名.字段名（匿名结构体继续追加字段名）\n")
+	buf.WriteString("var descriptions = map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "\t%q: %q,\n", k, desc[k])
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("descriptions.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// collect 记录结构体字段的行尾注释（没有时使用字段上方的注释），递归处理匿名结构体
+func collect(desc map[string]string, prefix string, st *ast.StructType) {
+	for _, field := range st.Fields.List {
+		text := ""
+		if field.Comment != nil {
+			text = field.Comment.Text()
+		} else if field.Doc != nil {
+			text = field.Doc.Text()
+		}
+		text = strings.Join(strings.Fields(text), " ")
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			key := prefix + "." + name.Name
+			if text != "" {
+				desc[key] = text
+			}
+			if inner, ok := field.Type.(*ast.StructType); ok {
+				collect(desc, key, inner)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package schema 根据配置结构体生成 JSON Schema，供编辑器自动补全和外部工具校验配置
+package schema
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// draft 生成的 Schema 使用的规范版本
+const draft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// ref 配置值中的 ${ENV} / ${file:...} 引用，任何类型的值都可以写成引用
+var ref = map[string]interface{}{
+	"type":    "string",
+	"pattern": `\$\{[^}]+\}`,
+}
+
+// Generate 返回完整配置结构的 JSON Schema（draft-07）。
+// 具名结构体放在 definitions 中引用；未知配置项不影响加载，但在 Schema 中按错误提示，便于发现拼写错误
+func Generate() map[string]interface{} {
+	g := &generator{defs: make(map[string]interface{})}
+	root := g.structSchema(reflect.TypeOf(config.Config{}), "Config")
+	root["$schema"] = draft
+	root["title"] = "TVGate 配置"
+	root["definitions"] = g.defs
+	return root
+}
+
+// JSON 返回缩进格式的 JSON Schema
+func JSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(Generate()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	defs map[string]interface{}
+}
+
+// schema 返回类型对应的 Schema，key 用于查找字段说明
+func (g *generator) schema(t reflect.Type, key string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case durationType:
+		return withRef(map[string]interface{}{
+			"type":     "string",
+			"pattern":  `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`,
+			"examples": []string{"30s", "5m", "1h30m"},
+		})
+	case timeType:
+		return withRef(map[string]interface{}{"type": "string", "format": "date-time"})
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return withRef(map[string]interface{}{"type": "boolean"})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return withRef(map[string]interface{}{"type": "integer"})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return withRef(map[string]interface{}{"type": "integer", "minimum": 0})
+	case reflect.Float32, reflect.Float64:
+		return withRef(map[string]interface{}{"type": "number"})
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem(), key)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem(), key)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, key)
+		}
+		// 具名结构体只生成一次，递归引用时同样有效
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = map[string]interface{}{}
+			g.defs[t.Name()] = g.structSchema(t, t.Name())
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	}
+	// interface{} 等任意值
+	return map[string]interface{}{}
+}
+
+// structSchema 按 yaml 标签生成结构体的 Schema，规则与 yaml.v3 解码一致：
+// "-" 忽略，",inline" 的结构体字段合并到上层，",inline" 的 map 作为其余键的值
+func (g *generator) structSchema(t reflect.Type, key string) map[string]interface{} {
+	s := map[string]interface{}{"type": "object"}
+	props := make(map[string]interface{})
+	var extra interface{} = false
+	g.fields(t, key, props, &extra)
+	s["properties"] = props
+	s["additionalProperties"] = extra
+	return s
+}
+
+func (g *generator) fields(t reflect.Type, key string, props map[string]interface{}, extra *interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldKey := key + "." + f.Name
+		if strings.Contains(","+opts+",", ",inline,") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				*extra = g.schema(ft.Elem(), fieldKey)
+			} else if ft.Kind() == reflect.Struct {
+				g.fields(ft, ft.Name(), props, extra)
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fs := nullable(g.schema(f.Type, fieldKey))
+		if desc := descriptions[fieldKey]; desc != "" {
+			fs["description"] = desc
+		}
+		props[name] = fs
+	}
+}
+
+// nullable 允许配置项留空（YAML 中 "key:" 的值为 null，解码为零值）。
+// draft-07 中 $ref 的同级关键字会被忽略，因此引用也包一层 anyOf，以便附加说明
+func nullable(s map[string]interface{}) map[string]interface{} {
+	null := map[string]interface{}{"type": "null"}
+	if list, ok := s["anyOf"].([]interface{}); ok {
+		s["anyOf"] = append(list, null)
+		return s
+	}
+	if t, ok := s["type"].(string); ok {
+		s["type"] = []string{t, "null"}
+		return s
+	}
+	return map[string]interface{}{"anyOf": []interface{}{s, null}}
+}
+
+// withRef 允许值写成 ${...} 引用
+func withRef(s map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"anyOf": []interface{}{s, ref}}
+}
@@ -1,86 +1,125 @@
 package update
 
 import (
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/stream"
 )
 
 // UpdateHubsOnConfigChange 根据配置变更更新Hubs
-// 配置变更时调用
-func UpdateHubsOnConfigChange(newIfaces []string) {
+// 只更新发生变化的参数；只有使用默认网卡且网卡配置变化的 Hub 才会重建，
+// 客户端迁移到新 Hub，播放不中断
+func UpdateHubsOnConfigChange(oldCfg, newCfg *config.Config) {
 	config.CfgMu.RLock()
-	newRejoinInterval := config.Cfg.Server.McastRejoinInterval
+	oldIfaces := oldCfg.Server.MulticastIfaces
+	newIfaces := newCfg.Server.MulticastIfaces
+	newRejoinInterval := newCfg.Server.McastRejoinInterval
+	newIdleTimeout := newCfg.Server.McastIdleTimeout
 	// 获取FCC相关配置
-	newFccTypeStr := config.Cfg.Server.FccType
-	newFccCacheSize := config.Cfg.Server.FccCacheSize
-	newFccPortMin := config.Cfg.Server.FccListenPortMin
-	newFccPortMax := config.Cfg.Server.FccListenPortMax
-	
+	newFccCacheSize := newCfg.Server.FccCacheSize
+	newFccPortMin := newCfg.Server.FccListenPortMin
+	newFccPortMax := newCfg.Server.FccListenPortMax
+	// 确定FCC类型
+	newFccType := "telecom" // 默认为电信类型
+	if newCfg.Server.FccType == "huawei" {
+		newFccType = "huawei"
+	}
 	config.CfgMu.RUnlock()
-	
-	for oldKey, hub := range stream.GlobalMultiChannelHub.Hubs {
+
+	// 更新 Hub 缓存内存上限
+	if stream.SetCacheMemoryLimit(newCfg.Server.CacheMemoryMB) {
+		logger.LogPrintf("🔄 更新 Hub 缓存内存上限: %dMB -> %dMB",
+			oldCfg.Server.CacheMemoryMB, newCfg.Server.CacheMemoryMB)
+	}
+
+	ifacesChanged := !sameIfaces(oldIfaces, newIfaces)
+
+	// 先复制一份，避免遍历时修改 map
+	stream.GlobalMultiChannelHub.Mu.RLock()
+	hubs := make(map[string]*stream.StreamHub, len(stream.GlobalMultiChannelHub.Hubs))
+	for key, hub := range stream.GlobalMultiChannelHub.Hubs {
+		hubs[key] = hub
+	}
+	stream.GlobalMultiChannelHub.Mu.RUnlock()
+
+	for oldKey, hub := range hubs {
+		if hub.IsClosed() {
+			continue
+		}
+
 		// 更新多播重新加入间隔
-		oldRejoinInterval := hub.GetRejoinInterval()
-		hub.SetRejoinInterval(newRejoinInterval)
-		hub.UpdateRejoinTimer()
-		
-		// 记录日志（如果有变更）
-		if oldRejoinInterval != newRejoinInterval {
-			logger.LogPrintf("🔄 更新 Hub %s 的多播重新加入间隔: %v -> %v", 
+		if oldRejoinInterval := hub.GetRejoinInterval(); oldRejoinInterval != newRejoinInterval {
+			hub.SetRejoinInterval(newRejoinInterval)
+			hub.UpdateRejoinTimer()
+			logger.LogPrintf("🔄 更新 Hub %s 的多播重新加入间隔: %v -> %v",
 				oldKey, oldRejoinInterval, newRejoinInterval)
 		}
-		
+
+		// 更新组播源空闲超时
+		if oldIdleTimeout := hub.GetIdleTimeout(); oldIdleTimeout != newIdleTimeout {
+			hub.SetIdleTimeout(newIdleTimeout)
+			logger.LogPrintf("🔄 更新 Hub %s 的组播源空闲超时: %v -> %v",
+				oldKey, oldIdleTimeout, newIdleTimeout)
+		}
+
+		// 更新重封装规则
+		if hub.SetRemux(newCfg.MatchRemux(hub.AddrList[0])) {
+			logger.LogPrintf("🔄 更新 Hub %s 的 TS 重封装规则", oldKey)
+		}
+
+		// 更新断线续播窗口
+		var resume time.Duration
+		if info := newCfg.Channels.ByAddr(strings.Join(hub.AddrList, ",")); info != nil {
+			resume = info.Resume
+		}
+		if hub.SetResume(resume) {
+			logger.LogPrintf("🔄 更新 Hub %s 的断线续播窗口: %v", oldKey, resume)
+		}
+
 		// 更新FCC配置
-		oldFccType := hub.GetFccType()
+		if oldFccType := hub.GetFccType(); oldFccType != newFccType {
+			hub.SetFccType(newFccType)
+			logger.LogPrintf("🔄 更新 Hub %s 的FCC类型: %v -> %v",
+				oldKey, oldFccType, newFccType)
+		}
 		oldFccCacheSize := hub.GetFccCacheSize()
 		oldFccPortMin := hub.GetFccPortMin()
 		oldFccPortMax := hub.GetFccPortMax()
-		
-		// 确定FCC类型
-		newFccType := "telecom" // 默认为电信类型
-		switch newFccTypeStr {
-		case "huawei":
-			newFccType = "huawei"
-		case "telecom":
-			newFccType = "telecom"
-		}
-		
-		hub.SetFccType(newFccType)
-		hub.SetFccParams(newFccCacheSize, newFccPortMin, newFccPortMax)
-		
-		// 记录FCC配置变更日志
-		if oldFccType != newFccType {
-			logger.LogPrintf("🔄 更新 Hub %s 的FCC类型: %v -> %v", 
-				oldKey, oldFccType, newFccType)
-		}
-		if oldFccCacheSize != newFccCacheSize {
-			logger.LogPrintf("🔄 更新 Hub %s 的FCC缓存大小: %v -> %v", 
-				oldKey, oldFccCacheSize, newFccCacheSize)
-		}
-		if oldFccPortMin != newFccPortMin {
-			logger.LogPrintf("🔄 更新 Hub %s 的FCC监听端口最小值: %v -> %v", 
-				oldKey, oldFccPortMin, newFccPortMin)
+		if oldFccCacheSize != newFccCacheSize || oldFccPortMin != newFccPortMin || oldFccPortMax != newFccPortMax {
+			hub.SetFccParams(newFccCacheSize, newFccPortMin, newFccPortMax)
+			logger.LogPrintf("🔄 更新 Hub %s 的FCC参数: 缓存 %v -> %v, 端口 %v-%v -> %v-%v",
+				oldKey, oldFccCacheSize, newFccCacheSize, oldFccPortMin, oldFccPortMax, newFccPortMin, newFccPortMax)
 		}
-		if oldFccPortMax != newFccPortMax {
-			logger.LogPrintf("🔄 更新 Hub %s 的FCC监听端口最大值: %v -> %v", 
-				oldKey, oldFccPortMax, newFccPortMax)
+
+		// URL 中通过 iface 参数指定网卡的 Hub 不受配置影响
+		if !ifacesChanged || !sameIfaces(hub.Ifaces(), oldIfaces) {
+			continue
 		}
-		
-		// 生成新 key
-		newKey := stream.GlobalMultiChannelHub.HubKey(hub.AddrList[0],newIfaces)
 
+		newKey := stream.GlobalMultiChannelHub.HubKey(hub.AddrList[0], newIfaces)
 		if oldKey == newKey {
-			// key 没变，只更新接口
-			_ = hub.UpdateInterfaces(newIfaces)
 			continue
 		}
 
-		// 创建新 Hub
-		newHub, err := stream.NewStreamHub(hub.AddrList, newIfaces)
-		if err != nil {
-			logger.LogPrintf("❌ 新 Hub 创建失败: %v", err)
-			continue
+		// 新网卡下已有同地址的 Hub 时直接合并，否则创建新 Hub
+		stream.GlobalMultiChannelHub.Mu.RLock()
+		newHub := stream.GlobalMultiChannelHub.Hubs[newKey]
+		stream.GlobalMultiChannelHub.Mu.RUnlock()
+		if newHub == nil || newHub.IsClosed() {
+			var err error
+			newHub, err = stream.NewStreamHub(hub.AddrList, newIfaces)
+			if err != nil {
+				logger.LogPrintf("❌ 新 Hub 创建失败: %v", err)
+				continue
+			}
+			ifaces := newIfaces
+			newHub.OnEmpty = func(h *stream.StreamHub) {
+				stream.GlobalMultiChannelHub.RemoveHubEx(h.AddrList[0], ifaces)
+			}
 		}
 
 		// 客户端迁移
@@ -88,8 +127,22 @@ func UpdateHubsOnConfigChange(newIfaces []string) {
 
 		// 替换到 GlobalMultiChannelHub
 		stream.GlobalMultiChannelHub.Mu.Lock()
-		delete(stream.GlobalMultiChannelHub.Hubs, oldKey)
+		if stream.GlobalMultiChannelHub.Hubs[oldKey] == hub {
+			delete(stream.GlobalMultiChannelHub.Hubs, oldKey)
+		}
 		stream.GlobalMultiChannelHub.Hubs[newKey] = newHub
 		stream.GlobalMultiChannelHub.Mu.Unlock()
+
+		// 释放旧 Hub 的组播监听
+		go hub.Close()
+		logger.LogPrintf("🔄 Hub %v 网卡变更 %v -> %v，已重建", hub.AddrList, oldIfaces, newIfaces)
+	}
+}
+
+// sameIfaces 比较网卡列表，nil 与空列表视为相同
+func sameIfaces(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
 	}
+	return reflect.DeepEqual(a, b)
 }
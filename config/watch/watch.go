@@ -2,23 +2,47 @@ package watch
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/cloudflare/tableflip"
 	"github.com/fsnotify/fsnotify"
 
+	"github.com/qist/tvgate/accesslog"
+	"github.com/qist/tvgate/alert"
+	"github.com/qist/tvgate/audit"
 	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/cast"
+	"github.com/qist/tvgate/dvr"
+	"github.com/qist/tvgate/cluster"
+	"github.com/qist/tvgate/bruteforce"
+	"github.com/qist/tvgate/dlna"
 	"github.com/qist/tvgate/dns"
+	"github.com/qist/tvgate/epg"
+	"github.com/qist/tvgate/ingest"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/config/history"
 	"github.com/qist/tvgate/config/load"
 	"github.com/qist/tvgate/config/update"
+	"github.com/qist/tvgate/kvstore"
 	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/logo"
+	"github.com/qist/tvgate/metrics"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/probe"
+	"github.com/qist/tvgate/quota"
+	"github.com/qist/tvgate/refresh"
 	"github.com/qist/tvgate/server"
+	"github.com/qist/tvgate/stats"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/utils/httperror"
+	"github.com/qist/tvgate/web"
 )
 
 // WatchConfigFile 监控配置文件变更并平滑更新服务
@@ -38,14 +62,11 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 		parentDir = "."
 	}
 
-	fileInfo, err := os.Stat(absPath)
-	var lastModifiedTime time.Time
-	if err == nil {
-		lastModifiedTime = fileInfo.ModTime()
-	} else {
-		lastModifiedTime = time.Now()
-		logger.LogPrintf("⚠️ 获取配置文件状态失败，将使用当前时间: %v", err)
+	// 主配置文件及引入文件的修改时间摘要
+	if _, err := os.Stat(absPath); err != nil {
+		logger.LogPrintf("⚠️ 获取配置文件状态失败: %v", err)
 	}
+	lastStamp := load.Stamp(absPath)
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -68,6 +89,24 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 		return
 	}
 
+	// 监控 includes 引入的文件所在目录，新增匹配的文件也会触发重新加载
+	watchIncludes := func() {
+		files, patterns := load.Included()
+		dirs := make(map[string]bool)
+		for _, f := range append(files, patterns...) {
+			dirs[filepath.Dir(f)] = true
+		}
+		for dir := range dirs {
+			if dir == parentDir {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				logger.LogPrintf("⚠️ 监控引入文件目录失败 %s: %v", dir, err)
+			}
+		}
+	}
+	watchIncludes()
+
 	var debounceTimer *time.Timer
 	debounceDelay := time.Duration(config.Cfg.Reload) * time.Second
 
@@ -82,36 +121,87 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 	oldKeyFile := config.Cfg.Server.KeyFile
 	oldTLSCertFile := config.Cfg.Server.TLS.CertFile
 	oldTLSKeyFile := config.Cfg.Server.TLS.KeyFile
+	oldACME := config.Cfg.Server.TLS.ACME
+	oldListeners := slices.Clone(config.Cfg.Server.Listeners)
 
 	reload := func() {
-		info, err := os.Stat(configPath)
-		if err != nil {
+		if _, err := os.Stat(configPath); err != nil {
 			logger.LogPrintf("❌ 获取文件信息失败: %v", err)
 			return
 		}
-		if !info.ModTime().After(lastModifiedTime) {
+		stamp := load.Stamp(absPath)
+		if stamp == lastStamp {
 			return
 		}
-		lastModifiedTime = info.ModTime()
+		lastStamp = stamp
 		logger.LogPrintf("📦 检测到配置文件修改，准备重新加载...")
 
+		// 保存旧配置用于按配置项比较，LoadConfig 会整体替换 config.Cfg
+		config.CfgMu.RLock()
+		oldCfg := config.Cfg
+		config.CfgMu.RUnlock()
+
 		if err := load.LoadConfig(configPath); err != nil {
 			logger.LogPrintf("❌ 重新加载配置失败: %v", err)
 			return
 		}
 		logger.LogPrintf("✅ 配置文件重新加载完成")
-		// 🔹 这里刷新 DNS 实例
-		dns.HandleConfigUpdate(	&config.Config{}, &config.Cfg)
-		config.CfgMu.RLock()
-		update.UpdateHubsOnConfigChange(config.Cfg.Server.MulticastIfaces)
-		config.CfgMu.RUnlock()
+		watchIncludes()
+		// 引入的文件可能已变化，按新的引入列表重新计算
+		lastStamp = load.Stamp(absPath)
 
 		muxMu.Lock()
 		defer muxMu.Unlock()
 
-		// 设置默认值 & token 管理器
+		// 设置默认值后再比较，避免未填写的默认值被当作变更
 		config.Cfg.SetDefaults()
-		auth.ReloadGlobalTokenManager(&config.Cfg.GlobalAuth)
+		changed := config.ChangedSections(&oldCfg, &config.Cfg)
+		// 保存本次生效的配置副本，用于查看差异和回滚
+		history.Configure(config.Cfg.History)
+		history.Default.RecordFile("reload", configPath, changed)
+		if len(changed) == 0 {
+			logger.LogPrintf("✅ 配置内容无变化，无需更新")
+			return
+		}
+		logger.LogPrintf("🔄 变更的配置项: %v", changed)
+
+		// 🔹 这里刷新 DNS 实例
+		if slices.Contains(changed, "dns") {
+			dns.HandleConfigUpdate(&oldCfg, &config.Cfg)
+		}
+		// 只有 server 配置变化才需要更新 Hub，且只重建网卡变化的 Hub
+		if slices.Contains(changed, "server") {
+			update.UpdateHubsOnConfigChange(&oldCfg, &config.Cfg)
+		}
+
+		// token 管理器重建会丢失动态 token 的在线状态，仅在认证配置变化时重载
+		if slices.Contains(changed, "global_auth") {
+			auth.ReloadGlobalTokenManager(&config.Cfg.GlobalAuth)
+		}
+		if err := monitor.SetTrustedProxies(config.Cfg.Access.TrustedProxies); err != nil {
+			logger.LogPrintf("❌ %v", err)
+		}
+		bruteforce.Configure(config.Cfg.BruteForce)
+		kvstore.Configure(config.Cfg.Store)
+		audit.Configure(config.Cfg.Audit)
+		quota.Configure(config.Cfg.Quota)
+		connlimit.Configure(config.Cfg.ConnLimit)
+		accesslog.Configure(config.Cfg.AccessLog)
+		alert.Configure(config.Cfg.Alert)
+		tracing.Configure(config.Cfg.Tracing)
+		metrics.Configure(config.Cfg.Metrics)
+		epg.Configure(config.Cfg.EPG)
+		logo.Configure(config.Cfg.Logo)
+		dlna.Configure(&config.Cfg)
+		cast.Configure(&config.Cfg)
+		dvr.Configure(&config.Cfg)
+		cluster.Configure(&config.Cfg)
+		probe.Configure(config.Cfg.Probe)
+		ingest.Configure(config.Cfg.Ingest)
+		refresh.Configure(config.Cfg.Refresh)
+		httperror.Configure(config.Cfg.ErrorPages)
+		web.ApplyDebug(config.Cfg.Debug)
+		stats.Configure(config.Cfg.Stats)
 		auth.CleanupGlobalTokenManager()
 
 		needRestart := oldPort != config.Cfg.Server.Port ||
@@ -120,7 +210,9 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 			oldCertFile != config.Cfg.Server.CertFile ||
 			oldKeyFile != config.Cfg.Server.KeyFile ||
 			oldTLSCertFile != config.Cfg.Server.TLS.CertFile ||
-			oldTLSKeyFile != config.Cfg.Server.TLS.KeyFile
+			oldTLSKeyFile != config.Cfg.Server.TLS.KeyFile ||
+			!reflect.DeepEqual(oldACME, config.Cfg.Server.TLS.ACME) ||
+			server.ListenersChanged(oldListeners, config.Cfg.Server.Listeners)
 
 		// 如果需要重启服务
 		if needRestart {
@@ -143,21 +235,11 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 			ctx, cancel := context.WithCancel(context.Background())
 			httpCancel = cancel
 
-			// 构建需要启动的新地址列表
-			newAddrs := make(map[string]bool)
-			newAddrs[fmt.Sprintf(":%d", config.Cfg.Server.Port)] = true
-			if config.Cfg.Server.HTTPPort > 0 {
-				newAddrs[fmt.Sprintf(":%d", config.Cfg.Server.HTTPPort)] = true
-			}
-			if config.Cfg.Server.TLS.HTTPSPort > 0 {
-				newAddrs[fmt.Sprintf(":%d", config.Cfg.Server.TLS.HTTPSPort)] = true
-			}
-
 			// 启动所有新服务
-			for addr := range newAddrs {
+			for _, addr := range server.ListenAddrs(&config.Cfg) {
 				mux := server.RegisterMux(addr, &config.Cfg)
 				logger.LogPrintf("🚀 正在启动服务 %s", addr)
-				go func(addr string, mux *http.ServeMux) {
+				go func(addr string, mux http.Handler) {
 					if err := server.StartHTTPServerWithConfig(ctx, addr, nil, &config.Cfg); err != nil {
 						logger.LogPrintf("❌ 启动 HTTP 服务失败 %s: %v", addr, err)
 					}
@@ -167,17 +249,7 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 			// 平滑更新路由
 			logger.LogPrintf("🔄 配置变更无需重启服务，进行平滑更新")
 
-			// 构建地址列表
-			addrs := make(map[string]bool)
-			addrs[fmt.Sprintf(":%d", config.Cfg.Server.Port)] = true
-			if config.Cfg.Server.HTTPPort > 0 {
-				addrs[fmt.Sprintf(":%d", config.Cfg.Server.HTTPPort)] = true
-			}
-			if config.Cfg.Server.TLS.HTTPSPort > 0 {
-				addrs[fmt.Sprintf(":%d", config.Cfg.Server.TLS.HTTPSPort)] = true
-			}
-
-			for addr := range addrs {
+			for _, addr := range server.ListenAddrs(&config.Cfg) {
 				mux := server.RegisterMux(addr, &config.Cfg)
 				server.SetHTTPHandler(addr, mux)
 			}
@@ -191,6 +263,8 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 		oldKeyFile = config.Cfg.Server.KeyFile
 		oldTLSCertFile = config.Cfg.Server.TLS.CertFile
 		oldTLSKeyFile = config.Cfg.Server.TLS.KeyFile
+		oldACME = config.Cfg.Server.TLS.ACME
+		oldListeners = slices.Clone(config.Cfg.Server.Listeners)
 	}
 
 	for {
@@ -199,7 +273,13 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 			if !ok {
 				return
 			}
-			if filepath.Clean(event.Name) == filepath.Clean(absPath) {
+			if filepath.Clean(event.Name) != filepath.Clean(absPath) && load.IsIncluded(event.Name) {
+				// 引入的文件新增、修改或删除都需要重新合并
+				if debounceTimer != nil {
+					debounceTimer.Stop()
+				}
+				debounceTimer = time.AfterFunc(debounceDelay, reload)
+			} else if filepath.Clean(event.Name) == filepath.Clean(absPath) {
 				switch {
 				case event.Op&(fsnotify.Write|fsnotify.Create) != 0:
 					if debounceTimer != nil {
@@ -218,6 +298,13 @@ func WatchConfigFile(configPath string, upgrader *tableflip.Upgrader) {
 				}
 			}
 
+		case <-config.ReloadCh:
+			// 接口写入配置后立即重新加载，不等待防抖
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			debounceTimer = time.AfterFunc(0, reload)
+
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -0,0 +1,219 @@
+// Package connlimit 流媒体并发连接数限制：全局与单个频道的上限，超出时拒绝或排队等待空位
+package connlimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// 超出限制时的处理方式
+const (
+	ActionReject = "reject"
+	ActionQueue  = "queue"
+)
+
+var (
+	// ErrGlobalLimit 全局连接数已满
+	ErrGlobalLimit = errors.New("服务器连接数已达上限")
+	// ErrChannelLimit 频道连接数已满
+	ErrChannelLimit = errors.New("频道连接数已达上限")
+)
+
+// Limiter 并发连接计数器
+type Limiter struct {
+	mu       sync.Mutex
+	cfg      config.ConnLimitConfig
+	total    int
+	channels map[string]int
+	queued   int
+	rejected uint64
+	changed  chan struct{} // 连接释放或配置变化时关闭并替换，唤醒排队的请求
+}
+
+// New 创建计数器
+func New() *Limiter {
+	return &Limiter{channels: make(map[string]int), changed: make(chan struct{})}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 更新配置，已有连接不受影响，排队的请求按新上限重新判断
+func (l *Limiter) Configure(cfg config.ConnLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	l.notifyLocked()
+}
+
+func (l *Limiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// channelLimit 频道的上限：channels 中最长前缀优先，否则使用 per_channel
+func channelLimit(cfg config.ConnLimitConfig, channel string) int {
+	best := -1
+	limit := cfg.PerChannel
+	for prefix, v := range cfg.Channels {
+		if strings.HasPrefix(channel, prefix) && len(prefix) > best {
+			best = len(prefix)
+			limit = v
+		}
+	}
+	return limit
+}
+
+// tryAcquireLocked 有空位时占用，返回未能占用的原因
+func (l *Limiter) tryAcquireLocked(channel string) error {
+	if l.cfg.MaxConns > 0 && l.total >= l.cfg.MaxConns {
+		return ErrGlobalLimit
+	}
+	if limit := channelLimit(l.cfg, channel); limit > 0 && l.channels[channel] >= limit {
+		return ErrChannelLimit
+	}
+	l.total++
+	l.channels[channel]++
+	return nil
+}
+
+// Acquire 为频道占用一个连接，返回的 release 在连接结束时调用；
+// 已满时按配置立即返回错误或排队等待，等待超时或请求取消时返回错误
+func (l *Limiter) Acquire(ctx context.Context, channel string) (release func(), err error) {
+	l.mu.Lock()
+	err = l.tryAcquireLocked(channel)
+	if err != nil && l.cfg.Action == ActionQueue {
+		timer := time.NewTimer(l.cfg.QueueTimeout)
+		defer timer.Stop()
+		l.queued++
+		for err != nil {
+			changed := l.changed
+			l.mu.Unlock()
+			select {
+			case <-changed:
+			case <-timer.C:
+				l.mu.Lock()
+				l.queued--
+				l.rejected++
+				l.mu.Unlock()
+				return nil, err
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.queued--
+				l.mu.Unlock()
+				return nil, ctx.Err()
+			}
+			l.mu.Lock()
+			err = l.tryAcquireLocked(channel)
+		}
+		l.queued--
+	} else if err != nil {
+		l.rejected++
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { l.release(channel) }) }, nil
+}
+
+func (l *Limiter) release(channel string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.channels[channel]--; l.channels[channel] <= 0 {
+		delete(l.channels, channel)
+	}
+	l.notifyLocked()
+}
+
+// ChannelStatus 频道当前连接数与上限，上限 0 表示不限制
+type ChannelStatus struct {
+	Channel string `json:"channel"`
+	Current int    `json:"current"`
+	Limit   int    `json:"limit"`
+}
+
+// Status 当前连接数与上限
+type Status struct {
+	Enabled  bool            `json:"enabled"` // 是否配置了任何上限
+	Current  int             `json:"current"`
+	Limit    int             `json:"limit"` // 0 表示不限制
+	Queued   int             `json:"queued"`
+	Rejected uint64          `json:"rejected"` // 累计拒绝（含排队超时）的连接数
+	Action   string          `json:"action"`
+	Channels []ChannelStatus `json:"channels"` // 按连接数从多到少排序
+}
+
+// Status 返回当前连接数、上限与各频道的连接数
+func (l *Limiter) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := Status{
+		Enabled:  l.cfg.MaxConns > 0 || l.cfg.PerChannel > 0 || len(l.cfg.Channels) > 0,
+		Current:  l.total,
+		Limit:    l.cfg.MaxConns,
+		Queued:   l.queued,
+		Rejected: l.rejected,
+		Action:   l.cfg.Action,
+		Channels: make([]ChannelStatus, 0, len(l.channels)),
+	}
+	for ch, n := range l.channels {
+		s.Channels = append(s.Channels, ChannelStatus{Channel: ch, Current: n, Limit: channelLimit(l.cfg, ch)})
+	}
+	sort.Slice(s.Channels, func(i, j int) bool {
+		if s.Channels[i].Current != s.Channels[j].Current {
+			return s.Channels[i].Current > s.Channels[j].Current
+		}
+		return s.Channels[i].Channel < s.Channels[j].Channel
+	})
+	return s
+}
+
+// Configure 更新全局实例的配置
+func Configure(cfg config.ConnLimitConfig) { Default.Configure(cfg) }
+
+// segmentExts HLS/DASH 播放列表与分片的扩展名，这类请求按所在目录计为同一频道
+var segmentExts = map[string]bool{
+	".m3u8": true, ".m3u": true, ".mpd": true,
+	".ts": true, ".m4s": true, ".mp4": true, ".m4a": true, ".m4v": true,
+	".aac": true, ".vtt": true, ".key": true, ".cmfv": true, ".cmfa": true,
+}
+
+// Channel 请求路径对应的频道：播放列表与分片取所在目录（如 /live/cctv1/），
+// 使同一频道的各个分片计为一个频道；组播地址、RTSP 等直播流路径本身即是频道
+func Channel(p string) string {
+	if segmentExts[strings.ToLower(path.Ext(p))] {
+		return strings.TrimSuffix(path.Dir(p), "/") + "/"
+	}
+	return p
+}
+
+// Acquire 在全局实例中为请求路径对应的频道（见 Channel）占用一个连接，失败时已写出 503 响应并返回 nil，
+// 成功时调用方需在连接结束时调用返回的函数
+func Acquire(w http.ResponseWriter, r *http.Request) func() {
+	channel := Channel(r.URL.Path)
+	release, err := Default.Acquire(r.Context(), channel)
+	if err == nil {
+		return release
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	logger.LogPrintf("🚫 %s，拒绝连接: %s", err, channel)
+	w.Header().Set("Retry-After", "5")
+	httperror.Error(w, r, err.Error(), http.StatusServiceUnavailable)
+	return nil
+}
@@ -0,0 +1,307 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+const (
+	rootID = "0"
+	// dlnaFlags 与直播流响应头 ContentFeatures.DLNA.ORG 保持一致
+	dlnaFlags = "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+	// ungrouped 没有分组的频道所在的目录
+	ungrouped = "未分组"
+)
+
+// group 按 group-title 划分的目录
+type group struct {
+	title string
+	items []int // entries 中的下标
+}
+
+// library 一次读取播放列表得到的频道目录，只有一个分组时频道直接放在根目录
+type library struct {
+	entries  []m3u.Entry
+	groups   []group
+	parents  map[int]string // 频道的父目录 ID
+	updateID uint32         // SystemUpdateID，频道变化时递增
+}
+
+func newLibrary(entries []m3u.Entry) *library {
+	lib := &library{entries: entries, parents: make(map[int]string), updateID: 1}
+	index := make(map[string]int)
+	for i, e := range entries {
+		// 只保留能经 TVGate 代理访问的频道
+		if !strings.HasPrefix(m3u.ProxyPath(e.URL), "/") {
+			continue
+		}
+		title := e.Group
+		if title == "" {
+			title = ungrouped
+		}
+		g, ok := index[title]
+		if !ok {
+			g = len(lib.groups)
+			index[title] = g
+			lib.groups = append(lib.groups, group{title: title})
+		}
+		lib.groups[g].items = append(lib.groups[g].items, i)
+	}
+	for g, gr := range lib.groups {
+		parent := rootID
+		if !lib.flat() {
+			parent = groupID(g)
+		}
+		for _, i := range gr.items {
+			lib.parents[i] = parent
+		}
+	}
+	return lib
+}
+
+func (lib *library) flat() bool { return len(lib.groups) <= 1 }
+
+func groupID(g int) string { return "g" + strconv.Itoa(g) }
+func itemID(i int) string  { return "i" + strconv.Itoa(i) }
+
+// didlLite ContentDirectory Browse 返回的 DIDL-Lite 文档
+type didlLite struct {
+	XMLName    xml.Name        `xml:"DIDL-Lite"`
+	XMLNS      string          `xml:"xmlns,attr"`
+	DC         string          `xml:"xmlns:dc,attr"`
+	UPnP       string          `xml:"xmlns:upnp,attr"`
+	Containers []didlContainer `xml:"container"`
+	Items      []didlItem      `xml:"item"`
+}
+
+type didlContainer struct {
+	ID         string `xml:"id,attr"`
+	ParentID   string `xml:"parentID,attr"`
+	Restricted string `xml:"restricted,attr"`
+	Searchable string `xml:"searchable,attr"`
+	ChildCount int    `xml:"childCount,attr"`
+	Title      string `xml:"dc:title"`
+	Class      string `xml:"upnp:class"`
+}
+
+type didlItem struct {
+	ID         string  `xml:"id,attr"`
+	ParentID   string  `xml:"parentID,attr"`
+	Restricted string  `xml:"restricted,attr"`
+	Title      string  `xml:"dc:title"`
+	Class      string  `xml:"upnp:class"`
+	Genre      string  `xml:"upnp:genre,omitempty"`
+	AlbumArt   string  `xml:"upnp:albumArtURI,omitempty"`
+	Res        didlRes `xml:"res"`
+}
+
+type didlRes struct {
+	ProtocolInfo    string `xml:"protocolInfo,attr"`
+	Resolution      string `xml:"resolution,attr,omitempty"`
+	AudioChannels   int    `xml:"nrAudioChannels,attr,omitempty"`
+	SampleFrequency int    `xml:"sampleFrequency,attr,omitempty"`
+	URL             string `xml:",chardata"`
+}
+
+// browser 处理一次 SOAP 请求，base 为客户端访问 TVGate 的地址
+type browser struct {
+	lib  *library
+	s    settings
+	base string
+}
+
+// browse 实现 ContentDirectory 的 Browse，返回 DIDL-Lite、本次返回数量与总数
+func (b *browser) browse(id, flag string, start, count int) (string, int, int, error) {
+	doc := didlLite{
+		XMLNS: "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		DC:    "http://purl.org/dc/elements/1.1/",
+		UPnP:  "urn:schemas-upnp-org:metadata-1-0/upnp/",
+	}
+	var returned, total int
+	switch flag {
+	case "BrowseMetadata":
+		c, it, ok := b.object(id)
+		if !ok {
+			return "", 0, 0, errNoSuchObject
+		}
+		if c != nil {
+			doc.Containers = append(doc.Containers, *c)
+		} else {
+			doc.Items = append(doc.Items, *it)
+		}
+		returned, total = 1, 1
+	case "BrowseDirectChildren":
+		containers, items, ok := b.children(id)
+		if !ok {
+			return "", 0, 0, errNoSuchObject
+		}
+		total = len(containers) + len(items)
+		from, to := page(total, start, count)
+		for i := from; i < to; i++ {
+			if i < len(containers) {
+				doc.Containers = append(doc.Containers, containers[i])
+			} else {
+				doc.Items = append(doc.Items, b.item(items[i-len(containers)]))
+			}
+		}
+		returned = to - from
+	default:
+		return "", 0, 0, errInvalidArgs
+	}
+	out, err := xml.Marshal(doc)
+	if err != nil {
+		return "", 0, 0, errCannotProcess
+	}
+	return string(out), returned, total, nil
+}
+
+// page 按 StartingIndex 与 RequestedCount 计算分页范围，RequestedCount 为 0 表示全部
+func page(total, start, count int) (int, int) {
+	from := min(max(start, 0), total)
+	to := total
+	if count > 0 {
+		to = min(from+count, total)
+	}
+	return from, to
+}
+
+// object 按 ID 查找目录或频道
+func (b *browser) object(id string) (*didlContainer, *didlItem, bool) {
+	lib := b.lib
+	switch {
+	case id == rootID:
+		n := len(lib.groups)
+		if lib.flat() && n == 1 {
+			n = len(lib.groups[0].items)
+		}
+		return &didlContainer{ID: rootID, ParentID: "-1", Restricted: "1", Searchable: "0", ChildCount: n,
+			Title: b.s.FriendlyName, Class: "object.container"}, nil, true
+	case strings.HasPrefix(id, "g") && !lib.flat():
+		g, err := strconv.Atoi(id[1:])
+		if err != nil || g < 0 || g >= len(lib.groups) {
+			return nil, nil, false
+		}
+		c := b.container(g)
+		return &c, nil, true
+	case strings.HasPrefix(id, "i"):
+		i, err := strconv.Atoi(id[1:])
+		if _, ok := lib.parents[i]; err != nil || !ok {
+			return nil, nil, false
+		}
+		it := b.item(i)
+		return nil, &it, true
+	}
+	return nil, nil, false
+}
+
+// children 目录下的子目录与频道
+func (b *browser) children(id string) ([]didlContainer, []int, bool) {
+	lib := b.lib
+	if id == rootID {
+		if lib.flat() {
+			if len(lib.groups) == 0 {
+				return nil, nil, true
+			}
+			return nil, lib.groups[0].items, true
+		}
+		containers := make([]didlContainer, len(lib.groups))
+		for g := range lib.groups {
+			containers[g] = b.container(g)
+		}
+		return containers, nil, true
+	}
+	if !strings.HasPrefix(id, "g") || lib.flat() {
+		if _, _, ok := b.object(id); ok {
+			// 频道没有子项
+			return nil, nil, true
+		}
+		return nil, nil, false
+	}
+	g, err := strconv.Atoi(id[1:])
+	if err != nil || g < 0 || g >= len(lib.groups) {
+		return nil, nil, false
+	}
+	return nil, lib.groups[g].items, true
+}
+
+func (b *browser) container(g int) didlContainer {
+	gr := b.lib.groups[g]
+	return didlContainer{ID: groupID(g), ParentID: rootID, Restricted: "1", Searchable: "0",
+		ChildCount: len(gr.items), Title: gr.title, Class: "object.container.storageFolder"}
+}
+
+func (b *browser) item(i int) didlItem {
+	e := b.lib.entries[i]
+	u := b.base + m3u.ProxyPath(e.URL)
+	if b.s.Token != "" {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += sep + b.s.tokenParam + "=" + url.QueryEscape(b.s.Token)
+	}
+	it := didlItem{
+		ID:         itemID(i),
+		ParentID:   b.lib.parents[i],
+		Restricted: "1",
+		Title:      e.Name,
+		Class:      "object.item.videoItem.videoBroadcast",
+		Genre:      e.Group,
+		Res:        didlRes{ProtocolInfo: "http-get:*:" + mimeType(e.URL) + ":" + dlnaFlags, URL: u},
+	}
+	// 频道正在播放且启用了探测时附带分辨率与音频参数
+	if info := monitor.MediaInfoOf(mediaName(e.URL)); info != nil {
+		if info.Width > 0 && info.Height > 0 {
+			it.Res.Resolution = strconv.Itoa(info.Width) + "x" + strconv.Itoa(info.Height)
+		}
+		if len(info.Audio) > 0 {
+			it.Res.AudioChannels = info.Audio[0].Channels
+			it.Res.SampleFrequency = info.Audio[0].SampleRate
+		}
+	}
+	switch logo := e.Attrs["tvg-logo"]; {
+	case b.s.logoPath != "":
+		it.AlbumArt = b.base + b.s.logoPath + "/" + url.PathEscape(e.Name) + ".png"
+	case strings.HasPrefix(logo, "http://") || strings.HasPrefix(logo, "https://"):
+		it.AlbumArt = logo
+	}
+	return it
+}
+
+// mediaName 上游地址对应的状态页频道标识：组播为 ip:port，RTSP 为完整地址
+func mediaName(raw string) string {
+	p := m3u.ProxyPath(raw)
+	switch {
+	case strings.HasPrefix(p, "/rtp/"), strings.HasPrefix(p, "/udp/"):
+		addr, _, _ := strings.Cut(p[len("/rtp/"):], "?")
+		return addr
+	case strings.HasPrefix(p, "/rtsp/"):
+		return "rtsp://" + p[len("/rtsp/"):]
+	}
+	return ""
+}
+
+// mimeType 按上游地址的扩展名判断类型，组播与其他直播流按 MPEG-TS 处理
+func mimeType(raw string) string {
+	p := raw
+	if u, err := url.Parse(raw); err == nil {
+		p = u.Path
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".flv":
+		return "video/x-flv"
+	case ".mp4":
+		return "video/mp4"
+	case ".mkv":
+		return "video/x-matroska"
+	}
+	return "video/mpeg"
+}
@@ -0,0 +1,200 @@
+// Package dlna DLNA/UPnP 媒体服务器：通过 SSDP 在局域网内广播 TVGate，并提供最小的 ContentDirectory 服务，
+// 智能电视等客户端可以直接发现并按分组浏览播放列表中的频道，播放地址指向 TVGate 的代理路径
+package dlna
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+// settings 生效的配置，除 dlna 外还需要代理端口、图标路径与 token 参数名
+type settings struct {
+	config.DLNAConfig
+	port       int    // 代理功能所在的 HTTP 端口
+	logoPath   string // 频道图标路径前缀，未启用图标缓存时为空
+	tokenParam string
+}
+
+// Manager DLNA 服务：SSDP 广播、播放列表读取与 UPnP 接口
+type Manager struct {
+	mu   sync.RWMutex
+	s    settings
+	uuid string
+	stop chan struct{}
+	lib  *library
+}
+
+// New 创建未启用的 DLNA 服务
+func New() *Manager { return &Manager{} }
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg *config.Config) { Default.Configure(cfg) }
+
+// Configure 应用配置，配置未变化时保持运行，变化或禁用时先发送 byebye 再按新配置启动
+func (m *Manager) Configure(cfg *config.Config) {
+	s := settings{DLNAConfig: cfg.DLNA, port: cfg.Server.Port, tokenParam: cfg.GlobalAuth.TokenParamName}
+	if cfg.Server.HTTPPort > 0 {
+		s.port = cfg.Server.HTTPPort
+	}
+	if cfg.Logo.Enabled {
+		s.logoPath = strings.TrimSuffix(cfg.Logo.Path, "/")
+	}
+	if s.tokenParam == "" {
+		s.tokenParam = "my_token"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil && reflect.DeepEqual(m.s, s) {
+		return
+	}
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.s = s
+	m.uuid = deviceUUID(s.UUID)
+	if !s.Enabled {
+		m.lib = nil
+		return
+	}
+	if m.lib == nil {
+		m.lib = newLibrary(nil)
+	}
+	m.stop = make(chan struct{})
+	go m.run(m.stop, s)
+	go m.announce(m.stop, s, m.uuid)
+	logger.LogPrintf("✅ DLNA 服务已启用: %s (uuid:%s)", s.FriendlyName, m.uuid)
+}
+
+// run 定期读取播放列表，频道有变化时更新 SystemUpdateID
+func (m *Manager) run(stop chan struct{}, s settings) {
+	ticker := time.NewTicker(s.Refresh)
+	defer ticker.Stop()
+	for {
+		var entries []m3u.Entry
+		for _, src := range s.Playlists {
+			list, err := m3u.Load(src, s.Timeout)
+			if err != nil {
+				logger.LogPrintf("⚠️ DLNA 读取播放列表 %s 失败: %v", src, err)
+			}
+			entries = append(entries, list...)
+		}
+		// 按 channels 配置的分组顺序与频道号排列
+		config.CfgMu.RLock()
+		channels := config.Cfg.Channels
+		config.CfgMu.RUnlock()
+		entries = m3u.Arrange(entries, &channels)
+		m.mu.Lock()
+		if m.stop == stop && (m.lib == nil || !reflect.DeepEqual(m.lib.entries, entries)) {
+			lib := newLibrary(entries)
+			if m.lib != nil {
+				lib.updateID = m.lib.updateID + 1
+			}
+			m.lib = lib
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deviceUUID 配置的 UUID，未配置时按主机名生成，重启后保持不变
+func deviceUUID(configured string) string {
+	if u := strings.TrimPrefix(strings.TrimSpace(configured), "uuid:"); u != "" {
+		return u
+	}
+	host, _ := os.Hostname()
+	sum := sha1.Sum([]byte("tvgate-dlna:" + host))
+	sum[6] = sum[6]&0x0f | 0x50 // 版本 5
+	return formatUUID(sum[:16])
+}
+
+// formatUUID 把 16 字节格式化为 UUID 字符串
+func formatUUID(b []byte) string {
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// serverHeader SSDP 与 HTTP 响应中的 SERVER 头
+func serverHeader() string {
+	return runtime.GOOS + "/1.0 UPnP/1.0 TVGate/" + config.Version
+}
+
+// Handler 提供设备描述、服务描述、SOAP 控制与事件订阅接口
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		s, uuid, lib := m.s, m.uuid, m.lib
+		m.mu.RUnlock()
+		if lib == nil {
+			http.NotFound(w, r)
+			return
+		}
+		prefix := strings.TrimSuffix(s.Path, "/")
+		w.Header().Set("Server", serverHeader())
+
+		switch name := strings.TrimPrefix(r.URL.Path, prefix+"/"); name {
+		case "device.xml":
+			writeXML(w, deviceDescription(s, uuid, prefix))
+		case "ContentDirectory.xml":
+			writeXML(w, contentDirectorySCPD)
+		case "ConnectionManager.xml":
+			writeXML(w, connectionManagerSCPD)
+		case "control/ContentDirectory", "control/ConnectionManager":
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", http.MethodPost)
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			base := s.BaseURL
+			if base == "" {
+				base = "http://" + r.Host
+			}
+			serveControl(w, r, &browser{lib: lib, s: s, base: strings.TrimSuffix(base, "/")})
+		case "event/ContentDirectory", "event/ConnectionManager":
+			// 不发送事件，仅接受订阅以兼容要求订阅成功的客户端
+			switch r.Method {
+			case "SUBSCRIBE":
+				sid := r.Header.Get("SID")
+				if sid == "" {
+					b := make([]byte, 16)
+					rand.Read(b)
+					b[6] = b[6]&0x0f | 0x40 // 版本 4
+					sid = "uuid:" + formatUUID(b)
+				}
+				w.Header().Set("SID", sid)
+				w.Header().Set("TIMEOUT", "Second-1800")
+			case "UNSUBSCRIBE":
+			default:
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func writeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	fmt.Fprint(w, body)
+}
@@ -0,0 +1,264 @@
+package dlna
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+)
+
+const (
+	contentDirectory  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connectionManager = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// upnpError SOAP Fault 中的 UPnPError
+type upnpError struct {
+	code int
+	desc string
+}
+
+func (e *upnpError) Error() string { return e.desc }
+
+var (
+	errInvalidAction = &upnpError{401, "Invalid Action"}
+	errInvalidArgs   = &upnpError{402, "Invalid Args"}
+	errNoSuchObject  = &upnpError{701, "No such object"}
+	errCannotProcess = &upnpError{720, "Cannot process the request"}
+)
+
+// arg SOAP 响应参数，按 SCPD 中定义的顺序输出
+type arg struct{ name, value string }
+
+// serveControl 处理 SOAP 控制请求，SOAPACTION 形如 "urn:...:ContentDirectory:1#Browse"
+func serveControl(w http.ResponseWriter, r *http.Request, b *browser) {
+	service, action, _ := strings.Cut(strings.Trim(r.Header.Get("SOAPACTION"), `" `), "#")
+	in, err := readArgs(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		writeFault(w, errInvalidArgs)
+		return
+	}
+	out, err := b.call(service, action, in)
+	if err != nil {
+		ue, ok := err.(*upnpError)
+		if !ok {
+			ue = errCannotProcess
+		}
+		writeFault(w, ue)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&buf, `<u:%sResponse xmlns:u="%s">`, action, service)
+	for _, a := range out {
+		fmt.Fprintf(&buf, "<%s>", a.name)
+		xml.EscapeText(&buf, []byte(a.value))
+		fmt.Fprintf(&buf, "</%s>", a.name)
+	}
+	fmt.Fprintf(&buf, `</u:%sResponse></s:Body></s:Envelope>`, action)
+	writeXML(w, buf.String())
+}
+
+// call 执行 SOAP 动作
+func (b *browser) call(service, action string, in map[string]string) ([]arg, error) {
+	switch service + "#" + action {
+	case contentDirectory + "#Browse":
+		start, err1 := strconv.Atoi(in["StartingIndex"])
+		count, err2 := strconv.Atoi(in["RequestedCount"])
+		if err1 != nil || err2 != nil {
+			return nil, errInvalidArgs
+		}
+		result, returned, total, err := b.browse(in["ObjectID"], in["BrowseFlag"], start, count)
+		if err != nil {
+			return nil, err
+		}
+		return []arg{
+			{"Result", result},
+			{"NumberReturned", strconv.Itoa(returned)},
+			{"TotalMatches", strconv.Itoa(total)},
+			{"UpdateID", strconv.FormatUint(uint64(b.lib.updateID), 10)},
+		}, nil
+	case contentDirectory + "#GetSystemUpdateID":
+		return []arg{{"Id", strconv.FormatUint(uint64(b.lib.updateID), 10)}}, nil
+	case contentDirectory + "#GetSearchCapabilities":
+		return []arg{{"SearchCaps", ""}}, nil
+	case contentDirectory + "#GetSortCapabilities":
+		return []arg{{"SortCaps", ""}}, nil
+	case connectionManager + "#GetProtocolInfo":
+		var source []string
+		for _, mime := range []string{"video/mpeg", "application/vnd.apple.mpegurl", "video/x-flv", "video/mp4", "video/x-matroska"} {
+			source = append(source, "http-get:*:"+mime+":"+dlnaFlags)
+		}
+		return []arg{{"Source", strings.Join(source, ",")}, {"Sink", ""}}, nil
+	case connectionManager + "#GetCurrentConnectionIDs":
+		return []arg{{"ConnectionIDs", "0"}}, nil
+	case connectionManager + "#GetCurrentConnectionInfo":
+		return []arg{
+			{"RcsID", "-1"},
+			{"AVTransportID", "-1"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Output"},
+			{"Status", "OK"},
+		}, nil
+	}
+	return nil, errInvalidAction
+}
+
+// readArgs 读取 Envelope/Body/<动作> 下的参数
+func readArgs(r io.Reader) (map[string]string, error) {
+	args := make(map[string]string)
+	dec := xml.NewDecoder(r)
+	depth := 0
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return args, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 4 {
+				name = t.Name.Local
+				args[name] = ""
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 4 {
+				args[name] += string(t)
+			}
+		}
+	}
+}
+
+func writeFault(w http.ResponseWriter, e *upnpError) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`, e.code, e.desc)
+}
+
+// deviceDescription 设备描述文档，服务地址均为相对路径
+func deviceDescription(s settings, uuid, prefix string) string {
+	var name bytes.Buffer
+	xml.EscapeText(&name, []byte(s.FriendlyName))
+	service := func(typ, id string) string {
+		return fmt.Sprintf(`<service><serviceType>%s</serviceType><serviceId>urn:upnp-org:serviceId:%s</serviceId>`+
+			`<SCPDURL>%s/%s.xml</SCPDURL><controlURL>%s/control/%s</controlURL><eventSubURL>%s/event/%s</eventSubURL></service>`,
+			typ, id, prefix, id, prefix, id, prefix, id)
+	}
+	return `<?xml version="1.0" encoding="utf-8"?>` +
+		`<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">` +
+		`<specVersion><major>1</major><minor>0</minor></specVersion><device>` +
+		`<deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType>` +
+		`<friendlyName>` + name.String() + `</friendlyName>` +
+		`<manufacturer>TVGate</manufacturer><manufacturerURL>https://github.com/qist/tvgate</manufacturerURL>` +
+		`<modelName>TVGate</modelName><modelNumber>` + config.Version + `</modelNumber>` +
+		`<UDN>uuid:` + uuid + `</UDN><dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC><serviceList>` +
+		service(contentDirectory, "ContentDirectory") +
+		service(connectionManager, "ConnectionManager") +
+		`</serviceList></device></root>`
+}
+
+// scpd 服务描述文档
+func scpd(actions, vars string) string {
+	return `<?xml version="1.0" encoding="utf-8"?><scpd xmlns="urn:schemas-upnp-org:service-1-0">` +
+		`<specVersion><major>1</major><minor>0</minor></specVersion>` +
+		`<actionList>` + actions + `</actionList><serviceStateTable>` + vars + `</serviceStateTable></scpd>`
+}
+
+// action 生成 SCPD 中的动作，参数形如 "in:ObjectID:A_ARG_TYPE_ObjectID"
+func action(name string, args ...string) string {
+	s := `<action><name>` + name + `</name><argumentList>`
+	for _, a := range args {
+		parts := strings.SplitN(a, ":", 3)
+		s += `<argument><name>` + parts[1] + `</name><direction>` + parts[0] + `</direction>` +
+			`<relatedStateVariable>` + parts[2] + `</relatedStateVariable></argument>`
+	}
+	return s + `</argumentList></action>`
+}
+
+// stateVar 生成 SCPD 中的状态变量，allowed 为允许的取值
+func stateVar(name, typ string, events bool, allowed ...string) string {
+	ev := "no"
+	if events {
+		ev = "yes"
+	}
+	s := `<stateVariable sendEvents="` + ev + `"><name>` + name + `</name><dataType>` + typ + `</dataType>`
+	if len(allowed) > 0 {
+		s += `<allowedValueList>`
+		for _, v := range allowed {
+			s += `<allowedValue>` + v + `</allowedValue>`
+		}
+		s += `</allowedValueList>`
+	}
+	return s + `</stateVariable>`
+}
+
+var contentDirectorySCPD = scpd(
+	action("Browse",
+		"in:ObjectID:A_ARG_TYPE_ObjectID",
+		"in:BrowseFlag:A_ARG_TYPE_BrowseFlag",
+		"in:Filter:A_ARG_TYPE_Filter",
+		"in:StartingIndex:A_ARG_TYPE_Index",
+		"in:RequestedCount:A_ARG_TYPE_Count",
+		"in:SortCriteria:A_ARG_TYPE_SortCriteria",
+		"out:Result:A_ARG_TYPE_Result",
+		"out:NumberReturned:A_ARG_TYPE_Count",
+		"out:TotalMatches:A_ARG_TYPE_Count",
+		"out:UpdateID:A_ARG_TYPE_UpdateID")+
+		action("GetSystemUpdateID", "out:Id:SystemUpdateID")+
+		action("GetSearchCapabilities", "out:SearchCaps:SearchCapabilities")+
+		action("GetSortCapabilities", "out:SortCaps:SortCapabilities"),
+	stateVar("A_ARG_TYPE_ObjectID", "string", false)+
+		stateVar("A_ARG_TYPE_BrowseFlag", "string", false, "BrowseMetadata", "BrowseDirectChildren")+
+		stateVar("A_ARG_TYPE_Filter", "string", false)+
+		stateVar("A_ARG_TYPE_Index", "ui4", false)+
+		stateVar("A_ARG_TYPE_Count", "ui4", false)+
+		stateVar("A_ARG_TYPE_SortCriteria", "string", false)+
+		stateVar("A_ARG_TYPE_Result", "string", false)+
+		stateVar("A_ARG_TYPE_UpdateID", "ui4", false)+
+		stateVar("SearchCapabilities", "string", false)+
+		stateVar("SortCapabilities", "string", false)+
+		stateVar("SystemUpdateID", "ui4", true),
+)
+
+var connectionManagerSCPD = scpd(
+	action("GetProtocolInfo", "out:Source:SourceProtocolInfo", "out:Sink:SinkProtocolInfo")+
+		action("GetCurrentConnectionIDs", "out:ConnectionIDs:CurrentConnectionIDs")+
+		action("GetCurrentConnectionInfo",
+			"in:ConnectionID:A_ARG_TYPE_ConnectionID",
+			"out:RcsID:A_ARG_TYPE_RcsID",
+			"out:AVTransportID:A_ARG_TYPE_AVTransportID",
+			"out:ProtocolInfo:A_ARG_TYPE_ProtocolInfo",
+			"out:PeerConnectionManager:A_ARG_TYPE_ConnectionManager",
+			"out:PeerConnectionID:A_ARG_TYPE_ConnectionID",
+			"out:Direction:A_ARG_TYPE_Direction",
+			"out:Status:A_ARG_TYPE_ConnectionStatus"),
+	stateVar("SourceProtocolInfo", "string", true)+
+		stateVar("SinkProtocolInfo", "string", true)+
+		stateVar("CurrentConnectionIDs", "string", true)+
+		stateVar("A_ARG_TYPE_ConnectionStatus", "string", false, "OK", "ContentFormatMismatch", "InsufficientBandwidth", "UnreliableChannel", "Unknown")+
+		stateVar("A_ARG_TYPE_ConnectionManager", "string", false)+
+		stateVar("A_ARG_TYPE_Direction", "string", false, "Input", "Output")+
+		stateVar("A_ARG_TYPE_ProtocolInfo", "string", false)+
+		stateVar("A_ARG_TYPE_ConnectionID", "i4", false)+
+		stateVar("A_ARG_TYPE_AVTransportID", "i4", false)+
+		stateVar("A_ARG_TYPE_RcsID", "i4", false),
+)
@@ -0,0 +1,238 @@
+package dlna
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	// maxAge SSDP 通告的有效期（秒）
+	maxAge = 1800
+	// aliveInterval 重复发送 ssdp:alive 的间隔，远小于 maxAge 以便新上线的客户端尽快发现
+	aliveInterval = 5 * time.Minute
+)
+
+var ssdpAddr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// targets 通告与响应的类型（NT / ST）
+func targets(uuid string) []string {
+	return []string{
+		"upnp:rootdevice",
+		"uuid:" + uuid,
+		"urn:schemas-upnp-org:device:MediaServer:1",
+		contentDirectory,
+		connectionManager,
+	}
+}
+
+// usn 类型对应的 USN
+func usn(uuid, nt string) string {
+	if nt == "uuid:"+uuid {
+		return nt
+	}
+	return "uuid:" + uuid + "::" + nt
+}
+
+// announce 加入 SSDP 组播组，响应 M-SEARCH 并定期发送 ssdp:alive，停止时发送 ssdp:byebye
+func (m *Manager) announce(stop chan struct{}, s settings, uuid string) {
+	ifaces := interfaces(s.Interfaces)
+	if len(ifaces) == 0 {
+		logger.LogPrintf("⚠️ DLNA 没有可用于组播的网卡，无法发送 SSDP 通告")
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, ssdpAddr)
+	if err != nil {
+		logger.LogPrintf("❌ DLNA 监听 SSDP 端口失败: %v", err)
+		return
+	}
+	pc := ipv4.NewPacketConn(conn)
+	for i := range ifaces {
+		// 默认网卡已在 ListenMulticastUDP 中加入，重复加入的错误忽略
+		_ = pc.JoinGroup(&ifaces[i], ssdpAddr)
+	}
+	send, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		conn.Close()
+		logger.LogPrintf("❌ DLNA 创建 SSDP 发送连接失败: %v", err)
+		return
+	}
+	sp := ipv4.NewPacketConn(send)
+	_ = sp.SetMulticastTTL(2)
+
+	go func() {
+		<-stop
+		notify(sp, ifaces, s, uuid, "ssdp:byebye")
+		conn.Close()
+		send.Close()
+	}()
+	go func() {
+		notify(sp, ifaces, s, uuid, "ssdp:alive")
+		ticker := time.NewTicker(aliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				notify(sp, ifaces, s, uuid, "ssdp:alive")
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 2048)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.LogPrintf("❌ DLNA 读取 SSDP 失败: %v", err)
+			}
+			return
+		}
+		sts, delay, ok := parseSearch(buf[:n], uuid)
+		if !ok {
+			continue
+		}
+		time.AfterFunc(delay, func() {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			location := locationFor(s, localIP(src))
+			for _, st := range sts {
+				_, _ = send.WriteToUDP(searchResponse(location, uuid, st), src)
+			}
+		})
+	}
+}
+
+// parseSearch 解析 M-SEARCH，返回需要响应的类型与随机延迟
+func parseSearch(data []byte, uuid string) ([]string, time.Duration, bool) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || req.Method != "M-SEARCH" || req.Header.Get("MAN") != `"ssdp:discover"` {
+		return nil, 0, false
+	}
+	st := req.Header.Get("ST")
+	all := targets(uuid)
+	var sts []string
+	switch {
+	case st == "ssdp:all":
+		sts = all
+	case slices.Contains(all, st):
+		sts = []string{st}
+	default:
+		return nil, 0, false
+	}
+	// MX 为客户端愿意等待的秒数，随机延迟以免同一网段的设备同时响应
+	mx, _ := strconv.Atoi(req.Header.Get("MX"))
+	mx = min(max(mx, 1), 5)
+	return sts, rand.N(time.Duration(mx) * time.Second), true
+}
+
+func searchResponse(location, uuid, st string) []byte {
+	return []byte("HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=" + strconv.Itoa(maxAge) + "\r\n" +
+		"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+		"EXT:\r\n" +
+		"LOCATION: " + location + "\r\n" +
+		"SERVER: " + serverHeader() + "\r\n" +
+		"ST: " + st + "\r\n" +
+		"USN: " + usn(uuid, st) + "\r\n\r\n")
+}
+
+// notify 在每块网卡上发送 NOTIFY
+func notify(pc *ipv4.PacketConn, ifaces []net.Interface, s settings, uuid, nts string) {
+	for i := range ifaces {
+		ip := interfaceIP(&ifaces[i])
+		if ip == nil {
+			continue
+		}
+		if err := pc.SetMulticastInterface(&ifaces[i]); err != nil {
+			continue
+		}
+		for _, nt := range targets(uuid) {
+			msg := "NOTIFY * HTTP/1.1\r\n" +
+				"HOST: " + ssdpAddr.String() + "\r\n" +
+				"NT: " + nt + "\r\n" +
+				"NTS: " + nts + "\r\n" +
+				"USN: " + usn(uuid, nt) + "\r\n"
+			if nts == "ssdp:alive" {
+				msg += "CACHE-CONTROL: max-age=" + strconv.Itoa(maxAge) + "\r\n" +
+					"LOCATION: " + locationFor(s, ip) + "\r\n" +
+					"SERVER: " + serverHeader() + "\r\n"
+			}
+			if _, err := pc.WriteTo([]byte(msg+"\r\n"), nil, ssdpAddr); err != nil {
+				logger.LogPrintf("⚠️ DLNA 在网卡 %s 上发送 SSDP 通告失败: %v", ifaces[i].Name, err)
+				break
+			}
+		}
+	}
+}
+
+// locationFor 设备描述地址，配置了 base_url 时使用 base_url
+func locationFor(s settings, ip net.IP) string {
+	base := strings.TrimSuffix(s.BaseURL, "/")
+	if base == "" {
+		base = fmt.Sprintf("http://%s", net.JoinHostPort(ip.String(), strconv.Itoa(s.port)))
+	}
+	return base + strings.TrimSuffix(s.Path, "/") + "/device.xml"
+}
+
+// localIP 访问 remote 时使用的本机地址，UDP 连接不会实际发送数据
+func localIP(remote *net.UDPAddr) net.IP {
+	c, err := net.DialUDP("udp4", nil, remote)
+	if err != nil {
+		return net.IPv4zero
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP
+}
+
+// interfaces 收发 SSDP 的网卡：配置的网卡，未配置时为所有已启用、支持组播的非回环网卡
+func interfaces(names []string) []net.Interface {
+	all, err := net.Interfaces()
+	if err != nil {
+		logger.LogPrintf("⚠️ DLNA 获取网卡列表失败: %v", err)
+		return nil
+	}
+	var out []net.Interface
+	for _, ifi := range all {
+		if len(names) > 0 {
+			if slices.Contains(names, ifi.Name) {
+				out = append(out, ifi)
+			}
+			continue
+		}
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 && ifi.Flags&net.FlagLoopback == 0 && interfaceIP(&ifi) != nil {
+			out = append(out, ifi)
+		}
+	}
+	return out
+}
+
+// interfaceIP 网卡的第一个 IPv4 地址
+func interfaceIP(ifi *net.Interface) net.IP {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+			return n.IP.To4()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存默认参数
+const (
+	defaultCacheSize = 4096
+	defaultMinTTL    = 5 * time.Second
+	defaultMaxTTL    = 10 * time.Minute
+	// 系统解析器不返回 TTL，使用固定缓存时间
+	systemResolverTTL = 30 * time.Second
+)
+
+// ttlRecorder 通过 context 传递给各 DNS 客户端，记录应答中的最小 TTL
+type ttlRecorder struct {
+	mu  sync.Mutex
+	ttl uint32
+	set bool
+}
+
+type ttlRecorderKey struct{}
+
+func withTTLRecorder(ctx context.Context) (context.Context, *ttlRecorder) {
+	rec := &ttlRecorder{}
+	return context.WithValue(ctx, ttlRecorderKey{}, rec), rec
+}
+
+// recordTTL 记录一次应答的 TTL，多次记录取最小值
+func recordTTL(ctx context.Context, ttl uint32) {
+	rec, ok := ctx.Value(ttlRecorderKey{}).(*ttlRecorder)
+	if !ok {
+		return
+	}
+	rec.mu.Lock()
+	if !rec.set || ttl < rec.ttl {
+		rec.ttl = ttl
+		rec.set = true
+	}
+	rec.mu.Unlock()
+}
+
+func (rec *ttlRecorder) get() (time.Duration, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return time.Duration(rec.ttl) * time.Second, rec.set
+}
+
+// dnsCache 按 TTL 过期的解析结果缓存
+type dnsCache struct {
+	mu      sync.Mutex
+	size    int
+	minTTL  time.Duration
+	maxTTL  time.Duration
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs    []net.IPAddr
+	expireAt time.Time
+}
+
+// newDNSCache size<0 表示关闭缓存
+func newDNSCache(size int, minTTL, maxTTL time.Duration) *dnsCache {
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		size = defaultCacheSize
+	}
+	if minTTL <= 0 {
+		minTTL = defaultMinTTL
+	}
+	if maxTTL <= 0 {
+		maxTTL = defaultMaxTTL
+	}
+	if maxTTL < minTTL {
+		maxTTL = minTTL
+	}
+	return &dnsCache{
+		size:    size,
+		minTTL:  minTTL,
+		maxTTL:  maxTTL,
+		entries: make(map[string]dnsCacheEntry),
+	}
+}
+
+func (c *dnsCache) get(host string) ([]net.IPAddr, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[host]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expireAt) {
+		delete(c.entries, host)
+		return nil, false
+	}
+	return e.addrs, true
+}
+
+func (c *dnsCache) put(host string, addrs []net.IPAddr, ttl time.Duration) {
+	if c == nil || len(addrs) == 0 {
+		return
+	}
+	if ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[host]; !exists && len(c.entries) >= c.size {
+		// 先清理过期项，仍然满时随机淘汰一项
+		for k, e := range c.entries {
+			if now.After(e.expireAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			for k := range c.entries {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+	c.entries[host] = dnsCacheEntry{addrs: addrs, expireAt: now.Add(ttl)}
+}
+
+// parseHosts 解析静态 hosts 配置，域名不区分大小写
+func parseHosts(hosts map[string][]string) map[string][]net.IPAddr {
+	result := make(map[string][]net.IPAddr, len(hosts))
+	for host, ips := range hosts {
+		var addrs []net.IPAddr
+		for _, s := range ips {
+			if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+				addrs = append(addrs, net.IPAddr{IP: ip})
+			}
+		}
+		if len(addrs) > 0 {
+			result[strings.ToLower(strings.TrimSuffix(host, "."))] = addrs
+		}
+	}
+	return result
+}
+
+// 代理组专用解析器，按服务器列表复用
+var (
+	groupResolversMu sync.Mutex
+	groupResolvers   = make(map[string]*Resolver)
+)
+
+// ForServers 返回使用指定 DNS 服务器的解析器（静态 hosts 与缓存参数沿用全局配置），
+// servers 为空时返回全局解析器
+func ForServers(servers []string) *Resolver {
+	if len(servers) == 0 {
+		return GetInstance()
+	}
+	key := strings.Join(servers, ",")
+
+	groupResolversMu.Lock()
+	defer groupResolversMu.Unlock()
+	if r, ok := groupResolvers[key]; ok {
+		return r
+	}
+	r := &Resolver{
+		systemResolver: &net.Resolver{
+			PreferGo:     true,
+			StrictErrors: false,
+		},
+	}
+	r.loadServers(servers)
+	groupResolvers[key] = r
+	return r
+}
+
+// resetGroupResolvers 配置变更后丢弃代理组解析器，下次使用时按新配置重建
+func resetGroupResolvers() {
+	groupResolversMu.Lock()
+	groupResolvers = make(map[string]*Resolver)
+	groupResolversMu.Unlock()
+}
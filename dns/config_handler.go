@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"reflect"
+
 	"github.com/qist/tvgate/config"
 )
 
@@ -35,6 +37,14 @@ func isDNSConfigChanged(oldCfg, newCfg *config.Config) bool {
 	if oldCfg.DNS.MaxConns != newCfg.DNS.MaxConns {
 		return true
 	}
+
+	// 检查静态 hosts 与缓存配置是否发生变化
+	if !reflect.DeepEqual(oldCfg.DNS.Hosts, newCfg.DNS.Hosts) ||
+		oldCfg.DNS.CacheSize != newCfg.DNS.CacheSize ||
+		oldCfg.DNS.MinTTL != newCfg.DNS.MinTTL ||
+		oldCfg.DNS.MaxTTL != newCfg.DNS.MaxTTL {
+		return true
+	}
 	
 	return false
 }
\ No newline at end of file
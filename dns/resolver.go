@@ -86,6 +86,8 @@ type Resolver struct {
 	resolvers      []string
 	timeout        time.Duration
 	maxConns       int
+	hosts          map[string][]net.IPAddr
+	cache          *dnsCache
 	mutex          sync.RWMutex
 }
 
@@ -111,13 +113,18 @@ func GetInstance() *Resolver {
 // --------------------------- 配置加载 ---------------------------
 
 func (r *Resolver) loadConfig() {
-	cfg := config.Cfg
+	r.loadServers(config.Cfg.DNS.Servers)
+}
+
+// loadServers 使用指定的 DNS 服务器列表初始化解析器，超时、hosts、缓存等参数取全局 DNS 配置
+func (r *Resolver) loadServers(servers []string) {
+	dnsCfg := config.Cfg.DNS
 
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	r.timeout = cfg.DNS.Timeout
-	r.maxConns = cfg.DNS.MaxConns
+	r.timeout = dnsCfg.Timeout
+	r.maxConns = dnsCfg.MaxConns
 	if r.timeout == 0 {
 		r.timeout = 5 * time.Second
 	}
@@ -126,12 +133,15 @@ func (r *Resolver) loadConfig() {
 		r.maxConns = 10 // 默认最大连接数
 	}
 
-	if len(cfg.DNS.Servers) > 0 {
-		r.resolvers = make([]string, len(cfg.DNS.Servers))
-		copy(r.resolvers, cfg.DNS.Servers)
+	r.hosts = parseHosts(dnsCfg.Hosts)
+	r.cache = newDNSCache(dnsCfg.CacheSize, dnsCfg.MinTTL, dnsCfg.MaxTTL)
 
-		r.clients = make([]dnsClient, 0, len(cfg.DNS.Servers))
-		for _, resolver := range cfg.DNS.Servers {
+	if len(servers) > 0 {
+		r.resolvers = make([]string, len(servers))
+		copy(r.resolvers, servers)
+
+		r.clients = make([]dnsClient, 0, len(servers))
+		for _, resolver := range servers {
 			client, err := createDNSClient(resolver, r.timeout, r.maxConns)
 			if err == nil {
 				r.clients = append(r.clients, client)
@@ -199,33 +209,9 @@ func isDNSStamp(server string) bool {
 // --------------------------- 查询接口 ---------------------------
 
 func (r *Resolver) LookupIP(host string) ([]net.IP, error) {
-	r.mutex.RLock()
-	timeout := r.timeout
-	clients := r.clients
-	r.mutex.RUnlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// 首先尝试配置的DNS解析器
-	for _, client := range clients {
-		ips, err := client.LookupIPAddr(ctx, host)
-		if err == nil {
-			result := make([]net.IP, len(ips))
-			for i, ip := range ips {
-				result[i] = ip.IP
-			}
-			return result, nil
-		}
-		// 即使有错误也继续尝试下一个解析器
-	}
-
-	// 如果配置的DNS解析失败或超时，回退到系统解析器
-	// 创建一个新的context，不带超时，让系统解析器自己处理
-	systemCtx := context.Background()
-	ips, err := r.systemResolver.LookupIPAddr(systemCtx, host)
+	ips, err := r.LookupIPAddr(context.Background(), host)
 	if err != nil {
-		return nil, fmt.Errorf("both configured and system DNS resolvers failed: %v", err)
+		return nil, err
 	}
 
 	result := make([]net.IP, len(ips))
@@ -235,20 +221,37 @@ func (r *Resolver) LookupIP(host string) ([]net.IP, error) {
 	return result, nil
 }
 
+// LookupIPAddr 依次查询静态 hosts、缓存、配置的 DNS 服务器，最后回退到系统解析器
 func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
 	r.mutex.RLock()
 	timeout := r.timeout
 	clients := r.clients
+	hosts := r.hosts
+	cache := r.cache
 	r.mutex.RUnlock()
 
+	key := strings.ToLower(strings.TrimSuffix(host, "."))
+	if ips, ok := hosts[key]; ok {
+		return ips, nil
+	}
+	if ips, ok := cache.get(key); ok {
+		return ips, nil
+	}
+
 	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// 首先尝试配置的DNS解析器
 	for _, client := range clients {
-		ips, err := client.LookupIPAddr(ctx, host)
+		clientCtx, rec := withTTLRecorder(lookupCtx)
+		ips, err := client.LookupIPAddr(clientCtx, host)
 		if err == nil {
+			ttl, ok := rec.get()
+			if !ok {
+				ttl = systemResolverTTL
+			}
+			cache.put(key, ips, ttl)
 			return ips, nil
 		}
 		// 即使有错误也继续尝试下一个解析器
@@ -260,6 +263,7 @@ func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr,
 	if err != nil {
 		return nil, fmt.Errorf("both configured and system DNS resolvers failed: %v", err)
 	}
+	cache.put(key, ips, systemResolverTTL)
 
 	return ips, nil
 }
@@ -267,6 +271,7 @@ func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr,
 // RefreshConfig 刷新配置
 func (r *Resolver) RefreshConfig() {
 	r.loadConfig()
+	resetGroupResolvers()
 }
 
 // GetResolvers 获取当前 DNS 列表
@@ -373,7 +378,7 @@ func (r *Resolver) GetSystemResolver() *net.Resolver {
 // --------------------------- 具体客户端实现 ---------------------------
 
 // 解析响应
-func parseDNSResponse(respBytes []byte) ([]net.IPAddr, error) {
+func parseDNSResponse(ctx context.Context, respBytes []byte) ([]net.IPAddr, error) {
 	respMsg := &dns.Msg{}
 	if err := respMsg.Unpack(respBytes); err != nil {
 		return nil, fmt.Errorf("解包DNS响应失败: %w", err)
@@ -389,8 +394,10 @@ func parseDNSResponse(respBytes []byte) ([]net.IPAddr, error) {
 		switch v := answer.(type) {
 		case *dns.A:
 			addrs = append(addrs, net.IPAddr{IP: v.A})
+			recordTTL(ctx, v.Hdr.Ttl)
 		case *dns.AAAA:
 			addrs = append(addrs, net.IPAddr{IP: v.AAAA})
+			recordTTL(ctx, v.Hdr.Ttl)
 		}
 	}
 
@@ -452,7 +459,7 @@ func (c *plainDNSClient) LookupIPAddr(ctx context.Context, host string) ([]net.I
 	}
 
 	// 解析响应
-	addrs, err := parseDNSResponse(respBytes)
+	addrs, err := parseDNSResponse(ctx, respBytes)
 	if err != nil {
 		return nil, fmt.Errorf("解析DNS响应失败: %w", err)
 	}
@@ -529,7 +536,7 @@ func (c *dohClient) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr
 	}
 
 	// 解析响应
-	addrs, err := parseDNSResponse(respBytes)
+	addrs, err := parseDNSResponse(ctx, respBytes)
 	if err != nil {
 		return nil, fmt.Errorf("解析DoH响应失败: %w", err)
 	}
@@ -589,7 +596,7 @@ func (c *doh3Client) LookupIPAddr(ctx context.Context, host string) ([]net.IPAdd
 	}
 
 	// 解析响应
-	addrs, err := parseDNSResponse(respBytes)
+	addrs, err := parseDNSResponse(ctx, respBytes)
 	if err != nil {
 		return nil, fmt.Errorf("解析DoH3响应失败: %w", err)
 	}
@@ -763,7 +770,7 @@ func (c *dotClient) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr
 	}
 
 	// 解析响应
-	addrs, err := parseDNSResponse(resp[2 : 2+respLen])
+	addrs, err := parseDNSResponse(ctx, resp[2 : 2+respLen])
 	if err != nil {
 		return nil, fmt.Errorf("解析DoT响应失败: %w", err)
 	}
@@ -835,7 +842,7 @@ func (c *quicClient) LookupIPAddr(ctx context.Context, host string) ([]net.IPAdd
 	}
 
 	// 解析响应
-	addrs, err := parseDNSResponse(respBytes)
+	addrs, err := parseDNSResponse(ctx, respBytes)
 	if err != nil {
 		return nil, fmt.Errorf("解析QUIC响应失败: %w", err)
 	}
@@ -976,7 +983,7 @@ func sendCryptQuery(ctx context.Context, dnsServer, b64 string) ([]net.IPAddr, e
 		return nil, fmt.Errorf("打包DNS响应失败: %w", err)
 	}
 
-	return parseDNSResponse(packedResp)
+	return parseDNSResponse(ctx, packedResp)
 }
 
 // --------------------------- UDP/TCP 通用查询 ---------------------------
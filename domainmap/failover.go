@@ -0,0 +1,78 @@
+package domainmap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/logger"
+)
+
+// 备用目标默认下线时长
+const defaultFailoverCooldown = 30 * time.Second
+
+// targetHealth 单个目标的被动健康状态：请求失败即下线一段时间，到期后重新参与尝试
+type targetHealth struct {
+	downUntil time.Time
+	failures  int
+}
+
+// 按 映射名|目标 记录，配置重新加载后保留
+var (
+	targetHealthMu sync.Mutex
+	targetHealths  = make(map[string]*targetHealth)
+)
+
+func targetHealthKey(cfg *auth.DomainMapConfig, target string) string {
+	return cfg.Name + "|" + target
+}
+
+// orderedTargets 返回按顺序尝试的目标：主目标在前，备用目标随后；
+// 处于下线期的目标移到最后，全部下线时仍按原顺序尝试
+func orderedTargets(cfg *auth.DomainMapConfig, primary string) []string {
+	all := append([]string{primary}, cfg.Targets...)
+	if len(all) == 1 {
+		return all
+	}
+	now := time.Now()
+	var up, down []string
+	targetHealthMu.Lock()
+	for _, t := range all {
+		if h, ok := targetHealths[targetHealthKey(cfg, t)]; ok && now.Before(h.downUntil) {
+			down = append(down, t)
+		} else {
+			up = append(up, t)
+		}
+	}
+	targetHealthMu.Unlock()
+	return append(up, down...)
+}
+
+// markTarget 记录目标请求结果，仅配置了备用目标的映射记录
+func markTarget(cfg *auth.DomainMapConfig, target string, ok bool) {
+	if len(cfg.Targets) == 0 {
+		return
+	}
+	key := targetHealthKey(cfg, target)
+	targetHealthMu.Lock()
+	defer targetHealthMu.Unlock()
+	h, exists := targetHealths[key]
+	if ok {
+		if exists && h.failures > 0 {
+			logger.LogPrintf("✅ 域名映射 %s 目标 %s 已恢复", cfg.Name, target)
+		}
+		delete(targetHealths, key)
+		return
+	}
+	if !exists {
+		h = &targetHealth{}
+		targetHealths[key] = h
+	}
+	cooldown := cfg.FailoverCooldown
+	if cooldown <= 0 {
+		cooldown = defaultFailoverCooldown
+	}
+	h.failures++
+	h.downUntil = time.Now().Add(cooldown)
+	logger.LogPrintf("🚫 域名映射 %s 目标 %s 不可用（连续失败 %d 次），%s 内优先使用其他目标", cfg.Name, target, h.failures, cooldown)
+}
@@ -21,7 +21,9 @@ import (
 
 	"github.com/qist/tvgate/auth"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
 	"github.com/qist/tvgate/dns"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/lb"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
@@ -29,6 +31,7 @@ import (
 	"github.com/qist/tvgate/rules"
 	"github.com/qist/tvgate/stream"
 	"github.com/qist/tvgate/utils/buffer"
+	"github.com/qist/tvgate/utils/headers"
 )
 
 // ---------------------------
@@ -67,12 +70,17 @@ func chooseScheme(protocol string, r *http.Request) string {
 
 type DomainMapper struct {
 	mappings        auth.DomainMapList
+	patterns        []sourcePattern // 通配符/正则 source
 	client          *http.Client
 	next            http.Handler
 	redirectHandler *RedirectHandler
 	tokenManagers   map[string]*auth.TokenManager
-	realHostMap     map[string]string // 真实地址映射表
-	realHostMapMu   sync.RWMutex      // 保护realHostMap的互斥锁
+	realHostMap     map[string]string                     // 真实地址映射表
+	realHostMapMu   sync.RWMutex                          // 保护realHostMap的互斥锁
+	tlsConfigs      map[*auth.DomainMapConfig]*tls.Config // 各映射的后端 TLS 配置
+	tlsConfigsMu    sync.Mutex
+	rewriters       map[*auth.DomainMapConfig]*bodyRewriter // 各映射的响应体改写规则
+	rewritersMu     sync.Mutex
 }
 
 type RedirectHandler struct {
@@ -93,6 +101,7 @@ func NewDomainMapper(mappings auth.DomainMapList, client *http.Client, next http
 
 	dm := &DomainMapper{
 		mappings:      mappings,
+		patterns:      compileSourcePatterns(mappings),
 		client:        client,
 		next:          next,
 		tokenManagers: make(map[string]*auth.TokenManager),
@@ -119,35 +128,23 @@ func NewDomainMapper(mappings auth.DomainMapList, client *http.Client, next http
 // ---------------------------
 
 func (dm *DomainMapper) MapDomain(host string) (string, string, bool) {
-	hostWithoutPort := host
-	if idx := strings.Index(host, ":"); idx != -1 {
-		hostWithoutPort = host[:idx]
-	}
-	for _, mapping := range dm.mappings {
-		if mapping.Source == hostWithoutPort {
-			return mapping.Target, mapping.Protocol, true
-		}
+	mapping, target, found := dm.matchMapping(host)
+	if !found {
+		return "", "", false
 	}
-	return "", "", false
+	return target, mapping.Protocol, true
 }
 
 func (dm *DomainMapper) GetDomainConfig(host string) *auth.DomainMapConfig {
-	hostWithoutPort := host
-	if idx := strings.Index(host, ":"); idx != -1 {
-		hostWithoutPort = host[:idx]
-	}
-	for _, mapping := range dm.mappings {
-		if mapping.Source == hostWithoutPort {
-			return mapping
-		}
-	}
-	return nil
+	mapping, _, _ := dm.matchMapping(host)
+	return mapping
 }
 
 // ReverseMapDomain 反向映射域名，将target转换为source
 func (dm *DomainMapper) ReverseMapDomain(targetHost string) (string, string, bool) {
 	for _, mapping := range dm.mappings {
-		if mapping.Target == targetHost {
+		// 通配符/正则映射无法反推 source
+		if mapping.Target == targetHost && !isSourcePattern(mapping.Source) {
 			return mapping.Source, mapping.Protocol, true
 		}
 	}
@@ -324,8 +321,12 @@ func (dm *DomainMapper) CleanTokenManagers() {
 			continue
 		}
 
-		// 检查该 host 是否还在当前的域名映射配置中
-		if cfg := dm.GetDomainConfig(host); cfg == nil {
+		// 检查该 host 是否还在当前的域名映射配置中（key 可能带有路径前缀）
+		hostOnly := host
+		if i := strings.Index(host, "/"); i >= 0 {
+			hostOnly = host[:i]
+		}
+		if cfg := dm.GetDomainConfig(hostOnly); cfg == nil {
 			// 如果不在配置中，清理这个 tokenManager
 			// 调用CleanupExpiredSessions方法清理过期会话
 			tm.CleanupExpiredSessions()
@@ -334,6 +335,11 @@ func (dm *DomainMapper) CleanTokenManagers() {
 	}
 }
 
+// tokenManagerKey 同一域名下按路径前缀区分的映射各自使用独立的 TokenManager
+func tokenManagerKey(host string, cfg *auth.DomainMapConfig) string {
+	return host + strings.TrimSuffix(cfg.PathPrefix, "/")
+}
+
 // AddRealHostMapping 添加真实地址映射
 func (dm *DomainMapper) AddRealHostMapping(realHost, sourceHost string) {
 	dm.realHostMapMu.Lock()
@@ -555,17 +561,15 @@ func (dm *DomainMapper) replaceSpecialNestedURLClean(
 
 func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer dm.CleanTokenManagers()
-	targetHost, protocol, found := dm.MapDomain(r.Host)
+	cfg, targetHost, found := dm.matchRoute(r.Host, r.URL.Path, false)
 	// logger.LogPrintf("映射域名: %s -> %s", r.Host, targetHost)
 	if !found {
 		dm.next.ServeHTTP(w, r)
 		return
 	}
-	cfg := dm.GetDomainConfig(r.Host)
-	if cfg == nil {
-		dm.next.ServeHTTP(w, r)
-		return
-	}
+	protocol := cfg.Protocol
+	// 后端请求路径（按 path_prefix/strip_prefix/target_path 改写）
+	backendPath := rewriteTargetPath(cfg, r.URL.Path)
 
 	// 获取sourceHost（即r.Host对应的source地址）
 	sourceHost := r.Host
@@ -580,14 +584,14 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	clientIP := ""
 	connID := ""
 	// 优先使用domainmap本地配置，没有配置才是全局认证配置
-	if cfg.Auth.TokensEnabled && (cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic) {
+	if cfg.Auth.TokensEnabled && (cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic || cfg.Auth.JWTTokens.EnableJWT || cfg.Auth.SignedURL.EnableSigned) {
 		// 使用domainmap本地配置
 		tokenParam = cfg.Auth.TokenParamName
 		if tokenParam == "" {
 			tokenParam = "token"
 		}
 		token = r.URL.Query().Get(tokenParam)
-	} else if globalTm := auth.GetGlobalTokenManager(); globalTm != nil && globalTm.Enabled && (globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0) {
+	} else if globalTm := auth.GetGlobalTokenManager(); globalTm != nil && globalTm.Enabled && (globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0 || globalTm.JWT != nil || globalTm.Signed != nil || globalTm.HasIssuedTokens()) {
 		// 使用全局token管理器的参数名
 		tokenParam = globalTm.TokenParamName
 		if tokenParam == "" {
@@ -613,11 +617,11 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if protocol == "rtsp" {
 		// 统一认证逻辑
-		clientIP := monitor.GetClientIP(r)
+		clientIP = monitor.GetClientIP(r)
 		connID = clientIP + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
 
 	} else {
-		clientIP := monitor.GetClientIP(r)
+		clientIP = monitor.GetClientIP(r)
 		frontendScheme := getRequestScheme(r)
 		// targetURL 是用于前端显示的URL（始终显示为source地址）
 		targetURL := &url.URL{
@@ -636,14 +640,23 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	// 请求/响应头改写：先执行全局规则，再执行映射自身的规则
+	globalRules := headers.GlobalRules()
+	if len(globalRules) > 0 || len(cfg.HeaderRules) > 0 {
+		headerRules := make([]config.HeaderRule, 0, len(globalRules)+len(cfg.HeaderRules))
+		headerRules = append(append(headerRules, globalRules...), cfg.HeaderRules...)
+		vars := headers.NewVars(r, monitor.GetClientIP(r), token)
+		r.Header = headers.ApplyRequest(headerRules, r.URL.Path, r.Header, vars)
+		w = headers.WrapResponse(w, headerRules, r.URL.Path, vars)
+	}
 	// 验证token - 统一处理HTTP和RTSP
 	if cfg.Auth.TokensEnabled {
 		// 如果domainmap配置了授权且启用了tokens，则进行验证
-		if cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic {
+		if cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic || cfg.Auth.JWTTokens.EnableJWT || cfg.Auth.SignedURL.EnableSigned {
 			var tm *auth.TokenManager
 
 			// 使用host作为key来获取TokenManager
-			host := r.Host
+			host := tokenManagerKey(r.Host, cfg)
 			if existingTm, ok := dm.tokenManagers[host]; ok {
 				tm = existingTm
 			} else {
@@ -652,7 +665,7 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// 验证token
-			if !tm.ValidateToken(token, r.URL.Path, connID) {
+			if !tm.ValidateSignedURL(r) && !tm.ValidateToken(r, token, connID, clientIP) {
 				http.Error(w, "Forbidden", http.StatusUnauthorized)
 				return
 			}
@@ -664,9 +677,9 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// 2. 只有当domainmap没有开启授权时，才检查全局认证
 		if globalTm := auth.GetGlobalTokenManager(); globalTm != nil && globalTm.Enabled {
 			// 如果全局认证启用且配置了动态或静态token，则进行验证
-			if globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0 {
+			if globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0 || globalTm.JWT != nil || globalTm.Signed != nil || globalTm.HasIssuedTokens() {
 				// 验证token
-				if !globalTm.ValidateToken(token, r.URL.Path, connID) {
+				if !globalTm.ValidateSignedURL(r) && !globalTm.ValidateToken(r, token, connID, clientIP) {
 					http.Error(w, "Forbidden", http.StatusUnauthorized)
 					return
 				}
@@ -681,7 +694,7 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 如果协议是RTSP，则转发给RTSP处理器
 	if protocol == "rtsp" {
 		// 构造新的URL路径，格式为 /rtsp/{targetHost}{原始路径}
-		newPath := "/rtsp/" + targetHost + r.URL.Path
+		newPath := "/rtsp/" + targetHost + backendPath
 
 		// 创建新的请求
 		newReq := r.Clone(r.Context())
@@ -715,7 +728,7 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// logger.LogPrintf("RTSP → HTTP request: %s", newReq.URL.String())
 		// logger.LogPrintf("RTSP → HTTP request: %s", connID)
 		// 转发给下一个处理器（即RTSP处理器）
-		RtspToHTTPHandler(w, newReq, connID)
+		RtspToHTTPHandler(w, newReq, connID, token)
 		return
 	}
 
@@ -744,7 +757,7 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	originalURL := &url.URL{
 		Scheme:   chooseScheme(protocol, r),
 		Host:     backendHost, // 使用映射后的target地址或默认target地址
-		Path:     r.URL.Path,
+		Path:     backendPath,
 		RawQuery: r.URL.RawQuery,
 	}
 
@@ -758,16 +771,16 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var tm *auth.TokenManager
 
-	if cfg.Auth.TokensEnabled && (cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic) {
+	if cfg.Auth.TokensEnabled && (cfg.Auth.DynamicTokens.EnableDynamic || cfg.Auth.StaticTokens.EnableStatic || cfg.Auth.JWTTokens.EnableJWT || cfg.Auth.SignedURL.EnableSigned) {
 		// 检查是否启用了静态token但没有提供token参数
-		if cfg.Auth.StaticTokens.EnableStatic && !cfg.Auth.DynamicTokens.EnableDynamic && token == "" {
+		if cfg.Auth.StaticTokens.EnableStatic && !cfg.Auth.DynamicTokens.EnableDynamic && !cfg.Auth.SignedURL.EnableSigned && token == "" {
 			// 如果只启用了静态token但没有提供token参数，则拒绝访问
 			http.Error(w, "Forbidden", http.StatusUnauthorized)
 			return
 		}
 
 		// 使用host作为key来获取TokenManager
-		host := r.Host
+		host := tokenManagerKey(r.Host, cfg)
 		if existingTm, ok := dm.tokenManagers[host]; ok {
 			tm = existingTm
 		} else {
@@ -776,15 +789,31 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	// logger.LogPrintf("connID: %s", connID)
+	// 并发连接数限制，已满时已返回 503
+	release := connlimit.Acquire(w, r)
+	if release == nil {
+		return
+	}
+	defer release()
+
 	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
 		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
 		URL:            targetURL.String(),
 		UserAgent:      r.UserAgent(),
 		ConnectionType: strings.ToUpper(targetURL.Scheme),
 		ConnectedAt:    time.Now(),
 		LastActive:     time.Now(),
+		Token:          token,
 	})
 	defer monitor.ActiveClients.Unregister(connID, strings.ToUpper(targetURL.Scheme))
+
+	// 会话被踢出时结束转发
+	kickCtx, kick := context.WithCancel(r.Context())
+	defer kick()
+	r = r.WithContext(kickCtx)
+	monitor.ActiveClients.SetCancel(connID, kick)
+	w = monitor.ActiveClients.CountBytes(connID, w)
 	updateActive := func() {
 		monitor.ActiveClients.UpdateLastActive(connID, time.Now())
 	}
@@ -817,7 +846,7 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// 使用全局认证配置处理token参数
 		if globalTm := auth.GetGlobalTokenManager(); globalTm != nil && globalTm.Enabled {
 			// 如果全局认证启用且配置了动态或静态token，则移除token参数
-			if globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0 {
+			if globalTm.DynamicConfig != nil || len(globalTm.StaticTokens) > 0 || globalTm.JWT != nil || globalTm.Signed != nil || globalTm.HasIssuedTokens() {
 				q := originalReqURL.Query()
 				tokenParamToRemove := "my_token"
 				// 优先使用domainmap本地配置
@@ -849,6 +878,23 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	config.Cfg.SetDefaults()
 	resolver := dns.GetInstance()
 
+	// 映射自身的超时配置优先于全局 http 配置
+	if cfg.ConnectTimeout > 0 {
+		httpCfg.ConnectTimeout = cfg.ConnectTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		httpCfg.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.Timeout > 0 {
+		httpCfg.Timeout = cfg.Timeout
+	}
+	tlsConf, err := dm.mappingTLSConfig(cfg)
+	if err != nil {
+		logger.LogPrintf("❌ 域名映射 %s TLS 配置错误: %v", cfg.Name, err)
+		http.Error(w, "后端 TLS 配置错误", http.StatusBadGateway)
+		return
+	}
+
 	dialer := &net.Dialer{
 		Timeout:   httpCfg.ConnectTimeout,
 		KeepAlive: httpCfg.KeepAlive,
@@ -886,94 +932,65 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		MaxIdleConnsPerHost:   httpCfg.MaxIdleConnsPerHost,
 		MaxConnsPerHost:       httpCfg.MaxConnsPerHost,
 		DisableKeepAlives:     httpCfg.DisableKeepAlives,
-		TLSClientConfig:       &tls.Config{InsecureSkipVerify: false},
+		TLSClientConfig:       tlsConf,
 	}
 
-	// 创建 Client
+	// 创建 Client（协议升级后的长连接不能受整体请求超时限制）
+	clientTimeout := httpCfg.Timeout
+	if isUpgradeRequest(r) {
+		clientTimeout = 0
+	}
 	client := &http.Client{
 		Transport: baseTransport,
-		Timeout:   httpCfg.Timeout,
+		Timeout:   clientTimeout,
 	}
 
-	// ---------- 处理代理或直连 ----------
-	pg := rules.ChooseProxyGroup(originalURL.Hostname(), targetHost)
-	var resp *http.Response
-	var err error
-
-	if pg != nil {
-		maxRetries := pg.MaxRetries
-		if maxRetries <= 0 {
-			maxRetries = 1
-		}
-		retryDelay := pg.RetryDelay
-
-		for attempt := 0; attempt <= maxRetries; attempt++ {
-			forceTest := attempt > 0
-			selectedProxy := lb.SelectProxy(pg, originalReqURL.String(), forceTest)
-
-			clientToUse := client
-			if selectedProxy != nil {
-				if proxyDialer, dErr := proxy.CreateProxyDialer(*selectedProxy); dErr == nil {
-					baseTransport.DialContext = proxyDialer.DialContext
-					clientToUse = &http.Client{
-						Transport: baseTransport,
-						Timeout:   httpCfg.Timeout,
-					}
-				}
-			}
-
-			targetReq, _ := http.NewRequest(r.Method, originalReqURL.String(), bytes.NewReader(reqBodyBytes))
-			for name, values := range r.Header {
-				if strings.ToLower(name) == "host" {
-					continue
-				}
-				for _, v := range values {
-					targetReq.Header.Add(name, v)
-				}
-			}
-
-			for k, v := range cfg.ServerHeaders {
-				if v != "" {
-					targetReq.Header.Set(k, v)
-				}
-			}
-			targetReq.Header.Set("Host", targetHost)
+	// 需要改写响应体时要求上游返回未压缩内容
+	rewriter := dm.bodyRewriterFor(cfg)
+	if rewriter != nil {
+		r.Header.Del("Accept-Encoding")
+	}
 
-			resp, err = dm.doWithRedirect(clientToUse, targetReq, 10, frontendScheme, r.Host, tokenParam)
-			if err == nil {
-				break
-			}
-			if attempt == maxRetries {
-				http.Error(w, fmt.Sprintf("代理请求失败: %v", err), http.StatusBadGateway)
-				return
-			}
-			time.Sleep(retryDelay)
-		}
-	} else {
-		targetReq, _ := http.NewRequest(r.Method, originalReqURL.String(), bytes.NewReader(reqBodyBytes))
-		for name, values := range r.Header {
-			if strings.ToLower(name) == "host" {
-				continue
-			}
-			for _, v := range values {
-				targetReq.Header.Add(name, v)
-			}
+	// ---------- 处理代理或直连，失败时依次切换备用目标 ----------
+	var resp *http.Response
+	releaseConn := func() {}
+	defer func() { releaseConn() }()
+
+	candidates := orderedTargets(cfg, targetHost)
+	for i, candidate := range candidates {
+		reqURL := originalReqURL
+		if candidate != targetHost {
+			reqURL.Host = candidate
 		}
-		for k, v := range cfg.ServerHeaders {
-			if v != "" {
-				targetReq.Header.Set(k, v)
-			}
+		var release func()
+		resp, release, err = dm.fetchUpstream(r, cfg, client, baseTransport, &reqURL, candidate, frontendScheme, tokenParam, reqBodyBytes)
+		// 配置了备用目标时 5xx 也视为失败
+		failed := err != nil || (len(cfg.Targets) > 0 && resp.StatusCode >= http.StatusInternalServerError)
+		markTarget(cfg, candidate, !failed)
+		if !failed || i == len(candidates)-1 {
+			releaseConn = release
+			originalReqURL = reqURL
+			break
 		}
-		targetReq.Header.Set("Host", targetHost)
-
-		resp, err = dm.doWithRedirect(client, targetReq, 10, frontendScheme, r.Host, tokenParam)
-		if err != nil {
-			http.Error(w, "无法连接目标服务器: "+err.Error(), http.StatusBadGateway)
-			return
+		if resp != nil {
+			resp.Body.Close()
 		}
+		release()
+		logger.LogPrintf("⚠️ 域名映射 %s 目标 %s 请求失败，切换到 %s", cfg.Name, candidate, candidates[i+1])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
+	// WebSocket 等协议升级：双向转发
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		serveUpgrade(w, r, resp, updateActive)
+		logger.LogRequestAndResponse(r, originalReqURL.String(), resp)
+		return
+	}
+
 	// ---------- 返回响应 ----------
 	for k, vv := range resp.Header {
 		for _, v := range vv {
@@ -986,6 +1003,10 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	contentType := resp.Header.Get("Content-Type")
 	bufSize := buffer.GetOptimalBufferSize(contentType, originalReqURL.Path)
 	isM3U8 := strings.Contains(contentType, "mpegurl")
+	// 上游仍返回压缩内容时无法改写
+	if resp.Header.Get("Content-Encoding") != "" || !rewriter.Applies(contentType) {
+		rewriter = nil
+	}
 
 	if isM3U8 {
 		// logger.LogPrintf("DEBUG: 检测到M3U8内容，开始处理...")
@@ -1017,6 +1038,9 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 
+				if rewriter != nil {
+					line = rewriter.Rewrite(line, contentType, frontendScheme, r.Host)
+				}
 				newLine := dm.replaceSpecialNestedURLClean(string(line), frontendScheme, r.Host, sourceHost, tm, tokenParam, seen)
 				if newLine != nil {
 					w.Write(newLine)
@@ -1034,6 +1058,17 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+	} else if rewriter != nil {
+		// 文本/JSON 响应整体读取后按规则改写，过大时不改写直接透传
+		data, rest, ok := readRewriteBody(resp.Body)
+		if ok {
+			w.Write(rewriter.Rewrite(data, contentType, frontendScheme, r.Host))
+			updateActive()
+		} else {
+			buf := buffer.GetBuffer(bufSize)
+			defer buffer.PutBuffer(bufSize, buf)
+			stream.CopyWithContext(r.Context(), w, rest, buf, updateActive)
+		}
 	} else {
 		buf := buffer.GetBuffer(bufSize)
 		defer buffer.PutBuffer(bufSize, buf)
@@ -1043,6 +1078,92 @@ func (dm *DomainMapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger.LogRequestAndResponse(r, originalReqURL.String(), resp)
 }
 
+// fetchUpstream 向一个目标发起请求：命中代理组时按组配置选择代理并重试，否则直连并按映射的 retries 重试。
+// 返回的 release 用于释放代理连接计数，调用方在响应处理完后调用
+func (dm *DomainMapper) fetchUpstream(r *http.Request, cfg *auth.DomainMapConfig, client *http.Client, baseTransport *http.Transport,
+	reqURL *url.URL, targetHost, frontendScheme, tokenParam string, reqBodyBytes []byte) (*http.Response, func(), error) {
+
+	newTargetReq := func() *http.Request {
+		targetReq, _ := http.NewRequest(r.Method, reqURL.String(), bytes.NewReader(reqBodyBytes))
+		for name, values := range r.Header {
+			if strings.ToLower(name) == "host" {
+				continue
+			}
+			for _, v := range values {
+				targetReq.Header.Add(name, v)
+			}
+		}
+		for k, v := range cfg.ServerHeaders {
+			if v != "" {
+				targetReq.Header.Set(k, v)
+			}
+		}
+		targetReq.Header.Set("Host", targetHost)
+		return targetReq
+	}
+
+	pg := rules.ChooseProxyGroup(reqURL.Hostname(), targetHost)
+	if pg == nil {
+		// 直连按映射配置的 retries 重试
+		for attempt := 0; ; attempt++ {
+			resp, err := dm.doWithRedirect(client, newTargetReq(), 10, frontendScheme, r.Host, tokenParam)
+			if err == nil {
+				return resp, func() {}, nil
+			}
+			if attempt >= cfg.Retries {
+				return nil, func() {}, fmt.Errorf("无法连接目标服务器: %v", err)
+			}
+			logger.LogPrintf("⚠️ 域名映射 %s 请求失败（第 %d 次），重试: %v", cfg.Name, attempt+1, err)
+			time.Sleep(cfg.RetryDelay)
+		}
+	}
+
+	maxRetries := pg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
+	retryDelay := pg.RetryDelay
+	if cfg.Retries > 0 {
+		maxRetries = cfg.Retries
+		retryDelay = cfg.RetryDelay
+	}
+
+	// 仅保留当前尝试所用代理的连接计数
+	releaseConn := func() {}
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		forceTest := attempt > 0
+		selectedProxy := lb.SelectProxy(pg, reqURL.String(), lb.ClientKey(pg, r), forceTest)
+
+		clientToUse := client
+		if selectedProxy != nil {
+			releaseConn()
+			releaseConn = groupstats.AcquireConn(pg, selectedProxy.Name)
+			if proxyDialer, dErr := proxy.CreateProxyDialer(*selectedProxy); dErr == nil {
+				proxyTransport := baseTransport.Clone()
+				proxyTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+					conn, err := proxyDialer.DialContext(ctx, network, addr)
+					return groupstats.CountConn(pg, conn), err
+				}
+				clientToUse = &http.Client{
+					Transport: proxyTransport,
+					Timeout:   client.Timeout,
+				}
+			}
+		}
+
+		resp, err := dm.doWithRedirect(clientToUse, newTargetReq(), 10, frontendScheme, r.Host, tokenParam)
+		if err == nil {
+			return resp, releaseConn, nil
+		}
+		if attempt == maxRetries {
+			releaseConn()
+			return nil, func() {}, fmt.Errorf("代理请求失败: %v", err)
+		}
+		time.Sleep(retryDelay)
+	}
+	return nil, releaseConn, fmt.Errorf("代理请求失败")
+}
+
 // ---------------------------
 // 处理 301/302 重定向
 // ---------------------------
@@ -0,0 +1,122 @@
+package domainmap
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/logger"
+)
+
+// sourcePattern 通配符或正则形式的 source
+//   - *.cdn.example.com：* 匹配任意子域名，可在 target 中用 $1 引用
+//   - ~^(\w+)\.cdn\.example\.com$：~ 开头为正则，捕获组可在 target 中用 $1、${name} 引用
+type sourcePattern struct {
+	mapping *auth.DomainMapConfig
+	re      *regexp.Regexp
+}
+
+// isSourcePattern 判断 source 是否为通配符或正则
+func isSourcePattern(source string) bool {
+	return strings.HasPrefix(source, "~") || strings.Contains(source, "*")
+}
+
+// compileSource 将通配符或正则 source 编译为不区分大小写的正则
+func compileSource(source string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(source, "~") {
+		return regexp.Compile("(?i)" + strings.TrimPrefix(source, "~"))
+	}
+	parts := strings.Split(source, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.Compile("(?i)^" + strings.Join(parts, "(.+)") + "$")
+}
+
+// compileSourcePatterns 编译所有通配符/正则映射，无效的规则记录日志后跳过
+func compileSourcePatterns(mappings auth.DomainMapList) []sourcePattern {
+	var patterns []sourcePattern
+	for _, mapping := range mappings {
+		if mapping == nil || !isSourcePattern(mapping.Source) {
+			continue
+		}
+		re, err := compileSource(mapping.Source)
+		if err != nil {
+			logger.LogPrintf("❌ 域名映射 %s 的 source 无效: %v", mapping.Name, err)
+			continue
+		}
+		patterns = append(patterns, sourcePattern{mapping: mapping, re: re})
+	}
+	return patterns
+}
+
+// matchMapping 按 host（可带端口）查找映射，不考虑路径前缀，用于重定向、URL 替换等只有域名的场景
+func (dm *DomainMapper) matchMapping(host string) (*auth.DomainMapConfig, string, bool) {
+	return dm.matchRoute(host, "", true)
+}
+
+// matchRoute 按 host 与请求路径查找映射：精确 host 优先，其次按配置顺序匹配通配符/正则；
+// 同类中 path_prefix 最长者优先。anyPath 为 true 时忽略路径前缀。返回的 target 已展开捕获组引用
+func (dm *DomainMapper) matchRoute(host, reqPath string, anyPath bool) (*auth.DomainMapConfig, string, bool) {
+	hostWithoutPort := host
+	if idx := strings.Index(host, ":"); idx != -1 {
+		hostWithoutPort = host[:idx]
+	}
+
+	var best *auth.DomainMapConfig
+	for _, mapping := range dm.mappings {
+		if mapping.Source != hostWithoutPort || !(anyPath || pathPrefixMatch(mapping.PathPrefix, reqPath)) {
+			continue
+		}
+		if best == nil || len(mapping.PathPrefix) > len(best.PathPrefix) {
+			best = mapping
+		}
+	}
+	if best != nil {
+		return best, best.Target, true
+	}
+
+	var bestTarget string
+	for _, p := range dm.patterns {
+		if !(anyPath || pathPrefixMatch(p.mapping.PathPrefix, reqPath)) {
+			continue
+		}
+		if best != nil && len(p.mapping.PathPrefix) <= len(best.PathPrefix) {
+			continue
+		}
+		m := p.re.FindStringSubmatchIndex(hostWithoutPort)
+		if m == nil {
+			continue
+		}
+		best = p.mapping
+		bestTarget = string(p.re.ExpandString(nil, p.mapping.Target, hostWithoutPort, m))
+	}
+	if best != nil {
+		return best, bestTarget, true
+	}
+	return nil, "", false
+}
+
+// pathPrefixMatch 按路径段匹配前缀，/api 匹配 /api 与 /api/x，不匹配 /apix；空前缀匹配全部
+func pathPrefixMatch(prefix, reqPath string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")
+}
+
+// rewriteTargetPath 生成后端请求路径：strip_prefix 时去掉 path_prefix，再拼接 target_path 前缀
+func rewriteTargetPath(cfg *auth.DomainMapConfig, reqPath string) string {
+	p := reqPath
+	if cfg.StripPrefix && cfg.PathPrefix != "" {
+		p = strings.TrimPrefix(p, strings.TrimSuffix(cfg.PathPrefix, "/"))
+	}
+	if cfg.TargetPath != "" {
+		p = "/" + strings.Trim(cfg.TargetPath, "/") + p
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
@@ -0,0 +1,115 @@
+package domainmap
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// 默认参与改写的内容类型
+var defaultRewriteContentTypes = []string{"text/", "json", "javascript", "xml", "mpegurl"}
+
+// 非 m3u8 响应改写时最多读取的字节数，超出部分不改写直接透传
+const maxRewriteBodySize = 8 << 20
+
+// bodyRewriter 编译后的响应体改写规则
+type bodyRewriter struct {
+	rules []compiledRewrite
+}
+
+type compiledRewrite struct {
+	rule config.BodyRewriteRule
+	re   *regexp.Regexp
+}
+
+// bodyRewriterFor 返回映射的改写规则（首次使用时编译），无规则时返回 nil
+func (dm *DomainMapper) bodyRewriterFor(cfg *auth.DomainMapConfig) *bodyRewriter {
+	if len(cfg.BodyRewrite) == 0 {
+		return nil
+	}
+	dm.rewritersMu.Lock()
+	defer dm.rewritersMu.Unlock()
+	if rw, ok := dm.rewriters[cfg]; ok {
+		return rw
+	}
+	rw := &bodyRewriter{}
+	for _, rule := range cfg.BodyRewrite {
+		if rule.Match == "" {
+			continue
+		}
+		c := compiledRewrite{rule: rule}
+		if rule.Regex {
+			re, err := regexp.Compile(rule.Match)
+			if err != nil {
+				logger.LogPrintf("❌ 域名映射 %s 响应改写规则无效 %q: %v", cfg.Name, rule.Match, err)
+				continue
+			}
+			c.re = re
+		}
+		rw.rules = append(rw.rules, c)
+	}
+	if dm.rewriters == nil {
+		dm.rewriters = make(map[*auth.DomainMapConfig]*bodyRewriter)
+	}
+	dm.rewriters[cfg] = rw
+	return rw
+}
+
+// applies 判断规则是否作用于该内容类型
+func (c compiledRewrite) applies(contentType string) bool {
+	types := c.rule.ContentTypes
+	if len(types) == 0 {
+		types = defaultRewriteContentTypes
+	}
+	contentType = strings.ToLower(contentType)
+	for _, t := range types {
+		if strings.Contains(contentType, strings.ToLower(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies 是否有规则作用于该内容类型
+func (rw *bodyRewriter) Applies(contentType string) bool {
+	if rw == nil {
+		return false
+	}
+	for _, c := range rw.rules {
+		if c.applies(contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewrite 依次执行规则，替换内容中的 ${scheme} ${host} 展开为客户端访问 tvgate 使用的协议和域名
+func (rw *bodyRewriter) Rewrite(body []byte, contentType, scheme, host string) []byte {
+	vars := strings.NewReplacer("${scheme}", scheme, "${host}", host)
+	for _, c := range rw.rules {
+		if !c.applies(contentType) {
+			continue
+		}
+		replace := vars.Replace(c.rule.Replace)
+		if c.re != nil {
+			body = c.re.ReplaceAll(body, []byte(replace))
+		} else {
+			body = []byte(strings.ReplaceAll(string(body), c.rule.Match, replace))
+		}
+	}
+	return body
+}
+
+// readRewriteBody 读取待改写的响应体；超过上限时返回 false，并返回已读部分与剩余部分拼接的 Reader
+func readRewriteBody(r io.Reader) ([]byte, io.Reader, bool) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRewriteBodySize+1))
+	if err != nil || len(data) > maxRewriteBodySize {
+		return nil, io.MultiReader(bytes.NewReader(data), r), false
+	}
+	return data, nil, true
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/bluenviron/gortsplib/v5/pkg/format"
 	// "github.com/pion/rtp"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/lb"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
@@ -25,12 +27,12 @@ import (
 	// "github.com/qist/tvgate/utils/worker"
 )
 
-func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request, connID string) {
+func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request, connID, token string) {
 	// 全局token验证
 	clientIP := monitor.GetClientIP(r)
 	// connID := clientIP + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
 	// tokenParamName := "my_token" // 默认参数名
-	logger.LogPrintf(connID)
+	logger.LogPrintf("%s", connID)
 	path := strings.TrimPrefix(r.URL.Path, "/rtsp/")
 	if path == "" {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
@@ -57,16 +59,32 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request, connID string) {
 		http.Error(w, "URL parse error: "+err.Error(), 500)
 		return
 	}
+	// 并发连接数限制，已满时已返回 503
+	release := connlimit.Acquire(w, r)
+	if release == nil {
+		return
+	}
+	defer release()
+
 	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
 		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
 		URL:            rtspURL,
 		UserAgent:      r.UserAgent(),
 		ConnectionType: "RTSP",
 		ConnectedAt:    time.Now(),
 		LastActive:     time.Now(),
+		Token:          token,
 	})
 	defer monitor.ActiveClients.Unregister(connID, "RTSP")
 
+	// 会话被踢出时结束推流
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+	monitor.ActiveClients.SetCancel(connID, cancel)
+	w = monitor.ActiveClients.CountBytes(connID, w)
+
 	client := &gortsplib.Client{
 		Scheme: parsedURL.Scheme,
 		Host:   parsedURL.Host,
@@ -87,16 +105,18 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request, connID string) {
 	if pg != nil {
 		selectedProxyChan := make(chan *config.ProxyConfig, 1)
 		go func() {
-			selectedProxyChan <- lb.SelectProxy(pg, rtspURL, false)
+			selectedProxyChan <- lb.SelectProxy(pg, rtspURL, lb.ClientKey(pg, r), false)
 		}()
 
 		select {
 		case selectedProxy := <-selectedProxyChan:
 			if selectedProxy != nil {
+				defer groupstats.AcquireConn(pg, selectedProxy.Name)()
 				proxyDialer, err := proxy.CreateProxyDialer(*selectedProxy)
 				if err == nil {
 					client.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-						return proxyDialer.DialContext(ctx, network, addr)
+						conn, err := proxyDialer.DialContext(ctx, network, addr)
+						return groupstats.CountConn(pg, conn), err
 					}
 					logger.LogPrintf("RTSP 通过代理 %s://%s:%d", selectedProxy.Type, selectedProxy.Server, selectedProxy.Port)
 				}
@@ -207,7 +227,6 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request, connID string) {
 		hub.SetRtspClient(client)
 	}
 
-	ctx := r.Context()
 	updateActive := func() {
 		monitor.ActiveClients.UpdateLastActive(connID, time.Now())
 	}
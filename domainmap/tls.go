@@ -0,0 +1,52 @@
+package domainmap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/qist/tvgate/auth"
+)
+
+// mappingTLSConfig 返回映射的后端 TLS 配置，证书文件只在首次使用时加载
+func (dm *DomainMapper) mappingTLSConfig(cfg *auth.DomainMapConfig) (*tls.Config, error) {
+	if cfg.TLS == nil {
+		return &tls.Config{InsecureSkipVerify: false}, nil
+	}
+
+	dm.tlsConfigsMu.Lock()
+	defer dm.tlsConfigsMu.Unlock()
+	if tlsConf, ok := dm.tlsConfigs[cfg]; ok {
+		return tlsConf.Clone(), nil
+	}
+
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		ServerName:         cfg.TLS.ServerName,
+	}
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书 %s 中没有有效证书", cfg.TLS.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	if dm.tlsConfigs == nil {
+		dm.tlsConfigs = make(map[*auth.DomainMapConfig]*tls.Config)
+	}
+	dm.tlsConfigs[cfg] = tlsConf
+	return tlsConf.Clone(), nil
+}
@@ -0,0 +1,92 @@
+package domainmap
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+)
+
+// isUpgradeRequest 判断是否为 WebSocket 等协议升级请求
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade 处理上游返回的 101 响应：接管客户端连接，把响应头转发给客户端后双向转发数据
+func serveUpgrade(w http.ResponseWriter, r *http.Request, resp *http.Response, updateActive func()) {
+	reqUpType := r.Header.Get("Upgrade")
+	respUpType := resp.Header.Get("Upgrade")
+	if !strings.EqualFold(reqUpType, respUpType) {
+		http.Error(w, "上游协议升级类型不匹配: "+respUpType, http.StatusBadGateway)
+		return
+	}
+
+	backConn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		http.Error(w, "上游连接不支持协议升级", http.StatusBadGateway)
+		return
+	}
+	defer backConn.Close()
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "客户端连接不支持协议升级: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	// 升级后连接不受 server 读写超时限制
+	_ = conn.SetDeadline(time.Time{})
+
+	for k, vv := range resp.Header {
+		w.Header()[k] = vv
+	}
+	upgradeResp := &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.Header(),
+	}
+	if err := upgradeResp.Write(brw); err != nil {
+		logger.LogPrintf("❌ 写入协议升级响应失败: %v", err)
+		return
+	}
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// 客户端可能已随请求发送了数据，需从缓冲读取
+		io.Copy(backConn, brw)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, backConn)
+		done <- struct{}{}
+	}()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			// 任一方向结束即关闭两端（defer），另一方向随之退出
+			updateActive()
+			return
+		case <-ticker.C:
+			updateActive()
+		}
+	}
+}
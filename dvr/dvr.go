@@ -0,0 +1,443 @@
+// Package dvr 定时录制：按 cron 表达式、指定时间或节目单中的节目，经 TVGate 自身拉取频道并保存为 TS 文件。
+// 时间重叠超出同时录制上限时按优先级取舍，磁盘剩余空间不足时不开始新录制并停止进行中的录制
+package dvr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+var (
+	// ErrDisabled 未启用定时录制
+	ErrDisabled = errors.New("未启用定时录制")
+	// ErrNoTimer 定时任务不存在
+	ErrNoTimer = errors.New("定时任务不存在")
+	// ErrNoRecording 录制记录不存在
+	ErrNoRecording = errors.New("录制记录不存在")
+)
+
+// 录制状态
+const (
+	StateRecording = "recording" // 录制中
+	StateCompleted = "completed" // 已完成
+	StateFailed    = "failed"    // 没有录到数据
+	StateStopped   = "stopped"   // 被手动停止、抢占或因磁盘空间不足停止，已录制的部分保留
+)
+
+// Timer 定时任务，cron、start 与 programme 三选一
+type Timer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`                // 录制名称，用作文件名前缀，默认为节目名或频道名
+	Channel   string    `json:"channel"`             // channels.list 中的频道名、上游地址、组播地址或 TVGate 路径；按节目录制时可省略，按节目单频道 id 查找
+	Cron      string    `json:"cron,omitempty"`      // 周期录制的 cron 表达式（分 时 日 月 周），与 duration 一起使用
+	Start     time.Time `json:"start"`               // 单次录制的开始时间，与 duration 一起使用
+	Duration  string    `json:"duration,omitempty"`  // 录制时长，如 30m、1h30m
+	Programme string    `json:"programme,omitempty"` // 节目单中的节目 ID（频道 id@开始时间），按节目时间前后加 pre_padding、post_padding 录制
+	Priority  int       `json:"priority"`            // 时间重叠超出 max_concurrent 时优先录制数值大的
+	Disabled  bool      `json:"disabled"`
+	Created   time.Time `json:"created"`
+}
+
+// Recording 一次录制
+type Recording struct {
+	ID       string    `json:"id"`
+	TimerID  string    `json:"timer_id"`
+	Name     string    `json:"name"`
+	Channel  string    `json:"channel"`
+	Title    string    `json:"title,omitempty"` // 按节目录制时的节目名
+	File     string    `json:"file"`
+	Start    time.Time `json:"start"` // 计划的开始时间
+	End      time.Time `json:"end"`   // 计划的结束时间
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"` // 零值表示仍在录制
+	Size     int64     `json:"size"`
+	State    string    `json:"state"`
+	Error    string    `json:"error,omitempty"`
+
+	Thumbnail string `json:"thumbnail,omitempty"`  // 缩略图文件
+	Post      string `json:"post,omitempty"`       // 录制完成后的处理状态，未配置处理时为空
+	PostError string `json:"post_error,omitempty"` // 处理失败的步骤与原因
+}
+
+// 录制完成后的处理状态
+const (
+	PostProcessing = "processing" // 处理中
+	PostDone       = "done"       // 全部步骤成功
+	PostFailed     = "failed"     // 有步骤失败，见 post_error
+)
+
+// stored 保存文件的内容
+type stored struct {
+	Timers     []*Timer     `json:"timers"`
+	Recordings []*Recording `json:"recordings"`
+}
+
+// settings 生效的配置，拉取频道需要代理端口与 token 参数名
+type settings struct {
+	config.DVRConfig
+	port       int
+	tokenParam string
+}
+
+// Manager 定时任务、调度与进行中的录制
+type Manager struct {
+	mu         sync.Mutex
+	s          settings
+	timers     map[string]*Timer
+	recordings []*Recording
+	active     map[string]*session  // 窗口 key -> 进行中的录制
+	done       map[string]time.Time // 已录完或被手动停止的窗口 key -> 窗口结束时间，不再重新开始
+	notes      map[string]string    // 定时任务 ID -> 最近一次未能录制的原因
+	stop       chan struct{}
+	loadedFrom string
+
+	diskChecked time.Time
+	diskErr     error
+
+	indexMu sync.Mutex
+	indexes map[string]*tsIndex // 录制文件 -> 点播 HLS 分片索引
+}
+
+// New 创建未启用的录制管理器
+func New() *Manager {
+	return &Manager{
+		timers: make(map[string]*Timer),
+		active: make(map[string]*session),
+		done:   make(map[string]time.Time),
+		notes:  make(map[string]string),
+
+		indexes: make(map[string]*tsIndex),
+	}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg *config.Config) { Default.Configure(cfg) }
+
+// Close 停止全局实例进行中的录制，程序退出时调用
+func Close() { Default.Close() }
+
+// defaultPath 默认文件与目录，与配置文件放在同一目录
+func defaultPath(name string) string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, name)
+}
+
+// Configure 应用配置：启用时加载定时任务并开始调度，禁用时停止调度与进行中的录制
+func (m *Manager) Configure(cfg *config.Config) {
+	s := settings{DVRConfig: cfg.DVR, port: cfg.Server.Port, tokenParam: cfg.GlobalAuth.TokenParamName}
+	if cfg.Server.HTTPPort > 0 {
+		s.port = cfg.Server.HTTPPort
+	}
+	if s.tokenParam == "" {
+		s.tokenParam = "my_token"
+	}
+	if s.Dir == "" {
+		s.Dir = defaultPath("recordings")
+	}
+	if s.File == "" {
+		s.File = defaultPath("dvr.json")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.s = s
+	if !s.Enabled {
+		if m.stop != nil {
+			close(m.stop)
+			m.stop = nil
+		}
+		m.stopAllLocked("已停用定时录制")
+		return
+	}
+	if m.loadedFrom != s.File {
+		m.stopAllLocked("录制记录文件已变更")
+		m.loadLocked(s.File)
+	}
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+		go m.run(m.stop)
+	}
+}
+
+// Close 停止调度与进行中的录制
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.stopAllLocked("程序退出")
+}
+
+// stopAllLocked 停止全部录制，录制协程结束时更新记录
+func (m *Manager) stopAllLocked(reason string) {
+	for _, sess := range m.active {
+		sess.cancel(reason)
+	}
+}
+
+// loadLocked 加载保存的定时任务与录制记录，上次退出时未结束的录制标记为已停止
+func (m *Manager) loadLocked(file string) {
+	m.loadedFrom = file
+	m.timers = make(map[string]*Timer)
+	m.recordings = nil
+	m.done = make(map[string]time.Time)
+	m.notes = make(map[string]string)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取定时录制文件 %s 失败: %v", file, err)
+		}
+		return
+	}
+	var st stored
+	if err := json.Unmarshal(data, &st); err != nil {
+		logger.LogPrintf("❌ 解析定时录制文件 %s 失败: %v", file, err)
+		return
+	}
+	for _, t := range st.Timers {
+		if t != nil && t.ID != "" {
+			m.timers[t.ID] = t
+		}
+	}
+	for _, r := range st.Recordings {
+		if r == nil {
+			continue
+		}
+		if r.State == StateRecording {
+			r.State, r.Error = StateStopped, "程序退出时未完成"
+			if info, err := os.Stat(r.File); err == nil {
+				r.Size = info.Size()
+				r.Finished = info.ModTime()
+			}
+		}
+		if r.Post == PostProcessing {
+			r.Post, r.PostError = PostFailed, "程序退出时未完成"
+		}
+		m.recordings = append(m.recordings, r)
+	}
+	logger.LogPrintf("✅ 已加载定时录制：%d 个定时任务，%d 条录制记录", len(m.timers), len(m.recordings))
+}
+
+// saveLocked 写入定时任务与录制记录
+func (m *Manager) saveLocked() {
+	if m.loadedFrom == "" {
+		return
+	}
+	st := stored{Timers: make([]*Timer, 0, len(m.timers)), Recordings: m.recordings}
+	for _, t := range m.timers {
+		st.Timers = append(st.Timers, t)
+	}
+	sort.Slice(st.Timers, func(i, j int) bool { return st.Timers[i].Created.Before(st.Timers[j].Created) })
+	if st.Recordings == nil {
+		st.Recordings = []*Recording{}
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		logger.LogPrintf("❌ 序列化定时录制失败: %v", err)
+		return
+	}
+	if dir := filepath.Dir(m.loadedFrom); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	tmp := m.loadedFrom + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.LogPrintf("❌ 写入定时录制文件失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, m.loadedFrom); err != nil {
+		os.Remove(tmp)
+		logger.LogPrintf("❌ 写入定时录制文件失败: %v", err)
+	}
+}
+
+// enabledLocked 未启用时返回 ErrDisabled，调用方需持有 m.mu
+func (m *Manager) enabledLocked() error {
+	if !m.s.Enabled {
+		return ErrDisabled
+	}
+	return nil
+}
+
+// AddTimer 添加定时任务，返回任务状态与时间重叠的提示
+func (m *Manager) AddTimer(t Timer) (TimerStatus, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return TimerStatus{}, nil, err
+	}
+	t.ID = newID()
+	t.Created = time.Now()
+	if err := validate(&t); err != nil {
+		return TimerStatus{}, nil, err
+	}
+	m.timers[t.ID] = &t
+	m.saveLocked()
+	logger.LogPrintf("📼 添加定时录制 %s: %s", t.ID, t.describe())
+	now := time.Now()
+	return m.statusLocked(&t, now), m.conflictsLocked(&t, now), nil
+}
+
+// UpdateTimer 修改定时任务，进行中的录制在下次调度时按新设置继续或停止
+func (m *Manager) UpdateTimer(id string, t Timer) (TimerStatus, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return TimerStatus{}, nil, err
+	}
+	old, ok := m.timers[id]
+	if !ok {
+		return TimerStatus{}, nil, ErrNoTimer
+	}
+	t.ID, t.Created = id, old.Created
+	if err := validate(&t); err != nil {
+		return TimerStatus{}, nil, err
+	}
+	m.timers[id] = &t
+	delete(m.notes, id)
+	m.saveLocked()
+	logger.LogPrintf("📼 修改定时录制 %s: %s", id, t.describe())
+	now := time.Now()
+	return m.statusLocked(&t, now), m.conflictsLocked(&t, now), nil
+}
+
+// DeleteTimer 删除定时任务并停止其进行中的录制，已录制的文件保留
+func (m *Manager) DeleteTimer(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return err
+	}
+	if _, ok := m.timers[id]; !ok {
+		return ErrNoTimer
+	}
+	delete(m.timers, id)
+	delete(m.notes, id)
+	for _, sess := range m.active {
+		if sess.rec.TimerID == id {
+			sess.cancel("定时任务已删除")
+		}
+	}
+	m.saveLocked()
+	logger.LogPrintf("📼 删除定时录制 %s", id)
+	return nil
+}
+
+// Timers 列出定时任务及下一次录制时间，按下一次录制时间排序
+func (m *Manager) Timers() ([]TimerStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := make([]TimerStatus, 0, len(m.timers))
+	for _, t := range m.timers {
+		list = append(list, m.statusLocked(t, now))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].Next, list[j].Next
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Recordings 列出录制记录，最新的在前
+func (m *Manager) Recordings() ([]Recording, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return nil, err
+	}
+	list := make([]Recording, 0, len(m.recordings))
+	for i := len(m.recordings) - 1; i >= 0; i-- {
+		r := *m.recordings[i]
+		for _, sess := range m.active {
+			if sess.rec == m.recordings[i] {
+				r.Size = sess.size()
+			}
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// StopRecording 停止进行中的录制，该次录制不再自动重新开始
+func (m *Manager) StopRecording(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return err
+	}
+	for key, sess := range m.active {
+		if sess.rec.ID == id {
+			m.done[key] = sess.rec.End
+			sess.cancel("已手动停止")
+			return nil
+		}
+	}
+	return ErrNoRecording
+}
+
+// DeleteRecording 删除已结束的录制记录及其文件与缩略图
+func (m *Manager) DeleteRecording(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.enabledLocked(); err != nil {
+		return err
+	}
+	for i, r := range m.recordings {
+		if r.ID != id {
+			continue
+		}
+		if r.State == StateRecording {
+			return errors.New("录制进行中，请先停止")
+		}
+		if r.Post == PostProcessing {
+			return errors.New("录制正在处理中，请稍后再删除")
+		}
+		if err := os.Remove(r.File); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if r.Thumbnail != "" {
+			os.Remove(r.Thumbnail)
+		}
+		m.indexMu.Lock()
+		delete(m.indexes, r.File)
+		m.indexMu.Unlock()
+		m.recordings = append(m.recordings[:i], m.recordings[i+1:]...)
+		m.saveLocked()
+		logger.LogPrintf("🗑️ 已删除录制 %s: %s", r.ID, r.File)
+		return nil
+	}
+	return ErrNoRecording
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,246 @@
+package dvr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// postEnabled 是否配置了录制完成后的处理
+func postEnabled(p config.DVRPostConfig) bool {
+	return p.Remux || p.Thumbnail || p.MoveTo != "" || len(p.Command) > 0 || p.Webhook != ""
+}
+
+// postProcess 录制完成后依次转封装、生成缩略图、移动文件、执行命令与调用 webhook，
+// 每一步完成后更新录制记录中的文件路径
+func (m *Manager) postProcess(rec *Recording, p config.DVRPostConfig) {
+	m.mu.Lock()
+	r := *rec
+	m.mu.Unlock()
+
+	var errs []string
+	step := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+		defer cancel()
+		err := fn(ctx)
+		if err != nil {
+			errs = append(errs, name+": "+err.Error())
+			logger.LogPrintf("❌ 录制 %s %s失败: %v", r.Name, name, err)
+		}
+		if info, err := os.Stat(r.File); err == nil {
+			r.Size = info.Size()
+		}
+		m.mu.Lock()
+		rec.File, rec.Thumbnail, rec.Size = r.File, r.Thumbnail, r.Size
+		m.saveLocked()
+		m.mu.Unlock()
+	}
+	if p.Remux {
+		step("转封装", func(ctx context.Context) error { return remux(ctx, p, &r) })
+	}
+	if p.Thumbnail {
+		step("生成缩略图", func(ctx context.Context) error { return thumbnail(ctx, p, &r) })
+	}
+	if p.MoveTo != "" {
+		step("移动文件", func(ctx context.Context) error { return move(p, &r) })
+	}
+	if len(p.Command) > 0 || p.Webhook != "" {
+		// 命令与 webhook 收到的录制信息包含此前步骤的错误
+		meta := r
+		meta.Post, meta.PostError = "", strings.Join(errs, "; ")
+		data, _ := json.Marshal(meta)
+		if len(p.Command) > 0 {
+			step("执行命令", func(ctx context.Context) error { return runCommand(ctx, p.Command, &r, data) })
+		}
+		if p.Webhook != "" {
+			step("调用 webhook", func(ctx context.Context) error { return callWebhook(ctx, p, data) })
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.Post, rec.PostError = PostDone, ""
+	if len(errs) > 0 {
+		rec.Post, rec.PostError = PostFailed, strings.Join(errs, "; ")
+	}
+	m.saveLocked()
+	logger.LogPrintf("📼 录制 %s 处理结束: %s，%s", rec.Name, rec.Post, rec.File)
+}
+
+// runFFmpeg 执行 ffmpeg，失败时返回标准错误输出的末尾
+func runFFmpeg(ctx context.Context, p config.DVRPostConfig, args ...string) error {
+	bin := p.FFmpeg
+	if bin == "" {
+		bin = "ffmpeg"
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, append([]string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y"}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return withOutput(err, stderr.Bytes())
+	}
+	return nil
+}
+
+// withOutput 在错误后附加命令输出的最后 512 字节
+func withOutput(err error, out []byte) error {
+	out = bytes.TrimSpace(out)
+	if len(out) > 512 {
+		out = out[len(out)-512:]
+	}
+	if len(out) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, out)
+}
+
+// remux 无损转封装为同名 MP4，先写入 .part 文件，成功后替换
+func remux(ctx context.Context, p config.DVRPostConfig, r *Recording) error {
+	out := uniquePath(strings.TrimSuffix(r.File, filepath.Ext(r.File)), ".mp4")
+	tmp := out + ".part"
+	err := runFFmpeg(ctx, p, "-i", r.File, "-map", "0:v?", "-map", "0:a?", "-c", "copy",
+		"-movflags", "+faststart", "-f", "mp4", tmp)
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if !p.KeepTS {
+		os.Remove(r.File)
+	}
+	r.File = out
+	return nil
+}
+
+// thumbnail 在 thumbnail_at 处截取一帧保存为与录制文件同名的 .jpg，录制短于该时长时取中间
+func thumbnail(ctx context.Context, p config.DVRPostConfig, r *Recording) error {
+	at := p.ThumbnailAt
+	if d := r.Finished.Sub(r.Started); d < at {
+		at = d / 2
+	}
+	out := strings.TrimSuffix(r.File, filepath.Ext(r.File)) + ".jpg"
+	err := runFFmpeg(ctx, p, "-ss", fmt.Sprintf("%.3f", at.Seconds()), "-i", r.File,
+		"-frames:v", "1", "-vf", "scale=480:-2", "-q:v", "3", out)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(out); err != nil {
+		return errors.New("没有截取到画面")
+	}
+	r.Thumbnail = out
+	return nil
+}
+
+// move 把录制文件与缩略图移动到 move_to 目录，目录中的 {name} {channel} {title} {date} 按录制信息替换
+func move(p config.DVRPostConfig, r *Recording) error {
+	title := r.Title
+	if title == "" {
+		title = r.Name
+	}
+	dir := strings.NewReplacer(
+		"{name}", safeName(r.Name),
+		"{channel}", safeName(r.Channel),
+		"{title}", safeName(title),
+		"{date}", r.Start.Local().Format("2006-01-02"),
+	).Replace(p.MoveTo)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.File)
+	dst := uniquePath(filepath.Join(dir, strings.TrimSuffix(filepath.Base(r.File), ext)), ext)
+	if err := moveFile(r.File, dst); err != nil {
+		return err
+	}
+	r.File = dst
+	if r.Thumbnail != "" {
+		thumb := strings.TrimSuffix(dst, ext) + ".jpg"
+		if err := moveFile(r.Thumbnail, thumb); err != nil {
+			return fmt.Errorf("移动缩略图失败: %w", err)
+		}
+		r.Thumbnail = thumb
+	}
+	return nil
+}
+
+// moveFile 移动文件，不能直接重命名（如跨分区）时复制后删除原文件
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// runCommand 执行外部命令，参数可用 {file} {thumbnail} {name} {channel} {title} {id}，录制信息 JSON 从标准输入传入
+func runCommand(ctx context.Context, command []string, r *Recording, meta []byte) error {
+	rep := strings.NewReplacer("{file}", r.File, "{thumbnail}", r.Thumbnail, "{name}", r.Name,
+		"{channel}", r.Channel, "{title}", r.Title, "{id}", r.ID)
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		args[i] = rep.Replace(arg)
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Stdin = bytes.NewReader(meta)
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		return withOutput(err, output.Bytes())
+	}
+	return nil
+}
+
+// callWebhook POST 录制信息 JSON
+func callWebhook(ctx context.Context, p config.DVRPostConfig, meta []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Webhook, bytes.NewReader(meta))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TVGate-DVR")
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
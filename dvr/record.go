@@ -0,0 +1,324 @@
+package dvr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/m3u"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// diskCheckInterval 检查磁盘剩余空间的间隔
+const diskCheckInterval = 10 * time.Second
+
+// session 进行中的一次录制
+type session struct {
+	rec     *Recording
+	written int64
+
+	mu     sync.Mutex
+	reason string // 提前结束的原因，为空表示录到了结束时间
+	stop   context.CancelFunc
+}
+
+func (s *session) size() int64 { return atomic.LoadInt64(&s.written) }
+
+// cancel 提前结束录制，只记录第一次的原因
+func (s *session) cancel(reason string) {
+	s.mu.Lock()
+	if s.reason == "" {
+		s.reason = reason
+	}
+	s.mu.Unlock()
+	s.stop()
+}
+
+func (s *session) Write(p []byte) (int, error) {
+	atomic.AddInt64(&s.written, int64(len(p)))
+	return len(p), nil
+}
+
+// channelPath 把频道转换为 TVGate 上的路径：channels.list 中的频道名按其上游地址，
+// 239.0.0.1:2000 与 239.0.0.1:2000@eth0 -> /rtp/239.0.0.1:2000（?iface=eth0），rtp:// 等上游地址按播放列表的规则改写，
+// 以 / 开头的路径保持不变；HLS 频道不支持录制
+func channelPath(channels *config.ChannelsConfig, channel string) (string, error) {
+	src := strings.TrimSpace(channel)
+	if info := channels.Lookup(src); info != nil && info.Addr != "" {
+		src = info.Addr
+	}
+	var p string
+	switch {
+	case src == "":
+		return "", errors.New("缺少频道")
+	case strings.HasPrefix(src, "/"):
+		p = src
+	case strings.Contains(src, "://"):
+		if p = m3u.ProxyPath(src); !strings.HasPrefix(p, "/") {
+			return "", fmt.Errorf("不支持录制该地址: %s", src)
+		}
+	default:
+		addr, iface, _ := strings.Cut(src, "@")
+		addr, _, _ = strings.Cut(addr, ",")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return "", fmt.Errorf("频道不存在或地址无效: %s", src)
+		}
+		p = "/rtp/" + addr
+		if iface != "" {
+			p += "?iface=" + url.QueryEscape(iface)
+		}
+	}
+	if u, err := url.Parse(p); err == nil && strings.EqualFold(path.Ext(u.Path), ".m3u8") {
+		return "", fmt.Errorf("不支持录制 HLS 频道: %s", src)
+	}
+	return p, nil
+}
+
+// resolveChannel 录制的频道：未指定时按节目单频道 id 查找 channels.list 中 epg_id 或频道名相同的频道
+func resolveChannel(w window) (name, p string, err error) {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	channels := &config.Cfg.Channels
+	name = w.timer.Channel
+	if name == "" {
+		for i := range channels.List {
+			if channels.List[i].EPGID == w.channel {
+				name = channels.List[i].Name
+				break
+			}
+		}
+		if name == "" && channels.Lookup(w.channel) != nil {
+			name = w.channel
+		}
+		if name == "" {
+			return "", "", fmt.Errorf("channels.list 中没有节目单频道 %s", w.channel)
+		}
+	}
+	p, err = channelPath(channels, name)
+	return name, p, err
+}
+
+// checkDiskLocked 检查录制目录所在磁盘的剩余空间，每 10 秒检查一次
+func (m *Manager) checkDiskLocked(now time.Time) error {
+	if now.Sub(m.diskChecked) < diskCheckInterval {
+		return m.diskErr
+	}
+	m.diskChecked = now
+	if err := os.MkdirAll(m.s.Dir, 0755); err != nil {
+		m.diskErr = fmt.Errorf("创建录制目录失败: %w", err)
+		return m.diskErr
+	}
+	usage, err := disk.Usage(m.s.Dir)
+	switch {
+	case err != nil:
+		// 无法获取时不阻止录制
+		m.diskErr = nil
+	case int64(usage.Free>>20) < m.s.MinFreeMB:
+		m.diskErr = fmt.Errorf("磁盘剩余空间不足: %d MB，低于 min_free_mb %d MB", usage.Free>>20, m.s.MinFreeMB)
+	default:
+		m.diskErr = nil
+	}
+	return m.diskErr
+}
+
+// safeName 去掉文件名中不允许的字符
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+}
+
+// uniquePath 返回 base+ext，已存在时加序号
+func uniquePath(base, ext string) string {
+	file := base + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return file
+		}
+		file = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// fileName 录制文件名：名称_开始时间.ts，重名时加序号
+func (m *Manager) fileName(name string, start time.Time) string {
+	safe := safeName(name)
+	if safe == "" {
+		safe = "recording"
+	}
+	return uniquePath(filepath.Join(m.s.Dir, safe+"_"+start.Format("20060102-1504")), ".ts")
+}
+
+// startLocked 开始录制一个时间段
+func (m *Manager) startLocked(w window, now time.Time) error {
+	channel, p, err := resolveChannel(w)
+	if err != nil {
+		return err
+	}
+	name := w.timer.Name
+	if name == "" {
+		name = w.title
+	}
+	if name == "" {
+		name = channel
+	}
+	if err := os.MkdirAll(m.s.Dir, 0755); err != nil {
+		return fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	file := m.fileName(name, w.start)
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("创建录制文件失败: %w", err)
+	}
+
+	base := strings.TrimSuffix(m.s.BaseURL, "/")
+	if base == "" {
+		base = "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(m.s.port))
+	}
+	src := base + p
+	var token string
+	if tm := auth.GetGlobalTokenManager(); tm != nil && tm.Enabled {
+		allow := p
+		if u, err := url.Parse(p); err == nil {
+			allow = u.Path
+		}
+		it, err := tm.IssueToken("", "定时录制: "+name, w.end.Sub(now)+time.Minute, auth.TokenACL{Allow: []string{allow}})
+		if err != nil {
+			f.Close()
+			os.Remove(file)
+			return fmt.Errorf("签发录制 token 失败: %w", err)
+		}
+		token = it.Token
+		sep := "?"
+		if strings.Contains(src, "?") {
+			sep = "&"
+		}
+		src += sep + m.s.tokenParam + "=" + url.QueryEscape(token)
+	}
+
+	rec := &Recording{
+		ID:      newID(),
+		TimerID: w.timer.ID,
+		Name:    name,
+		Channel: channel,
+		Title:   w.title,
+		File:    file,
+		Start:   w.start,
+		End:     w.end,
+		Started: now,
+		State:   StateRecording,
+	}
+	ctx, stop := context.WithDeadline(context.Background(), w.end)
+	sess := &session{rec: rec, stop: stop}
+	key := w.key()
+	m.active[key] = sess
+	m.recordings = append(m.recordings, rec)
+	m.saveLocked()
+	logger.LogPrintf("🔴 开始录制 %s: %s -> %s，至 %s", name, base+p, file, w.end.Format("2006-01-02 15:04:05"))
+
+	retry := m.s.Retry
+	go func() {
+		err := m.record(ctx, sess, f, src, retry)
+		f.Close()
+		if token != "" {
+			if tm := auth.GetGlobalTokenManager(); tm != nil {
+				_ = tm.RevokeToken(token)
+			}
+		}
+		m.finish(key, sess, err)
+	}()
+	return nil
+}
+
+// record 拉取频道写入文件直到结束时间或被停止，连接中断时按 retry 间隔重新连接，返回最后一次的错误
+func (m *Manager) record(ctx context.Context, sess *session, f *os.File, src string, retry time.Duration) error {
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", "TVGate-DVR")
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				_, err = io.Copy(io.MultiWriter(f, sess), resp.Body)
+				if err == nil {
+					err = errors.New("频道连接已断开")
+				}
+			} else {
+				err = fmt.Errorf("拉取频道失败: %s", resp.Status)
+			}
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return lastErr
+		}
+		lastErr = err
+		logger.LogPrintf("⚠️ 录制 %s 中断，%s 后重新连接: %v", sess.rec.Name, retry, err)
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(retry):
+		}
+	}
+}
+
+// finish 录制结束后更新记录：录到结束时间的窗口不再重新开始，被抢占或因磁盘空间不足停止的窗口之后可以继续录制
+func (m *Manager) finish(key string, sess *session, err error) {
+	sess.mu.Lock()
+	reason := sess.reason
+	sess.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active[key] == sess {
+		delete(m.active, key)
+	}
+	rec := sess.rec
+	rec.Finished = time.Now()
+	rec.Size = sess.size()
+	switch {
+	case reason != "":
+		rec.State, rec.Error = StateStopped, reason
+	case rec.Size == 0:
+		rec.State = StateFailed
+		rec.Error = "没有收到数据"
+		if err != nil {
+			rec.Error += ": " + err.Error()
+		}
+		m.done[key] = rec.End
+	default:
+		rec.State = StateCompleted
+		if err != nil {
+			rec.Error = "录制期间曾中断: " + err.Error()
+		}
+		m.done[key] = rec.End
+	}
+	if rec.Size == 0 {
+		os.Remove(rec.File)
+	}
+	if rec.State == StateCompleted && postEnabled(m.s.Post) {
+		rec.Post = PostProcessing
+		go m.postProcess(rec, m.s.Post)
+	}
+	m.saveLocked()
+	logger.LogPrintf("⏹️ 录制结束 %s: %s，%d MB，%s", rec.Name, rec.State, rec.Size>>20, rec.File)
+}
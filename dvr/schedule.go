@@ -0,0 +1,331 @@
+package dvr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/epg"
+	"github.com/qist/tvgate/logger"
+)
+
+// 定时任务状态
+const (
+	TimerScheduled  = "scheduled"   // 等待开始
+	TimerRecording  = "recording"   // 录制中
+	TimerMissed     = "missed"      // 应在录制中但未能开始，如与其它录制重叠超出上限或磁盘空间不足，原因见 note
+	TimerWaitingEPG = "waiting_epg" // 节目单中还没有该节目
+	TimerDone       = "done"        // 不会再录制
+	TimerDisabled   = "disabled"    // 已停用
+)
+
+// 计算重叠时检查的时间范围与每个定时任务最多展开的次数
+const (
+	conflictHorizon = 7 * 24 * time.Hour
+	maxOccurrences  = 200
+)
+
+// TimerStatus 定时任务及其下一次录制
+type TimerStatus struct {
+	Timer
+	Next  time.Time `json:"next"` // 下一次或正在进行的录制的开始时间，零值表示不会再录制
+	End   time.Time `json:"end"`
+	Title string    `json:"title,omitempty"` // 按节目录制时的节目名
+	State string    `json:"state"`
+	Note  string    `json:"note,omitempty"` // 最近一次未能录制的原因
+}
+
+// window 定时任务的一次录制时间段
+type window struct {
+	timer      *Timer
+	start, end time.Time
+	title      string
+	channel    string // 节目单频道 id，按节目录制且未指定频道时用于查找频道
+}
+
+// key 窗口标识，同一窗口只录制一次
+func (w window) key() string {
+	return w.timer.ID + "@" + strconv.FormatInt(w.start.Unix(), 10)
+}
+
+// duration 解析录制时长
+func (t *Timer) duration() time.Duration {
+	d, _ := time.ParseDuration(t.Duration)
+	return d
+}
+
+// describe 日志中的任务说明
+func (t *Timer) describe() string {
+	target := t.Channel
+	switch {
+	case t.Programme != "":
+		return fmt.Sprintf("节目 %s %s", t.Programme, target)
+	case t.Cron != "":
+		return fmt.Sprintf("%s 每次 %s，cron %q", target, t.Duration, t.Cron)
+	default:
+		return fmt.Sprintf("%s %s 起 %s", target, t.Start.Format("2006-01-02 15:04"), t.Duration)
+	}
+}
+
+// validate 检查并规范化定时任务
+func validate(t *Timer) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Channel = strings.TrimSpace(t.Channel)
+	t.Cron = strings.TrimSpace(t.Cron)
+	t.Programme = strings.TrimSpace(t.Programme)
+	kinds := 0
+	for _, set := range []bool{t.Cron != "", !t.Start.IsZero(), t.Programme != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("cron、start 与 programme 必须且只能设置一个")
+	}
+	if t.Programme != "" {
+		t.Duration = ""
+	} else {
+		d, err := time.ParseDuration(t.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("duration 无效: %q", t.Duration)
+		}
+		if t.Channel == "" {
+			return errors.New("缺少 channel")
+		}
+	}
+	if t.Cron != "" {
+		if _, err := parseCron(t.Cron); err != nil {
+			return err
+		}
+	}
+	if t.Channel != "" {
+		config.CfgMu.RLock()
+		_, err := channelPath(&config.Cfg.Channels, t.Channel)
+		config.CfgMu.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next 返回结束时间晚于 after 的第一个录制时间段；不会再录制时返回 ok=false，节目单中没有该节目时返回错误
+func (m *Manager) next(t *Timer, after time.Time) (window, bool, error) {
+	w := window{timer: t}
+	switch {
+	case t.Programme != "":
+		p, found := epg.Default.Programme(t.Programme)
+		if !found {
+			return w, false, errors.New("节目单中没有该节目")
+		}
+		w.start, w.end = p.Start.Add(-m.s.PrePadding), p.Stop.Add(m.s.PostPadding)
+		w.title, w.channel = p.Title, p.Channel
+	case t.Cron != "":
+		s, err := parseCron(t.Cron)
+		if err != nil {
+			return w, false, err
+		}
+		d := t.duration()
+		// 第一个 f+d > after 的触发时间，即 f > after-d
+		f := s.next(after.Add(-d))
+		if f.IsZero() {
+			return w, false, nil
+		}
+		w.start, w.end = f, f.Add(d)
+	default:
+		w.start, w.end = t.Start, t.Start.Add(t.duration())
+	}
+	return w, w.end.After(after), nil
+}
+
+// occurrences 展开 [from, to) 内的录制时间段
+func (m *Manager) occurrences(t *Timer, from, to time.Time) []window {
+	var list []window
+	after := from
+	for len(list) < maxOccurrences {
+		w, ok, err := m.next(t, after)
+		if err != nil || !ok || !w.start.Before(to) {
+			break
+		}
+		list = append(list, w)
+		if t.Cron == "" {
+			break
+		}
+		after = w.end
+	}
+	return list
+}
+
+// statusLocked 定时任务的当前状态
+func (m *Manager) statusLocked(t *Timer, now time.Time) TimerStatus {
+	st := TimerStatus{Timer: *t, Note: m.notes[t.ID]}
+	w, ok, err := m.next(t, now)
+	if err == nil && ok && !m.done[w.key()].IsZero() {
+		// 本次已录完或被手动停止，显示下一次
+		w, ok, err = m.next(t, w.end)
+	}
+	switch {
+	case err != nil:
+		st.State, st.Note = TimerWaitingEPG, err.Error()
+		return st
+	case !ok:
+		st.State = TimerDone
+		return st
+	}
+	st.Next, st.End, st.Title = w.start, w.end, w.title
+	switch {
+	case t.Disabled:
+		st.State = TimerDisabled
+	case m.active[w.key()] != nil:
+		st.State = TimerRecording
+	case !w.start.After(now) && st.Note != "":
+		st.State = TimerMissed
+	default:
+		st.State = TimerScheduled
+	}
+	return st
+}
+
+// conflictsLocked 检查 t 在未来 7 天内与优先级不低于它的其它定时任务的重叠，超出同时录制上限时返回提示
+func (m *Manager) conflictsLocked(t *Timer, now time.Time) []string {
+	if t.Disabled {
+		return nil
+	}
+	to := now.Add(conflictHorizon)
+	var others []window
+	for _, o := range m.timers {
+		if o.ID != t.ID && !o.Disabled && o.Priority >= t.Priority {
+			others = append(others, m.occurrences(o, now, to)...)
+		}
+	}
+	var warnings []string
+	for _, w := range m.occurrences(t, now, to) {
+		// 按时间点统计同时进行的录制数
+		points := []time.Time{w.start}
+		for _, o := range others {
+			if o.start.After(w.start) && o.start.Before(w.end) {
+				points = append(points, o.start)
+			}
+		}
+		peak := 0
+		for _, p := range points {
+			n := 0
+			for _, o := range others {
+				if !o.start.After(p) && o.end.After(p) {
+					n++
+				}
+			}
+			peak = max(peak, n)
+		}
+		if peak >= m.s.MaxConcurrent {
+			warnings = append(warnings, fmt.Sprintf("%s 开始的录制与 %d 个录制重叠，超出同时录制上限 %d，可能不会录制",
+				w.start.Format("2006-01-02 15:04"), peak, m.s.MaxConcurrent))
+		}
+	}
+	return warnings
+}
+
+// run 每秒调度一次
+func (m *Manager) run(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			m.tick(now)
+		}
+	}
+}
+
+// tick 找出当前应录制的时间段，按优先级（相同时进行中的优先、开始早的优先）选出不超过上限的录制，
+// 开始新的录制并停止被抢占的录制；磁盘剩余空间不足时停止全部录制
+func (m *Manager) tick(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop == nil {
+		return
+	}
+	for key, end := range m.done {
+		if !end.After(now) {
+			delete(m.done, key)
+		}
+	}
+
+	var wanted []window
+	for _, t := range m.timers {
+		if t.Disabled {
+			continue
+		}
+		w, ok, err := m.next(t, now)
+		if err != nil || !ok || w.start.After(now) {
+			continue
+		}
+		if _, done := m.done[w.key()]; done {
+			continue
+		}
+		wanted = append(wanted, w)
+	}
+	sort.Slice(wanted, func(i, j int) bool {
+		a, b := wanted[i], wanted[j]
+		if a.timer.Priority != b.timer.Priority {
+			return a.timer.Priority > b.timer.Priority
+		}
+		ra, rb := m.active[a.key()] != nil, m.active[b.key()] != nil
+		if ra != rb {
+			return ra
+		}
+		if !a.start.Equal(b.start) {
+			return a.start.Before(b.start)
+		}
+		return a.timer.ID < b.timer.ID
+	})
+
+	diskErr := m.checkDiskLocked(now)
+	selected := make(map[string]bool, len(wanted))
+	for i, w := range wanted {
+		key := w.key()
+		if i >= m.s.MaxConcurrent {
+			if m.active[key] == nil {
+				m.noteLocked(w.timer.ID, "与其它录制时间重叠，超出同时录制上限")
+			}
+			continue
+		}
+		selected[key] = true
+		if m.active[key] != nil {
+			continue
+		}
+		if diskErr != nil {
+			m.noteLocked(w.timer.ID, diskErr.Error())
+			continue
+		}
+		if err := m.startLocked(w, now); err != nil {
+			m.noteLocked(w.timer.ID, err.Error())
+			continue
+		}
+		delete(m.notes, w.timer.ID)
+	}
+	for key, sess := range m.active {
+		switch {
+		case diskErr != nil:
+			sess.cancel(diskErr.Error())
+		case !selected[key]:
+			// 定时任务被删除、停用、修改或被优先级更高的录制抢占
+			sess.cancel("已停止：定时任务已变更或被优先级更高的录制抢占")
+		}
+	}
+}
+
+// noteLocked 记录定时任务未能录制的原因，相同原因只记录一次日志
+func (m *Manager) noteLocked(id, note string) {
+	if m.notes[id] == note {
+		return
+	}
+	m.notes[id] = note
+	logger.LogPrintf("⚠️ 定时录制 %s 未能录制: %s", id, note)
+}
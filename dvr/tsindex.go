@@ -0,0 +1,216 @@
+package dvr
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+)
+
+const tsPacketSize = 188
+
+// segment HLS 分片：录制文件中的字节范围与时长
+type segment struct {
+	offset, size int64
+	duration     float64
+}
+
+// tsIndex TS 录制文件的分片索引，按文件大小与修改时间判断是否过期
+type tsIndex struct {
+	size    int64
+	modTime time.Time
+	header  []byte // 文件中第一个 PAT 与 PMT 包，加在每个分片前，播放器从任意分片开始都能解析节目
+	segs    []segment
+}
+
+// tsScanner 扫描 TS 包，按视频关键帧（random_access_indicator）与 PCR 时间切分
+type tsScanner struct {
+	target float64
+
+	pat      []byte
+	pmts     map[uint16][]byte // PMT PID -> 第一个 PMT 包，nil 表示尚未收到
+	video    map[uint16]bool
+	pcrPID   int
+	lastPCR  int64
+	now      float64 // 按 PCR 累计的时间（秒）
+	hasPCR   bool
+	segStart float64
+	segOff   int64
+	segs     []segment
+}
+
+// buildIndex 扫描文件生成分片索引；没有 PCR 时整个文件作为一个分片，时长为 fallback
+func buildIndex(f *os.File, target time.Duration, fallback time.Duration) (*tsIndex, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	s := &tsScanner{target: target.Seconds(), pmts: make(map[uint16][]byte), video: make(map[uint16]bool), pcrPID: -1}
+	r := bufio.NewReaderSize(io.NewSectionReader(f, 0, info.Size()), 256<<10)
+	var off int64
+	pkt := make([]byte, tsPacketSize)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		if b != 0x47 {
+			// 跳过不完整或损坏的数据直到下一个同步字节
+			off++
+			continue
+		}
+		pkt[0] = b
+		if _, err := io.ReadFull(r, pkt[1:]); err != nil {
+			break
+		}
+		s.packet(pkt, off)
+		off += tsPacketSize
+	}
+
+	idx := &tsIndex{size: info.Size(), modTime: info.ModTime()}
+	if s.pat != nil {
+		idx.header = append(idx.header, s.pat...)
+		for _, p := range s.pmts {
+			idx.header = append(idx.header, p...)
+		}
+	}
+	last := segment{offset: s.segOff, size: info.Size() - s.segOff, duration: s.now - s.segStart}
+	if !s.hasPCR {
+		last.duration = fallback.Seconds()
+	}
+	if last.size > 0 {
+		if last.duration <= 0 {
+			last.duration = s.target
+		}
+		idx.segs = append(s.segs, last)
+	} else {
+		idx.segs = s.segs
+	}
+	return idx, nil
+}
+
+// packet 处理一个 TS 包
+func (s *tsScanner) packet(p []byte, off int64) {
+	pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+	pusi := p[1]&0x40 != 0
+	payload := 4
+	var rai bool
+	if p[3]&0x20 != 0 {
+		alen := int(p[4])
+		payload = 5 + alen
+		if alen > 0 {
+			flags := p[5]
+			rai = flags&0x40 != 0
+			if flags&0x10 != 0 && alen >= 7 && (s.pcrPID < 0 || s.pcrPID == int(pid)) {
+				s.pcrPID = int(pid)
+				s.pcr(int64(p[6])<<25 | int64(p[7])<<17 | int64(p[8])<<9 | int64(p[9])<<1 | int64(p[10])>>7)
+			}
+		}
+	}
+	if payload >= tsPacketSize || p[3]&0x10 == 0 {
+		return
+	}
+
+	switch {
+	case pid == 0 && pusi && s.pat == nil:
+		if s.parsePAT(p[payload:]) {
+			s.pat = append([]byte(nil), p...)
+		}
+	case pusi && s.pmts[pid] == nil && s.isPMT(pid):
+		if s.parsePMT(p[payload:]) {
+			s.pmts[pid] = append([]byte(nil), p...)
+		}
+	}
+
+	if !pusi || off == s.segOff {
+		return
+	}
+	elapsed := s.now - s.segStart
+	var cut bool
+	switch {
+	case len(s.video) > 0:
+		// 在关键帧处切分；编码器未标记关键帧时超过两倍目标时长后在视频帧开始处切分
+		cut = s.video[pid] && (rai && elapsed >= s.target || elapsed >= 2*s.target)
+	default:
+		// 纯音频
+		cut = int(pid) == s.pcrPID && elapsed >= s.target
+	}
+	if cut {
+		s.segs = append(s.segs, segment{offset: s.segOff, size: off - s.segOff, duration: elapsed})
+		s.segOff, s.segStart = off, s.now
+	}
+}
+
+// pcr 按 PCR 累计时间，回绕时继续累计，跳变超过 10 秒视为不连续
+func (s *tsScanner) pcr(base int64) {
+	if !s.hasPCR {
+		s.hasPCR, s.lastPCR = true, base
+		return
+	}
+	delta := (base - s.lastPCR) & (1<<33 - 1)
+	s.lastPCR = base
+	if delta > 10*90000 {
+		return
+	}
+	s.now += float64(delta) / 90000
+}
+
+func (s *tsScanner) isPMT(pid uint16) bool {
+	p, ok := s.pmts[pid]
+	return ok && p == nil
+}
+
+// section 取 PSI 段，只处理从本包开始且不跨包的段
+func section(payload []byte, tableID byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	ptr := int(payload[0])
+	if 1+ptr+3 > len(payload) {
+		return nil
+	}
+	sec := payload[1+ptr:]
+	if sec[0] != tableID {
+		return nil
+	}
+	n := 3 + (int(sec[1]&0x0f)<<8 | int(sec[2]))
+	if n > len(sec) || n < 12 {
+		return nil
+	}
+	return sec[:n-4] // 去掉 CRC
+}
+
+// parsePAT 记录各节目的 PMT PID
+func (s *tsScanner) parsePAT(payload []byte) bool {
+	sec := section(payload, 0x00)
+	if sec == nil {
+		return false
+	}
+	for i := 8; i+4 <= len(sec); i += 4 {
+		program := uint16(sec[i])<<8 | uint16(sec[i+1])
+		pid := uint16(sec[i+2]&0x1f)<<8 | uint16(sec[i+3])
+		if program != 0 {
+			s.pmts[pid] = nil
+		}
+	}
+	return true
+}
+
+// parsePMT 记录视频流 PID
+func (s *tsScanner) parsePMT(payload []byte) bool {
+	sec := section(payload, 0x02)
+	if sec == nil {
+		return false
+	}
+	i := 12 + (int(sec[10]&0x0f)<<8 | int(sec[11]))
+	for i+5 <= len(sec) {
+		streamType := sec[i]
+		pid := uint16(sec[i+1]&0x1f)<<8 | uint16(sec[i+2])
+		switch streamType {
+		case 0x01, 0x02, 0x10, 0x1b, 0x24, 0x42, 0xd1, 0xea:
+			s.video[pid] = true
+		}
+		i += 5 + (int(sec[i+3]&0x0f)<<8 | int(sec[i+4]))
+	}
+	return true
+}
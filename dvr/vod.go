@@ -0,0 +1,348 @@
+package dvr
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// vodItem 点播列表中的一个录制
+type vodItem struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Channel   string    `json:"channel"`
+	Title     string    `json:"title,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Duration  int64     `json:"duration"` // 实际录制时长（秒）
+	Size      int64     `json:"size"`
+	URL       string    `json:"url"`                 // 录制文件，支持 Range 请求
+	HLS       string    `json:"hls,omitempty"`       // TS 录制的 HLS 播放列表
+	Thumbnail string    `json:"thumbnail,omitempty"` // 缩略图
+	MP4       bool      `json:"-"`
+}
+
+// vodContentTypes 录制文件的 Content-Type
+var vodContentTypes = map[string]string{
+	".ts":  "video/mp2t",
+	".mp4": "video/mp4",
+	".jpg": "image/jpeg",
+}
+
+// vodPlayable 已结束、有数据且不在处理中的录制
+func vodPlayable(r *Recording) bool {
+	return (r.State == StateCompleted || r.State == StateStopped) && r.Size > 0 && r.Post != PostProcessing
+}
+
+// VODHandler 录制点播，prefix 为访问路径：
+// prefix 列出已结束的录制，浏览器访问返回 HTML，其它返回 JSON；prefix<id>.ts 或 .mp4 为录制文件，支持 Range 请求；
+// prefix<id>.m3u8 为 TS 录制按关键帧切分的 HLS 播放列表，分片为 prefix<id>/<序号>.ts；prefix<id>.jpg 为缩略图
+func (m *Manager) VODHandler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			httperror.Error(w, r, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, prefix)
+		name, seg, _ := strings.Cut(rest, "/")
+		ext := path.Ext(name)
+		id := strings.TrimSuffix(name, ext)
+		query, ok := vodAuth(w, r, prefix+id)
+		if !ok {
+			return
+		}
+		if rest == "" {
+			m.serveVODList(w, r, prefix, query)
+			return
+		}
+		rec, ok := m.vodRecording(id)
+		if !ok {
+			httperror.Error(w, r, "录制不存在", http.StatusNotFound)
+			return
+		}
+		switch {
+		case seg != "" && ext == "":
+			m.serveSegment(w, r, rec, strings.TrimSuffix(seg, ".ts"))
+		case ext == ".m3u8" && seg == "" && isTS(rec.File):
+			m.servePlaylist(w, r, rec, id, query)
+		case ext == ".jpg" && seg == "" && rec.Thumbnail != "":
+			serveVODFile(w, r, rec.Thumbnail)
+		case seg == "" && strings.EqualFold(ext, filepath.Ext(rec.File)):
+			serveVODFile(w, r, rec.File)
+		default:
+			httperror.Error(w, r, "Not Found", http.StatusNotFound)
+		}
+	})
+}
+
+// vodAuth 与直播相同的全局 token 校验，同一录制的播放列表与分片计为一个会话；
+// 返回附加到列表与播放列表中地址的 token 参数
+func vodAuth(w http.ResponseWriter, r *http.Request, session string) (string, bool) {
+	tm := auth.GetGlobalTokenManager()
+	if tm == nil {
+		return "", true
+	}
+	tokenParam := "my_token"
+	if tm.TokenParamName != "" {
+		tokenParam = tm.TokenParamName
+	}
+	token := r.URL.Query().Get(tokenParam)
+	clientIP := monitor.GetClientIP(r)
+	connID := clientIP + "_" + session
+	if !tm.ValidateSignedURL(r) && !tm.ValidateToken(r, token, connID, clientIP) {
+		httperror.Error(w, r, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+	tm.KeepAlive(token, connID, clientIP, r.URL.Path)
+	if token == "" {
+		return "", true
+	}
+	return "?" + url.Values{tokenParam: {token}}.Encode(), true
+}
+
+func isTS(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".ts")
+}
+
+// vodRecording 按 ID 取可以点播的录制
+func (m *Manager) vodRecording(id string) (Recording, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.s.Enabled || !m.s.VOD.Enabled {
+		return Recording{}, false
+	}
+	for _, r := range m.recordings {
+		if r.ID == id && vodPlayable(r) {
+			return *r, true
+		}
+	}
+	return Recording{}, false
+}
+
+// serveVODFile 发送录制文件或缩略图，支持 Range 请求
+func serveVODFile(w http.ResponseWriter, r *http.Request, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		httperror.Error(w, r, "录制文件不存在", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ct, ok := vodContentTypes[strings.ToLower(filepath.Ext(file))]; ok {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeContent(w, r, filepath.Base(file), info.ModTime(), f)
+}
+
+// serveVODList 列出可以点播的录制，最新的在前
+func (m *Manager) serveVODList(w http.ResponseWriter, r *http.Request, prefix, query string) {
+	m.mu.Lock()
+	if !m.s.Enabled || !m.s.VOD.Enabled {
+		m.mu.Unlock()
+		httperror.Error(w, r, "Not Found", http.StatusNotFound)
+		return
+	}
+	items := make([]vodItem, 0, len(m.recordings))
+	for i := len(m.recordings) - 1; i >= 0; i-- {
+		rec := m.recordings[i]
+		if !vodPlayable(rec) {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(rec.File))
+		it := vodItem{
+			ID:       rec.ID,
+			Name:     rec.Name,
+			Channel:  rec.Channel,
+			Title:    rec.Title,
+			Start:    rec.Start,
+			End:      rec.End,
+			Duration: int64(rec.Finished.Sub(rec.Started).Seconds()),
+			Size:     rec.Size,
+			URL:      prefix + rec.ID + ext + query,
+			MP4:      ext == ".mp4",
+		}
+		if ext == ".ts" {
+			it.HLS = prefix + rec.ID + ".m3u8" + query
+		}
+		if rec.Thumbnail != "" {
+			it.Thumbnail = prefix + rec.ID + ".jpg" + query
+		}
+		items = append(items, it)
+	}
+	m.mu.Unlock()
+
+	if r.URL.Query().Get("format") != "json" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := vodListPage.Execute(w, items); err != nil {
+			logger.LogPrintf("⚠️ 点播列表页面生成失败: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(items)
+}
+
+// index 取录制文件的分片索引，文件大小或修改时间变化时重新扫描
+func (m *Manager) index(rec Recording) (*tsIndex, error) {
+	m.mu.Lock()
+	target := m.s.VOD.SegmentDuration
+	m.mu.Unlock()
+
+	m.indexMu.Lock()
+	defer m.indexMu.Unlock()
+	f, err := os.Open(rec.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if idx := m.indexes[rec.File]; idx != nil && idx.size == info.Size() && idx.modTime.Equal(info.ModTime()) {
+		return idx, nil
+	}
+	start := time.Now()
+	idx, err := buildIndex(f, target, rec.Finished.Sub(rec.Started))
+	if err != nil {
+		return nil, err
+	}
+	m.indexes[rec.File] = idx
+	logger.LogPrintf("📼 点播分片索引 %s: %d 个分片，耗时 %s", rec.File, len(idx.segs), time.Since(start).Round(time.Millisecond))
+	return idx, nil
+}
+
+// servePlaylist 生成 HLS 点播播放列表，分片地址附加请求中的 token
+func (m *Manager) servePlaylist(w http.ResponseWriter, r *http.Request, rec Recording, id, query string) {
+	idx, err := m.index(rec)
+	if err != nil {
+		httperror.Error(w, r, "读取录制文件失败", http.StatusNotFound)
+		return
+	}
+	maxDur := 1.0
+	for _, s := range idx.segs {
+		maxDur = math.Max(maxDur, s.duration)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n", int(math.Ceil(maxDur)))
+	for i, s := range idx.segs {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s/%d.ts%s\n", s.duration, id, i, query)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, b.String())
+}
+
+// serveSegment 发送一个 HLS 分片：文件开头的 PAT/PMT 加上分片的字节范围
+func (m *Manager) serveSegment(w http.ResponseWriter, r *http.Request, rec Recording, seq string) {
+	n, err := strconv.Atoi(seq)
+	if err != nil || n < 0 {
+		httperror.Error(w, r, "Not Found", http.StatusNotFound)
+		return
+	}
+	idx, err := m.index(rec)
+	if err != nil || n >= len(idx.segs) {
+		httperror.Error(w, r, "Not Found", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(rec.File)
+	if err != nil {
+		httperror.Error(w, r, "录制文件不存在", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	s := idx.segs[n]
+	var header []byte
+	if s.offset > 0 {
+		header = idx.header
+	}
+	w.Header().Set("Content-Type", "video/mp2t")
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(len(header))+s.size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(header); err != nil {
+		return
+	}
+	io.Copy(w, io.NewSectionReader(f, s.offset, s.size))
+}
+
+// vodListPage 点播列表页面
+var vodListPage = template.Must(template.New("vod").Funcs(template.FuncMap{
+	"localTime": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"duration":  func(sec int64) string { return (time.Duration(sec) * time.Second).String() },
+	"sizeMB":    func(n int64) string { return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MB" },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>录制点播</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; margin: 0; padding: 20px; background: #f3f3f3; color: #1a1a1a; }
+h1 { font-size: 22px; font-weight: 600; }
+.item { display: flex; gap: 16px; background: #fff; border-radius: 8px; padding: 12px; margin-bottom: 12px; box-shadow: 0 1px 3px rgba(0,0,0,.08); }
+.item img, .item .blank { width: 200px; height: 112px; object-fit: cover; border-radius: 4px; background: #ddd; flex-shrink: 0; }
+.item h2 { font-size: 16px; margin: 0 0 6px; }
+.meta { color: #666; font-size: 13px; margin-bottom: 8px; }
+a { color: #0067c0; margin-right: 12px; text-decoration: none; }
+video { width: 100%; max-width: 720px; margin-top: 8px; display: none; }
+.empty { color: #666; }
+</style>
+</head>
+<body>
+<h1>录制点播</h1>
+{{range .}}
+<div class="item">
+    {{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="">{{else}}<div class="blank"></div>{{end}}
+    <div>
+        <h2>{{.Name}}{{if and .Title (ne .Title .Name)}}（{{.Title}}）{{end}}</h2>
+        <div class="meta">{{.Channel}} · {{localTime .Start}} · {{duration .Duration}} · {{sizeMB .Size}}</div>
+        {{if .MP4}}<a href="#" data-src="{{.URL}}" onclick="return play(this)">播放</a>{{end}}
+        {{if .HLS}}<a href="#" data-src="{{.HLS}}" onclick="return play(this)">播放（HLS）</a>{{end}}
+        <a href="{{.URL}}" download>下载</a>
+        {{if .HLS}}<a href="{{.HLS}}">HLS 地址</a>{{end}}
+        <video controls></video>
+    </div>
+</div>
+{{else}}
+<p class="empty">还没有录制</p>
+{{end}}
+<script>
+function play(link) {
+    const video = link.parentNode.querySelector('video');
+    const src = link.dataset.src;
+    if (src.indexOf('.m3u8') >= 0 && !video.canPlayType('application/vnd.apple.mpegurl')) {
+        alert('浏览器不支持直接播放 HLS，请复制 HLS 地址到播放器中打开');
+        return false;
+    }
+    video.src = src;
+    video.style.display = 'block';
+    video.play();
+    return false;
+}
+</script>
+</body>
+</html>
+`))
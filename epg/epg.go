@@ -0,0 +1,363 @@
+// Package epg XMLTV 节目单：定时拉取一个或多个 XMLTV 源，合并后按配置的频道过滤并改写 tvg-id，
+// 缓存在内存和文件中，在 /xmltv.xml 提供给播放器
+package epg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// ErrDisabled 未启用节目单
+var ErrDisabled = errors.New("未启用节目单")
+
+// snapshot 一次合并的结果
+type snapshot struct {
+	xml        []byte
+	gz         []byte
+	channels   int
+	programmes int
+	icons      map[string]string // 频道图标，见 guide.icons
+	schedule   []Programme       // 按频道与开始时间排序的节目，见 guide.schedule
+	updated    time.Time
+}
+
+// Status 节目单状态
+type Status struct {
+	Enabled    bool              `json:"enabled"`
+	Path       string            `json:"path"`
+	Updated    time.Time         `json:"updated"` // 最近一次成功合并的时间，零值表示尚无数据
+	Channels   int               `json:"channels"`
+	Programmes int               `json:"programmes"`
+	Size       int               `json:"size"`    // 未压缩大小（字节）
+	GzipSize   int               `json:"gz_size"` // 压缩后大小（字节）
+	LastError  string            `json:"last_error,omitempty"`
+	Sources    map[string]string `json:"sources"` // 各源最近一次拉取结果：ok 或错误信息
+}
+
+// Manager 节目单拉取、合并与缓存
+type Manager struct {
+	mu       sync.RWMutex
+	cfg      config.EPGConfig
+	stop     chan struct{}
+	trigger  chan struct{}
+	current  *snapshot
+	lastErr  error
+	sources  map[string]string
+	loadedAt string // 已从中加载缓存的文件
+}
+
+// New 创建未启用的节目单管理器
+func New() *Manager { return &Manager{} }
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.EPGConfig) { Default.Configure(cfg) }
+
+// defaultFile 默认缓存文件，与配置文件放在同一目录
+func defaultFile() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "epg.xml.gz")
+}
+
+// FilePath 返回配置对应的缓存文件
+func FilePath(cfg config.EPGConfig) string {
+	if cfg.File != "" {
+		return cfg.File
+	}
+	return defaultFile()
+}
+
+// Configure 应用配置：源、频道或刷新间隔变化时立即重新拉取，禁用时停止并清空节目单
+func (m *Manager) Configure(cfg config.EPGConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := m.cfg
+	m.cfg = cfg
+	if !cfg.Enabled || len(cfg.Sources) == 0 {
+		if m.stop != nil {
+			close(m.stop)
+			m.stop = nil
+		}
+		m.current, m.lastErr, m.sources, m.loadedAt = nil, nil, nil, ""
+		return
+	}
+	if m.current == nil && m.loadedAt != FilePath(cfg) {
+		// 先使用上次的缓存，拉取完成前也能提供节目单
+		m.loadCacheLocked(FilePath(cfg))
+	}
+	if m.stop != nil && reflect.DeepEqual(old.Sources, cfg.Sources) && reflect.DeepEqual(old.Channels, cfg.Channels) &&
+		reflect.DeepEqual(old.Headers, cfg.Headers) && old.Refresh == cfg.Refresh {
+		return
+	}
+	if m.stop != nil {
+		close(m.stop)
+	}
+	m.stop = make(chan struct{})
+	m.trigger = make(chan struct{}, 1)
+	go m.run(m.stop, m.trigger, cfg.Refresh)
+}
+
+func (m *Manager) loadCacheLocked(path string) {
+	m.loadedAt = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return
+	}
+	// 重新解析一次以获得频道数和图标
+	snap, _, err := build([]string{path}, nil, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	})
+	if err != nil {
+		return
+	}
+	snap.xml, snap.gz = raw, data
+	if info, err := os.Stat(path); err == nil {
+		snap.updated = info.ModTime()
+	}
+	m.current = snap
+	logger.LogPrintf("✅ 已加载节目单缓存: %s（%d 个频道）", path, snap.channels)
+}
+
+func (m *Manager) run(stop, trigger chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	m.refresh(stop)
+	for {
+		select {
+		case <-ticker.C:
+			m.refresh(stop)
+		case <-trigger:
+			m.refresh(stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Refresh 立即重新拉取，拉取在后台进行
+func (m *Manager) Refresh() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.stop == nil {
+		return ErrDisabled
+	}
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// refresh 拉取并合并全部源，成功后替换当前节目单并写入缓存文件；失败时保留上次的节目单
+func (m *Manager) refresh(stop chan struct{}) {
+	m.mu.RLock()
+	cfg := m.cfg
+	m.mu.RUnlock()
+
+	start := time.Now()
+	snap, errs, err := build(cfg.Sources, cfg.Channels, func(src string) (io.ReadCloser, error) {
+		return open(cfg, src)
+	})
+	sources := make(map[string]string, len(cfg.Sources))
+	for _, src := range cfg.Sources {
+		if e := errs[src]; e != nil {
+			sources[src] = e.Error()
+			logger.LogPrintf("⚠️ 拉取节目单源 %s 失败: %v", src, e)
+		} else {
+			sources[src] = "ok"
+		}
+	}
+	if err == nil {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(snap.xml)
+		zw.Close()
+		snap.gz = buf.Bytes()
+		snap.updated = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != stop {
+		// 配置已变化，结果作废
+		return
+	}
+	m.sources = sources
+	m.lastErr = err
+	if err != nil {
+		logger.LogPrintf("❌ 更新节目单失败: %v", err)
+		return
+	}
+	m.current = snap
+	logger.LogPrintf("✅ 节目单已更新：%d 个频道，%d 个节目，耗时 %s", snap.channels, snap.programmes, time.Since(start).Round(time.Millisecond))
+	if path := FilePath(cfg); path != "" {
+		if err := writeFile(path, snap.gz); err != nil {
+			logger.LogPrintf("⚠️ 写入节目单缓存失败: %v", err)
+		}
+		m.loadedAt = path
+	}
+}
+
+// open 打开 http(s) 地址或本地文件
+func open(cfg config.EPGConfig, src string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.Open(src)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "TVGate")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("返回错误状态码 %d", resp.StatusCode)
+	}
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelBody 关闭响应体时释放超时 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// writeFile 先写临时文件再重命名，避免读取到写了一半的缓存
+func writeFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Status 返回节目单状态
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := Status{
+		Enabled: m.stop != nil,
+		Path:    m.cfg.Path,
+		Sources: make(map[string]string, len(m.sources)),
+	}
+	for k, v := range m.sources {
+		st.Sources[k] = v
+	}
+	if m.lastErr != nil {
+		st.LastError = m.lastErr.Error()
+	}
+	if s := m.current; s != nil {
+		st.Updated = s.updated
+		st.Channels = s.channels
+		st.Programmes = s.programmes
+		st.Size = len(s.xml)
+		st.GzipSize = len(s.gz)
+	}
+	return st
+}
+
+// Icon 返回节目单中频道的图标地址，按频道 id 或显示名称（不区分大小写）查找
+func (m *Manager) Icon(channel string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.current == nil {
+		return ""
+	}
+	return m.current.icons[strings.ToLower(strings.TrimSpace(channel))]
+}
+
+// Handler 提供节目单：path 返回 XML（客户端支持时以 gzip 传输），path 加 .gz 返回压缩文件
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m.mu.RLock()
+		snap := m.current
+		m.mu.RUnlock()
+		if snap == nil {
+			http.Error(w, "EPG not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		if strings.HasSuffix(r.URL.Path, ".gz") {
+			w.Header().Set("Content-Type", "application/gzip")
+			http.ServeContent(w, r, "xmltv.xml.gz", snap.updated, bytes.NewReader(snap.gz))
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+			http.ServeContent(w, r, "", snap.updated, bytes.NewReader(snap.gz))
+			return
+		}
+		http.ServeContent(w, r, "", snap.updated, bytes.NewReader(snap.xml))
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(enc), "gzip") && strings.TrimSpace(q) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
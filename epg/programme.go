@@ -0,0 +1,109 @@
+package epg
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Programme 节目单中的一个节目
+type Programme struct {
+	ID      string    `json:"id"`      // 节目 ID：频道 id@开始时间（UTC，20060102150405）
+	Channel string    `json:"channel"` // 输出的频道 id
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	Stop    time.Time `json:"stop"`
+}
+
+// ProgrammeID 按频道 id 与开始时间生成节目 ID
+func ProgrammeID(channel string, start time.Time) string {
+	return channel + "@" + start.UTC().Format("20060102150405")
+}
+
+// parseTime 解析 XMLTV 时间，如 20240101120000 +0800，省略时区时按 UTC
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"20060102150405 -0700", "20060102150405"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// schedule 解析节目的开始、结束时间与标题，按频道与开始时间排序；缺少开始或结束时间的节目忽略
+func (g *guide) schedule() []Programme {
+	list := make([]Programme, 0, len(g.programmes))
+	for _, p := range g.programmes {
+		var start, stop time.Time
+		var okStart, okStop bool
+		for _, a := range p.Attrs {
+			switch a.Name.Local {
+			case "start":
+				start, okStart = parseTime(a.Value)
+			case "stop":
+				stop, okStop = parseTime(a.Value)
+			}
+		}
+		if !okStart || !okStop || !stop.After(start) {
+			continue
+		}
+		var inner struct {
+			Titles []string `xml:"title"`
+		}
+		_ = xml.Unmarshal([]byte("<p>"+p.Inner+"</p>"), &inner)
+		title := ""
+		if len(inner.Titles) > 0 {
+			title = strings.TrimSpace(inner.Titles[0])
+		}
+		list = append(list, Programme{ID: ProgrammeID(p.Channel, start), Channel: p.Channel, Title: title, Start: start, Stop: stop})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Channel != list[j].Channel {
+			return list[i].Channel < list[j].Channel
+		}
+		return list[i].Start.Before(list[j].Start)
+	})
+	return list
+}
+
+// Programme 按节目 ID 查找节目
+func (m *Manager) Programme(id string) (Programme, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.current == nil {
+		return Programme{}, false
+	}
+	channel := id
+	if i := strings.LastIndexByte(id, '@'); i >= 0 {
+		channel = id[:i]
+	}
+	list := m.current.schedule
+	i := sort.Search(len(list), func(i int) bool { return list[i].Channel >= channel })
+	for ; i < len(list) && list[i].Channel == channel; i++ {
+		if list[i].ID == id {
+			return list[i], true
+		}
+	}
+	return Programme{}, false
+}
+
+// Programmes 列出频道在 [from, to) 内播出的节目，channel 为空时列出所有频道
+func (m *Manager) Programmes(channel string, from, to time.Time) []Programme {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Programme, 0)
+	if m.current == nil {
+		return result
+	}
+	for _, p := range m.current.schedule {
+		if channel != "" && p.Channel != channel {
+			continue
+		}
+		if p.Stop.After(from) && p.Start.Before(to) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package epg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+)
+
+// xmlChannel XMLTV 中的 <channel>，Inner 保留原始内容（display-name、icon、url 等）
+type xmlChannel struct {
+	ID    string   `xml:"id,attr"`
+	Names []string `xml:"display-name"`
+	Icons []struct {
+		Src string `xml:"src,attr"`
+	} `xml:"icon"`
+	Inner string `xml:",innerxml"`
+}
+
+// xmlProgramme XMLTV 中的 <programme>，只改写 channel 属性，其余属性与内容原样输出
+type xmlProgramme struct {
+	Channel string     `xml:"channel,attr"`
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// guide 合并后的节目单
+type guide struct {
+	channels   []xmlChannel
+	programmes []xmlProgramme
+
+	seen   map[string]bool // 已输出的频道 id
+	filled map[string]bool // 已由靠前的源提供节目的频道 id
+}
+
+// matcher 源中的频道 id / 显示名称到输出频道的映射
+type matcher struct {
+	all   bool                          // 未配置频道，输出全部
+	byKey map[string]*config.EPGChannel // 小写的 id 或显示名称
+}
+
+func newMatcher(channels []config.EPGChannel) *matcher {
+	m := &matcher{all: len(channels) == 0, byKey: make(map[string]*config.EPGChannel)}
+	for i := range channels {
+		ch := &channels[i]
+		keys := ch.Match
+		if len(keys) == 0 {
+			keys = []string{ch.ID}
+		}
+		for _, k := range keys {
+			k = strings.ToLower(strings.TrimSpace(k))
+			if _, dup := m.byKey[k]; k != "" && !dup {
+				m.byKey[k] = ch
+			}
+		}
+	}
+	return m
+}
+
+// lookup 按频道 id 与显示名称查找输出频道，未匹配时返回 nil
+func (m *matcher) lookup(id string, names []string) *config.EPGChannel {
+	if ch := m.byKey[strings.ToLower(strings.TrimSpace(id))]; ch != nil {
+		return ch
+	}
+	for _, n := range names {
+		if ch := m.byKey[strings.ToLower(strings.TrimSpace(n))]; ch != nil {
+			return ch
+		}
+	}
+	return nil
+}
+
+// merge 解析一个 XMLTV 源并合并到 g：同一输出频道只保留第一个提供节目的源
+func (g *guide) merge(r io.Reader, m *matcher) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// XMLTV 源几乎都是 UTF-8，个别源声明了其他编码但实际内容仍为 UTF-8
+		return input, nil
+	}
+
+	ids := make(map[string]string)    // 源频道 id -> 输出频道 id
+	provides := make(map[string]bool) // 本源提供节目的输出频道 id
+	foundTv := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "tv":
+			foundTv = true
+		case "channel":
+			var ch xmlChannel
+			if err := dec.DecodeElement(&ch, &se); err != nil {
+				return err
+			}
+			out := ch.ID
+			if !m.all {
+				cfg := m.lookup(ch.ID, ch.Names)
+				if cfg == nil {
+					continue
+				}
+				out = cfg.ID
+				if cfg.Name != "" {
+					ch.Inner = "<display-name>" + escape(cfg.Name) + "</display-name>" + ch.Inner
+				}
+			}
+			ids[ch.ID] = out
+			if !g.seen[out] {
+				g.seen[out] = true
+				ch.ID = out
+				g.channels = append(g.channels, ch)
+			}
+		case "programme":
+			var p xmlProgramme
+			if err := dec.DecodeElement(&p, &se); err != nil {
+				return err
+			}
+			out, ok := ids[p.Channel]
+			if !ok {
+				// 节目出现在频道定义之前或源中没有频道定义时按 id 匹配
+				out = p.Channel
+				if !m.all {
+					cfg := m.lookup(p.Channel, nil)
+					if cfg == nil {
+						continue
+					}
+					out = cfg.ID
+				}
+				ids[p.Channel] = out
+			}
+			if g.filled[out] {
+				continue
+			}
+			provides[out] = true
+			p.Channel = out
+			g.programmes = append(g.programmes, p)
+		default:
+			if !foundTv {
+				return fmt.Errorf("不是 XMLTV 格式: 根元素为 <%s>", se.Name.Local)
+			}
+			dec.Skip()
+		}
+	}
+	if !foundTv {
+		return fmt.Errorf("不是 XMLTV 格式: 缺少 <tv> 元素")
+	}
+	for id := range provides {
+		g.filled[id] = true
+	}
+	return nil
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// encode 输出合并后的 XMLTV
+func (g *guide) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString("<!DOCTYPE tv SYSTEM \"xmltv.dtd\">\n")
+	bw.WriteString("<tv generator-info-name=\"TVGate\">\n")
+	for _, ch := range g.channels {
+		fmt.Fprintf(bw, "  <channel id=\"%s\">%s</channel>\n", escape(ch.ID), ch.Inner)
+	}
+	for _, p := range g.programmes {
+		bw.WriteString("  <programme")
+		for _, a := range p.Attrs {
+			fmt.Fprintf(bw, " %s=\"%s\"", a.Name.Local, escape(a.Value))
+		}
+		fmt.Fprintf(bw, " channel=\"%s\">%s</programme>\n", escape(p.Channel), p.Inner)
+	}
+	bw.WriteString("</tv>\n")
+	return bw.Flush()
+}
+
+// build 依次合并所有源，返回合并结果与各源的错误；全部失败时返回错误
+func build(sources []string, channels []config.EPGChannel, open func(string) (io.ReadCloser, error)) (*snapshot, map[string]error, error) {
+	g := &guide{seen: make(map[string]bool), filled: make(map[string]bool)}
+	m := newMatcher(channels)
+	errs := make(map[string]error)
+	for _, src := range sources {
+		rc, err := open(src)
+		if err == nil {
+			err = g.merge(rc, m)
+			rc.Close()
+		}
+		if err != nil {
+			errs[src] = err
+		}
+	}
+	if len(errs) == len(sources) {
+		return nil, errs, fmt.Errorf("全部 %d 个节目单源拉取失败", len(sources))
+	}
+	var buf bytes.Buffer
+	if err := g.encode(&buf); err != nil {
+		return nil, errs, err
+	}
+	return &snapshot{xml: buf.Bytes(), channels: len(g.channels), programmes: len(g.programmes), icons: g.icons(), schedule: g.schedule()}, errs, nil
+}
+
+// icons 频道图标地址，按小写的频道 id 和显示名称索引
+func (g *guide) icons() map[string]string {
+	icons := make(map[string]string)
+	for _, ch := range g.channels {
+		if len(ch.Icons) == 0 || ch.Icons[0].Src == "" {
+			continue
+		}
+		src := ch.Icons[0].Src
+		for _, key := range append([]string{ch.ID}, ch.Names...) {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if _, ok := icons[key]; key != "" && !ok {
+				icons[key] = src
+			}
+		}
+	}
+	return icons
+}
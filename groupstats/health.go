@@ -0,0 +1,226 @@
+package groupstats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	p "github.com/qist/tvgate/proxy"
+)
+
+// 健康检查默认参数
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+	defaultHealthRise     = 2
+	defaultHealthFall     = 3
+	defaultHealthURL      = "http://www.gstatic.com/generate_204"
+)
+
+// healthParams 补全默认值后的健康检查参数
+type healthParams struct {
+	url      string
+	interval time.Duration
+	timeout  time.Duration
+	rise     int
+	fall     int
+}
+
+func resolveHealthParams(hc *config.HealthCheckConfig) healthParams {
+	hp := healthParams{
+		url:      hc.URL,
+		interval: hc.Interval,
+		timeout:  hc.Timeout,
+		rise:     hc.Rise,
+		fall:     hc.Fall,
+	}
+	if hp.url == "" {
+		hp.url = defaultHealthURL
+	}
+	if hp.interval <= 0 {
+		hp.interval = defaultHealthInterval
+	}
+	if hp.timeout <= 0 {
+		hp.timeout = defaultHealthTimeout
+	}
+	if hp.rise <= 0 {
+		hp.rise = defaultHealthRise
+	}
+	if hp.fall <= 0 {
+		hp.fall = defaultHealthFall
+	}
+	return hp
+}
+
+// 正在进行中的检查，避免同一代理的检查重叠
+var (
+	healthInflightMu sync.Mutex
+	healthInflight   = make(map[string]bool)
+)
+
+// StartHealthChecker 周期性地对启用了 health_check 的代理组执行主动健康检查，
+// 连续失败达到 fall 次的代理被标记为 down 并移出轮换，连续成功 rise 次后恢复
+func StartHealthChecker(tick time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			runDueHealthChecks()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// runDueHealthChecks 找出到期的代理并发起检查
+func runDueHealthChecks() {
+	now := time.Now()
+
+	config.CfgMu.RLock()
+	type job struct {
+		groupName string
+		group     *config.ProxyGroupConfig
+		proxy     config.ProxyConfig
+		params    healthParams
+	}
+	var jobs []job
+	for name, group := range config.Cfg.ProxyGroups {
+		if group == nil || group.HealthCheck == nil || !group.HealthCheck.Enabled || group.Stats == nil {
+			continue
+		}
+		hp := resolveHealthParams(group.HealthCheck)
+
+		group.Stats.RLock()
+		for _, proxy := range group.Proxies {
+			if proxy == nil || proxy.Name == "" || proxy.Disabled {
+				continue
+			}
+			stats := group.Stats.ProxyStats[proxy.Name]
+			if stats != nil && now.Sub(stats.HealthLastCheck) < hp.interval {
+				continue
+			}
+			jobs = append(jobs, job{groupName: name, group: group, proxy: *proxy, params: hp})
+		}
+		group.Stats.RUnlock()
+	}
+	config.CfgMu.RUnlock()
+
+	for _, j := range jobs {
+		key := j.groupName + "/" + j.proxy.Name
+		healthInflightMu.Lock()
+		if healthInflight[key] {
+			healthInflightMu.Unlock()
+			continue
+		}
+		healthInflight[key] = true
+		healthInflightMu.Unlock()
+
+		go func(j job, key string) {
+			defer func() {
+				healthInflightMu.Lock()
+				delete(healthInflight, key)
+				healthInflightMu.Unlock()
+			}()
+			rt, status, err := checkProxyHealth(j.group, j.proxy, j.params.url, j.params.timeout)
+			RecordProbe(j.groupName, j.proxy.Name, NewProbeRecord(ProbeHealth, rt, status, err))
+			updateHealthStats(j.groupName, j.group, j.proxy.Name, j.params, rt, err)
+		}(j, key)
+	}
+}
+
+// checkProxyHealth 通过代理访问检查地址，返回耗时和状态码，5xx 及网络错误视为失败
+func checkProxyHealth(group *config.ProxyGroupConfig, proxy config.ProxyConfig, url string, timeout time.Duration) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := p.CreateGroupProxyClient(ctx, &config.Cfg, group, proxy)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Range", "bytes=0-1023")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	rt := time.Since(start)
+	if err != nil {
+		return rt, 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return rt, resp.StatusCode, fmt.Errorf("HTTP 状态码 %d", resp.StatusCode)
+	}
+	return rt, resp.StatusCode, nil
+}
+
+// TestURL 手动测试使用的地址：请求指定的地址，否则为健康检查地址
+func TestURL(group *config.ProxyGroupConfig, url string) string {
+	if url != "" {
+		return url
+	}
+	if group.HealthCheck != nil && group.HealthCheck.URL != "" {
+		return group.HealthCheck.URL
+	}
+	return defaultHealthURL
+}
+
+// TestProxy 手动测试代理（停用的代理同样可以测试），结果记入探测历史，不影响代理的选择状态
+func TestProxy(groupName string, group *config.ProxyGroupConfig, proxy config.ProxyConfig, url string) ProbeRecord {
+	timeout := defaultHealthTimeout
+	if group.HealthCheck != nil && group.HealthCheck.Timeout > 0 {
+		timeout = group.HealthCheck.Timeout
+	}
+	rt, status, err := checkProxyHealth(group, proxy, TestURL(group, url), timeout)
+	rec := NewProbeRecord(ProbeManual, rt, status, err)
+	RecordProbe(groupName, proxy.Name, rec)
+	return rec
+}
+
+// updateHealthStats 根据检查结果更新连续成功/失败计数并处理状态切换
+func updateHealthStats(groupName string, group *config.ProxyGroupConfig, proxyName string, hp healthParams, rt time.Duration, err error) {
+	group.Stats.Lock()
+	defer group.Stats.Unlock()
+
+	stats := group.Stats.ProxyStats[proxyName]
+	if stats == nil {
+		stats = &config.ProxyStats{Alive: true}
+		group.Stats.ProxyStats[proxyName] = stats
+	}
+	stats.HealthLastCheck = time.Now()
+	stats.HealthRT = rt
+
+	if err != nil {
+		stats.HealthError = err.Error()
+		stats.HealthSuccesses = 0
+		stats.HealthFailures++
+		if stats.HealthStatus != config.HealthDown && stats.HealthFailures >= hp.fall {
+			stats.HealthStatus = config.HealthDown
+			logger.LogPrintf("❌ 代理组 %s: 代理 %s 连续 %d 次健康检查失败，移出轮换: %v", groupName, proxyName, stats.HealthFailures, err)
+		}
+		return
+	}
+
+	stats.HealthError = ""
+	stats.HealthFailures = 0
+	stats.HealthSuccesses++
+	switch stats.HealthStatus {
+	case config.HealthDown:
+		if stats.HealthSuccesses >= hp.rise {
+			stats.HealthStatus = config.HealthUp
+			logger.LogPrintf("✅ 代理组 %s: 代理 %s 连续 %d 次健康检查成功，恢复轮换", groupName, proxyName, stats.HealthSuccesses)
+		}
+	case config.HealthUnknown:
+		// 首次检查成功即视为健康，避免启动阶段无代理可用
+		stats.HealthStatus = config.HealthUp
+	}
+}
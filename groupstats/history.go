@@ -0,0 +1,73 @@
+package groupstats
+
+import (
+	"sync"
+	"time"
+)
+
+// 探测来源
+const (
+	ProbeSpeedTest = "speedtest" // 负载均衡测速
+	ProbeHealth    = "health"    // 主动健康检查
+	ProbeManual    = "manual"    // Web 页面手动测试
+)
+
+// probeHistorySize 每个代理保留的探测记录条数
+const probeHistorySize = 100
+
+// ProbeRecord 一次测速、健康检查或手动测试的结果
+type ProbeRecord struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	OK         bool      `json:"ok"`
+	LatencyMS  float64   `json:"latency_ms"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// 按 "组名/代理名" 保存的探测记录，旧记录在前
+var (
+	probeMu      sync.Mutex
+	probeHistory = make(map[string][]ProbeRecord)
+)
+
+// RecordProbe 记录代理的一次探测结果，超出条数时丢弃最旧的记录
+func RecordProbe(groupName, proxyName string, rec ProbeRecord) {
+	if groupName == "" || proxyName == "" {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	key := groupName + "/" + proxyName
+
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	records := append(probeHistory[key], rec)
+	if len(records) > probeHistorySize {
+		records = append(records[:0:0], records[len(records)-probeHistorySize:]...)
+	}
+	probeHistory[key] = records
+}
+
+// ProbeHistory 返回代理的探测记录，按时间从旧到新
+func ProbeHistory(groupName, proxyName string) []ProbeRecord {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	return append([]ProbeRecord(nil), probeHistory[groupName+"/"+proxyName]...)
+}
+
+// NewProbeRecord 由探测耗时、状态码和错误生成记录
+func NewProbeRecord(source string, rt time.Duration, statusCode int, err error) ProbeRecord {
+	rec := ProbeRecord{
+		Time:       time.Now(),
+		Source:     source,
+		OK:         err == nil,
+		LatencyMS:  float64(rt.Microseconds()) / 1000,
+		StatusCode: statusCode,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
@@ -88,6 +88,10 @@ func isGroupConfigChanged(oldGroup, newGroup *config.ProxyGroupConfig) bool {
 		oldGroup.RetryDelay != newGroup.RetryDelay ||
 		oldGroup.MaxRT != newGroup.MaxRT ||
 		oldGroup.IPv6 != newGroup.IPv6 ||
+		oldGroup.Protocol != newGroup.Protocol ||
+		!reflect.DeepEqual(oldGroup.DNS, newGroup.DNS) ||
+		!reflect.DeepEqual(oldGroup.HealthCheck, newGroup.HealthCheck) ||
+		!reflect.DeepEqual(oldGroup.Fallback, newGroup.Fallback) ||
 		!proxyListEqual(oldGroup.Proxies, newGroup.Proxies)
 }
 
@@ -128,6 +132,7 @@ func proxyEqual(a, b *config.ProxyConfig) bool {
 		a.Username == b.Username &&
 		a.Password == b.Password &&
 		a.Name == b.Name &&
+		a.Weight == b.Weight &&
 		reflect.DeepEqual(a.Headers, b.Headers)
 }
 
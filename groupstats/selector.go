@@ -0,0 +1,178 @@
+package groupstats
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// Selector 代理组负载均衡策略。
+// Select 在调用方持有 group.Stats 写锁的情况下调用，candidates 为当前可用代理（非空）
+type Selector interface {
+	Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, clientIP string) *config.ProxyConfig
+}
+
+var (
+	selectorMu       sync.RWMutex
+	selectorRegistry = map[string]Selector{}
+)
+
+// RegisterSelector 注册负载均衡策略，名称对应代理组的 loadbalance 配置
+func RegisterSelector(name string, s Selector) {
+	selectorMu.Lock()
+	defer selectorMu.Unlock()
+	selectorRegistry[strings.ToLower(name)] = s
+}
+
+// GetSelector 按名称获取负载均衡策略
+func GetSelector(name string) (Selector, bool) {
+	selectorMu.RLock()
+	defer selectorMu.RUnlock()
+	s, ok := selectorRegistry[strings.ToLower(name)]
+	return s, ok
+}
+
+func init() {
+	RegisterSelector("round-robin", roundRobinSelector{})
+	RegisterSelector("least-conn", leastConnSelector{})
+	RegisterSelector("lowest-latency", lowestLatencySelector{})
+	RegisterSelector("weighted", weightedSelector{})
+	RegisterSelector("consistent-hash", consistentHashSelector{})
+}
+
+// AvailableProxies 返回当前可参与选择的代理：未停用、存活、未冷却、未被健康检查下线，
+// 且已测速成功或健康检查正常。调用方需持有 group.Stats 锁
+func AvailableProxies(group *config.ProxyGroupConfig, now time.Time) []*config.ProxyConfig {
+	var result []*config.ProxyConfig
+	for _, proxy := range group.Proxies {
+		if proxy == nil || proxy.Disabled {
+			continue
+		}
+		stats := group.Stats.ProxyStats[proxy.Name]
+		if stats == nil || !stats.Alive || now.Before(stats.CooldownUntil) || stats.IsHealthDown() {
+			continue
+		}
+		if stats.ResponseTime <= 0 && stats.HealthStatus != config.HealthUp {
+			continue
+		}
+		result = append(result, proxy)
+	}
+	return result
+}
+
+// AcquireConn 记录一个经由该代理的连接，返回的函数用于释放（least-conn 策略使用）
+func AcquireConn(group *config.ProxyGroupConfig, proxyName string) func() {
+	group.Stats.RLock()
+	stats := group.Stats.ProxyStats[proxyName]
+	group.Stats.RUnlock()
+	if stats == nil {
+		return func() {}
+	}
+	atomic.AddInt64(&stats.ActiveConns, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt64(&stats.ActiveConns, -1) })
+	}
+}
+
+// roundRobinSelector 依次轮询
+type roundRobinSelector struct{}
+
+func (roundRobinSelector) Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, _ string) *config.ProxyConfig {
+	idx := group.Stats.RoundRobinIndex % len(candidates)
+	group.Stats.RoundRobinIndex = (idx + 1) % len(candidates)
+	return candidates[idx]
+}
+
+// leastConnSelector 选择当前连接数最少的代理，相同时轮询
+type leastConnSelector struct{}
+
+func (leastConnSelector) Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, clientIP string) *config.ProxyConfig {
+	var best []*config.ProxyConfig
+	min := int64(-1)
+	for _, proxy := range candidates {
+		conns := atomic.LoadInt64(&group.Stats.ProxyStats[proxy.Name].ActiveConns)
+		switch {
+		case min < 0 || conns < min:
+			min = conns
+			best = append(best[:0], proxy)
+		case conns == min:
+			best = append(best, proxy)
+		}
+	}
+	return roundRobinSelector{}.Select(group, best, clientIP)
+}
+
+// lowestLatencySelector 选择健康检查延迟最低的代理，未启用健康检查时使用测速延迟
+type lowestLatencySelector struct{}
+
+func (lowestLatencySelector) Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, clientIP string) *config.ProxyConfig {
+	var best *config.ProxyConfig
+	var bestRT time.Duration
+	for _, proxy := range candidates {
+		stats := group.Stats.ProxyStats[proxy.Name]
+		rt := stats.HealthRT
+		if rt <= 0 {
+			rt = stats.ResponseTime
+		}
+		if rt <= 0 {
+			continue
+		}
+		if best == nil || rt < bestRT {
+			best, bestRT = proxy, rt
+		}
+	}
+	if best == nil {
+		return roundRobinSelector{}.Select(group, candidates, clientIP)
+	}
+	return best
+}
+
+// weightedSelector 平滑加权轮询（与 nginx 一致），权重未配置时按 1 处理
+type weightedSelector struct{}
+
+func (weightedSelector) Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, _ string) *config.ProxyConfig {
+	var best *config.ProxyStats
+	var bestProxy *config.ProxyConfig
+	total := 0
+	for _, proxy := range candidates {
+		w := proxy.Weight
+		if w <= 0 {
+			w = 1
+		}
+		total += w
+		stats := group.Stats.ProxyStats[proxy.Name]
+		stats.WeightCurrent += w
+		if best == nil || stats.WeightCurrent > best.WeightCurrent {
+			best, bestProxy = stats, proxy
+		}
+	}
+	best.WeightCurrent -= total
+	return bestProxy
+}
+
+// consistentHashSelector 按客户端 IP 做一致性哈希（rendezvous hashing），
+// 同一客户端固定落在同一代理上，代理增减时只影响该代理上的客户端
+type consistentHashSelector struct{}
+
+func (consistentHashSelector) Select(group *config.ProxyGroupConfig, candidates []*config.ProxyConfig, clientIP string) *config.ProxyConfig {
+	if clientIP == "" {
+		return roundRobinSelector{}.Select(group, candidates, clientIP)
+	}
+	var best *config.ProxyConfig
+	var bestScore uint64
+	for _, proxy := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(clientIP))
+		h.Write([]byte{0})
+		h.Write([]byte(proxy.Name))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = proxy, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,84 @@
+package groupstats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// stickyEntry 客户端绑定的代理
+type stickyEntry struct {
+	proxy    string
+	expireAt time.Time
+}
+
+var (
+	stickyMu        sync.Mutex
+	stickyBindings  = make(map[string]stickyEntry)
+	stickyLastSweep time.Time
+)
+
+// 过期绑定的清理间隔
+const stickySweepInterval = time.Minute
+
+func stickyKey(group *config.ProxyGroupConfig, clientKey string) string {
+	return GroupName(group) + "|" + clientKey
+}
+
+// StickyProxy 返回客户端在该代理组中仍有效的绑定代理；代理已失效（下线、冷却、被移除）时返回 nil。
+// 命中时按 sticky_ttl 续期。调用方需持有 group.Stats 锁
+func StickyProxy(group *config.ProxyGroupConfig, clientKey string, now time.Time) *config.ProxyConfig {
+	if group.StickyTTL <= 0 || clientKey == "" {
+		return nil
+	}
+	key := stickyKey(group, clientKey)
+
+	stickyMu.Lock()
+	entry, ok := stickyBindings[key]
+	if ok && now.After(entry.expireAt) {
+		delete(stickyBindings, key)
+		ok = false
+	}
+	stickyMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	for _, proxy := range group.Proxies {
+		if proxy == nil || proxy.Name != entry.proxy {
+			continue
+		}
+		stats := group.Stats.ProxyStats[proxy.Name]
+		if proxy.Disabled || stats == nil || !stats.Alive || now.Before(stats.CooldownUntil) || stats.IsHealthDown() {
+			break
+		}
+		stickyMu.Lock()
+		stickyBindings[key] = stickyEntry{proxy: proxy.Name, expireAt: now.Add(group.StickyTTL)}
+		stickyMu.Unlock()
+		return proxy
+	}
+	return nil
+}
+
+// BindSticky 记录客户端选中的代理，sticky_ttl 内同一客户端继续使用该代理
+func BindSticky(group *config.ProxyGroupConfig, clientKey string, proxy *config.ProxyConfig) {
+	if group.StickyTTL <= 0 || clientKey == "" || proxy == nil {
+		return
+	}
+	now := time.Now()
+	key := stickyKey(group, clientKey)
+
+	stickyMu.Lock()
+	defer stickyMu.Unlock()
+	stickyBindings[key] = stickyEntry{proxy: proxy.Name, expireAt: now.Add(group.StickyTTL)}
+	if now.Sub(stickyLastSweep) < stickySweepInterval {
+		return
+	}
+	stickyLastSweep = now
+	for k, e := range stickyBindings {
+		if now.After(e.expireAt) {
+			delete(stickyBindings, k)
+		}
+	}
+}
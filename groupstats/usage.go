@@ -0,0 +1,278 @@
+package groupstats
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// GroupUsage 代理组流量统计
+type GroupUsage struct {
+	Name         string `json:"name"`
+	Day          string `json:"day"`   // 统计日，格式 2006-01-02
+	Month        string `json:"month"` // 统计月，格式 2006-01
+	DayBytes     uint64 `json:"day_bytes"`
+	MonthBytes   uint64 `json:"month_bytes"`
+	TotalBytes   uint64 `json:"total_bytes"`
+	DailyCapMB   int64  `json:"-"`
+	MonthlyCapMB int64  `json:"-"`
+	OverCap      bool   `json:"-"`
+}
+
+// groupCounter 单个代理组的计数器，pending 为尚未合并到日/月统计的字节数
+type groupCounter struct {
+	pending uint64
+
+	mu    sync.Mutex
+	usage GroupUsage
+}
+
+var (
+	usageMu       sync.RWMutex
+	usageCounters = make(map[string]*groupCounter)
+	usageDirty    int32
+	saveMu        sync.Mutex
+)
+
+// usageFile 流量统计持久化文件，与配置文件放在同一目录
+func usageFile() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "proxygroup_usage.json")
+}
+
+func getCounter(name string) *groupCounter {
+	usageMu.RLock()
+	c, ok := usageCounters[name]
+	usageMu.RUnlock()
+	if ok {
+		return c
+	}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if c, ok = usageCounters[name]; !ok {
+		c = &groupCounter{usage: GroupUsage{Name: name}}
+		usageCounters[name] = c
+	}
+	return c
+}
+
+// GroupName 根据代理组指针查找组名
+func GroupName(group *config.ProxyGroupConfig) string {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	for name, g := range config.Cfg.ProxyGroups {
+		if g == group {
+			return name
+		}
+	}
+	return ""
+}
+
+// AddGroupBytes 累计经由代理组传输的字节数
+func AddGroupBytes(groupName string, n int) {
+	if groupName == "" || n <= 0 {
+		return
+	}
+	atomic.AddUint64(&getCounter(groupName).pending, uint64(n))
+	atomic.StoreInt32(&usageDirty, 1)
+}
+
+// flush 将 pending 合并到日/月统计，跨日或跨月时重置对应计数
+func (c *groupCounter) flush(now time.Time) GroupUsage {
+	n := atomic.SwapUint64(&c.pending, 0)
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.usage.Day != day {
+		c.usage.Day = day
+		c.usage.DayBytes = 0
+	}
+	if c.usage.Month != month {
+		c.usage.Month = month
+		c.usage.MonthBytes = 0
+	}
+	c.usage.DayBytes += n
+	c.usage.MonthBytes += n
+	c.usage.TotalBytes += n
+	return c.usage
+}
+
+// isOverCap 判断统计值是否超过代理组的日/月流量上限
+func isOverCap(u GroupUsage, group *config.ProxyGroupConfig) bool {
+	if group.DailyCapMB > 0 && u.DayBytes >= uint64(group.DailyCapMB)<<20 {
+		return true
+	}
+	if group.MonthlyCapMB > 0 && u.MonthBytes >= uint64(group.MonthlyCapMB)<<20 {
+		return true
+	}
+	return false
+}
+
+// GroupOverCap 代理组是否已用完流量额度，超出后该组在本日/本月内被跳过
+func GroupOverCap(group *config.ProxyGroupConfig) bool {
+	if group.DailyCapMB <= 0 && group.MonthlyCapMB <= 0 {
+		return false
+	}
+	name := GroupName(group)
+	if name == "" {
+		return false
+	}
+	return isOverCap(getCounter(name).flush(time.Now()), group)
+}
+
+// GetGroupUsage 返回所有代理组的流量统计，按组名排序
+func GetGroupUsage() []GroupUsage {
+	config.CfgMu.RLock()
+	groups := make(map[string]*config.ProxyGroupConfig, len(config.Cfg.ProxyGroups))
+	for name, g := range config.Cfg.ProxyGroups {
+		groups[name] = g
+	}
+	config.CfgMu.RUnlock()
+
+	now := time.Now()
+	result := make([]GroupUsage, 0, len(groups))
+	for name, g := range groups {
+		if g == nil {
+			continue
+		}
+		u := getCounter(name).flush(now)
+		u.DailyCapMB = g.DailyCapMB
+		u.MonthlyCapMB = g.MonthlyCapMB
+		u.OverCap = isOverCap(u, g)
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// LoadGroupUsage 启动时从文件恢复流量统计
+func LoadGroupUsage() {
+	data, err := os.ReadFile(usageFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取代理组流量统计失败: %v", err)
+		}
+		return
+	}
+	var saved []GroupUsage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.LogPrintf("⚠️ 解析代理组流量统计失败: %v", err)
+		return
+	}
+	for _, u := range saved {
+		c := getCounter(u.Name)
+		c.mu.Lock()
+		c.usage = u
+		c.mu.Unlock()
+	}
+	logger.LogPrintf("✅ 已恢复 %d 个代理组的流量统计", len(saved))
+}
+
+// SaveGroupUsage 将流量统计写入文件（先写临时文件再重命名）
+func SaveGroupUsage() {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	now := time.Now()
+	usageMu.RLock()
+	counters := make([]*groupCounter, 0, len(usageCounters))
+	for _, c := range usageCounters {
+		counters = append(counters, c)
+	}
+	usageMu.RUnlock()
+	if len(counters) == 0 {
+		return
+	}
+
+	saved := make([]GroupUsage, 0, len(counters))
+	for _, c := range counters {
+		saved = append(saved, c.flush(now))
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Name < saved[j].Name })
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	file := usageFile()
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.LogPrintf("❌ 保存代理组流量统计失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		logger.LogPrintf("❌ 保存代理组流量统计失败: %v", err)
+	}
+}
+
+// StartGroupUsageSaver 定期持久化流量统计（退出时由 SaveGroupUsage 同步保存）
+func StartGroupUsageSaver(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.SwapInt32(&usageDirty, 0) == 1 {
+				SaveGroupUsage()
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// countingConn 统计经由代理连接收发的字节数
+type countingConn struct {
+	net.Conn
+	group string
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	AddGroupBytes(c.group, n)
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	AddGroupBytes(c.group, n)
+	return n, err
+}
+
+// CountConn 包装代理连接，收发字节计入代理组流量
+func CountConn(group *config.ProxyGroupConfig, conn net.Conn) net.Conn {
+	if conn == nil {
+		return nil
+	}
+	return &countingConn{Conn: conn, group: GroupName(group)}
+}
+
+// countingBody 统计经由代理下载的响应体字节数
+type countingBody struct {
+	io.ReadCloser
+	group string
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	AddGroupBytes(b.group, n)
+	return n, err
+}
+
+// CountBody 包装代理响应体，读取的字节计入代理组流量
+func CountBody(group *config.ProxyGroupConfig, body io.ReadCloser) io.ReadCloser {
+	return &countingBody{ReadCloser: body, group: GroupName(group)}
+}
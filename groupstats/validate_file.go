@@ -21,6 +21,14 @@ func ValidateConfig(groups map[string]*config.ProxyGroupConfig) error {
 				return fmt.Errorf("代理组 %s 的第 %d 个代理名称为空", groupName, i)
 			}
 		}
+		for i, hop := range group.Fallback {
+			if hop.Group == config.FallbackDirect {
+				continue
+			}
+			if _, ok := groups[hop.Group]; !ok {
+				return fmt.Errorf("代理组 %s 的第 %d 个 fallback 引用了不存在的代理组 %q", groupName, i, hop.Group)
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/cluster"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// ClusterRelayHandler 边缘节点经源站拉取频道，所有源站都不可用且允许本地回源时返回 false，由调用方继续处理
+func ClusterRelayHandler(w http.ResponseWriter, r *http.Request) bool {
+	clientIP := monitor.GetClientIP(r)
+	connID := clientIP + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// 全局 token 验证，本地不认识的 token 由源站校验
+	var token string
+	if auth.GetGlobalTokenManager() != nil {
+		tokenParam := "my_token"
+		if auth.GetGlobalTokenManager().TokenParamName != "" {
+			tokenParam = auth.GetGlobalTokenManager().TokenParamName
+		}
+		token = r.URL.Query().Get(tokenParam)
+		if !auth.GetGlobalTokenManager().ValidateSignedURL(r) && !auth.GetGlobalTokenManager().ValidateToken(r, token, connID, clientIP) {
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
+			return true
+		}
+		auth.GetGlobalTokenManager().KeepAlive(token, connID, clientIP, r.URL.Path)
+	}
+
+	// 并发连接数限制，已满时已返回 503
+	release := connlimit.Acquire(w, r)
+	if release == nil {
+		return true
+	}
+	defer release()
+
+	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
+		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
+		URL:            r.URL.Path,
+		UserAgent:      r.UserAgent(),
+		ConnectionType: "CLUSTER",
+		ConnectedAt:    time.Now(),
+		LastActive:     time.Now(),
+		Token:          token,
+	})
+	defer monitor.ActiveClients.Unregister(connID, "CLUSTER")
+
+	// 会话被踢出时结束推流
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+	monitor.ActiveClients.SetCancel(connID, cancel)
+	cw := monitor.ActiveClients.CountBytes(connID, w)
+
+	updateActive := func() {
+		monitor.ActiveClients.UpdateLastActive(connID, time.Now())
+	}
+	err := cluster.Default.Serve(cw, r, updateActive)
+	switch {
+	case err == nil:
+	case errors.Is(err, cluster.ErrUnavailable) && cluster.Default.Fallback():
+		logger.LogPrintf("⚠️ %v，本地回源: %s", err, r.URL.Path)
+		return false
+	default:
+		httperror.Error(w, r, "集群回源失败: "+err.Error(), http.StatusBadGateway)
+	}
+	return true
+}
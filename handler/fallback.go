@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/groupstats"
+	"github.com/qist/tvgate/lb"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/proxy"
+	"github.com/qist/tvgate/stream"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// 降级链默认参数
+const (
+	defaultHopRetries = 1
+	defaultHopTimeout = 10 * time.Second
+)
+
+// upstreamHop 解析后的降级链节点，group 为 nil 表示直连
+type upstreamHop struct {
+	name       string
+	group      *config.ProxyGroupConfig
+	retries    int
+	timeout    time.Duration
+	retryDelay time.Duration
+}
+
+// buildUpstreamChain 将代理组的 fallback 配置解析为回源路径，引用不存在的代理组会被跳过
+func buildUpstreamChain(pg *config.ProxyGroupConfig) []upstreamHop {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+
+	chain := make([]upstreamHop, 0, len(pg.Fallback))
+	for _, hop := range pg.Fallback {
+		h := upstreamHop{
+			name:       hop.Group,
+			retries:    hop.Retries,
+			timeout:    hop.Timeout,
+			retryDelay: hop.RetryDelay,
+		}
+		if h.retries <= 0 {
+			h.retries = defaultHopRetries
+		}
+		if h.timeout <= 0 {
+			h.timeout = defaultHopTimeout
+		}
+		if hop.Group != config.FallbackDirect {
+			g, ok := config.Cfg.ProxyGroups[hop.Group]
+			if !ok || g == nil {
+				logger.LogPrintf("⚠️ fallback 引用的代理组 %s 不存在，已跳过", hop.Group)
+				continue
+			}
+			h.group = g
+		}
+		chain = append(chain, h)
+	}
+	return chain
+}
+
+// serveWithFallback 按降级链依次回源，每一跳按配置重试，全部失败后返回 502
+func serveWithFallback(ctx context.Context, w http.ResponseWriter, r *http.Request, client *http.Client,
+	pg *config.ProxyGroupConfig, targetURL, connID string, bodyBytes []byte) {
+
+	chain := buildUpstreamChain(pg)
+	var lastErr error
+
+	for hopIdx, hop := range chain {
+		for attempt := 0; attempt < hop.retries; attempt++ {
+			if ctx.Err() != nil {
+				return
+			}
+			if attempt > 0 && hop.retryDelay > 0 {
+				time.Sleep(hop.retryDelay)
+			}
+
+			resp, attemptCtx, release, err := doHopRequest(ctx, r, client, hop, targetURL, bodyBytes, attempt)
+			if err != nil {
+				lastErr = err
+				release()
+				logger.LogPrintf("⚠️ 回源 [%d/%d] %s 第 %d 次尝试失败: %v", hopIdx+1, len(chain), hop.name, attempt+1, err)
+				continue
+			}
+
+			if hop.group != nil {
+				logger.LogPrintf("✅ 回源成功: 经由 %s", hop.name)
+			}
+			updateActive := func() {
+				monitor.ActiveClients.UpdateLastActive(connID, time.Now())
+			}
+			stream.HandleProxyResponse(attemptCtx, w, r, targetURL, resp, updateActive)
+			resp.Body.Close()
+			release()
+			return
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用的回源路径")
+	}
+	httperror.Error(w, r, "回源失败："+lastErr.Error(), http.StatusBadGateway)
+}
+
+// doHopRequest 通过一跳发起请求。超时只限制等待响应头的时间，成功后的流式传输不受影响。
+// 返回的 release 用于释放本次请求占用的资源（上下文、连接计数），调用方必须调用
+func doHopRequest(ctx context.Context, r *http.Request, client *http.Client, hop upstreamHop,
+	targetURL string, bodyBytes []byte, attempt int) (*http.Response, context.Context, func(), error) {
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	releaseConn := func() {}
+	release := func() {
+		releaseConn()
+		cancel()
+	}
+
+	httpClient := client
+	var selected *config.ProxyConfig
+	if hop.group != nil {
+		selected = selectProxyWithTimeout(attemptCtx, hop.group, targetURL, lb.ClientKey(hop.group, r), attempt > 0)
+		if selected == nil {
+			return nil, attemptCtx, release, fmt.Errorf("代理组 %s 无可用代理", hop.name)
+		}
+		releaseConn = groupstats.AcquireConn(hop.group, selected.Name)
+
+		pc, err := proxy.CreateGroupProxyClient(attemptCtx, &config.Cfg, hop.group, *selected)
+		if err != nil {
+			markProxyResult(hop.group, selected, false)
+			return nil, attemptCtx, release, err
+		}
+		httpClient = pc
+	}
+
+	var body io.Reader
+	if len(bodyBytes) > 0 {
+		body = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequestWithContext(attemptCtx, r.Method, targetURL, body)
+	if err != nil {
+		return nil, attemptCtx, release, err
+	}
+	stream.CopyHeadersExceptSensitive(req.Header, r.Header, r.ProtoMajor)
+	via := "direct"
+	if selected != nil {
+		via = selected.Name
+	}
+	req, span := startUpstreamSpan(req, via, attempt)
+	span.SetAttr("upstream.hop", hop.name)
+
+	timer := time.AfterFunc(hop.timeout, cancel)
+	resp, err := httpClient.Do(req)
+	if !timer.Stop() {
+		// 超时已触发，请求上下文已取消
+		if err == nil {
+			resp.Body.Close()
+		}
+		resp, err = nil, fmt.Errorf("等待响应超时 (%v)", hop.timeout)
+	}
+	endUpstreamSpan(span, resp, err)
+
+	fail := func(err error) (*http.Response, context.Context, func(), error) {
+		if selected != nil {
+			markProxyResult(hop.group, selected, false)
+		}
+		return nil, attemptCtx, release, err
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+	if resp.StatusCode >= 500 {
+		resp.Body.Close()
+		return fail(fmt.Errorf("上游返回状态码 %d", resp.StatusCode))
+	}
+
+	if selected != nil {
+		markProxyResult(hop.group, selected, true)
+		resp.Body = NewTimeoutReadCloser(groupstats.CountBody(hop.group, resp.Body), 10*time.Second)
+	}
+	return resp, attemptCtx, release, nil
+}
+
+// selectProxyWithTimeout 异步选择代理，超过拨号超时返回 nil
+func selectProxyWithTimeout(ctx context.Context, group *config.ProxyGroupConfig, targetURL, clientKey string, forceTest bool) *config.ProxyConfig {
+	span := startSelectSpan(ctx, forceTest)
+	res := make(chan *config.ProxyConfig, 1)
+	go func() {
+		res <- lb.SelectProxy(group, targetURL, clientKey, forceTest)
+	}()
+	select {
+	case p := <-res:
+		endSelectSpan(span, p, false)
+		return p
+	case <-time.After(config.DefaultDialTimeout):
+		endSelectSpan(span, nil, true)
+		return nil
+	}
+}
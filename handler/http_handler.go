@@ -14,13 +14,19 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/cluster"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/lb"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
 	"github.com/qist/tvgate/proxy"
 	"github.com/qist/tvgate/rules"
 	"github.com/qist/tvgate/stream"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/utils/headers"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 // 读超时包装器，给响应体读加超时控制，避免代理响应体卡死
@@ -114,6 +120,17 @@ func Handler(client *http.Client) http.HandlerFunc {
 			drop(w)
 			return
 		}
+
+		// 全局请求/响应头改写规则
+		if headerRules := headers.GlobalRules(); len(headerRules) > 0 {
+			tokenParamName := "my_token"
+			if tm := auth.GetGlobalTokenManager(); tm != nil && tm.TokenParamName != "" {
+				tokenParamName = tm.TokenParamName
+			}
+			vars := headers.NewVars(r, monitor.GetClientIP(r), requestToken(r, tokenParamName))
+			r.Header = headers.ApplyRequest(headerRules, r.URL.Path, r.Header, vars)
+			w = headers.WrapResponse(w, headerRules, r.URL.Path, vars)
+		}
 		// if r.URL.Path == "/" {
 		// 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		// 	w.Header().Set("Server", "TVGate")
@@ -132,6 +149,10 @@ func Handler(client *http.Client) http.HandlerFunc {
 		// 	return
 		// }
 
+		// 集群边缘节点经源站拉取
+		if cluster.Default.Relays(r.URL.Path) && ClusterRelayHandler(w, r) {
+			return
+		}
 		switch {
 		case strings.HasPrefix(r.URL.Path, "/udp/"):
 			UdpRtpHandler(w, r, "/udp/")
@@ -147,7 +168,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 		targetURL := stream.GetTargetURL(r, targetPath)
 		parsedURL, err := url.Parse(targetURL)
 		if err != nil {
-			http.Error(w, "无效的目标 URL", http.StatusBadRequest)
+			httperror.Error(w, r, "无效的目标 URL", http.StatusBadRequest)
 			return
 		}
 		// 注册活跃客户端
@@ -157,6 +178,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 		hashStr := hex.EncodeToString(h[:])
 		connID := clientIP + "_" + hashStr
 		// 全局token验证
+		var token string
 		if auth.GetGlobalTokenManager() != nil {
 			tokenParamName := "my_token" // 默认参数名
 			// 如果全局配置中有自定义的token参数名，则使用自定义的
@@ -165,28 +187,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 			}
 
 			// 提取token参数，处理嵌套URL的情况
-			var token string
-			token = r.URL.Query().Get(tokenParamName)
-
-			// 如果在常规查询参数中没有找到token，检查路径中是否包含嵌套URL
-			if token == "" {
-				// 检查路径是否包含嵌套URL格式（如 /http://... 或 /https://...）
-				path := r.URL.Path
-				if strings.HasPrefix(path, "/http://") || strings.HasPrefix(path, "/https://") {
-					// 尝试解析整个路径作为URL
-					fullPath := path
-					if r.URL.RawQuery != "" {
-						fullPath = path + "?" + r.URL.RawQuery
-					}
-
-					// 解析嵌套URL
-					nestedURLStr := strings.TrimLeft(fullPath, "/")
-					nestedURL, err := url.Parse(nestedURLStr)
-					if err == nil {
-						token = nestedURL.Query().Get(tokenParamName)
-					}
-				}
-			}
+			token = requestToken(r, tokenParamName)
 
 			// // 获取客户端真实IP
 			// clientIP := monitor.GetClientIP(r)
@@ -195,9 +196,9 @@ func Handler(client *http.Client) http.HandlerFunc {
 			// connID := clientIP + "_" + r.RemoteAddr
 
 			// 验证全局token
-			if !auth.GetGlobalTokenManager().ValidateToken(token, r.URL.Path, connID) {
+			if !auth.GetGlobalTokenManager().ValidateSignedURL(r) && !auth.GetGlobalTokenManager().ValidateToken(r, token, connID, clientIP) {
 				// logger.LogPrintf("全局token验证失败: token=%s, path=%s, ip=%s", token, r.URL.Path, clientIP)
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				httperror.Error(w, r, "Forbidden", http.StatusForbidden)
 				return
 			}
 
@@ -205,7 +206,8 @@ func Handler(client *http.Client) http.HandlerFunc {
 			auth.GetGlobalTokenManager().KeepAlive(token, connID, clientIP, r.URL.Path)
 			// logger.LogPrintf("全局token验证成功: token=%s, path=%s, ip=%s", token, r.URL.Path, clientIP)
 		}
-		ctx, cancel := context.WithCancel(context.Background()) // 可加超时限制
+		// 回源不随客户端请求取消（断开由 SetCancel 处理），只沿用请求的链路追踪
+		ctx, cancel := context.WithCancel(tracing.ContextWithSpan(context.Background(), tracing.SpanFromContext(r.Context()))) // 可加超时限制
 		defer cancel()
 
 		// 安全读取请求体（非 GET/HEAD 且有 Body）
@@ -214,7 +216,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 			var err error
 			bodyBytes, err = io.ReadAll(r.Body)
 			if err != nil {
-				http.Error(w, "读取请求体失败", http.StatusInternalServerError)
+				httperror.Error(w, r, "读取请求体失败", http.StatusInternalServerError)
 				return
 			}
 		}
@@ -246,15 +248,38 @@ func Handler(client *http.Client) http.HandlerFunc {
 			}
 		}
 
+		// 短时间内重复请求的 m3u8 直接返回已改写的缓存
+		if stream.ServeCachedPlaylist(w, r, targetURL) {
+			return
+		}
+
+		// 分片缓存：命中直接返回，同一分片并发请求只回源一次
+		served, releaseSegment := stream.AcquireSegment(w, r, targetURL)
+		if served {
+			return
+		}
+		defer releaseSegment()
+
+		// 并发连接数限制，已满时已返回 503
+		release := connlimit.Acquire(w, r)
+		if release == nil {
+			return
+		}
+		defer release()
+
 		monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
 			IP:             clientIP,
+			TrustedIP:      monitor.TrustedClientIP(r),
 			URL:            targetURL,
 			UserAgent:      r.UserAgent(),
 			ConnectionType: strings.ToUpper(parsedURL.Scheme),
 			ConnectedAt:    time.Now(),
 			LastActive:     time.Now(),
+			Token:          token,
 		})
 		defer monitor.ActiveClients.Unregister(connID, strings.ToUpper(parsedURL.Scheme))
+		monitor.ActiveClients.SetCancel(connID, cancel)
+		w = monitor.ActiveClients.CountBytes(connID, w)
 
 		// 构造直连请求
 		var originBody io.ReadCloser
@@ -263,7 +288,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 		}
 		originReq, err := http.NewRequest(r.Method, targetURL, originBody)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		originReq = originReq.WithContext(ctx)
@@ -277,6 +302,12 @@ func Handler(client *http.Client) http.HandlerFunc {
 		}
 
 		if pg := rules.ChooseProxyGroup(hostname, originalHost); pg != nil {
+			// 配置了降级链时按链路依次回源
+			if len(pg.Fallback) > 0 {
+				serveWithFallback(ctx, w, r, client, pg, targetURL, connID, bodyBytes)
+				return
+			}
+
 			maxRetries := pg.MaxRetries
 			if maxRetries <= 0 {
 				maxRetries = 1
@@ -284,13 +315,18 @@ func Handler(client *http.Client) http.HandlerFunc {
 			retryDelay := pg.RetryDelay
 			readTimeout := 10 * time.Second // 响应体读超时
 
+			// 仅保留当前尝试所用代理的连接计数
+			releaseConn := func() {}
+			defer func() { releaseConn() }()
+
 			for attempt := 0; attempt <= maxRetries; attempt++ {
 				forceTest := attempt > 0
 
 				// 异步选择代理
+				selectSpan := startSelectSpan(ctx, forceTest)
 				proxyRes := make(chan *config.ProxyConfig, 1)
 				go func() {
-					proxyRes <- lb.SelectProxy(pg, targetURL, forceTest)
+					proxyRes <- lb.SelectProxy(pg, targetURL, lb.ClientKey(pg, r), forceTest)
 				}()
 
 				var selectedProxy *config.ProxyConfig
@@ -301,9 +337,11 @@ func Handler(client *http.Client) http.HandlerFunc {
 					} else {
 						logger.LogPrintf("异步选择代理返回 nil（第 %d 次尝试）", attempt+1)
 					}
+					endSelectSpan(selectSpan, selectedProxy, false)
 				case <-time.After(config.DefaultDialTimeout):
 					logger.LogPrintf("异步选择代理未完成，继续直连或下一次尝试（第 %d 次）", attempt+1)
 					selectedProxy = nil
+					endSelectSpan(selectSpan, nil, true)
 				}
 
 				if selectedProxy == nil {
@@ -316,7 +354,10 @@ func Handler(client *http.Client) http.HandlerFunc {
 					continue
 				}
 
-				proxyClient, err := proxy.CreateProxyClient(ctx, &config.Cfg, *selectedProxy, pg.IPv6)
+				releaseConn()
+				releaseConn = groupstats.AcquireConn(pg, selectedProxy.Name)
+
+				proxyClient, err := proxy.CreateGroupProxyClient(ctx, &config.Cfg, pg, *selectedProxy)
 				if err != nil {
 					markProxyResult(pg, selectedProxy, false)
 					continue
@@ -334,14 +375,16 @@ func Handler(client *http.Client) http.HandlerFunc {
 				}
 				reqCopy = reqCopy.WithContext(ctx)
 				stream.CopyHeadersExceptSensitive(reqCopy.Header, r.Header, r.ProtoMajor)
+				reqCopy, upstreamSpan := startUpstreamSpan(reqCopy, selectedProxy.Name, attempt)
 
 				// 发起代理请求
 				proxyResp, err := proxyClient.Do(reqCopy)
+				endUpstreamSpan(upstreamSpan, proxyResp, err)
 				if err != nil {
 					logger.LogPrintf("⚠️ 代理请求网络错误（第 %d 次）：%v", attempt+1, err)
 					markProxyResult(pg, selectedProxy, false)
 					if attempt == maxRetries {
-						http.Error(w, "代理请求失败："+err.Error(), http.StatusBadGateway)
+						httperror.Error(w, r, "代理请求失败："+err.Error(), http.StatusBadGateway)
 						return
 					}
 					time.Sleep(retryDelay)
@@ -352,7 +395,7 @@ func Handler(client *http.Client) http.HandlerFunc {
 					logger.LogPrintf("⚠️ 代理请求无响应（第 %d 次）", attempt+1)
 					markProxyResult(pg, selectedProxy, false)
 					if attempt == maxRetries {
-						http.Error(w, "代理无响应", http.StatusBadGateway)
+						httperror.Error(w, r, "代理无响应", http.StatusBadGateway)
 						return
 					}
 					time.Sleep(retryDelay)
@@ -363,14 +406,14 @@ func Handler(client *http.Client) http.HandlerFunc {
 				// 	ReadCloser: proxyResp.Body,
 				// 	timeout:    readTimeout,
 				// }
-				proxyResp.Body = NewTimeoutReadCloser(proxyResp.Body, readTimeout)
+				proxyResp.Body = NewTimeoutReadCloser(groupstats.CountBody(pg, proxyResp.Body), readTimeout)
 
 				if proxyResp.StatusCode >= 500 {
 					logger.LogPrintf("⚠️ 代理服务器错误状态码 %d（第 %d 次）", proxyResp.StatusCode, attempt+1)
 					proxyResp.Body.Close()
 					markProxyResult(pg, selectedProxy, false)
 					if attempt == maxRetries {
-						http.Error(w, fmt.Sprintf("代理服务器错误状态码: %d", proxyResp.StatusCode), http.StatusBadGateway)
+						httperror.Error(w, r, fmt.Sprintf("代理服务器错误状态码: %d", proxyResp.StatusCode), http.StatusBadGateway)
 						return
 					}
 					time.Sleep(retryDelay)
@@ -418,18 +461,20 @@ func Handler(client *http.Client) http.HandlerFunc {
 			}
 		}
 		// fallback: 直连请求
+		originReq, upstreamSpan := startUpstreamSpan(originReq, "direct", 0)
 		clientResp, err := client.Do(originReq)
+		endUpstreamSpan(upstreamSpan, clientResp, err)
 		if err != nil {
-			http.Error(w, "直连请求失败："+err.Error(), http.StatusBadGateway)
+			httperror.Error(w, r, "直连请求失败："+err.Error(), http.StatusBadGateway)
 			return
 		}
 		if clientResp == nil {
-			http.Error(w, "直连无响应", http.StatusBadGateway)
+			httperror.Error(w, r, "直连无响应", http.StatusBadGateway)
 			return
 		}
 		defer clientResp.Body.Close()
 		if clientResp.StatusCode >= 500 {
-			http.Error(w, fmt.Sprintf("服务器返回错误状态码: %d", clientResp.StatusCode), http.StatusBadGateway)
+			httperror.Error(w, r, fmt.Sprintf("服务器返回错误状态码: %d", clientResp.StatusCode), http.StatusBadGateway)
 			return
 		}
 		// 定义更新活跃时间的回调
@@ -469,6 +514,30 @@ func Handler(client *http.Client) http.HandlerFunc {
 	}
 }
 
+// requestToken 提取 token 参数，查询参数中没有时尝试嵌套 URL（如 /http://...?token=）
+func requestToken(r *http.Request, tokenParamName string) string {
+	token := r.URL.Query().Get(tokenParamName)
+	if token != "" {
+		return token
+	}
+	// 检查路径是否包含嵌套URL格式（如 /http://... 或 /https://...）
+	path := r.URL.Path
+	if strings.HasPrefix(path, "/http://") || strings.HasPrefix(path, "/https://") {
+		// 尝试解析整个路径作为URL
+		fullPath := path
+		if r.URL.RawQuery != "" {
+			fullPath = path + "?" + r.URL.RawQuery
+		}
+
+		// 解析嵌套URL
+		nestedURL, err := url.Parse(strings.TrimLeft(fullPath, "/"))
+		if err == nil {
+			token = nestedURL.Query().Get(tokenParamName)
+		}
+	}
+	return token
+}
+
 func markProxyResult(group *config.ProxyGroupConfig, proxy *config.ProxyConfig, alive bool) {
 	group.Stats.Lock()
 	defer group.Stats.Unlock()
@@ -17,12 +17,15 @@ import (
 
 	"github.com/qist/tvgate/auth"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/lb"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
 	"github.com/qist/tvgate/proxy"
 	"github.com/qist/tvgate/rules"
 	"github.com/qist/tvgate/stream"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
@@ -30,22 +33,26 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 	connID := clientIP + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
 
 	// 全局 token 验证
+	var token string
 	if auth.GetGlobalTokenManager() != nil {
 		tokenParam := "my_token"
 		if auth.GetGlobalTokenManager().TokenParamName != "" {
 			tokenParam = auth.GetGlobalTokenManager().TokenParamName
 		}
-		token := r.URL.Query().Get(tokenParam)
-		if !auth.GetGlobalTokenManager().ValidateToken(token, r.URL.Path, connID) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+		token = r.URL.Query().Get(tokenParam)
+		if !auth.GetGlobalTokenManager().ValidateSignedURL(r) && !auth.GetGlobalTokenManager().ValidateToken(r, token, connID, clientIP) {
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
 			return
 		}
 		auth.GetGlobalTokenManager().KeepAlive(token, connID, clientIP, r.URL.Path)
 	}
 
+	// 去掉集群签名参数，避免源站上同一频道因签名不同重复拉流
+	r.URL.RawQuery = auth.StripClusterParams(r.URL.RawQuery)
+
 	path := strings.TrimPrefix(r.URL.Path, "/rtsp/")
 	if path == "" {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httperror.Error(w, r, "Invalid path", http.StatusBadRequest)
 		return
 	}
 
@@ -85,20 +92,36 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 	logger.LogPrintf("RTSP → HTTP request: %s", rtspURL)
 	parsedURL, err := url.Parse(rtspURL)
 	if err != nil {
-		http.Error(w, "URL parse error: "+err.Error(), 500)
+		httperror.Error(w, r, "URL parse error: "+err.Error(), 500)
 		return
 	}
 
+	// 并发连接数限制，已满时已返回 503
+	release := connlimit.Acquire(w, r)
+	if release == nil {
+		return
+	}
+	defer release()
+
 	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
 		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
 		URL:            rtspURL,
 		UserAgent:      r.UserAgent(),
 		ConnectionType: "RTSP",
 		ConnectedAt:    time.Now(),
 		LastActive:     time.Now(),
+		Token:          token,
 	})
 	defer monitor.ActiveClients.Unregister(connID, "RTSP")
 
+	// 会话被踢出时结束推流
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+	monitor.ActiveClients.SetCancel(connID, cancel)
+	w = monitor.ActiveClients.CountBytes(connID, w)
+
 	client := &gortsplib.Client{
 		Scheme: parsedURL.Scheme,
 		Host:   parsedURL.Host,
@@ -119,16 +142,18 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 	if pg != nil {
 		selectedProxyChan := make(chan *config.ProxyConfig, 1)
 		go func() {
-			selectedProxyChan <- lb.SelectProxy(pg, rtspURL, false)
+			selectedProxyChan <- lb.SelectProxy(pg, rtspURL, lb.ClientKey(pg, r), false)
 		}()
 
 		select {
 		case selectedProxy := <-selectedProxyChan:
 			if selectedProxy != nil {
+				defer groupstats.AcquireConn(pg, selectedProxy.Name)()
 				proxyDialer, err := proxy.CreateProxyDialer(*selectedProxy)
 				if err == nil {
 					client.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-						return proxyDialer.DialContext(ctx, network, addr)
+						conn, err := proxyDialer.DialContext(ctx, network, addr)
+						return groupstats.CountConn(pg, conn), err
 					}
 					logger.LogPrintf("RTSP 通过代理 %s://%s:%d", selectedProxy.Type, selectedProxy.Server, selectedProxy.Port)
 				}
@@ -169,7 +194,7 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		err = client.Start()
 		if err != nil {
-			http.Error(w, "RTSP connect error: "+err.Error(), 500)
+			httperror.Error(w, r, "RTSP connect error: "+err.Error(), 500)
 			return
 		}
 
@@ -183,13 +208,13 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 		parsedURL, err := base.ParseURL(rtspURL)
 		_, err = client.Options(parsedURL)
 		if err != nil {
-			http.Error(w, "RTSP OPTIONS error: "+err.Error(), 500)
+			httperror.Error(w, r, "RTSP OPTIONS error: "+err.Error(), 500)
 			return
 		}
 
 		desc, _, err := client.Describe(parsedURL)
 		if err != nil {
-			http.Error(w, "RTSP DESCRIBE error: "+err.Error(), 500)
+			httperror.Error(w, r, "RTSP DESCRIBE error: "+err.Error(), 500)
 			return
 		}
 		for _, m := range desc.Medias {
@@ -224,7 +249,7 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if videoMedia == nil || (videoFormat == nil && mpegtsFormat == nil) {
-			http.Error(w, "No supported video stream found", 500)
+			httperror.Error(w, r, "No supported video stream found", 500)
 			return
 		}
 
@@ -239,24 +264,23 @@ func RtspToHTTPHandler(w http.ResponseWriter, r *http.Request) {
 		hub.SetRtspClient(client)
 	}
 
-	ctx := r.Context()
 	updateActive := func() {
 		monitor.ActiveClients.UpdateLastActive(connID, time.Now())
 	}
 
 	if mpegtsFormat != nil && videoMedia != nil {
 		if err := stream.HandleMpegtsStream(ctx, w, client, videoMedia, mpegtsFormat, r, rtspURL, hub, updateActive); err != nil {
-			http.Error(w, "Stream error: "+err.Error(), 500)
+			httperror.Error(w, r, "Stream error: "+err.Error(), 500)
 		}
 		return
 	}
 
 	if videoFormat != nil && videoMedia != nil {
 		if err := stream.HandleH264AacStream(ctx, w, client, videoMedia, videoFormat, audioMedia, audioFormat, r, rtspURL, hub, updateActive); err != nil {
-			http.Error(w, "Stream error: "+err.Error(), 500)
+			httperror.Error(w, r, "Stream error: "+err.Error(), 500)
 		}
 		return
 	}
 
-	http.Error(w, "No supported stream format found", 500)
+	httperror.Error(w, r, "No supported stream format found", 500)
 }
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/tracing"
+)
+
+// startSelectSpan 开始记录选择代理耗费的时间，forceTest 为重试时强制重新测速
+func startSelectSpan(ctx context.Context, forceTest bool) *tracing.Span {
+	_, span := tracing.Start(ctx, "proxy.select", tracing.KindInternal)
+	span.SetAttr("proxy.force_test", forceTest)
+	return span
+}
+
+func endSelectSpan(span *tracing.Span, selected *config.ProxyConfig, timeout bool) {
+	switch {
+	case timeout:
+		span.SetError(fmt.Errorf("选择代理超时 (%v)", config.DefaultDialTimeout))
+	case selected == nil:
+		span.SetError(fmt.Errorf("无可用代理"))
+	default:
+		span.SetAttr("proxy.name", selected.Name)
+	}
+	span.End()
+}
+
+// startUpstreamSpan 为一次回源请求开始 client span，via 为代理名称，直连时为 direct；
+// 返回带 span 的请求，配置了 propagate 时附加 traceparent
+func startUpstreamSpan(req *http.Request, via string, attempt int) (*http.Request, *tracing.Span) {
+	ctx, span := tracing.Start(req.Context(), "upstream "+req.Method, tracing.KindClient)
+	if span == nil {
+		return req, nil
+	}
+	span.SetAttr("server.address", req.URL.Host)
+	span.SetAttr("proxy.name", via)
+	span.SetAttr("http.request.resend_count", attempt)
+	tracing.Inject(ctx, req.Header)
+	return req.WithContext(ctx), span
+}
+
+// endUpstreamSpan 在收到响应头或请求失败时结束 client span，之后的流式传输不计入
+func endUpstreamSpan(span *tracing.Span, resp *http.Response, err error) {
+	if err != nil {
+		span.SetError(err)
+	} else if resp != nil {
+		span.SetHTTPStatus(resp.StatusCode)
+	}
+	span.End()
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"context"
 	"github.com/qist/tvgate/auth"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
 	"github.com/qist/tvgate/logger"
 	"github.com/qist/tvgate/monitor"
 	"github.com/qist/tvgate/stream"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/utils/httperror"
 	"net"
 	"net/http"
 	"strconv"
@@ -17,15 +21,16 @@ func UdpRtpHandler(w http.ResponseWriter, r *http.Request, prefix string) {
 	clientIP := monitor.GetClientIP(r)
 	connID := clientIP + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
 	// 全局 token 验证
+	var token string
 	if auth.GetGlobalTokenManager() != nil {
 		tokenParam := "my_token"
 		if auth.GetGlobalTokenManager().TokenParamName != "" {
 			tokenParam = auth.GetGlobalTokenManager().TokenParamName
 		}
-		token := r.URL.Query().Get(tokenParam)
+		token = r.URL.Query().Get(tokenParam)
 
-		if !auth.GetGlobalTokenManager().ValidateToken(token, r.URL.Path, connID) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+		if !auth.GetGlobalTokenManager().ValidateSignedURL(r) && !auth.GetGlobalTokenManager().ValidateToken(r, token, connID, clientIP) {
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
 			return
 		}
 
@@ -35,7 +40,7 @@ func UdpRtpHandler(w http.ResponseWriter, r *http.Request, prefix string) {
 	// 解析 UDP 地址
 	addr := r.URL.Path[len(prefix):]
 	if addr == "" || !strings.Contains(addr, ":") {
-		http.Error(w, "Address must be ip:port", http.StatusBadRequest)
+		httperror.Error(w, r, "Address must be ip:port", http.StatusBadRequest)
 		return
 	}
 
@@ -55,10 +60,15 @@ func UdpRtpHandler(w http.ResponseWriter, r *http.Request, prefix string) {
 	}
 
 	// 使用 MultiChannelHub 获取或创建 Hub
+	_, joinSpan := tracing.Start(r.Context(), "hub.join", tracing.KindInternal)
+	joinSpan.SetAttr("multicast.address", addr)
+	joinSpan.SetAttr("multicast.ifaces", strings.Join(ifaces, ","))
 	hub, err := stream.GlobalMultiChannelHub.GetOrCreateHub(addr, ifaces)
+	joinSpan.SetError(err)
+	joinSpan.End()
 	if err != nil {
 
-		http.Error(w, "Failed to listen UDP: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to listen UDP: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -90,16 +100,33 @@ func UdpRtpHandler(w http.ResponseWriter, r *http.Request, prefix string) {
 	if strings.HasPrefix(prefix, "/rtp/") {
 		connectionType = "RTP"
 	}
+	// 并发连接数限制，已满时已返回 503
+	release := connlimit.Acquire(w, r)
+	if release == nil {
+		return
+	}
+	defer release()
+
 	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
 		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
 		URL:            addr,
 		UserAgent:      r.UserAgent(),
 		ConnectionType: connectionType,
 		ConnectedAt:    time.Now(),
 		LastActive:     time.Now(),
+		Token:          token,
 	})
 	defer monitor.ActiveClients.Unregister(connID, connectionType)
 
+	// 会话被踢出时结束推流，Hub 通过 RemoveCh 移除同一 connID 的订阅
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.Header.Set("X-ConnID", connID)
+	monitor.ActiveClients.SetCancel(connID, cancel)
+	w = monitor.ActiveClients.CountBytes(connID, w)
+
 	updateActive := func() {
 		monitor.ActiveClients.UpdateLastActive(connID, time.Now())
 	}
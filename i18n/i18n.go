@@ -0,0 +1,171 @@
+// Package i18n 界面文字翻译。源文字为中文，语言包以中文原文为键，未翻译的文字保持原文
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qist/tvgate/config"
+)
+
+// 支持的语言
+const (
+	Chinese = "zh"
+	English = "en"
+
+	// Default 源文字的语言，也是无法协商时的默认语言
+	Default = Chinese
+
+	// CookieName 用户在页面上切换的语言
+	CookieName = "tvgate_lang"
+)
+
+//go:embed locales/*.json locales/*.js
+var localesFS embed.FS
+
+var (
+	loadOnce sync.Once
+	packs    map[string]map[string]string
+	keys     map[string][]string // 按长度从长到短排序的键，用于替换混合文字中的片段
+)
+
+func load() {
+	packs = map[string]map[string]string{Chinese: {}}
+	keys = map[string][]string{Chinese: nil}
+	files, _ := localesFS.ReadDir("locales")
+	for _, f := range files {
+		name := f.Name()
+		if path.Ext(name) != ".json" {
+			continue
+		}
+		data, err := localesFS.ReadFile("locales/" + name)
+		if err != nil {
+			continue
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("i18n: 语言包 " + name + " 格式错误: " + err.Error())
+		}
+		lang := strings.TrimSuffix(name, ".json")
+		packs[lang] = messages
+		list := make([]string, 0, len(messages))
+		for k := range messages {
+			list = append(list, k)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if len(list[i]) != len(list[j]) {
+				return len(list[i]) > len(list[j])
+			}
+			return list[i] < list[j]
+		})
+		keys[lang] = list
+	}
+}
+
+// Supported 是否支持该语言
+func Supported(lang string) bool {
+	loadOnce.Do(load)
+	_, ok := packs[lang]
+	return ok
+}
+
+// Messages 返回语言包，调用方不得修改
+func Messages(lang string) map[string]string {
+	loadOnce.Do(load)
+	return packs[lang]
+}
+
+// T 翻译一条文字：先整句查找；找不到时按 "前缀: 详情" 翻译前缀，详情（通常是错误信息或参数）保持原样
+func T(lang, msg string) string {
+	loadOnce.Do(load)
+	pack := packs[lang]
+	if len(pack) == 0 || msg == "" {
+		return msg
+	}
+	if s, ok := pack[msg]; ok {
+		return s
+	}
+	for _, sep := range []string{": ", "："} {
+		if i := strings.Index(msg, sep); i > 0 {
+			if s, ok := pack[msg[:i]]; ok {
+				return s + ": " + msg[i+len(sep):]
+			}
+		}
+	}
+	return msg
+}
+
+// Negotiate 选择请求使用的语言，优先级：参数 lang > Cookie tvgate_lang > 配置 language > Accept-Language > 中文
+func Negotiate(r *http.Request) string {
+	if lang := Normalize(r.URL.Query().Get("lang")); Supported(lang) {
+		return lang
+	}
+	if c, err := r.Cookie(CookieName); err == nil {
+		if lang := Normalize(c.Value); Supported(lang) {
+			return lang
+		}
+	}
+	config.CfgMu.RLock()
+	configured := Normalize(config.Cfg.Language)
+	config.CfgMu.RUnlock()
+	if Supported(configured) {
+		return configured
+	}
+	return fromAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// Normalize 只保留主语言标签，如 zh-CN -> zh、en_US -> en
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// fromAcceptLanguage 按 q 值选择支持的语言，q 值相同时取靠前的
+func fromAcceptLanguage(header string) string {
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if lang := Normalize(tag); q > bestQ && Supported(lang) {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// Script 返回页面翻译脚本：包含语言包，页面加载后替换文字节点及 placeholder/title 等属性，
+// 并翻译动态插入的内容和 alert/confirm；语言为中文时只提供切换语言的方法
+func Script(lang string) []byte {
+	loadOnce.Do(load)
+	code, _ := localesFS.ReadFile("locales/i18n.js")
+	messages := packs[lang]
+	if lang == Default {
+		messages = nil
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"lang":     lang,
+		"cookie":   CookieName,
+		"messages": messages,
+		"keys":     keys[lang],
+	})
+	var sb strings.Builder
+	sb.WriteString("window.TVGATE_I18N = ")
+	sb.Write(data)
+	sb.WriteString(";\n")
+	sb.Write(code)
+	return []byte(sb.String())
+}
@@ -0,0 +1,118 @@
+// 页面翻译：源文字为中文，按 window.TVGATE_I18N 中的语言包替换文字节点与属性
+(function () {
+    var cfg = window.TVGATE_I18N || {};
+    var messages = cfg.messages || {};
+    // 单字的键（如时长单位）只整句匹配，避免替换其他词语中的同一个字
+    var keys = (cfg.keys || []).filter(function (k) { return k.length >= 2 && /[一-鿿]/.test(k); });
+    var duration = /(\d+)\s*(天|小时|分钟|分|秒)/g;
+    var enabled = Object.keys(messages).length > 0;
+    var cjk = /[一-鿿]/;
+
+    // 整句查找，找不到时替换其中已翻译的片段
+    function translate(text) {
+        if (!enabled || !text || !cjk.test(text)) return text;
+        var trimmed = text.trim();
+        if (Object.prototype.hasOwnProperty.call(messages, trimmed)) {
+            return text.replace(trimmed, messages[trimmed]);
+        }
+        var out = text.replace(duration, function (m, n, unit) {
+            return n + (messages[unit] || unit);
+        });
+        for (var i = 0; i < keys.length && cjk.test(out); i++) {
+            if (out.indexOf(keys[i]) >= 0) {
+                out = out.split(keys[i]).join(messages[keys[i]]);
+            }
+        }
+        return out;
+    }
+
+    var attrs = ['placeholder', 'title', 'alt', 'aria-label'];
+
+    function translateElement(el) {
+        for (var i = 0; i < attrs.length; i++) {
+            var v = el.getAttribute && el.getAttribute(attrs[i]);
+            if (v && cjk.test(v)) el.setAttribute(attrs[i], translate(v));
+        }
+        if ((el.tagName === 'INPUT' && (el.type === 'button' || el.type === 'submit')) && cjk.test(el.value)) {
+            el.value = translate(el.value);
+        }
+    }
+
+    function translateTree(root) {
+        if (!enabled || !root) return;
+        if (root.nodeType === 3) {
+            var t = translate(root.nodeValue);
+            if (t !== root.nodeValue) root.nodeValue = t;
+            return;
+        }
+        if (root.nodeType !== 1 || root.tagName === 'SCRIPT' || root.tagName === 'STYLE' ||
+            root.tagName === 'TEXTAREA' || root.isContentEditable) return;
+        translateElement(root);
+        var walker = document.createTreeWalker(root, NodeFilter.SHOW_ELEMENT | NodeFilter.SHOW_TEXT, {
+            acceptNode: function (n) {
+                var p = n.nodeType === 3 ? n.parentNode : n;
+                while (p && p !== root) {
+                    if (p.tagName === 'SCRIPT' || p.tagName === 'STYLE' || p.tagName === 'TEXTAREA' || p.isContentEditable) {
+                        return NodeFilter.FILTER_REJECT;
+                    }
+                    p = p.parentNode;
+                }
+                return NodeFilter.FILTER_ACCEPT;
+            }
+        });
+        var n;
+        while ((n = walker.nextNode())) {
+            if (n.nodeType === 3) {
+                var tv = translate(n.nodeValue);
+                if (tv !== n.nodeValue) n.nodeValue = tv;
+            } else {
+                translateElement(n);
+            }
+        }
+    }
+
+    // 切换语言：写入 Cookie 后刷新页面
+    window.tvgateSetLanguage = function (lang) {
+        document.cookie = cfg.cookie + '=' + encodeURIComponent(lang) + '; path=/; max-age=31536000; SameSite=Lax';
+        var url = new URL(location.href);
+        url.searchParams.delete('lang');
+        location.href = url.toString();
+    };
+    window.tvgateLanguage = cfg.lang;
+    window.t = translate;
+
+    if (!enabled) return;
+
+    document.documentElement.setAttribute('lang', cfg.lang);
+    var alert0 = window.alert, confirm0 = window.confirm, prompt0 = window.prompt;
+    window.alert = function (msg) { return alert0.call(window, translate(String(msg))); };
+    window.confirm = function (msg) { return confirm0.call(window, translate(String(msg))); };
+    window.prompt = function (msg, def) { return prompt0.call(window, translate(String(msg)), def); };
+
+    function start() {
+        document.title = translate(document.title);
+        translateTree(document.body);
+        new MutationObserver(function (records) {
+            for (var i = 0; i < records.length; i++) {
+                var r = records[i];
+                if (r.type === 'characterData') {
+                    var tv = translate(r.target.nodeValue);
+                    if (tv !== r.target.nodeValue) r.target.nodeValue = tv;
+                } else if (r.type === 'attributes') {
+                    translateElement(r.target);
+                } else {
+                    for (var j = 0; j < r.addedNodes.length; j++) translateTree(r.addedNodes[j]);
+                }
+            }
+        }).observe(document.body, {
+            childList: true, subtree: true, characterData: true,
+            attributes: true, attributeFilter: attrs
+        });
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', start);
+    } else {
+        start();
+    }
+})();
@@ -0,0 +1,183 @@
+package ingest
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// AMF0 类型标记
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0A
+	amfDate        = 0x0B
+	amfLongString  = 0x0C
+)
+
+var errAMF = errors.New("AMF0 数据错误")
+
+// amfObj AMF0 对象，按写入顺序编码
+type amfObj []amfProp
+
+type amfProp struct {
+	key   string
+	value any
+}
+
+// amfDecode 解码一条命令消息中的所有值；对象与 ECMA 数组解码为 map[string]any
+func amfDecode(b []byte) ([]any, error) {
+	var out []any
+	for len(b) > 0 {
+		v, n, err := amfValue(b)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, v)
+		b = b[n:]
+	}
+	return out, nil
+}
+
+// amfValue 解码一个值，返回值与占用的字节数
+func amfValue(b []byte) (any, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errAMF
+	}
+	switch b[0] {
+	case amfNumber:
+		if len(b) < 9 {
+			return nil, 0, errAMF
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[1:9])), 9, nil
+	case amfBoolean:
+		if len(b) < 2 {
+			return nil, 0, errAMF
+		}
+		return b[1] != 0, 2, nil
+	case amfString:
+		s, n, err := amfString16(b[1:])
+		return s, 1 + n, err
+	case amfLongString:
+		if len(b) < 5 {
+			return nil, 0, errAMF
+		}
+		l := int(binary.BigEndian.Uint32(b[1:5]))
+		if len(b) < 5+l {
+			return nil, 0, errAMF
+		}
+		return string(b[5 : 5+l]), 5 + l, nil
+	case amfObject:
+		m, n, err := amfProps(b[1:])
+		return m, 1 + n, err
+	case amfECMAArray:
+		if len(b) < 5 {
+			return nil, 0, errAMF
+		}
+		m, n, err := amfProps(b[5:])
+		return m, 5 + n, err
+	case amfStrictArray:
+		if len(b) < 5 {
+			return nil, 0, errAMF
+		}
+		count := int(binary.BigEndian.Uint32(b[1:5]))
+		pos := 5
+		var arr []any
+		for i := 0; i < count; i++ {
+			v, n, err := amfValue(b[pos:])
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, v)
+			pos += n
+		}
+		return arr, pos, nil
+	case amfDate:
+		if len(b) < 11 {
+			return nil, 0, errAMF
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[1:9])), 11, nil
+	case amfNull, amfUndefined:
+		return nil, 1, nil
+	}
+	return nil, 0, errAMF
+}
+
+func amfString16(b []byte) (string, int, error) {
+	if len(b) < 2 {
+		return "", 0, errAMF
+	}
+	l := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+l {
+		return "", 0, errAMF
+	}
+	return string(b[2 : 2+l]), 2 + l, nil
+}
+
+// amfProps 解码对象属性直到结束标记
+func amfProps(b []byte) (map[string]any, int, error) {
+	m := make(map[string]any)
+	pos := 0
+	for {
+		key, n, err := amfString16(b[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += n
+		if pos >= len(b) {
+			return nil, 0, errAMF
+		}
+		if key == "" && b[pos] == amfObjectEnd {
+			return m, pos + 1, nil
+		}
+		v, n, err := amfValue(b[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		m[key] = v
+		pos += n
+	}
+}
+
+// amfEncode 编码一组值，支持 float64、int、bool、string、amfObj 与 nil
+func amfEncode(values ...any) []byte {
+	var b []byte
+	for _, v := range values {
+		b = amfAppend(b, v)
+	}
+	return b
+}
+
+func amfAppend(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case float64:
+		b = append(b, amfNumber)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case int:
+		return amfAppend(b, float64(v))
+	case bool:
+		if v {
+			return append(b, amfBoolean, 1)
+		}
+		return append(b, amfBoolean, 0)
+	case string:
+		b = append(b, amfString)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(v)))
+		return append(b, v...)
+	case amfObj:
+		b = append(b, amfObject)
+		for _, p := range v {
+			b = binary.BigEndian.AppendUint16(b, uint16(len(p.key)))
+			b = append(b, p.key...)
+			b = amfAppend(b, p.value)
+		}
+		return append(b, 0, 0, amfObjectEnd)
+	}
+	return append(b, amfNull)
+}
@@ -0,0 +1,240 @@
+package ingest
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	flvCodecAVC = 7
+	flvSoundAAC = 10
+	// tsOffset 时间戳整体后移 1 秒，避免 PCR 为负
+	tsOffset = 90000
+	// audioOnlyFrames 收到这么多音频帧仍没有视频参数时按纯音频开始输出
+	audioOnlyFrames = 50
+)
+
+var errAVCConfig = errors.New("AVC 参数错误")
+
+// datagramWriter 把写入的 TS 包凑满 7 个发送一个 UDP 包
+type datagramWriter struct {
+	conn *net.UDPConn
+	buf  []byte
+}
+
+func (d *datagramWriter) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	sent := 0
+	for len(d.buf)-sent >= datagramSize {
+		// 发送失败（如发送缓冲区满）只丢弃这一个包，不中断推流
+		_, _ = d.conn.Write(d.buf[sent : sent+datagramSize])
+		sent += datagramSize
+	}
+	d.buf = d.buf[:copy(d.buf, d.buf[sent:])]
+	return len(p), nil
+}
+
+// flush 发出剩余的 TS 包
+func (d *datagramWriter) flush() {
+	if len(d.buf) > 0 {
+		_, _ = d.conn.Write(d.buf)
+		d.buf = d.buf[:0]
+	}
+}
+
+// flvRemuxer 把 RTMP 的 FLV 音视频标签（H264 + AAC）重封装为 TS。
+// 收到第一帧视频时按已收到的序列头建立节目，之后出现的新轨道不再加入
+type flvRemuxer struct {
+	name string
+	out  *datagramWriter
+	w    *mpegts.Writer
+
+	video      *mpegts.Track
+	audio      *mpegts.Track
+	sps, pps   []byte
+	lengthSize int
+	aac        *mpeg4audio.Config
+	waiting    int // 等待视频参数期间收到的音频帧数
+
+	warned map[string]bool // 不支持的编码只提示一次
+}
+
+func newFLVRemuxer(name string, out *datagramWriter) *flvRemuxer {
+	return &flvRemuxer{name: name, out: out, warned: make(map[string]bool)}
+}
+
+// start 按已收到的序列头建立 TS 节目
+func (r *flvRemuxer) start() error {
+	var tracks []*mpegts.Track
+	if r.sps != nil {
+		r.video = &mpegts.Track{Codec: &mpegts.CodecH264{}}
+		tracks = append(tracks, r.video)
+	}
+	if r.aac != nil {
+		r.audio = &mpegts.Track{Codec: &mpegts.CodecMPEG4Audio{Config: *r.aac}}
+		tracks = append(tracks, r.audio)
+	}
+	r.w = &mpegts.Writer{W: r.out, Tracks: tracks}
+	return r.w.Initialize()
+}
+
+// unsupported 提示不支持的编码
+func (r *flvRemuxer) unsupported(what string) {
+	if !r.warned[what] {
+		r.warned[what] = true
+		logger.LogPrintf("⚠️ 推流 %s 的%s不受支持，已忽略（仅支持 H264 与 AAC）", r.name, what)
+	}
+}
+
+// videoTag 处理一个视频标签，ts 为毫秒时间戳
+func (r *flvRemuxer) videoTag(ts uint32, b []byte) error {
+	if len(b) < 5 {
+		return nil
+	}
+	// 最高位为扩展 RTMP（HEVC、AV1 等）
+	if b[0]&0x80 != 0 || b[0]&0x0F != flvCodecAVC {
+		r.unsupported("视频编码")
+		return nil
+	}
+	keyframe := b[0]>>4 == 1
+	cts := int32(uint32(b[2])<<16|uint32(b[3])<<8|uint32(b[4])) << 8 >> 8
+	switch b[1] {
+	case 0:
+		return r.avcConfig(b[5:])
+	case 2:
+		return nil
+	}
+	if r.w == nil {
+		if r.sps == nil {
+			return nil
+		}
+		if err := r.start(); err != nil {
+			return err
+		}
+	}
+	if r.video == nil {
+		return nil
+	}
+
+	au, err := r.splitNALUs(b[5:])
+	if err != nil || len(au) == 0 {
+		return err
+	}
+	// 关键帧前补上参数集，观看者可以从任意关键帧开始解码
+	if keyframe && !hasSPS(au) {
+		au = append([][]byte{r.sps, r.pps}, au...)
+	}
+	dts := int64(ts)*90 + tsOffset
+	return r.w.WriteH264(r.video, dts+int64(cts)*90, dts, au)
+}
+
+// avcConfig 解析 AVCDecoderConfigurationRecord，取第一个 SPS 与 PPS
+func (r *flvRemuxer) avcConfig(b []byte) error {
+	if len(b) < 7 {
+		return errAVCConfig
+	}
+	r.lengthSize = int(b[4]&0x03) + 1
+	pos := 5
+	var sets [2][]byte
+	for i := range sets {
+		if pos >= len(b) {
+			return errAVCConfig
+		}
+		count := int(b[pos])
+		if i == 0 {
+			count &= 0x1F
+		}
+		pos++
+		for j := 0; j < count; j++ {
+			if pos+2 > len(b) {
+				return errAVCConfig
+			}
+			l := int(binary.BigEndian.Uint16(b[pos:]))
+			pos += 2
+			if pos+l > len(b) {
+				return errAVCConfig
+			}
+			if sets[i] == nil {
+				sets[i] = append([]byte(nil), b[pos:pos+l]...)
+			}
+			pos += l
+		}
+	}
+	if sets[0] == nil || sets[1] == nil {
+		return errAVCConfig
+	}
+	r.sps, r.pps = sets[0], sets[1]
+	return nil
+}
+
+// splitNALUs 按序列头中的长度字段大小拆分 NAL 单元
+func (r *flvRemuxer) splitNALUs(b []byte) ([][]byte, error) {
+	var au [][]byte
+	for len(b) > 0 {
+		if len(b) < r.lengthSize {
+			return nil, errAVCConfig
+		}
+		l := 0
+		for _, c := range b[:r.lengthSize] {
+			l = l<<8 | int(c)
+		}
+		b = b[r.lengthSize:]
+		if l > len(b) {
+			return nil, errAVCConfig
+		}
+		if l > 0 {
+			au = append(au, b[:l])
+		}
+		b = b[l:]
+	}
+	return au, nil
+}
+
+func hasSPS(au [][]byte) bool {
+	for _, nalu := range au {
+		if h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeSPS {
+			return true
+		}
+	}
+	return false
+}
+
+// audioTag 处理一个音频标签，ts 为毫秒时间戳
+func (r *flvRemuxer) audioTag(ts uint32, b []byte) error {
+	if len(b) < 2 {
+		return nil
+	}
+	if b[0]>>4 != flvSoundAAC {
+		r.unsupported("音频编码")
+		return nil
+	}
+	if b[1] == 0 {
+		var conf mpeg4audio.Config
+		if err := conf.Unmarshal(b[2:]); err != nil {
+			return err
+		}
+		r.aac = &conf
+		return nil
+	}
+	if r.w == nil {
+		// 先等视频参数，一直没有时按纯音频开始
+		if r.aac == nil || r.sps == nil && r.waiting < audioOnlyFrames {
+			r.waiting++
+			return nil
+		}
+		if err := r.start(); err != nil {
+			return err
+		}
+	}
+	if r.audio == nil || len(b) == 2 {
+		return nil
+	}
+	return r.w.WriteMPEG4Audio(r.audio, int64(ts)*90+tsOffset, [][]byte{b[2:]})
+}
@@ -0,0 +1,314 @@
+// Package ingest 推流接入：编码器以 HTTP POST/PUT 持续上传 TS 流，或以 RTMP 发布 H264/AAC（重封装为 TS），
+// 按 7 个 TS 包一个 UDP 包转发到配置的地址。转发到组播地址时观看者与普通组播频道一样通过 /udp/地址 播放，
+// 同一频道的观看者共用一个 Hub
+package ingest
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+const (
+	tsPacketSize = 188
+	// datagramSize 每个 UDP 包承载 7 个 TS 包，与组播源一致
+	datagramSize = 7 * tsPacketSize
+)
+
+// Manager 推流点与正在进行的推流
+type Manager struct {
+	mu      sync.Mutex
+	cfg     config.IngestConfig
+	streams map[string]config.IngestStream
+	active  map[string]bool // 正在推流的名称，同一推流点同时只允许一路
+
+	rtmp     net.Listener
+	rtmpAddr string
+}
+
+// New 创建未启用的推流接入
+func New() *Manager {
+	return &Manager{streams: make(map[string]config.IngestStream), active: make(map[string]bool)}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.IngestConfig) { Default.Configure(cfg) }
+
+// Configure 应用配置，正在进行的推流继续使用原配置直到断开
+func (m *Manager) Configure(cfg config.IngestConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reflect.DeepEqual(m.cfg, cfg) {
+		return
+	}
+	m.cfg = cfg
+	m.streams = make(map[string]config.IngestStream, len(cfg.Streams))
+	for _, st := range cfg.Streams {
+		m.streams[st.Name] = st
+	}
+	if cfg.Enabled {
+		logger.LogPrintf("✅ HTTP 推流接入已启用，路径 %s，推流点 %d 个", cfg.Path, len(cfg.Streams))
+		m.listenRTMP(cfg.RTMPListen)
+	} else {
+		m.listenRTMP("")
+	}
+}
+
+// lookup 返回名称对应的推流点与空闲超时，未启用或不存在时 ok 为 false
+func (m *Manager) lookup(name string) (st config.IngestStream, idle time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok = m.streams[name]
+	return st, m.cfg.IdleTimeout, ok && m.cfg.Enabled
+}
+
+// Handler 推流接入处理器：POST/PUT 前缀+名称 推流，GET 跳转到对应的 /udp/ 播放地址
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(m.serve)
+}
+
+func (m *Manager) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	prefix := strings.TrimSuffix(m.cfg.Path, "/") + "/"
+	m.mu.Unlock()
+	st, idle, ok := m.lookup(strings.TrimPrefix(r.URL.Path, prefix))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// 播放与普通组播频道相同，保留 token 等参数
+		target := "/udp/" + st.Addr
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	case http.MethodPost, http.MethodPut:
+		if !authorized(r, st.Token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ingest"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		m.publish(w, r, st, idle)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized 校验推流密钥：请求头 Authorization: Bearer 密钥 或参数 token
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	got := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// publish 读取请求体并转发，推流结束或超过 idle 未收到数据时返回
+func (m *Manager) publish(w http.ResponseWriter, r *http.Request, st config.IngestStream, idle time.Duration) {
+	sess, err := m.begin(r.Context(), st, monitor.GetClientIP(r), r.URL.Path, r.UserAgent())
+	if errors.Is(err, errBusy) {
+		http.Error(w, "推流点正在使用", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	stop := context.AfterFunc(sess.ctx, func() { _ = r.Body.Close() })
+	defer stop()
+
+	rc := http.NewResponseController(w)
+	total, err := forward(sess.conn, r.Body, func() {
+		_ = rc.SetReadDeadline(time.Now().Add(idle))
+		sess.touch()
+	})
+	switch sess.finish(total, err) {
+	case finishEOF:
+		w.WriteHeader(http.StatusNoContent)
+	case finishError:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	}
+}
+
+var errBusy = errors.New("推流点正在使用")
+
+// session 一路正在进行的推流：占用推流点、连接转发地址并登记为在线客户端，可在状态页踢出
+type session struct {
+	m      *Manager
+	st     config.IngestStream
+	conn   *net.UDPConn
+	connID string
+	start  time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// begin 开始一路推流，同一推流点已有推流时返回 errBusy；ctx 结束或被踢出时 sess.ctx 结束
+func (m *Manager) begin(ctx context.Context, st config.IngestStream, clientIP, url, userAgent string) (*session, error) {
+	m.mu.Lock()
+	if m.active[st.Name] {
+		m.mu.Unlock()
+		return nil, errBusy
+	}
+	m.active[st.Name] = true
+	m.mu.Unlock()
+
+	conn, err := dial(st)
+	if err != nil {
+		m.release(st.Name)
+		logger.LogPrintf("❌ 推流 %s 无法发送到 %s: %v", st.Name, st.Addr, err)
+		return nil, err
+	}
+
+	sess := &session{m: m, st: st, conn: conn, start: time.Now(),
+		connID: "ingest_" + st.Name + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)}
+	sess.ctx, sess.cancel = context.WithCancel(ctx)
+	monitor.ActiveClients.Register(sess.connID, &monitor.ClientConnection{
+		IP:             clientIP,
+		URL:            url,
+		UserAgent:      userAgent,
+		ConnectionType: "INGEST",
+		ConnectedAt:    sess.start,
+		LastActive:     sess.start,
+	})
+	monitor.ActiveClients.SetCancel(sess.connID, sess.cancel)
+	logger.LogPrintf("📥 推流 %s 开始: %s -> %s", st.Name, clientIP, st.Addr)
+	return sess, nil
+}
+
+func (m *Manager) release(name string) {
+	m.mu.Lock()
+	delete(m.active, name)
+	m.mu.Unlock()
+}
+
+// touch 收到数据时更新在线客户端的活跃时间
+func (s *session) touch() {
+	monitor.ActiveClients.UpdateLastActive(s.connID, time.Now())
+}
+
+// 推流结束的方式
+const (
+	finishEOF    = iota // 推流端正常结束
+	finishClosed        // 连接断开或被踢出
+	finishError         // 超时或数据错误
+)
+
+// finish 结束推流并记录日志，返回结束方式
+func (s *session) finish(total int64, err error) int {
+	how := finishError
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+		how = finishEOF
+	case s.ctx.Err() != nil:
+		how = finishClosed
+	}
+	s.cancel()
+	monitor.ActiveClients.Unregister(s.connID, "INGEST")
+	s.conn.Close()
+	s.m.release(s.st.Name)
+
+	elapsed := time.Since(s.start).Round(time.Second)
+	switch how {
+	case finishEOF:
+		logger.LogPrintf("📥 推流 %s 结束，时长 %v，共 %d 字节", s.st.Name, elapsed, total)
+	case finishClosed:
+		logger.LogPrintf("📥 推流 %s 已断开，时长 %v，共 %d 字节", s.st.Name, elapsed, total)
+	default:
+		logger.LogPrintf("⚠️ 推流 %s 中断，时长 %v，共 %d 字节: %v", s.st.Name, elapsed, total, err)
+	}
+	return how
+}
+
+// dial 连接转发地址，组播按配置设置出口网卡与 TTL，并允许本机的 Hub 收到
+func dial(st config.IngestStream) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", st.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP.IsMulticast() {
+		pc := ipv4.NewPacketConn(conn)
+		if st.Iface != "" {
+			iface, err := net.InterfaceByName(st.Iface)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if err := pc.SetMulticastInterface(iface); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("设置组播出口网卡 %s 失败: %w", st.Iface, err)
+			}
+		}
+		_ = pc.SetMulticastTTL(st.TTL)
+		_ = pc.SetMulticastLoopback(true)
+	}
+	return conn, nil
+}
+
+// forward 从 body 读取 TS 流，按 TS 包对齐后每 7 个包发送一个 UDP 包，返回读取的字节数。
+// 每次读取前调用 onData 延长读取期限
+func forward(conn *net.UDPConn, body io.Reader, onData func()) (int64, error) {
+	buf := make([]byte, 64*1024)
+	var total int64
+	kept := 0 // 上次剩余、不足一个 UDP 包的数据，位于 buf 开头
+	for {
+		onData()
+		n, err := body.Read(buf[kept:])
+		total += int64(n)
+		data := buf[:kept+n]
+		// 丢弃同步字节之前的数据
+		data = data[syncOffset(data):]
+		for len(data) >= datagramSize {
+			// 发送失败（如发送缓冲区满）只丢弃这一个包，不中断推流
+			_, _ = conn.Write(data[:datagramSize])
+			data = data[datagramSize:]
+		}
+		if err != nil {
+			// 剩余不足 7 个的完整 TS 包一起发出
+			if n := len(data) / tsPacketSize * tsPacketSize; n > 0 {
+				_, _ = conn.Write(data[:n])
+			}
+			return total, err
+		}
+		kept = copy(buf, data)
+	}
+}
+
+// syncOffset 返回第一个 TS 同步字节的位置，没有时返回数据长度
+func syncOffset(b []byte) int {
+	for i := range b {
+		if b[i] == 0x47 && (i+tsPacketSize >= len(b) || b[i+tsPacketSize] == 0x47) {
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,261 @@
+package ingest
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	rtmpChunkSize  = 4096
+	rtmpWindowSize = 2500000
+	// handshakeTimeout 握手与发布命令须在该时间内完成
+	handshakeTimeout = 10 * time.Second
+)
+
+var errUnpublished = errors.New("推流端已停止发布")
+
+// listenRTMP 按配置启动或停止 RTMP 监听，调用方持有 m.mu
+func (m *Manager) listenRTMP(addr string) {
+	if addr == m.rtmpAddr {
+		return
+	}
+	if m.rtmp != nil {
+		m.rtmp.Close()
+		m.rtmp = nil
+		logger.LogPrintf("🔄 RTMP 推流监听 %s 已停止", m.rtmpAddr)
+	}
+	m.rtmpAddr = ""
+	if addr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.LogPrintf("❌ RTMP 推流监听 %s 失败: %v", addr, err)
+		return
+	}
+	m.rtmp, m.rtmpAddr = ln, addr
+	logger.LogPrintf("✅ RTMP 推流监听 %s", addr)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serveRTMP(conn)
+		}
+	}()
+}
+
+// rtmpConn 一个 RTMP 连接，只接受发布
+type rtmpConn struct {
+	m    *Manager
+	conn net.Conn
+	r    *chunkReader
+	w    *chunkWriter
+
+	app      string
+	query    url.Values // connect 时 app 中的参数
+	flashVer string
+	window   uint64 // 推流端要求的确认窗口
+	acked    uint64
+
+	idle  time.Duration
+	sess  *session
+	out   *datagramWriter
+	remux *flvRemuxer
+	total int64
+	seen  time.Time // 上次更新活跃时间
+}
+
+// serveRTMP 处理一个连接：握手、connect、createStream、publish，之后接收音视频直到断开
+func (m *Manager) serveRTMP(conn net.Conn) {
+	defer conn.Close()
+	c := &rtmpConn{m: m, conn: conn, r: newChunkReader(conn), w: newChunkWriter(conn)}
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := rtmpHandshake(conn); err != nil {
+		return
+	}
+
+	var err error
+	for {
+		var msg *rtmpMessage
+		if msg, err = c.r.read(); err != nil {
+			break
+		}
+		if err = c.handle(msg); err != nil {
+			break
+		}
+		if c.window > 0 && c.r.cr.n-c.acked >= c.window {
+			c.acked = c.r.cr.n
+			if err = c.w.control(rtmpAck, uint32(c.acked)); err != nil {
+				break
+			}
+		}
+	}
+	if c.sess != nil {
+		c.out.flush()
+		if errors.Is(err, errUnpublished) {
+			err = nil
+		}
+		c.sess.finish(c.total, err)
+	}
+}
+
+// handle 处理一条消息
+func (c *rtmpConn) handle(msg *rtmpMessage) error {
+	switch msg.typeID {
+	case rtmpSetChunkSize:
+		if len(msg.payload) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+		c.r.chunkSize = max(binary.BigEndian.Uint32(msg.payload)&0x7FFFFFFF, 1)
+	case rtmpAbort:
+		if len(msg.payload) >= 4 {
+			c.r.abort(binary.BigEndian.Uint32(msg.payload))
+		}
+	case rtmpWindowAckSize:
+		if len(msg.payload) >= 4 {
+			c.window = uint64(binary.BigEndian.Uint32(msg.payload))
+		}
+	case rtmpCommandAMF0, rtmpCommandAMF3:
+		payload := msg.payload
+		if msg.typeID == rtmpCommandAMF3 && len(payload) > 0 {
+			payload = payload[1:]
+		}
+		return c.command(msg.streamID, payload)
+	case rtmpAudio, rtmpVideo:
+		if c.remux == nil {
+			return nil
+		}
+		c.total += int64(len(msg.payload))
+		if now := time.Now(); now.Sub(c.seen) >= time.Second {
+			c.seen = now
+			c.sess.touch()
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.idle))
+		if msg.typeID == rtmpAudio {
+			return c.remux.audioTag(msg.timestamp, msg.payload)
+		}
+		return c.remux.videoTag(msg.timestamp, msg.payload)
+	}
+	return nil
+}
+
+// command 处理 AMF0 命令
+func (c *rtmpConn) command(streamID uint32, payload []byte) error {
+	args, err := amfDecode(payload)
+	if err != nil || len(args) < 2 {
+		return fmt.Errorf("命令解析失败: %v", err)
+	}
+	name, _ := args[0].(string)
+	txn, _ := args[1].(float64)
+	switch name {
+	case "connect":
+		if obj, ok := arg(args, 2).(map[string]any); ok {
+			app, _ := obj["app"].(string)
+			c.app, c.query = splitQuery(app)
+			c.flashVer, _ = obj["flashVer"].(string)
+		}
+		if err := c.w.control(rtmpWindowAckSize, rtmpWindowSize); err != nil {
+			return err
+		}
+		if err := c.w.control(rtmpSetPeerBandwidth, rtmpWindowSize); err != nil {
+			return err
+		}
+		if err := c.w.control(rtmpSetChunkSize, rtmpChunkSize); err != nil {
+			return err
+		}
+		c.w.chunkSize = rtmpChunkSize
+		return c.reply(0, "_result", txn,
+			amfObj{{"fmsVer", "FMS/3,0,1,123"}, {"capabilities", 31}},
+			amfObj{{"level", "status"}, {"code", "NetConnection.Connect.Success"},
+				{"description", "Connection succeeded."}, {"objectEncoding", 0}})
+	case "createStream":
+		return c.reply(0, "_result", txn, nil, 1)
+	case "publish":
+		key, _ := arg(args, 3).(string)
+		return c.publish(streamID, key)
+	case "play":
+		_ = c.status(streamID, "error", "NetStream.Play.Failed", "只支持推流")
+		return errors.New("不支持播放")
+	case "FCUnpublish", "deleteStream", "closeStream":
+		if c.sess != nil {
+			return errUnpublished
+		}
+	default:
+		// releaseStream、FCPublish 等只需回复
+		if txn != 0 {
+			return c.reply(0, "_result", txn, nil)
+		}
+	}
+	return nil
+}
+
+// publish 校验推流密钥并开始推流，推流地址为 rtmp://主机/应用/名称?token=密钥，
+// 密钥也可以写在应用名后
+func (c *rtmpConn) publish(streamID uint32, key string) error {
+	if c.sess != nil {
+		return errors.New("重复发布")
+	}
+	name, query := splitQuery(key)
+	token := query.Get("token")
+	if token == "" {
+		token = c.query.Get("token")
+	}
+	st, idle, ok := c.m.lookup(name)
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(st.Token)) != 1 || st.Token == "" {
+		_ = c.status(streamID, "error", "NetStream.Publish.BadName", "推流点不存在或密钥错误")
+		return fmt.Errorf("推流 %s 认证失败", name)
+	}
+
+	ip, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	sess, err := c.m.begin(context.Background(), st, ip, "rtmp://"+c.app+"/"+name, c.flashVer)
+	if err != nil {
+		_ = c.status(streamID, "error", "NetStream.Publish.BadName", err.Error())
+		return err
+	}
+	c.sess, c.idle = sess, idle
+	c.out = &datagramWriter{conn: sess.conn}
+	c.remux = newFLVRemuxer(name, c.out)
+	// 被踢出时断开连接
+	context.AfterFunc(sess.ctx, func() { _ = c.conn.Close() })
+	_ = c.conn.SetDeadline(time.Time{})
+	_ = c.conn.SetReadDeadline(time.Now().Add(idle))
+	return c.status(streamID, "status", "NetStream.Publish.Start", name+" is now published.")
+}
+
+// reply 发送命令消息
+func (c *rtmpConn) reply(streamID uint32, values ...any) error {
+	return c.w.write(3, rtmpCommandAMF0, streamID, amfEncode(values...))
+}
+
+// status 发送 onStatus
+func (c *rtmpConn) status(streamID uint32, level, code, description string) error {
+	return c.w.write(5, rtmpCommandAMF0, streamID, amfEncode("onStatus", 0, nil,
+		amfObj{{"level", level}, {"code", code}, {"description", description}}))
+}
+
+// arg 返回第 i 个参数，不存在时为 nil
+func arg(args []any, i int) any {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// splitQuery 拆分 名称?参数
+func splitQuery(s string) (string, url.Values) {
+	name, raw, _ := strings.Cut(s, "?")
+	query, _ := url.ParseQuery(raw)
+	return name, query
+}
@@ -0,0 +1,224 @@
+package ingest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// RTMP 消息类型
+const (
+	rtmpSetChunkSize     = 1
+	rtmpAbort            = 2
+	rtmpAck              = 3
+	rtmpWindowAckSize    = 5
+	rtmpSetPeerBandwidth = 6
+	rtmpAudio            = 8
+	rtmpVideo            = 9
+	rtmpDataAMF3         = 15
+	rtmpCommandAMF3      = 17
+	rtmpDataAMF0         = 18
+	rtmpCommandAMF0      = 20
+)
+
+const (
+	handshakeSize = 1536
+	// maxMessageSize 单条消息的上限，超过时认为数据错误
+	maxMessageSize = 8 << 20
+)
+
+// rtmpHandshake 服务端简单握手：S1 的版本字段为 0，客户端不会校验摘要
+func rtmpHandshake(rw io.ReadWriter) error {
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(rw, c0c1); err != nil {
+		return err
+	}
+	if c0c1[0] != 3 {
+		return fmt.Errorf("不支持的 RTMP 版本 %d", c0c1[0])
+	}
+	s := make([]byte, 1+2*handshakeSize)
+	s[0] = 3
+	_, _ = rand.Read(s[9 : 1+handshakeSize])
+	copy(s[1+handshakeSize:], c0c1[1:])
+	if _, err := rw.Write(s); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(rw, c0c1[1:])
+	return err
+}
+
+// rtmpMessage 一条完整的 RTMP 消息
+type rtmpMessage struct {
+	typeID    uint8
+	streamID  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+// chunkStream 一个块流的头部状态与正在接收的消息
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+	buf       []byte
+}
+
+// countingReader 统计读取的字节数，用于回复确认
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// chunkReader 把块重组为消息
+type chunkReader struct {
+	cr        *countingReader
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*chunkStream
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	cr := &countingReader{r: r}
+	return &chunkReader{cr: cr, r: bufio.NewReaderSize(cr, 64*1024), chunkSize: 128, streams: make(map[uint32]*chunkStream)}
+}
+
+// read 返回下一条完整的消息
+func (c *chunkReader) read() (*rtmpMessage, error) {
+	var hdr [11]byte
+	for {
+		b0, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		format := b0 >> 6
+		csid := uint32(b0 & 0x3F)
+		switch csid {
+		case 0:
+			if _, err := io.ReadFull(c.r, hdr[:1]); err != nil {
+				return nil, err
+			}
+			csid = 64 + uint32(hdr[0])
+		case 1:
+			if _, err := io.ReadFull(c.r, hdr[:2]); err != nil {
+				return nil, err
+			}
+			csid = 64 + uint32(hdr[0]) + uint32(hdr[1])*256
+		}
+		cs := c.streams[csid]
+		if cs == nil {
+			if format != 0 {
+				return nil, fmt.Errorf("块流 %d 缺少完整头部", csid)
+			}
+			cs = &chunkStream{}
+			c.streams[csid] = cs
+		}
+
+		// 头部长度依次为 11、7、3、0 字节
+		size := [4]int{11, 7, 3, 0}[format]
+		if _, err := io.ReadFull(c.r, hdr[:size]); err != nil {
+			return nil, err
+		}
+		if format <= 2 {
+			ts := uint32(hdr[0])<<16 | uint32(hdr[1])<<8 | uint32(hdr[2])
+			cs.extended = ts == 0xFFFFFF
+			if format <= 1 {
+				cs.length = uint32(hdr[3])<<16 | uint32(hdr[4])<<8 | uint32(hdr[5])
+				cs.typeID = hdr[6]
+				if cs.length > maxMessageSize {
+					return nil, fmt.Errorf("消息长度 %d 超出上限", cs.length)
+				}
+			}
+			if format == 0 {
+				cs.streamID = binary.LittleEndian.Uint32(hdr[7:11])
+			}
+			cs.delta = ts
+		}
+		if cs.extended {
+			if _, err := io.ReadFull(c.r, hdr[:4]); err != nil {
+				return nil, err
+			}
+			if format <= 2 {
+				cs.delta = binary.BigEndian.Uint32(hdr[:4])
+			}
+		}
+		// 新消息开始时更新时间戳：类型 0 为绝对时间，其余为增量
+		if len(cs.buf) == 0 {
+			if format == 0 {
+				cs.timestamp = cs.delta
+			} else {
+				cs.timestamp += cs.delta
+			}
+		}
+
+		n := min(c.chunkSize, cs.length-uint32(len(cs.buf)))
+		start := len(cs.buf)
+		cs.buf = append(cs.buf, make([]byte, n)...)
+		if _, err := io.ReadFull(c.r, cs.buf[start:]); err != nil {
+			return nil, err
+		}
+		if uint32(len(cs.buf)) < cs.length {
+			continue
+		}
+		msg := &rtmpMessage{typeID: cs.typeID, streamID: cs.streamID, timestamp: cs.timestamp, payload: cs.buf}
+		cs.buf = nil
+		return msg, nil
+	}
+}
+
+// abort 丢弃块流上接收了一半的消息
+func (c *chunkReader) abort(csid uint32) {
+	if cs := c.streams[csid]; cs != nil {
+		cs.buf = nil
+	}
+}
+
+// chunkWriter 把消息切分为块发送
+type chunkWriter struct {
+	w         *bufio.Writer
+	chunkSize int
+}
+
+func newChunkWriter(w io.Writer) *chunkWriter {
+	return &chunkWriter{w: bufio.NewWriter(w), chunkSize: 128}
+}
+
+// write 发送一条时间戳为 0 的消息，第一块使用类型 0 头部，其余为类型 3
+func (c *chunkWriter) write(csid uint8, typeID uint8, streamID uint32, payload []byte) error {
+	var hdr [12]byte
+	hdr[0] = csid & 0x3F
+	hdr[4], hdr[5], hdr[6] = byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload))
+	hdr[7] = typeID
+	binary.LittleEndian.PutUint32(hdr[8:], streamID)
+	_, _ = c.w.Write(hdr[:])
+	for i := 0; i < len(payload); i += c.chunkSize {
+		if i > 0 {
+			_ = c.w.WriteByte(0xC0 | csid&0x3F)
+		}
+		_, _ = c.w.Write(payload[i:min(i+c.chunkSize, len(payload))])
+	}
+	return c.w.Flush()
+}
+
+// control 发送协议控制消息
+func (c *chunkWriter) control(typeID uint8, values ...uint32) error {
+	var b []byte
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	if typeID == rtmpSetPeerBandwidth {
+		// 带宽限制类型：2 动态
+		b = append(b[:4], 2)
+	}
+	return c.write(2, typeID, 0, b)
+}
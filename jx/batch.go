@@ -0,0 +1,138 @@
+package jx
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/monitor"
+)
+
+// maxBatchBody 批量解析请求体的最大长度
+const maxBatchBody = 1 << 20
+
+// batchItem 批量解析中的一项
+type batchItem struct {
+	JX string `json:"jx"` // 剧名或播放页地址
+	ID string `json:"id"` // 集数，为空使用请求的 id 或 default_id
+}
+
+// batchRequest 批量解析请求体，urls 为只有地址的简写，与 items 合并
+type batchRequest struct {
+	Items []batchItem `json:"items"`
+	URLs  []string    `json:"urls"`
+	ID    string      `json:"id"`
+	Full  bool        `json:"full"`
+}
+
+// batchResult 批量解析中一项的结果，顺序与请求相同
+type batchResult struct {
+	JX     string                 `json:"jx"`
+	ID     string                 `json:"id,omitempty"`
+	Result map[string]interface{} `json:"result"`
+}
+
+// HandleBatch 批量解析：POST JSON {"items": [{"jx": "地址", "id": "集数"}], "urls": ["地址"], "id": "集数", "full": false}，
+// 按 batch.concurrency 并发解析，每项与单个解析相同地使用缓存与频率限制
+func (h *JXHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clientIP := monitor.GetClientIP(r)
+	sum := md5.Sum([]byte(r.URL.Path + "/" + r.URL.RawQuery + "/" + r.RemoteAddr))
+	connID := clientIP + "_" + hex.EncodeToString(sum[:])
+	if !h.authorize(w, r, clientIP, connID) {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBatchBody+1))
+	if err != nil {
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if len(data) > maxBatchBody {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		JSONResponse(w, map[string]interface{}{"error": "请求体过大"})
+		return
+	}
+	var body batchRequest
+	if err := json.Unmarshal(data, &body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		JSONResponse(w, map[string]interface{}{"error": "请求体不是有效的 JSON: " + err.Error()})
+		return
+	}
+	items := body.Items
+	for _, u := range body.URLs {
+		items = append(items, batchItem{JX: u})
+	}
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		JSONResponse(w, map[string]interface{}{"error": "没有需要解析的地址"})
+		return
+	}
+	if len(items) > h.Config.Batch.MaxItems {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		JSONResponse(w, map[string]interface{}{"error": "单次最多解析 " + strconv.Itoa(h.Config.Batch.MaxItems) + " 个"})
+		return
+	}
+
+	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
+		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
+		URL:            r.URL.Path,
+		UserAgent:      r.UserAgent(),
+		ConnectionType: strings.ToUpper(r.URL.Scheme),
+		ConnectedAt:    time.Now(),
+		LastActive:     time.Now(),
+	})
+	defer monitor.ActiveClients.Unregister(connID, strings.ToUpper(r.URL.Scheme))
+
+	results := make([]batchResult, len(items))
+	sem := make(chan struct{}, max(h.Config.Batch.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		results[i] = batchResult{JX: strings.TrimSpace(item.JX), ID: item.ID}
+		if results[i].ID == "" {
+			results[i].ID = body.ID
+		}
+		if results[i].JX == "" {
+			results[i].Result = map[string]interface{}{"error": "jx 参数不能为空"}
+			continue
+		}
+		wg.Add(1)
+		go func(res *batchResult) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-r.Context().Done():
+				res.Result = map[string]interface{}{"error": "请求已取消"}
+				return
+			}
+			defer func() { <-sem }()
+			res.Result, _ = h.parse(r, clientIP, parseRequest{jx: res.JX, id: res.ID, full: body.Full})
+		}(&results[i])
+	}
+	wg.Wait()
+
+	success := 0
+	for _, res := range results {
+		if _, failed := res.Result["error"]; !failed {
+			success++
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":   len(results),
+		"success": success,
+		"failed":  len(results) - success,
+		"results": results,
+	})
+}
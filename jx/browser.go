@@ -0,0 +1,535 @@
+package jx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// browserStartTimeout 等待浏览器输出调试地址的时间
+const browserStartTimeout = 20 * time.Second
+
+// defaultBrowserMatch 没有配置 match 与 script 时作为播放地址的页面请求
+var defaultBrowserMatch = regexp.MustCompile(`(?i)\.(m3u8|mp4|flv)(\?|$)`)
+
+// defaultBlockedURLs 没有配置 block_urls 时不加载的资源
+var defaultBlockedURLs = []string{
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.webp", "*.svg", "*.ico",
+	"*.woff", "*.woff2", "*.ttf", "*.otf", "*.css",
+}
+
+// browserNames 没有配置 path 时在 PATH 中查找的浏览器
+var browserNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// browserInstance 一个浏览器进程；使用 endpoint 时没有进程，调试地址在每次使用时获取
+type browserInstance struct {
+	cmd    *exec.Cmd
+	dir    string // 临时的用户数据目录
+	wsURL  string
+	exited chan struct{}
+
+	active    int       // 打开中的页面数
+	uses      int       // 已打开过的页面数
+	idleSince time.Time // 最后一个页面关闭的时间
+	retired   bool      // 不再打开新页面，页面全部关闭后退出
+}
+
+// alive 进程是否仍在运行
+func (b *browserInstance) alive() bool {
+	if b.exited == nil {
+		return true
+	}
+	select {
+	case <-b.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop 结束进程并删除用户数据目录
+func (b *browserInstance) stop() {
+	if b.cmd == nil {
+		return
+	}
+	b.cmd.Process.Kill()
+	<-b.exited
+	os.RemoveAll(b.dir)
+	logger.LogPrintf("🔄 无头浏览器已关闭 (pid %d，打开过 %d 个页面)", b.cmd.Process.Pid, b.uses)
+}
+
+// browserPool 按 jx.browser 管理浏览器进程：按需启动，页面数受 instances × max_pages 限制，
+// 打开 max_uses 个页面后或空闲 idle_timeout 后关闭，配置变化时旧进程在页面关闭后退出
+type browserPool struct {
+	mu        sync.Mutex
+	cfg       config.JXBrowserConfig
+	slots     chan struct{}
+	instances []*browserInstance
+	reaping   bool
+}
+
+// browsers 全局浏览器池，配置重载重建 JXHandler 后继续使用
+var browsers = &browserPool{}
+
+// CloseBrowsers 结束所有浏览器进程，程序退出时调用
+func CloseBrowsers() {
+	browsers.mu.Lock()
+	list := browsers.instances
+	browsers.instances = nil
+	browsers.mu.Unlock()
+	for _, b := range list {
+		b.stop()
+	}
+}
+
+// acquire 等待空闲的页面位置并选择浏览器，返回的 release 在页面关闭后调用，broken 表示浏览器无法使用
+func (p *browserPool) acquire(ctx context.Context, cfg config.JXBrowserConfig) (*browserInstance, func(broken bool), error) {
+	p.mu.Lock()
+	if p.slots == nil || !reflect.DeepEqual(p.cfg, cfg) {
+		for _, b := range p.instances {
+			b.retired = true
+		}
+		p.closeRetired()
+		p.cfg = cfg
+		size := cfg.MaxPages
+		if cfg.Endpoint == "" {
+			size *= cfg.Instances
+		}
+		p.slots = make(chan struct{}, max(size, 1))
+	}
+	slots := p.slots
+	p.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("等待浏览器页面超时: %w", ctx.Err())
+	}
+	b, err := p.pick(cfg)
+	if err != nil {
+		<-slots
+		return nil, nil, err
+	}
+	return b, func(broken bool) {
+		p.release(b, broken)
+		<-slots
+	}, nil
+}
+
+// pick 选择打开页面最少的浏览器，都已满时启动新的进程
+func (p *browserPool) pick(cfg config.JXBrowserConfig) (*browserInstance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *browserInstance
+	running := 0
+	for _, b := range p.instances {
+		if b.retired || !b.alive() {
+			b.retired = true
+			continue
+		}
+		running++
+		if b.active < cfg.MaxPages && (best == nil || b.active < best.active) {
+			best = b
+		}
+	}
+	p.closeRetired()
+	if best == nil {
+		if cfg.Endpoint != "" {
+			if running > 0 {
+				return nil, errors.New("浏览器页面已满")
+			}
+			best = &browserInstance{}
+		} else {
+			if running >= cfg.Instances {
+				return nil, errors.New("浏览器页面已满")
+			}
+			b, err := launchBrowser(cfg)
+			if err != nil {
+				return nil, err
+			}
+			best = b
+			if !p.reaping {
+				p.reaping = true
+				go p.reap()
+			}
+		}
+		p.instances = append(p.instances, best)
+	}
+	best.active++
+	return best, nil
+}
+
+// release 页面关闭后更新计数，达到 max_uses 或浏览器无法使用时不再打开新页面
+func (p *browserPool) release(b *browserInstance, broken bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.active--
+	b.uses++
+	b.idleSince = time.Now()
+	if broken || b.cmd != nil && b.uses >= p.cfg.MaxUses {
+		b.retired = true
+	}
+	p.closeRetired()
+}
+
+// closeRetired 移除已停用且没有页面的浏览器，在持有锁时调用
+func (p *browserPool) closeRetired() {
+	list := p.instances[:0]
+	for _, b := range p.instances {
+		if b.retired && b.active <= 0 {
+			go b.stop()
+			continue
+		}
+		list = append(list, b)
+	}
+	clear(p.instances[len(list):])
+	p.instances = list
+}
+
+// reap 定时关闭空闲超过 idle_timeout 的浏览器，没有启动的进程时退出
+func (p *browserPool) reap() {
+	for {
+		p.mu.Lock()
+		idle := p.cfg.IdleTimeout
+		p.mu.Unlock()
+		time.Sleep(min(max(idle/2, time.Second), 30*time.Second))
+
+		p.mu.Lock()
+		launched := false
+		for _, b := range p.instances {
+			if b.cmd == nil {
+				continue
+			}
+			if b.active == 0 && time.Since(b.idleSince) >= p.cfg.IdleTimeout {
+				b.retired = true
+				continue
+			}
+			launched = true
+		}
+		p.closeRetired()
+		if !launched {
+			p.reaping = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+	}
+}
+
+// browserPath 返回浏览器可执行文件
+func browserPath(path string) (string, error) {
+	if path != "" {
+		return exec.LookPath(path)
+	}
+	for _, name := range browserNames {
+		if p, err := exec.LookPath(name); err == nil {
+			return p, nil
+		}
+	}
+	return "", errors.New("没有找到 Chromium，请设置 jx.browser.path 或 jx.browser.endpoint")
+}
+
+// launchBrowser 启动无头浏览器并从标准错误读取调试地址
+func launchBrowser(cfg config.JXBrowserConfig) (*browserInstance, error) {
+	path, err := browserPath(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "tvgate-browser-")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"--headless=new",
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + dir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--disable-gpu",
+		"--disable-extensions",
+		"--disable-background-networking",
+		"--disable-dev-shm-usage",
+		"--mute-audio",
+		"--js-flags=--max-old-space-size=" + strconv.Itoa(cfg.MaxMemoryMB),
+	}
+	if os.Geteuid() == 0 {
+		// root 用户下 Chromium 不能使用沙箱
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, cfg.Args...)
+	args = append(args, "about:blank")
+
+	cmd := exec.Command(path, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("启动浏览器失败: %w", err)
+	}
+	b := &browserInstance{cmd: cmd, dir: dir, exited: make(chan struct{}), idleSince: time.Now()}
+
+	found := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		u := ""
+		for u == "" && sc.Scan() {
+			u, _ = strings.CutPrefix(strings.TrimSpace(sc.Text()), "DevTools listening on ")
+		}
+		found <- u
+		io.Copy(io.Discard, stderr)
+	}()
+	go func() {
+		cmd.Wait()
+		close(b.exited)
+	}()
+
+	select {
+	case b.wsURL = <-found:
+	case <-time.After(browserStartTimeout):
+	}
+	if b.wsURL == "" {
+		b.stop()
+		return nil, errors.New("浏览器启动后没有输出调试地址")
+	}
+	logger.LogPrintf("✅ 已启动无头浏览器 (pid %d)", cmd.Process.Pid)
+	return b, nil
+}
+
+// endpointURL 返回 endpoint 对应的浏览器调试地址，http 地址通过 /json/version 获取
+func endpointURL(ctx context.Context, endpoint string) (string, error) {
+	if strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://") {
+		return endpoint, nil
+	}
+	data, err := fetchPage(ctx, strings.TrimSuffix(endpoint, "/")+"/json/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("获取浏览器调试地址失败: %w", err)
+	}
+	var v struct {
+		URL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil || v.URL == "" {
+		return "", errors.New("浏览器没有返回调试地址")
+	}
+	return v.URL, nil
+}
+
+// browserResolver 由无头浏览器打开播放页：页面请求的地址匹配 match 时作为播放地址，
+// 或在页面加载后执行 script 直到返回结果
+type browserResolver struct {
+	cfg     config.JXBrowserConfig
+	match   string
+	script  string
+	headers map[string]string
+}
+
+func (r *browserResolver) Resolve(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	match := defaultBrowserMatch
+	if r.match != "" {
+		re, err := regexp.Compile(r.match)
+		if err != nil {
+			return nil, fmt.Errorf("match 不是有效的正则: %w", err)
+		}
+		match = re
+	} else if r.script != "" {
+		match = nil
+	}
+
+	b, release, err := browsers.acquire(ctx, r.cfg)
+	if err != nil {
+		return nil, err
+	}
+	broken := false
+	defer func() { release(broken) }()
+
+	wsURL := b.wsURL
+	if r.cfg.Endpoint != "" {
+		if wsURL, err = endpointURL(ctx, r.cfg.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := dialCDP(ctx, wsURL)
+	if err != nil {
+		broken = b.cmd != nil
+		return nil, err
+	}
+	defer conn.close()
+	return r.open(ctx, conn, link, id, match)
+}
+
+// open 新建页面并导航到播放页，结束后关闭页面
+func (r *browserResolver) open(ctx context.Context, conn *cdpConn, link, id string, match *regexp.Regexp) (*Result, error) {
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := conn.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, err
+	}
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn.call(cctx, "", "Target.closeTarget", map[string]any{"targetId": target.TargetID}, nil)
+	}()
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := conn.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &session); err != nil {
+		return nil, err
+	}
+	sid := session.SessionID
+
+	if err := conn.call(ctx, sid, "Network.enable", nil, nil); err != nil {
+		return nil, err
+	}
+	blocked := r.cfg.BlockURLs
+	if blocked == nil {
+		blocked = defaultBlockedURLs
+	}
+	if len(blocked) > 0 {
+		if err := conn.call(ctx, sid, "Network.setBlockedURLs", map[string]any{"urls": blocked}, nil); err != nil {
+			return nil, err
+		}
+	}
+	headers := make(map[string]string)
+	for k, v := range r.headers {
+		if strings.EqualFold(k, "User-Agent") {
+			if err := conn.call(ctx, sid, "Network.setUserAgentOverride", map[string]any{"userAgent": v}, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		headers[k] = v
+	}
+	if len(headers) > 0 {
+		if err := conn.call(ctx, sid, "Network.setExtraHTTPHeaders", map[string]any{"headers": headers}, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.call(ctx, sid, "Page.enable", nil, nil); err != nil {
+		return nil, err
+	}
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := conn.call(ctx, sid, "Page.navigate", map[string]any{"url": link}, &nav); err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != "" {
+		return nil, fmt.Errorf("打开页面失败: %s", nav.ErrorText)
+	}
+
+	script := ""
+	if r.script != "" {
+		l, _ := json.Marshal(link)
+		i, _ := json.Marshal(id)
+		script = strings.NewReplacer("{url}", string(l), "{id}", string(i)).Replace(r.script)
+	}
+	var poll <-chan time.Time
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, fmt.Errorf("页面在超时前没有得到播放地址: %w", lastErr)
+			}
+			return nil, fmt.Errorf("页面在超时前没有得到播放地址: %w", ctx.Err())
+		case <-conn.done:
+			return nil, errors.New("浏览器连接已断开")
+		case msg := <-conn.events:
+			if msg.SessionID != sid {
+				continue
+			}
+			switch msg.Method {
+			case "Network.requestWillBeSent":
+				if match == nil {
+					continue
+				}
+				var ev struct {
+					Request struct {
+						URL string `json:"url"`
+					} `json:"request"`
+				}
+				if json.Unmarshal(msg.Params, &ev) == nil && match.MatchString(ev.Request.URL) {
+					return &Result{URL: ev.Request.URL}, nil
+				}
+			case "Page.loadEventFired":
+				if script == "" || poll != nil {
+					continue
+				}
+				// 页面加载后脚本可能还没有取到值，每 500ms 重新执行
+				t := time.NewTicker(500 * time.Millisecond)
+				defer t.Stop()
+				poll = t.C
+				res, err := evaluate(ctx, conn, sid, script)
+				if res != nil {
+					return res, nil
+				}
+				lastErr = err
+			}
+		case <-poll:
+			res, err := evaluate(ctx, conn, sid, script)
+			if res != nil {
+				return res, nil
+			}
+			lastErr = err
+		}
+	}
+}
+
+// evaluate 在页面中执行脚本，返回字符串时作为播放地址，返回对象时按 {name,id,url,title} 解析，
+// 没有取到值时返回 nil
+func evaluate(ctx context.Context, conn *cdpConn, sid, script string) (*Result, error) {
+	var out struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception *struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	err := conn.call(ctx, sid, "Runtime.evaluate", map[string]any{
+		"expression":    script,
+		"returnByValue": true,
+		"awaitPromise":  true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	if e := out.ExceptionDetails; e != nil {
+		if e.Exception != nil && e.Exception.Description != "" {
+			return nil, fmt.Errorf("脚本执行出错: %s", e.Exception.Description)
+		}
+		return nil, fmt.Errorf("脚本执行出错: %s", e.Text)
+	}
+	var s string
+	if json.Unmarshal(out.Result.Value, &s) == nil {
+		if s = strings.TrimSpace(s); s != "" {
+			return &Result{URL: s}, nil
+		}
+		return nil, nil
+	}
+	var res Result
+	if json.Unmarshal(out.Result.Value, &res) == nil && (res.Name != "" || res.URL != "") {
+		return &res, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,143 @@
+package jx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// cdpMessage Chrome DevTools 协议的消息：有 id 的是命令的返回，有 method 的是事件
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// cdpConn 到浏览器调试地址的连接，页面通过 flatten 方式的 sessionId 在同一连接上收发
+type cdpConn struct {
+	ws  *websocket.Conn
+	wmu sync.Mutex
+	seq atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan cdpMessage
+	err     error
+
+	events chan cdpMessage
+	done   chan struct{}
+}
+
+// dialCDP 连接浏览器的 webSocketDebuggerUrl
+func dialCDP(ctx context.Context, wsURL string) (*cdpConn, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接浏览器失败: %w", err)
+	}
+	ws.SetReadLimit(64 << 20)
+	c := &cdpConn{
+		ws:      ws,
+		pending: make(map[int64]chan cdpMessage),
+		events:  make(chan cdpMessage, 256),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// readLoop 把命令的返回交给等待的调用，事件放入 events，处理不过来的事件丢弃
+func (c *cdpConn) readLoop() {
+	defer close(c.done)
+	for {
+		var msg cdpMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			c.mu.Lock()
+			c.err = err
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			return
+		}
+		if msg.ID != 0 {
+			c.mu.Lock()
+			ch, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+			continue
+		}
+		select {
+		case c.events <- msg:
+		default:
+		}
+	}
+}
+
+// call 发送命令并等待返回，result 为 nil 时忽略返回内容
+func (c *cdpConn) call(ctx context.Context, sessionID, method string, params, result any) error {
+	if params == nil {
+		params = struct{}{}
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := c.seq.Add(1)
+	ch := make(chan cdpMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("浏览器连接已断开: %w", c.err)
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.wmu.Lock()
+	err = c.ws.WriteJSON(cdpMessage{ID: id, Method: method, SessionID: sessionID, Params: raw})
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return errors.New("浏览器连接已断开")
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("%s: %s", method, msg.Error.Message)
+		}
+		if result != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// close 关闭连接并等待读取结束
+func (c *cdpConn) close() {
+	c.ws.Close()
+	<-c.done
+}
@@ -0,0 +1,120 @@
+package jx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// externalPlaceholders 替换外部解析器地址与参数中的 {url} {id}，escape 时按查询参数编码
+func externalPlaceholders(s, link, id string, escape bool) string {
+	if escape {
+		link, id = url.QueryEscape(link), url.QueryEscape(id)
+	}
+	return strings.NewReplacer("{url}", link, "{id}", id).Replace(s)
+}
+
+// decodeResult 解析外部解析器返回的 JSON：{"name": "剧名", "id": "集数", "url": "播放地址", "title": "标题"}
+func decodeResult(data []byte) (*Result, error) {
+	var res Result
+	if err := json.Unmarshal(bytes.TrimSpace(data), &res); err != nil {
+		return nil, fmt.Errorf("解析结果不是 JSON: %w", err)
+	}
+	return &res, nil
+}
+
+// httpResolver 调用外部解析服务：GET 时播放页地址通过 {url} {id} 传入，
+// POST 时请求体为 {"url": "播放页地址", "id": "集数"}
+type httpResolver struct {
+	url     string
+	method  string
+	headers map[string]string
+}
+
+func (r *httpResolver) Resolve(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	method := strings.ToUpper(r.method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if method != http.MethodGet {
+		data, _ := json.Marshal(map[string]any{"url": link, "id": id, "options": options})
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, externalPlaceholders(r.url, link, id, true), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("解析服务返回状态码 %d", resp.StatusCode)
+	}
+	return decodeResult(data)
+}
+
+// validExecID 检查传给外部程序的集数：只允许字母、数字、下划线和点，
+// 避免来自查询参数的 id 被当作命令行选项
+func validExecID(id string) bool {
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// execResolver 执行外部程序：参数可用 {url} {id}，options 以 JX_ 开头的环境变量传入，
+// 标准输出为与 httpResolver 相同的 JSON。占位参数前应加 "--"，{url} 与 {id} 不能以 - 开头
+type execResolver struct {
+	command []string
+}
+
+func (r *execResolver) Resolve(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	if len(r.command) == 0 {
+		return nil, fmt.Errorf("没有配置命令")
+	}
+	if !validExecID(id) {
+		return nil, fmt.Errorf("id 只能包含字母、数字、下划线和点")
+	}
+	if strings.HasPrefix(link, "-") {
+		return nil, fmt.Errorf("播放页地址不能以 - 开头")
+	}
+	args := make([]string, len(r.command)-1)
+	for i, arg := range r.command[1:] {
+		args[i] = externalPlaceholders(arg, link, id, false)
+	}
+	cmd := exec.CommandContext(ctx, r.command[0], args...)
+	cmd.Env = append(cmd.Environ(), "JX_URL="+link, "JX_ID="+id)
+	for k, v := range options {
+		cmd.Env = append(cmd.Env, "JX_"+strings.ToUpper(k)+"="+v)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return decodeResult(stdout.Bytes())
+}
@@ -1,15 +1,12 @@
 package jx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/qist/tvgate/config"
 )
 
 var iqiyiHeaders = map[string]string{
@@ -29,58 +26,40 @@ var iqiyiTVIdRegex = `"tvId":(.*?),`
 var iqiyiInfoURLTemplate = "https://pcw-api.iqiyi.com/video/video/baseinfo/%s"
 
 func init() {
-	RegisterVideoSource("iqiyi.com", HandleIQiyiLink)
+	RegisterResolver("iqiyi", []string{"iqiyi.com"}, ResolverFunc(ResolveIQiyi))
 }
 
-// HandleIQiyiLink 处理爱奇艺 URL
-func HandleIQiyiLink(w http.ResponseWriter, r *http.Request, link, id string) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", link, nil)
-	for k, v := range iqiyiHeaders {
-		req.Header.Set(k, v)
-	}
-
-	resp, err := client.Do(req)
+// ResolveIQiyi 从爱奇艺播放页解析 tvId，再查询剧名与集数
+func ResolveIQiyi(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	body, err := fetchPage(ctx, link, iqiyiHeaders)
 	if err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "请求失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("请求失败: %w", err)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	html := string(body)
 
-	tvId := parseTVId(html, iqiyiTVIdRegex)
+	tvId := parseTVId(string(body), iqiyiTVIdRegex)
 	if tvId == "" {
-		JSONResponse(w, map[string]interface{}{"error": "未获取到 tvId"})
-		return
+		return nil, errors.New("未获取到 tvId")
 	}
 
-	infoURL := fmt.Sprintf(iqiyiInfoURLTemplate, tvId)
-	infoReq, _ := http.NewRequest("GET", infoURL, nil)
-	for k, v := range iqiyiHeaders {
-		infoReq.Header.Set(k, v)
-	}
-	infoResp, err := client.Do(infoReq)
+	infoBody, err := fetchPage(ctx, fmt.Sprintf(iqiyiInfoURLTemplate, tvId), iqiyiHeaders)
 	if err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "获取视频信息失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("获取视频信息失败: %w", err)
 	}
-	defer infoResp.Body.Close()
-	infoBody, _ := io.ReadAll(infoResp.Body)
 
 	var infoData map[string]interface{}
 	if err := json.Unmarshal(infoBody, &infoData); err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "解析视频信息失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("解析视频信息失败: %w", err)
 	}
 
 	data, ok := infoData["data"].(map[string]interface{})
 	if !ok {
-		JSONResponse(w, map[string]interface{}{"error": "未找到 data 字段"})
-		return
+		return nil, errors.New("未找到 data 字段")
 	}
 
 	albumName, _ := data["albumName"].(string)
+	if albumName == "" {
+		return nil, fmt.Errorf("未获取到 tvId %s 的剧名", tvId)
+	}
 	if orderVal, exists := data["order"]; exists {
 		switch v := orderVal.(type) {
 		case string:
@@ -88,23 +67,9 @@ func HandleIQiyiLink(w http.ResponseWriter, r *http.Request, link, id string) {
 		case float64:
 			id = strconv.FormatInt(int64(v), 10)
 		}
-	} else if id == "" {
-		id = config.Cfg.JX.DefaultID
-	}
-
-	// 使用全局 JX 配置调用 HandleRequest
-	if albumName != "" {
-		h := NewJXHandler(&config.Cfg.JX) // 取地址
-		h.HandleRequest(w, r, albumName, id)
-		return
 	}
-
-	JSONResponse(w, map[string]interface{}{
-		"url":         link,
-		"tvId":        tvId,
-		"content_len": len(html),
-		"status_code": resp.StatusCode,
-	})
+	title, _ := data["name"].(string)
+	return &Result{Name: albumName, ID: id, Title: title}, nil
 }
 
 func parseTVId(html, regexStr string) string {
@@ -4,7 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 
 	"fmt"
@@ -15,15 +15,6 @@ import (
 	"time"
 )
 
-type VideoSourceHandler func(w http.ResponseWriter, r *http.Request, link, id string)
-
-var sourceMap = map[string]VideoSourceHandler{}
-
-// RegisterVideoSource 注册视频源处理函数
-func RegisterVideoSource(domain string, handler VideoSourceHandler) {
-	sourceMap[strings.ToLower(domain)] = handler
-}
-
 type JXHandler struct {
 	Config *config.JXConfig
 }
@@ -40,6 +31,40 @@ func (h *JXHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	hi := md5.Sum([]byte(raw))
 	hashStr := hex.EncodeToString(hi[:])
 	connID := clientIP + "_" + hashStr
+	if !h.authorize(w, r, clientIP, connID) {
+		return
+	}
+	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
+		IP:             clientIP,
+		TrustedIP:      monitor.TrustedClientIP(r),
+		URL:            r.URL.Path,
+		UserAgent:      r.UserAgent(),
+		ConnectionType: strings.ToUpper(r.URL.Scheme),
+		ConnectedAt:    time.Now(),
+		LastActive:     time.Now(),
+	})
+	defer monitor.ActiveClients.Unregister(connID, strings.ToUpper(r.URL.Scheme))
+
+	req := parseRequest{jx: r.URL.Query().Get("jx"), id: r.URL.Query().Get("id"), full: r.URL.Query().Get("full") == "1"}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// w.Header().Set("Server", "TVGate")
+
+	if req.jx == "" {
+		JSONResponse(w, map[string]interface{}{"error": "jx 参数不能为空"})
+		return
+	}
+
+	resp, limited := h.parse(r, clientIP, req)
+	if limited {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	JSONResponse(w, resp)
+}
+
+// authorize 全局 token 验证，失败时已返回 403
+func (h *JXHandler) authorize(w http.ResponseWriter, r *http.Request, clientIP, connID string) bool {
 	// 全局token验证
 	if auth.GetGlobalTokenManager() != nil {
 		tokenParamName := "my_token" // 默认参数名
@@ -52,15 +77,15 @@ func (h *JXHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		// connID := clientIP + "_" + r.RemoteAddr
 
 		// 验证全局token
-		if !auth.GetGlobalTokenManager().ValidateToken(token, r.URL.Path, connID) {
-			logger.LogPrintf("全局token验证失败: token=%s, path=%s, ip=%s", token, r.URL.Path, clientIP)
+		if !auth.GetGlobalTokenManager().ValidateSignedURL(r) && !auth.GetGlobalTokenManager().ValidateToken(r, token, connID, clientIP) {
+			logger.LogPrintf("全局token验证失败: token=%s, path=%s, ip=%s", logger.MaskToken(token), r.URL.Path, clientIP)
 			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
+			return false
 		}
 
 		// 更新全局token活跃状态
 		auth.GetGlobalTokenManager().KeepAlive(token, connID, clientIP, r.URL.Path)
-		logger.LogPrintf("全局token验证成功: token=%s, path=%s, ip=%s", token, r.URL.Path, clientIP)
+		logger.LogPrintf("全局token验证成功: token=%s, path=%s, ip=%s", logger.MaskToken(token), r.URL.Path, clientIP)
 	}
 	// 如果启用了全局认证，在向后端发送请求前删除 token 参数（保持原始 URL）
 	if auth.GetGlobalTokenManager() != nil {
@@ -72,55 +97,82 @@ func (h *JXHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		cleanURL := removeQueryParamRaw(r.URL.String(), tokenParamName)
 		logger.LogPrintf("清理后的URL: %s", cleanURL)
 	}
-	monitor.ActiveClients.Register(connID, &monitor.ClientConnection{
-		IP:             clientIP,
-		URL:            r.URL.Path,
-		UserAgent:      r.UserAgent(),
-		ConnectionType: strings.ToUpper(r.URL.Scheme),
-		ConnectedAt:    time.Now(),
-		LastActive:     time.Now(),
-	})
-	defer monitor.ActiveClients.Unregister(connID, strings.ToUpper(r.URL.Scheme))
+	return true
+}
 
-	jxParam := r.URL.Query().Get("jx")
-	idParam := r.URL.Query().Get("id")
-	if idParam == "" {
-		idParam = h.Config.DefaultID
-	}
+// parseRequest 一次解析请求
+type parseRequest struct {
+	jx   string // 剧名或播放页地址
+	id   string // 请求中的集数，可能为空
+	full bool   // 同时返回完整的剧集列表
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	// w.Header().Set("Server", "TVGate")
+// errTooFrequent 超出解析频率限制时的结果
+func errTooFrequent() map[string]interface{} {
+	return map[string]interface{}{"error": "请求过于频繁，请稍后再试"}
+}
 
-	if jxParam == "" {
-		JSONResponse(w, map[string]interface{}{"error": "jx 参数不能为空"})
-		return
+// parse 相同的解析请求直接返回缓存的结果（不计入客户端的解析次数），否则按客户端限速后解析并缓存成功的结果；
+// 超出客户端限速时 limited 为 true
+func (h *JXHandler) parse(r *http.Request, clientIP string, req parseRequest) (resp map[string]interface{}, limited bool) {
+	id := req.id
+	if id == "" {
+		id = h.Config.DefaultID
 	}
-
-	// 如果是完整 URL
-	if strings.HasPrefix(jxParam, "http://") || strings.HasPrefix(jxParam, "https://") {
-		if handler := matchSourceHandler(jxParam); handler != nil {
-			handler(w, r, jxParam, idParam)
-			return
-		}
+	cacheKey := req.jx + "|" + id + "|" + strconv.FormatBool(req.full)
+	if resp, ok := cachedResponse(cacheKey); ok {
+		return resp, false
+	}
+	if !allow("client:"+clientIP, h.Config.RateLimit.Client) {
+		return errTooFrequent(), true
 	}
 
-	// 默认走普通 API 查询
-	h.HandleRequest(w, r, jxParam, idParam)
+	resp, ttl := h.resolve(r, req, id)
+	if _, failed := resp["error"]; !failed {
+		storeResponse(cacheKey, resp, ttl)
+	}
+	return resp, false
 }
 
-// matchSourceHandler 根据 URL host 返回对应处理函数
-func matchSourceHandler(rawURL string) VideoSourceHandler {
-	u, err := url.Parse(rawURL)
+// resolve 完整 URL 由对应网站的解析器得到剧名与集数或直接得到播放地址，其余按剧名查询 API 组；
+// id 为补全默认值后的集数，同时返回结果的缓存时长
+func (h *JXHandler) resolve(r *http.Request, req parseRequest, id string) (map[string]interface{}, time.Duration) {
+	ttl := h.Config.CacheTTL
+	if !strings.HasPrefix(req.jx, "http://") && !strings.HasPrefix(req.jx, "https://") {
+		return h.query(r, req.jx, id, req.full), ttl
+	}
+	res := h.matchResolver(req.jx)
+	if res == nil {
+		return h.query(r, req.jx, id, req.full), ttl
+	}
+	if res.cacheTTL != 0 {
+		ttl = res.cacheTTL
+	}
+	limit := h.Config.RateLimit.Site
+	if res.rateLimit != 0 {
+		limit = res.rateLimit
+	}
+	if !allow("site:"+res.name, limit) {
+		return errTooFrequent(), 0
+	}
+
+	result, err := res.resolve(r.Context(), req.jx, req.id)
 	if err != nil {
-		return nil
+		logger.LogPrintf("解析器 %s 解析 %s 失败: %v", res.name, req.jx, err)
+		return map[string]interface{}{"error": "解析失败: " + err.Error()}, 0
 	}
-	host := strings.ToLower(u.Host)
-	for key, handler := range sourceMap {
-		if strings.Contains(host, key) {
-			return handler
-		}
+	if result.URL != "" {
+		return map[string]interface{}{
+			"From_id":     result.ID,
+			"From_title":  result.Title,
+			"From_source": res.name,
+			"url":         result.URL,
+		}, ttl
+	}
+	if result.ID == "" {
+		result.ID = id
 	}
-	return nil
+	return h.query(r, result.Name, result.ID, req.full), ttl
 }
 
 // removeQueryParamRaw 保持原始 query 格式，只删除指定参数
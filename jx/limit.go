@@ -0,0 +1,81 @@
+package jx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/utils/ratelimit"
+)
+
+// maxCachedResults 最多缓存的解析结果数
+const maxCachedResults = 1024
+
+// cachedResult 缓存的解析结果
+type cachedResult struct {
+	resp    map[string]interface{}
+	expires time.Time
+}
+
+// 解析结果缓存与限速器在配置重载重建 JXHandler 后继续使用
+var (
+	cacheMu sync.Mutex
+	results = make(map[string]*cachedResult)
+
+	limitMu  sync.Mutex
+	limiters = make(map[int]*ratelimit.Limiter)
+)
+
+// cachedResponse 返回未过期的缓存结果
+func cachedResponse(key string) (map[string]interface{}, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	e, ok := results[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(results, key)
+		return nil, false
+	}
+	return e.resp, true
+}
+
+// storeResponse 缓存成功的解析结果，数量超出上限时先清理过期的，仍超出时丢弃最早过期的
+func storeResponse(key string, resp map[string]interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if _, ok := results[key]; !ok && len(results) >= maxCachedResults {
+		oldest := ""
+		for k, e := range results {
+			if now.After(e.expires) {
+				delete(results, k)
+			} else if oldest == "" || e.expires.Before(results[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(results) >= maxCachedResults {
+			delete(results, oldest)
+		}
+	}
+	results[key] = &cachedResult{resp: resp, expires: now.Add(ttl)}
+}
+
+// allow 按每分钟次数限制 key 的请求，perMinute <= 0 不限制；每种次数一个按 key 分桶的限速器
+func allow(key string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+	limitMu.Lock()
+	l, ok := limiters[perMinute]
+	if !ok {
+		// 允许一分钟的额度集中使用
+		l = ratelimit.NewLimiter(float64(perMinute)/60, perMinute)
+		limiters[perMinute] = l
+	}
+	limitMu.Unlock()
+	return l.Allow(key)
+}
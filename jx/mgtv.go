@@ -1,50 +1,35 @@
 package jx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"regexp"
-	// "strconv"
-	// "strings"
-	"time"
-
-	"github.com/qist/tvgate/config"
-	// "github.com/qist/tvgate/logger"
 )
 
 // 初始化注册芒果 TV
 func init() {
-	RegisterVideoSource("mgtv.com", HandleMGTVLink)
+	RegisterResolver("mgtv", []string{"mgtv.com"}, ResolverFunc(ResolveMGTV))
 }
 
-// HandleMGTVLink 处理芒果 TV 播放地址
-func HandleMGTVLink(w http.ResponseWriter, r *http.Request, link, id string) {
+// ResolveMGTV 从芒果 TV 播放地址解析剧名与集数
+func ResolveMGTV(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
 	videoID, cid := extractMGTVIDs(link)
 	if videoID == "" || cid == "" {
-		JSONResponse(w, map[string]interface{}{"error": "无法解析 video_id 或 cid"})
-		return
+		return nil, errors.New("无法解析 video_id 或 cid")
 	}
 
 	apiURL := fmt.Sprintf("https://pcweb.api.mgtv.com/player/vinfo?video_id=%s&cid=%s", url.QueryEscape(videoID), url.QueryEscape(cid))
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	req, _ := http.NewRequest("GET", apiURL, nil)
-	// 添加请求头
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", link)
-	req.Header.Set("Origin", "https://www.mgtv.com")
-	req.Header.Set("Connection", "keep-alive")
-
-	resp, err := client.Do(req)
+	body, err := fetchPage(ctx, apiURL, map[string]string{
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36",
+		"Referer":    link,
+		"Origin":     "https://www.mgtv.com",
+	})
 	if err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "请求视频信息失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("请求视频信息失败: %w", err)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 
 	var infoData struct {
 		Data struct {
@@ -56,12 +41,10 @@ func HandleMGTVLink(w http.ResponseWriter, r *http.Request, link, id string) {
 		Msg  string `json:"msg"`
 	}
 	if err := json.Unmarshal(body, &infoData); err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "解析JSON失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
 	}
 	if infoData.Code != 200 {
-		JSONResponse(w, map[string]interface{}{"error": "未获取到有效视频信息"})
-		return
+		return nil, errors.New("未获取到有效视频信息")
 	}
 
 	// 从 t2 提取集数
@@ -74,12 +57,7 @@ func HandleMGTVLink(w http.ResponseWriter, r *http.Request, link, id string) {
 	if seriesName == "" {
 		seriesName = infoData.Data.T2
 	}
-
-	// logger.LogPrintf("videoID: %s, cid: %s, series_name: %s, episode: %s", videoID, cid, seriesName, episode)
-
-	// 调用统一 HandleRequest 返回数据
-	h := NewJXHandler(&config.Cfg.JX)
-	h.HandleRequest(w, r, seriesName, episode)
+	return &Result{Name: seriesName, ID: episode, Title: infoData.Data.T2}, nil
 }
 
 // extractMGTVIDs 从播放 URL 中提取 video_id 和 cid
@@ -1,16 +1,12 @@
 package jx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"regexp"
 	"strings"
-	"time"
-
-	"github.com/qist/tvgate/config"
-	// "github.com/qist/tvgate/logger"
 )
 
 var qqHeaders = map[string]string{
@@ -37,67 +33,44 @@ type QQAPIResult struct {
 }
 
 func init() {
-	RegisterVideoSource("qq.com", HandleQQLink)
+	RegisterResolver("qq", []string{"qq.com"}, ResolverFunc(ResolveQQ))
 }
 
-// HandleQQLink 处理腾讯视频 URL
-func HandleQQLink(w http.ResponseWriter, r *http.Request, link, id string) {
-    videoID := parseQQVideoID(link)
-    if videoID == "" {
-        JSONResponse(w, map[string]interface{}{"error": "未解析到视频ID"})
-        return
-    }
-
-    infoURL := fmt.Sprintf(qqInfoURLTemplate, videoID)
-    client := &http.Client{Timeout: 10 * time.Second}
-
-    req, _ := http.NewRequest("GET", infoURL, nil)
-    for k, v := range qqHeaders {
-        req.Header.Set(k, v)
-    }
-    resp, err := client.Do(req)
-    if err != nil {
-        JSONResponse(w, map[string]interface{}{"error": "获取视频信息失败: " + err.Error()})
-        return
-    }
-    defer resp.Body.Close()
-
-    body, _ := io.ReadAll(resp.Body)
-    cleanBody := cleanJSONP(string(body))
-
-    var info QQAPIResult
-    if err := json.Unmarshal([]byte(cleanBody), &info); err != nil {
-        JSONResponse(w, map[string]interface{}{"error": "解析JSON失败: " + err.Error()})
-        return
-    }
+// ResolveQQ 从腾讯视频地址解析剧名与集数
+func ResolveQQ(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	videoID := parseQQVideoID(link)
+	if videoID == "" {
+		return nil, errors.New("未解析到视频ID")
+	}
 
-    if len(info.Results) == 0 {
-        JSONResponse(w, map[string]interface{}{"error": "未获取到视频信息"})
-        return
-    }
+	body, err := fetchPage(ctx, fmt.Sprintf(qqInfoURLTemplate, videoID), qqHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("获取视频信息失败: %w", err)
+	}
+	cleanBody := cleanJSONP(string(body))
 
-    fields := info.Results[0].Fields
-    seriesName := fields.SeriesName
-    if seriesName == "" {
-        seriesName = fields.Title // 兜底
-    }
+	var info QQAPIResult
+	if err := json.Unmarshal([]byte(cleanBody), &info); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
+	}
 
-    // ✅ 优先用腾讯返回的集数
-    if fields.CTitleOutput != "" {
-        id = fields.CTitleOutput
-    }
-    if id == "" {
-        id = config.Cfg.JX.DefaultID
-    }
+	if len(info.Results) == 0 {
+		return nil, errors.New("未获取到视频信息")
+	}
 
-    // logger.LogPrintf("QQLink resolved: videoID=%s, series=%s, episode=%s", fields.VideoID, seriesName, id)
+	fields := info.Results[0].Fields
+	seriesName := fields.SeriesName
+	if seriesName == "" {
+		seriesName = fields.Title // 兜底
+	}
 
-    // 调用通用 HandleRequest
-    h := NewJXHandler(&config.Cfg.JX)
-    h.HandleRequest(w, r, seriesName, id)
+	// ✅ 优先用腾讯返回的集数，没有时由调用方使用请求中的集数或默认集数
+	if fields.CTitleOutput != "" {
+		id = fields.CTitleOutput
+	}
+	return &Result{Name: seriesName, ID: id, Title: fields.Title}, nil
 }
 
-
 // 提取视频ID
 func parseQQVideoID(link string) string {
 	matches := qqVideoIDRegex.FindStringSubmatch(link)
@@ -16,28 +16,36 @@ import (
 
 // HandleRequest 通用视频 API 查询
 func (h *JXHandler) HandleRequest(w http.ResponseWriter, r *http.Request, name, id string) {
+	JSONResponse(w, h.query(r, name, id, r.URL.Query().Get("full") == "1"))
+}
+
+// query 按剧名与集数依次查询 API 组，返回给客户端的结果，失败时含 error；showData 时同时返回完整的剧集列表
+func (h *JXHandler) query(r *http.Request, name, id string, showData bool) map[string]interface{} {
 	if h.Config == nil || len(h.Config.APIGroups) == 0 {
-		JSONResponse(w, map[string]interface{}{"error": "未配置视频 API"})
-		return
+		return map[string]interface{}{"error": "未配置视频 API"}
 	}
 
-	showData := r.URL.Query().Get("full") == "1"
-
 	type apiRequest struct {
+		group   string
 		api     *config.VideoAPIGroupConfig
 		baseURL string
 		fullURL string
 	}
 
 	var requests []apiRequest
-	for _, api := range h.Config.APIGroups {
+	for group, api := range h.Config.APIGroups {
 		for _, baseURL := range api.Endpoints {
 			fullURL := fmt.Sprintf(api.QueryTemplate, strings.TrimRight(baseURL, "/"), url.QueryEscape(name))
-			requests = append(requests, apiRequest{api: api, baseURL: baseURL, fullURL: fullURL})
+			requests = append(requests, apiRequest{group: group, api: api, baseURL: baseURL, fullURL: fullURL})
 		}
 	}
 
+	limited := false
 	for _, req := range requests {
+		if !allow("api:"+req.group, h.Config.RateLimit.Site) {
+			limited = true
+			continue
+		}
 		client := &http.Client{Timeout: req.api.Timeout}
 
 		var respBody []byte
@@ -96,12 +104,14 @@ func (h *JXHandler) HandleRequest(w http.ResponseWriter, r *http.Request, name,
 			finalResp["data"] = result
 		}
 
-		JSONResponse(w, finalResp)
 		logger.LogRequestAndResponse(r, req.fullURL, &http.Response{StatusCode: http.StatusOK})
-		return
+		return finalResp
 	}
 
-	JSONResponse(w, map[string]interface{}{"error": "未获取到有效数据"})
+	if limited {
+		return errTooFrequent()
+	}
+	return map[string]interface{}{"error": "未获取到有效数据"}
 }
 
 // -------------------- 辅助函数 --------------------
@@ -0,0 +1,198 @@
+package jx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// defaultResolverTimeout 解析器没有配置超时时使用
+const defaultResolverTimeout = 10 * time.Second
+
+// Result 解析结果：得到剧名与集数时由 api_groups 查询播放地址，直接得到播放地址时不再查询
+type Result struct {
+	Name  string `json:"name"`  // 剧名
+	ID    string `json:"id"`    // 集数
+	URL   string `json:"url"`   // 播放地址
+	Title string `json:"title"` // 标题，只用于返回给客户端
+}
+
+// Resolver 视频网站解析器，link 为播放页地址，id 为请求中的集数（可能为空），
+// options 为配置中该解析器的 options
+type Resolver interface {
+	Resolve(ctx context.Context, link, id string, options map[string]string) (*Result, error)
+}
+
+// ResolverFunc 把函数作为 Resolver 使用
+type ResolverFunc func(ctx context.Context, link, id string, options map[string]string) (*Result, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
+	return f(ctx, link, id, options)
+}
+
+// builtinResolver 编译进程序的解析器
+type builtinResolver struct {
+	domains  []string
+	resolver Resolver
+}
+
+var builtins = map[string]builtinResolver{}
+
+// RegisterResolver 注册内置解析器，在 init 中调用；domains 为默认处理的网站域名（匹配子域名），
+// 配置中 jx.resolvers 下同名的配置块可以覆盖域名、传入 options 或停用
+func RegisterResolver(name string, domains []string, r Resolver) {
+	lower := make([]string, len(domains))
+	for i, d := range domains {
+		lower[i] = strings.ToLower(d)
+	}
+	builtins[strings.ToLower(name)] = builtinResolver{domains: lower, resolver: r}
+}
+
+// Builtin 是否有该名称的内置解析器
+func Builtin(name string) bool {
+	_, ok := builtins[strings.ToLower(name)]
+	return ok
+}
+
+// activeResolver 按配置生效的解析器
+type activeResolver struct {
+	name     string
+	domains  []string
+	options  map[string]string
+	timeout  time.Duration
+	resolver Resolver
+
+	cacheTTL  time.Duration // 0 使用 jx.cache_ttl
+	rateLimit int           // 0 使用 jx.rate_limit.site
+}
+
+func (a *activeResolver) matches(host string) bool {
+	for _, d := range a.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildResolvers 合并内置解析器与配置：配置中的解析器按名称排序在前，没有配置的内置解析器使用默认域名
+func buildResolvers(jx config.JXConfig) []*activeResolver {
+	cfg := jx.Resolvers
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list []*activeResolver
+	configured := make(map[string]bool)
+	for _, name := range names {
+		rc := cfg[name]
+		key := strings.ToLower(name)
+		configured[key] = true
+		if rc == nil || rc.Disabled {
+			continue
+		}
+		a := &activeResolver{name: name, options: rc.Options, timeout: rc.Timeout, cacheTTL: rc.CacheTTL, rateLimit: rc.RateLimit}
+		for _, d := range rc.Domains {
+			a.domains = append(a.domains, strings.ToLower(strings.TrimPrefix(d, "*.")))
+		}
+		switch strings.ToLower(rc.Type) {
+		case "":
+			b, ok := builtins[key]
+			if !ok {
+				continue
+			}
+			a.resolver = b.resolver
+			if len(a.domains) == 0 {
+				a.domains = b.domains
+			}
+		case "http":
+			a.resolver = &httpResolver{url: rc.URL, method: rc.Method, headers: rc.Headers}
+		case "exec":
+			a.resolver = &execResolver{command: rc.Command}
+		case "browser":
+			a.resolver = &browserResolver{cfg: jx.Browser, match: rc.Match, script: rc.Script, headers: rc.Headers}
+			if a.timeout <= 0 {
+				a.timeout = jx.Browser.NavigationTimeout
+			}
+		default:
+			continue
+		}
+		list = append(list, a)
+	}
+
+	names = names[:0]
+	for name := range builtins {
+		if !configured[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := builtins[name]
+		list = append(list, &activeResolver{name: name, domains: b.domains, resolver: b.resolver})
+	}
+	return list
+}
+
+// matchResolver 按播放页地址的域名选择解析器
+func (h *JXHandler) matchResolver(rawURL string) *activeResolver {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, a := range buildResolvers(*h.Config) {
+		if a.matches(host) {
+			return a
+		}
+	}
+	return nil
+}
+
+// resolve 调用解析器，超时默认 10s
+func (a *activeResolver) resolve(ctx context.Context, link, id string) (*Result, error) {
+	timeout := a.timeout
+	if timeout <= 0 {
+		timeout = defaultResolverTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := a.resolver.Resolve(ctx, link, id, a.options)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || res.Name == "" && res.URL == "" {
+		return nil, errors.New("解析器没有返回剧名或播放地址")
+	}
+	return res, nil
+}
+
+// fetchPage 内置解析器获取网站接口或页面
+func fetchPage(ctx context.Context, link string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("返回状态码 %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+}
@@ -1,72 +1,53 @@
 package jx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/qist/tvgate/config"
-	// "github.com/qist/tvgate/logger"
 )
 
 // 初始化注册优酷
 func init() {
-	RegisterVideoSource("youku.com", HandleYoukuLink)
+	RegisterResolver("youku", []string{"youku.com"}, ResolverFunc(ResolveYouku))
 }
 
-// HandleYoukuLink 处理优酷播放地址
-func HandleYoukuLink(w http.ResponseWriter, r *http.Request, link, id string) {
+// ResolveYouku 从优酷播放地址解析剧名与集数
+func ResolveYouku(ctx context.Context, link, id string, options map[string]string) (*Result, error) {
 	videoID := extractYoukuID(link)
 	if videoID == "" {
-		JSONResponse(w, map[string]interface{}{"error": "无法解析 video_id"})
-		return
+		return nil, errors.New("无法解析 video_id")
 	}
 
 	// 构造 API 请求 URL
 	apiURL := fmt.Sprintf("https://openapi.youku.com/v2/videos/show_basic.json?video_id=%s&client_id=53e6cc67237fc59a", url.QueryEscape(videoID))
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	req, _ := http.NewRequest("GET", apiURL, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", link)
-	req.Header.Set("Origin", "https://www.youku.com")
-	req.Header.Set("Connection", "keep-alive")
-
-	resp, err := client.Do(req)
+	body, err := fetchPage(ctx, apiURL, map[string]string{
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36",
+		"Referer":    link,
+		"Origin":     "https://www.youku.com",
+	})
 	if err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "请求视频信息失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("请求视频信息失败: %w", err)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 
 	var infoData struct {
 		Title string `json:"title"`
 		Link  string `json:"link"`
 	}
 	if err := json.Unmarshal(body, &infoData); err != nil {
-		JSONResponse(w, map[string]interface{}{"error": "解析JSON失败: " + err.Error()})
-		return
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
 	}
 
 	if infoData.Title == "" {
-		JSONResponse(w, map[string]interface{}{"error": "未获取到有效视频信息"})
-		return
+		return nil, errors.New("未获取到有效视频信息")
 	}
 
 	seriesName, episode := parseYoukuTitle(infoData.Title)
-
-	// logger.LogPrintf("videoID: %s, series_name: %s, episode: %s", videoID, seriesName, episode)
-
-	// 调用统一 HandleRequest 返回数据
-	h := NewJXHandler(&config.Cfg.JX)
-	h.HandleRequest(w, r, seriesName, episode)
+	return &Result{Name: seriesName, ID: episode, Title: infoData.Title}, nil
 }
 
 // extractYoukuID 从优酷 URL 中提取 video_id
@@ -0,0 +1,151 @@
+// Package kvstore 多实例共享的键值存储：负载均衡后的多个实例通过它共享签发的 token、会话数与封禁记录。
+// 未配置时各功能只在本实例内保存，存储实现通过 Register 注册，内置 redis
+package kvstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// Store 哈希表形式的共享存储，key 不含前缀，由实现统一添加
+type Store interface {
+	HGet(ctx context.Context, key, field string) (string, bool, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HSet(ctx context.Context, key, field, value string) error
+	HDel(ctx context.Context, key string, fields ...string) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	Close() error
+}
+
+// Factory 按配置创建存储
+type Factory func(cfg config.StoreConfig) (Store, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register 注册存储实现，名称对应 store.type
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = f
+}
+
+func init() {
+	Register("redis", newRedis)
+}
+
+var (
+	mu      sync.RWMutex
+	current Store
+	active  config.StoreConfig
+	stop    chan struct{}
+
+	syncMu  sync.Mutex
+	syncers []func(Store)
+)
+
+// OnSync 注册同步函数，配置了共享存储时按 sync_interval 定期调用
+func OnSync(fn func(Store)) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	syncers = append(syncers, fn)
+}
+
+// Shared 当前的共享存储，未配置时为 nil
+func Shared() Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Configure 应用配置，配置未变化时保持连接，变化时关闭旧连接
+func Configure(cfg config.StoreConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg == active && (current != nil || cfg.Type == "") {
+		return
+	}
+	if stop != nil {
+		close(stop)
+		stop = nil
+	}
+	if current != nil {
+		_ = current.Close()
+		current = nil
+	}
+	active = cfg
+	if cfg.Type == "" || strings.EqualFold(cfg.Type, "memory") {
+		return
+	}
+
+	registryMu.RLock()
+	f, ok := registry[strings.ToLower(cfg.Type)]
+	registryMu.RUnlock()
+	if !ok {
+		logger.LogPrintf("❌ 不支持的共享存储类型: %s", cfg.Type)
+		return
+	}
+	s, err := f(cfg)
+	if err != nil {
+		logger.LogPrintf("❌ 连接共享存储失败: %v", err)
+		return
+	}
+	current = s
+	stop = make(chan struct{})
+	go run(stop, s, cfg.SyncInterval)
+	logger.LogPrintf("✅ 已启用共享存储: %s %s", cfg.Type, cfg.Addr)
+}
+
+// run 定期调用同步函数
+func run(stop chan struct{}, s Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		syncMu.Lock()
+		fns := append([]func(Store){}, syncers...)
+		syncMu.Unlock()
+		for _, fn := range fns {
+			fn(s)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Interval 同步间隔，共享的数据超过数个间隔未更新视为失效
+func Interval() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active.SyncInterval
+}
+
+// Context 单次访问共享存储的超时
+func Context() (context.Context, context.CancelFunc) {
+	mu.RLock()
+	timeout := active.Timeout
+	mu.RUnlock()
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// InstanceID 本实例的标识，用于区分各实例写入的会话数
+func InstanceID() string { return instanceID }
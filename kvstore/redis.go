@@ -0,0 +1,230 @@
+package kvstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// redisStore 只实现用到的少量命令的 Redis 客户端（RESP2），连接按需建立并放回空闲池
+type redisStore struct {
+	cfg    config.StoreConfig
+	idle   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError Redis 返回的错误回复，连接仍然可用
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func newRedis(cfg config.StoreConfig) (Store, error) {
+	s := &redisStore{cfg: cfg, idle: make(chan *redisConn, cfg.PoolSize)}
+	// 启动时检查一次连接与认证，失败时直接报告配置错误
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if _, err := s.do(ctx, "PING"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *redisStore) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: s.cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	var setup [][]string
+	switch {
+	case s.cfg.Username != "":
+		setup = append(setup, []string{"AUTH", s.cfg.Username, s.cfg.Password})
+	case s.cfg.Password != "":
+		setup = append(setup, []string{"AUTH", s.cfg.Password})
+	}
+	if s.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.cfg.DB)})
+	}
+	for _, args := range setup {
+		if _, err := c.do(ctx, s.cfg.Timeout, args); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do 执行一条命令，网络错误时丢弃连接
+func (s *redisStore) do(ctx context.Context, args ...string) (any, error) {
+	var c *redisConn
+	select {
+	case c = <-s.idle:
+	default:
+		var err error
+		if c, err = s.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.do(ctx, s.cfg.Timeout, args)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		c.conn.Close()
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+	if s.closed {
+		s.drain()
+	}
+	return reply, err
+}
+
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.conn.SetDeadline(deadline)
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, a...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply 读取一条回复：状态与批量字符串为 string，整数为 int64，数组为 []any，空值为 nil
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: 回复格式错误: %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		list := make([]any, n)
+		for i := range list {
+			if list[i], err = readReply(r); err != nil {
+				var re redisError
+				if !errors.As(err, &re) {
+					return nil, err
+				}
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("redis: 未知的回复类型: %q", line)
+}
+
+func (s *redisStore) key(k string) string { return s.cfg.Prefix + k }
+
+func (s *redisStore) HGet(ctx context.Context, key, field string) (string, bool, error) {
+	reply, err := s.do(ctx, "HGET", s.key(key), field)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	v, _ := reply.(string)
+	return v, true, nil
+}
+
+func (s *redisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := s.do(ctx, "HGETALL", s.key(key))
+	if err != nil {
+		return nil, err
+	}
+	list, _ := reply.([]any)
+	m := make(map[string]string, len(list)/2)
+	for i := 0; i+1 < len(list); i += 2 {
+		k, _ := list[i].(string)
+		v, _ := list[i+1].(string)
+		m[k] = v
+	}
+	return m, nil
+}
+
+func (s *redisStore) HSet(ctx context.Context, key, field, value string) error {
+	_, err := s.do(ctx, "HSET", s.key(key), field, value)
+	return err
+}
+
+func (s *redisStore) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	_, err := s.do(ctx, append([]string{"HDEL", s.key(key)}, fields...)...)
+	return err
+}
+
+func (s *redisStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := s.do(ctx, "PEXPIRE", s.key(key), strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (s *redisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.drain()
+	return nil
+}
+
+// drain 关闭空闲连接，调用方持有 mu
+func (s *redisStore) drain() {
+	for {
+		select {
+		case c := <-s.idle:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
@@ -13,7 +13,7 @@ func SelectProxyFromCache(group *config.ProxyGroupConfig, now time.Time) *config
 		idx := (start + i) % n
 		proxy := group.Proxies[idx]
 		stats, ok := group.Stats.ProxyStats[proxy.Name]
-		if ok && stats.Alive &&
+		if ok && !proxy.Disabled && stats.Alive && !stats.IsHealthDown() &&
 			now.After(stats.CooldownUntil) &&
 			stats.ResponseTime > 0 {
 
@@ -1,9 +1,12 @@
 package lb
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/logger"
 )
 
@@ -16,6 +19,7 @@ func ConsumeRemainingResults(ch chan config.TestResult, count int, group *config
 
 	for i := 0; i < count; i++ {
 		res := <-ch
+		recordTestResult(group, res)
 		group.Stats.Lock()
 		stats := group.Stats.ProxyStats[res.Proxy.Name]
 		if stats == nil {
@@ -47,3 +51,13 @@ func ConsumeRemainingResults(ch chan config.TestResult, count int, group *config
 		group.Stats.Unlock()
 	}
 }
+
+// recordTestResult 将测速结果记入代理的探测历史，5xx 状态码按失败记录
+func recordTestResult(group *config.ProxyGroupConfig, res config.TestResult) {
+	err := res.Err
+	if err == nil && res.StatusCode >= http.StatusInternalServerError {
+		err = fmt.Errorf("HTTP 状态码 %d", res.StatusCode)
+	}
+	groupstats.RecordProbe(groupstats.GroupName(group), res.Proxy.Name,
+		groupstats.NewProbeRecord(groupstats.ProbeSpeedTest, res.ResponseTime, res.StatusCode, err))
+}
@@ -36,7 +36,7 @@ func SelectFastestProxy(group *config.ProxyGroupConfig, targetURL string, forceT
 
 		for _, proxy := range group.Proxies {
 			stats, ok := group.Stats.ProxyStats[proxy.Name]
-			if ok && now.Sub(stats.LastCheck) <= interval && stats.ResponseTime > 0 {
+			if ok && !proxy.Disabled && now.Sub(stats.LastCheck) <= interval && stats.ResponseTime > 0 {
 				// 缓存有效且测速过，认为是“可用的”
 				allNoRT = false
 			}
@@ -65,6 +65,12 @@ func SelectFastestProxy(group *config.ProxyGroupConfig, targetURL string, forceT
 			} else if stats.Alive && now.Before(stats.CooldownUntil) {
 				status = "🚫冷"
 			}
+			if stats.IsHealthDown() {
+				status = "⛔下"
+			}
+			if proxy.Disabled {
+				status = "⏸停"
+			}
 
 			cooldown := "无"
 			if stats.CooldownUntil.After(now) {
@@ -89,7 +95,7 @@ func SelectFastestProxy(group *config.ProxyGroupConfig, targetURL string, forceT
 			if !ok {
 				continue
 			}
-			if now.Before(stats.CooldownUntil) || !stats.Alive || stats.ResponseTime > maxAcceptableRT {
+			if proxy.Disabled || now.Before(stats.CooldownUntil) || !stats.Alive || stats.ResponseTime > maxAcceptableRT || stats.IsHealthDown() {
 				continue
 			}
 			if stats.ResponseTime < minTime && stats.ResponseTime > 0 {
@@ -118,7 +124,7 @@ func SelectFastestProxy(group *config.ProxyGroupConfig, targetURL string, forceT
 
 		group.Stats.Lock()
 		stats := group.Stats.ProxyStats[proxy.Name]
-		if stats != nil && now.Before(stats.CooldownUntil) {
+		if proxy.Disabled || stats != nil && (now.Before(stats.CooldownUntil) || stats.IsHealthDown()) {
 			group.Stats.Unlock()
 			continue
 		}
@@ -140,7 +146,7 @@ func SelectFastestProxy(group *config.ProxyGroupConfig, targetURL string, forceT
 				proxyCtx, proxyCancel := context.WithTimeout(context.Background(), config.DefaultDialTimeout)
 				defer proxyCancel()
 
-				client, err := p.CreateProxyClient(proxyCtx, &config.Cfg, proxy, group.IPv6)
+				client, err := p.CreateGroupProxyClient(proxyCtx, &config.Cfg, group, proxy)
 				if err != nil {
 					resultChan <- config.TestResult{Proxy: proxy, Err: err}
 					return
@@ -177,6 +183,7 @@ loop:
 	for i := 0; i < tested; i++ {
 		select {
 		case res := <-resultChan:
+			recordTestResult(group, res)
 			group.Stats.Lock()
 			stats := group.Stats.ProxyStats[res.Proxy.Name]
 			if stats == nil {
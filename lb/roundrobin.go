@@ -43,7 +43,7 @@ func SelectRoundRobinProxy(group *config.ProxyGroupConfig, targetURL string, for
 
 		for _, proxy := range group.Proxies {
 			stats, ok := group.Stats.ProxyStats[proxy.Name]
-			if ok && now.Sub(stats.LastCheck) <= interval && stats.ResponseTime > 0 {
+			if ok && !proxy.Disabled && now.Sub(stats.LastCheck) <= interval && stats.ResponseTime > 0 {
 				// 缓存有效且测速过，认为是“可用的”
 				allNoRT = false
 			}
@@ -73,6 +73,12 @@ func SelectRoundRobinProxy(group *config.ProxyGroupConfig, targetURL string, for
 			} else if stats.Alive && now.Before(stats.CooldownUntil) {
 				status = "🚫冷"
 			}
+			if stats.IsHealthDown() {
+				status = "⛔下"
+			}
+			if proxy.Disabled {
+				status = "⏸停"
+			}
 
 			cooldown := "无"
 			if stats.CooldownUntil.After(now) {
@@ -95,7 +101,7 @@ func SelectRoundRobinProxy(group *config.ProxyGroupConfig, targetURL string, for
 			idx := (start + i) % n
 			proxy := group.Proxies[idx]
 			stats, ok := group.Stats.ProxyStats[proxy.Name]
-			if !ok || !stats.Alive || now.Before(stats.CooldownUntil) {
+			if !ok || proxy.Disabled || !stats.Alive || now.Before(stats.CooldownUntil) || stats.IsHealthDown() {
 				continue
 			}
 
@@ -133,7 +139,7 @@ func SelectRoundRobinProxy(group *config.ProxyGroupConfig, targetURL string, for
 
 		group.Stats.Lock()
 		stats := group.Stats.ProxyStats[proxy.Name]
-		if stats != nil && now.Before(stats.CooldownUntil) {
+		if proxy.Disabled || stats != nil && (now.Before(stats.CooldownUntil) || stats.IsHealthDown()) {
 			group.Stats.Unlock()
 			continue
 		}
@@ -154,7 +160,7 @@ func SelectRoundRobinProxy(group *config.ProxyGroupConfig, targetURL string, for
 				proxyCtx, proxyCancel := context.WithTimeout(context.Background(), config.DefaultDialTimeout)
 				defer proxyCancel()
 
-				client, err := p.CreateProxyClient(proxyCtx, &config.Cfg, proxy, group.IPv6)
+				client, err := p.CreateGroupProxyClient(proxyCtx, &config.Cfg, group, proxy)
 				if err != nil {
 					resultChan <- config.TestResult{Proxy: proxy, Err: err}
 					return
@@ -191,6 +197,7 @@ LOOP:
 	for i := 0; i < tested; i++ {
 		select {
 		case res := <-resultChan:
+			recordTestResult(group, res)
 			group.Stats.Lock()
 			stats := group.Stats.ProxyStats[res.Proxy.Name]
 			if stats == nil {
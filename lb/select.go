@@ -2,12 +2,14 @@ package lb
 
 import (
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/groupstats"
 	"github.com/qist/tvgate/logger"
 	"strings"
+	"time"
 )
 
-// selectProxy 根据策略选择代理
-func SelectProxy(group *config.ProxyGroupConfig, targetURL string, forceTest bool) *config.ProxyConfig {
+// selectProxy 根据策略选择代理，clientKey（见 ClientKey）用于粘性绑定及 consistent-hash 等按客户端选择的策略
+func SelectProxy(group *config.ProxyGroupConfig, targetURL string, clientKey string, forceTest bool) *config.ProxyConfig {
 	config.LogConfigMutex.Lock()
 	defer config.LogConfigMutex.Unlock()
 
@@ -17,24 +19,92 @@ func SelectProxy(group *config.ProxyGroupConfig, targetURL string, forceTest boo
 	}
 	logger.LogPrintf("代理组中有 %d 个代理", len(group.Proxies))
 
+	if groupstats.GroupOverCap(group) {
+		logger.LogPrintf("⚠️ 代理组已超出流量上限，跳过")
+		return nil
+	}
+
 	if group.Stats == nil {
 		group.Stats = &config.GroupStats{
 			ProxyStats: make(map[string]*config.ProxyStats),
 		}
 	}
 
-	switch strings.ToLower(group.LoadBalance) {
+	// 粘性绑定：有效期内同一客户端继续使用上次的代理（重试测速时重新选择）
+	if group.StickyTTL > 0 && !forceTest {
+		group.Stats.Lock()
+		proxy := groupstats.StickyProxy(group, clientKey, time.Now())
+		group.Stats.Unlock()
+		if proxy != nil {
+			logger.LogPrintf("粘性绑定选择代理: %s", proxy.Name)
+			return proxy
+		}
+	}
+
+	proxy := selectByStrategy(group, targetURL, clientKey, forceTest)
+	groupstats.BindSticky(group, clientKey, proxy)
+	return proxy
+}
+
+// selectByStrategy 按负载均衡策略选择代理
+func selectByStrategy(group *config.ProxyGroupConfig, targetURL string, clientKey string, forceTest bool) *config.ProxyConfig {
+	strategy := strings.ToLower(group.LoadBalance)
+	switch strategy {
 	case "fastest":
 		proxy := SelectFastestProxy(group, targetURL, forceTest)
 		if proxy != nil {
 			logger.LogPrintf("选择最快的代理: %s", proxy.Name)
 		}
 		return proxy
-	default:
+	case "", "round-robin":
 		proxy := SelectRoundRobinProxy(group, targetURL, forceTest)
 		if proxy != nil {
 			logger.LogPrintf("轮询选择代理: %s", proxy.Name)
 		}
 		return proxy
 	}
+
+	selector, ok := groupstats.GetSelector(strategy)
+	if !ok {
+		logger.LogPrintf("⚠️ 未知负载均衡策略 %s，使用轮询", group.LoadBalance)
+		proxy := SelectRoundRobinProxy(group, targetURL, forceTest)
+		if proxy != nil {
+			logger.LogPrintf("轮询选择代理: %s", proxy.Name)
+		}
+		return proxy
+	}
+
+	proxy := selectWithSelector(group, selector, targetURL, clientKey, forceTest)
+	if proxy != nil {
+		logger.LogPrintf("按 %s 策略选择代理: %s", strategy, proxy.Name)
+	}
+	return proxy
+}
+
+// selectWithSelector 在可用代理中按策略选择；无可用代理或需要重新测速时先测速
+func selectWithSelector(group *config.ProxyGroupConfig, selector groupstats.Selector, targetURL, clientIP string, forceTest bool) *config.ProxyConfig {
+	var tested *config.ProxyConfig
+	if forceTest {
+		tested = SelectRoundRobinProxy(group, targetURL, true)
+	}
+
+	group.Stats.Lock()
+	candidates := groupstats.AvailableProxies(group, time.Now())
+	group.Stats.Unlock()
+
+	if len(candidates) == 0 && tested == nil {
+		// 尚无测速结果，通过测速刷新状态
+		tested = SelectRoundRobinProxy(group, targetURL, true)
+		group.Stats.Lock()
+		candidates = groupstats.AvailableProxies(group, time.Now())
+		group.Stats.Unlock()
+	}
+
+	if len(candidates) == 0 {
+		return tested
+	}
+
+	group.Stats.Lock()
+	defer group.Stats.Unlock()
+	return selector.Select(group, candidates, clientIP)
 }
@@ -0,0 +1,26 @@
+package lb
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/monitor"
+)
+
+// ClientKey 返回用于粘性绑定与一致性哈希的客户端标识：
+// sticky_by 为 token 且请求携带 token 时使用 token，否则使用客户端 IP
+func ClientKey(group *config.ProxyGroupConfig, r *http.Request) string {
+	if group != nil && strings.EqualFold(group.StickyBy, "token") {
+		config.CfgMu.RLock()
+		param := config.Cfg.GlobalAuth.TokenParamName
+		config.CfgMu.RUnlock()
+		if param == "" {
+			param = "my_token"
+		}
+		if token := r.URL.Query().Get(param); token != "" {
+			return "token:" + token
+		}
+	}
+	return monitor.GetClientIP(r)
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dedupKeyLimit 同时跟踪的日志格式数上限，超出后新的格式不再合并，避免以非常量格式调用时占用过多内存
+const dedupKeyLimit = 10000
+
+// dedupEntry 同一模块、同一格式的日志在当前窗口内的统计
+type dedupEntry struct {
+	printed    int    // 已输出条数
+	last       string // 最后输出的消息，完全相同的消息直接省略
+	suppressed int    // 省略的条数
+	lastSkip   string // 最后一条省略的消息，汇总时输出
+	level      string
+}
+
+// dedupState 重复日志合并：窗口从某格式第一次出现开始，窗口内与上一条完全相同的日志省略，
+// 同一格式最多输出 burst 条，窗口结束时输出一条汇总
+type dedupState struct {
+	mu      sync.Mutex
+	window  time.Duration
+	burst   int
+	entries map[string]*dedupEntry
+}
+
+var dedup = &dedupState{entries: make(map[string]*dedupEntry)}
+
+func (d *dedupState) configure(window time.Duration, burst int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = window
+	d.burst = max(burst, 1)
+}
+
+// allow 返回日志是否输出；首次省略时安排在窗口结束时输出汇总
+func (d *dedupState) allow(module, format, level, msg string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.window <= 0 {
+		return true
+	}
+
+	key := module + "\x00" + format
+	e, ok := d.entries[key]
+	if !ok {
+		if len(d.entries) >= dedupKeyLimit {
+			return true
+		}
+		d.entries[key] = &dedupEntry{printed: 1, last: msg, level: level}
+		time.AfterFunc(d.window, func() { d.flush(key, module) })
+		return true
+	}
+	if msg != e.last && e.printed < d.burst {
+		e.printed++
+		e.last = msg
+		return true
+	}
+	e.suppressed++
+	e.lastSkip = msg
+	e.level = level
+	return false
+}
+
+// flush 窗口结束：有省略的日志时输出汇总，并开始新的窗口
+func (d *dedupState) flush(key, module string) {
+	d.mu.Lock()
+	e := d.entries[key]
+	delete(d.entries, key)
+	window := d.window
+	d.mu.Unlock()
+
+	if e == nil || e.suppressed == 0 {
+		return
+	}
+	emit(e.level, module, fmt.Sprintf("%s（%s 内重复或相似的日志已省略 %d 条）", e.lastSkip, window, e.suppressed))
+}
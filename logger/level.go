@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+var levelRanks = map[string]int{
+	LevelDebug: 0,
+	LevelInfo:  1,
+	LevelWarn:  2,
+	LevelError: 3,
+}
+
+// levelConfig 全局级别与按模块覆盖的级别，整体替换，读取时无需加锁
+type levelConfig struct {
+	level   int
+	modules map[string]int // 模块前缀 -> 级别，如 stream、config/remote
+}
+
+var levels atomic.Pointer[levelConfig]
+
+func init() {
+	levels.Store(&levelConfig{level: levelRanks[LevelInfo]})
+}
+
+// ParseLevel 校验日志级别，空值按 info 处理
+func ParseLevel(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "" {
+		return LevelInfo, nil
+	}
+	if level == "warning" {
+		return LevelWarn, nil
+	}
+	if _, ok := levelRanks[level]; !ok {
+		return "", fmt.Errorf("日志级别应为 debug、info、warn 或 error: %s", level)
+	}
+	return level, nil
+}
+
+// SetLevels 设置全局级别与按模块覆盖的级别，modules 的值为空时删除该模块的覆盖
+func SetLevels(level string, modules map[string]string) error {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	lc := &levelConfig{level: levelRanks[lv], modules: make(map[string]int, len(modules))}
+	for module, l := range modules {
+		module = strings.Trim(module, "/")
+		if module == "" || strings.TrimSpace(l) == "" {
+			continue
+		}
+		if l, err = ParseLevel(l); err != nil {
+			return fmt.Errorf("模块 %s: %w", module, err)
+		}
+		lc.modules[module] = levelRanks[l]
+	}
+	levels.Store(lc)
+	return nil
+}
+
+// Levels 返回当前的全局级别与按模块覆盖的级别
+func Levels() (string, map[string]string) {
+	lc := levels.Load()
+	modules := make(map[string]string, len(lc.modules))
+	for module, rank := range lc.modules {
+		modules[module] = levelName(rank)
+	}
+	return levelName(lc.level), modules
+}
+
+func levelName(rank int) string {
+	for name, r := range levelRanks {
+		if r == rank {
+			return name
+		}
+	}
+	return LevelInfo
+}
+
+// minLevel 返回模块生效的最低级别，按最长的模块前缀匹配
+func (lc *levelConfig) minLevel(module string) int {
+	rank, matched := lc.level, -1
+	for prefix, r := range lc.modules {
+		if (module == prefix || strings.HasPrefix(module, prefix+"/")) && len(prefix) > matched {
+			rank, matched = r, len(prefix)
+		}
+	}
+	return rank
+}
+
+// lowest 返回全局与各模块级别中最低的级别，低于它的日志无需取调用方模块即可丢弃
+func (lc *levelConfig) lowest() int {
+	rank := lc.level
+	for _, r := range lc.modules {
+		rank = min(rank, r)
+	}
+	return rank
+}
@@ -2,6 +2,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
@@ -17,33 +18,126 @@ type LogConfig struct {
 	MaxBackups int
 	MaxAgeDays int
 	Compress   bool
+	Level      string            // debug、info、warn、error，默认 info
+	Format     string            // text 或 json，默认 text
+	Modules    map[string]string // 按模块覆盖的级别
+	// 重复日志合并：窗口内同一条日志只输出第一条，同一格式的日志最多输出 DedupBurst 条，
+	// 窗口结束时输出省略的条数；DedupWindow 为 0 不合并
+	DedupWindow time.Duration
+	DedupBurst  int
 }
 
 var logger = struct {
 	sync.RWMutex
 	enabled bool
+	json    bool
 	output  io.Writer
 }{
 	enabled: false,
 	output:  io.Discard,
 }
 
+// LogPrintf 按消息前缀推断级别（见 LevelOf）输出日志
 func LogPrintf(format string, v ...interface{}) {
+	logf("", format, v...)
+}
+
+// Debugf 输出 debug 级别日志，默认不输出，可在 log.level 或 log.modules 中按模块开启
+func Debugf(format string, v ...interface{}) { logf(LevelDebug, format, v...) }
+
+// Infof 输出 info 级别日志
+func Infof(format string, v ...interface{}) { logf(LevelInfo, format, v...) }
+
+// Warnf 输出 warn 级别日志
+func Warnf(format string, v ...interface{}) { logf(LevelWarn, format, v...) }
+
+// Errorf 输出 error 级别日志
+func Errorf(format string, v ...interface{}) { logf(LevelError, format, v...) }
+
+// logf level 为空时按消息内容推断；低于生效级别的日志在格式化之前丢弃
+func logf(level, format string, v ...interface{}) {
+	logger.RLock()
+	defer logger.RUnlock()
+	enabled := logger.enabled && logger.output != nil
+	if !enabled && subscribers.Load() == 0 {
+		return
+	}
+
+	lc := levels.Load()
+	if level != "" && levelRanks[level] < lc.lowest() {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if level == "" {
+		level = LevelOf(msg)
+	}
+	rank := levelRanks[level]
+	if rank < lc.lowest() {
+		return
+	}
+	module := callerModule(2)
+	if rank < lc.minLevel(module) {
+		return
+	}
+
+	if !dedup.allow(module, format, level, msg) {
+		return
+	}
+	emitLocked(time.Now(), level, module, msg)
+}
+
+// emit 输出一条已通过级别过滤的日志，用于重复日志的汇总
+func emit(level, module, msg string) {
 	logger.RLock()
 	defer logger.RUnlock()
+	emitLocked(time.Now(), level, module, msg)
+}
+
+// emitLocked 调用方需持有 logger 读锁
+func emitLocked(now time.Time, level, module, msg string) {
 	if logger.enabled && logger.output != nil {
-		fmt.Fprintf(logger.output, time.Now().Format("2006/01/02 15:04:05 ")+format+"\n", v...)
+		if logger.json {
+			line, _ := json.Marshal(struct {
+				Time    string `json:"time"`
+				Level   string `json:"level"`
+				Module  string `json:"module,omitempty"`
+				Message string `json:"msg"`
+			}{now.Format(time.RFC3339Nano), level, module, msg})
+			logger.output.Write(append(line, '\n'))
+		} else {
+			fmt.Fprint(logger.output, now.Format("2006/01/02 15:04:05 ")+msg+"\n")
+		}
 	}
+	// 同时保留在内存中，供 Web 实时日志页面查看
+	record(now, level, msg, module)
 }
 
-func SetupLogger(cfg LogConfig) {
+// CurrentFile 返回当前日志文件路径，输出到标准输出或未开启日志时为空
+func CurrentFile() string {
+	logger.RLock()
+	defer logger.RUnlock()
+	if lj, ok := logger.output.(*lumberjack.Logger); ok && logger.enabled {
+		return lj.Filename
+	}
+	return ""
+}
+
+// SetupLogger 按配置设置日志输出、格式与级别，级别无效时保持 info 并返回错误
+func SetupLogger(cfg LogConfig) error {
+	err := SetLevels(cfg.Level, cfg.Modules)
+	if err != nil {
+		SetLevels(LevelInfo, nil)
+	}
+	dedup.configure(cfg.DedupWindow, cfg.DedupBurst)
+
 	logger.Lock()
 	defer logger.Unlock()
 
+	logger.json = cfg.Format == "json"
 	if !cfg.Enabled {
 		logger.enabled = false
 		logger.output = io.Discard
-		return
+		return err
 	}
 
 	logger.enabled = true
@@ -58,4 +152,5 @@ func SetupLogger(cfg LogConfig) {
 			Compress:   cfg.Compress,
 		}
 	}
+	return err
 }
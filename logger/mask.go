@@ -0,0 +1,10 @@
+package logger
+
+// MaskToken 日志中只保留 token 的前几位，能通过日志查看器读到日志的用户无法拿到完整 token
+func MaskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	n := min(6, len(token)/2)
+	return token[:n] + "****"
+}
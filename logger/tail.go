@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 日志级别，LogPrintf 由消息前缀推断
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// recentSize 保留在内存中的最近日志条数，新订阅者先收到这些记录
+const recentSize = 1000
+
+// modulePrefix 模块名去掉的包路径前缀
+const modulePrefix = "github.com/qist/tvgate/"
+
+// Entry 一条日志记录，供 Web 实时日志页面使用
+type Entry struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Module  string    `json:"module"`
+	Message string    `json:"message"`
+}
+
+var tail = struct {
+	sync.Mutex
+	seq    uint64
+	recent []Entry // 环形缓冲区
+	next   int
+	subs   map[chan Entry]struct{}
+}{
+	subs: make(map[chan Entry]struct{}),
+}
+
+// subscribers 订阅者数量，无订阅者且未开启日志时跳过记录
+var subscribers atomic.Int32
+
+// record 记录一条日志并推送给订阅者；订阅者处理不过来时丢弃该条，不阻塞日志输出
+func record(t time.Time, level, msg, module string) {
+	tail.Lock()
+	defer tail.Unlock()
+
+	tail.seq++
+	e := Entry{Seq: tail.seq, Time: t, Level: level, Module: module, Message: msg}
+	if len(tail.recent) < recentSize {
+		tail.recent = append(tail.recent, e)
+	} else {
+		tail.recent[tail.next] = e
+	}
+	tail.next = (tail.next + 1) % recentSize
+
+	for ch := range tail.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Recent 返回内存中最近的日志，按时间从旧到新
+func Recent() []Entry {
+	tail.Lock()
+	defer tail.Unlock()
+	return recentLocked()
+}
+
+func recentLocked() []Entry {
+	out := make([]Entry, 0, len(tail.recent))
+	if len(tail.recent) < recentSize {
+		return append(out, tail.recent...)
+	}
+	out = append(out, tail.recent[tail.next:]...)
+	return append(out, tail.recent[:tail.next]...)
+}
+
+// Subscribe 订阅日志，返回订阅时已有的最近日志、后续日志的通道和取消订阅函数
+func Subscribe(buffer int) ([]Entry, <-chan Entry, func()) {
+	ch := make(chan Entry, buffer)
+	tail.Lock()
+	backlog := recentLocked()
+	tail.subs[ch] = struct{}{}
+	tail.Unlock()
+	subscribers.Add(1)
+
+	var once sync.Once
+	return backlog, ch, func() {
+		once.Do(func() {
+			tail.Lock()
+			delete(tail.subs, ch)
+			tail.Unlock()
+			subscribers.Add(-1)
+		})
+	}
+}
+
+// LevelOf 按消息前缀推断日志级别：❌/错误/Failed 为 error，⚠️/🚫 为 warn，DEBUG 为 debug，其余为 info
+func LevelOf(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, "DEBUG"), strings.HasPrefix(msg, "[DEBUG]"):
+		return LevelDebug
+	case strings.HasPrefix(msg, "❌"), strings.HasPrefix(msg, "错误"),
+		strings.HasPrefix(msg, "Failed"), strings.HasPrefix(msg, "Error"):
+		return LevelError
+	case strings.HasPrefix(msg, "⚠️"), strings.HasPrefix(msg, "🚫"):
+		return LevelWarn
+	}
+	return LevelInfo
+}
+
+// callerModule 返回调用 LogPrintf 的包路径（去掉模块前缀），如 stream、config/remote
+func callerModule(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	// 函数名形如 github.com/qist/tvgate/stream.(*Hub).run.func1，包路径到最后一个 / 之后的第一个 .
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	return strings.TrimPrefix(name, modulePrefix)
+}
@@ -0,0 +1,326 @@
+// Package logo 频道图标缓存：按频道名查找图标地址，拉取后缩放为 PNG 并缓存到磁盘，
+// 在 /logo/<频道>.png 提供给播放器，源站慢或无法访问时继续使用已缓存的图标
+package logo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/epg"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/httperror"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+const (
+	// maxLogoSize 单个图标的最大下载大小
+	maxLogoSize = 5 << 20
+	// playlistRefresh 重新读取播放列表中 tvg-logo 的间隔
+	playlistRefresh = time.Hour
+)
+
+// call 同一图标同时只拉取一次，其他请求等待结果
+type call struct {
+	done chan struct{}
+	err  error
+}
+
+// Manager 图标地址查找与缓存
+type Manager struct {
+	mu       sync.RWMutex
+	cfg      config.LogoConfig
+	stop     chan struct{}
+	fromList map[string]string // 播放列表中的 tvg-logo，按小写的 tvg-id、tvg-name 和频道名索引
+
+	fetchMu  sync.Mutex
+	inflight map[string]*call
+}
+
+// New 创建未启用的图标管理器
+func New() *Manager { return &Manager{inflight: make(map[string]*call)} }
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.LogoConfig) { Default.Configure(cfg) }
+
+// defaultDir 默认缓存目录，与配置文件放在同一目录
+func defaultDir() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "logo_cache")
+}
+
+// Dir 返回配置对应的缓存目录
+func Dir(cfg config.LogoConfig) string {
+	if cfg.Dir != "" {
+		return cfg.Dir
+	}
+	return defaultDir()
+}
+
+// Configure 应用配置：配置了播放列表时定期读取其中的 tvg-logo，禁用时停止
+func (m *Manager) Configure(cfg config.LogoConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	if !cfg.Enabled || len(cfg.Playlists) == 0 {
+		m.fromList = nil
+		return
+	}
+	m.stop = make(chan struct{})
+	go m.run(m.stop, cfg.Playlists, cfg.Timeout)
+}
+
+func (m *Manager) run(stop chan struct{}, playlists []string, timeout time.Duration) {
+	ticker := time.NewTicker(playlistRefresh)
+	defer ticker.Stop()
+	for {
+		logos := make(map[string]string)
+		for _, src := range playlists {
+			if err := readPlaylist(src, timeout, logos); err != nil {
+				logger.LogPrintf("⚠️ 读取播放列表 %s 中的图标失败: %v", src, err)
+			}
+		}
+		m.mu.Lock()
+		if m.stop == stop {
+			m.fromList = logos
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readPlaylist 读取播放列表中的 tvg-logo，按 tvg-id、tvg-name 和频道名记录，先出现的为准
+func readPlaylist(src string, timeout time.Duration, logos map[string]string) error {
+	entries, err := m3u.Load(src, timeout)
+	for _, e := range entries {
+		logo := e.Attrs["tvg-logo"]
+		if logo == "" {
+			continue
+		}
+		for _, k := range []string{e.Attrs["tvg-id"], e.Attrs["tvg-name"], e.Name} {
+			k = strings.ToLower(strings.TrimSpace(k))
+			if _, ok := logos[k]; k != "" && !ok {
+				logos[k] = logo
+			}
+		}
+	}
+	return err
+}
+
+// Source 查找频道的图标地址：logos 配置、播放列表的 tvg-logo、节目单的 icon，均不区分大小写
+func (m *Manager) Source(channel string) string {
+	key := strings.ToLower(strings.TrimSpace(channel))
+	m.mu.RLock()
+	cfg, fromList := m.cfg, m.fromList
+	m.mu.RUnlock()
+	if u := cfg.Logos[channel]; u != "" {
+		return u
+	}
+	for name, u := range cfg.Logos {
+		if strings.ToLower(name) == key {
+			return u
+		}
+	}
+	if u := fromList[key]; u != "" {
+		return u
+	}
+	return epg.Default.Icon(channel)
+}
+
+// Handler 提供 /logo/<频道>.png，扩展名可省略
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			httperror.Error(w, r, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := path.Base(r.URL.Path)
+		if ext := path.Ext(name); ext != "" {
+			name = strings.TrimSuffix(name, ext)
+		}
+		src := m.Source(name)
+		if src == "" {
+			httperror.Error(w, r, "Not Found", http.StatusNotFound)
+			return
+		}
+
+		m.mu.RLock()
+		cfg := m.cfg
+		m.mu.RUnlock()
+		file, err := m.cached(cfg, src)
+		if err != nil {
+			logger.LogPrintf("⚠️ 获取频道 %s 的图标失败: %v", name, err)
+			httperror.Error(w, r, "Logo unavailable", http.StatusBadGateway)
+			return
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			httperror.Error(w, r, "Logo unavailable", http.StatusBadGateway)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			httperror.Error(w, r, "Logo unavailable", http.StatusBadGateway)
+			return
+		}
+		// 只提供转换后的 PNG，旧版本原样缓存的 SVG 等格式不再返回，避免与管理界面同源时执行脚本
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		if http.DetectContentType(head[:n]) != "image/png" {
+			httperror.Error(w, r, "Logo unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	})
+}
+
+// cached 返回图标的缓存文件，不存在或过期时拉取；拉取失败但有旧缓存时返回旧缓存
+func (m *Manager) cached(cfg config.LogoConfig, src string) (string, error) {
+	sum := sha1.Sum([]byte(src))
+	file := filepath.Join(Dir(cfg), hex.EncodeToString(sum[:10])+"_"+strconv.Itoa(cfg.Size))
+	info, statErr := os.Stat(file)
+	if statErr == nil && time.Since(info.ModTime()) < cfg.TTL {
+		return file, nil
+	}
+
+	m.fetchMu.Lock()
+	c, ok := m.inflight[file]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		m.inflight[file] = c
+		go func() {
+			c.err = fetch(cfg, src, file)
+			m.fetchMu.Lock()
+			delete(m.inflight, file)
+			m.fetchMu.Unlock()
+			close(c.done)
+		}()
+	}
+	m.fetchMu.Unlock()
+	<-c.done
+
+	if c.err != nil {
+		if statErr == nil {
+			return file, nil
+		}
+		return "", c.err
+	}
+	return file, nil
+}
+
+// fetch 下载图标，缩放并转为 PNG；无法解码的格式（如 SVG、WebP）不缓存
+func fetch(cfg config.LogoConfig, src, file string) error {
+	var rc io.ReadCloser
+	var err error
+	if configured(cfg, src) {
+		rc, err = m3u.Open(src, cfg.Timeout)
+	} else if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		// 播放列表和节目单来自外部，只能访问公网地址
+		rc, err = m3u.Get(publicClient, src, cfg.Timeout)
+	} else {
+		// 播放列表和节目单来自外部，其中的本地路径不能读取
+		return fmt.Errorf("图标地址 %s 不是 http(s) 地址", src)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxLogoSize+1))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > maxLogoSize {
+		return fmt.Errorf("图标超过 %d 字节", maxLogoSize)
+	}
+	data, err = toPNG(data, cfg.Size)
+	if err != nil {
+		return fmt.Errorf("无法转换图标（支持 PNG、JPEG、GIF）: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// configured 图标地址是否直接配置在 logos 中
+func configured(cfg config.LogoConfig, src string) bool {
+	for _, u := range cfg.Logos {
+		if u == src {
+			return true
+		}
+	}
+	return false
+}
+
+// publicClient 拉取播放列表和节目单中的图标，连接时检查解析后的地址，
+// 重定向或 DNS 重绑定到内网地址同样被拒绝；logos 中配置的地址不受限制
+var publicClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+					return fmt.Errorf("图标地址指向内网或本机地址 %s", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// publicIP 是否为公网单播地址
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast() && !cgnat.Contains(ip)
+}
+
+// cgnat 运营商级 NAT 共享地址 100.64.0.0/10
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
@@ -0,0 +1,69 @@
+package logo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+)
+
+// maxLogoPixels 解码前按图片头检查的最大像素数，防止很小的文件解码后占用大量内存
+const maxLogoPixels = 4096 * 4096
+
+// toPNG 解码图标，最长边超过 size 时等比缩小，编码为 PNG
+func toPNG(data []byte, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxLogoPixels {
+		return nil, fmt.Errorf("图标尺寸 %dx%d 超过限制", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, shrink(img, size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// shrink 按区域平均等比缩小，图标尺寸不大，逐像素处理足够快
+func shrink(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if size <= 0 || (w <= size && h <= size) {
+		return src
+	}
+	nw, nh := size, size
+	if w > h {
+		nh = max(1, h*size/w)
+	} else {
+		nw = max(1, w*size/h)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0, y1 := b.Min.Y+y*h/nh, b.Min.Y+(y+1)*h/nh
+		for x := 0; x < nw; x++ {
+			x0, x1 := b.Min.X+x*w/nw, b.Min.X+(x+1)*w/nw
+			// 预乘 alpha 后求平均，避免透明像素的颜色渗入边缘
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
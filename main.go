@@ -9,21 +9,51 @@ import (
 	"os/signal"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/cloudflare/tableflip"
+	"github.com/qist/tvgate/accesslog"
+	"github.com/qist/tvgate/alert"
+	"github.com/qist/tvgate/audit"
 	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/cast"
+	"github.com/qist/tvgate/dvr"
+	"github.com/qist/tvgate/cluster"
+	"github.com/qist/tvgate/bruteforce"
 	"github.com/qist/tvgate/clear"
+	"github.com/qist/tvgate/cli"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/config/history"
 	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/config/remote"
 	"github.com/qist/tvgate/config/watch"
+	"github.com/qist/tvgate/dlna"
 	"github.com/qist/tvgate/dns"
+	"github.com/qist/tvgate/epg"
 	"github.com/qist/tvgate/groupstats"
+	"github.com/qist/tvgate/ingest"
+	"github.com/qist/tvgate/jx"
+	"github.com/qist/tvgate/kvstore"
 	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/logo"
+	"github.com/qist/tvgate/metrics"
 	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/probe"
 	"github.com/qist/tvgate/publisher"
+	"github.com/qist/tvgate/quota"
+	"github.com/qist/tvgate/refresh"
 	"github.com/qist/tvgate/server"
+	"github.com/qist/tvgate/stats"
+	"github.com/qist/tvgate/stream"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/updater"
+	httpclient "github.com/qist/tvgate/utils/http"
+	"github.com/qist/tvgate/utils/httperror"
+	"github.com/qist/tvgate/utils/systemd"
+	"github.com/qist/tvgate/utils/upgrade"
 	"github.com/qist/tvgate/web"
 )
 
@@ -42,14 +72,31 @@ var taskPool = sync.Pool{
 var shutdownMux sync.Mutex
 var shutdownOnce sync.Once
 
+// upgraded 新进程已接管，旧进程退出时不再通知 systemd 停止
+var upgraded atomic.Bool
+
 func main() {
+	root := cli.Root(serve)
+	flag.Usage = root.PrintUsage
 	flag.Parse()
 
-	if *config.VersionFlag {
-		fmt.Println("程序版本:", config.Version)
-		return
+	// 兼容旧的选项写法：-version、-check、-schema、-dump-default
+	args := flag.Args()
+	switch {
+	case *config.VersionFlag:
+		args = []string{"version"}
+	case *config.CheckFlag:
+		args = []string{"check"}
+	case *config.SchemaFlag:
+		args = []string{"schema"}
+	case *config.DumpDefault:
+		args = []string{"dump-default"}
 	}
+	os.Exit(root.Execute(args))
+}
 
+// serve 启动服务，服务退出后返回
+func serve(args []string) int {
 	// -------------------------
 	// 初始化 tableflip Upgrader（仅非 Windows 平台）
 	// -------------------------
@@ -77,6 +124,15 @@ func main() {
 	*config.ConfigFilePath = configFilePath
 	fmt.Println("使用配置文件:", configFilePath)
 
+	// 远程配置：启动时先同步到本地文件，无法访问时使用本地缓存
+	if *config.RemoteConfig != "" {
+		if ok, err := remote.Sync(*config.RemoteConfig, configFilePath, *config.RemoteInterval); err != nil {
+			fmt.Println("⚠️ 远程配置不可用，使用本地缓存:", err)
+		} else if ok {
+			fmt.Println("✅ 已从远程配置更新:", configFilePath)
+		}
+	}
+
 	if err := load.LoadConfig(configFilePath); err != nil {
 		log.Fatalf("加载配置文件失败: %v", err)
 	}
@@ -96,6 +152,7 @@ func main() {
 	// 初始化代理组统计
 	// -------------------------
 	groupstats.InitProxyGroups()
+	groupstats.LoadGroupUsage()
 	for _, group := range config.Cfg.ProxyGroups {
 		group.Stats = &config.GroupStats{
 			ProxyStats: make(map[string]*config.ProxyStats),
@@ -118,6 +175,48 @@ func main() {
 		auth.GlobalTokenManager = nil
 	}
 
+	// 可信反向代理，用于解析 X-Forwarded-For
+	if err := monitor.SetTrustedProxies(config.Cfg.Access.TrustedProxies); err != nil {
+		logger.LogPrintf("❌ %v", err)
+	}
+
+	// 暴力破解防护
+	bruteforce.Configure(config.Cfg.BruteForce)
+	kvstore.Configure(config.Cfg.Store)
+
+	// token 使用审计日志
+	audit.Configure(config.Cfg.Audit)
+
+	// token 流量配额
+	quota.Configure(config.Cfg.Quota)
+	connlimit.Configure(config.Cfg.ConnLimit)
+	accesslog.Configure(config.Cfg.AccessLog)
+	alert.Configure(config.Cfg.Alert)
+	tracing.Configure(config.Cfg.Tracing)
+	metrics.Configure(config.Cfg.Metrics)
+	epg.Configure(config.Cfg.EPG)
+	logo.Configure(config.Cfg.Logo)
+	dlna.Configure(&config.Cfg)
+	cast.Configure(&config.Cfg)
+	dvr.Configure(&config.Cfg)
+	cluster.Configure(&config.Cfg)
+	probe.Configure(config.Cfg.Probe)
+	ingest.Configure(config.Cfg.Ingest)
+	refresh.Configure(config.Cfg.Refresh)
+	httperror.Configure(config.Cfg.ErrorPages)
+	stream.SetCacheMemoryLimit(config.Cfg.Server.CacheMemoryMB)
+	// 网卡变化后自动重新加入组播组
+	stream.WatchInterfaces()
+	web.ApplyDebug(config.Cfg.Debug)
+	quota.Default.Load()
+
+	// 频道与代理组历史统计
+	stats.Configure(config.Cfg.Stats)
+
+	// 配置版本历史，记录启动时的配置
+	history.Configure(config.Cfg.History)
+	history.Default.RecordFile("startup", configFilePath, nil)
+
 	tm := &auth.TokenManager{
 		Enabled:       true,
 		StaticTokens:  make(map[string]*auth.SessionInfo),
@@ -149,6 +248,12 @@ func main() {
 	stopCleaner := make(chan struct{})
 	stopAccessCleaner := make(chan struct{})
 	stopProxyStats := make(chan struct{})
+	stopHealthCheck := make(chan struct{})
+	stopGroupUsage := make(chan struct{})
+	stopQuotaUsage := make(chan struct{})
+	stopRemoteConfig := make(chan struct{})
+	stopWatchdog := make(chan struct{})
+	stopUpdateCheck := make(chan struct{})
 
 	startTask := func(f func()) {
 		task := taskPool.Get().(*mainTask)
@@ -167,18 +272,20 @@ func main() {
 	startTask(func() { clear.StartRedirectChainCleaner(10*time.Minute, 30*time.Minute, stopCleaner) })
 	startTask(func() { clear.StartAccessCacheCleaner(10*time.Minute, 30*time.Minute, stopAccessCleaner) })
 	startTask(func() { clear.StartGlobalProxyStatsCleaner(10*time.Minute, 2*time.Hour, stopProxyStats) })
+	startTask(func() { groupstats.StartHealthChecker(5*time.Second, stopHealthCheck) })
+	startTask(func() { groupstats.StartGroupUsageSaver(time.Minute, stopGroupUsage) })
+	startTask(func() { quota.Default.StartSaver(time.Minute, stopQuotaUsage) })
+	startTask(func() { updater.StartAutoCheck(stopUpdateCheck) })
+	if *config.RemoteConfig != "" {
+		startTask(func() {
+			remote.Watch(*config.RemoteConfig, configFilePath, *config.RemoteInterval, stopRemoteConfig)
+		})
+	}
 
 	// -------------------------
 	// 日志
 	// -------------------------
-	logger.SetupLogger(logger.LogConfig{
-		Enabled:    config.Cfg.Log.Enabled,
-		File:       config.Cfg.Log.File,
-		MaxSizeMB:  config.Cfg.Log.MaxSizeMB,
-		MaxBackups: config.Cfg.Log.MaxBackups,
-		MaxAgeDays: config.Cfg.Log.MaxAgeDays,
-		Compress:   config.Cfg.Log.Compress,
-	})
+	load.SetupLogger()
 
 	// -------------------------
 	// 启动配置文件监控
@@ -204,25 +311,19 @@ func main() {
 	// 启动 HTTP Server（支持 tableflip 热更）
 	// -------------------------
 	var wg sync.WaitGroup
-	startServer := func(port int) {
+	startServer := func(addr string) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			addr := fmt.Sprintf(":%d", port)
 			if err := server.StartHTTPServer(config.ServerCtx, addr, upg); err != nil && err != context.Canceled {
 				logger.LogPrintf("❌ 启动 HTTP 服务失败 %s: %v", addr, err)
 			}
 		}()
 	}
 
-	if config.Cfg.Server.Port > 0 {
-		startServer(config.Cfg.Server.Port)
-	}
-	if config.Cfg.Server.HTTPPort > 0 {
-		startServer(config.Cfg.Server.HTTPPort)
-	}
-	if config.Cfg.Server.TLS.HTTPSPort > 0 {
-		startServer(config.Cfg.Server.TLS.HTTPSPort)
+	// port、http_port、tls.https_port 与 listeners 中的全部地址
+	for _, addr := range server.ListenAddrs(&config.Cfg) {
+		startServer(addr)
 	}
 
 	wg.Wait() // 阻塞等待所有 server
@@ -236,7 +337,7 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		fmt.Println("收到退出信号，开始优雅退出")
-		gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopActiveClients, stopStartSystemStatsUpdater)
+		gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopHealthCheck, stopGroupUsage, stopQuotaUsage, stopRemoteConfig, stopActiveClients, stopStartSystemStatsUpdater, stopWatchdog, stopUpdateCheck)
 		if !isWindows && upg != nil {
 			upg.Exit()
 		} else {
@@ -258,17 +359,42 @@ func main() {
 		if err := upg.Ready(); err != nil {
 			log.Fatalf("升级器准备失败: %v", err)
 		}
+
+		// SIGHUP 热更新监听（upgrade 命令、管理界面升级与 systemctl reload），新进程就绪后旧进程退出
+		upgrade.Attach(upg)
+		upgrade.StartListener(nil)
+		startTask(func() {
+			<-upg.Exit()
+			upgraded.Store(true)
+			logger.LogPrintf("🔄 新进程已接管，旧进程退出")
+			gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopHealthCheck, stopGroupUsage, stopQuotaUsage, stopRemoteConfig, stopActiveClients, stopStartSystemStatsUpdater, stopWatchdog, stopUpdateCheck)
+		})
 	}
 
+	// -------------------------
+	// systemd 启动完成通知与看门狗（Type=notify、WatchdogSec）
+	// -------------------------
+	server.SetReady(true)
+	systemd.Ready()
+	startTask(func() { systemd.StartWatchdog(watchdogHealthy, stopWatchdog) })
+
 	<-config.ServerCtx.Done()
-	gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopActiveClients, stopStartSystemStatsUpdater)
+	gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopHealthCheck, stopGroupUsage, stopQuotaUsage, stopRemoteConfig, stopActiveClients, stopStartSystemStatsUpdater, stopWatchdog, stopUpdateCheck)
+	return 0
 }
 
-func gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopActiveClients, stopStartSystemStatsUpdater chan struct{}) {
+func gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopHealthCheck, stopGroupUsage, stopQuotaUsage, stopRemoteConfig, stopActiveClients, stopStartSystemStatsUpdater, stopWatchdog, stopUpdateCheck chan struct{}) {
 	shutdownOnce.Do(func() {
 		shutdownMux.Lock()
 		defer shutdownMux.Unlock()
 
+		server.SetReady(false)
+		if !upgraded.Load() {
+			systemd.Stopping()
+		}
+		close(stopWatchdog)
+		close(stopUpdateCheck)
+
 		if config.Cancel != nil {
 			config.Cancel()
 		}
@@ -279,10 +405,25 @@ func gracefulShutdown(stopCleaner, stopAccessCleaner, stopProxyStats, stopActive
 		close(stopCleaner)
 		close(stopAccessCleaner)
 		close(stopProxyStats)
+		close(stopHealthCheck)
+		close(stopGroupUsage)
+		groupstats.SaveGroupUsage()
+		close(stopQuotaUsage)
+		quota.Default.Save()
+		httpclient.SaveCookies()
+		jx.CloseBrowsers()
+		dvr.Close()
+		stats.Close()
+		close(stopRemoteConfig)
 		close(stopActiveClients)
 		close(stopStartSystemStatsUpdater)
 
 		time.Sleep(100 * time.Millisecond)
 		fmt.Println("优雅退出完成")
 	})
-}
\ No newline at end of file
+}
+
+// watchdogHealthy 看门狗自检：配置锁长时间无法获取说明主要流程已卡住（如死锁），此时停止心跳由 systemd 重启
+func watchdogHealthy() bool {
+	return server.Alive(5 * time.Second)
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// point 一组同名、同标签的指标
+type point struct {
+	name   string
+	tags   map[string]string
+	fields []field
+}
+
+// field 指标值，value 为 int64、uint64 或 float64
+type field struct {
+	key   string
+	value any
+}
+
+func (p *point) add(key string, value any) {
+	p.fields = append(p.fields, field{key, value})
+}
+
+// sortedTags 合并全局标签与指标自身标签（同名时以指标自身为准），按键排序，忽略空值
+func sortedTags(global, own map[string]string) [][2]string {
+	merged := make(map[string]string, len(global)+len(own))
+	for k, v := range global {
+		merged[k] = v
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+	tags := make([][2]string, 0, len(merged))
+	for k, v := range merged {
+		if k != "" && v != "" {
+			tags = append(tags, [2]string{k, v})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	return tags
+}
+
+func formatValue(v any, influx bool) string {
+	switch n := v.(type) {
+	case int64:
+		if influx {
+			return strconv.FormatInt(n, 10) + "i"
+		}
+		return strconv.FormatInt(n, 10)
+	case uint64:
+		if influx {
+			return strconv.FormatUint(n, 10) + "i"
+		}
+		return strconv.FormatUint(n, 10)
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return "0"
+}
+
+var (
+	influxNameEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper  = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// encodeInflux 按 InfluxDB 行协议编码：measurement,tag=v field=1i,field=0.5 时间戳(ns)
+func encodeInflux(buf *bytes.Buffer, prefix string, global map[string]string, points []point, now time.Time) {
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	for _, p := range points {
+		if len(p.fields) == 0 {
+			continue
+		}
+		name := p.name
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		buf.WriteString(influxNameEscaper.Replace(name))
+		for _, t := range sortedTags(global, p.tags) {
+			buf.WriteByte(',')
+			buf.WriteString(influxTagEscaper.Replace(t[0]))
+			buf.WriteByte('=')
+			buf.WriteString(influxTagEscaper.Replace(t[1]))
+		}
+		for i, f := range p.fields {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(influxTagEscaper.Replace(f.key))
+			buf.WriteByte('=')
+			buf.WriteString(formatValue(f.value, true))
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(ts)
+		buf.WriteByte('\n')
+	}
+}
+
+// graphiteEscaper Graphite 路径与标签中不允许出现的字符
+var graphiteEscaper = strings.NewReplacer(" ", "_", ";", "_", "\n", "_", "\t", "_")
+
+// encodeGraphite 按 Graphite 文本协议编码，标签使用 Graphite 1.1 起支持的 ;tag=value 形式：
+// prefix.measurement.field;tag=v 值 时间戳(s)
+func encodeGraphite(buf *bytes.Buffer, prefix string, global map[string]string, points []point, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	for _, p := range points {
+		var tags strings.Builder
+		for _, t := range sortedTags(global, p.tags) {
+			tags.WriteByte(';')
+			tags.WriteString(graphiteEscaper.Replace(strings.ReplaceAll(t[0], "=", "_")))
+			tags.WriteByte('=')
+			// 标签值不能以 ~ 开头
+			tags.WriteString(strings.TrimLeft(graphiteEscaper.Replace(t[1]), "~"))
+		}
+		base := graphiteEscaper.Replace(p.name)
+		if prefix != "" {
+			base = graphiteEscaper.Replace(prefix) + "." + base
+		}
+		for _, f := range p.fields {
+			buf.WriteString(base)
+			buf.WriteByte('.')
+			buf.WriteString(graphiteEscaper.Replace(f.key))
+			buf.WriteString(tags.String())
+			buf.WriteByte(' ')
+			buf.WriteString(formatValue(f.value, false))
+			buf.WriteByte(' ')
+			buf.WriteString(ts)
+			buf.WriteByte('\n')
+		}
+	}
+}
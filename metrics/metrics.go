@@ -0,0 +1,230 @@
+// Package metrics 指标推送：定时采集系统、频道与代理组指标，以 InfluxDB 行协议或 Graphite 文本协议
+// 推送到 InfluxDB（HTTP / UDP）、Telegraf、Graphite carbon 等，供不使用 Prometheus 拉取的监控系统使用
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/groupstats"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// udpPayload 单个 UDP 包的最大长度，避免被分片
+const udpPayload = 1400
+
+// Exporter 定时采集并推送指标
+type Exporter struct {
+	mu       sync.Mutex
+	cfg      config.MetricsConfig
+	stop     chan struct{}
+	interval time.Duration
+	failing  bool // 上次推送失败，恢复时记录一次日志
+}
+
+// New 创建未启用的指标推送器
+func New() *Exporter { return &Exporter{} }
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.MetricsConfig) { Default.Configure(cfg) }
+
+// Configure 应用配置：启用时按推送间隔启动推送，禁用时停止
+func (e *Exporter) Configure(cfg config.MetricsConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cfg = cfg
+	if !cfg.Enabled || cfg.Address == "" {
+		if e.stop != nil {
+			close(e.stop)
+			e.stop = nil
+		}
+		return
+	}
+	if e.stop == nil || e.interval != cfg.Interval {
+		if e.stop != nil {
+			close(e.stop)
+		}
+		e.stop = make(chan struct{})
+		e.interval = cfg.Interval
+		go e.run(e.stop, cfg.Interval)
+	}
+}
+
+func (e *Exporter) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.push(stop, time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// push 采集一次并推送，失败只在首次和恢复时记录日志，避免推送目标故障时刷屏
+func (e *Exporter) push(stop chan struct{}, now time.Time) {
+	e.mu.Lock()
+	if e.stop != stop {
+		e.mu.Unlock()
+		return
+	}
+	cfg := e.cfg
+	e.mu.Unlock()
+
+	var buf bytes.Buffer
+	if cfg.Format == "graphite" {
+		encodeGraphite(&buf, cfg.Prefix, cfg.Tags, collect(), now)
+	} else {
+		encodeInflux(&buf, cfg.Prefix, cfg.Tags, collect(), now)
+	}
+	err := send(cfg, buf.Bytes())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil && !e.failing:
+		e.failing = true
+		logger.LogPrintf("❌ 推送指标到 %s 失败: %v", cfg.Address, err)
+	case err == nil && e.failing:
+		e.failing = false
+		logger.LogPrintf("✅ 指标推送已恢复: %s", cfg.Address)
+	}
+}
+
+// collect 采集系统、程序自身、频道与代理组指标；累计值直接推送，由监控系统计算速率
+func collect() []point {
+	ts := monitor.GlobalTrafficStats.GetTrafficStats()
+
+	system := point{name: "system"}
+	system.add("cpu_percent", ts.CPUUsage)
+	system.add("memory_used", ts.MemoryUsage)
+	system.add("memory_total", ts.MemoryTotal)
+	system.add("disk_used", ts.DiskUsage)
+	system.add("disk_total", ts.DiskTotal)
+	system.add("load1", ts.LoadAverage.Load1)
+	system.add("inbound_bandwidth", ts.InboundBandwidth)
+	system.add("outbound_bandwidth", ts.OutboundBandwidth)
+
+	app := point{name: "app"}
+	app.add("cpu_percent", ts.App.CPUPercent)
+	app.add("memory_used", ts.App.MemoryUsage)
+	app.add("inbound_bytes", ts.App.InboundBytes)
+	app.add("outbound_bytes", ts.App.OutboundBytes)
+	app.add("inbound_bandwidth", ts.App.InboundBandwidth)
+	app.add("outbound_bandwidth", ts.App.OutboundBandwidth)
+	app.add("active_connections", ts.ActiveConnections)
+	app.add("total_connections", ts.TotalConnections)
+	app.add("clients", int64(len(monitor.ActiveClients.GetAll())))
+	cache := monitor.GetCacheMemory()
+	app.add("cache_memory_used", cache.Used)
+	app.add("cache_memory_limit", cache.Limit)
+	app.add("cache_evicted_frames", cache.Evicted)
+
+	points := []point{system, app}
+	for _, h := range monitor.GetTopHubs("metrics", 0) {
+		p := point{name: "channel", tags: map[string]string{"channel": h.Name, "type": h.Type}}
+		p.add("clients", int64(h.Clients))
+		p.add("packets", h.Packets)
+		p.add("bytes", h.Bytes)
+		p.add("drops", h.Drops)
+		p.add("watchdog_rejoins", h.Rejoins)
+		p.add("buffer_bytes", h.BufferBytes)
+		p.add("busy_percent", h.BusyPercent)
+		points = append(points, p)
+	}
+	for _, g := range groupstats.GetGroupUsage() {
+		p := point{name: "proxy_group", tags: map[string]string{"group": g.Name}}
+		p.add("day_bytes", g.DayBytes)
+		p.add("month_bytes", g.MonthBytes)
+		p.add("total_bytes", g.TotalBytes)
+		points = append(points, p)
+	}
+	return points
+}
+
+// send 按地址协议推送：http(s) 以 POST 写入，tcp 每次建立连接，udp 按行拆分为多个包
+func send(cfg config.MetricsConfig, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return sendHTTP(cfg, data)
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", u.Host, cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(cfg.Timeout))
+		_, err = conn.Write(data)
+		return err
+	case "udp":
+		conn, err := net.DialTimeout("udp", u.Host, cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		for len(data) > 0 {
+			n := len(data)
+			if n > udpPayload {
+				// 在最后一个完整行处截断，单行超长时整行发送
+				n = bytes.LastIndexByte(data[:udpPayload], '\n') + 1
+				if n == 0 {
+					n = bytes.IndexByte(data, '\n') + 1
+					if n == 0 {
+						n = len(data)
+					}
+				}
+			}
+			if _, err := conn.Write(data[:n]); err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的协议 %q", u.Scheme)
+}
+
+func sendHTTP(cfg config.MetricsConfig, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Address, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
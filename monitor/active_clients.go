@@ -1,8 +1,15 @@
 package monitor
 
 import (
+	"context"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/qist/tvgate/audit"
+	"github.com/qist/tvgate/quota"
 )
 
 // ClientConnection 表示一个客户端连接
@@ -16,6 +23,16 @@ type ClientConnection struct {
 	IsMobile       bool
 	ConnectedAt    time.Time
 	LastActive     time.Time
+	Token          string `json:"-"` // 使用的 token，用于统计会话数
+	TrustedIP      string `json:"-"` // 按 TrustedClientIP 识别的客户端 IP，用于按 IP 限制会话数，为空时同 IP
+
+	cancel context.CancelFunc // 断开连接，见 Kick
+	bytes  int64              // 发送给客户端的字节数，见 CountBytes
+}
+
+// Bytes 返回已发送给客户端的字节数
+func (c *ClientConnection) Bytes() int64 {
+	return atomic.LoadInt64(&c.bytes)
 }
 
 // ActiveConnectionsManager 管理活跃客户端
@@ -35,6 +52,9 @@ func (m *ActiveConnectionsManager) Register(connID string, conn *ClientConnectio
 	defer m.mu.Unlock()
 
 	conn.ID = connID
+	if conn.TrustedIP == "" {
+		conn.TrustedIP = conn.IP
+	}
 	if existing, ok := m.conns[connID]; ok {
 		// 已存在，更新
 		existing.URL = conn.URL
@@ -42,13 +62,36 @@ func (m *ActiveConnectionsManager) Register(connID string, conn *ClientConnectio
 		existing.Referer = conn.Referer
 		existing.ConnectionType = conn.ConnectionType
 		existing.IsMobile = conn.IsMobile
+		existing.Token = conn.Token
+		existing.TrustedIP = conn.TrustedIP
 		existing.LastActive = time.Now()
 	} else {
 		// 新连接
 		conn.ConnectedAt = time.Now()
 		conn.LastActive = conn.ConnectedAt
 		m.conns[connID] = conn
+		recordAudit(audit.EventStart, conn)
+	}
+}
+
+// recordAudit 记录携带 token 的连接开始/结束事件
+func recordAudit(eventType string, conn *ClientConnection) {
+	if conn.Token == "" {
+		return
+	}
+	ev := audit.Event{
+		Type:      eventType,
+		Token:     conn.Token,
+		ConnID:    conn.ID,
+		URL:       conn.URL,
+		ClientIP:  conn.IP,
+		UserAgent: conn.UserAgent,
 	}
+	if eventType == audit.EventStop {
+		ev.Bytes = conn.Bytes()
+		ev.Duration = time.Since(conn.ConnectedAt).Round(time.Second).Seconds()
+	}
+	audit.Record(ev)
 }
 
 // Unregister 注销客户端连接
@@ -57,9 +100,10 @@ func (m *ActiveConnectionsManager) Unregister(connID string, connType string) {
 	defer m.mu.Unlock()
 
 	if conn, ok := m.conns[connID]; ok {
-		if connType == "RTSP" || connType == "UDP" {
-			// RTSP/UDP → 立即删除
+		if connType == "RTSP" || connType == "UDP" || connType == "CLUSTER" {
+			// RTSP/UDP/集群回源 → 立即删除
 			delete(m.conns, connID)
+			recordAudit(audit.EventStop, conn)
 		} else {
 			// HTTP/HTTPS → 更新最后活跃，等待 Cleaner 清理
 			conn.LastActive = time.Now()
@@ -93,6 +137,20 @@ func (m *ActiveConnectionsManager) GetConnectionsByIP(ip string) []*ClientConnec
 	return list
 }
 
+// GetConnectionsByTrustedIP 获取 TrustedIP 为指定 IP 的所有连接
+func (m *ActiveConnectionsManager) GetConnectionsByTrustedIP(ip string) []*ClientConnection {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var list []*ClientConnection
+	for _, c := range m.conns {
+		if c.TrustedIP == ip {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
 // UpdateLastActive 更新客户端最后活跃时间
 func (m *ActiveConnectionsManager) UpdateLastActive(connID string, t time.Time) {
 	m.mu.Lock()
@@ -112,6 +170,7 @@ func (m *ActiveConnectionsManager) CleanInactiveConnections(timeout time.Duratio
 	for id, conn := range m.conns {
 		if now.Sub(conn.LastActive) > timeout {
 			delete(m.conns, id)
+			recordAudit(audit.EventStop, conn)
 		}
 	}
 }
@@ -132,6 +191,148 @@ func (m *ActiveConnectionsManager) StartCleaner(interval time.Duration, timeout
 	}()
 }
 
+// SetCancel 设置断开连接的方法，被 Kick 时调用
+func (m *ActiveConnectionsManager) SetCancel(connID string, cancel context.CancelFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.conns[connID]; ok {
+		c.cancel = cancel
+	}
+}
+
+// Kick 断开并移除客户端连接
+func (m *ActiveConnectionsManager) Kick(connID string) bool {
+	m.mu.Lock()
+	conn, ok := m.conns[connID]
+	if ok {
+		delete(m.conns, connID)
+		recordAudit(audit.EventStop, conn)
+	}
+	m.mu.Unlock()
+
+	if ok && conn.cancel != nil {
+		conn.cancel()
+	}
+	return ok
+}
+
+// KickIP 断开指定 IP 的所有客户端连接，返回断开的连接数
+func (m *ActiveConnectionsManager) KickIP(ip string) int {
+	n := 0
+	for _, c := range m.GetConnectionsByIP(ip) {
+		if m.Kick(c.ID) {
+			n++
+		}
+	}
+	return n
+}
+
+// ClientInfo 客户端连接的展示信息，token 已隐藏中间部分
+type ClientInfo struct {
+	ID             string    `json:"id"`
+	IP             string    `json:"ip"`
+	URL            string    `json:"url"`
+	UserAgent      string    `json:"user_agent"`
+	ConnectionType string    `json:"type"`
+	Token          string    `json:"token,omitempty"`
+	ConnectedAt    time.Time `json:"connected_at"`
+	LastActive     time.Time `json:"last_active"`
+	Duration       float64   `json:"duration"` // 已连接秒数
+	Bytes          int64     `json:"bytes"`
+	Stream         *HubClient `json:"stream,omitempty"` // 组播/RTP 客户端的发送统计
+}
+
+// List 返回所有客户端连接的展示信息，按连接时间从早到晚排序
+func (m *ActiveConnectionsManager) List() []ClientInfo {
+	now := time.Now()
+	streams := hubClientsByID()
+	m.mu.RLock()
+	list := make([]ClientInfo, 0, len(m.conns))
+	for _, c := range m.conns {
+		info := ClientInfo{
+			ID:             c.ID,
+			IP:             c.IP,
+			URL:            c.URL,
+			UserAgent:      c.UserAgent,
+			ConnectionType: c.ConnectionType,
+			ConnectedAt:    c.ConnectedAt,
+			LastActive:     c.LastActive,
+			Duration:       now.Sub(c.ConnectedAt).Round(time.Second).Seconds(),
+			Bytes:          c.Bytes(),
+			Stream:         streams[c.ID],
+		}
+		if c.Token != "" {
+			info.Token = maskToken(c.Token)
+		}
+		list = append(list, info)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ConnectedAt.Before(list[j].ConnectedAt) })
+	return list
+}
+
+// GetConnectionsByToken 获取使用指定 token 的所有连接，按连接时间从早到晚排序
+func (m *ActiveConnectionsManager) GetConnectionsByToken(token string) []*ClientConnection {
+	m.mu.RLock()
+	var list []*ClientConnection
+	for _, c := range m.conns {
+		if c.Token == token {
+			list = append(list, c)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ConnectedAt.Before(list[j].ConnectedAt) })
+	return list
+}
+
+// TokenSessions token 的当前会话数
+type TokenSessions struct {
+	Token    string
+	Sessions int
+	IPs      int
+}
+
+// GetTokenSessions 统计每个 token 的当前会话数，按会话数从多到少排序
+func (m *ActiveConnectionsManager) GetTokenSessions() []TokenSessions {
+	m.mu.RLock()
+	sessions := make(map[string]int)
+	ips := make(map[string]map[string]struct{})
+	for _, c := range m.conns {
+		if c.Token == "" {
+			continue
+		}
+		sessions[c.Token]++
+		if ips[c.Token] == nil {
+			ips[c.Token] = make(map[string]struct{})
+		}
+		ips[c.Token][c.IP] = struct{}{}
+	}
+	m.mu.RUnlock()
+
+	list := make([]TokenSessions, 0, len(sessions))
+	for token, n := range sessions {
+		list = append(list, TokenSessions{Token: maskToken(token), Sessions: n, IPs: len(ips[token])})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Sessions != list[j].Sessions {
+			return list[i].Sessions > list[j].Sessions
+		}
+		return list[i].Token < list[j].Token
+	})
+	return list
+}
+
+// maskToken 页面展示时隐藏 token 中间部分
+func maskToken(token string) string {
+	if len(token) <= 8 {
+		return token[:len(token)/2] + "****"
+	}
+	return token[:4] + "****" + token[len(token)-4:]
+}
+
 // GetConnectionByID 根据 ConnID 获取单个客户端连接
 func (m *ActiveConnectionsManager) GetConnectionByID(connID string) *ClientConnection {
 	m.mu.RLock()
@@ -141,3 +342,40 @@ func (m *ActiveConnectionsManager) GetConnectionByID(connID string) *ClientConne
 	}
 	return nil
 }
+
+// CountBytes 统计写给客户端的字节数，携带 token 的连接同时计入 token 流量配额
+func (m *ActiveConnectionsManager) CountBytes(connID string, w http.ResponseWriter) http.ResponseWriter {
+	m.mu.RLock()
+	conn, ok := m.conns[connID]
+	m.mu.RUnlock()
+	if !ok {
+		return w
+	}
+	return &countingWriter{ResponseWriter: w, conn: conn}
+}
+
+// countingWriter 统计写入字节数，保留 Flush，并通过 Unwrap 支持 http.ResponseController
+type countingWriter struct {
+	http.ResponseWriter
+	conn *ClientConnection
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	atomic.AddInt64(&c.conn.bytes, int64(n))
+	if c.conn.Token != "" && quota.Add(c.conn.Token, n) && err == nil {
+		// 流量配额用完，返回错误使转发循环断开连接
+		err = quota.ErrExceeded
+	}
+	return n, err
+}
+
+func (c *countingWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
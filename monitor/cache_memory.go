@@ -0,0 +1,34 @@
+package monitor
+
+import "sync"
+
+// CacheMemory Hub 缓存的内存占用
+type CacheMemory struct {
+	Used    int64  `json:"used"`    // 当前占用字节数
+	Limit   int64  `json:"limit"`   // 内存上限字节数，0 表示不限制
+	Buffers int    `json:"buffers"` // 非空的缓存数
+	Evicted uint64 `json:"evicted"` // 累计因超出上限淘汰的帧数
+}
+
+var (
+	cacheMemoryMu       sync.RWMutex
+	cacheMemoryProvider func() CacheMemory
+)
+
+// RegisterCacheMemoryProvider 注册 Hub 缓存占用数据来源（由 stream 包注册，避免循环依赖）
+func RegisterCacheMemoryProvider(p func() CacheMemory) {
+	cacheMemoryMu.Lock()
+	defer cacheMemoryMu.Unlock()
+	cacheMemoryProvider = p
+}
+
+// GetCacheMemory 返回 Hub 缓存的内存占用
+func GetCacheMemory() CacheMemory {
+	cacheMemoryMu.RLock()
+	p := cacheMemoryProvider
+	cacheMemoryMu.RUnlock()
+	if p == nil {
+		return CacheMemory{}
+	}
+	return p()
+}
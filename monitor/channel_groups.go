@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+)
+
+// ungroupedChannels 没有配置分组的频道所在的分组
+const ungroupedChannels = "未分组"
+
+// ChannelGroup 状态页分组视图中的一个分组
+type ChannelGroup struct {
+	Name     string         `json:"name"`
+	Clients  int            `json:"clients"` // 分组内所有频道的客户端数
+	Channels []GroupChannel `json:"channels"`
+}
+
+// GroupChannel 分组中正在播放的频道
+type GroupChannel struct {
+	Name    string `json:"name"`             // 配置中的频道名，未配置时为频道标识
+	Number  int    `json:"number,omitempty"` // 频道号
+	Hub     string `json:"hub"`              // 频道标识（组播地址 / RTSP 地址）
+	Type    string `json:"type"`
+	Clients int    `json:"clients"`
+}
+
+// Summary 分组内频道的简要列表，如 "CCTV-1 (3)、CCTV-2 (1)"
+func (g ChannelGroup) Summary() string {
+	parts := make([]string, len(g.Channels))
+	for i, ch := range g.Channels {
+		parts[i] = ch.Name + " (" + strconv.Itoa(ch.Clients) + ")"
+	}
+	return strings.Join(parts, "、")
+}
+
+// GetChannelGroups 按 channels 配置把正在播放的频道分组：分组按配置的顺序，其余按名称，未分组的在最后；
+// 组内有频道号的按频道号在前。没有配置频道信息时返回 nil
+func GetChannelGroups() []ChannelGroup {
+	config.CfgMu.RLock()
+	channels := config.Cfg.Channels
+	config.CfgMu.RUnlock()
+	if len(channels.List) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int)
+	var groups []ChannelGroup
+	for _, h := range GetTopHubs("", 0) {
+		ch := GroupChannel{Name: h.Name, Hub: h.Name, Type: h.Type, Clients: h.Clients}
+		group := ungroupedChannels
+		if info := channels.ByAddr(h.Name); info != nil {
+			ch.Name, ch.Number = info.Name, info.Number
+			if info.Group != "" {
+				group = info.Group
+			}
+		}
+		i, ok := index[group]
+		if !ok {
+			i = len(groups)
+			index[group] = i
+			groups = append(groups, ChannelGroup{Name: group})
+		}
+		groups[i].Clients += ch.Clients
+		groups[i].Channels = append(groups[i].Channels, ch)
+	}
+
+	rank := func(name string) int {
+		if name == ungroupedChannels {
+			return len(channels.Groups) + 1
+		}
+		return channels.GroupRank(name)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if ri, rj := rank(groups[i].Name), rank(groups[j].Name); ri != rj {
+			return ri < rj
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	for _, g := range groups {
+		sort.Slice(g.Channels, func(i, j int) bool {
+			a, b := g.Channels[i], g.Channels[j]
+			if (a.Number > 0) != (b.Number > 0) {
+				return a.Number > 0
+			}
+			if a.Number != b.Number {
+				return a.Number < b.Number
+			}
+			return a.Name < b.Name
+		})
+	}
+	return groups
+}
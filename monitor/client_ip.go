@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/qist/tvgate/utils/ipacl"
+)
+
+// trustedProxies 可信反向代理列表，为 nil 时 GetClientIP 直接信任 X-Forwarded-For（兼容旧行为），
+// 访问控制使用的 TrustedClientIP 只信任直连地址
+var trustedProxies atomic.Pointer[ipacl.List]
+
+// SetTrustedProxies 设置可信反向代理 IP/网段，配置加载与重载时调用；
+// 配置错误时不再信任任何代理并返回错误
+func SetTrustedProxies(entries []string) error {
+	if len(entries) == 0 {
+		trustedProxies.Store(nil)
+		return nil
+	}
+	list, err := ipacl.Parse(entries)
+	if err != nil {
+		trustedProxies.Store(&ipacl.List{})
+		return fmt.Errorf("trusted_proxies 配置错误: %w", err)
+	}
+	trustedProxies.Store(&list)
+	return nil
+}
+
+// TrustedClientIP 访问控制与封禁使用的客户端 IP：仅当直连地址在 trusted_proxies 中时才使用转发头，
+// 未配置 trusted_proxies 时为直连地址，客户端无法通过伪造请求头绕过
+func TrustedClientIP(r *http.Request) string {
+	if trusted := trustedProxies.Load(); trusted != nil {
+		return clientIPBehindProxies(r, *trusted)
+	}
+	return remoteIP(r)
+}
+
+// clientIPBehindProxies 仅当直连地址为可信代理时才使用转发头；
+// X-Forwarded-For 从右向左跳过可信代理，取第一个不可信的地址，防止客户端伪造
+func clientIPBehindProxies(r *http.Request, trusted ipacl.List) string {
+	remote := remoteIP(r)
+	if !trusted.ContainsString(remote) {
+		return remote
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			client = ip
+			if !trusted.ContainsString(ip) {
+				break
+			}
+		}
+		return client
+	}
+	if xr := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xr) != nil {
+		return xr
+	}
+	return remote
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -4,13 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"net"
 	"net/http"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+	"github.com/qist/tvgate/groupstats"
+	"github.com/qist/tvgate/i18n"
 )
 
 // 页面数据结构
@@ -24,12 +26,24 @@ type StatusData struct {
 	TrafficStats  *TrafficStats
 	ClientIP      string
 	ActiveClients []*ClientConnection
+	TokenSessions []TokenSessions
+	TopHubs       []HubUsage
+	ChannelGroups []ChannelGroup // 按 channels 配置分组的正在播放的频道，未配置时为空
+	GroupUsage    []groupstats.GroupUsage
+	ConnLimit     connlimit.Status
 	WebPath       string
+	PlayerPath    string      // Web 频道预览页地址，未启用 Web 管理时为空
+	Lang          string      `json:"-"` // 页面语言
+	I18NScript    template.JS `json:"-"` // 页面翻译脚本
 }
 
 // HTTP 处理入口
 func HandleMonitor(w http.ResponseWriter, r *http.Request) {
 	// w.Header().Set("server", "TVGate")
+	if r.Header.Get("Accept") == "text/event-stream" || r.URL.Query().Get("format") == "sse" {
+		handleEventStream(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if r.Header.Get("Accept") == "application/json" || r.URL.Query().Get("format") == "json" {
 		handleJSONRequest(w, r)
@@ -47,6 +61,8 @@ func handleJSONRequest(w http.ResponseWriter, r *http.Request) {
 
 func handleHTMLRequest(w http.ResponseWriter, r *http.Request) {
 	data := prepareStatusData(r)
+	data.Lang = i18n.Negotiate(r)
+	data.I18NScript = template.JS(i18n.Script(data.Lang))
 
 	tmpl := `<!DOCTYPE html>
 <html>
@@ -93,6 +109,8 @@ body {
 .table tr:hover {background:#2a2a2a;}
 .table td.url-cell {max-width:700px;}
 .table td.ua-cell {max-width:200px;}
+.table a.preview {color:inherit; text-decoration:none; border-bottom:1px dashed #888;}
+.table a.preview:hover {color:#4CAF50;}
 .status-alive {color:#4CAF50;font-weight:bold;}
 .status-dead {color:#f44336;font-weight:bold;}
 .status-cooldown {color:#ff9800;font-weight:bold;}
@@ -102,6 +120,7 @@ body {
 .refresh-btn {border:none; padding:8px 15px; border-radius:5px; font-weight:bold; cursor:pointer;}
 .refresh-on {background:#4CAF50; color:white;}
 .refresh-off {background:#f44336; color:white;}
+.live-state {font-size:12px; color:#aaa;}
 .toggle-column {cursor:pointer; user-select:none;}
 .theme-btn {
     border:none; 
@@ -122,12 +141,13 @@ body {
 .card h3 { margin-top:0; color:#e0e0e0; }
 .card ul li { padding:5px 0; }
 </style>
+<script>{{.I18NScript}}</script>
 </head>
 <body>
 
 <div class="header">
 <h1>TVGate 状态监控</h1>
-<p>更新时间: {{.Timestamp.Format "2006-01-02 15:04:05"}}</p>
+<p>更新时间: <span id="live-time">{{.Timestamp.Format "2006-01-02 15:04:05"}}</span> <span id="live-state" class="live-state"></span></p>
 </div>
 
 <div class="refresh-controls">
@@ -141,6 +161,10 @@ body {
 <option value="30000">30s</option>
 </select>
 <button id="toggleTheme" class="theme-btn">🌓 切换主题</button>
+<select id="language" onchange="tvgateSetLanguage(this.value)">
+<option value="zh"{{if eq .Lang "zh"}} selected{{end}}>中文</option>
+<option value="en"{{if eq .Lang "en"}} selected{{end}}>English</option>
+</select>
 </div>
 
 <h2>系统信息</h2>
@@ -152,15 +176,15 @@ body {
       <li><strong>内核版本:</strong> {{.TrafficStats.HostInfo.KernelVersion}}</li>
       <li><strong>CPU架构:</strong> {{.TrafficStats.HostInfo.KernelArch}}</li>
       <li><strong>版本:</strong> {{.Version}}</li>
-      <li><strong>运行时间:</strong> 
+      <li><strong>运行时间:</strong> <span id="live-uptime">
         {{$totalSeconds := .Uptime.Seconds}}
         {{$days := float64ToInt64 (divFloat64 $totalSeconds 86400)}}
         {{$hours := float64ToInt64 (divFloat64 (modFloat64 $totalSeconds 86400) 3600)}}
         {{$minutes := float64ToInt64 (divFloat64 (modFloat64 $totalSeconds 3600) 60)}}
         {{$seconds := float64ToInt64 (modFloat64 $totalSeconds 60)}}
-        {{if gt $days 0}}{{$days}}天{{end}}{{if gt $hours 0}}{{$hours}}小时{{end}}{{if gt $minutes 0}}{{$minutes}}分{{end}}{{$seconds}}秒
+        {{if gt $days 0}}{{$days}}天{{end}}{{if gt $hours 0}}{{$hours}}小时{{end}}{{if gt $minutes 0}}{{$minutes}}分{{end}}{{$seconds}}秒</span>
       </li>
-      <li><strong>Goroutines:</strong> {{.Goroutines}}</li>
+      <li><strong>Goroutines:</strong> <span id="live-goroutines">{{.Goroutines}}</span></li>
       <li><strong>客户端IP:</strong> {{.ClientIP}}</li>
     </ul>
   </div>
@@ -168,31 +192,31 @@ body {
   <div class="card">
     <h3>网络流量</h3>
     <ul style="list-style: none; padding: 0;">
-      <li><strong>总流量:</strong> {{FormatBytes .TrafficStats.TotalBytes}}</li>
-      <li><strong>入口流量:</strong> {{FormatBytes .TrafficStats.InboundBytes}}</li>
-      <li><strong>出口流量:</strong> {{FormatBytes .TrafficStats.OutboundBytes}}</li>
-      <li><strong>实时总带宽(入):</strong> {{FormatNetworkBandwidth .TrafficStats.InboundBandwidth}}</li>
-      <li><strong>实时总带宽(出):</strong> {{FormatNetworkBandwidth .TrafficStats.OutboundBandwidth}}</li>
+      <li><strong>总流量:</strong> <span id="live-total-bytes">{{FormatBytes .TrafficStats.TotalBytes}}</span></li>
+      <li><strong>入口流量:</strong> <span id="live-inbound-bytes">{{FormatBytes .TrafficStats.InboundBytes}}</span></li>
+      <li><strong>出口流量:</strong> <span id="live-outbound-bytes">{{FormatBytes .TrafficStats.OutboundBytes}}</span></li>
+      <li><strong>实时总带宽(入):</strong> <span id="live-inbound-bandwidth">{{FormatNetworkBandwidth .TrafficStats.InboundBandwidth}}</span></li>
+      <li><strong>实时总带宽(出):</strong> <span id="live-outbound-bandwidth">{{FormatNetworkBandwidth .TrafficStats.OutboundBandwidth}}</span></li>
     </ul>
   </div>
   
   <div class="card">
     <h3>CPU与内存</h3>
     <ul style="list-style: none; padding: 0;">
-      <li><strong>系统负载:</strong> {{printf "%.2f" .TrafficStats.LoadAverage.Load1}} / {{printf "%.2f" .TrafficStats.LoadAverage.Load5}} / {{printf "%.2f" .TrafficStats.LoadAverage.Load15}}</li>
+      <li><strong>系统负载:</strong> <span id="live-load">{{printf "%.2f" .TrafficStats.LoadAverage.Load1}} / {{printf "%.2f" .TrafficStats.LoadAverage.Load5}} / {{printf "%.2f" .TrafficStats.LoadAverage.Load15}}</span></li>
       <li><strong>CPU核心数:</strong> {{.TrafficStats.CPUCount}}</li> 
-	  <li><strong>CPU 使用率:</strong> {{printf "%.2f%%" .TrafficStats.CPUUsage}}</li>
-	  {{if ge .TrafficStats.CPUTemperature 0.0}}<li><strong>CPU 温度:</strong> {{printf "%.2f°C" .TrafficStats.CPUTemperature}}</li>{{end}}
+	  <li><strong>CPU 使用率:</strong> <span id="live-cpu">{{printf "%.2f%%" .TrafficStats.CPUUsage}}</span></li>
+	  {{if ge .TrafficStats.CPUTemperature 0.0}}<li><strong>CPU 温度:</strong> <span id="live-temperature">{{printf "%.2f°C" .TrafficStats.CPUTemperature}}</span></li>{{end}}
 	  <li><strong>总内存:</strong> {{FormatBytes .TrafficStats.MemoryTotal}}</li>
-      <li><strong>内存使用:</strong> {{FormatBytes .TrafficStats.MemoryUsage}}</li>
+      <li><strong>内存使用:</strong> <span id="live-memory">{{FormatBytes .TrafficStats.MemoryUsage}}</span></li>
     </ul>
   </div>
   
   <div class="card">
     <h3>TVGate监控</h3>
     <ul style="list-style: none; padding: 0;">
-      <li><strong>CPU:</strong> {{printf "%.2f%%" .TrafficStats.App.CPUPercent}} <small style="color:#aaa; font-size:10px;">（多核 CPU 时可能超过 100%）</small></li>
-      <li><strong>内存:</strong> {{FormatBytes .TrafficStats.App.MemoryUsage}}</li>
+      <li><strong>CPU:</strong> <span id="live-app-cpu">{{printf "%.2f%%" .TrafficStats.App.CPUPercent}}</span> <small style="color:#aaa; font-size:10px;">（多核 CPU 时可能超过 100%）</small></li>
+      <li><strong>内存:</strong> <span id="live-app-memory">{{FormatBytes .TrafficStats.App.MemoryUsage}}</span></li>
     </ul>
   </div>
 </div>
@@ -244,7 +268,7 @@ body {
           <th>发送带宽</th>
         </tr>
       </thead>
-      <tbody>
+      <tbody id="live-interfaces">
         {{range .TrafficStats.NetworkInterfaces}}
         <tr>
           <td>{{.Name}}</td>
@@ -260,8 +284,90 @@ body {
   </div>
 </div>
 
-<h2>活跃客户端连接</h2>
+<div id="live-hubs-section"{{if not .TopHubs}} style="display:none;"{{end}}>
+<h2>频道资源占用 Top <span id="live-hubs-count">{{len .TopHubs}}</span></h2>
+<table class="table">
+<thead>
+<tr>
+<th style="width: 400px;">频道</th>
+<th style="width: 80px;">类型</th>
+<th style="text-align:center; width: 80px;">客户端</th>
+<th style="text-align:center;">处理耗时占比</th>
+<th style="text-align:center;">累计处理耗时</th>
+<th style="text-align:center;">缓冲内存(估算)</th>
+<th style="text-align:center;">包数</th>
+<th style="text-align:center;">码率</th>
+<th style="text-align:center;">流量</th>
+</tr>
+</thead>
+<tbody id="live-hubs">
+{{range .TopHubs}}
+<tr>
+<td style="word-break: break-all;" title="{{.Name}}">{{if $.PlayerPath}}<a class="preview" href="{{$.PlayerPath}}?src={{.Name}}" target="_blank">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td>
+<td>{{.Type}}</td>
+<td style="text-align:center;">{{.Clients}}</td>
+<td style="text-align:center;">{{printf "%.2f%%" .BusyPercent}}</td>
+<td style="text-align:center;">{{.BusyTime.Round 1000000}}</td>
+<td style="text-align:center;">{{FormatBytes .BufferBytes}}</td>
+<td style="text-align:center;">{{.Packets}}</td>
+<td style="text-align:center;">-</td>
+<td style="text-align:center;">{{FormatBytes .Bytes}}</td>
+</tr>
+{{end}}
+</tbody>
+</table>
+</div>
+
+<div id="live-groups-section"{{if not .ChannelGroups}} style="display:none;"{{end}}>
+<h2>频道分组</h2>
+<table class="table">
+<thead>
+<tr>
+<th style="width: 200px;">分组</th>
+<th style="text-align:center; width: 80px;">频道数</th>
+<th style="text-align:center; width: 80px;">客户端</th>
+<th>频道（客户端数）</th>
+</tr>
+</thead>
+<tbody id="live-groups">
+{{range .ChannelGroups}}
+<tr>
+<td>{{.Name}}</td>
+<td style="text-align:center;">{{len .Channels}}</td>
+<td style="text-align:center;">{{.Clients}}</td>
+<td style="word-break: break-all;">{{.Summary}}</td>
+</tr>
+{{end}}
+</tbody>
+</table>
+</div>
+
+<div id="live-connlimit-section"{{if not .ConnLimit.Enabled}} style="display:none;"{{end}}>
+<h2>并发连接 <span id="live-connlimit-current">{{.ConnLimit.Current}}</span> / <span id="live-connlimit-limit">{{if .ConnLimit.Limit}}{{.ConnLimit.Limit}}{{else}}不限{{end}}</span></h2>
+<p>排队中 <span id="live-connlimit-queued">{{.ConnLimit.Queued}}</span>，累计拒绝 <span id="live-connlimit-rejected">{{.ConnLimit.Rejected}}</span></p>
 <table class="table">
+<thead>
+<tr>
+<th>频道</th>
+<th style="text-align:center; width: 120px;">连接数</th>
+<th style="text-align:center; width: 120px;">上限</th>
+</tr>
+</thead>
+<tbody id="live-connlimit">
+{{range .ConnLimit.Channels}}
+<tr>
+<td style="word-break: break-all;">{{.Channel}}</td>
+<td style="text-align:center;">{{.Current}}</td>
+<td style="text-align:center;">{{if .Limit}}{{.Limit}}{{else}}不限{{end}}</td>
+</tr>
+{{end}}
+</tbody>
+</table>
+</div>
+
+<h2>活跃客户端连接 (<span id="live-clients-count">{{len .ActiveClients}}</span>)</h2>
+<table class="table">
+<thead>
 <tr>
 <th style="width: 300px;">IP</th>
 <th style="width: 400px;">URL</th>
@@ -270,17 +376,68 @@ body {
 <th style="text-align:center; width: 80px;">连接时间</th>
 <th style="text-align:center; width: 80px;">最后活跃</th>
 </tr>
+</thead>
+<tbody id="live-clients">
 {{range .ActiveClients}}
 <tr>
 <td style="word-break: break-all;">{{.IP}}</td>
-<td class="url-cell" style="word-break: break-all;" title="{{.URL}}">{{.URL}}</td>
+<td class="url-cell" style="word-break: break-all;" title="{{.URL}}">{{if and $.PlayerPath (or (eq .ConnectionType "UDP") (eq .ConnectionType "RTP") (eq .ConnectionType "RTSP"))}}<a class="preview" href="{{$.PlayerPath}}?src={{.URL}}" target="_blank">{{.URL}}</a>{{else}}{{.URL}}{{end}}</td>
 <td>{{.ConnectionType}}</td>
 <td class="ua-cell" style="word-break: break-word;" title="{{.UserAgent}}">{{.UserAgent}}</td>
 <td style="text-align:center;">{{.ConnectedAt.Format "15:04:05"}}</td>
 <td style="text-align:center;">{{.LastActive.Format "15:04:05"}}</td>
 </tr>
 {{end}}
+</tbody>
+</table>
+
+<div id="live-tokens-section"{{if not .TokenSessions}} style="display:none;"{{end}}>
+<h2>Token 会话</h2>
+<table class="table">
+<thead>
+<tr>
+<th style="width: 300px;">Token</th>
+<th style="text-align:center; width: 80px;">会话数</th>
+<th style="text-align:center; width: 80px;">IP 数</th>
+</tr>
+</thead>
+<tbody id="live-tokens">
+{{range .TokenSessions}}
+<tr>
+<td style="word-break: break-all;">{{.Token}}</td>
+<td style="text-align:center;">{{.Sessions}}</td>
+<td style="text-align:center;">{{.IPs}}</td>
+</tr>
+{{end}}
+</tbody>
 </table>
+</div>
+
+{{if .GroupUsage}}
+<h2>代理组流量</h2>
+<table class="table">
+<tr>
+<th>代理组</th>
+<th style="text-align:center;">今日</th>
+<th style="text-align:center;">日上限</th>
+<th style="text-align:center;">本月</th>
+<th style="text-align:center;">月上限</th>
+<th style="text-align:center;">累计</th>
+<th>状态</th>
+</tr>
+{{range .GroupUsage}}
+<tr>
+<td>{{.Name}}</td>
+<td style="text-align:center;">{{FormatBytes .DayBytes}}</td>
+<td style="text-align:center;">{{if gt .DailyCapMB 0}}{{.DailyCapMB}} MB{{else}}-{{end}}</td>
+<td style="text-align:center;">{{FormatBytes .MonthBytes}}</td>
+<td style="text-align:center;">{{if gt .MonthlyCapMB 0}}{{.MonthlyCapMB}} MB{{else}}-{{end}}</td>
+<td style="text-align:center;">{{FormatBytes .TotalBytes}}</td>
+<td>{{if .OverCap}}<span class="status-dead">🚫 超出上限</span>{{else}}<span class="status-alive">✅ 正常</span>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
 
 <h2>代理组状态</h2>
 {{range $name, $group := .ProxyGroups}}
@@ -292,6 +449,7 @@ body {
 <th>类型 <span class="toggle-column" data-column="2" data-group="{{$name}}">👁</span></th>
 <th>服务器 <span class="toggle-column" data-column="3" data-group="{{$name}}">👁</span></th>
 <th>HTTP状态</th>
+<th>健康检查</th>
 <th>状态</th>
 </tr>
 {{range $proxy := $group.Proxies}}
@@ -306,6 +464,13 @@ body {
   </td>
 <td>
 {{ $stats := index $group.Stats.ProxyStats $proxy.Name }}
+{{if and $stats $group.HealthCheck}}{{if eq $stats.HealthStatus "up"}}<span class="status-alive">✅ 正常</span> {{$stats.HealthRT.Round 1000000}}
+{{else if eq $stats.HealthStatus "down"}}<span class="status-dead" title="{{$stats.HealthError}}">❌ 下线</span> (失败 {{$stats.HealthFailures}} 次)
+{{else}}<span class="status-unknown">⚪ 检查中</span>{{end}}
+{{else}}-{{end}}
+</td>
+<td>
+{{ $stats := index $group.Stats.ProxyStats $proxy.Name }}
 {{if $stats}}
 {{if and $stats.Alive (or (gt $stats.ResponseTime 0) (gt $stats.FailCount 0))}}<span class="status-alive">✅ 活跃</span>
 {{else if $stats.CooldownUntil.After $.Timestamp}}<span class="status-cooldown">🚫 冷却</span>
@@ -329,7 +494,7 @@ if(intervalSelect.querySelector('option[value="'+refreshMs+'"]')) intervalSelect
 
 function applyButtonUI(){
     if(auto){
-        toggleBtn.textContent = '⟳ 自动刷新 (' + (refreshMs/1000) + 's)';
+        toggleBtn.textContent = '⟳ 实时更新 (' + (refreshMs/1000) + 's)';
         toggleBtn.className = 'refresh-btn refresh-on';
     }else{
         toggleBtn.textContent = '⏸ 刷新已暂停';
@@ -337,10 +502,155 @@ function applyButtonUI(){
     }
 }
 
-function stopTimer(){ if(timer){ clearInterval(timer); timer=null; } }
-function startTimer(){ stopTimer(); timer=setInterval(()=>{location.reload();}, refreshMs); }
+// 优先通过 Server-Sent Events 接收增量更新，浏览器不支持或连接失败时退回定时刷新页面
+let source = null;
+const playerPath = "{{.PlayerPath}}";
+const liveState = document.getElementById('live-state');
+
+function stopTimer(){
+    if(timer){ clearInterval(timer); timer=null; }
+    if(source){ source.close(); source=null; }
+    liveState.textContent = '';
+}
+function startReload(){ timer=setInterval(()=>{location.reload();}, refreshMs); }
+function startTimer(){
+    stopTimer();
+    if(!window.EventSource){ startReload(); return; }
+    source = new EventSource(location.pathname + '?format=sse&interval=' + refreshMs);
+    source.onopen = () => { liveState.textContent = '● 实时'; };
+    source.onmessage = (event) => applyLive(JSON.parse(event.data));
+    source.onerror = () => {
+        if(source && source.readyState === EventSource.CLOSED){
+            source = null;
+            startReload();
+        } else {
+            liveState.textContent = '○ 重连中';
+        }
+    };
+}
 function persist(){ localStorage.setItem('autoRefresh', auto); localStorage.setItem('refreshMs', refreshMs); }
 
+function formatBytes(b){
+    if(b < 1024) return b + ' B';
+    let div = 1024, exp = 0;
+    for(let n = b / 1024; n >= 1024; n /= 1024){ div *= 1024; exp++; }
+    return (b / div).toFixed(2) + ' ' + 'KMGTPE'[exp] + 'B';
+}
+function formatBitrate(bps){
+    if(bps >= 1e6) return (bps / 1e6).toFixed(2) + ' Mbps';
+    if(bps >= 1e3) return (bps / 1e3).toFixed(0) + ' kbps';
+    return bps > 0 ? bps + ' bps' : '-';
+}
+function formatUptime(sec){
+    sec = Math.floor(sec);
+    const d = Math.floor(sec / 86400), h = Math.floor(sec % 86400 / 3600), m = Math.floor(sec % 3600 / 60);
+    return (d > 0 ? d + '天' : '') + (h > 0 ? h + '小时' : '') + (m > 0 ? m + '分' : '') + (sec % 60) + '秒';
+}
+function formatTime(value, withDate){
+    const t = new Date(value), pad = (n) => String(n).padStart(2, '0');
+    const time = pad(t.getHours()) + ':' + pad(t.getMinutes()) + ':' + pad(t.getSeconds());
+    return withDate ? t.getFullYear() + '-' + pad(t.getMonth() + 1) + '-' + pad(t.getDate()) + ' ' + time : time;
+}
+function setText(id, text){ const el = document.getElementById(id); if(el) el.textContent = text; }
+function cell(text, style){
+    const td = document.createElement('td');
+    td.textContent = text;
+    if(style) td.style.cssText = style;
+    return td;
+}
+function previewCell(name, preview){
+    const td = cell('', 'word-break: break-all;');
+    td.title = name;
+    if(playerPath && preview){
+        const a = document.createElement('a');
+        a.className = 'preview';
+        a.href = playerPath + '?src=' + encodeURIComponent(name);
+        a.target = '_blank';
+        a.textContent = name;
+        td.appendChild(a);
+    } else {
+        td.textContent = name;
+    }
+    return td;
+}
+function fillRows(id, list, build){
+    const tbody = document.getElementById(id);
+    if(!tbody) return;
+    tbody.replaceChildren(...list.map((item) => { const tr = document.createElement('tr'); tr.append(...build(item)); return tr; }));
+}
+
+function applyLive(data){
+    const center = 'text-align:center;';
+    if(data.time){
+        setText('live-time', formatTime(data.time.timestamp, true));
+        setText('live-uptime', formatUptime(data.time.uptime));
+        setText('live-goroutines', data.time.goroutines);
+    }
+    if(data.system){
+        const s = data.system;
+        setText('live-total-bytes', formatBytes(s.total_bytes));
+        setText('live-inbound-bytes', formatBytes(s.inbound_bytes));
+        setText('live-outbound-bytes', formatBytes(s.outbound_bytes));
+        setText('live-inbound-bandwidth', formatBytes(s.inbound_bandwidth) + '/s');
+        setText('live-outbound-bandwidth', formatBytes(s.outbound_bandwidth) + '/s');
+        setText('live-load', s.load.Load1.toFixed(2) + ' / ' + s.load.Load5.toFixed(2) + ' / ' + s.load.Load15.toFixed(2));
+        setText('live-cpu', s.cpu_usage.toFixed(2) + '%');
+        setText('live-temperature', s.cpu_temperature.toFixed(2) + '°C');
+        setText('live-memory', formatBytes(s.memory_usage));
+        setText('live-app-cpu', s.app_cpu.toFixed(2) + '%');
+        setText('live-app-memory', formatBytes(s.app_memory));
+    }
+    if(data.interfaces){
+        fillRows('live-interfaces', data.interfaces || [], (n) => [
+            cell(n.Name), cell(formatBytes(n.BytesRecv)), cell(formatBytes(n.BytesSent)),
+            cell(formatBytes(n.RecvBandwidth) + '/s'), cell(formatBytes(n.SendBandwidth) + '/s')
+        ]);
+    }
+    if(data.hubs){
+        document.getElementById('live-hubs-section').style.display = data.hubs.length ? '' : 'none';
+        setText('live-hubs-count', data.hubs.length);
+        fillRows('live-hubs', data.hubs, (h) => [
+            previewCell(h.name, true), cell(h.type), cell(h.clients, center),
+            cell(h.busy_percent.toFixed(2) + '%', center), cell(h.busy_time, center),
+            cell(formatBytes(h.buffer_bytes), center), cell(h.packets, center),
+            cell(formatBitrate(h.bitrate), center), cell(formatBytes(h.bytes), center)
+        ]);
+    }
+    if(data.groups){
+        document.getElementById('live-groups-section').style.display = data.groups.length ? '' : 'none';
+        fillRows('live-groups', data.groups, (g) => [
+            cell(g.name), cell(g.channels.length, center), cell(g.clients, center),
+            cell(g.channels.map((c) => c.name + ' (' + c.clients + ')').join('、'), 'word-break: break-all;')
+        ]);
+    }
+    if(data.clients){
+        setText('live-clients-count', data.clients.total);
+        fillRows('live-clients', data.clients.list, (c) => {
+            const ua = cell(c.user_agent, 'word-break: break-word;');
+            ua.className = 'ua-cell';
+            ua.title = c.user_agent;
+            const url = previewCell(c.url, c.type === 'UDP' || c.type === 'RTP' || c.type === 'RTSP');
+            url.className = 'url-cell';
+            return [cell(c.ip, 'word-break: break-all;'), url, cell(c.type), ua, cell(formatTime(c.connected_at), center), cell('-', center)];
+        });
+    }
+    if(data.conn_limit){
+        const l = data.conn_limit;
+        document.getElementById('live-connlimit-section').style.display = l.enabled ? '' : 'none';
+        setText('live-connlimit-current', l.current);
+        setText('live-connlimit-limit', l.limit || '不限');
+        setText('live-connlimit-queued', l.queued);
+        setText('live-connlimit-rejected', l.rejected);
+        fillRows('live-connlimit', l.channels, (c) => [
+            cell(c.channel, 'word-break: break-all;'), cell(c.current, center), cell(c.limit || '不限', center)
+        ]);
+    }
+    if(data.tokens){
+        document.getElementById('live-tokens-section').style.display = data.tokens.length ? '' : 'none';
+        fillRows('live-tokens', data.tokens, (t) => [cell(t.Token, 'word-break: break-all;'), cell(t.Sessions, center), cell(t.IPs, center)]);
+    }
+}
+
 if(auto) startTimer();
 applyButtonUI();
 
@@ -567,6 +877,7 @@ func prepareStatusData(r *http.Request) StatusData {
 			Proxies:     make([]*config.ProxyConfig, len(group.Proxies)),
 			Domains:     group.Domains,
 			LoadBalance: group.LoadBalance,
+			HealthCheck: group.HealthCheck,
 			Stats:       &config.GroupStats{ProxyStats: make(map[string]*config.ProxyStats)},
 		}
 		for i, p := range group.Proxies {
@@ -604,20 +915,45 @@ func prepareStatusData(r *http.Request) StatusData {
 		TrafficStats:  trafficStats, // 包含系统统计 + 应用统计
 		ClientIP:      clientIP,
 		ActiveClients: ActiveClients.GetAll(),
+		TokenSessions: ActiveClients.GetTokenSessions(),
+		TopHubs:       GetTopHubs("status", 10),
+		ChannelGroups: GetChannelGroups(),
+		GroupUsage:    groupstats.GetGroupUsage(),
+		ConnLimit:     connlimit.Default.Status(),
 		WebPath:       config.Cfg.Web.Path, // 注入动态 Web.Path
+		PlayerPath:    playerPath(),
 	}
 }
 
+// playerPath 返回 Web 频道预览页地址，点击状态页中的频道即可在浏览器中预览
+func playerPath() string {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	if !config.Cfg.Web.Enabled {
+		return ""
+	}
+	p := config.Cfg.Web.Path
+	if p == "" {
+		p = "/web/"
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p + "player"
+}
+
 func GetClientIP(r *http.Request) string {
+	if trusted := trustedProxies.Load(); trusted != nil {
+		return clientIPBehindProxies(r, *trusted)
+	}
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		return strings.TrimSpace(strings.Split(xff, ",")[0])
 	}
 	if xr := r.Header.Get("X-Real-IP"); xr != "" {
 		return xr
 	}
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
+	return remoteIP(r)
 }
\ No newline at end of file
@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HubClient 单个 Hub 客户端的发送统计，用于排查观看者网络质量
+type HubClient struct {
+	ID          string    `json:"id"`  // 连接 ID，与 ClientInfo.ID 一致
+	Hub         string    `json:"hub"` // 所在频道
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	ConnectedAt time.Time `json:"connected_at"`
+	LastWrite   time.Time `json:"last_write"` // 最后一次成功写出数据的时间，零值表示尚未收到数据
+	Bytes       uint64    `json:"bytes"`      // 已写出字节数
+	Packets     uint64    `json:"packets"`    // 已写出包数
+	Drops       uint64    `json:"drops"`      // 因接收过慢被丢弃的包数
+	DropRate    float64   `json:"drop_rate"`  // 丢包率（百分比）
+	Queued      int       `json:"queued"`     // 当前排队待发送的包数
+}
+
+var (
+	hubClientMu        sync.RWMutex
+	hubClientProviders []func() []HubClient
+)
+
+// RegisterHubClientProvider 注册 Hub 客户端统计数据来源（由 stream 包注册，避免循环依赖）
+func RegisterHubClientProvider(p func() []HubClient) {
+	hubClientMu.Lock()
+	defer hubClientMu.Unlock()
+	hubClientProviders = append(hubClientProviders, p)
+}
+
+// GetHubClients 返回所有 Hub 客户端的统计，丢包率高的排在前面
+func GetHubClients() []HubClient {
+	hubClientMu.RLock()
+	providers := append([]func() []HubClient(nil), hubClientProviders...)
+	hubClientMu.RUnlock()
+
+	all := []HubClient{}
+	for _, p := range providers {
+		all = append(all, p()...)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].DropRate != all[j].DropRate {
+			return all[i].DropRate > all[j].DropRate
+		}
+		return all[i].ConnectedAt.Before(all[j].ConnectedAt)
+	})
+	return all
+}
+
+// hubClientsByID 按连接 ID 索引 Hub 客户端统计
+func hubClientsByID() map[string]*HubClient {
+	list := GetHubClients()
+	m := make(map[string]*HubClient, len(list))
+	for i := range list {
+		m[list[i].ID] = &list[i]
+	}
+	return m
+}
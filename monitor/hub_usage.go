@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HubUsage 单个频道（Hub）的资源占用估算
+type HubUsage struct {
+	Name        string        // 频道标识（组播地址 / RTSP 地址）
+	Type        string        // UDP / RTSP
+	Clients     int           // 当前客户端数
+	Packets     uint64        // 累计处理包数
+	Bytes       uint64        // 累计处理字节数
+	BufferBytes uint64        // 估算的缓冲区占用（包数 × 平均包大小）
+	BusyTime    time.Duration // 累计处理与分发耗时（墙钟时间，包含等待慢客户端的时间，不是 CPU 时间）
+	BusyPercent float64       // 距同一调用方上次查询以来处理与分发耗时的占比
+	Drops       uint64        // 累计因客户端接收过慢丢弃的包数
+	Rejoins     uint64        // 累计因长时间未收到数据自动重新加入组播组的次数
+	Media       *MediaInfo    // 探测到的音视频参数，未启用探测或尚未探测时为 nil
+}
+
+var (
+	hubUsageMu        sync.RWMutex
+	hubUsageProviders []func(consumer string) []HubUsage
+)
+
+// RegisterHubUsageProvider 注册 Hub 资源占用数据来源（由 stream 包注册，避免循环依赖）
+func RegisterHubUsageProvider(p func(consumer string) []HubUsage) {
+	hubUsageMu.Lock()
+	defer hubUsageMu.Unlock()
+	hubUsageProviders = append(hubUsageProviders, p)
+}
+
+// GetTopHubs 按资源占用排序返回前 n 个频道，n<=0 返回全部；
+// consumer 标识调用方，BusyPercent 按该调用方上次查询以来计算，各调用方互不影响，为空时不计算
+func GetTopHubs(consumer string, n int) []HubUsage {
+	hubUsageMu.RLock()
+	providers := append([]func(consumer string) []HubUsage(nil), hubUsageProviders...)
+	hubUsageMu.RUnlock()
+
+	var all []HubUsage
+	for _, p := range providers {
+		all = append(all, p(consumer)...)
+	}
+	for i := range all {
+		all[i].Media = MediaInfoOf(all[i].Name)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].BusyPercent != all[j].BusyPercent {
+			return all[i].BusyPercent > all[j].BusyPercent
+		}
+		if all[i].BufferBytes != all[j].BufferBytes {
+			return all[i].BufferBytes > all[j].BufferBytes
+		}
+		return all[i].Bytes > all[j].Bytes
+	})
+
+	if n > 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
@@ -0,0 +1,342 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/connlimit"
+)
+
+const (
+	// liveSampleInterval 实时推送的采样间隔，所有订阅者共用同一份采样
+	liveSampleInterval = time.Second
+	// liveSystemInterval 有订阅者时刷新系统统计的间隔（无订阅者时由 StartSystemStatsUpdater 定时刷新）
+	liveSystemInterval = 5 * time.Second
+	// liveMinInterval 客户端可请求的最短推送间隔
+	liveMinInterval = time.Second
+	// liveTopHubs 推送的频道数，与状态页一致
+	liveTopHubs = 10
+)
+
+// LiveHub 实时推送的频道信息
+type LiveHub struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Clients     int     `json:"clients"`
+	Bitrate     uint64  `json:"bitrate"` // 最近一个采样周期的码率 (bit/s)
+	BusyPercent float64 `json:"busy_percent"`
+	BusyTime    string  `json:"busy_time"`
+	BufferBytes uint64  `json:"buffer_bytes"`
+	Packets     uint64  `json:"packets"`
+	Bytes       uint64  `json:"bytes"`
+}
+
+// LiveClient 实时推送的客户端信息，不含最后活跃时间以免每次采样都变化
+type LiveClient struct {
+	IP          string    `json:"ip"`
+	URL         string    `json:"url"`
+	Type        string    `json:"type"`
+	UserAgent   string    `json:"user_agent"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// liveSnapshot 一次采样的结果，按区块分别序列化，推送时只发送变化的区块
+type liveSnapshot map[string]json.RawMessage
+
+// liveSampler 有订阅者时按 liveSampleInterval 采样，无订阅者时停止
+type liveSampler struct {
+	mu          sync.Mutex
+	subscribers int
+	stop        chan struct{}
+	latest      liveSnapshot
+}
+
+// sampleState 单个采样 goroutine 的状态
+type sampleState struct {
+	prevBytes  map[string]uint64
+	prevAt     time.Time
+	lastSystem time.Time
+}
+
+var sampler = &liveSampler{}
+
+// systemStatsMu 串行化系统统计刷新，定时刷新与实时推送可能同时触发
+var systemStatsMu sync.Mutex
+
+func refreshSystemStats() {
+	systemStatsMu.Lock()
+	defer systemStatsMu.Unlock()
+	updateSystemStats()
+}
+
+// subscribe 增加订阅者，返回取消订阅的方法
+func (s *liveSampler) subscribe() func() {
+	s.mu.Lock()
+	s.subscribers++
+	if s.subscribers == 1 {
+		s.stop = make(chan struct{})
+		s.latest = nil
+		go s.run(s.stop)
+	}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.subscribers--
+			if s.subscribers == 0 {
+				close(s.stop)
+			}
+			s.mu.Unlock()
+		})
+	}
+}
+
+// snapshot 返回最近一次采样，尚未采样时返回 nil
+func (s *liveSampler) snapshot() liveSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
+
+func (s *liveSampler) run(stop chan struct{}) {
+	st := &sampleState{}
+	s.publish(stop, s.sample(st))
+
+	ticker := time.NewTicker(liveSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.publish(stop, s.sample(st))
+		case <-stop:
+			return
+		}
+	}
+}
+
+// publish 保存采样结果；订阅者全部离开后又有新订阅者时，旧的采样 goroutine 不再覆盖
+func (s *liveSampler) publish(stop chan struct{}, snap liveSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == stop {
+		s.latest = snap
+	}
+}
+
+func (s *liveSampler) sample(st *sampleState) liveSnapshot {
+	now := time.Now()
+	if now.Sub(st.lastSystem) >= liveSystemInterval {
+		refreshSystemStats()
+		st.lastSystem = now
+	}
+	ts := GlobalTrafficStats.GetTrafficStats()
+
+	// 码率按两次采样之间的累计字节数差值计算
+	hubs := GetTopHubs("live", 0)
+	elapsed := now.Sub(st.prevAt).Seconds()
+	bytes := make(map[string]uint64, len(hubs))
+	liveHubs := make([]LiveHub, 0, liveTopHubs)
+	for _, h := range hubs {
+		key := h.Type + " " + h.Name
+		bytes[key] = h.Bytes
+		if len(liveHubs) >= liveTopHubs {
+			continue
+		}
+		lh := LiveHub{
+			Name:        h.Name,
+			Type:        h.Type,
+			Clients:     h.Clients,
+			BusyPercent: h.BusyPercent,
+			BusyTime:    h.BusyTime.Round(time.Millisecond).String(),
+			BufferBytes: h.BufferBytes,
+			Packets:     h.Packets,
+			Bytes:       h.Bytes,
+		}
+		if prev, ok := st.prevBytes[key]; ok && elapsed > 0 && h.Bytes >= prev {
+			lh.Bitrate = uint64(float64(h.Bytes-prev) * 8 / elapsed)
+		}
+		liveHubs = append(liveHubs, lh)
+	}
+	st.prevBytes = bytes
+	st.prevAt = now
+
+	conns := ActiveClients.GetAll()
+	clients := make([]LiveClient, 0, len(conns))
+	counts := make(map[string]int)
+	for _, c := range conns {
+		clients = append(clients, LiveClient{
+			IP:          c.IP,
+			URL:         c.URL,
+			Type:        c.ConnectionType,
+			UserAgent:   c.UserAgent,
+			ConnectedAt: c.ConnectedAt,
+		})
+		counts[c.ConnectionType]++
+	}
+	// 按连接时间排序，保证列表未变化时序列化结果相同
+	sort.Slice(clients, func(i, j int) bool {
+		a, b := clients[i], clients[j]
+		if !a.ConnectedAt.Equal(b.ConnectedAt) {
+			return a.ConnectedAt.Before(b.ConnectedAt)
+		}
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.URL < b.URL
+	})
+
+	snap := liveSnapshot{}
+	put := func(key string, v interface{}) {
+		if b, err := json.Marshal(v); err == nil {
+			snap[key] = b
+		}
+	}
+	put("time", timeSection(now))
+	put("system", systemSection(ts))
+	put("interfaces", ts.NetworkInterfaces)
+	put("hubs", liveHubs)
+	groups := GetChannelGroups()
+	if groups == nil {
+		groups = []ChannelGroup{}
+	}
+	put("groups", groups)
+	put("clients", map[string]interface{}{
+		"total":  len(clients),
+		"counts": counts,
+		"list":   clients,
+	})
+	put("tokens", ActiveClients.GetTokenSessions())
+	put("conn_limit", connlimit.Default.Status())
+	return snap
+}
+
+func timeSection(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp":  now,
+		"uptime":     time.Since(config.StartTime).Seconds(),
+		"goroutines": runtime.NumGoroutine(),
+	}
+}
+
+func systemSection(ts *TrafficStats) map[string]interface{} {
+	return map[string]interface{}{
+		"total_bytes":        ts.TotalBytes,
+		"inbound_bytes":      ts.InboundBytes,
+		"outbound_bytes":     ts.OutboundBytes,
+		"inbound_bandwidth":  ts.InboundBandwidth,
+		"outbound_bandwidth": ts.OutboundBandwidth,
+		"cpu_usage":          ts.CPUUsage,
+		"cpu_temperature":    ts.CPUTemperature,
+		"memory_usage":       ts.MemoryUsage,
+		"memory_total":       ts.MemoryTotal,
+		"load":               ts.LoadAverage,
+		"app_cpu":            ts.App.CPUPercent,
+		"app_memory":         ts.App.MemoryUsage,
+	}
+}
+
+// Summary 返回运行状态概要，time 与 system 区块与实时推送相同
+func Summary() map[string]interface{} {
+	now := time.Now()
+	ts := GlobalTrafficStats.GetTrafficStats()
+	hubs, viewers := 0, 0
+	for _, h := range GetTopHubs("", 0) {
+		hubs++
+		viewers += h.Clients
+	}
+	return map[string]interface{}{
+		"version":    config.Version,
+		"time":       timeSection(now),
+		"system":     systemSection(ts),
+		"clients":    len(ActiveClients.GetAll()),
+		"channels":   hubs,
+		"viewers":    viewers,
+		"conn_limit": connlimit.Default.Status(),
+		"cache":      GetCacheMemory(),
+	}
+}
+
+// handleEventStream 以 Server-Sent Events 推送状态更新：首条消息包含全部区块，
+// 之后只发送发生变化的区块。参数 interval 为推送间隔（毫秒），最短 1s
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式输出", http.StatusInternalServerError)
+		return
+	}
+
+	interval := 3 * time.Second
+	if ms, err := strconv.Atoi(r.URL.Query().Get("interval")); err == nil {
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	if interval < liveMinInterval {
+		interval = liveMinInterval
+	}
+
+	unsubscribe := sampler.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	flusher.Flush()
+
+	sent := make(map[string]string)
+	push := func() error {
+		snap := sampler.snapshot()
+		if snap == nil {
+			return nil
+		}
+		changed := make(map[string]json.RawMessage)
+		for key, raw := range snap {
+			if sent[key] != string(raw) {
+				changed[key] = raw
+				sent[key] = string(raw)
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		b, err := json.Marshal(changed)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	// 首次采样在订阅时异步进行，稍等片刻再发送全量数据
+	first := time.NewTimer(100 * time.Millisecond)
+	defer first.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-first.C:
+			if sampler.snapshot() == nil {
+				first.Reset(100 * time.Millisecond)
+				continue
+			}
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+		if err := push(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// MediaInfo 探测得到的频道音视频参数
+type MediaInfo struct {
+	VideoCodec string      `json:"video_codec,omitempty"` // H264 / H265 / MPEG Video
+	Width      int         `json:"width,omitempty"`
+	Height     int         `json:"height,omitempty"`
+	FrameRate  float64     `json:"frame_rate,omitempty"`
+	Interlaced bool        `json:"interlaced,omitempty"`
+	Audio      []AudioInfo `json:"audio,omitempty"` // 按节目中的顺序
+	ProbedAt   time.Time   `json:"probed_at"`
+}
+
+// AudioInfo 单条音轨的参数
+type AudioInfo struct {
+	PID        uint16 `json:"pid"`
+	Codec      string `json:"codec"` // AAC / MP3 / AC3 / Opus
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+}
+
+var (
+	mediaMu sync.RWMutex
+	media   = make(map[string]*MediaInfo)
+)
+
+// SetMediaInfo 保存频道的探测结果，info 为 nil 时删除
+func SetMediaInfo(name string, info *MediaInfo) {
+	mediaMu.Lock()
+	defer mediaMu.Unlock()
+	if info == nil {
+		delete(media, name)
+		return
+	}
+	media[name] = info
+}
+
+// MediaInfoOf 按状态页中的频道标识查找探测结果，组播地址也可以匹配指定了网卡（@网卡）的频道
+func MediaInfoOf(name string) *MediaInfo {
+	mediaMu.RLock()
+	defer mediaMu.RUnlock()
+	if info, ok := media[name]; ok {
+		return info
+	}
+	for key, info := range media {
+		if strings.HasPrefix(key, name+"@") {
+			return info
+		}
+	}
+	return nil
+}
+
+// MediaNames 已有探测结果的频道标识
+func MediaNames() []string {
+	mediaMu.RLock()
+	defer mediaMu.RUnlock()
+	names := make([]string, 0, len(media))
+	for name := range media {
+		names = append(names, name)
+	}
+	return names
+}
@@ -197,7 +197,7 @@ func StartSystemStatsUpdater(interval time.Duration, stopChan chan struct{}) {
 		for {
 			select {
 			case <-ticker.C:
-			updateSystemStats()
+			refreshSystemStats()
 			case <-stopChan:
 				ticker.Stop()
 				return
@@ -0,0 +1,275 @@
+// Package probe 频道探测：定期旁听正在播放的频道，解析 PAT/PMT、视频 SPS 与音频参数，
+// 得到编码、分辨率、帧率与声道，结果保存在 monitor 中供状态接口与 DLNA 使用
+package probe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/stream"
+)
+
+const (
+	// scanInterval 检查是否有需要探测的频道的间隔
+	scanInterval = 10 * time.Second
+	// fpsSamples 视频 SPS 中没有帧率时，按多少帧的 DTS 估算
+	fpsSamples = 50
+)
+
+// errDone 所有轨道都已得到参数，结束读取
+var errDone = errors.New("probe done")
+
+// Prober 定期探测正在播放的频道
+type Prober struct {
+	mu   sync.Mutex
+	cfg  config.ProbeConfig
+	stop chan struct{}
+}
+
+// New 创建未启用的探测器
+func New() *Prober { return &Prober{} }
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.ProbeConfig) { Default.Configure(cfg) }
+
+// Configure 应用配置：启用时定期探测，禁用时停止并清除已有结果
+func (p *Prober) Configure(cfg config.ProbeConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cfg == p.cfg && (p.stop != nil) == cfg.Enabled {
+		return
+	}
+	p.cfg = cfg
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	if !cfg.Enabled {
+		for _, name := range monitor.MediaNames() {
+			monitor.SetMediaInfo(name, nil)
+		}
+		return
+	}
+	p.stop = make(chan struct{})
+	go p.run(p.stop, cfg)
+}
+
+// run 探测新出现的频道与超过 interval 未探测的频道，频道停止播放后删除结果
+func (p *Prober) run(stop chan struct{}, cfg config.ProbeConfig) {
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	// 停止时中断正在进行的探测
+	base, cancelAll := context.WithCancel(context.Background())
+	defer cancelAll()
+	go func() {
+		<-stop
+		cancelAll()
+	}()
+	for {
+		active := make(map[string]bool)
+		for _, name := range stream.ActiveHubs() {
+			active[name] = true
+			if info := monitor.MediaInfoOf(name); info != nil && time.Since(info.ProbedAt) < cfg.Interval {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(base, cfg.Timeout)
+			info, err := Probe(ctx, name)
+			cancel()
+			if base.Err() != nil {
+				return
+			}
+			if err != nil {
+				logger.LogPrintf("⚠️ 探测频道 %s 失败: %v", name, err)
+				// 失败的频道同样等待 interval 后再试
+				info = &monitor.MediaInfo{}
+			}
+			info.ProbedAt = time.Now()
+			monitor.SetMediaInfo(name, info)
+		}
+		for _, name := range monitor.MediaNames() {
+			if !active[name] {
+				monitor.SetMediaInfo(name, nil)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// prober 一次探测的状态，pending 为尚未得到参数的轨道数
+type prober struct {
+	info    monitor.MediaInfo
+	pending int
+
+	fps     float64
+	firstTS int64
+	frames  int
+}
+
+// Probe 旁听一个正在播放的频道（名称同 stream.OpenPreview），在 ctx 结束前尽量得到所有轨道的参数
+func Probe(ctx context.Context, name string) (*monitor.MediaInfo, error) {
+	rc, err := stream.OpenPreview(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	reader := &mpegts.Reader{R: rc}
+	if err := reader.Initialize(); err != nil {
+		return nil, err
+	}
+	reader.OnDecodeError(func(error) {})
+
+	p := &prober{}
+	for _, track := range reader.Tracks() {
+		p.track(reader, track)
+	}
+	if p.pending == 0 {
+		return &p.info, nil
+	}
+	for {
+		err := reader.Read()
+		if errors.Is(err, errDone) {
+			return &p.info, nil
+		}
+		if err != nil {
+			// 超时或频道停止时返回已得到的部分结果
+			if p.info.VideoCodec != "" || len(p.info.Audio) > 0 {
+				return &p.info, nil
+			}
+			if errors.Is(err, io.EOF) && ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		}
+	}
+}
+
+// track 按轨道编码登记回调，参数在 PMT 中已知的轨道直接记录
+func (p *prober) track(reader *mpegts.Reader, track *mpegts.Track) {
+	switch codec := track.Codec.(type) {
+	case *mpegts.CodecH264:
+		if p.info.VideoCodec != "" {
+			return
+		}
+		p.info.VideoCodec = "H264"
+		p.pending++
+		reader.OnDataH264(track, func(_, dts int64, au [][]byte) error {
+			for _, nalu := range au {
+				if len(nalu) > 0 && h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeSPS && p.info.Width == 0 {
+					var sps h264.SPS
+					if sps.Unmarshal(nalu) == nil {
+						p.info.Width, p.info.Height = sps.Width(), sps.Height()
+						p.info.Interlaced = !sps.FrameMbsOnlyFlag
+						p.fps = sps.FPS()
+					}
+				}
+			}
+			return p.video(dts)
+		})
+	case *mpegts.CodecH265:
+		if p.info.VideoCodec != "" {
+			return
+		}
+		p.info.VideoCodec = "H265"
+		p.pending++
+		reader.OnDataH265(track, func(_, dts int64, au [][]byte) error {
+			for _, nalu := range au {
+				if len(nalu) > 1 && h265.NALUType((nalu[0]>>1)&0x3F) == h265.NALUType_SPS_NUT && p.info.Width == 0 {
+					var sps h265.SPS
+					if sps.Unmarshal(nalu) == nil {
+						p.info.Width, p.info.Height = sps.Width(), sps.Height()
+						p.fps = sps.FPS()
+					}
+				}
+			}
+			return p.video(dts)
+		})
+	case *mpegts.CodecMPEG1Video, *mpegts.CodecMPEG4Video:
+		if p.info.VideoCodec == "" {
+			p.info.VideoCodec = "MPEG Video"
+		}
+	case *mpegts.CodecMPEG4Audio:
+		p.info.Audio = append(p.info.Audio, monitor.AudioInfo{PID: track.PID, Codec: "AAC",
+			SampleRate: codec.Config.SampleRate, Channels: codec.Config.ChannelCount})
+	case *mpegts.CodecMPEG4AudioLATM:
+		p.info.Audio = append(p.info.Audio, monitor.AudioInfo{PID: track.PID, Codec: "AAC-LATM"})
+	case *mpegts.CodecAC3:
+		p.info.Audio = append(p.info.Audio, monitor.AudioInfo{PID: track.PID, Codec: "AC3",
+			SampleRate: codec.SampleRate, Channels: codec.ChannelCount})
+	case *mpegts.CodecOpus:
+		p.info.Audio = append(p.info.Audio, monitor.AudioInfo{PID: track.PID, Codec: "Opus",
+			SampleRate: 48000, Channels: codec.ChannelCount})
+	case *mpegts.CodecMPEG1Audio:
+		// 采样率与声道要等到第一帧
+		i := len(p.info.Audio)
+		p.info.Audio = append(p.info.Audio, monitor.AudioInfo{PID: track.PID, Codec: "MP3"})
+		p.pending++
+		reader.OnDataMPEG1Audio(track, func(_ int64, frames [][]byte) error {
+			a := &p.info.Audio[i]
+			if a.SampleRate != 0 || len(frames) == 0 {
+				return nil
+			}
+			var h mpeg1audio.FrameHeader
+			if h.Unmarshal(frames[0]) != nil {
+				return nil
+			}
+			a.SampleRate, a.Channels = h.SampleRate, 2
+			if h.ChannelMode == mpeg1audio.ChannelModeMono {
+				a.Channels = 1
+			}
+			return p.done()
+		})
+	}
+}
+
+// video 得到分辨率后确定帧率：SPS 中有帧率时直接使用，否则按 DTS 估算
+func (p *prober) video(dts int64) error {
+	if p.info.Width == 0 || p.info.FrameRate != 0 {
+		return nil
+	}
+	if p.fps > 0 {
+		p.info.FrameRate = roundFPS(p.fps)
+		return p.done()
+	}
+	if p.frames == 0 {
+		p.firstTS = dts
+	}
+	p.frames++
+	if p.frames > fpsSamples && dts > p.firstTS {
+		p.info.FrameRate = roundFPS(float64(p.frames-1) * 90000 / float64(dts-p.firstTS))
+		return p.done()
+	}
+	return nil
+}
+
+// done 一条轨道的参数已得到，全部得到时结束读取
+func (p *prober) done() error {
+	if p.pending--; p.pending <= 0 {
+		return errDone
+	}
+	return nil
+}
+
+// roundFPS 保留两位小数
+func roundFPS(fps float64) float64 {
+	return float64(int(fps*100+0.5)) / 100
+}
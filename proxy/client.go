@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/dns"
 	"github.com/qist/tvgate/logger"
 	conf "github.com/qist/tvgate/proxy/config"
 	httpclient "github.com/qist/tvgate/utils/http"
@@ -18,6 +19,16 @@ import (
 
 // createProxyClient 根据代理配置和 IPv6 开关创建 http.Client
 func CreateProxyClient(ctx context.Context, cfg *config.Config, proxyConfig config.ProxyConfig, enableIPv6 bool) (*http.Client, error) {
+	return createProxyClient(ctx, cfg, proxyConfig, enableIPv6, httpclient.ProtoAuto, nil)
+}
+
+// CreateGroupProxyClient 按代理组的 IPv6、上游协议与 DNS 配置创建 http.Client
+func CreateGroupProxyClient(ctx context.Context, cfg *config.Config, group *config.ProxyGroupConfig, proxyConfig config.ProxyConfig) (*http.Client, error) {
+	return createProxyClient(ctx, cfg, proxyConfig, group.IPv6, group.Protocol, group.DNS)
+}
+
+// dnsServers 非空时，目标地址（标准 HTTP 代理时为代理服务器地址）改用这些 DNS 服务器解析
+func createProxyClient(ctx context.Context, cfg *config.Config, proxyConfig config.ProxyConfig, enableIPv6 bool, protocol string, dnsServers []string) (*http.Client, error) {
 	NormalizeProxyConfig(&proxyConfig)
 
 	proxyType := strings.ToLower(proxyConfig.Type)
@@ -67,6 +78,10 @@ func CreateProxyClient(ctx context.Context, cfg *config.Config, proxyConfig conf
 			if !enableIPv6 && (network == "tcp6" || network == "tcp") {
 				network = "tcp4"
 			}
+			if len(dnsServers) > 0 {
+				// 代理组指定了 DNS，本地解析后把 IP 交给代理，避免使用被污染的解析结果
+				addr = resolveWithServers(dialCtx, dnsServers, addr, enableIPv6)
+			}
 			// Step 1: 尝试通过代理拨号
 			conn, err := dialer.DialContext(dialCtx, network, addr)
 			if err != nil {
@@ -87,13 +102,18 @@ func CreateProxyClient(ctx context.Context, cfg *config.Config, proxyConfig conf
 		}
 	} else {
 		baseDialer := &net.Dialer{Timeout: 10 * time.Second}
-		safeDial := conf.SafeDialContext(baseDialer, enableIPv6)
+		safeDial := conf.SafeDialContextWithResolver(baseDialer, enableIPv6, dns.ForServers(dnsServers))
 
 		transport.DialContext = func(dialCtx context.Context, network, addr string) (net.Conn, error) {
 			if !enableIPv6 && (network == "tcp6" || network == "tcp") {
 				network = "tcp4"
 			}
 
+			if transport.Proxy != nil && len(dnsServers) > 0 {
+				// 代理组指定了 DNS，代理服务器地址同样使用该 DNS 解析
+				return safeDial(dialCtx, network, addr)
+			}
+
 			if transport.Proxy != nil {
 				// 尝试通过代理拨号
 				conn, err := baseDialer.DialContext(dialCtx, network, addr)
@@ -118,11 +138,30 @@ func CreateProxyClient(ctx context.Context, cfg *config.Config, proxyConfig conf
 
 	}
 	// 这里使用 NewHTTPClient 生成最终 client
-	client := httpclient.NewHTTPClient(cfg, transport)
+	client := httpclient.NewHTTPClientWithProtocol(cfg, transport, protocol)
 
 	return client, nil
 }
 
+// resolveWithServers 使用指定 DNS 服务器解析 addr 中的域名，失败时原样返回由代理端解析
+func resolveWithServers(ctx context.Context, servers []string, addr string, enableIPv6 bool) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return addr
+	}
+	ips, err := dns.ForServers(servers).LookupIPAddr(ctx, host)
+	if err != nil {
+		logger.LogPrintf("⚠️ 代理组 DNS 解析 %s 失败，交由代理解析: %v", host, err)
+		return addr
+	}
+	for _, ip := range ips {
+		if enableIPv6 || ip.IP.To4() != nil {
+			return net.JoinHostPort(ip.IP.String(), port)
+		}
+	}
+	return addr
+}
+
 // 判断错误是否属于解析失败
 func isResolveError(err error) bool {
 	if err == nil {
@@ -129,8 +129,11 @@ func (w *SocksDialerWrapper) Dial(network, addr string) (net.Conn, error) {
 
 // SafeDialContext 封装 DNS fallback 的拨号逻辑
 func SafeDialContext(base *net.Dialer, enableIPv6 bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
-	resolver := dns.GetInstance()
+	return SafeDialContextWithResolver(base, enableIPv6, dns.GetInstance())
+}
 
+// SafeDialContextWithResolver 与 SafeDialContext 相同，但使用指定的解析器（如代理组专用 DNS）
+func SafeDialContextWithResolver(base *net.Dialer, enableIPv6 bool, resolver *dns.Resolver) func(ctx context.Context, network, addr string) (net.Conn, error) {
 	return func(ctx context.Context, network, addr string) (net.Conn, error) {
 		if !enableIPv6 && (network == "tcp6" || network == "tcp") {
 			network = "tcp4"
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
 	"github.com/qist/tvgate/logger"
 	"github.com/shirou/gopsutil/v3/process"
 	"gopkg.in/yaml.v3"
@@ -1421,9 +1422,14 @@ func (sm *StreamManager) updateConfigFile(newStreamKey string) error {
 		return err
 	}
 
-	// 解析YAML
+	// 按配置文件格式解析
+	var doc yaml.Node
 	var cfg map[string]interface{}
-	if err := yaml.Unmarshal(configData, &cfg); err != nil {
+	err = load.UnmarshalNode(*config.ConfigFilePath, configData, &doc)
+	if err == nil {
+		err = doc.Decode(&cfg)
+	}
+	if err != nil {
 		logger.LogPrintf("Failed to unmarshal config file: %v", err)
 		return err
 	}
@@ -1466,9 +1472,14 @@ func (sm *StreamManager) updateConfigFile(newStreamKey string) error {
 		logger.LogPrintf("Publisher config not found")
 	}
 
-	// 重新序列化为YAML
+	// 按配置文件格式重新序列化
 	logger.LogPrintf("Serializing updated config")
-	newConfigData, err := yaml.Marshal(cfg)
+	var out yaml.Node
+	err = out.Encode(cfg)
+	var newConfigData []byte
+	if err == nil {
+		newConfigData, err = load.MarshalNode(*config.ConfigFilePath, &out)
+	}
 	if err != nil {
 		logger.LogPrintf("Failed to marshal updated config: %v", err)
 		return err
@@ -0,0 +1,397 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// ErrExceeded 流量配额已用完，写入客户端时返回该错误以断开连接
+var ErrExceeded = errors.New("token 流量配额已用完")
+
+// 未合并的字节数达到该值时重新判断是否超额，单个连接最多超出约 1MB
+const flushBytes = 1 << 20
+
+// Usage token 当前统计日/月的流量
+type Usage struct {
+	Token      string `json:"token"`
+	Day        string `json:"day"`   // 统计日，格式 2006-01-02
+	Month      string `json:"month"` // 统计月，格式 2006-01
+	DayBytes   uint64 `json:"day_bytes"`
+	MonthBytes uint64 `json:"month_bytes"`
+}
+
+// Status token 配额使用情况，剩余字节数为 -1 表示不限制
+type Status struct {
+	Usage
+	DailyMB        int64 `json:"daily_mb"`
+	MonthlyMB      int64 `json:"monthly_mb"`
+	DayRemaining   int64 `json:"day_remaining"`
+	MonthRemaining int64 `json:"month_remaining"`
+	Exceeded       bool  `json:"exceeded"`
+}
+
+// counter 单个 token 的计数器，pending 为尚未合并到日/月统计的字节数
+type counter struct {
+	pending  uint64
+	exceeded int32 // 上次合并时是否已超额
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// flush 将 pending 合并到日/月统计，跨日或跨月时重置对应计数，返回合并后的统计与是否超额
+func (c *counter) flush(now time.Time, limit config.TokenQuota) (Usage, bool) {
+	n := atomic.SwapUint64(&c.pending, 0)
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.usage.Day != day {
+		c.usage.Day = day
+		c.usage.DayBytes = 0
+	}
+	if c.usage.Month != month {
+		c.usage.Month = month
+		c.usage.MonthBytes = 0
+	}
+	c.usage.DayBytes += n
+	c.usage.MonthBytes += n
+
+	over := isOver(c.usage, limit)
+	if over && atomic.SwapInt32(&c.exceeded, 1) == 0 {
+		logger.LogPrintf("🚫 token 流量配额已用完: %s, 今日 %d MB, 本月 %d MB",
+			logger.MaskToken(c.usage.Token), c.usage.DayBytes>>20, c.usage.MonthBytes>>20)
+	} else if !over {
+		atomic.StoreInt32(&c.exceeded, 0)
+	}
+	return c.usage, over
+}
+
+// isOver 判断统计值是否达到日/月配额
+func isOver(u Usage, limit config.TokenQuota) bool {
+	if limit.DailyMB > 0 && u.DayBytes >= uint64(limit.DailyMB)<<20 {
+		return true
+	}
+	if limit.MonthlyMB > 0 && u.MonthBytes >= uint64(limit.MonthlyMB)<<20 {
+		return true
+	}
+	return false
+}
+
+// remaining 返回剩余字节数，未限制时为 -1
+func remaining(used uint64, limitMB int64) int64 {
+	if limitMB <= 0 {
+		return -1
+	}
+	if left := int64(uint64(limitMB)<<20) - int64(used); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Manager 按 token 统计流量并判断配额
+type Manager struct {
+	mu       sync.RWMutex
+	cfg      config.QuotaConfig
+	counters map[string]*counter
+
+	dirty  int32
+	saveMu sync.Mutex
+}
+
+// New 创建未启用的 Manager，需调用 Configure 启用
+func New() *Manager {
+	return &Manager{counters: make(map[string]*counter)}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 更新配置，已有的流量统计保留，超额状态按新配额重新判断
+func (m *Manager) Configure(cfg config.QuotaConfig) {
+	m.mu.Lock()
+	m.cfg = cfg
+	counters := make([]*counter, 0, len(m.counters))
+	for _, c := range m.counters {
+		counters = append(counters, c)
+	}
+	m.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range counters {
+		limit, _ := m.limit(c.usage.Token)
+		c.flush(now, limit)
+	}
+}
+
+// tokenLimits 运行时为 token 设置的配额，如批量导入的签发 token 自带的配额
+var tokenLimits func(token string) (config.TokenQuota, bool)
+
+// SetTokenLimits 设置运行时配额的查询函数，优先级低于 quota.tokens、高于默认配额；只在 init 中调用
+func SetTokenLimits(f func(token string) (config.TokenQuota, bool)) {
+	tokenLimits = f
+}
+
+// limit 返回 token 的配额，未启用或不限制时第二个返回值为 false
+func (m *Manager) limit(token string) (config.TokenQuota, bool) {
+	m.mu.RLock()
+	enabled := m.cfg.Enabled
+	limit, ok := m.cfg.Tokens[token]
+	defaults := config.TokenQuota{DailyMB: m.cfg.DailyMB, MonthlyMB: m.cfg.MonthlyMB}
+	m.mu.RUnlock()
+	if !enabled || token == "" {
+		return config.TokenQuota{}, false
+	}
+	if !ok && tokenLimits != nil {
+		limit, ok = tokenLimits(token)
+	}
+	if !ok {
+		limit = defaults
+	}
+	return limit, limit.DailyMB > 0 || limit.MonthlyMB > 0
+}
+
+func (m *Manager) counter(token string) *counter {
+	m.mu.RLock()
+	c, ok := m.counters[token]
+	m.mu.RUnlock()
+	if ok {
+		return c
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok = m.counters[token]; !ok {
+		c = &counter{usage: Usage{Token: token}}
+		m.counters[token] = c
+	}
+	return c
+}
+
+// Add 累计发送给 token 的字节数，返回是否已超额；未配置配额的 token 不统计
+func (m *Manager) Add(token string, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	limit, ok := m.limit(token)
+	if !ok {
+		return false
+	}
+	c := m.counter(token)
+	if atomic.AddUint64(&c.pending, uint64(n)) >= flushBytes {
+		c.flush(time.Now(), limit)
+	}
+	atomic.StoreInt32(&m.dirty, 1)
+	return atomic.LoadInt32(&c.exceeded) == 1
+}
+
+// Exceeded token 是否已用完配额
+func (m *Manager) Exceeded(token string) bool {
+	limit, ok := m.limit(token)
+	if !ok {
+		return false
+	}
+	_, over := m.counter(token).flush(time.Now(), limit)
+	return over
+}
+
+// Status 返回 token 的配额使用情况
+func (m *Manager) Status(token string) Status {
+	limit, ok := m.limit(token)
+	now := time.Now()
+	u, over := Usage{Token: token, Day: now.Format("2006-01-02"), Month: now.Format("2006-01")}, false
+	m.mu.RLock()
+	c, counted := m.counters[token]
+	m.mu.RUnlock()
+	if ok || counted {
+		if c == nil {
+			c = m.counter(token)
+		}
+		u, over = c.flush(now, limit)
+	}
+	return Status{
+		Usage:          u,
+		DailyMB:        limit.DailyMB,
+		MonthlyMB:      limit.MonthlyMB,
+		DayRemaining:   remaining(u.DayBytes, limit.DailyMB),
+		MonthRemaining: remaining(u.MonthBytes, limit.MonthlyMB),
+		Exceeded:       over,
+	}
+}
+
+// Statuses 返回所有已统计及单独配置了配额的 token，按 token 排序
+func (m *Manager) Statuses() []Status {
+	m.mu.RLock()
+	tokens := make(map[string]struct{}, len(m.counters)+len(m.cfg.Tokens))
+	for token := range m.counters {
+		tokens[token] = struct{}{}
+	}
+	if m.cfg.Enabled {
+		for token := range m.cfg.Tokens {
+			tokens[token] = struct{}{}
+		}
+	}
+	m.mu.RUnlock()
+
+	list := make([]Status, 0, len(tokens))
+	for token := range tokens {
+		list = append(list, m.Status(token))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Token < list[j].Token })
+	return list
+}
+
+// Reset 清零 token 当日与当月的流量，用于续费后立即恢复
+func (m *Manager) Reset(token string) {
+	c := m.counter(token)
+	atomic.StoreUint64(&c.pending, 0)
+	c.mu.Lock()
+	c.usage.DayBytes = 0
+	c.usage.MonthBytes = 0
+	c.mu.Unlock()
+	atomic.StoreInt32(&c.exceeded, 0)
+	atomic.StoreInt32(&m.dirty, 1)
+	logger.LogPrintf("✅ 已重置 token 流量配额: %s", logger.MaskToken(token))
+}
+
+// usageFile 流量统计持久化文件，与配置文件放在同一目录
+func usageFile() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "token_quota.json")
+}
+
+// Load 启动时从文件恢复流量统计
+func (m *Manager) Load() {
+	data, err := os.ReadFile(usageFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取 token 流量统计失败: %v", err)
+		}
+		return
+	}
+	var saved []Usage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.LogPrintf("⚠️ 解析 token 流量统计失败: %v", err)
+		return
+	}
+	for _, u := range saved {
+		c := m.counter(u.Token)
+		c.mu.Lock()
+		c.usage = u
+		c.mu.Unlock()
+	}
+	logger.LogPrintf("✅ 已恢复 %d 个 token 的流量统计", len(saved))
+}
+
+// Save 将流量统计写入文件（先写临时文件再重命名），已跨月的记录不再保存
+func (m *Manager) Save() {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(m.snapshot(time.Now()), "", "  ")
+	if err != nil {
+		return
+	}
+	file := usageFile()
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.LogPrintf("❌ 保存 token 流量统计失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		logger.LogPrintf("❌ 保存 token 流量统计失败: %v", err)
+	}
+}
+
+// Usages 返回当前统计月内所有 token 的流量统计，用于导出备份
+func (m *Manager) Usages() []Usage {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	return m.snapshot(time.Now())
+}
+
+// Restore 从备份恢复流量统计，覆盖同名 token 的当前统计，返回恢复的数量
+func (m *Manager) Restore(list []Usage) int {
+	n := 0
+	for _, u := range list {
+		if u.Token == "" {
+			continue
+		}
+		c := m.counter(u.Token)
+		atomic.StoreUint64(&c.pending, 0)
+		c.mu.Lock()
+		c.usage = u
+		c.mu.Unlock()
+		limit, _ := m.limit(u.Token)
+		c.flush(time.Now(), limit)
+		n++
+	}
+	if n > 0 {
+		atomic.StoreInt32(&m.dirty, 1)
+		logger.LogPrintf("✅ 已从备份恢复 %d 个 token 的流量统计", n)
+	}
+	return n
+}
+
+// snapshot 合并所有计数器并返回按 token 排序的统计，调用方需持有 saveMu
+func (m *Manager) snapshot(now time.Time) []Usage {
+	month := now.Format("2006-01")
+	m.mu.Lock()
+	counters := make([]*counter, 0, len(m.counters))
+	for token, c := range m.counters {
+		c.mu.Lock()
+		stale := c.usage.Month != "" && c.usage.Month != month && atomic.LoadUint64(&c.pending) == 0
+		c.mu.Unlock()
+		if stale {
+			delete(m.counters, token)
+			continue
+		}
+		counters = append(counters, c)
+	}
+	m.mu.Unlock()
+
+	saved := make([]Usage, 0, len(counters))
+	for _, c := range counters {
+		limit, _ := m.limit(c.usage.Token)
+		u, _ := c.flush(now, limit)
+		saved = append(saved, u)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Token < saved[j].Token })
+	return saved
+}
+
+// StartSaver 定期持久化流量统计（退出时由 Save 同步保存）
+func (m *Manager) StartSaver(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.SwapInt32(&m.dirty, 0) == 1 {
+				m.Save()
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Configure 更新全局实例配置
+func Configure(cfg config.QuotaConfig) { Default.Configure(cfg) }
+
+// Add 累计全局实例中 token 的流量
+func Add(token string, n int) bool { return Default.Add(token, n) }
+
+// Exceeded 查询全局实例中 token 是否已超额
+func Exceeded(token string) bool { return Default.Exceeded(token) }
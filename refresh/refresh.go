@@ -0,0 +1,301 @@
+// Package refresh 上游地址刷新：按频道调用接口或外部命令获取带有时效鉴权参数的上游地址，
+// 缓存到有效期结束前，访问 /refresh/<频道> 时用获取的地址交给代理处理
+package refresh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/m3u"
+)
+
+// maxResult 接口返回或命令输出的最大长度
+const maxResult = 1 << 20
+
+var errNoValue = errors.New("结果中没有取到值")
+
+// value 一个频道最近获取的值
+type value struct {
+	mu         sync.Mutex // 同一频道同时只获取一次
+	channel    config.RefreshChannel
+	val        string
+	expires    time.Time
+	refreshing bool
+}
+
+// Manager 各频道的地址获取与缓存，配置重载后未变化的频道继续使用已获取的值
+type Manager struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	values  map[string]*value
+	client  *http.Client
+}
+
+// New 创建没有频道的管理器
+func New() *Manager {
+	return &Manager{values: make(map[string]*value), client: &http.Client{}}
+}
+
+// Default 全局实例
+var Default = New()
+
+// Configure 应用全局实例的配置
+func Configure(cfg config.RefreshConfig) { Default.Configure(cfg) }
+
+// Configure 更新频道，配置变化的频道丢弃已获取的值
+func (m *Manager) Configure(cfg config.RefreshConfig) {
+	values := make(map[string]*value)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = cfg.Timeout
+	if cfg.Enabled {
+		for _, ch := range cfg.Channels {
+			if old, ok := m.values[ch.Name]; ok && sameChannel(old.channel, ch) {
+				values[ch.Name] = old
+				continue
+			}
+			values[ch.Name] = &value{channel: ch}
+		}
+	}
+	m.values = values
+}
+
+// sameChannel 两个配置获取的值是否相同
+func sameChannel(a, b config.RefreshChannel) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// Resolve 返回频道当前的上游地址：值有效时直接返回，剩余不到 1/5 有效期时在后台重新获取，
+// 没有值或已过期时立即获取
+func (m *Manager) Resolve(ctx context.Context, name string) (string, error) {
+	m.mu.RLock()
+	v, ok := m.values[name]
+	m.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("频道 %s 不存在", name)
+	}
+
+	v.mu.Lock()
+	now := time.Now()
+	if v.val != "" && now.Before(v.expires) {
+		if !v.refreshing && v.expires.Sub(now) < v.channel.TTL/5 {
+			v.refreshing = true
+			go m.refreshAhead(v)
+		}
+		upstream := expand(v.channel, v.val)
+		v.mu.Unlock()
+		return upstream, nil
+	}
+	defer v.mu.Unlock()
+	val, err := m.fetch(ctx, v.channel)
+	if err != nil {
+		return "", err
+	}
+	v.val, v.expires = val, time.Now().Add(v.channel.TTL)
+	return expand(v.channel, val), nil
+}
+
+// refreshAhead 在有效期结束前获取新值，失败时继续使用旧值直到过期
+func (m *Manager) refreshAhead(v *value) {
+	val, err := m.fetch(context.Background(), v.channel)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.refreshing = false
+	if err != nil {
+		logger.LogPrintf("⚠️ 刷新频道 %s 的上游地址失败，继续使用旧地址至 %s: %v", v.channel.Name, v.expires.Format("15:04:05"), err)
+		return
+	}
+	v.val, v.expires = val, time.Now().Add(v.channel.TTL)
+	logger.LogPrintf("🔄 频道 %s 的上游地址已刷新", v.channel.Name)
+}
+
+// expand 用获取的值生成上游地址
+func expand(ch config.RefreshChannel, val string) string {
+	if ch.URL == "" {
+		return val
+	}
+	return strings.ReplaceAll(ch.URL, "{value}", val)
+}
+
+// placeholders 替换接口地址、请求体与命令参数中的 {name} {timestamp}
+func placeholders(ch config.RefreshChannel, s string) string {
+	return strings.NewReplacer("{name}", ch.Name, "{timestamp}", strconv.FormatInt(time.Now().Unix(), 10)).Replace(s)
+}
+
+// fetch 调用接口或外部命令并按 json_path 取值
+func (m *Manager) fetch(ctx context.Context, ch config.RefreshChannel) (string, error) {
+	m.mu.RLock()
+	timeout := m.timeout
+	m.mu.RUnlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var out []byte
+	var err error
+	if len(ch.Command) > 0 {
+		out, err = m.run(ctx, ch)
+	} else {
+		out, err = m.call(ctx, ch)
+	}
+	if err != nil {
+		return "", err
+	}
+	if ch.JSONPath == "" {
+		if val := strings.TrimSpace(string(out)); val != "" {
+			return val, nil
+		}
+		return "", errNoValue
+	}
+	var doc any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return "", fmt.Errorf("结果不是 JSON: %w", err)
+	}
+	return lookup(doc, ch.JSONPath)
+}
+
+// call 调用获取地址的接口
+func (m *Manager) call(ctx context.Context, ch config.RefreshChannel) ([]byte, error) {
+	method := strings.ToUpper(ch.Method)
+	if method == "" {
+		method = http.MethodGet
+		if ch.Body != "" {
+			method = http.MethodPost
+		}
+	}
+	var body io.Reader
+	if ch.Body != "" {
+		body = strings.NewReader(placeholders(ch, ch.Body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, placeholders(ch, ch.API), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ch.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("接口返回状态码 %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResult))
+}
+
+// run 执行外部命令，以标准输出为结果
+func (m *Manager) run(ctx context.Context, ch config.RefreshChannel) ([]byte, error) {
+	args := make([]string, len(ch.Command)-1)
+	for i, arg := range ch.Command[1:] {
+		args[i] = placeholders(ch, arg)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ch.Command[0], args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.Len() > maxResult {
+		return nil, errors.New("命令输出过长")
+	}
+	return stdout.Bytes(), nil
+}
+
+// lookup 按 a.b.0.c 形式的路径取值，数字表示数组下标，取到的值为字符串或数字
+func lookup(doc any, p string) (string, error) {
+	for _, key := range strings.Split(p, ".") {
+		switch node := doc.(type) {
+		case map[string]any:
+			doc = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", errNoValue
+			}
+			doc = node[i]
+		default:
+			return "", errNoValue
+		}
+	}
+	switch v := doc.(type) {
+	case string:
+		if v != "" {
+			return v, nil
+		}
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", errNoValue
+}
+
+// has 是否配置了该频道
+func (m *Manager) has(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.values[name]
+	return ok
+}
+
+// Handler 访问 路径前缀+频道名 时把请求改写为上游地址对应的代理路径交给 next，
+// 客户端的查询参数（如 token）附加在上游地址的参数之后；不改变客户端访问的地址，播放器重新加载 m3u8 时会用到新地址
+func (m *Manager) Handler(prefix string, next http.Handler) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		if !m.has(name) {
+			// 允许带扩展名访问，如 /refresh/cctv1.m3u8
+			name = strings.TrimSuffix(name, path.Ext(name))
+			if !m.has(name) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		upstream, err := m.Resolve(r.Context(), name)
+		if err != nil {
+			logger.LogPrintf("❌ 获取频道 %s 的上游地址失败: %v", name, err)
+			http.Error(w, "获取上游地址失败", http.StatusBadGateway)
+			return
+		}
+		target := m3u.ProxyPath(upstream)
+		if !strings.HasPrefix(target, "/") {
+			logger.LogPrintf("❌ 频道 %s 的上游地址不受支持: %s", name, upstream)
+			http.Error(w, "上游地址不受支持", http.StatusBadGateway)
+			return
+		}
+
+		p, query, _ := strings.Cut(target, "?")
+		if r.URL.RawQuery != "" {
+			if query != "" {
+				query += "&"
+			}
+			query += r.URL.RawQuery
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path, r2.URL.RawPath, r2.URL.RawQuery = p, "", query
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/bruteforce"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/httperror"
+	"github.com/qist/tvgate/utils/ipacl"
+)
+
+// accessRule 已解析的访问规则
+type accessRule struct {
+	host   string
+	prefix string
+	acl    ipacl.ACL
+}
+
+func (rule *accessRule) match(host, urlPath string) bool {
+	if rule.host != "" && !strings.EqualFold(rule.host, host) {
+		return false
+	}
+	return strings.HasPrefix(urlPath, rule.prefix)
+}
+
+// denyAll 配置无效时使用，拒绝所有地址，不因配置错误放行
+var denyAll = ipacl.ACL{Deny: ipacl.List{
+	{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+	{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+}}
+
+// IPAccess 客户端 IP 访问控制，先检查手动封禁与全局 allow/deny，再检查最匹配的规则，
+// 在中间件链之外执行，早于认证；客户端 IP 见 monitor.TrustedClientIP
+func IPAccess(next http.Handler, cfg *config.Config) http.Handler {
+	global, err := ipacl.NewACL(cfg.Access.Allow, cfg.Access.Deny)
+	if err != nil {
+		logger.LogPrintf("❌ access 配置错误，拒绝所有请求: %v", err)
+		global = denyAll
+	}
+
+	rules := make([]accessRule, 0, len(cfg.Access.Rules))
+	for _, r := range cfg.Access.Rules {
+		acl, err := ipacl.NewACL(r.Allow, r.Deny)
+		if err != nil {
+			logger.LogPrintf("❌ access 规则配置错误，拒绝匹配的请求 (host: %s, path_prefix: %s): %v", r.Host, r.PathPrefix, err)
+			acl = denyAll
+		}
+		rules = append(rules, accessRule{host: r.Host, prefix: r.PathPrefix, acl: acl})
+	}
+	if global.Empty() && len(rules) == 0 {
+		return rejectBlocked(next)
+	}
+
+	// 指定域名的规则优先，其次路径前缀最长者
+	sort.SliceStable(rules, func(i, j int) bool {
+		if (rules[i].host != "") != (rules[j].host != "") {
+			return rules[i].host != ""
+		}
+		return len(rules[i].prefix) > len(rules[j].prefix)
+	})
+
+	return rejectBlocked(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := monitor.TrustedClientIP(r)
+		allowed := global.Allowed(clientIP)
+		if allowed {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			for i := range rules {
+				if rules[i].match(host, r.URL.Path) {
+					allowed = rules[i].acl.Allowed(clientIP)
+					break
+				}
+			}
+		}
+		if !allowed {
+			logger.LogPrintf("🚫 IP禁止访问: %s, host: %s, url: %s", clientIP, r.Host, r.URL.Path)
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// rejectBlocked 拒绝被管理员手动封禁的 IP，见 bruteforce.Ban
+func rejectBlocked(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := monitor.TrustedClientIP(r)
+		if remaining, blocked := bruteforce.Blocked(clientIP); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Round(time.Second)/time.Second)))
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	acmeMu      sync.Mutex
+	acmeMgr     *autocert.Manager
+	acmeCurrent config.ACMEConfig
+)
+
+// ACMECacheDir 返回证书缓存目录，未配置时使用配置文件目录下的 acme
+func ACMECacheDir(cfg config.ACMEConfig) string {
+	if cfg.CacheDir != "" {
+		return cfg.CacheDir
+	}
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "acme")
+}
+
+// getACMEManager 返回与配置对应的证书管理器，未启用时返回 nil；配置不变时复用同一个，避免重复申请
+func getACMEManager(cfg config.ACMEConfig) *autocert.Manager {
+	if !cfg.Enabled || len(cfg.Domains) == 0 {
+		return nil
+	}
+
+	acmeMu.Lock()
+	defer acmeMu.Unlock()
+	if acmeMgr != nil && acmeCurrent.Email == cfg.Email && acmeCurrent.CacheDir == cfg.CacheDir &&
+		acmeCurrent.DirectoryURL == cfg.DirectoryURL && slices.Equal(acmeCurrent.Domains, cfg.Domains) {
+		return acmeMgr
+	}
+
+	cacheDir := ACMECacheDir(cfg)
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		logger.LogPrintf("❌ 创建证书缓存目录失败 %s: %v", cacheDir, err)
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	acmeMgr = m
+	acmeCurrent = cfg
+	acmeCurrent.Domains = slices.Clone(cfg.Domains)
+	logger.LogPrintf("🔐 已启用自动证书: %s，缓存目录 %s", strings.Join(cfg.Domains, ", "), cacheDir)
+	return m
+}
+
+// makeACMETLSConfig 使用自动证书的 TLS 配置，证书在首次握手时申请并自动续期；
+// NextProtos 加入 acme-tls/1 以响应 TLS-ALPN-01 验证
+func makeACMETLSConfig(m *autocert.Manager, minVersion, maxVersion uint16, cipherSuites []uint16, curves []tls.CurveID) *tls.Config {
+	return &tls.Config{
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curves,
+		NextProtos:       []string{"h3", "h2", "http/1.1", acme.ALPNProto},
+		GetCertificate:   m.GetCertificate,
+	}
+}
+
+// acmeHTTPHandler 在明文端口上响应 HTTP-01 验证（/.well-known/acme-challenge/），其他请求交给 next
+func acmeHTTPHandler(addr string, cfg *config.Config, next http.Handler) http.Handler {
+	if addr == fmt.Sprintf(":%d", cfg.Server.TLS.HTTPSPort) {
+		return next
+	}
+	m := getACMEManager(cfg.Server.TLS.ACME)
+	if m == nil {
+		return next
+	}
+	return m.HTTPHandler(next)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// 跨域策略未设置时的默认值
+var (
+	defaultCORSOrigins = []string{"*"}
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"}
+)
+
+const defaultCORSMaxAge = 10 * time.Minute
+
+// corsPolicy 合并默认值后的跨域策略
+type corsPolicy struct {
+	prefix      string
+	disabled    bool
+	origins     []string
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// newCORSPolicy 合并策略，override 中未设置的项使用 base
+func newCORSPolicy(prefix string, base, override config.CORSPolicy) corsPolicy {
+	pick := func(o, b, d []string) []string {
+		if len(o) > 0 {
+			return o
+		}
+		if len(b) > 0 {
+			return b
+		}
+		return d
+	}
+	maxAge := override.MaxAge
+	if maxAge <= 0 {
+		maxAge = base.MaxAge
+	}
+	if maxAge <= 0 {
+		maxAge = defaultCORSMaxAge
+	}
+	return corsPolicy{
+		prefix:      prefix,
+		origins:     pick(override.AllowOrigins, base.AllowOrigins, defaultCORSOrigins),
+		methods:     strings.Join(pick(override.AllowMethods, base.AllowMethods, defaultCORSMethods), ", "),
+		headers:     strings.Join(pick(override.AllowHeaders, base.AllowHeaders, nil), ", "),
+		expose:      strings.Join(pick(override.ExposeHeaders, base.ExposeHeaders, nil), ", "),
+		credentials: override.AllowCredentials || base.AllowCredentials,
+		maxAge:      strconv.Itoa(int(maxAge / time.Second)),
+	}
+}
+
+// allowOrigin 返回 Access-Control-Allow-Origin 的值，不允许时返回空；
+// * 始终原样返回，不回显请求的来源，否则任何网站都能带着凭据读取响应
+func (p *corsPolicy) allowOrigin(origin string) string {
+	lower := strings.ToLower(origin)
+	for _, o := range p.origins {
+		switch {
+		case o == "*":
+			return "*"
+		case strings.EqualFold(o, origin):
+			return origin
+		case strings.Contains(o, "*"):
+			if ok, _ := path.Match(strings.ToLower(o), lower); ok {
+				return origin
+			}
+		}
+	}
+	return ""
+}
+
+// serve 写出跨域头，预检请求直接应答并返回 true
+func (p *corsPolicy) serve(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.disabled {
+		return false
+	}
+	h := w.Header()
+	allowed := p.allowOrigin(origin)
+	if allowed != "*" {
+		h.Add("Vary", "Origin")
+	}
+	if allowed != "" {
+		h.Set("Access-Control-Allow-Origin", allowed)
+		// 按 * 允许的来源不返回允许凭据，浏览器不会为其携带 Cookie
+		if p.credentials && allowed != "*" {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if p.expose != "" {
+			h.Set("Access-Control-Expose-Headers", p.expose)
+		}
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	// 预检请求：来源不允许时不返回允许的方法与请求头，由浏览器拒绝
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if allowed != "" {
+		h.Set("Access-Control-Allow-Methods", p.methods)
+		if p.headers != "" {
+			h.Set("Access-Control-Allow-Headers", p.headers)
+		} else if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+			h.Set("Access-Control-Allow-Headers", req)
+		}
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// corsHandler 按路径前缀（最长前缀优先）选择策略
+func corsHandler(next http.Handler, cfg config.MiddlewareCORSConfig) http.Handler {
+	global := newCORSPolicy("/", cfg.CORSPolicy, config.CORSPolicy{})
+	policies := make([]corsPolicy, 0, len(cfg.Paths))
+	for _, pp := range cfg.Paths {
+		p := newCORSPolicy(pp.PathPrefix, cfg.CORSPolicy, pp.CORSPolicy)
+		p.disabled = pp.Disabled
+		policies = append(policies, p)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return len(policies[i].prefix) > len(policies[j].prefix)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &global
+		for i := range policies {
+			if strings.HasPrefix(r.URL.Path, policies[i].prefix) {
+				p = &policies[i]
+				break
+			}
+		}
+		if p.serve(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS 跨域访问头，作为中间件链中的 cors 使用；middleware.cors.enabled 时已对所有请求生效，链中的 cors 不再重复处理
+func CORS(next http.Handler, cfg *config.Config) http.Handler {
+	if cfg.Middleware.CORS.Enabled {
+		return next
+	}
+	return corsHandler(next, cfg.Middleware.CORS)
+}
+
+// GlobalCORS middleware.cors.enabled 时对所有请求应用跨域策略，在 token 校验等中间件之前应答预检请求
+func GlobalCORS(next http.Handler, cfg *config.Config) http.Handler {
+	if !cfg.Middleware.CORS.Enabled {
+		return next
+	}
+	return corsHandler(next, cfg.Middleware.CORS)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/stream"
+)
+
+// ready 启动完成（配置已加载、全部端口已开始监听）后为 true，开始退出时恢复为 false
+var ready atomic.Bool
+
+// SetReady 设置就绪状态，退出时先置为 false 让编排系统停止转发新请求
+func SetReady(v bool) { ready.Store(v) }
+
+// Alive 主流程是否正常：配置锁在 timeout 内无法获取说明已卡住（如死锁）
+func Alive(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		config.CfgMu.RLock()
+		config.CfgMu.RUnlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Health 存活与就绪检查接口，在 IP 访问控制和中间件链之外处理，探针来自节点地址也不会被拦截
+func Health(cfg *config.Config, next http.Handler) http.Handler {
+	hc := cfg.Health
+	if !hc.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case hc.LivenessPath:
+			if Alive(2 * time.Second) {
+				writeHealth(w, r, http.StatusOK, "ok", nil)
+			} else {
+				writeHealth(w, r, http.StatusServiceUnavailable, "fail", map[string]string{"config_lock": "获取配置锁超时"})
+			}
+		case hc.ReadinessPath:
+			checks, ok := readiness(hc)
+			if ok {
+				writeHealth(w, r, http.StatusOK, "ok", checks)
+			} else {
+				writeHealth(w, r, http.StatusServiceUnavailable, "fail", checks)
+			}
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// readiness 逐项检查就绪条件，返回每项的结果
+func readiness(hc config.HealthConfig) (map[string]string, bool) {
+	checks := make(map[string]string)
+	ok := true
+	fail := func(name, msg string) {
+		checks[name] = msg
+		ok = false
+	}
+
+	if ready.Load() {
+		checks["startup"] = "ok"
+	} else {
+		fail("startup", "启动中或正在退出")
+	}
+
+	config.CfgMu.RLock()
+	addrs := ListenAddrs(&config.Cfg)
+	config.CfgMu.RUnlock()
+	serverMu.Lock()
+	var missing []string
+	for _, addr := range addrs {
+		if _, bound := servers[addr]; !bound {
+			missing = append(missing, addr)
+		}
+	}
+	serverMu.Unlock()
+	if len(missing) == 0 {
+		checks["listeners"] = "ok"
+	} else {
+		fail("listeners", fmt.Sprintf("未监听: %v", missing))
+	}
+
+	if hc.MinHealthyHubs > 0 {
+		healthy, total := stream.HealthyHubs(hc.HubIdle)
+		msg := fmt.Sprintf("%d/%d 个 Hub 正常，至少需要 %d 个", healthy, total, hc.MinHealthyHubs)
+		if healthy >= hc.MinHealthyHubs {
+			checks["hubs"] = msg
+		} else {
+			fail("hubs", msg)
+		}
+	}
+	return checks, ok
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, code int, status string, checks map[string]string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	body := map[string]any{"status": status}
+	if len(checks) > 0 {
+		body["checks"] = checks
+	}
+	json.NewEncoder(w).Encode(body)
+}
@@ -11,15 +11,22 @@ import (
 	"time"
 
 	"github.com/cloudflare/tableflip"
-	"github.com/libp2p/go-reuseport"
 	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/catchup"
+	"github.com/qist/tvgate/cluster"
+	"github.com/qist/tvgate/dlna"
+	"github.com/qist/tvgate/dvr"
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/domainmap"
+	"github.com/qist/tvgate/epg"
+	"github.com/qist/tvgate/ingest"
 	h "github.com/qist/tvgate/handler"
 	"github.com/qist/tvgate/jx"
 	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/logo"
 	"github.com/qist/tvgate/monitor"
 	"github.com/qist/tvgate/publisher"
+	"github.com/qist/tvgate/refresh"
 	httpclient "github.com/qist/tvgate/utils/http"
 	"github.com/qist/tvgate/web"
 	"github.com/quic-go/quic-go"
@@ -77,31 +84,40 @@ func StartHTTPServerWithConfig(ctx context.Context, addr string, upgrader *table
 
 	tlsConfig, certFile, keyFile := GetTLSConfig(addr, cfg)
 	enableH3 := tlsConfig != nil && addr == fmt.Sprintf(":%d", cfg.Server.TLS.HTTPSPort) && cfg.Server.TLS.EnableH3
+	if l := findListener(cfg, addr); l != nil {
+		enableH3 = tlsConfig != nil && l.EnableH3 && !strings.HasPrefix(addr, unixPrefix)
+		features := "全部功能"
+		if len(l.Features) > 0 {
+			features = strings.Join(l.Features, ", ")
+		}
+		logger.LogPrintf("📡 监听 %s: %s", listenerName(l), features)
+	}
 
 	srv := &http.Server{
 		Handler:           mux,
+		// 读写超时按路由由 RouteTimeouts 设置，长时间的流不受整体超时限制
 		ReadTimeout:       0,
 		WriteTimeout:      0,
-		IdleTimeout:       60 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		MaxHeaderBytes:    1 << 20,
+		IdleTimeout:       cfg.Server.Timeouts.Idle,
+		ReadHeaderTimeout: cfg.Server.Timeouts.ReadHeader,
+		MaxHeaderBytes:    cfg.Server.Limits.MaxHeaderKB << 10,
 		TLSConfig:         tlsConfig,
 	}
+	if tlsConfig == nil && cfg.Cluster.Role == config.ClusterOrigin {
+		// 边缘节点使用明文 HTTP/2 回源，所有频道复用一条连接
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
 
-	// ==================== TCP Listener ====================
-	var ln net.Listener
-	var err error
-	if upgrader != nil {
-		ln, err = upgrader.Listen("tcp", addr)
-		if err != nil {
-			// fallback reuseport
-			ln, err = reuseport.Listen("tcp", addr)
-		}
-	} else {
-		ln, err = reuseport.Listen("tcp", addr)
+	// ==================== TCP / Unix Socket Listener ====================
+	name := ""
+	if l := findListener(cfg, addr); l != nil {
+		name = l.Name
 	}
+	ln, err := listen(upgrader, addr, name)
 	if err != nil {
-		return fmt.Errorf("❌ 创建 TCP listener 失败: %w", err)
+		return fmt.Errorf("❌ 创建 listener 失败 %s: %w", addr, err)
 	}
 
 	// ==================== HTTP/3 UDP Listener ====================
@@ -124,7 +140,8 @@ func StartHTTPServerWithConfig(ctx context.Context, addr string, upgrader *table
 			Addr:        addr,
 			Handler:     mux,
 			TLSConfig:   tlsConfig,
-			IdleTimeout: 60 * time.Second,
+			IdleTimeout: cfg.Server.Timeouts.Idle,
+			MaxHeaderBytes: cfg.Server.Limits.MaxHeaderKB << 10,
 			QUICConfig: &quic.Config{
 				Allow0RTT:          true,
 				MaxIdleTimeout:     60 * time.Second,
@@ -223,7 +240,13 @@ func GetTLSConfig(addr string, cfg *config.Config) (*tls.Config, string, string)
 		minVersion, maxVersion = parseProtocols(cfg.Server.TLS.Protocols)
 		cipherSuites = parseCipherSuites(cfg.Server.TLS.Ciphers)
 		curves = parseCurvePreferences(cfg.Server.TLS.ECDHCurve)
+		if m := getACMEManager(cfg.Server.TLS.ACME); m != nil {
+			return makeACMETLSConfig(m, minVersion, maxVersion, cipherSuites, curves), "", ""
+		}
 	default:
+		if l := findListener(cfg, addr); l != nil {
+			return listenerTLSConfig(l, cfg)
+		}
 		return nil, "", ""
 	}
 
@@ -234,61 +257,39 @@ func GetTLSConfig(addr string, cfg *config.Config) (*tls.Config, string, string)
 	return makeTLSConfig(certFile, keyFile, minVersion, maxVersion, cipherSuites, curves), certFile, keyFile
 }
 
-func RegisterMux(addr string, cfg *config.Config) *http.ServeMux {
+// RegisterMux 按监听地址提供的功能注册路由，并按 Order 组装对所有请求生效的阶段，最内层为按路径前缀的中间件链
+func RegisterMux(addr string, cfg *config.Config) http.Handler {
 	mux := http.NewServeMux()
-
-	oldAddr := fmt.Sprintf(":%d", cfg.Server.Port)
-	newHTTPAddr := ""
-	newHTTPSAddr := ""
-	if cfg.Server.HTTPPort > 0 {
-		newHTTPAddr = fmt.Sprintf(":%d", cfg.Server.HTTPPort)
-	}
-	if cfg.Server.TLS.HTTPSPort > 0 {
-		newHTTPSAddr = fmt.Sprintf(":%d", cfg.Server.TLS.HTTPSPort)
-	}
-
-	// 是否启用了新端口
-	hasNewPort := (newHTTPAddr != "" || newHTTPSAddr != "")
-
-	switch {
-	case !hasNewPort && addr == oldAddr:
-		// 没有新端口 → 旧端口跑全功能
-		RegisterFullMux(mux, cfg)
-
-	case hasNewPort && addr == oldAddr:
-		// 有新端口 → 旧端口降级成 monitor/web
-		RegisterMonitorWebMux(mux, cfg)
-
-	case hasNewPort && addr == newHTTPAddr:
-		// 新 HTTP 端口 → jx + 默认代理
-		RegisterJXAndProxyMux(mux, cfg)
-
-	case hasNewPort && addr == newHTTPSAddr:
-		// 新 HTTPS 端口 → 也只跑 jx + 默认代理
-		RegisterJXAndProxyMux(mux, cfg)
-
-	default:
-		// 默认兜底 → 只开监控，避免空路由
-		RegisterMonitorWebMux(mux, cfg)
-	}
-
-	return mux
+	features := muxFeatures(addr, cfg)
+	registerFeatures(mux, cfg, features)
+	return buildStages(BuildMiddlewareChain(mux, cfg), addr, cfg)
 }
 
 // monitor + web
 func RegisterMonitorWebMux(mux *http.ServeMux, cfg *config.Config) {
+	registerFeatures(mux, cfg, []string{config.FeatureMonitor, config.FeatureWeb})
+}
+
+// 状态页
+func registerMonitor(mux *http.ServeMux, cfg *config.Config) {
 	monitorPath := cfg.Monitor.Path
 	if monitorPath == "" {
 		monitorPath = "/status"
 	}
-	mux.Handle(monitorPath, SecurityHeaders(http.HandlerFunc(monitor.HandleMonitor)))
+	mux.Handle(monitorPath, http.HandlerFunc(monitor.HandleMonitor))
+}
 
+// Web 管理
+func registerWeb(mux *http.ServeMux, cfg *config.Config) {
 	if cfg.Web.Enabled {
 		webConfig := web.WebConfig{
 			Username: cfg.Web.Username,
 			Password: cfg.Web.Password,
 			Enabled:  cfg.Web.Enabled,
 			Path:     cfg.Web.Path,
+			APIToken: cfg.Web.APIToken,
+			Users:    cfg.Web.Users,
+			APIKeys:  cfg.Web.APIKeys,
 		}
 		configHandler := web.NewConfigHandler(webConfig)
 		configHandler.RegisterRoutes(mux)
@@ -297,13 +298,22 @@ func RegisterMonitorWebMux(mux *http.ServeMux, cfg *config.Config) {
 
 // jx + 默认代理
 func RegisterJXAndProxyMux(mux *http.ServeMux, cfg *config.Config) {
+	registerFeatures(mux, cfg, []string{config.FeatureJX, config.FeatureProxy})
+}
+
+// 视频解析
+func registerJX(mux *http.ServeMux, cfg *config.Config) {
 	jxHandler := jx.NewJXHandler(&cfg.JX)
 	jxPath := cfg.JX.Path
 	if jxPath == "" {
 		jxPath = "/jx"
 	}
-	mux.Handle(jxPath, SecurityHeaders(http.HandlerFunc(jxHandler.Handle)))
-	
+	mux.Handle(jxPath, http.HandlerFunc(jxHandler.Handle))
+	mux.Handle(strings.TrimSuffix(jxPath, "/")+"/batch", http.HandlerFunc(jxHandler.HandleBatch))
+}
+
+// 推流与默认代理（含域名映射）
+func registerProxy(mux *http.ServeMux, cfg *config.Config) {
 	// 添加 publisher 路由（如果配置了publisher）
 	if cfg.Publisher != nil && cfg.Publisher.Path != "" {
 		publisherPath := cfg.Publisher.Path
@@ -312,13 +322,40 @@ func RegisterJXAndProxyMux(mux *http.ServeMux, cfg *config.Config) {
 		}
 		// 确保不会注册重复的路径
 		if publisherPath != "/" {
-			mux.Handle(publisherPath, SecurityHeaders(http.StripPrefix(strings.TrimSuffix(publisherPath, "/"), publisher.GetHandler())))
-			mux.Handle(strings.TrimSuffix(publisherPath, "/"), SecurityHeaders(http.RedirectHandler(publisherPath, http.StatusMovedPermanently)))
+			mux.Handle(publisherPath, http.StripPrefix(strings.TrimSuffix(publisherPath, "/"), publisher.GetHandler()))
+			mux.Handle(strings.TrimSuffix(publisherPath, "/"), http.RedirectHandler(publisherPath, http.StatusMovedPermanently))
 		}
 	}
 
+	// 节目单与播放列表同样由播放器访问，放在代理端口上
+	if cfg.EPG.Enabled {
+		mux.Handle(cfg.EPG.Path, epg.Default.Handler())
+		mux.Handle(cfg.EPG.Path+".gz", epg.Default.Handler())
+	}
+	if cfg.Logo.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.Logo.Path, "/")+"/", logo.Default.Handler())
+	}
+	if cfg.Catchup.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.Catchup.Path, "/")+"/", catchup.NewHandler(cfg))
+	}
+	if cfg.DLNA.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.DLNA.Path, "/")+"/", dlna.Default.Handler())
+	}
+	if cfg.Ingest.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.Ingest.Path, "/")+"/", ingest.Default.Handler())
+	}
+	if cfg.DVR.Enabled && cfg.DVR.VOD.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.DVR.VOD.Path, "/")+"/", dvr.Default.VODHandler(cfg.DVR.VOD.Path))
+	}
+	if cfg.Cluster.Role == config.ClusterOrigin {
+		mux.Handle("/"+strings.Trim(cfg.Cluster.Path, "/")+"/", cluster.Default.Handler())
+	}
+
 	client := httpclient.NewHTTPClient(cfg, nil)
-	defaultHandler := SecurityHeaders(http.HandlerFunc(h.Handler(client)))
+	defaultHandler := http.HandlerFunc(h.Handler(client))
+	if cfg.Refresh.Enabled {
+		mux.Handle(strings.TrimSuffix(cfg.Refresh.Path, "/")+"/", refresh.Default.Handler(cfg.Refresh.Path, defaultHandler))
+	}
 
 	if len(cfg.DomainMap) > 0 {
 		mappings := make(auth.DomainMapList, len(cfg.DomainMap))
@@ -327,15 +364,29 @@ func RegisterJXAndProxyMux(mux *http.ServeMux, cfg *config.Config) {
 				Name:          mapping.Name,
 				Source:        mapping.Source,
 				Target:        mapping.Target,
+				Targets:       mapping.Targets,
 				Protocol:      mapping.Protocol,
 				Auth:          mapping.Auth,
 				ClientHeaders: mapping.ClientHeaders,
 				ServerHeaders: mapping.ServerHeaders,
+				HeaderRules:   mapping.HeaderRules,
+				PathPrefix:    mapping.PathPrefix,
+				StripPrefix:   mapping.StripPrefix,
+				TargetPath:    mapping.TargetPath,
+
+				FailoverCooldown:      mapping.FailoverCooldown,
+				TLS:                   mapping.TLS,
+				ConnectTimeout:        mapping.ConnectTimeout,
+				ResponseHeaderTimeout: mapping.ResponseHeaderTimeout,
+				Timeout:               mapping.Timeout,
+				Retries:               mapping.Retries,
+				RetryDelay:            mapping.RetryDelay,
+				BodyRewrite:           mapping.BodyRewrite,
 			}
 		}
 		localClient := &http.Client{Timeout: cfg.HTTP.Timeout}
 		domainMapper := domainmap.NewDomainMapper(mappings, localClient, defaultHandler)
-		mux.Handle("/", SecurityHeaders(domainMapper))
+		mux.Handle("/", domainMapper)
 	} else {
 		mux.Handle("/", defaultHandler)
 	}
@@ -344,6 +395,5 @@ func RegisterJXAndProxyMux(mux *http.ServeMux, cfg *config.Config) {
 
 // 全功能 = monitor/web + jx + 默认代理
 func RegisterFullMux(mux *http.ServeMux, cfg *config.Config) {
-	RegisterMonitorWebMux(mux, cfg)
-	RegisterJXAndProxyMux(mux, cfg)
+	registerFeatures(mux, cfg, config.ListenerFeatures)
 }
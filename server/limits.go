@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// RequestLimits 检查请求地址长度、请求头条数与请求体大小，请求体大小按路径前缀（最长前缀优先）可单独设置；
+// 没有 Content-Length 的请求体在读取超出上限时返回错误
+func RequestLimits(next http.Handler, cfg *config.Config) http.Handler {
+	l := cfg.Server.Limits
+	routes := append([]config.BodyLimitRoute(nil), l.Routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+	bodyLimit := func(path string) int64 {
+		for _, rt := range routes {
+			if strings.HasPrefix(path, rt.PathPrefix) {
+				return rt.MaxBodyKB << 10
+			}
+		}
+		return l.MaxBodyKB << 10
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.MaxURLLength > 0 && len(r.RequestURI) > l.MaxURLLength {
+			httperror.Error(w, r, "请求地址过长", http.StatusRequestURITooLong)
+			return
+		}
+		if l.MaxHeaders > 0 {
+			n := 0
+			for _, v := range r.Header {
+				n += len(v)
+			}
+			if n > l.MaxHeaders {
+				httperror.Error(w, r, "请求头过多", http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+		}
+		if limit := bodyLimit(r.URL.Path); limit > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit {
+				httperror.Error(w, r, "请求体过大", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cloudflare/tableflip"
+	"github.com/libp2p/go-reuseport"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/systemd"
+)
+
+// unixPrefix Unix Socket 监听地址的前缀
+const unixPrefix = "unix:"
+
+// ListenAddrs 返回需要启动的全部监听地址：port、http_port、tls.https_port 与 listeners，已去重
+func ListenAddrs(cfg *config.Config) []string {
+	var addrs []string
+	add := func(addr string) {
+		if addr != "" && !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	if cfg.Server.Port > 0 {
+		add(fmt.Sprintf(":%d", cfg.Server.Port))
+	}
+	if cfg.Server.HTTPPort > 0 {
+		add(fmt.Sprintf(":%d", cfg.Server.HTTPPort))
+	}
+	if cfg.Server.TLS.HTTPSPort > 0 {
+		add(fmt.Sprintf(":%d", cfg.Server.TLS.HTTPSPort))
+	}
+	for _, l := range cfg.Server.Listeners {
+		add(strings.TrimSpace(l.Listen))
+	}
+	return addrs
+}
+
+// ListenersChanged 监听地址或证书变化时需要重启服务，只改变功能时平滑更新路由即可
+func ListenersChanged(old, cur []config.ListenerConfig) bool {
+	if len(old) != len(cur) {
+		return true
+	}
+	for i := range old {
+		a, b := old[i], cur[i]
+		if a.Listen != b.Listen || a.CertFile != b.CertFile || a.KeyFile != b.KeyFile ||
+			a.ACME != b.ACME || a.EnableH3 != b.EnableH3 {
+			return true
+		}
+	}
+	return false
+}
+
+// findListener 返回地址对应的 listeners 配置，不是额外监听地址时返回 nil
+func findListener(cfg *config.Config, addr string) *config.ListenerConfig {
+	for i := range cfg.Server.Listeners {
+		if strings.TrimSpace(cfg.Server.Listeners[i].Listen) == addr {
+			return &cfg.Server.Listeners[i]
+		}
+	}
+	return nil
+}
+
+// listenerName 日志中显示的监听名称
+func listenerName(l *config.ListenerConfig) string {
+	if l.Name != "" {
+		return l.Name + " " + l.Listen
+	}
+	return l.Listen
+}
+
+// hasFeature 功能列表为空时提供全部功能
+func hasFeature(features []string, feature string) bool {
+	return len(features) == 0 || slices.Contains(features, feature)
+}
+
+// RegisterListenerMux 按 listeners 配置的功能注册路由
+func RegisterListenerMux(mux *http.ServeMux, cfg *config.Config, l *config.ListenerConfig) {
+	registerFeatures(mux, cfg, l.Features)
+}
+
+// listenerTLSConfig listeners 的 TLS 配置，协议版本、加密套件与曲线沿用 server.tls
+func listenerTLSConfig(l *config.ListenerConfig, cfg *config.Config) (*tls.Config, string, string) {
+	minVersion, maxVersion := parseProtocols(cfg.Server.TLS.Protocols)
+	cipherSuites := parseCipherSuites(cfg.Server.TLS.Ciphers)
+	curves := parseCurvePreferences(cfg.Server.TLS.ECDHCurve)
+	if l.ACME {
+		if m := getACMEManager(cfg.Server.TLS.ACME); m != nil {
+			return makeACMETLSConfig(m, minVersion, maxVersion, cipherSuites, curves), "", ""
+		}
+		return nil, "", ""
+	}
+	if l.CertFile == "" || l.KeyFile == "" {
+		return nil, "", ""
+	}
+	return makeTLSConfig(l.CertFile, l.KeyFile, minVersion, maxVersion, cipherSuites, curves), l.CertFile, l.KeyFile
+}
+
+// listen 创建 TCP 或 Unix Socket 监听，优先使用热更新时从旧进程继承的监听，其次是 systemd socket 激活传入的监听
+func listen(upgrader *tableflip.Upgrader, addr, name string) (net.Listener, error) {
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		network, address = "unix", path
+	}
+
+	if upgrader != nil {
+		if ln, err := upgrader.Fds.Listener(network, address); err == nil && ln != nil {
+			return ln, nil
+		}
+	}
+	if ln := systemd.Listener(addr, name); ln != nil {
+		// 登记到升级器，热更新时新进程可以继续使用
+		if tl, ok := ln.(tableflip.Listener); ok && upgrader != nil {
+			if err := upgrader.Fds.AddListener(network, address, tl); err != nil {
+				logger.LogPrintf("⚠️ 登记 systemd socket 失败 %s: %v", addr, err)
+			}
+		}
+		return ln, nil
+	}
+
+	if network == "unix" {
+		if upgrader != nil {
+			if ln, err := upgrader.Listen(network, address); err == nil {
+				return ln, nil
+			}
+		}
+		// 清理上次异常退出遗留的 socket 文件
+		if fi, err := os.Lstat(address); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+			os.Remove(address)
+		} else if err == nil {
+			return nil, errors.New(address + " 已存在且不是 socket 文件")
+		}
+		return net.Listen(network, address)
+	}
+
+	if upgrader != nil {
+		if ln, err := upgrader.Listen(network, address); err == nil {
+			return ln, nil
+		}
+		// fallback reuseport
+	}
+	return reuseport.Listen(network, address)
+}
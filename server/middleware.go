@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/accesslog"
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/utils/httperror"
+	"github.com/qist/tvgate/utils/ratelimit"
+)
+
+// Middleware 中间件工厂，根据配置包装下一个 handler
+type Middleware func(next http.Handler, cfg *config.Config) http.Handler
+
+var (
+	middlewareMu       sync.RWMutex
+	middlewareRegistry = map[string]Middleware{}
+)
+
+// RegisterMiddleware 注册命名中间件，配置中的 global 与 chain 通过名称引用；
+// 其它包可在 init 中注册自定义中间件，同名时替换内置的
+func RegisterMiddleware(name string, m Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	middlewareRegistry[strings.ToLower(name)] = m
+}
+
+func getMiddleware(name string) (Middleware, bool) {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+	m, ok := middlewareRegistry[strings.ToLower(name)]
+	return m, ok
+}
+
+func init() {
+	RegisterMiddleware("security_headers", func(next http.Handler, _ *config.Config) http.Handler {
+		return SecurityHeaders(next)
+	})
+	RegisterMiddleware("auth", TokenAuth)
+	RegisterMiddleware("rate_limit", RateLimit)
+	RegisterMiddleware("cors", CORS)
+	RegisterMiddleware("logging", AccessLogging)
+	RegisterMiddleware("compression", Compression)
+}
+
+// defaultMiddlewareRoutes 未配置中间件链时的默认行为
+var defaultMiddlewareRoutes = []config.MiddlewareRoute{
+	{PathPrefix: "/", Chain: []string{"security_headers"}},
+}
+
+// chainRoute 已构建好的路径前缀中间件链
+type chainRoute struct {
+	prefix  string
+	handler http.Handler
+}
+
+// BuildMiddlewareChain 按路径前缀为 mux 组装中间件链，匹配最长前缀，
+// 链中顺序即执行顺序（第一个最先执行）；global 中的中间件对所有路径生效，在路径前缀的链之前执行
+func BuildMiddlewareChain(next http.Handler, cfg *config.Config) http.Handler {
+	routes := cfg.Middleware.Routes
+	if len(routes) == 0 {
+		routes = defaultMiddlewareRoutes
+	}
+
+	built := make([]chainRoute, 0, len(routes))
+	for _, route := range routes {
+		prefix := route.PathPrefix
+		if prefix == "" {
+			prefix = "/"
+		}
+		built = append(built, chainRoute{prefix: prefix, handler: wrapChain(next, route.Chain, cfg, "路径前缀 "+prefix)})
+	}
+
+	// 最长前缀优先
+	sort.SliceStable(built, func(i, j int) bool {
+		return len(built[i].prefix) > len(built[j].prefix)
+	})
+
+	dispatch := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range built {
+			if strings.HasPrefix(r.URL.Path, route.prefix) {
+				route.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+	return wrapChain(dispatch, cfg.Middleware.Global, cfg, "global")
+}
+
+// wrapChain 按链中顺序包装 next，未知的中间件记录日志后忽略
+func wrapChain(next http.Handler, chain []string, cfg *config.Config, where string) http.Handler {
+	h := next
+	for i := len(chain) - 1; i >= 0; i-- {
+		name := chain[i]
+		m, ok := getMiddleware(name)
+		if !ok {
+			logger.LogPrintf("⚠️ 未知中间件 %q，已忽略 (%s)", name, where)
+			continue
+		}
+		h = m(h, cfg)
+	}
+	return h
+}
+
+// TokenAuth 全局 token 校验中间件
+func TokenAuth(next http.Handler, _ *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tm := auth.GetGlobalTokenManager()
+		if tm == nil || !tm.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tokenParam := "my_token"
+		if tm.TokenParamName != "" {
+			tokenParam = tm.TokenParamName
+		}
+		token := r.URL.Query().Get(tokenParam)
+		clientIP := monitor.GetClientIP(r)
+		connID := clientIP + "_" + r.URL.Path
+		if !tm.ValidateSignedURL(r) && !tm.ValidateToken(r, token, connID, clientIP) {
+			httperror.Error(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+		tm.KeepAlive(token, connID, clientIP, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit 按客户端 IP 的请求频率限制
+func RateLimit(next http.Handler, cfg *config.Config) http.Handler {
+	rl := cfg.Middleware.RateLimit
+	if rl.RequestsPerSecond <= 0 {
+		return next
+	}
+	limiter := ratelimit.NewLimiter(rl.RequestsPerSecond, rl.Burst)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.Allow(monitor.GetClientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			httperror.Error(w, r, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLogging 请求日志
+func AccessLogging(next http.Handler, _ *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.LogPrintf("%s %s %s %d %dB %s", monitor.GetClientIP(r), r.Method, r.URL.Path, rec.status, rec.bytes, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// AccessLog 写访问日志，在 IP 访问控制和中间件链之外执行，被拒绝的请求同样记录；
+// 播放会话在连接断开时记录一行，包含时长和发送的字节数
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accesslog.Default.Enabled(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// 转发中断时 handler 可能以 panic(http.ErrAbortHandler) 结束，同样记录
+		defer func() {
+			accesslog.Default.Write(accesslog.Entry{
+				Time:      start,
+				ClientIP:  monitor.GetClientIP(r),
+				Method:    r.Method,
+				URI:       r.RequestURI,
+				Proto:     r.Proto,
+				Host:      r.Host,
+				Status:    rec.status,
+				Bytes:     rec.bytes,
+				Duration:  time.Since(start),
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			})
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Tracing 为每个请求开始一个 server span，并记录响应第一个字节发出的时间，
+// 频道起播慢时可据此区分是回源、选择代理还是等待组播数据
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+routeName(r.URL.Path), tracing.KindServer)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("url.path", r.URL.Path)
+		span.SetAttr("client.address", monitor.GetClientIP(r))
+		span.SetAttr("user_agent.original", r.UserAgent())
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		rec.firstWrite = func() { span.AddEvent("first_byte") }
+		defer func() {
+			span.SetAttr("http.response.body.size", rec.bytes)
+			span.SetHTTPStatus(rec.status)
+			span.End()
+		}()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+	})
+}
+
+// routeName span 名称使用路由类型而不是完整路径，避免每个频道一个名称
+func routeName(path string) string {
+	for _, prefix := range []string{"/udp/", "/rtp/", "/rtsp/"} {
+		if strings.HasPrefix(path, prefix) {
+			return prefix
+		}
+	}
+	return "proxy"
+}
+
+// statusRecorder 记录响应状态码和字节数，保留 Flush/Hijack 能力
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	firstWrite  func() // 第一次写入响应体时调用
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	n, err := s.ResponseWriter.Write(b)
+	if n > 0 && s.firstWrite != nil {
+		s.firstWrite()
+		s.firstWrite = nil
+	}
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := s.ResponseWriter.(http.Hijacker); ok {
+		if !s.wroteHeader {
+			// WebSocket 等协议升级后响应由 handler 直接写入连接
+			s.status = http.StatusSwitchingProtocols
+			s.wroteHeader = true
+		}
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// 可压缩的内容类型，视频分片等二进制流不压缩
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/dash+xml",
+	"application/vnd.apple.mpegurl",
+	"application/x-mpegurl",
+	"audio/mpegurl",
+	"audio/x-mpegurl",
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compression 对文本类响应（m3u8/mpd/json/html 等）进行 gzip 压缩
+func Compression(next http.Handler, _ *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") ||
+			r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// gzipResponseWriter 在写响应头时根据 Content-Type 决定是否压缩
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	decided     bool
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) decide() {
+	if g.decided {
+		return
+	}
+	g.decided = true
+	h := g.ResponseWriter.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if h.Get("Content-Encoding") != "" || !isCompressible(h.Get("Content-Type")) {
+		return
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	if code != http.StatusNoContent && code != http.StatusNotModified && code >= http.StatusOK {
+		g.decide()
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		if g.ResponseWriter.Header().Get("Content-Type") == "" {
+			g.ResponseWriter.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := g.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) Close() {
+	if g.gz != nil {
+		_ = g.gz.Close()
+	}
+}
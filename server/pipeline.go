@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// 内置阶段的顺序，数值小的在外层先执行；第三方阶段取两个内置阶段之间的值即可插入其中
+const (
+	StageAccessLog = 100 // 访问日志，记录包括被拒绝在内的全部请求
+	StageHealth    = 200 // 健康检查接口，不受访问控制限制
+	StageTracing   = 300 // 链路追踪
+	StageACME      = 400 // ACME HTTP-01 验证请求
+	StageIPAccess  = 500 // IP 访问控制
+	StageCORS      = 600 // middleware.cors.enabled 时的全局跨域策略
+	StageLimits    = 700 // 请求大小限制
+	StageTimeouts  = 800 // 按路由的超时
+)
+
+// Stage 对所有请求生效的处理阶段，RegisterMux 按 Order 从外到内组装，最内层为按路径前缀的中间件链
+type Stage struct {
+	Name  string
+	Order int
+	Wrap  func(next http.Handler, addr string, cfg *config.Config) http.Handler
+}
+
+// Route 额外的路由，在提供 Feature 功能的监听地址上挂载
+type Route struct {
+	Feature string                                // config.FeatureMonitor、FeatureWeb、FeatureJX 或 FeatureProxy，为空时所有监听地址都挂载
+	Pattern string                                // http.ServeMux 的路由模式，如 /plugin/
+	Handler func(cfg *config.Config) http.Handler // 每次组装路由（包括配置重载）时调用，返回 nil 时不挂载
+}
+
+var (
+	pipelineMu sync.RWMutex
+	stages     = map[string]Stage{}
+	routes     []Route
+)
+
+// RegisterStage 注册处理阶段，在 init 中调用；同名阶段替换已注册的，可用于替换内置阶段
+func RegisterStage(s Stage) {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	stages[s.Name] = s
+}
+
+// RegisterRoute 注册额外的路由，在 init 中调用；下次组装路由时生效
+func RegisterRoute(r Route) {
+	pipelineMu.Lock()
+	defer pipelineMu.Unlock()
+	routes = append(routes, r)
+}
+
+func init() {
+	RegisterStage(Stage{Name: "access_log", Order: StageAccessLog, Wrap: func(next http.Handler, _ string, _ *config.Config) http.Handler {
+		return AccessLog(next)
+	}})
+	RegisterStage(Stage{Name: "health", Order: StageHealth, Wrap: func(next http.Handler, _ string, cfg *config.Config) http.Handler {
+		return Health(cfg, next)
+	}})
+	RegisterStage(Stage{Name: "tracing", Order: StageTracing, Wrap: func(next http.Handler, _ string, _ *config.Config) http.Handler {
+		return Tracing(next)
+	}})
+	RegisterStage(Stage{Name: "acme", Order: StageACME, Wrap: func(next http.Handler, addr string, cfg *config.Config) http.Handler {
+		return acmeHTTPHandler(addr, cfg, next)
+	}})
+	RegisterStage(Stage{Name: "ip_access", Order: StageIPAccess, Wrap: func(next http.Handler, _ string, cfg *config.Config) http.Handler {
+		return IPAccess(next, cfg)
+	}})
+	RegisterStage(Stage{Name: "cors", Order: StageCORS, Wrap: func(next http.Handler, _ string, cfg *config.Config) http.Handler {
+		return GlobalCORS(next, cfg)
+	}})
+	RegisterStage(Stage{Name: "limits", Order: StageLimits, Wrap: func(next http.Handler, _ string, cfg *config.Config) http.Handler {
+		return RequestLimits(next, cfg)
+	}})
+	RegisterStage(Stage{Name: "timeouts", Order: StageTimeouts, Wrap: func(next http.Handler, _ string, cfg *config.Config) http.Handler {
+		return RouteTimeouts(next, cfg)
+	}})
+}
+
+// buildStages 按 Order 从内到外包装 next，Order 相同时按名称排序
+func buildStages(next http.Handler, addr string, cfg *config.Config) http.Handler {
+	pipelineMu.RLock()
+	list := make([]Stage, 0, len(stages))
+	for _, s := range stages {
+		list = append(list, s)
+	}
+	pipelineMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Order != list[j].Order {
+			return list[i].Order > list[j].Order
+		}
+		return list[i].Name > list[j].Name
+	})
+	h := next
+	for _, s := range list {
+		h = s.Wrap(h, addr, cfg)
+	}
+	return h
+}
+
+// muxFeatures 监听地址提供的功能：listeners 按配置；只有 port 时提供全部功能；
+// 同时配置了 http_port 或 https_port 时 port 只提供状态页与 Web 管理，新端口提供视频解析与代理
+func muxFeatures(addr string, cfg *config.Config) []string {
+	if l := findListener(cfg, addr); l != nil {
+		if len(l.Features) == 0 {
+			return config.ListenerFeatures
+		}
+		return l.Features
+	}
+	var httpAddr, httpsAddr string
+	if cfg.Server.HTTPPort > 0 {
+		httpAddr = fmt.Sprintf(":%d", cfg.Server.HTTPPort)
+	}
+	if cfg.Server.TLS.HTTPSPort > 0 {
+		httpsAddr = fmt.Sprintf(":%d", cfg.Server.TLS.HTTPSPort)
+	}
+	hasNewPort := httpAddr != "" || httpsAddr != ""
+	oldAddr := fmt.Sprintf(":%d", cfg.Server.Port)
+	switch {
+	case !hasNewPort && addr == oldAddr:
+		return config.ListenerFeatures
+	case hasNewPort && addr == oldAddr:
+		return []string{config.FeatureMonitor, config.FeatureWeb}
+	case hasNewPort && (addr == httpAddr || addr == httpsAddr):
+		return []string{config.FeatureJX, config.FeatureProxy}
+	default:
+		// 兜底只开监控与管理，避免空路由
+		return []string{config.FeatureMonitor, config.FeatureWeb}
+	}
+}
+
+// registerFeatures 注册功能对应的内置路由与 RegisterRoute 注册的路由
+func registerFeatures(mux *http.ServeMux, cfg *config.Config, features []string) {
+	if hasFeature(features, config.FeatureMonitor) {
+		registerMonitor(mux, cfg)
+	}
+	if hasFeature(features, config.FeatureWeb) {
+		registerWeb(mux, cfg)
+	}
+	if hasFeature(features, config.FeatureJX) {
+		registerJX(mux, cfg)
+	}
+	if hasFeature(features, config.FeatureProxy) {
+		registerProxy(mux, cfg)
+	}
+
+	pipelineMu.RLock()
+	extra := append([]Route(nil), routes...)
+	pipelineMu.RUnlock()
+	for _, r := range extra {
+		if r.Feature != "" && !hasFeature(features, r.Feature) {
+			continue
+		}
+		h := r.Handler(cfg)
+		if h == nil {
+			continue
+		}
+		handleRoute(mux, r.Pattern, h)
+	}
+}
+
+// handleRoute 挂载路由，与已有路由冲突时记录日志而不是退出
+func handleRoute(mux *http.ServeMux, pattern string, h http.Handler) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.LogPrintf("❌ 注册路由 %s 失败: %v", pattern, err)
+		}
+	}()
+	mux.Handle(pattern, h)
+}
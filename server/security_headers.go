@@ -31,6 +31,9 @@ func SecurityHeaders(next http.Handler) http.Handler {
 
 		case strings.HasPrefix(r.URL.Path, "/rtp/"):
 
+		case r.Header.Get("Upgrade") != "":
+			// WebSocket 等协议升级请求不能关闭连接
+
 		default:
 			if r.ProtoMajor == 1 {
 				w.Header().Set("Connection", "close")
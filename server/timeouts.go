@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// RouteTimeouts 按路径前缀（最长前缀优先）设置请求的超时：
+// 非流式路由的 write 是整个请求的截止时间，到期后断开连接并取消请求；
+// 流式路由每次写入前重新设置写截止时间，只断开接收过慢的客户端，idle 内没有数据写出时取消请求
+func RouteTimeouts(next http.Handler, cfg *config.Config) http.Handler {
+	routes := make([]config.RouteTimeout, 0, len(cfg.Server.Timeouts.Routes))
+	for _, rt := range cfg.Server.Timeouts.Routes {
+		if rt.PathPrefix == "" {
+			rt.PathPrefix = "/"
+		}
+		if rt.Read > 0 || rt.Write > 0 || rt.Stream && rt.Idle > 0 {
+			routes = append(routes, rt)
+		}
+	}
+	if len(routes) == 0 {
+		return next
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rt := range routes {
+			if strings.HasPrefix(r.URL.Path, rt.PathPrefix) {
+				serveWithTimeouts(w, r, next, rt)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveWithTimeouts 按一条路由配置处理请求
+func serveWithTimeouts(w http.ResponseWriter, r *http.Request, next http.Handler, rt config.RouteTimeout) {
+	start := time.Now()
+	rc := http.NewResponseController(w)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	tw := &timeoutWriter{ResponseWriter: w, rc: rc}
+	if rt.Read > 0 && r.Body != nil && r.Body != http.NoBody {
+		// 读完请求体后清除读截止时间，否则等待客户端断开的后台读取到期时会取消请求
+		_ = rc.SetReadDeadline(start.Add(rt.Read))
+		r.Body = &deadlineBody{ReadCloser: r.Body, rc: rc}
+	}
+	if rt.Stream {
+		tw.write = rt.Write
+		if rt.Idle > 0 {
+			tw.idle = rt.Idle
+			tw.timer = time.AfterFunc(rt.Idle, func() {
+				logger.LogPrintf("⚠️ %s 超过 %s 没有数据发送给客户端，已断开", r.URL.Path, rt.Idle)
+				cancel()
+			})
+		}
+	} else if rt.Write > 0 {
+		_ = rc.SetWriteDeadline(start.Add(rt.Write))
+		tw.timer = time.AfterFunc(rt.Write, cancel)
+	}
+	defer func() {
+		if tw.timer != nil {
+			tw.timer.Stop()
+		}
+		if !tw.hijacked {
+			// 写截止时间不会在下一个请求开始时重置，keep-alive 连接上需要清除
+			_ = rc.SetWriteDeadline(time.Time{})
+		}
+	}()
+
+	next.ServeHTTP(tw, r.WithContext(ctx))
+}
+
+// deadlineBody 请求体读完或出错时清除读截止时间
+type deadlineBody struct {
+	io.ReadCloser
+	rc   *http.ResponseController
+	done bool
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !b.done {
+		b.done = true
+		_ = b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+// timeoutWriter 流式路由每次写入前延长写截止时间并重置空闲计时，保留 Flush/Hijack 能力
+type timeoutWriter struct {
+	http.ResponseWriter
+	rc       *http.ResponseController
+	write    time.Duration // 单次写入超时，只用于流式路由
+	idle     time.Duration // 空闲超时，只用于流式路由
+	timer    *time.Timer   // 到期时取消请求
+	hijacked bool
+}
+
+func (t *timeoutWriter) arm() {
+	if t.write > 0 {
+		_ = t.rc.SetWriteDeadline(time.Now().Add(t.write))
+	}
+	if t.idle > 0 {
+		t.timer.Reset(t.idle)
+	}
+}
+
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.arm()
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *timeoutWriter) Flush() {
+	t.arm()
+	_ = t.rc.Flush()
+}
+
+func (t *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := t.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, brw, err := hj.Hijack()
+	if err == nil {
+		// 升级后的连接（如 WebSocket）由 handler 自行管理超时
+		t.hijacked = true
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		_ = conn.SetDeadline(time.Time{})
+	}
+	return conn, brw, err
+}
+
+func (t *timeoutWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,470 @@
+package stats
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/groupstats"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// 序列类型，同时作为数据库中的顶层 bucket 名
+const (
+	KindChannel = "channel" // 频道（组播/RTP/RTSP Hub）
+	KindGroup   = "group"   // 代理组
+)
+
+// 清理过期数据的间隔
+const pruneInterval = time.Hour
+
+// ErrDisabled 未启用历史统计
+var ErrDisabled = errors.New("未启用历史统计")
+
+// Point 一个采样点；按时间段聚合时 viewers 与 bitrate 取平均，errors 求和
+type Point struct {
+	Time    time.Time `json:"time"`
+	Viewers float64   `json:"viewers"` // 频道为客户端数，代理组为经由该组的连接数
+	Bitrate uint64    `json:"bitrate"` // 码率 (bit/s)，频道为接收码率，代理组为代理流量
+	Errors  uint64    `json:"errors"`  // 频道为发送超时丢弃的包数，代理组为不可用的代理数
+}
+
+// Series 序列概要
+type Series struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// record 数据库中保存的采样值，键为大端序 Unix 秒
+type record struct {
+	Viewers float64 `json:"v"`
+	Bitrate uint64  `json:"b"`
+	Errors  uint64  `json:"e"`
+}
+
+// Store 定时采样并保存到 bbolt 数据库
+type Store struct {
+	mu        sync.Mutex
+	cfg       config.StatsConfig
+	path      string
+	db        *bolt.DB
+	stop      chan struct{}
+	interval  time.Duration
+	lastPrune time.Time
+}
+
+// sampleState 单个采样 goroutine 上次采样的累计值
+type sampleState struct {
+	prevAt     time.Time
+	prevHubs   map[string]monitor.HubUsage
+	prevGroups map[string]uint64
+}
+
+// Default 全局实例
+var Default = &Store{}
+
+// defaultFile 默认数据库文件，与配置文件放在同一目录
+func defaultFile() string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	return filepath.Join(dir, "stats.db")
+}
+
+// FilePath 返回配置对应的数据库文件
+func FilePath(cfg config.StatsConfig) string {
+	if cfg.File != "" {
+		return cfg.File
+	}
+	return defaultFile()
+}
+
+// Configure 应用配置：启用时按采样间隔启动采样，数据库文件变化时重新打开，禁用时关闭数据库
+func (s *Store) Configure(cfg config.StatsConfig) {
+	path := FilePath(cfg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	if !cfg.Enabled || path != s.path {
+		s.closeLocked()
+	}
+	if !cfg.Enabled {
+		return
+	}
+	s.path = path
+	if s.db == nil {
+		// 平滑升级时旧进程可能仍持有数据库，打开失败时由采样重试
+		s.openLocked()
+	}
+	if s.stop == nil || s.interval != cfg.Interval {
+		if s.stop != nil {
+			close(s.stop)
+		}
+		s.stop = make(chan struct{})
+		s.interval = cfg.Interval
+		go s.run(s.stop, cfg.Interval)
+	}
+}
+
+func (s *Store) openLocked() {
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		logger.LogPrintf("❌ 打开历史统计数据库失败: %v", err)
+		return
+	}
+	s.db = db
+	logger.LogPrintf("✅ 历史统计数据库: %s", s.path)
+}
+
+// Close 停止采样并关闭数据库
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Store) closeLocked() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	s.path = ""
+}
+
+// Backup 将数据库的一致性快照写入 w
+func (s *Store) Backup(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return ErrDisabled
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// Restore 用备份替换 path 处的数据库文件；数据库正在使用该文件时先关闭，替换后重新打开
+func (s *Store) Restore(path string, r io.Reader) error {
+	tmp := path + ".restore"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// 打开一次以确认是有效的 bbolt 数据库
+		var db *bolt.DB
+		if db, err = bolt.Open(tmp, 0o600, &bolt.Options{Timeout: time.Second, ReadOnly: true}); err == nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reopen := s.db != nil && s.path == path
+	if reopen {
+		s.db.Close()
+		s.db = nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if reopen {
+		s.openLocked()
+	}
+	logger.LogPrintf("✅ 已从备份恢复历史统计数据库: %s", path)
+	return nil
+}
+
+func (s *Store) run(stop chan struct{}, interval time.Duration) {
+	st := &sampleState{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample(st, stop, time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sample 采样所有频道与代理组并写入数据库，码率与错误数按与上次采样的差值计算
+func (s *Store) sample(st *sampleState, stop chan struct{}, now time.Time) {
+	hubs := make(map[string]monitor.HubUsage)
+	for _, h := range monitor.GetTopHubs("", 0) {
+		hubs[h.Name] = h
+	}
+	groupBytes := make(map[string]uint64)
+	for _, u := range groupstats.GetGroupUsage() {
+		groupBytes[u.Name] = u.TotalBytes
+	}
+
+	elapsed := now.Sub(st.prevAt).Seconds()
+	points := map[string]map[string]record{
+		KindChannel: make(map[string]record),
+		KindGroup:   make(map[string]record),
+	}
+	if !st.prevAt.IsZero() && elapsed > 0 {
+		for name, h := range hubs {
+			prev, ok := st.prevHubs[name]
+			if !ok || h.Bytes < prev.Bytes || h.Drops < prev.Drops {
+				// 新出现或重建的频道，下次采样再记录
+				continue
+			}
+			points[KindChannel][name] = record{
+				Viewers: float64(h.Clients),
+				Bitrate: uint64(float64(h.Bytes-prev.Bytes) * 8 / elapsed),
+				Errors:  h.Drops - prev.Drops,
+			}
+		}
+		for name, g := range groupSnapshot() {
+			bytes := groupBytes[name]
+			prev, ok := st.prevGroups[name]
+			if !ok || bytes < prev {
+				continue
+			}
+			g.Bitrate = uint64(float64(bytes-prev) * 8 / elapsed)
+			points[KindGroup][name] = g
+		}
+	}
+	st.prevAt = now
+	st.prevHubs = hubs
+	st.prevGroups = groupBytes
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != stop {
+		// 配置已变化，该采样 goroutine 即将退出
+		return
+	}
+	if s.db == nil {
+		if s.openLocked(); s.db == nil {
+			return
+		}
+	}
+	key := timeKey(now)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for kind, series := range points {
+			root, err := tx.CreateBucketIfNotExists([]byte(kind))
+			if err != nil {
+				return err
+			}
+			for name, rec := range series {
+				b, err := root.CreateBucketIfNotExists([]byte(name))
+				if err != nil {
+					return err
+				}
+				data, _ := json.Marshal(rec)
+				if err := b.Put(key, data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.LogPrintf("❌ 写入历史统计失败: %v", err)
+	}
+
+	if now.Sub(s.lastPrune) >= pruneInterval {
+		s.lastPrune = now
+		if err := s.pruneLocked(now.Add(-s.cfg.Retention)); err != nil {
+			logger.LogPrintf("❌ 清理历史统计失败: %v", err)
+		}
+	}
+}
+
+// groupSnapshot 代理组的连接数与不可用代理数
+func groupSnapshot() map[string]record {
+	config.CfgMu.RLock()
+	groups := make(map[string]*config.ProxyGroupConfig, len(config.Cfg.ProxyGroups))
+	for name, g := range config.Cfg.ProxyGroups {
+		groups[name] = g
+	}
+	config.CfgMu.RUnlock()
+
+	result := make(map[string]record, len(groups))
+	for name, g := range groups {
+		if g == nil {
+			continue
+		}
+		var rec record
+		if g.Stats != nil {
+			g.Stats.RLock()
+			for _, ps := range g.Stats.ProxyStats {
+				if ps == nil {
+					continue
+				}
+				rec.Viewers += float64(atomic.LoadInt64(&ps.ActiveConns))
+				tested := ps.ResponseTime > 0 || ps.FailCount > 0
+				if ps.IsHealthDown() || (tested && !ps.Alive) {
+					rec.Errors++
+				}
+			}
+			g.Stats.RUnlock()
+		}
+		result[name] = rec
+	}
+	return result
+}
+
+// pruneLocked 删除 before 之前的采样，删除后为空的序列一并删除
+func (s *Store) pruneLocked(before time.Time) error {
+	limit := timeKey(before)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, kind := range []string{KindChannel, KindGroup} {
+			root := tx.Bucket([]byte(kind))
+			if root == nil {
+				continue
+			}
+			var empty [][]byte
+			err := root.ForEachBucket(func(name []byte) error {
+				b := root.Bucket(name)
+				c := b.Cursor()
+				for k, _ := c.First(); k != nil && string(k) < string(limit); k, _ = c.First() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+				}
+				if k, _ := c.First(); k == nil {
+					empty = append(empty, append([]byte(nil), name...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, name := range empty {
+				if err := root.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// List 列出 since 之后有数据的序列，按最后采样时间从新到旧排序
+func (s *Store) List(kind string, since time.Time) ([]Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil, ErrDisabled
+	}
+	list := make([]Series, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(kind))
+		if root == nil {
+			return nil
+		}
+		return root.ForEachBucket(func(name []byte) error {
+			k, _ := root.Bucket(name).Cursor().Last()
+			if k == nil {
+				return nil
+			}
+			if last := keyTime(k); !last.Before(since) {
+				list = append(list, Series{Kind: kind, Name: string(name), LastSeen: last})
+			}
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].LastSeen.Equal(list[j].LastSeen) {
+			return list[i].LastSeen.After(list[j].LastSeen)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, err
+}
+
+// Query 查询序列在 [since, until) 内的采样，step 大于 0 时按 step 聚合
+func (s *Store) Query(kind, name string, since, until time.Time, step time.Duration) ([]Point, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil, ErrDisabled
+	}
+
+	points := make([]Point, 0)
+	var n int // 当前聚合点内的采样数
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(kind))
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		end := string(timeKey(until))
+		for k, v := c.Seek(timeKey(since)); k != nil && string(k) < end; k, v = c.Next() {
+			var rec record
+			if json.Unmarshal(v, &rec) != nil {
+				continue
+			}
+			t := keyTime(k)
+			if step > 0 {
+				t = t.Truncate(step)
+			}
+			if last := len(points) - 1; last >= 0 && points[last].Time.Equal(t) {
+				// 累加到当前聚合点，viewers 与 bitrate 按采样数求平均
+				p := &points[last]
+				p.Viewers = (p.Viewers*float64(n) + rec.Viewers) / float64(n+1)
+				p.Bitrate = uint64((float64(p.Bitrate)*float64(n) + float64(rec.Bitrate)) / float64(n+1))
+				p.Errors += rec.Errors
+				n++
+				continue
+			}
+			points = append(points, Point{Time: t, Viewers: rec.Viewers, Bitrate: rec.Bitrate, Errors: rec.Errors})
+			n = 1
+		}
+		return nil
+	})
+	return points, err
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.Unix()))
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(key)), 0)
+}
+
+// Configure 应用全局实例配置
+func Configure(cfg config.StatsConfig) { Default.Configure(cfg) }
+
+// Close 关闭全局实例
+func Close() { Default.Close() }
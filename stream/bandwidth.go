@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/ratelimit"
+)
+
+// 每种速率一个按客户端分桶的限速器
+var (
+	bandwidthMu       sync.Mutex
+	bandwidthLimiters = make(map[int]*ratelimit.Limiter)
+)
+
+// resolveClientKbps 按 token > 频道 > 全局 的优先级确定客户端限速值
+func resolveClientKbps(r *http.Request) int {
+	config.CfgMu.RLock()
+	bw := config.Cfg.Bandwidth
+	config.CfgMu.RUnlock()
+
+	if len(bw.Tokens) > 0 {
+		tokenParam := "my_token"
+		if tm := auth.GetGlobalTokenManager(); tm != nil && tm.TokenParamName != "" {
+			tokenParam = tm.TokenParamName
+		}
+		if token := r.URL.Query().Get(tokenParam); token != "" {
+			if kbps, ok := bw.Tokens[token]; ok {
+				return kbps
+			}
+		}
+	}
+
+	best := -1
+	kbps := bw.PerClientKbps
+	for prefix, v := range bw.Channels {
+		if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > best {
+			best = len(prefix)
+			kbps = v
+		}
+	}
+	return kbps
+}
+
+func bandwidthLimiter(kbps int) *ratelimit.Limiter {
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	l, ok := bandwidthLimiters[kbps]
+	if !ok {
+		bytesPerSec := float64(kbps) * 1000 / 8
+		// 允许 1 秒的突发，避免播放起步阶段卡顿
+		l = ratelimit.NewLimiter(bytesPerSec, int(bytesPerSec))
+		bandwidthLimiters[kbps] = l
+	}
+	return l
+}
+
+// LimitWriter 按配置对客户端响应限速，同一客户端 IP 的多个连接共享带宽；未配置限速时原样返回
+func LimitWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	kbps := resolveClientKbps(r)
+	if kbps <= 0 {
+		return w
+	}
+	return &rateLimitedWriter{
+		ResponseWriter: w,
+		bucket:         bandwidthLimiter(kbps).Get(monitor.GetClientIP(r)),
+		r:              r,
+	}
+}
+
+// rateLimitedWriter 写入前从令牌桶取走与数据等量的令牌
+type rateLimitedWriter struct {
+	http.ResponseWriter
+	bucket *ratelimit.TokenBucket
+	r      *http.Request
+}
+
+// 单次取令牌的最大字节数，大块数据拆分写入使发送更平滑
+const rateLimitChunk = 32 * 1024
+
+func (rw *rateLimitedWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > rateLimitChunk {
+			chunk = chunk[:rateLimitChunk]
+		}
+		if err := rw.bucket.Wait(rw.r.Context(), len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := rw.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+func (rw *rateLimitedWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *rateLimitedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (rw *rateLimitedWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
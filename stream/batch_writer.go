@@ -0,0 +1,71 @@
+package stream
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/qist/tvgate/config"
+)
+
+// batchWriter 把多个 TS 包合并为一次写出：累计达到 size 字节时立即写出并 Flush，
+// 不足时由调用方按 delay 定时调用 flush。高码率时大幅减少 chunked 分块与系统调用次数
+type batchWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	stats   *clientStats
+	size    int
+	buf     []byte
+	packets int // buf 中的包数
+}
+
+func newBatchWriter(w io.Writer, flusher http.Flusher, stats *clientStats, size int) *batchWriter {
+	return &batchWriter{
+		w:       w,
+		flusher: flusher,
+		stats:   stats,
+		size:    size,
+		buf:     make([]byte, 0, size+tsPacketSize*7),
+	}
+}
+
+// add 追加一个包，累计达到批量大小时写出
+func (b *batchWriter) add(data []byte) error {
+	b.buf = append(b.buf, data...)
+	b.packets++
+	if len(b.buf) < b.size {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush 写出累计的数据并 Flush
+func (b *batchWriter) flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	n, err := b.w.Write(b.buf)
+	b.stats.wrote(n, b.packets)
+	if err != nil {
+		// 保留 packets，断线续播据此计算未写出的帧数
+		return err
+	}
+	b.buf = b.buf[:0]
+	b.packets = 0
+	b.flusher.Flush()
+	return nil
+}
+
+// writeBatchConfig 返回 HTTP 客户端批量写出的大小与最长等待时间
+func writeBatchConfig() (int, time.Duration) {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	size, delay := config.Cfg.Server.WriteBatchKB<<10, config.Cfg.Server.WriteBatchLatency
+	if size <= 0 {
+		size = 64 << 10
+	}
+	if delay <= 0 {
+		delay = 50 * time.Millisecond
+	}
+	return size, delay
+}
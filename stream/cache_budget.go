@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// cacheBudget 所有 Hub 缓存（RingBuffer）共用的内存预算。
+// 缓存按实际占用的字节计数，总量超过上限时从占用最多的缓存开始淘汰最旧的帧，
+// 回收到上限的 90%，避免频道很多时缓存占满内存
+type cacheBudget struct {
+	limit      atomic.Int64
+	used       atomic.Int64
+	evicted    atomic.Uint64 // 累计因超出预算淘汰的帧数
+	reclaiming atomic.Bool
+
+	mu      sync.Mutex
+	buffers map[*RingBuffer]struct{} // 非空的缓存
+}
+
+var globalCacheBudget = &cacheBudget{buffers: make(map[*RingBuffer]struct{})}
+
+func init() {
+	monitor.RegisterCacheMemoryProvider(globalCacheBudget.usage)
+}
+
+// SetCacheMemoryLimit 设置 Hub 缓存的内存上限(MB)，<=0 表示不限制；上限变化时返回 true
+func SetCacheMemoryLimit(mb int) bool {
+	limit := int64(max(mb, 0)) << 20
+	if globalCacheBudget.limit.Swap(limit) == limit {
+		return false
+	}
+	globalCacheBudget.check()
+	return true
+}
+
+// update 记录缓存占用的变化，缓存变为非空时登记、清空时注销；调用方持有 r.lock
+func (b *cacheBudget) update(r *RingBuffer, delta int64) {
+	b.used.Add(delta)
+	if (r.bytes > 0) != r.tracked {
+		r.tracked = r.bytes > 0
+		b.mu.Lock()
+		if r.tracked {
+			b.buffers[r] = struct{}{}
+		} else {
+			delete(b.buffers, r)
+		}
+		b.mu.Unlock()
+	}
+	if delta > 0 {
+		b.check()
+	}
+}
+
+// check 超出上限时在后台回收，同一时间只有一个回收任务
+func (b *cacheBudget) check() {
+	limit := b.limit.Load()
+	if limit <= 0 || b.used.Load() <= limit {
+		return
+	}
+	if b.reclaiming.CompareAndSwap(false, true) {
+		go b.reclaim()
+	}
+}
+
+// reclaim 按占用从多到少淘汰各缓存最旧的帧，每个缓存最少保留平均份额
+func (b *cacheBudget) reclaim() {
+	defer b.reclaiming.Store(false)
+
+	b.mu.Lock()
+	buffers := make([]*RingBuffer, 0, len(b.buffers))
+	for r := range b.buffers {
+		buffers = append(buffers, r)
+	}
+	b.mu.Unlock()
+	if len(buffers) == 0 {
+		return
+	}
+
+	sizes := make(map[*RingBuffer]int64, len(buffers))
+	for _, r := range buffers {
+		sizes[r] = r.Bytes()
+	}
+	sort.Slice(buffers, func(i, j int) bool { return sizes[buffers[i]] > sizes[buffers[j]] })
+
+	target := b.limit.Load() * 9 / 10
+	share := target / int64(len(buffers))
+	before := b.used.Load()
+	frames := 0
+	for _, r := range buffers {
+		excess := b.used.Load() - target
+		if excess <= 0 {
+			break
+		}
+		frames += r.trim(max(sizes[r]-excess, share))
+	}
+	if frames > 0 {
+		b.evicted.Add(uint64(frames))
+		logger.LogPrintf("⚠️ Hub 缓存超出内存上限 %dMB，已淘汰 %d 帧，占用 %dMB -> %dMB",
+			b.limit.Load()>>20, frames, before>>20, b.used.Load()>>20)
+	}
+}
+
+// usage 当前缓存占用，供状态接口与指标使用
+func (b *cacheBudget) usage() monitor.CacheMemory {
+	b.mu.Lock()
+	buffers := len(b.buffers)
+	b.mu.Unlock()
+	return monitor.CacheMemory{
+		Used:    b.used.Load(),
+		Limit:   b.limit.Load(),
+		Buffers: buffers,
+		Evicted: b.evicted.Load(),
+	}
+}
@@ -0,0 +1,208 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// MPD 清单最大读取大小
+const maxMPDSize = 10 << 20
+
+// IsDASHContent 判断响应是否为 DASH MPD 清单
+func IsDASHContent(contentType, urlPath string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "application/dash+xml") {
+		return true
+	}
+	// 部分源站以 text/xml、application/octet-stream 等类型返回 mpd
+	return strings.EqualFold(path.Ext(urlPath), ".mpd")
+}
+
+// 需要改写的 URL 属性：元素名 -> 属性列表
+var dashURLAttrs = map[string][]string{
+	"SegmentTemplate":     {"media", "initialization", "index", "bitstreamSwitching"},
+	"SegmentURL":          {"media", "index"},
+	"Initialization":      {"sourceURL"},
+	"RepresentationIndex": {"sourceURL"},
+	"BitstreamSwitching":  {"sourceURL"},
+}
+
+// dashAttrRes 属性名 -> 匹配该属性的正则
+var dashAttrRes = func() map[string]*regexp.Regexp {
+	res := make(map[string]*regexp.Regexp)
+	for _, attrs := range dashURLAttrs {
+		for _, attr := range attrs {
+			res[attr] = regexp.MustCompile(`(\s` + attr + `\s*=\s*)(["'])(.*?)(["'])`)
+		}
+	}
+	return res
+}()
+
+// dashTemplateRe 匹配 SegmentTemplate 中的 $Number%05d$ 等占位符
+var dashTemplateRe = regexp.MustCompile(`\$[^$]*\$`)
+
+// dashEdit 对原始 MPD 的一处替换
+type dashEdit struct {
+	start, end int
+	text       string
+}
+
+// handleDASHManifest 改写 MPD 中的 BaseURL 与分片模板地址，使分片经由 tvgate 访问
+func handleDASHManifest(w http.ResponseWriter, r *http.Request, targetURL string, proxyResp *http.Response) {
+	body, err := io.ReadAll(io.LimitReader(proxyResp.Body, maxMPDSize))
+	if err != nil {
+		httperror.Error(w, r, "读取响应内容失败", http.StatusInternalServerError)
+		return
+	}
+
+	rw := newPlaylistRewriter(r, proxyResp, targetURL)
+	result, err := rewriteMPD(body, rw)
+	if err != nil {
+		// 解析失败时原样返回，避免影响播放
+		logger.LogPrintf("⚠️ MPD 解析失败，原样返回: %v", err)
+		result = body
+	}
+
+	CopyHeader(w.Header(), proxyResp.Header, r.ProtoMajor)
+	w.Header().Del("Content-Length")
+	storeCachedPlaylist(r, targetURL, proxyResp.StatusCode, proxyResp.Header, result)
+	w.WriteHeader(proxyResp.StatusCode)
+	_, _ = w.Write(result)
+}
+
+// rewriteMPD 按文档层级解析 BaseURL，并改写 BaseURL 文本与分片地址属性，
+// 仅替换对应片段，保留原始 XML 格式与命名空间
+func rewriteMPD(body []byte, rw *playlistRewriter) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+
+	// bases[i] 为第 i 层元素内生效的上游 BaseURL
+	bases := []*url.URL{rw.base}
+	var edits []dashEdit
+
+	inBaseURL := false
+	var baseText strings.Builder
+	baseTextStart, baseTextEnd := -1, -1
+
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			bases = append(bases, bases[len(bases)-1])
+			name := t.Name.Local
+			if name == "BaseURL" {
+				inBaseURL = true
+				baseText.Reset()
+				baseTextStart, baseTextEnd = -1, -1
+			}
+			if attrs, ok := dashURLAttrs[name]; ok {
+				raw := string(body[start:end])
+				if newRaw := rewriteDASHAttrs(raw, attrs, bases[len(bases)-1], rw); newRaw != raw {
+					edits = append(edits, dashEdit{start, end, newRaw})
+				}
+			}
+
+		case xml.CharData:
+			if inBaseURL {
+				if baseTextStart < 0 {
+					baseTextStart = start
+				}
+				baseTextEnd = end
+				baseText.Write(t)
+			}
+
+		case xml.EndElement:
+			if t.Name.Local == "BaseURL" && inBaseURL {
+				inBaseURL = false
+				// BaseURL 元素本身位于 bases 顶层，其父元素在下一层
+				parent := bases[len(bases)-2]
+				text := strings.TrimSpace(baseText.String())
+				if abs := rw.resolve(parent, text); abs != "" {
+					if u, err := url.Parse(abs); err == nil {
+						// 后续兄弟元素及子元素使用该 BaseURL
+						bases[len(bases)-2] = u
+					}
+					if baseTextStart >= 0 {
+						edits = append(edits, dashEdit{baseTextStart, baseTextEnd, html.EscapeString(rw.rewriteWithBase(parent, text))})
+					}
+				}
+			}
+			if len(bases) > 1 {
+				bases = bases[:len(bases)-1]
+			}
+		}
+	}
+
+	if len(edits) == 0 {
+		return body, nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	out.Grow(len(body) + len(edits)*64)
+	pos := 0
+	for _, e := range edits {
+		if e.start < pos {
+			continue
+		}
+		out.Write(body[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(body[pos:])
+	return out.Bytes(), nil
+}
+
+// rewriteDASHAttrs 改写元素起始标签中的 URL 属性
+func rewriteDASHAttrs(raw string, attrs []string, base *url.URL, rw *playlistRewriter) string {
+	for _, attr := range attrs {
+		re := dashAttrRes[attr]
+		raw = re.ReplaceAllStringFunc(raw, func(m string) string {
+			sub := re.FindStringSubmatch(m)
+			if sub[2] != sub[4] {
+				return m
+			}
+			value := html.UnescapeString(sub[3])
+			return sub[1] + sub[2] + html.EscapeString(rewriteDASHTemplate(value, base, rw)) + sub[4]
+		})
+	}
+	return raw
+}
+
+// rewriteDASHTemplate 改写带 $Number$ 等占位符的模板地址，占位符在解析期间保持原样
+func rewriteDASHTemplate(value string, base *url.URL, rw *playlistRewriter) string {
+	var placeholders []string
+	protected := dashTemplateRe.ReplaceAllStringFunc(value, func(m string) string {
+		placeholders = append(placeholders, m)
+		return fmt.Sprintf("tvgatetpl%d", len(placeholders)-1)
+	})
+
+	rewritten := rw.rewriteWithBase(base, protected)
+
+	for i := len(placeholders) - 1; i >= 0; i-- {
+		rewritten = strings.Replace(rewritten, fmt.Sprintf("tvgatetpl%d", i), placeholders[i], 1)
+	}
+	return rewritten
+}
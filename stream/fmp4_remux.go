@@ -0,0 +1,378 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg1audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+)
+
+const (
+	videoTimeScale  = 90000
+	previewPartSpan = 200 // 每个 fMP4 分片的最长时长（毫秒），越小延迟越低
+)
+
+// PreviewTrack 频道中的一条音视频轨道
+type PreviewTrack struct {
+	PID       uint16 `json:"pid"`
+	Codec     string `json:"codec"`
+	Video     bool   `json:"video"`
+	Supported bool   `json:"supported"` // 浏览器能否通过 MSE 播放
+}
+
+// PreviewInfo 开始输出 fMP4 前确定的流信息
+type PreviewInfo struct {
+	MIME   string         `json:"mime"` // 含 codecs 参数，用于 MediaSource.addSourceBuffer
+	Tracks []PreviewTrack `json:"tracks"`
+	Audio  int            `json:"audio"` // 选用的音轨在全部音轨中的序号，-1 表示无音频
+}
+
+// remuxTrack 输出中的一条 fMP4 轨道
+type remuxTrack struct {
+	id        int
+	timeScale uint32
+	codec     fmp4.Codec
+	codecStr  string
+
+	last     *fmp4.Sample // 等待下一个样本确定时长
+	lastTS   int64
+	samples  []*fmp4.Sample
+	baseTime uint64
+}
+
+// push 追加样本，ts 为相对起点的解码时间（轨道时间刻度）
+func (t *remuxTrack) push(s *fmp4.Sample, ts int64) {
+	if t.last != nil {
+		d := ts - t.lastTS
+		if d <= 0 {
+			d = 1
+		}
+		t.last.Duration = uint32(d)
+		if len(t.samples) == 0 {
+			t.baseTime = uint64(t.lastTS)
+		}
+		t.samples = append(t.samples, t.last)
+	}
+	t.last, t.lastTS = s, ts
+}
+
+// full 已缓存的样本是否达到一个分片的时长
+func (t *remuxTrack) full() bool {
+	if len(t.samples) == 0 {
+		return false
+	}
+	return uint64(t.lastTS)-t.baseTime >= uint64(t.timeScale)*previewPartSpan/1000
+}
+
+// fmp4Remuxer 将 MPEG-TS 转封装为 fMP4
+type fmp4Remuxer struct {
+	w      io.Writer
+	onInit func(PreviewInfo) error
+	info   PreviewInfo
+	td     mpegts.TimeDecoder
+
+	video *remuxTrack
+	audio *remuxTrack
+	hevc  bool
+	vps   []byte
+	sps   []byte
+	pps   []byte
+
+	started  bool
+	startDTS int64 // 起点（90kHz），之前的数据全部丢弃
+	seq      uint32
+}
+
+// RemuxFMP4 读取 MPEG-TS，转封装为 fMP4（初始化段 + 连续分片）写入 w，供浏览器 MSE 直接播放。
+// 支持 H264/H265 视频和 AAC/MP3 音频；audio 为选用的音轨序号，超出范围时使用第一条可播放的音轨。
+// 写出初始化段之前调用 onInit，返回错误时中止。
+func RemuxFMP4(r io.Reader, w io.Writer, audio int, onInit func(PreviewInfo) error) error {
+	reader := &mpegts.Reader{R: r}
+	if err := reader.Initialize(); err != nil {
+		return fmt.Errorf("解析节目信息失败: %w", err)
+	}
+	reader.OnDecodeError(func(error) {})
+
+	m := &fmp4Remuxer{w: w, onInit: onInit, info: PreviewInfo{Audio: -1}}
+	m.td.Initialize()
+
+	var audioTracks []*mpegts.Track
+	var audioSupported []bool
+	for _, track := range reader.Tracks() {
+		pt := PreviewTrack{PID: track.PID, Video: track.Codec.IsVideo()}
+		switch track.Codec.(type) {
+		case *mpegts.CodecH264:
+			pt.Codec, pt.Supported = "H264", m.video == nil
+			if pt.Supported {
+				m.video = &remuxTrack{id: 1, timeScale: videoTimeScale}
+				reader.OnDataH264(track, func(pts, dts int64, au [][]byte) error {
+					return m.onVideo(pts, dts, au)
+				})
+			}
+		case *mpegts.CodecH265:
+			pt.Codec, pt.Supported = "H265", m.video == nil
+			if pt.Supported {
+				m.video = &remuxTrack{id: 1, timeScale: videoTimeScale}
+				m.hevc = true
+				reader.OnDataH265(track, func(pts, dts int64, au [][]byte) error {
+					return m.onVideo(pts, dts, au)
+				})
+			}
+		case *mpegts.CodecMPEG4Audio:
+			pt.Codec, pt.Supported = "AAC", true
+		case *mpegts.CodecMPEG1Audio:
+			// mediacommon 将 MPEG-1 音频标记为视频轨道，这里纠正
+			pt.Codec, pt.Video, pt.Supported = "MP3", false, true
+		case *mpegts.CodecMPEG4AudioLATM:
+			pt.Codec = "AAC-LATM"
+		case *mpegts.CodecAC3:
+			pt.Codec = "AC3"
+		case *mpegts.CodecOpus:
+			pt.Codec = "Opus"
+		case *mpegts.CodecMPEG1Video, *mpegts.CodecMPEG4Video:
+			pt.Codec = "MPEG Video"
+		case *mpegts.CodecKLV, *mpegts.CodecDVBSubtitle:
+			continue
+		default:
+			pt.Codec = "unknown"
+		}
+		m.info.Tracks = append(m.info.Tracks, pt)
+		if !pt.Video {
+			audioTracks = append(audioTracks, track)
+			audioSupported = append(audioSupported, pt.Supported)
+		}
+	}
+
+	// 指定的音轨不存在或不可播放时，退回第一条可播放的音轨
+	pick := -1
+	if audio >= 0 && audio < len(audioTracks) && audioSupported[audio] {
+		pick = audio
+	} else {
+		for i, ok := range audioSupported {
+			if ok {
+				pick = i
+				break
+			}
+		}
+	}
+	if pick >= 0 {
+		m.info.Audio = pick
+		m.setupAudio(reader, audioTracks[pick])
+	}
+
+	if m.video == nil && m.audio == nil {
+		return errors.New("频道中没有浏览器可播放的音视频轨道")
+	}
+
+	for {
+		if err := reader.Read(); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *fmp4Remuxer) setupAudio(reader *mpegts.Reader, track *mpegts.Track) {
+	switch codec := track.Codec.(type) {
+	case *mpegts.CodecMPEG4Audio:
+		cfg := codec.Config
+		m.audio = &remuxTrack{
+			id:        2,
+			timeScale: uint32(cfg.SampleRate),
+			codec:     &fmp4.CodecMPEG4Audio{Config: cfg},
+			codecStr:  fmt.Sprintf("mp4a.40.%d", int(cfg.Type)),
+		}
+		reader.OnDataMPEG4Audio(track, func(pts int64, aus [][]byte) error {
+			return m.onAudio(pts, aus, 1024)
+		})
+	case *mpegts.CodecMPEG1Audio:
+		// 采样率和声道数要等到第一帧才能确定
+		m.audio = &remuxTrack{id: 2, codecStr: "mp4a.40.34"}
+		reader.OnDataMPEG1Audio(track, func(pts int64, frames [][]byte) error {
+			var h mpeg1audio.FrameHeader
+			if err := h.Unmarshal(frames[0]); err != nil {
+				return nil
+			}
+			if m.audio.codec == nil {
+				channels := 2
+				if h.ChannelMode == mpeg1audio.ChannelModeMono {
+					channels = 1
+				}
+				m.audio.timeScale = uint32(h.SampleRate)
+				m.audio.codec = &fmp4.CodecMPEG1Audio{SampleRate: h.SampleRate, ChannelCount: channels}
+			}
+			return m.onAudio(pts, frames, h.SampleCount())
+		})
+	}
+}
+
+func (m *fmp4Remuxer) onVideo(pts, dts int64, au [][]byte) error {
+	pts, dts = m.td.Decode(pts), m.td.Decode(dts)
+	m.updateParams(au)
+
+	if !m.started {
+		random := h264.IsRandomAccess(au)
+		if m.hevc {
+			random = h265.IsRandomAccess(au)
+		}
+		// 从关键帧开始输出，音频参数未就绪时等待下一个关键帧
+		if !random || m.sps == nil || m.pps == nil || m.hevc && m.vps == nil ||
+			m.audio != nil && m.audio.codec == nil {
+			return nil
+		}
+		if m.hevc {
+			m.video.codec = &fmp4.CodecH265{VPS: m.vps, SPS: m.sps, PPS: m.pps}
+		} else {
+			m.video.codec = &fmp4.CodecH264{SPS: m.sps, PPS: m.pps}
+		}
+		m.video.codecStr = videoCodecString(m.hevc, m.sps)
+		if err := m.start(dts); err != nil {
+			return err
+		}
+	}
+
+	s := &fmp4.Sample{}
+	var err error
+	if m.hevc {
+		err = s.FillH265(int32(pts-dts), au)
+	} else {
+		err = s.FillH264(int32(pts-dts), au)
+	}
+	if err != nil {
+		return nil
+	}
+	m.video.push(s, dts-m.startDTS)
+	if m.video.full() {
+		return m.writePart(m.video)
+	}
+	return nil
+}
+
+func (m *fmp4Remuxer) onAudio(pts int64, aus [][]byte, samplesPerAU int) error {
+	pts = m.td.Decode(pts)
+	if !m.started {
+		if m.video != nil || m.audio.codec == nil {
+			return nil
+		}
+		if err := m.start(pts); err != nil {
+			return err
+		}
+	}
+	if pts < m.startDTS {
+		return nil
+	}
+
+	ts := (pts - m.startDTS) * int64(m.audio.timeScale) / videoTimeScale
+	for i, au := range aus {
+		m.audio.push(&fmp4.Sample{Payload: au}, ts+int64(i*samplesPerAU))
+	}
+	if m.audio.full() {
+		return m.writePart(m.audio)
+	}
+	return nil
+}
+
+// updateParams 记录访问单元中携带的参数集
+func (m *fmp4Remuxer) updateParams(au [][]byte) {
+	for _, nalu := range au {
+		if len(nalu) == 0 {
+			continue
+		}
+		if m.hevc {
+			switch h265.NALUType((nalu[0] >> 1) & 0b111111) {
+			case h265.NALUType_VPS_NUT:
+				m.vps = append([]byte(nil), nalu...)
+			case h265.NALUType_SPS_NUT:
+				m.sps = append([]byte(nil), nalu...)
+			case h265.NALUType_PPS_NUT:
+				m.pps = append([]byte(nil), nalu...)
+			}
+			continue
+		}
+		switch h264.NALUType(nalu[0] & 0x1F) {
+		case h264.NALUTypeSPS:
+			m.sps = append([]byte(nil), nalu...)
+		case h264.NALUTypePPS:
+			m.pps = append([]byte(nil), nalu...)
+		}
+	}
+}
+
+// start 确定起点并写出初始化段
+func (m *fmp4Remuxer) start(startDTS int64) error {
+	m.started = true
+	m.startDTS = startDTS
+
+	var init fmp4.Init
+	var codecs []string
+	for _, t := range []*remuxTrack{m.video, m.audio} {
+		if t == nil {
+			continue
+		}
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{ID: t.id, TimeScale: t.timeScale, Codec: t.codec})
+		codecs = append(codecs, t.codecStr)
+	}
+	mime := "video/mp4"
+	if m.video == nil {
+		mime = "audio/mp4"
+	}
+	m.info.MIME = fmt.Sprintf(`%s; codecs="%s"`, mime, strings.Join(codecs, ","))
+	if m.onInit != nil {
+		if err := m.onInit(m.info); err != nil {
+			return err
+		}
+	}
+
+	var buf seekablebuffer.Buffer
+	if err := init.Marshal(&buf); err != nil {
+		return fmt.Errorf("生成 fMP4 初始化段失败: %w", err)
+	}
+	return m.write(buf.Bytes())
+}
+
+func (m *fmp4Remuxer) writePart(t *remuxTrack) error {
+	part := fmp4.Part{
+		SequenceNumber: m.seq,
+		Tracks:         []*fmp4.PartTrack{{ID: t.id, BaseTime: t.baseTime, Samples: t.samples}},
+	}
+	m.seq++
+	t.samples = nil
+
+	var buf seekablebuffer.Buffer
+	if err := part.Marshal(&buf); err != nil {
+		return fmt.Errorf("生成 fMP4 分片失败: %w", err)
+	}
+	return m.write(buf.Bytes())
+}
+
+func (m *fmp4Remuxer) write(b []byte) error {
+	if _, err := m.w.Write(b); err != nil {
+		return err
+	}
+	if f, ok := m.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// videoCodecString 生成 RFC 6381 编码字符串
+func videoCodecString(hevc bool, sps []byte) string {
+	if hevc {
+		var s h265.SPS
+		if err := s.Unmarshal(sps); err == nil {
+			return fmt.Sprintf("hvc1.%d.4.L%d.B0", s.ProfileTierLevel.GeneralProfileIdc, s.ProfileTierLevel.GeneralLevelIdc)
+		}
+		return "hvc1.1.4.L93.B0"
+	}
+	if len(sps) >= 4 {
+		return fmt.Sprintf("avc1.%02x%02x%02x", sps[1], sps[2], sps[3])
+	}
+	return "avc1.42e01e"
+}
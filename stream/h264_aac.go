@@ -314,7 +314,7 @@ func HandleH264AacStream(
 					}
 					for _, au := range aus {
 						if len(au) == 0 {
-							logger.LogPrintf("⚠️ Skip empty AU")
+							logger.Debugf("Skip empty AU")
 							continue
 						}
 						adts := buildADTSHeader(audioFormat.Config, len(au))
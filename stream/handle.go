@@ -10,6 +10,7 @@ import (
 	"github.com/qist/tvgate/logger"
 	// "github.com/qist/tvgate/monitor"
 	"github.com/qist/tvgate/utils/buffer"
+	"github.com/qist/tvgate/utils/httperror"
 	"io"
 	"net/http"
 	"net/url"
@@ -40,6 +41,7 @@ func HandleProxyResponse(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}()
 
 	logger.LogRequestAndResponse(r, targetURL, resp) // 日志记录
+	w = LimitWriter(w, r)
 	u, _ := url.Parse(targetURL)
 	contentType := resp.Header.Get("Content-Type")
 	bufSize := buffer.GetOptimalBufferSize(contentType, u.Path)
@@ -53,10 +55,18 @@ func HandleProxyResponse(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	if IsSupportedContentType(resp.Header.Get("Content-Type")) {
-		handleSpecialContent(w, r, resp, buf)
+		handleSpecialContent(w, r, targetURL, resp, buf)
 		return
 	}
 
+	if resp.StatusCode == http.StatusOK && IsDASHContent(contentType, u.Path) {
+		handleDASHManifest(w, r, targetURL, resp)
+		return
+	}
+
+	// 分片缓存：读取完整分片后写入缓存，再返回给客户端
+	body := cacheSegmentResponse(r, targetURL, resp)
+
 	// 复制响应头
 	CopyHeader(w.Header(), resp.Header, r.ProtoMajor)
 	w.WriteHeader(resp.StatusCode)
@@ -67,7 +77,7 @@ func HandleProxyResponse(ctx context.Context, w http.ResponseWriter, r *http.Req
 	task := handleTaskPool.Get().(*handleTask)
 	task.f = func() {
 		defer close(done)
-		if err := CopyWithContext(ctx, w, resp.Body, buf, updateActive); err != nil {
+		if err := CopyWithContext(ctx, w, body, buf, updateActive); err != nil {
 			HandleCopyError(r, err, resp)
 		}
 	}
@@ -170,7 +180,7 @@ func GetTargetURL(r *http.Request, targetPath string) string {
 			targetURL += "?" + r.URL.RawQuery
 		}
 	}
-	logger.LogPrintf("getTargetURL 处理: 原始路径=%s, 查询参数=%s, 最终URL=%s",
+	logger.Debugf("getTargetURL 处理: 原始路径=%s, 查询参数=%s, 最终URL=%s",
 		path, r.URL.RawQuery, targetURL)
 
 	return targetURL
@@ -306,6 +316,11 @@ func CopyHeader(dst, src http.Header, protoMajor int) {
 			continue
 		}
 
+		// 已按本机的跨域策略设置时不再透传上游的跨域头，避免出现多个 Access-Control-Allow-Origin
+		if strings.HasPrefix(lowerKey, "access-control-") && dst.Get("Access-Control-Allow-Origin") != "" {
+			continue
+		}
+
 		// HTTP/2 不允许的头
 		if protoMajor == 2 {
 			switch lowerKey {
@@ -368,21 +383,17 @@ func handleRedirect(w http.ResponseWriter, r *http.Request, proxyResp *http.Resp
 	w.WriteHeader(proxyResp.StatusCode)
 }
 
-// handleSpecialContent 处理 m3u8 文件内容
-func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *http.Response, buf []byte) {
+// uriAttrRe 匹配 EXT-X-KEY / EXT-X-MEDIA / EXT-X-MAP 等标签中的 URI 属性
+var uriAttrRe = regexp.MustCompile(`URI="([^"]+)"`)
+
+// handleSpecialContent 处理 m3u8 文件内容，将分片、子列表和密钥地址改写回 tvgate
+func handleSpecialContent(w http.ResponseWriter, r *http.Request, targetURL string, proxyResp *http.Response, buf []byte) {
 	// defer proxyResp.Body.Close()
 
 	bufSize := len(buf)
 	reader := bufio.NewReaderSize(proxyResp.Body, bufSize)
 
-	scheme := getRequestScheme(r)
-	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
-
-	tm := auth.GetGlobalTokenManager()
-	tokenParam := "token"
-	if tm != nil && tm.TokenParamName != "" {
-		tokenParam = tm.TokenParamName
-	}
+	rw := newPlaylistRewriter(r, proxyResp, targetURL)
 
 	seen := make(map[string]struct{})
 	var resultLines []string
@@ -390,7 +401,7 @@ func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *htt
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil && !errors.Is(err, io.EOF) {
-			http.Error(w, "读取响应内容失败", http.StatusInternalServerError)
+			httperror.Error(w, r, "读取响应内容失败", http.StatusInternalServerError)
 			return
 		}
 
@@ -405,22 +416,9 @@ func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *htt
 		// --- 处理 EXT 标签 ---
 		if strings.HasPrefix(line, "#") {
 			if strings.Contains(line, "URI=\"") {
-				re := regexp.MustCompile(`URI="([^"]+)"`)
-				line = re.ReplaceAllStringFunc(line, func(match string) string {
-					uri := re.FindStringSubmatch(match)[1]
-					newURI := uri
-					token := ""
-					if tm != nil && tm.Enabled {
-						token = generateToken(tm, uri)
-					}
-					if token != "" {
-						if strings.Contains(newURI, "?") {
-							newURI += "&" + tokenParam + "=" + token
-						} else {
-							newURI += "?" + tokenParam + "=" + token
-						}
-					}
-					return fmt.Sprintf(`URI="%s"`, newURI)
+				line = uriAttrRe.ReplaceAllStringFunc(line, func(match string) string {
+					uri := uriAttrRe.FindStringSubmatch(match)[1]
+					return fmt.Sprintf(`URI="%s"`, rw.rewrite(uri))
 				})
 			}
 			resultLines = append(resultLines, line)
@@ -430,19 +428,8 @@ func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *htt
 			continue
 		}
 
-		// --- 普通行 (TS/URL) ---
-		newLine := line
-		token := ""
-		if tm != nil && tm.Enabled {
-			token = generateToken(tm, line)
-		}
-		if token != "" {
-			if strings.Contains(newLine, "?") {
-				newLine += "&" + tokenParam + "=" + token
-			} else {
-				newLine += "?" + tokenParam + "=" + token
-			}
-		}
+		// --- 普通行 (TS/子列表 URL，可能是相对路径) ---
+		newLine := rw.rewrite(line)
 
 		// 去重
 		if _, exists := seen[newLine]; exists {
@@ -453,11 +440,6 @@ func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *htt
 		}
 		seen[newLine] = struct{}{}
 
-		// ✅ 只有 http/https 时才拼接 baseURL
-		if strings.HasPrefix(newLine, "http://") || strings.HasPrefix(newLine, "https://") {
-			newLine = joinBaseWithFullURL(baseURL, newLine)
-		}
-
 		resultLines = append(resultLines, newLine)
 
 		if err == io.EOF {
@@ -466,12 +448,13 @@ func handleSpecialContent(w http.ResponseWriter, r *http.Request, proxyResp *htt
 	}
 
 	// 拼接结果
-	result := strings.Join(resultLines, "\n") + "\n"
+	result := []byte(strings.Join(resultLines, "\n") + "\n")
 
 	CopyHeader(w.Header(), proxyResp.Header, r.ProtoMajor)
 	w.Header().Del("Content-Length")
+	storeCachedPlaylist(r, targetURL, proxyResp.StatusCode, proxyResp.Header, result)
 	w.WriteHeader(proxyResp.StatusCode)
-	_, _ = w.Write([]byte(result))
+	_, _ = w.Write(result)
 }
 
 // joinBaseWithFullURL 拼接 baseURL 和完整 URL，不做任何 encode
@@ -0,0 +1,197 @@
+package stream
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+)
+
+// cachedPlaylist 改写后的 m3u8 缓存项
+type cachedPlaylist struct {
+	body     []byte
+	header   http.Header
+	status   int
+	expireAt time.Time
+}
+
+// playlistCache 改写后的 m3u8 短时缓存，避免同一播放列表被大量客户端重复拉取和改写
+var playlistCache = struct {
+	sync.RWMutex
+	entries map[string]*cachedPlaylist
+}{entries: make(map[string]*cachedPlaylist)}
+
+// playlistCacheTTL 获取 m3u8 缓存时间，<=0 表示关闭缓存
+func playlistCacheTTL() time.Duration {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	return config.Cfg.HLS.PlaylistCacheTTL
+}
+
+// playlistTokenParam 返回改写时使用的 token 参数名
+func playlistTokenParam(tm *auth.TokenManager) string {
+	if tm != nil && tm.TokenParamName != "" {
+		return tm.TokenParamName
+	}
+	return "my_token"
+}
+
+// playlistCacheKey 缓存 key 需要包含客户端访问的 scheme/host 和客户端自己的 token，
+// 改写结果依赖于它们，避免把一个客户端的 token 返回给其它客户端
+func playlistCacheKey(r *http.Request, targetURL string) string {
+	token := r.URL.Query().Get(playlistTokenParam(auth.GetGlobalTokenManager()))
+	return getRequestScheme(r) + "://" + r.Host + "|" + targetURL + "|" + token
+}
+
+// ServeCachedPlaylist 命中缓存时直接返回改写后的 m3u8，返回 true 表示已处理
+func ServeCachedPlaylist(w http.ResponseWriter, r *http.Request, targetURL string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if playlistCacheTTL() <= 0 {
+		return false
+	}
+
+	key := playlistCacheKey(r, targetURL)
+	playlistCache.RLock()
+	entry, ok := playlistCache.entries[key]
+	playlistCache.RUnlock()
+	if !ok || time.Now().After(entry.expireAt) {
+		return false
+	}
+
+	CopyHeader(w.Header(), entry.header, r.ProtoMajor)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(entry.status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(entry.body)
+	}
+	return true
+}
+
+// storeCachedPlaylist 保存改写后的 m3u8，只缓存 200 响应
+func storeCachedPlaylist(r *http.Request, targetURL string, status int, header http.Header, body []byte) {
+	ttl := playlistCacheTTL()
+	if ttl <= 0 || status != http.StatusOK {
+		return
+	}
+
+	now := time.Now()
+	key := playlistCacheKey(r, targetURL)
+
+	playlistCache.Lock()
+	defer playlistCache.Unlock()
+	// 顺带清理过期项，避免缓存无限增长
+	for k, e := range playlistCache.entries {
+		if now.After(e.expireAt) {
+			delete(playlistCache.entries, k)
+		}
+	}
+	playlistCache.entries[key] = &cachedPlaylist{
+		body:     body,
+		header:   header.Clone(),
+		status:   status,
+		expireAt: now.Add(ttl),
+	}
+}
+
+// playlistRewriter 把 m3u8 中的分片/子列表/密钥地址改写回 tvgate
+type playlistRewriter struct {
+	base         *url.URL // 上游 m3u8 的真实地址，用于解析相对路径
+	proxyBase    string   // 客户端访问 tvgate 的地址，如 http://host:port
+	tm           *auth.TokenManager
+	tokenParam   string
+	clientToken  string // 客户端请求播放列表时携带的 token，无法生成 token 时沿用
+	originalHost string // 保持代理组选择的原始域名
+}
+
+func newPlaylistRewriter(r *http.Request, proxyResp *http.Response, targetURL string) *playlistRewriter {
+	tm := auth.GetGlobalTokenManager()
+	rw := &playlistRewriter{
+		proxyBase:  getRequestScheme(r) + "://" + r.Host,
+		tm:         tm,
+		tokenParam: playlistTokenParam(tm),
+	}
+	rw.clientToken = r.URL.Query().Get(rw.tokenParam)
+
+	if proxyResp != nil && proxyResp.Request != nil && proxyResp.Request.URL != nil {
+		rw.base = proxyResp.Request.URL
+	} else if u, err := url.Parse(targetURL); err == nil {
+		rw.base = u
+	}
+
+	// 优先沿用客户端传入的 original_host，否则使用 m3u8 所在域名，
+	// 使分片即使位于其它 CDN 域名也能命中与播放列表相同的代理组
+	rw.originalHost = r.URL.Query().Get("original_host")
+	if rw.originalHost == "" && rw.base != nil {
+		rw.originalHost = rw.base.Hostname()
+	}
+	return rw
+}
+
+// rewrite 改写单个 URI：解析为绝对地址 -> 拼接 tvgate 前缀 -> 追加 token/original_host，
+// 没有动态或静态 token 可生成时（签发 token、JWT 等）沿用客户端自己的 token
+func (rw *playlistRewriter) rewrite(uri string) string {
+	return rw.rewriteWithBase(rw.base, uri)
+}
+
+// resolve 将 uri 相对 base 解析为绝对地址，失败返回空字符串
+func (rw *playlistRewriter) resolve(base *url.URL, uri string) string {
+	uri = strings.TrimSpace(uri)
+	abs := uri
+	if base != nil {
+		ref, err := url.Parse(uri)
+		if err != nil {
+			return ""
+		}
+		abs = base.ResolveReference(ref).String()
+	}
+	if !strings.HasPrefix(abs, "http://") && !strings.HasPrefix(abs, "https://") {
+		return ""
+	}
+	return abs
+}
+
+// rewriteWithBase 与 rewrite 相同，但使用指定的 base 解析相对路径（DASH 中 BaseURL 可逐级嵌套）
+func (rw *playlistRewriter) rewriteWithBase(base *url.URL, uri string) string {
+	uri = strings.TrimSpace(uri)
+	if uri == "" || strings.HasPrefix(uri, "data:") || strings.HasPrefix(uri, "skd://") {
+		return uri
+	}
+
+	abs := rw.resolve(base, uri)
+	if abs == "" {
+		return uri
+	}
+
+	newURI := joinBaseWithFullURL(rw.proxyBase, abs)
+
+	if rw.tm != nil && rw.tm.Enabled && !strings.Contains(newURI, rw.tokenParam+"=") {
+		token := generateToken(rw.tm, abs)
+		if token == "" {
+			token = url.QueryEscape(rw.clientToken)
+		}
+		if token != "" {
+			newURI = appendQueryParam(newURI, rw.tokenParam, token)
+		}
+	}
+
+	if rw.originalHost != "" && !strings.Contains(newURI, "original_host=") {
+		if u, err := url.Parse(abs); err == nil && u.Hostname() != rw.originalHost {
+			newURI = appendQueryParam(newURI, "original_host", rw.originalHost)
+		}
+	}
+	return newURI
+}
+
+// appendQueryParam 追加查询参数，不对原 URL 做 encode
+func appendQueryParam(rawURL, key, value string) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + key + "=" + value
+	}
+	return rawURL + "?" + key + "=" + value
+}
@@ -3,6 +3,8 @@ package stream
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v5"
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
@@ -30,6 +32,10 @@ type StreamHubs struct {
 	videoFormat  interface{}
 	audioMedia   *description.Media
 	audioFormat  *format.MPEG4Audio
+	// 资源占用统计
+	usage hubUsage
+	// 广播使用的客户端快照，clients 变化时在 mu 内重建，广播时无锁读取
+	snapshot atomic.Pointer[[]*ringbuffer.RingBuffer]
 }
 
 func NewStreamHubs() *StreamHubs {
@@ -50,6 +56,7 @@ func (hub *StreamHubs) AddClient(ch *ringbuffer.RingBuffer) {
 		return
 	}
 	hub.clients[ch] = struct{}{}
+	hub.syncClients()
 }
 
 func (hub *StreamHubs) RemoveClient(ch *ringbuffer.RingBuffer) {
@@ -59,25 +66,35 @@ func (hub *StreamHubs) RemoveClient(ch *ringbuffer.RingBuffer) {
 	// 检查channel是否还在clients映射中
 	if _, exists := hub.clients[ch]; exists {
 		delete(hub.clients, ch)
+		hub.syncClients()
 		ch.Close()
 	}
 	// 如果channel不存在于clients映射中，说明已经被Broadcast方法移除并关闭了
 }
 
 func (hub *StreamHubs) Broadcast(data []byte) {
-	hub.mu.Lock()
-	clients := make([]*ringbuffer.RingBuffer, 0, len(hub.clients))
-	for ch := range hub.clients {
-		clients = append(clients, ch)
-	}
-	hub.mu.Unlock()
+	start := time.Now()
+	defer hub.usage.record(len(data), start)
 
-	for _, ch := range clients {
+	clients := hub.snapshot.Load()
+	if clients == nil {
+		return
+	}
+	for _, ch := range *clients {
 		buf := make([]byte, len(data))
 		copy(buf, data)
 		ch.Push(buf)
 		}
-	// logger.LogPrintf("DEBUG: Broadcasted %d bytes to %d clients", len(data), len(clients))
+	// logger.LogPrintf("DEBUG: Broadcasted %d bytes to %d clients", len(data), len(*clients))
+}
+
+// syncClients 按 clients 重建广播使用的快照，调用方持有 hub.mu
+func (hub *StreamHubs) syncClients() {
+	clients := make([]*ringbuffer.RingBuffer, 0, len(hub.clients))
+	for ch := range hub.clients {
+		clients = append(clients, ch)
+	}
+	hub.snapshot.Store(&clients)
 }
 
 func (hub *StreamHubs) ClientCount() int {
@@ -106,6 +123,7 @@ func (hub *StreamHubs) Close() {
 		ch.Close()
 	}
 	hub.clients = nil
+	hub.syncClients()
 }
 
 // 新增方法：设置流为播放状态
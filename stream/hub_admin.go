@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// ErrHubNotFound 指定的 Hub 不存在或已关闭
+var ErrHubNotFound = errors.New("Hub 不存在或已关闭")
+
+// HubInfo 组播/RTP Hub 的运行信息，供管理接口使用
+type HubInfo struct {
+	Key        string    `json:"key"`    // Hub 标识，关闭或更新网卡时使用
+	Addrs      []string  `json:"addrs"`  // 组播地址
+	Ifaces     []string  `json:"ifaces"` // 创建 Hub 时指定的网卡，为空表示默认网卡
+	Clients    int       `json:"clients"`
+	Playing    bool      `json:"playing"`
+	Packets    uint64    `json:"packets"`
+	Bytes      uint64    `json:"bytes"`
+	Drops      uint64    `json:"drops"`
+	Rejoins    uint64    `json:"rejoins"`
+	LastPacket time.Time `json:"last_packet"` // 最后收到数据包的时间，零值表示尚未收到数据
+}
+
+// info 汇总 Hub 的运行信息，不影响状态页的 CPU 占比采样
+func (h *StreamHub) info(key string) HubInfo {
+	h.Mu.RLock()
+	hi := HubInfo{
+		Key:     key,
+		Addrs:   append([]string(nil), h.AddrList...),
+		Ifaces:  append([]string(nil), h.ifaces...),
+		Clients: len(h.Clients),
+		Playing: h.state == StatePlayings && !h.IsClosed(),
+	}
+	h.Mu.RUnlock()
+	hi.Packets = atomic.LoadUint64(&h.usage.packets)
+	hi.Bytes = atomic.LoadUint64(&h.usage.bytes)
+	hi.Drops = atomic.LoadUint64(&h.usage.drops)
+	hi.Rejoins = atomic.LoadUint64(&h.usage.rejoins)
+	if last := atomic.LoadInt64(&h.usage.last); last > 0 {
+		hi.LastPacket = time.Unix(0, last)
+	}
+	return hi
+}
+
+// ListHubs 列出所有未关闭的组播/RTP Hub，按地址排序
+func ListHubs() []HubInfo {
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make(map[string]*StreamHub, len(GlobalMultiChannelHub.Hubs))
+	for key, h := range GlobalMultiChannelHub.Hubs {
+		hubs[key] = h
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	list := make([]HubInfo, 0, len(hubs))
+	for key, h := range hubs {
+		if h.IsClosed() {
+			continue
+		}
+		list = append(list, h.info(key))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := hubDisplayName(list[i].Addrs, list[i].Ifaces), hubDisplayName(list[j].Addrs, list[j].Ifaces)
+		return a < b
+	})
+	return list
+}
+
+// lookupHub 按 key 查找未关闭的 Hub
+func lookupHub(key string) *StreamHub {
+	GlobalMultiChannelHub.Mu.RLock()
+	h := GlobalMultiChannelHub.Hubs[key]
+	GlobalMultiChannelHub.Mu.RUnlock()
+	if h == nil || h.IsClosed() {
+		return nil
+	}
+	return h
+}
+
+// CloseHub 强制关闭 Hub：离开组播组并断开其全部客户端，Hub 不存在时返回 false
+func CloseHub(key string) bool {
+	GlobalMultiChannelHub.Mu.Lock()
+	h, ok := GlobalMultiChannelHub.Hubs[key]
+	if ok {
+		delete(GlobalMultiChannelHub.Hubs, key)
+	}
+	GlobalMultiChannelHub.Mu.Unlock()
+	if !ok || h.IsClosed() {
+		return false
+	}
+	h.Close()
+	logger.LogPrintf("🚫 已强制关闭 Hub: %s", hubDisplayName(h.AddrList, h.ifaces))
+	return true
+}
+
+// StartHub 预先加入组播组，ifaces 为空时使用 server.multicast_ifaces；
+// 与客户端请求相同地址和网卡时共用该 Hub，在第一个客户端离开前一直保持加入
+func StartHub(addr string, ifaces []string) (HubInfo, error) {
+	if len(ifaces) == 0 {
+		config.CfgMu.RLock()
+		ifaces = append(ifaces, config.Cfg.Server.MulticastIfaces...)
+		config.CfgMu.RUnlock()
+	}
+	h, err := GlobalMultiChannelHub.GetOrCreateHub(addr, ifaces)
+	if err != nil {
+		return HubInfo{}, err
+	}
+	logger.LogPrintf("✅ 已预先启动 Hub: %s", hubDisplayName(h.AddrList, ifaces))
+	return h.info(GlobalMultiChannelHub.HubKey(addr, ifaces)), nil
+}
+
+// UpdateHubInterfaces 在指定网卡上重新加入组播组，客户端不断开；ifaces 为空时在 Hub 原有网卡上重新加入。
+// Hub 的 key 与 Ifaces 保持不变，之后请求原网卡的客户端仍共用该 Hub
+func UpdateHubInterfaces(key string, ifaces []string) (HubInfo, error) {
+	h := lookupHub(key)
+	if h == nil {
+		return HubInfo{}, ErrHubNotFound
+	}
+	if len(ifaces) == 0 {
+		ifaces = h.Ifaces()
+	}
+	if err := h.UpdateInterfaces(ifaces); err != nil {
+		return HubInfo{}, err
+	}
+	return h.info(key), nil
+}
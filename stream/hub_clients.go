@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/monitor"
+)
+
+// clientStats 单个 Hub 客户端的发送统计，hubClient 按值存放在 map 中，统计通过指针共享
+type clientStats struct {
+	ip          string
+	userAgent   string
+	connectedAt time.Time
+
+	bytes     uint64
+	packets   uint64
+	drops     uint64 // 发送超时被丢弃的包数
+	lastWrite int64  // 最后一次成功写出的时间（UnixNano）
+}
+
+func newClientStats(r *http.Request) *clientStats {
+	s := &clientStats{connectedAt: time.Now()}
+	if r != nil {
+		s.ip = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			s.ip = host
+		}
+		s.userAgent = r.UserAgent()
+	}
+	return s
+}
+
+// wrote 记录一次成功写出，packets 为本次写出包含的包数
+func (s *clientStats) wrote(n, packets int) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.bytes, uint64(n))
+	atomic.AddUint64(&s.packets, uint64(packets))
+	atomic.StoreInt64(&s.lastWrite, time.Now().UnixNano())
+}
+
+// drop 记录一次因客户端接收过慢而丢弃的包
+func (s *clientStats) drop() {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.drops, 1)
+}
+
+func init() {
+	monitor.RegisterHubClientProvider(collectHubClients)
+}
+
+// collectHubClients 汇总所有组播/RTP Hub 中客户端的发送统计
+func collectHubClients() []monitor.HubClient {
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make([]*StreamHub, 0, len(GlobalMultiChannelHub.Hubs))
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		hubs = append(hubs, h)
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	var result []monitor.HubClient
+	for _, h := range hubs {
+		h.Mu.RLock()
+		name := hubDisplayName(h.AddrList, h.ifaces)
+		for connID, c := range h.Clients {
+			s := c.stats
+			if s == nil {
+				continue
+			}
+			packets := atomic.LoadUint64(&s.packets)
+			drops := atomic.LoadUint64(&s.drops)
+			hc := monitor.HubClient{
+				ID:          connID,
+				Hub:         name,
+				IP:          s.ip,
+				UserAgent:   s.userAgent,
+				ConnectedAt: s.connectedAt,
+				Bytes:       atomic.LoadUint64(&s.bytes),
+				Packets:     packets,
+				Drops:       drops,
+				Queued:      len(c.ch),
+			}
+			if last := atomic.LoadInt64(&s.lastWrite); last > 0 {
+				hc.LastWrite = time.Unix(0, last)
+			}
+			if total := packets + drops; total > 0 {
+				hc.DropRate = float64(drops) * 100 / float64(total)
+			}
+			result = append(result, hc)
+		}
+		h.Mu.RUnlock()
+	}
+	return result
+}
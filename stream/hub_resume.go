@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/logger"
+)
+
+// resumePoint 客户端断开时的播放位置
+type resumePoint struct {
+	seq uint64
+	at  time.Time
+}
+
+// hubResume 断线续播：启用时 Hub 把发出的帧同时写入缓存，记录每个客户端断开时的位置，
+// 客户端在窗口内重新连接时先补发断开后的缓存帧再继续直播；最后一个客户端断开后 Hub 保留一个窗口的时间
+type hubResume struct {
+	window atomic.Int64 // time.Duration，0 表示不启用
+
+	mu     sync.Mutex
+	points map[string]resumePoint // 客户端标识 -> 断开时的位置
+}
+
+// resumeWindow 断线续播窗口，0 表示不启用
+func (h *StreamHub) resumeWindow() time.Duration {
+	return time.Duration(h.resume.window.Load())
+}
+
+// SetResume 设置断线续播窗口，关闭时清空缓存；未变化时返回 false
+func (h *StreamHub) SetResume(window time.Duration) bool {
+	if time.Duration(h.resume.window.Swap(int64(window))) == window {
+		return false
+	}
+	if window <= 0 {
+		h.resume.mu.Lock()
+		h.resume.points = nil
+		h.resume.mu.Unlock()
+		h.Mu.RLock()
+		if h.CacheBuffer != nil {
+			h.CacheBuffer.Reset()
+		}
+		h.Mu.RUnlock()
+	}
+	return true
+}
+
+// cacheFrame 启用断线续播时缓存发出的帧，data 可能来自缓冲池，需要复制
+func (h *StreamHub) cacheFrame(data []byte) {
+	if h.resume.window.Load() <= 0 {
+		return
+	}
+	h.Mu.RLock()
+	cache := h.CacheBuffer
+	h.Mu.RUnlock()
+	if cache != nil {
+		cache.Push(append([]byte(nil), data...))
+	}
+}
+
+// joinCache 新客户端加入时补发的缓存帧，与是否启用断线续播无关
+func (h *StreamHub) joinCache() [][]byte {
+	if h.CacheBuffer == nil {
+		return nil
+	}
+	return h.CacheBuffer.GetAll()
+}
+
+// resumeKey 断线续播的客户端标识：请求中的 token，未携带 token 时返回空，不续播；
+// 同一 IP 后可能有多个用户（NAT、代理），不能按 IP 续播
+func resumeKey(r *http.Request) string {
+	param := "my_token"
+	if tm := auth.GetGlobalTokenManager(); tm != nil && tm.TokenParamName != "" {
+		param = tm.TokenParamName
+	}
+	if token := r.URL.Query().Get(param); token != "" {
+		return "token:" + token
+	}
+	return ""
+}
+
+// takeResume 取出客户端在窗口内断开时的位置之后的缓存帧，没有可续播的位置时返回 false
+func (h *StreamHub) takeResume(key string) ([][]byte, bool) {
+	window := h.resumeWindow()
+	h.resume.mu.Lock()
+	p, ok := h.resume.points[key]
+	delete(h.resume.points, key)
+	h.resume.mu.Unlock()
+	if !ok || time.Since(p.at) > window {
+		return nil, false
+	}
+	h.Mu.RLock()
+	cache := h.CacheBuffer
+	h.Mu.RUnlock()
+	if cache == nil {
+		return nil, false
+	}
+	frames := cache.Since(p.seq)
+	logger.LogPrintf("⏯️ 客户端断线续播，补发 %d 帧", len(frames))
+	return frames, true
+}
+
+// saveResume 记录客户端断开时的位置，unsent 为已进入发送队列但未写出的帧数
+func (h *StreamHub) saveResume(key string, unsent int) {
+	window := h.resumeWindow()
+	if window <= 0 {
+		return
+	}
+	h.Mu.RLock()
+	cache := h.CacheBuffer
+	h.Mu.RUnlock()
+	if cache == nil {
+		return
+	}
+	seq := cache.Seq()
+	seq -= min(seq, uint64(unsent))
+
+	now := time.Now()
+	h.resume.mu.Lock()
+	defer h.resume.mu.Unlock()
+	if h.resume.points == nil {
+		h.resume.points = make(map[string]resumePoint)
+	}
+	for k, p := range h.resume.points {
+		if now.Sub(p.at) > window {
+			delete(h.resume.points, k)
+		}
+	}
+	h.resume.points[key] = resumePoint{seq: seq, at: now}
+}
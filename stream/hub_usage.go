@@ -0,0 +1,184 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/monitor"
+)
+
+// hubUsage 单个 Hub 的资源统计：包数、字节数以及处理与分发耗时
+type hubUsage struct {
+	packets   uint64
+	bytes     uint64
+	busyNanos int64
+	drops     uint64 // 客户端接收过慢被丢弃的包数
+	rejoins   uint64 // 看门狗重新加入组播组的次数
+	last      int64  // 最后收到数据包的时间（UnixNano），用于就绪检查
+
+	mu        sync.Mutex
+	baselines map[string]usageBaseline // 各调用方上次采样时的累计耗时，见 sample
+}
+
+// usageBaseline 某个调用方上次采样的基准
+type usageBaseline struct {
+	busy int64
+	at   time.Time
+}
+
+// record 记录一次数据包处理，start 为开始处理的时间；耗时为墙钟时间，包含分发时等待慢客户端的时间
+func (u *hubUsage) record(n int, start time.Time) {
+	atomic.AddUint64(&u.packets, 1)
+	atomic.AddUint64(&u.bytes, uint64(n))
+	atomic.AddInt64(&u.busyNanos, int64(time.Since(start)))
+	atomic.StoreInt64(&u.last, start.UnixNano())
+}
+
+// receivedWithin 是否在 d 内收到过数据包
+func (u *hubUsage) receivedWithin(d time.Duration) bool {
+	last := atomic.LoadInt64(&u.last)
+	return last > 0 && time.Since(time.Unix(0, last)) <= d
+}
+
+// drop 记录一次因客户端接收过慢而丢弃的包
+func (u *hubUsage) drop() {
+	atomic.AddUint64(&u.drops, 1)
+}
+
+// sample 返回累计统计及距 consumer 上次采样以来的处理耗时占比，consumer 为空时不计算占比
+func (u *hubUsage) sample(consumer string) (packets, bytes uint64, busy time.Duration, percent float64) {
+	packets = atomic.LoadUint64(&u.packets)
+	bytes = atomic.LoadUint64(&u.bytes)
+	busyNanos := atomic.LoadInt64(&u.busyNanos)
+
+	if consumer == "" {
+		return packets, bytes, time.Duration(busyNanos), 0
+	}
+	u.mu.Lock()
+	now := time.Now()
+	if last, ok := u.baselines[consumer]; ok {
+		if elapsed := now.Sub(last.at); elapsed > 0 {
+			percent = float64(busyNanos-last.busy) / float64(elapsed) * 100
+		}
+	}
+	if u.baselines == nil {
+		u.baselines = make(map[string]usageBaseline)
+	}
+	u.baselines[consumer] = usageBaseline{busy: busyNanos, at: now}
+	u.mu.Unlock()
+
+	return packets, bytes, time.Duration(busyNanos), percent
+}
+
+// avgPacketSize 平均包大小，用于估算缓冲区占用
+func avgPacketSize(packets, bytes uint64) uint64 {
+	if packets == 0 {
+		return 0
+	}
+	return bytes / packets
+}
+
+func init() {
+	monitor.RegisterHubUsageProvider(collectHubUsage)
+}
+
+// hubDisplayName 组播/RTP Hub 在状态页中的名称：地址列表，指定网卡时附加 @网卡
+func hubDisplayName(addrs, ifaces []string) string {
+	name := strings.Join(addrs, ",")
+	if len(ifaces) > 0 {
+		name += "@" + strings.Join(ifaces, ",")
+	}
+	return name
+}
+
+// collectHubUsage 汇总组播/RTP Hub 与 RTSP Hub 的资源占用
+func collectHubUsage(consumer string) []monitor.HubUsage {
+	var result []monitor.HubUsage
+
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make([]*StreamHub, 0, len(GlobalMultiChannelHub.Hubs))
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		hubs = append(hubs, h)
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	for _, h := range hubs {
+		packets, bytes, busy, percent := h.usage.sample(consumer)
+
+		h.Mu.RLock()
+		clients := len(h.Clients)
+		queued := 0
+		for _, c := range h.Clients {
+			queued += len(c.ch)
+		}
+		name := hubDisplayName(h.AddrList, h.ifaces)
+		if h.CacheBuffer != nil {
+			queued += h.CacheBuffer.GetCount()
+		}
+		h.Mu.RUnlock()
+
+		queued += int(atomic.LoadInt32(&h.fccPendingCount))
+
+		result = append(result, monitor.HubUsage{
+			Name:        name,
+			Type:        "UDP",
+			Clients:     clients,
+			Packets:     packets,
+			Bytes:       bytes,
+			BufferBytes: uint64(queued) * avgPacketSize(packets, bytes),
+			BusyTime:    busy,
+			BusyPercent: percent,
+			Drops:       atomic.LoadUint64(&h.usage.drops),
+			Rejoins:     atomic.LoadUint64(&h.usage.rejoins),
+		})
+	}
+
+	hubMu.Lock()
+	rtspHubs := make(map[string]*StreamHubs, len(hubManager))
+	for k, h := range hubManager {
+		rtspHubs[k] = h
+	}
+	hubMu.Unlock()
+
+	for streamURL, h := range rtspHubs {
+		packets, bytes, busy, percent := h.usage.sample(consumer)
+		clients := h.ClientCount()
+		result = append(result, monitor.HubUsage{
+			Name:        streamURL,
+			Type:        "RTSP",
+			Clients:     clients,
+			Packets:     packets,
+			Bytes:       bytes,
+			BusyTime:    busy,
+			BusyPercent: percent,
+			// RTSP 每个客户端持有一份拷贝，按一个包估算
+			BufferBytes: uint64(clients) * avgPacketSize(packets, bytes),
+		})
+	}
+
+	return result
+}
+
+// HealthyHubs 统计组播/RTP 与 RTSP Hub 总数以及 maxIdle 内收到过数据的 Hub 数，不影响处理耗时占比的采样
+func HealthyHubs(maxIdle time.Duration) (healthy, total int) {
+	GlobalMultiChannelHub.Mu.RLock()
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		total++
+		if !h.IsClosed() && h.usage.receivedWithin(maxIdle) {
+			healthy++
+		}
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	hubMu.Lock()
+	for _, h := range hubManager {
+		total++
+		if h.usage.receivedWithin(maxIdle) {
+			healthy++
+		}
+	}
+	hubMu.Unlock()
+	return healthy, total
+}
@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	// ifaceSettle 网卡事件之后等待状态稳定的时间，PPPoE 拨号等场景会先后产生多条事件
+	ifaceSettle = 2 * time.Second
+	// ifacePollInterval 不支持事件通知时轮询网卡的间隔
+	ifacePollInterval = 5 * time.Second
+)
+
+var ifaceWatchOnce sync.Once
+
+// WatchInterfaces 监听网卡启停与地址变化（Linux 使用 netlink，其它系统轮询），
+// 变化后让受影响的 Hub 重新加入组播组，VLAN、PPPoE 断线重连后无需手动修改配置
+func WatchInterfaces() {
+	ifaceWatchOnce.Do(func() {
+		events := make(chan struct{}, 1)
+		if err := watchInterfaceEvents(events); err != nil {
+			logger.LogPrintf("⚠️ 无法订阅网卡变化，改为每 %v 轮询: %v", ifacePollInterval, err)
+			go pollInterfaces(events)
+		}
+		go handleInterfaceChanges(events)
+	})
+}
+
+// notifyInterfaces 通知网卡可能发生了变化，不阻塞
+func notifyInterfaces(events chan<- struct{}) {
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+}
+
+func pollInterfaces(events chan<- struct{}) {
+	ticker := time.NewTicker(ifacePollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		notifyInterfaces(events)
+	}
+}
+
+// handleInterfaceChanges 收到事件后等待状态稳定，再与上次的快照比较找出变化的网卡
+func handleInterfaceChanges(events <-chan struct{}) {
+	prev := snapshotInterfaces()
+	for range events {
+		settle := time.NewTimer(ifaceSettle)
+	wait:
+		for {
+			select {
+			case <-events:
+				settle.Reset(ifaceSettle)
+			case <-settle.C:
+				break wait
+			}
+		}
+
+		cur := snapshotInterfaces()
+		changed := make(map[string]bool)
+		for name, state := range cur {
+			if prev[name] != state {
+				changed[name] = true
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				changed[name] = true
+			}
+		}
+		prev = cur
+		if len(changed) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(changed))
+		for name := range changed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		logger.LogPrintf("🔄 网卡状态变化: %v", names)
+		rejoinHubs(changed)
+	}
+}
+
+// snapshotInterfaces 记录每个网卡的编号、启用状态与地址，网卡重建后编号会变化
+func snapshotInterfaces() map[string]string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	snap := make(map[string]string, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		var addrs []string
+		if list, err := iface.Addrs(); err == nil {
+			for _, a := range list {
+				addrs = append(addrs, a.String())
+			}
+		}
+		sort.Strings(addrs)
+		snap[iface.Name] = fmt.Sprintf("%d|%s|%s", iface.Index, iface.Flags, strings.Join(addrs, ","))
+	}
+	return snap
+}
+
+// rejoinHubs 指定了网卡的 Hub 在网卡变化后重新监听，使用默认网卡的 Hub 刷新组播成员关系
+func rejoinHubs(changed map[string]bool) {
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make([]*StreamHub, 0, len(GlobalMultiChannelHub.Hubs))
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		hubs = append(hubs, h)
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	for _, h := range hubs {
+		if h.IsClosed() {
+			continue
+		}
+		ifaces := h.Ifaces()
+		if len(ifaces) == 0 {
+			h.smoothRejoinMulticast()
+			continue
+		}
+		for _, name := range ifaces {
+			if changed[name] {
+				if err := h.UpdateInterfaces(ifaces); err != nil {
+					logger.LogPrintf("❌ Hub %v 重新加入组播组失败: %v", h.AddrList, err)
+				}
+				break
+			}
+		}
+	}
+}
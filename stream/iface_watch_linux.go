@@ -0,0 +1,49 @@
+//go:build linux
+
+package stream
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// netlink 组播组（linux/rtnetlink.h），syscall 包未导出
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4Ifaddr = 0x10
+	rtmgrpIPv6Ifaddr = 0x100
+)
+
+// watchInterfaceEvents 订阅 netlink 的网卡与地址变化，只用作触发信号，具体变化由快照比较得出
+func watchInterfaceEvents(events chan<- struct{}) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "netlink")
+	go func() {
+		defer f.Close()
+		buf := make([]byte, 64*1024)
+		for {
+			// 接收缓冲区溢出（ENOBUFS）时同样视为发生了变化
+			if _, err := f.Read(buf); err != nil && !errors.Is(err, syscall.ENOBUFS) {
+				return
+			}
+			notifyInterfaces(events)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package stream
+
+// watchInterfaceEvents 其它系统没有统一的网卡事件通知，定期轮询
+func watchInterfaceEvents(events chan<- struct{}) error {
+	go pollInterfaces(events)
+	return nil
+}
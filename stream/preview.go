@@ -0,0 +1,192 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/qist/tvgate/utils/buffer/ringbuffer"
+)
+
+// ErrPreviewNotFound 频道当前没有在播放
+var ErrPreviewNotFound = errors.New("频道未在播放")
+
+// OpenPreview 旁听一个正在播放的频道，返回其 MPEG-TS 数据；ctx 结束或关闭读取端时退订。
+// name 为状态页中的频道标识：组播/RTP 地址（可带 @网卡）或 RTSP 地址。
+// 预览只订阅已有的 Hub，不会为此新建组播订阅或 RTSP 连接。
+func OpenPreview(ctx context.Context, name string) (io.ReadCloser, error) {
+	if h := findUDPHub(name); h != nil {
+		return previewUDPHub(ctx, h)
+	}
+
+	hubMu.Lock()
+	hub, ok := hubManager[name]
+	hubMu.Unlock()
+	if ok {
+		return previewRTSPHub(ctx, hub)
+	}
+	return nil, ErrPreviewNotFound
+}
+
+// findUDPHub 按状态页名称或组播地址查找 Hub
+func findUDPHub(name string) *StreamHub {
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make([]*StreamHub, 0, len(GlobalMultiChannelHub.Hubs))
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		hubs = append(hubs, h)
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+
+	var byAddr *StreamHub
+	for _, h := range hubs {
+		if h.IsClosed() {
+			continue
+		}
+		h.Mu.RLock()
+		full := hubDisplayName(h.AddrList, h.ifaces)
+		addrs := h.AddrList
+		h.Mu.RUnlock()
+		if full == name {
+			return h
+		}
+		for _, addr := range addrs {
+			if addr == name && byAddr == nil {
+				byAddr = h
+			}
+		}
+	}
+	return byAddr
+}
+
+func previewUDPHub(ctx context.Context, h *StreamHub) (io.ReadCloser, error) {
+	connID := "preview_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	ch := make(chan []byte, 4096)
+	done := make(chan struct{})
+	select {
+	case h.AddCh <- hubClient{ch: ch, done: done, connID: connID, stats: newClientStats(nil)}:
+	case <-h.Closed:
+		return nil, ErrPreviewNotFound
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() {
+			// 客户端可能已随配置变更迁移到新 Hub
+			cur := h.current()
+			select {
+			case cur.RemoveCh <- connID:
+			case <-cur.Closed:
+			}
+		}()
+		for {
+			select {
+			case data, ok := <-ch:
+				if !ok {
+					pw.CloseWithError(io.EOF)
+					return
+				}
+				// 数据来自缓冲池，Write 返回前已被读取端拷贝
+				if _, err := pw.Write(data); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				pw.CloseWithError(ctx.Err())
+				return
+			case <-done:
+				pw.CloseWithError(io.EOF)
+				return
+			case <-h.Closed:
+				pw.CloseWithError(io.EOF)
+				return
+			}
+		}
+	}()
+	return pr, nil
+}
+
+func previewRTSPHub(ctx context.Context, hub *StreamHubs) (io.ReadCloser, error) {
+	hub.mu.Lock()
+	playing := !hub.isClosed && hub.state == StatePlaying
+	hub.mu.Unlock()
+	if !playing {
+		return nil, ErrPreviewNotFound
+	}
+
+	rb, err := ringbuffer.New(2048)
+	if err != nil {
+		return nil, err
+	}
+	hub.AddClient(rb)
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() {
+			hub.RemoveClient(rb)
+			// 预览是最后一个客户端时，与普通播放一样停止 RTSP 拉流
+			hub.mu.Lock()
+			last := len(hub.clients) == 0
+			client := hub.rtspClient
+			if last {
+				hub.rtspClient = nil
+			}
+			hub.mu.Unlock()
+			if last {
+				hub.SetStopped()
+				if client != nil {
+					client.Close()
+				}
+			}
+		}()
+		for {
+			data, ok := rb.PullWithContext(ctx)
+			if !ok {
+				if ctx.Err() != nil {
+					pw.CloseWithError(ctx.Err())
+				} else {
+					pw.CloseWithError(io.EOF)
+				}
+				return
+			}
+			if _, err := pw.Write(data.([]byte)); err != nil {
+				return
+			}
+		}
+	}()
+	return pr, nil
+}
+
+// ActiveHubs 返回有客户端在播放的频道标识（与状态页一致），可直接传给 OpenPreview
+func ActiveHubs() []string {
+	var names []string
+	GlobalMultiChannelHub.Mu.RLock()
+	hubs := make([]*StreamHub, 0, len(GlobalMultiChannelHub.Hubs))
+	for _, h := range GlobalMultiChannelHub.Hubs {
+		hubs = append(hubs, h)
+	}
+	GlobalMultiChannelHub.Mu.RUnlock()
+	for _, h := range hubs {
+		if h.IsClosed() {
+			continue
+		}
+		h.Mu.RLock()
+		if len(h.Clients) > 0 {
+			names = append(names, hubDisplayName(h.AddrList, h.ifaces))
+		}
+		h.Mu.RUnlock()
+	}
+
+	hubMu.Lock()
+	rtspHubs := make(map[string]*StreamHubs, len(hubManager))
+	for k, h := range hubManager {
+		rtspHubs[k] = h
+	}
+	hubMu.Unlock()
+	for name, h := range rtspHubs {
+		if h.ClientCount() > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,21 @@
+package stream
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/qist/tvgate/config"
+)
+
+// rawRTPRequested 客户端是否要求原样转发 RTP 包：参数 raw=1 / raw=0 优先，未指定时按 server.raw_rtp 配置
+func rawRTPRequested(r *http.Request, addr string) bool {
+	if v := r.URL.Query().Get("raw"); v != "" {
+		raw, err := strconv.ParseBool(v)
+		if err == nil {
+			return raw
+		}
+	}
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	return config.Cfg.IsRawRTP(addr)
+}
@@ -0,0 +1,290 @@
+package stream
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// 可缓存的 HLS 分片后缀
+var segmentExts = map[string]struct{}{
+	".ts":  {},
+	".m4s": {},
+	".aac": {},
+	".mp4": {},
+	".m4a": {},
+	".m4v": {},
+}
+
+// segmentEntry 分片缓存项，启用磁盘缓存时 data 为空，内容保存在 file 中
+type segmentEntry struct {
+	key      string
+	data     []byte
+	file     string
+	size     int64
+	header   http.Header
+	expireAt time.Time
+	elem     *list.Element
+}
+
+// segmentCache 按 URL 缓存 HLS 分片，同一分片并发请求时只回源一次
+type segmentCache struct {
+	mu       sync.Mutex
+	entries  map[string]*segmentEntry
+	lru      *list.List
+	size     int64
+	inflight map[string]chan struct{}
+	diskDirs map[string]bool // 已初始化的磁盘缓存目录
+}
+
+var globalSegmentCache = &segmentCache{
+	entries:  make(map[string]*segmentEntry),
+	lru:      list.New(),
+	inflight: make(map[string]chan struct{}),
+	diskDirs: make(map[string]bool),
+}
+
+// segmentCacheConfig 获取当前分片缓存配置
+func segmentCacheConfig() config.SegmentCacheConfig {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	return config.Cfg.HLS.SegmentCache
+}
+
+// isCacheableSegment 判断请求是否为可缓存的分片
+func isCacheableSegment(r *http.Request, targetURL string) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return false
+	}
+	if !segmentCacheConfig().Enabled {
+		return false
+	}
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return false
+	}
+	_, ok := segmentExts[strings.ToLower(path.Ext(u.Path))]
+	return ok
+}
+
+// AcquireSegment 命中缓存时直接返回分片（served=true）；
+// 未命中时若已有相同分片正在回源则等待其完成，否则成为回源者，处理完成后需调用 release
+func AcquireSegment(w http.ResponseWriter, r *http.Request, targetURL string) (served bool, release func()) {
+	release = func() {}
+	if !isCacheableSegment(r, targetURL) {
+		return false, release
+	}
+	c := globalSegmentCache
+
+	if c.serve(w, r, targetURL) {
+		return true, release
+	}
+
+	c.mu.Lock()
+	if ch, ok := c.inflight[targetURL]; ok {
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-r.Context().Done():
+			return true, release
+		case <-time.After(config.DefaultDialTimeout):
+		}
+		if c.serve(w, r, targetURL) {
+			return true, release
+		}
+		// 等待的回源失败或分片过大未缓存，自行回源
+		return false, release
+	}
+	ch := make(chan struct{})
+	c.inflight[targetURL] = ch
+	c.mu.Unlock()
+
+	return false, func() {
+		c.mu.Lock()
+		delete(c.inflight, targetURL)
+		c.mu.Unlock()
+		close(ch)
+	}
+}
+
+// serve 从缓存返回分片
+func (c *segmentCache) serve(w http.ResponseWriter, r *http.Request, key string) bool {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	if time.Now().After(e.expireAt) {
+		c.removeLocked(e)
+		c.mu.Unlock()
+		return false
+	}
+	c.lru.MoveToFront(e.elem)
+	data, file, header := e.data, e.file, e.header
+	c.mu.Unlock()
+
+	if file != "" {
+		var err error
+		data, err = os.ReadFile(file)
+		if err != nil {
+			logger.LogPrintf("⚠️ 读取分片磁盘缓存失败: %v", err)
+			c.mu.Lock()
+			if cur, ok := c.entries[key]; ok && cur == e {
+				c.removeLocked(e)
+			}
+			c.mu.Unlock()
+			return false
+		}
+	}
+
+	w = LimitWriter(w, r)
+	CopyHeader(w.Header(), header, r.ProtoMajor)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+	return true
+}
+
+// cacheSegmentResponse 读取分片响应并写入缓存，返回替换后的响应体；
+// 不满足缓存条件时原样返回 body
+func cacheSegmentResponse(r *http.Request, targetURL string, resp *http.Response) io.Reader {
+	if resp.StatusCode != http.StatusOK || !isCacheableSegment(r, targetURL) {
+		return resp.Body
+	}
+	cfg := segmentCacheConfig()
+	maxEntry := int64(cfg.MaxEntryMB) << 20
+	if resp.ContentLength > maxEntry {
+		return resp.Body
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEntry+1))
+	if err != nil {
+		// 读取失败时把已读部分和剩余部分一起交给后续复制逻辑处理
+		return io.MultiReader(bytes.NewReader(data), resp.Body)
+	}
+	if int64(len(data)) > maxEntry {
+		return io.MultiReader(bytes.NewReader(data), resp.Body)
+	}
+
+	globalSegmentCache.store(cfg, targetURL, resp.Header, data)
+	return bytes.NewReader(data)
+}
+
+// uncachedHeaders 不随分片缓存的响应头：Cookie、逐跳头以及只对本次响应有效的头，缓存会返回给所有客户端
+var uncachedHeaders = []string{
+	"Set-Cookie", "Set-Cookie2", "Content-Length", "Date", "Age",
+	"Connection", "Proxy-Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade",
+	"Te", "Trailer", "Proxy-Authenticate",
+}
+
+// cacheableHeader 复制上游响应头并去掉 uncachedHeaders 及 Connection 中列出的逐跳头
+func cacheableHeader(header http.Header) http.Header {
+	h := header.Clone()
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range uncachedHeaders {
+		h.Del(name)
+	}
+	return h
+}
+
+// store 写入缓存并按容量淘汰最久未使用的分片
+func (c *segmentCache) store(cfg config.SegmentCacheConfig, key string, header http.Header, data []byte) {
+	e := &segmentEntry{
+		key:      key,
+		size:     int64(len(data)),
+		header:   cacheableHeader(header),
+		expireAt: time.Now().Add(cfg.TTL),
+	}
+
+	if cfg.DiskPath != "" {
+		file, err := c.writeDisk(cfg.DiskPath, key, data)
+		if err != nil {
+			logger.LogPrintf("⚠️ 写入分片磁盘缓存失败，改用内存缓存: %v", err)
+			e.data = data
+		} else {
+			e.file = file
+		}
+	} else {
+		e.data = data
+	}
+
+	maxSize := int64(cfg.MaxSizeMB) << 20
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[key]; ok {
+		if old.file == e.file {
+			old.file = "" // 同名文件已被新内容覆盖，不能删除
+		}
+		c.removeLocked(old)
+	}
+	e.elem = c.lru.PushFront(e)
+	c.entries[key] = e
+	c.size += e.size
+
+	now := time.Now()
+	for elem := c.lru.Back(); elem != nil && (c.size > maxSize || now.After(elem.Value.(*segmentEntry).expireAt)); {
+		prev := elem.Prev()
+		if old := elem.Value.(*segmentEntry); old != e {
+			c.removeLocked(old)
+		}
+		elem = prev
+	}
+}
+
+// removeLocked 删除缓存项，调用方需持有 c.mu
+func (c *segmentCache) removeLocked(e *segmentEntry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	if e.file != "" {
+		_ = os.Remove(e.file)
+	}
+}
+
+// writeDisk 将分片写入磁盘缓存目录，首次使用目录时清理上次运行残留的文件
+func (c *segmentCache) writeDisk(dir, key string, data []byte) (string, error) {
+	c.mu.Lock()
+	if !c.diskDirs[dir] {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			c.mu.Unlock()
+			return "", err
+		}
+		if old, _ := filepath.Glob(filepath.Join(dir, "*.seg")); len(old) > 0 {
+			for _, f := range old {
+				_ = os.Remove(f)
+			}
+		}
+		c.diskDirs[dir] = true
+	}
+	c.mu.Unlock()
+
+	sum := sha1.Sum([]byte(key))
+	file := filepath.Join(dir, hex.EncodeToString(sum[:])+".seg")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
@@ -0,0 +1,258 @@
+package stream
+
+import (
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const tsPacketSize = 188
+
+// TSFilter 从多节目传输流（MPTS）中选出一个节目，或只保留指定的 PID。
+// 按节目过滤时解析 PAT/PMT，PAT 改写为只包含选中的节目，只转发该节目的 PMT、PCR 与基本流
+type TSFilter struct {
+	program int             // 选中的节目号，0 表示不按节目过滤
+	extra   map[uint16]bool // 额外保留的 PID
+
+	pmtPID  int             // 选中节目的 PMT PID，-1 表示尚未从 PAT 得到
+	allowed map[uint16]bool // 选中节目的 PMT、PCR 与各基本流 PID
+	pat     tsSection
+	pmt     tsSection
+	patCC   byte
+	patHead [3]byte // 原 PAT 的 transport_stream_id 与版本号，改写后保持不变
+
+	pending []byte // 未凑满一个 TS 包的数据
+	out     []byte
+}
+
+// tsSection 跨 TS 包拼接 PSI 段
+type tsSection struct {
+	buf []byte
+}
+
+// NewTSFilter 按请求参数创建过滤器：program 为节目号（service_id），pid 为逗号分隔的 PID（十进制或 0x 开头的十六进制）；
+// 都未指定时返回 nil
+func NewTSFilter(q url.Values) *TSFilter {
+	program, _ := strconv.Atoi(q.Get("program"))
+	var extra map[uint16]bool
+	for _, s := range strings.Split(q.Get("pid"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		pid, err := strconv.ParseUint(s, 0, 13)
+		if err != nil {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[uint16]bool)
+		}
+		extra[uint16(pid)] = true
+	}
+	if program <= 0 && extra == nil {
+		return nil
+	}
+	return &TSFilter{
+		program: program,
+		extra:   extra,
+		pmtPID:  -1,
+		allowed: make(map[uint16]bool),
+	}
+}
+
+// Filter 过滤一段 TS 数据，返回的切片在下次调用前有效。
+// 不按节目过滤时 PAT 原样转发；按节目过滤时在收到 PAT/PMT 之前丢弃所有数据
+func (f *TSFilter) Filter(data []byte) []byte {
+	f.out = f.out[:0]
+	if len(f.pending) > 0 {
+		need := tsPacketSize - len(f.pending)
+		if len(data) < need {
+			f.pending = append(f.pending, data...)
+			return f.out
+		}
+		f.pending = append(f.pending, data[:need]...)
+		data = data[need:]
+		if f.pending[0] == 0x47 {
+			f.packet(f.pending)
+		}
+		f.pending = f.pending[:0]
+	}
+	for len(data) >= tsPacketSize {
+		if data[0] != 0x47 {
+			// 失步时找下一个同步字节
+			i := 1
+			for i < len(data) && data[i] != 0x47 {
+				i++
+			}
+			data = data[i:]
+			continue
+		}
+		f.packet(data[:tsPacketSize])
+		data = data[tsPacketSize:]
+	}
+	if len(data) > 0 && data[0] == 0x47 {
+		f.pending = append(f.pending, data...)
+	}
+	return f.out
+}
+
+func (f *TSFilter) packet(pkt []byte) {
+	pid := binary.BigEndian.Uint16(pkt[1:3]) & 0x1FFF
+	switch {
+	case pid == 0:
+		if f.program <= 0 {
+			f.out = append(f.out, pkt...)
+			return
+		}
+		if section := f.pat.push(pkt); section != nil {
+			f.parsePAT(section)
+		}
+		if f.pmtPID >= 0 {
+			f.out = append(f.out, f.makePAT()...)
+		}
+	case f.extra[pid]:
+		f.out = append(f.out, pkt...)
+	case f.program > 0 && int(pid) == f.pmtPID:
+		if section := f.pmt.push(pkt); section != nil {
+			f.parsePMT(section)
+		}
+		f.out = append(f.out, pkt...)
+	case f.allowed[pid]:
+		f.out = append(f.out, pkt...)
+	}
+}
+
+// push 加入一个 TS 包的负载，段完整时返回整个段
+func (s *tsSection) push(pkt []byte) []byte {
+	payload := tsPayload(pkt)
+	if payload == nil {
+		return nil
+	}
+	if pkt[1]&0x40 != 0 { // payload_unit_start_indicator
+		pointer := int(payload[0])
+		if 1+pointer >= len(payload) {
+			s.buf = s.buf[:0]
+			return nil
+		}
+		s.buf = append(s.buf[:0], payload[1+pointer:]...)
+	} else if len(s.buf) > 0 {
+		s.buf = append(s.buf, payload...)
+	} else {
+		return nil
+	}
+	if len(s.buf) < 3 {
+		return nil
+	}
+	total := 3 + int(binary.BigEndian.Uint16(s.buf[1:3])&0x0FFF)
+	if len(s.buf) < total {
+		return nil
+	}
+	section := s.buf[:total]
+	s.buf = s.buf[:0]
+	return section
+}
+
+// tsPayload 返回 TS 包的负载，跳过自适应字段
+func tsPayload(pkt []byte) []byte {
+	afc := (pkt[3] >> 4) & 0x3
+	switch afc {
+	case 1:
+		return pkt[4:]
+	case 3:
+		n := 5 + int(pkt[4])
+		if n >= len(pkt) {
+			return nil
+		}
+		return pkt[n:]
+	}
+	return nil
+}
+
+// parsePAT 在 PAT 中查找选中节目的 PMT PID
+func (f *TSFilter) parsePAT(section []byte) {
+	if len(section) < 12 || section[0] != 0x00 {
+		return
+	}
+	copy(f.patHead[:], section[3:6])
+	// 去掉 8 字节段头与 4 字节 CRC
+	for p := section[8 : len(section)-4]; len(p) >= 4; p = p[4:] {
+		program := int(binary.BigEndian.Uint16(p[0:2]))
+		if program == f.program {
+			pmtPID := int(binary.BigEndian.Uint16(p[2:4]) & 0x1FFF)
+			if pmtPID != f.pmtPID {
+				f.pmtPID = pmtPID
+				f.allowed = make(map[uint16]bool)
+			}
+			return
+		}
+	}
+}
+
+// parsePMT 记录节目的 PCR PID 与各基本流 PID
+func (f *TSFilter) parsePMT(section []byte) {
+	if len(section) < 16 || section[0] != 0x02 {
+		return
+	}
+	if int(binary.BigEndian.Uint16(section[3:5])) != f.program {
+		return
+	}
+	allowed := map[uint16]bool{uint16(f.pmtPID): true}
+	allowed[binary.BigEndian.Uint16(section[8:10])&0x1FFF] = true
+	infoLen := int(binary.BigEndian.Uint16(section[10:12]) & 0x0FFF)
+	end := len(section) - 4
+	p := 12 + infoLen
+	for p+5 <= end {
+		allowed[binary.BigEndian.Uint16(section[p+1:p+3])&0x1FFF] = true
+		p += 5 + int(binary.BigEndian.Uint16(section[p+3:p+5])&0x0FFF)
+	}
+	f.allowed = allowed
+}
+
+// makePAT 生成只包含选中节目的 PAT 包
+func (f *TSFilter) makePAT() []byte {
+	pkt := make([]byte, tsPacketSize)
+	for i := range pkt {
+		pkt[i] = 0xFF
+	}
+	pkt[0] = 0x47
+	pkt[1] = 0x40 // payload_unit_start_indicator，PID 0
+	pkt[2] = 0x00
+	pkt[3] = 0x10 | f.patCC // 仅负载
+	f.patCC = (f.patCC + 1) & 0x0F
+	pkt[4] = 0 // pointer_field
+
+	section := pkt[5:]
+	section[0] = 0x00                // table_id
+	section[1] = 0xB0                // section_syntax_indicator
+	section[2] = 13                  // section_length：5 字节头 + 4 字节节目 + 4 字节 CRC
+	copy(section[3:6], f.patHead[:]) // transport_stream_id、版本号与 current_next_indicator
+	section[6], section[7] = 0, 0
+	binary.BigEndian.PutUint16(section[8:10], uint16(f.program))
+	binary.BigEndian.PutUint16(section[10:12], 0xE000|uint16(f.pmtPID))
+	binary.BigEndian.PutUint32(section[12:16], crc32MPEG(section[:12]))
+	return pkt
+}
+
+var crc32MPEGTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// crc32MPEG PSI 段使用的 CRC-32/MPEG-2
+func crc32MPEG(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc = crc<<8 ^ crc32MPEGTable[byte(crc>>24)^v]
+	}
+	return crc
+}
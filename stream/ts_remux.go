@@ -0,0 +1,284 @@
+package stream
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sync"
+
+	"github.com/qist/tvgate/config"
+)
+
+const (
+	sdtPID       = 0x11
+	caDescriptor = 0x09
+	svcDescTag   = 0x48
+)
+
+// tsRemuxer 按重封装规则原地改写 TS 包：重映射 PID、删除 CA 描述符、改写 SDT 中的节目名称。
+// PSI 段改写后长度变化时在包内补 0xFF，跨包的段或改写后放不下时保持原样
+type tsRemuxer struct {
+	rule   config.RemuxRule
+	pidMap map[uint16]uint16
+
+	mu      sync.Mutex
+	pmtPIDs map[uint16]bool // 最近一次 PAT 中的 PMT PID（原 PID）
+}
+
+// newTSRemuxer 按规则创建重封装器，规则为空或没有需要改写的内容时返回 nil
+func newTSRemuxer(rule *config.RemuxRule) *tsRemuxer {
+	if rule == nil {
+		return nil
+	}
+	r := &tsRemuxer{rule: *rule, pmtPIDs: make(map[uint16]bool)}
+	for from, to := range rule.PIDMap {
+		if r.pidMap == nil {
+			r.pidMap = make(map[uint16]uint16)
+		}
+		r.pidMap[uint16(from)&0x1FFF] = uint16(to) & 0x1FFF
+	}
+	if r.pidMap == nil && !rule.StripCA && rule.ServiceName == "" && rule.Provider == "" {
+		return nil
+	}
+	return r
+}
+
+// remap 返回重映射后的 PID
+func (r *tsRemuxer) remap(pid uint16) uint16 {
+	if to, ok := r.pidMap[pid]; ok {
+		return to
+	}
+	return pid
+}
+
+// packet 改写一个 TS 包
+func (r *tsRemuxer) packet(pkt []byte) {
+	pid := binary.BigEndian.Uint16(pkt[1:3]) & 0x1FFF
+	if pkt[1]&0x40 != 0 {
+		r.mu.Lock()
+		switch {
+		case pid == PAT_PID:
+			rewriteSection(pkt, r.rewritePAT)
+		case r.pmtPIDs[pid]:
+			rewriteSection(pkt, r.rewritePMT)
+		case pid == sdtPID && (r.rule.StripCA || r.rule.ServiceName != "" || r.rule.Provider != ""):
+			rewriteSection(pkt, r.rewriteSDT)
+		}
+		r.mu.Unlock()
+	}
+	if to, ok := r.pidMap[pid]; ok {
+		pkt[1] = pkt[1]&0xE0 | byte(to>>8)
+		pkt[2] = byte(to)
+	}
+}
+
+// rewriteSection 以 fn 改写包内第一个完整的 PSI 段，CRC 错误、段跨包或后面还有其它段时不处理
+func rewriteSection(pkt []byte, fn func(section []byte) []byte) {
+	payload := tsPayload(pkt)
+	if len(payload) == 0 {
+		return
+	}
+	start := len(pkt) - len(payload) + 1 + int(payload[0])
+	if start+3 > len(pkt) {
+		return
+	}
+	end := start + 3 + int(binary.BigEndian.Uint16(pkt[start+1:start+3])&0x0FFF)
+	if end > len(pkt) || (end < len(pkt) && pkt[end] != 0xFF) {
+		return
+	}
+	if crc32MPEG(pkt[start:end]) != 0 {
+		return
+	}
+	section := fn(pkt[start:end])
+	if section == nil || start+len(section) > len(pkt) {
+		return
+	}
+	n := copy(pkt[start:], section)
+	for i := start + n; i < len(pkt); i++ {
+		pkt[i] = 0xFF
+	}
+}
+
+// finishSection 设置段长度并追加 CRC
+func finishSection(b []byte) []byte {
+	length := len(b) + 4 - 3
+	b[1] = b[1]&0xF0 | byte(length>>8)&0x0F
+	b[2] = byte(length)
+	return binary.BigEndian.AppendUint32(b, crc32MPEG(b))
+}
+
+// rewritePAT 记录 PMT PID 并改写为重映射后的 PID
+func (r *tsRemuxer) rewritePAT(section []byte) []byte {
+	if section[0] != 0x00 || len(section) < 12 {
+		return nil
+	}
+	pmtPIDs := make(map[uint16]bool)
+	out := append([]byte(nil), section[:len(section)-4]...)
+	for p := 8; p+4 <= len(out); p += 4 {
+		pid := binary.BigEndian.Uint16(out[p+2:p+4]) & 0x1FFF
+		if binary.BigEndian.Uint16(out[p:p+2]) != 0 {
+			pmtPIDs[pid] = true
+		}
+		binary.BigEndian.PutUint16(out[p+2:p+4], 0xE000|r.remap(pid))
+	}
+	r.pmtPIDs = pmtPIDs
+	if r.pidMap == nil {
+		return nil
+	}
+	return finishSection(out)
+}
+
+// rewritePMT 改写 PCR PID 与基本流 PID，按需删除 CA 描述符
+func (r *tsRemuxer) rewritePMT(section []byte) []byte {
+	if section[0] != 0x02 || len(section) < 16 {
+		return nil
+	}
+	body := section[:len(section)-4]
+	infoLen := int(binary.BigEndian.Uint16(body[10:12]) & 0x0FFF)
+	if 12+infoLen > len(body) {
+		return nil
+	}
+	out := append([]byte(nil), body[:12]...)
+	pcr := binary.BigEndian.Uint16(body[8:10]) & 0x1FFF
+	binary.BigEndian.PutUint16(out[8:10], 0xE000|r.remap(pcr))
+
+	info := r.descriptors(body[12 : 12+infoLen])
+	binary.BigEndian.PutUint16(out[10:12], 0xF000|uint16(len(info)))
+	out = append(out, info...)
+
+	for p := 12 + infoLen; p+5 <= len(body); {
+		esLen := int(binary.BigEndian.Uint16(body[p+3:p+5]) & 0x0FFF)
+		if p+5+esLen > len(body) {
+			return nil
+		}
+		pid := binary.BigEndian.Uint16(body[p+1:p+3]) & 0x1FFF
+		esInfo := r.descriptors(body[p+5 : p+5+esLen])
+		out = append(out, body[p], 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(out[len(out)-4:], 0xE000|r.remap(pid))
+		binary.BigEndian.PutUint16(out[len(out)-2:], 0xF000|uint16(len(esInfo)))
+		out = append(out, esInfo...)
+		p += 5 + esLen
+	}
+	return finishSection(out)
+}
+
+// descriptors 按规则过滤描述符，strip_ca 时删除 CA 描述符
+func (r *tsRemuxer) descriptors(b []byte) []byte {
+	if !r.rule.StripCA {
+		return b
+	}
+	var out []byte
+	for len(b) >= 2 {
+		n := 2 + int(b[1])
+		if n > len(b) {
+			break
+		}
+		if b[0] != caDescriptor {
+			out = append(out, b[:n]...)
+		}
+		b = b[n:]
+	}
+	return out
+}
+
+// rewriteSDT 改写当前传输流 SDT 中的节目名称与提供商，strip_ca 时清除 free_CA_mode
+func (r *tsRemuxer) rewriteSDT(section []byte) []byte {
+	if section[0] != 0x42 || len(section) < 15 {
+		return nil
+	}
+	body := section[:len(section)-4]
+	out := append([]byte(nil), body[:11]...)
+	for p := 11; p+5 <= len(body); {
+		serviceID := int(binary.BigEndian.Uint16(body[p : p+2]))
+		loopLen := int(binary.BigEndian.Uint16(body[p+3:p+5]) & 0x0FFF)
+		if p+5+loopLen > len(body) {
+			return nil
+		}
+		descs := body[p+5 : p+5+loopLen]
+		status := body[p+3] & 0xF0
+		if r.rule.Program == 0 || r.rule.Program == serviceID {
+			descs = r.serviceDescriptors(descs)
+			if r.rule.StripCA {
+				status &^= 0x10
+			}
+		}
+		out = append(out, body[p], body[p+1], body[p+2], status|byte(len(descs)>>8)&0x0F, byte(len(descs)))
+		out = append(out, descs...)
+		p += 5 + loopLen
+	}
+	return finishSection(out)
+}
+
+// serviceDescriptors 改写 service_descriptor 中的提供商与节目名称
+func (r *tsRemuxer) serviceDescriptors(b []byte) []byte {
+	if r.rule.ServiceName == "" && r.rule.Provider == "" {
+		return b
+	}
+	var out []byte
+	for len(b) >= 2 {
+		n := 2 + int(b[1])
+		if n > len(b) {
+			break
+		}
+		d := b[:n]
+		b = b[n:]
+		if d[0] != svcDescTag || len(d) < 4 {
+			out = append(out, d...)
+			continue
+		}
+		provLen := int(d[3])
+		if 4+provLen >= len(d) || 5+provLen+int(d[4+provLen]) > len(d) {
+			out = append(out, d...)
+			continue
+		}
+		provider := d[4 : 4+provLen]
+		name := d[5+provLen : 5+provLen+int(d[4+provLen])]
+		if r.rule.Provider != "" {
+			provider = dvbString(r.rule.Provider)
+		}
+		if r.rule.ServiceName != "" {
+			name = dvbString(r.rule.ServiceName)
+		}
+		if len(provider) > 255 || len(name) > 255 || 3+len(provider)+len(name) > 255 {
+			out = append(out, d...)
+			continue
+		}
+		out = append(out, svcDescTag, byte(3+len(provider)+len(name)), d[2], byte(len(provider)))
+		out = append(out, provider...)
+		out = append(out, byte(len(name)))
+		out = append(out, name...)
+	}
+	return out
+}
+
+// dvbString 编码 DVB 文本，非 ASCII 文本加 0x15 前缀表示 UTF-8
+func dvbString(s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return append([]byte{0x15}, s...)
+		}
+	}
+	return []byte(s)
+}
+
+// SetRemux 设置 Hub 的重封装规则，规则未变化时返回 false
+func (h *StreamHub) SetRemux(rule *config.RemuxRule) bool {
+	old := h.remux.Load()
+	if (old == nil && newTSRemuxer(rule) == nil) || (old != nil && rule != nil && reflect.DeepEqual(old.rule, *rule)) {
+		return false
+	}
+	h.remux.Store(newTSRemuxer(rule))
+	return true
+}
+
+// applyRemux 对一段对齐的 TS 数据应用重封装规则
+func (h *StreamHub) applyRemux(data []byte) {
+	rm := h.remux.Load()
+	if rm == nil {
+		return
+	}
+	for i := 0; i+tsPacketSize <= len(data); i += tsPacketSize {
+		if data[i] == 0x47 {
+			rm.packet(data[i : i+tsPacketSize])
+		}
+	}
+}
@@ -119,7 +119,7 @@ func (h *StreamHub) processTelecomFCCPacket(fmtField byte, data []byte) bool {
 		h.Mu.RUnlock()
 		if should {
 			h.fccSetState(FCC_STATE_UNICAST_PENDING, "收到服务器响应 (FMT 3)")
-			logger.LogPrintf("FCC (电信): 收到服务器响应 (FMT 3)")
+			logger.Debugf("FCC (电信): 收到服务器响应 (FMT 3)")
 		}
 		return true
 
@@ -131,7 +131,7 @@ func (h *StreamHub) processTelecomFCCPacket(fmtField byte, data []byte) bool {
 			return true
 		}
 		h.fccSetState(FCC_STATE_MCAST_REQUESTED, "收到同步通知 (FMT 4)，准备切换到组播")
-		logger.LogPrintf("FCC (电信): 收到同步通知 (FMT 4)，准备切换到组播")
+		logger.Debugf("FCC (电信): 收到同步通知 (FMT 4)，准备切换到组播")
 
 		// 调用prepareSwitchToMulticast来准备切换到多播模式
 		h.prepareSwitchToMulticast()
@@ -151,13 +151,13 @@ func (h *StreamHub) processHuaweiFCCPacket(fmtField byte, data []byte) bool {
 		h.Mu.RUnlock()
 		if should {
 			h.fccSetState(FCC_STATE_UNICAST_PENDING, "收到服务器响应 (FMT 6)")
-			logger.LogPrintf("FCC (华为): 收到服务器响应 (FMT 6)")
+			logger.Debugf("FCC (华为): 收到服务器响应 (FMT 6)")
 
 			if len(data) >= 32 {
 				flag := binary.BigEndian.Uint32(data[28:32])
 				if flag&0x01000000 != 0 {
 					h.fccSetState(FCC_STATE_UNICAST_ACTIVE, "需要NAT穿越")
-					logger.LogPrintf("FCC (华为): 需要NAT穿越")
+					logger.Debugf("FCC (华为): 需要NAT穿越")
 				}
 			}
 		}
@@ -173,7 +173,7 @@ func (h *StreamHub) processHuaweiFCCPacket(fmtField byte, data []byte) bool {
 		}
 		if active {
 			h.fccSetState(FCC_STATE_MCAST_REQUESTED, "收到同步通知 (FMT 8)，准备切换到组播")
-			logger.LogPrintf("FCC (华为): 收到同步通知 (FMT 8)，准备切换到组播")
+			logger.Debugf("FCC (华为): 收到同步通知 (FMT 8)，准备切换到组播")
 			h.prepareSwitchToMulticast()
 			return true
 		}
@@ -185,7 +185,7 @@ func (h *StreamHub) processHuaweiFCCPacket(fmtField byte, data []byte) bool {
 		h.Mu.RUnlock()
 		if should {
 			h.fccSetState(FCC_STATE_UNICAST_ACTIVE, "收到NAT穿越包 (FMT 12)")
-			logger.LogPrintf("FCC (华为): 收到NAT穿越包 (FMT 12)")
+			logger.Debugf("FCC (华为): 收到NAT穿越包 (FMT 12)")
 		}
 		return true
 
@@ -271,7 +271,7 @@ func (h *StreamHub) prepareSwitchToMulticast() {
 	// 设置终止序列号（起始序列号+缓冲区大小）
 	h.Mu.Lock()
 	h.fccTermSeq = h.fccStartSeq + uint16(h.fccCacheSize)
-	logger.LogPrintf("FCC: 终止序列号设置为 %d (起始序列号 %d + 缓冲区大小 %d)",
+	logger.Debugf("FCC: 终止序列号设置为 %d (起始序列号 %d + 缓冲区大小 %d)",
 		h.fccTermSeq, h.fccStartSeq, h.fccCacheSize)
 	h.Mu.Unlock()
 
@@ -285,7 +285,7 @@ func (h *StreamHub) prepareSwitchToMulticast() {
 				h.Mu.Lock()
 				h.fccTermSent = true
 				h.Mu.Unlock()
-				logger.LogPrintf("FCC: 终止包已发送，终止序列号 %d", h.fccTermSeq)
+				logger.Debugf("FCC: 终止包已发送，终止序列号 %d", h.fccTermSeq)
 			}
 		}()
 	}
@@ -925,7 +925,7 @@ func (h *StreamHub) processFCCMediaBufRef(bufRef *BufferRef) {
 	pending := h.fccState == FCC_STATE_UNICAST_PENDING
 	if pending && len(data) >= 12 {
 		h.fccStartSeq = binary.BigEndian.Uint16(data[2:4])
-		logger.LogPrintf("FCC: 起始序列号为 %d", h.fccStartSeq)
+		logger.Debugf("FCC: 起始序列号为 %d", h.fccStartSeq)
 	}
 	h.Mu.Unlock()
 
@@ -937,7 +937,7 @@ func (h *StreamHub) processFCCMediaBufRef(bufRef *BufferRef) {
 
 	if pending {
 		h.fccSetState(FCC_STATE_UNICAST_ACTIVE, "收到第一个单播数据包")
-		logger.LogPrintf("FCC: 收到第一个单播数据包，切换到单播活动状态")
+		logger.Debugf("FCC: 收到第一个单播数据包，切换到单播活动状态")
 	}
 
 	h.Mu.Lock()
@@ -1008,7 +1008,7 @@ func (h *StreamHub) handleMcastDataDuringTransition(data []byte) {
 					h.fccTermSent = true
 					h.fccTermSeq = sequence // 记录终止序列号
 					h.Mu.Unlock()
-					logger.LogPrintf("FCC: 终止包已发送，序列号 %d", sequence)
+					logger.Debugf("FCC: 终止包已发送，序列号 %d", sequence)
 				}
 			}()
 		}
@@ -1129,7 +1129,7 @@ func (h *StreamHub) fccSetState(newState int, reason string) bool {
 	}
 
 	// 记录状态转换
-	logger.LogPrintf("FCC: 状态从 %d 转换到 %d，原因: %s", h.fccState, newState, reason)
+	logger.Debugf("FCC: 状态从 %d 转换到 %d，原因: %s", h.fccState, newState, reason)
 
 	h.fccState = newState
 
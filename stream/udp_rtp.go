@@ -20,6 +20,8 @@ import (
 
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/tracing"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 const (
@@ -86,6 +88,7 @@ const (
 
 // ====================
 // RingBuffer 环形缓冲区
+// 槽位按需增长到 size，占用的字节计入全局缓存预算（见 cache_budget.go）
 // ====================
 type RingBuffer struct {
 	buf   [][]byte
@@ -93,11 +96,15 @@ type RingBuffer struct {
 	start int
 	count int
 	lock  sync.Mutex
+
+	bytes   int64  // 缓存帧占用的字节数（按底层数组容量计）
+	tracked bool   // 是否已登记到缓存预算
+	seq     uint64 // 累计写入的帧数，第 n 个写入的帧序号为 n-1，用于断线续播定位
 }
 
 func NewRingBuffer(size int) *RingBuffer {
 	return &RingBuffer{
-		buf:  make([][]byte, size),
+		buf:  make([][]byte, 0, min(size, 64)),
 		size: size,
 	}
 }
@@ -105,13 +112,27 @@ func NewRingBuffer(size int) *RingBuffer {
 func (r *RingBuffer) Push(item []byte) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	r.push(item)
+}
+
+// push 写入一帧，已满时覆盖最旧的帧；调用方需持有锁
+func (r *RingBuffer) push(item []byte) {
+	delta := int64(cap(item))
 	if r.count < r.size {
-		r.buf[(r.start+r.count)%r.size] = item
+		// 未满过时帧连续存放在 buf 末尾，直接追加
+		if i := (r.start + r.count) % r.size; i < len(r.buf) {
+			r.buf[i] = item
+		} else {
+			r.buf = append(r.buf, item)
+		}
 		r.count++
 	} else {
+		delta -= int64(cap(r.buf[r.start]))
 		r.buf[r.start] = item
 		r.start = (r.start + 1) % r.size
 	}
+	r.seq++
+	r.account(delta)
 }
 
 func (r *RingBuffer) GetAll() [][]byte {
@@ -129,6 +150,31 @@ func (r *RingBuffer) GetAll() [][]byte {
 	return result
 }
 
+// Seq 返回下一个写入的帧的序号
+func (r *RingBuffer) Seq() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.seq
+}
+
+// Since 返回序号不小于 seq 的缓存帧，seq 对应的帧已被淘汰时从最旧的帧开始
+func (r *RingBuffer) Since(seq uint64) [][]byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if seq >= r.seq {
+		return nil
+	}
+	skip := 0
+	if oldest := r.seq - uint64(r.count); seq > oldest {
+		skip = int(seq - oldest)
+	}
+	result := make([][]byte, 0, r.count-skip)
+	for i := skip; i < r.count; i++ {
+		result = append(result, r.buf[(r.start+i)%r.size])
+	}
+	return result
+}
+
 // Reset clears the ring buffer
 func (r *RingBuffer) Reset() {
 	r.lock.Lock()
@@ -139,6 +185,8 @@ func (r *RingBuffer) Reset() {
 	for i := range r.buf {
 		r.buf[i] = nil
 	}
+	r.buf = r.buf[:0]
+	r.account(-r.bytes)
 }
 
 // GetCount 返回当前缓冲区中的元素数量
@@ -148,28 +196,56 @@ func (r *RingBuffer) GetCount() int {
 	return r.count
 }
 
+// Bytes 返回缓存帧占用的字节数
+func (r *RingBuffer) Bytes() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.bytes
+}
+
 // 优化版Push，支持预分配和重用
 func (r *RingBuffer) PushWithReuse(item []byte) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	r.push(item)
+}
 
-	if r.count < r.size {
-		r.buf[(r.start+r.count)%r.size] = item
-		r.count++
-	} else {
-		// 重用已有位置的缓冲区
-		r.buf[r.start] = item
+// trim 从最旧的帧开始淘汰，直到占用不超过 max 字节，返回淘汰的帧数
+func (r *RingBuffer) trim(max int64) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var freed int64
+	n := 0
+	for r.count > 0 && r.bytes-freed > max {
+		freed += int64(cap(r.buf[r.start]))
+		r.buf[r.start] = nil
 		r.start = (r.start + 1) % r.size
+		r.count--
+		n++
 	}
+	r.account(-freed)
+	return n
+}
+
+// account 更新占用字节数并同步到缓存预算；调用方需持有锁
+func (r *RingBuffer) account(delta int64) {
+	if delta == 0 {
+		return
+	}
+	r.bytes += delta
+	globalCacheBudget.update(r, delta)
 }
 
 // ====================
 // StreamHub 流处理中心
 // ====================
 type hubClient struct {
-	ch        chan []byte
-	connID    string
-	dropCount uint64 // 客户端丢包计数
+	ch      chan []byte
+	done    chan struct{} // 客户端移除或 Hub 关闭时关闭；ch 不关闭，广播无需与移除互斥
+	raw     bool          // 原样转发 RTP 包，不提取 TS 负载
+	connID  string
+	stats   *clientStats // 发送统计，见 hub_clients.go
+	resumed bool         // 断线续播的客户端，队列中已预先放入续播帧，不再发送初始化帧
 	// lastFrame []byte // 客户端最后一帧，用于重发
 }
 
@@ -199,12 +275,14 @@ type StreamHub struct {
 	state       int // 0: stopped, 1: playing, 2: error
 	stateCond   *sync.Cond
 	OnEmpty     func(h *StreamHub) // 当客户端数量为0时触发
+	movedTo     *StreamHub         // 客户端已迁移到的新 Hub
 
 	// UDP连接相关字段
 	UdpConns       []*net.UDPConn
 	rtpBuffer      []byte
 	rejoinTimer    *time.Timer   // 重新加入组播组的定时器
 	rejoinInterval time.Duration // 重新加入组播组的时间间隔
+	idleTimeout    time.Duration // 组播源空闲超时，超时后由看门狗重新加入组播组
 	ifaces         []string      // 指定的网络接口
 
 	// 客户端管理通道
@@ -239,6 +317,18 @@ type StreamHub struct {
 
 	// 添加客户端状态更新通道
 	clientStateChan chan int
+
+	// 资源占用统计
+	usage hubUsage
+
+	// TS 重封装规则，未配置时为 nil
+	remux atomic.Pointer[tsRemuxer]
+
+	// 断线续播，见 hub_resume.go
+	resume hubResume
+
+	// 广播使用的客户端快照，Clients 变化时在 h.Mu 内重建，广播时无锁读取
+	snapshot atomic.Pointer[[]hubClient]
 }
 
 // 定义客户端状态常量
@@ -310,6 +400,11 @@ func NewStreamHub(addrs []string, ifaces []string) (*StreamHub, error) {
 	// 获取多播重新加入间隔配置
 	config.CfgMu.RLock()
 	hub.rejoinInterval = config.Cfg.Server.McastRejoinInterval
+	hub.idleTimeout = config.Cfg.Server.McastIdleTimeout
+	hub.remux.Store(newTSRemuxer(config.Cfg.MatchRemux(addrs[0])))
+	if info := config.Cfg.Channels.ByAddr(strings.Join(addrs, ",")); info != nil {
+		hub.SetResume(info.Resume)
+	}
 	config.CfgMu.RUnlock()
 
 	var lastErr error
@@ -360,6 +455,7 @@ func NewStreamHub(addrs []string, ifaces []string) (*StreamHub, error) {
 	hub.fccPendingBuf = NewRingBuffer(hub.fccCacheSize)
 
 	go hub.run()
+	go hub.watchdog()
 	hub.startReadLoops()
 	return hub, nil
 }
@@ -468,6 +564,7 @@ func (h *StreamHub) readLoop(conn *net.UDPConn, hubAddr string) {
 			h.BufPool.Put(buf)
 			continue
 		}
+		start := time.Now()
 
 		inRef := NewPooledBufferRef(buf, buf[:n], h.BufPool)
 
@@ -484,11 +581,12 @@ func (h *StreamHub) readLoop(conn *net.UDPConn, hubAddr string) {
 			inRef.Put()
 			continue
 		}
+		// 广播后归还缓冲，原始 RTP 包同时发给原样转发的客户端
+		h.broadcastRef(outRef, inRef.data)
 		if outRef != inRef {
 			inRef.Put()
 		}
-		// 广播后归还缓冲
-		h.broadcastRef(outRef)
+		h.usage.record(n, start)
 	}
 }
 
@@ -503,6 +601,7 @@ func (h *StreamHub) processRTPPacketRef(inRef *BufferRef) *BufferRef {
 		return nil
 	}
 	if len(data) >= 188 && data[0] == 0x47 {
+		h.applyRemux(data)
 		return inRef
 	}
 	if len(data) < 12 {
@@ -590,11 +689,15 @@ func (h *StreamHub) processRTPPacketRef(inRef *BufferRef) *BufferRef {
 	fccEnabled := h.fccEnabled
 	currentFccState := h.fccState
 	h.Mu.RUnlock()
+	rm := h.remux.Load()
 	for i := 0; i < len(chunk); i += 188 {
 		ts := chunk[i : i+188]
 		if ts[0] != 0x47 {
 			continue
 		}
+		if rm != nil {
+			rm.packet(ts)
+		}
 		pid := ((int(ts[1]) & 0x1F) << 8) | int(ts[2])
 		tsCC := ts[3] & 0x0F
 		if fccEnabled {
@@ -778,8 +881,6 @@ func (h *StreamHub) broadcast(data []byte) {
 	pid := ((uint16(data[1]) & 0x1f) << 8) | uint16(data[2])
 
 	h.Mu.Lock()
-	defer h.Mu.Unlock()
-
 	if pid == PAT_PID {
 		// 保存PAT包用于FCC
 		if h.patBuffer == nil {
@@ -808,19 +909,13 @@ func (h *StreamHub) broadcast(data []byte) {
 		}
 	}
 
-	// 发送数据给所有客户端
-	for _, c := range h.Clients {
-		select {
-		case c.ch <- data:
-		case <-time.After(100 * time.Millisecond):
-			// 如果发送超时，则断开客户端连接
-			// 注意：这里不能直接调用Close，因为hubClient没有Close方法
-		}
-	}
+	h.Mu.Unlock()
+
+	h.deliver(data, data)
 }
 
-// 零拷贝引用广播，发送完成后归还池
-func (h *StreamHub) broadcastRef(bufRef *BufferRef) {
+// 零拷贝引用广播，发送完成后归还池；raw 为接收到的原始包
+func (h *StreamHub) broadcastRef(bufRef *BufferRef, raw []byte) {
 	// 检查是否是FCC多播过渡阶段
 	if h.IsFccEnabled() {
 		h.Mu.RLock()
@@ -832,28 +927,67 @@ func (h *StreamHub) broadcastRef(bufRef *BufferRef) {
 			return
 		}
 	}
-	data := bufRef.data
-	for _, c := range h.Clients {
+	h.deliver(bufRef.data, raw)
+	bufRef.Put()
+}
+
+// deliver 把数据发送给快照中的所有客户端，原样转发 RTP 的客户端发送 raw，不持有 h.Mu：
+// 先非阻塞发送，客户端队列已满时最多等待 100ms，超时计为丢包
+func (h *StreamHub) deliver(data, raw []byte) {
+	h.cacheFrame(data)
+	clients := h.snapshot.Load()
+	if clients == nil {
+		return
+	}
+	for _, c := range *clients {
+		data := data
+		if c.raw {
+			data = raw
+		}
+		select {
+		case c.ch <- data:
+			continue
+		case <-c.done:
+			continue
+		default:
+		}
 		select {
 		case c.ch <- data:
+		case <-c.done:
 		case <-time.After(100 * time.Millisecond):
+			h.usage.drop()
+			c.stats.drop()
 		}
 	}
-	bufRef.Put()
+}
+
+// syncClients 按 Clients 重建广播使用的快照，调用方持有 h.Mu
+func (h *StreamHub) syncClients() {
+	clients := make([]hubClient, 0, len(h.Clients))
+	for _, c := range h.Clients {
+		clients = append(clients, c)
+	}
+	h.snapshot.Store(&clients)
 }
 
 // ====================
 // 客户端管理循环
 // ====================
 func (h *StreamHub) run() {
+	// 启用断线续播时最后一个客户端断开后保留 Hub 的计时
+	var linger <-chan time.Time
 	for {
 		select {
 		case client := <-h.AddCh:
+			linger = nil
 			h.Mu.Lock()
 			h.Clients[client.connID] = client
+			h.syncClients()
 			curCount := len(h.Clients)
 			h.Mu.Unlock()
-			go h.sendInitial(client.ch)
+			if !client.resumed {
+				go h.sendInitial(client.ch)
+			}
 			logger.LogPrintf("➕ 客户端加入，当前客户端数量=%d", curCount)
 
 		case connID := <-h.RemoveCh:
@@ -865,6 +999,7 @@ func (h *StreamHub) run() {
 			if client, ok := h.Clients[connID]; ok {
 				clientToClose = &client
 				delete(h.Clients, connID)
+				h.syncClients()
 				curCount = len(h.Clients)
 				logger.LogPrintf("➖ 客户端离开，当前客户端数量=%d", curCount)
 
@@ -875,41 +1010,62 @@ func (h *StreamHub) run() {
 			}
 			h.Mu.Unlock()
 
-			// 在锁外关闭客户端channel
-			if clientToClose != nil && clientToClose.ch != nil {
-				close(clientToClose.ch)
+			// 在锁外通知客户端退出
+			if clientToClose != nil && clientToClose.done != nil {
+				close(clientToClose.done)
 			}
 
-			// 如果没有客户端了，异步关闭Hub
+			// 如果没有客户端了，异步关闭Hub；启用断线续播时等待客户端在窗口内重新连接
 			if shouldCloseHub {
-				// 只有在启用FCC时才清理FCC连接
-				if h.fccEnabled {
-					h.cleanupFCC()
+				if window := h.resumeWindow(); window > 0 {
+					linger = time.After(window)
+					continue
 				}
+				h.closeEmpty()
+				return
+			}
 
-				// 在单独的goroutine中关闭以避免死锁
-				go h.Close()
-				if h.OnEmpty != nil {
-					h.OnEmpty(h) // 自动删除 hub
-				}
+		case <-linger:
+			h.Mu.RLock()
+			curCount := len(h.Clients)
+			h.Mu.RUnlock()
+			if curCount == 0 {
+				logger.LogPrintf("⏹️ 断线续播窗口内没有客户端重新连接，关闭 Hub")
+				h.closeEmpty()
 				return
 			}
+			linger = nil
 
 		case <-h.Closed:
 			h.Mu.Lock()
-			// 安全地关闭所有客户端通道
+			// 通知所有客户端退出
 			for _, client := range h.Clients {
-				if client.ch != nil {
-					close(client.ch)
+				if client.done != nil {
+					close(client.done)
 				}
 			}
 			h.Clients = nil
+			h.syncClients()
 			h.Mu.Unlock()
 			return
 		}
 	}
 }
 
+// closeEmpty 没有客户端时关闭 Hub 并从管理器中删除
+func (h *StreamHub) closeEmpty() {
+	// 只有在启用FCC时才清理FCC连接
+	if h.fccEnabled {
+		h.cleanupFCC()
+	}
+
+	// 在单独的goroutine中关闭以避免死锁
+	go h.Close()
+	if h.OnEmpty != nil {
+		h.OnEmpty(h) // 自动删除 hub
+	}
+}
+
 // ====================
 // 新客户端发送初始化帧
 // FCC / 非 FCC 统一入口
@@ -930,7 +1086,7 @@ func (h *StreamHub) sendInitial(ch chan []byte) {
 
 		// 获取缓存快照
 		h.Mu.Lock()
-		cachedFrames := h.CacheBuffer.GetAll()
+		cachedFrames := h.joinCache()
 		h.Mu.Unlock()
 
 		// 异步非阻塞发送
@@ -971,7 +1127,7 @@ func (h *StreamHub) sendInitial(ch chan []byte) {
 			}
 			packets = append(packets, frames[start:]...)
 		} else {
-			cachedFrames := h.CacheBuffer.GetAll()
+			cachedFrames := h.joinCache()
 			packets = append(packets, cachedFrames...)
 		}
 
@@ -987,7 +1143,7 @@ func (h *StreamHub) sendInitial(ch chan []byte) {
 
 		// 补充普通缓存（如果没有FCC帧或者需要更多数据）
 		if !fccFramesAvailable || len(packets) < 10 {
-			cachedFrames := h.CacheBuffer.GetAll()
+			cachedFrames := h.joinCache()
 			packets = append(packets, cachedFrames...)
 		}
 	}
@@ -1026,7 +1182,7 @@ func (h *StreamHub) sendPacketsNonBlocking(ch chan []byte, packets [][]byte) {
 func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentType string, updateActive func()) {
 	select {
 	case <-h.Closed:
-		http.Error(w, "Stream hub closed", http.StatusServiceUnavailable)
+		httperror.Error(w, r, "Stream hub closed", http.StatusServiceUnavailable)
 		return
 	default:
 	}
@@ -1036,9 +1192,25 @@ func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentTyp
 		connID = strconv.FormatInt(time.Now().UnixNano(), 10)
 	}
 
+	raw := rawRTPRequested(r, h.AddrList[0])
+	// 断线续播：携带同一 token 在窗口内重新连接的客户端先收到断开后的缓存帧
+	var resumeID string
+	var resumed [][]byte
+	var resuming bool
+	if !raw && h.resumeWindow() > 0 {
+		if resumeID = resumeKey(r); resumeID != "" {
+			resumed, resuming = h.takeResume(resumeID)
+		}
+	}
+
 	// 增加缓冲区大小
-	ch := make(chan []byte, 4096)
-	h.AddCh <- hubClient{ch: ch, connID: connID}
+	ch := make(chan []byte, 4096+len(resumed))
+	for _, f := range resumed {
+		ch <- f
+	}
+	done := make(chan struct{})
+	stats := newClientStats(r)
+	h.AddCh <- hubClient{ch: ch, done: done, raw: raw, connID: connID, stats: stats, resumed: resuming}
 
 	// 检查是否启用了FCC
 	h.Mu.Lock()
@@ -1096,7 +1268,8 @@ func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentTyp
 	}
 
 	defer func() {
-		h.RemoveCh <- connID
+		// 客户端可能已随配置变更迁移到新 Hub
+		h.current().RemoveCh <- connID
 
 		// 只有在FCC已初始化的情况下才发送终止包
 		if fccEnabled && fccInitialized {
@@ -1143,24 +1316,50 @@ func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentTyp
 		w.Header().Set("Transfer-Encoding", "chunked")
 		w.Header().Set("Accept-Ranges", "none")
 	}
+	w = LimitWriter(w, r)
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		httperror.Error(w, r, "Streaming unsupported!", http.StatusInternalServerError)
 		return
 	}
 
 	ctx := r.Context()
-	bufferedBytes := 0
-	const maxBufferSize = 128 * 1024 // 128KB缓冲区
-	flushTicker := time.NewTicker(50 * time.Millisecond)
+	// 多个包合并为一次写出，batchDelay 内未凑满一批时也写出，控制延迟
+	batchSize, batchDelay := writeBatchConfig()
+	batch := newBatchWriter(w, flusher, stats, batchSize)
+	if resumeID != "" {
+		// 记录断开时的位置：队列中与未写出的帧视为未播放
+		defer func() { h.saveResume(resumeID, len(ch)+batch.packets) }()
+	}
+	// 频道结束或 hub 关闭时写出最后一批未满的数据，客户端已断开时无需再写
+	defer func() {
+		if ctx.Err() == nil {
+			_ = batch.flush()
+		}
+	}()
+	flushTicker := time.NewTicker(batchDelay)
 	defer flushTicker.Stop()
 	activeTicker := time.NewTicker(5 * time.Second)
 	defer activeTicker.Stop()
 
-	if !h.WaitForPlaying(ctx) {
+	// 等待组播（或 FCC 单播）数据到达，新加入的频道起播慢时主要耗在这里
+	_, waitSpan := tracing.Start(ctx, "hub.wait_playing", tracing.KindInternal)
+	waitSpan.SetAttr("hub.fcc", fccInitialized)
+	playing := h.WaitForPlaying(ctx)
+	if !playing {
+		waitSpan.SetError(ctx.Err())
+	}
+	waitSpan.End()
+	if !playing {
 		return
 	}
 
+	// 多节目传输流按 program / pid 参数只转发选中的节目，原样转发 RTP 时不过滤
+	var filter *TSFilter
+	if !raw {
+		filter = NewTSFilter(r.URL.Query())
+	}
+
 	// 检查客户端是否已经断开连接
 	clientDisconnected := make(chan struct{})
 	go func() {
@@ -1174,19 +1373,17 @@ func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentTyp
 			if !ok {
 				return
 			}
-			n, err := w.Write(data)
-			if err != nil {
-				return
+			if filter != nil {
+				if data = filter.Filter(data); len(data) == 0 {
+					continue
+				}
 			}
-			bufferedBytes += n
-			if bufferedBytes >= maxBufferSize {
-				flusher.Flush()
-				bufferedBytes = 0
+			if err := batch.add(data); err != nil {
+				return
 			}
 		case <-flushTicker.C:
-			if bufferedBytes > 0 {
-				flusher.Flush()
-				bufferedBytes = 0
+			if err := batch.flush(); err != nil {
+				return
 			}
 		case <-activeTicker.C:
 			if updateActive != nil {
@@ -1195,6 +1392,8 @@ func (h *StreamHub) ServeHTTP(w http.ResponseWriter, r *http.Request, contentTyp
 		case <-clientDisconnected:
 			// 客户端断开连接，退出循环
 			return
+		case <-done:
+			return
 		case <-h.Closed:
 			return
 		}
@@ -1232,6 +1431,7 @@ func (h *StreamHub) Close() {
 	// 暂存客户端连接用于稍后关闭
 	clients := h.Clients
 	h.Clients = nil
+	h.syncClients()
 
 	// 清理各种缓冲区
 	if h.CacheBuffer != nil {
@@ -1268,10 +1468,10 @@ func (h *StreamHub) Close() {
 		}
 	}
 
-	// 在锁外关闭所有客户端channel
+	// 在锁外通知所有客户端退出
 	for _, client := range clients {
-		if client.ch != nil {
-			close(client.ch)
+		if client.done != nil {
+			close(client.done)
 		}
 	}
 
@@ -1541,10 +1741,33 @@ func (h *StreamHub) TransferClientsTo(newHub *StreamHub) {
 		}
 	}
 
+	newHub.syncClients()
 	h.Clients = make(map[string]hubClient)
+	h.syncClients()
+	h.movedTo = newHub
 	logger.LogPrintf("🔄 客户端已迁移到新Hub，数量=%d", len(newHub.Clients))
 }
 
+// current 返回客户端当前所在的 Hub（沿迁移链查找）
+func (h *StreamHub) current() *StreamHub {
+	for {
+		h.Mu.RLock()
+		next := h.movedTo
+		h.Mu.RUnlock()
+		if next == nil {
+			return h
+		}
+		h = next
+	}
+}
+
+// Ifaces 返回 Hub 使用的网络接口
+func (h *StreamHub) Ifaces() []string {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return append([]string(nil), h.ifaces...)
+}
+
 // SetRejoinInterval 设置重新加入间隔
 func (h *StreamHub) SetRejoinInterval(interval time.Duration) {
 	h.Mu.Lock()
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+)
+
+// maxWatchdogBackoff 连续重新加入仍收不到数据时，两次重新加入的最长间隔
+const maxWatchdogBackoff = 5 * time.Minute
+
+// SetIdleTimeout 设置组播源空闲超时，超过该时长未收到数据时重新加入组播组，0 表示禁用
+func (h *StreamHub) SetIdleTimeout(timeout time.Duration) {
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+	h.idleTimeout = timeout
+}
+
+// GetIdleTimeout 获取组播源空闲超时
+func (h *StreamHub) GetIdleTimeout() time.Duration {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return h.idleTimeout
+}
+
+// watchdog 组播源看门狗：部分 IGMP Snooping 交换机会悄悄丢弃成员关系，
+// 长时间收不到数据时离开并重新加入组播组（重建 socket），仍无数据时按指数退避重试
+func (h *StreamHub) watchdog() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	since := time.Now() // 最近一次收到数据或重新加入的时间
+	wait := time.Duration(0)
+	attempts := 0
+	for {
+		select {
+		case <-h.Closed:
+			return
+		case <-ticker.C:
+		}
+
+		timeout := h.GetIdleTimeout()
+		if timeout <= 0 {
+			since, wait, attempts = time.Now(), 0, 0
+			continue
+		}
+		if last := atomic.LoadInt64(&h.usage.last); last > since.UnixNano() {
+			since = time.Unix(0, last)
+			if attempts > 0 {
+				logger.LogPrintf("✅ 组播源已恢复: %v，重新加入 %d 次", h.AddrList, attempts)
+				wait, attempts = 0, 0
+			}
+		}
+		if wait < timeout {
+			wait = timeout
+		}
+		idle := time.Since(since)
+		if idle < wait {
+			continue
+		}
+
+		attempts++
+		atomic.AddUint64(&h.usage.rejoins, 1)
+		logger.LogPrintf("⚠️ 组播源 %v 已 %v 未收到数据，重新加入组播组（第 %d 次）", h.AddrList, idle.Round(time.Second), attempts)
+		if err := h.UpdateInterfaces(h.Ifaces()); err != nil {
+			logger.LogPrintf("❌ 重新加入组播组失败 %v: %v", h.AddrList, err)
+		}
+		since = time.Now()
+		if wait *= 2; wait > maxWatchdogBackoff {
+			wait = maxWatchdogBackoff
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	// queueSize 待导出 span 的队列长度，导出跟不上时丢弃新的 span，不影响请求处理
+	queueSize = 4096
+	// batchSize 单次导出的最大 span 数
+	batchSize = 512
+	// flushInterval 未攒满一批时的导出间隔
+	flushInterval = 5 * time.Second
+)
+
+// tracer 当前生效的配置与导出队列，配置变化时整体替换
+type tracer struct {
+	endpoint  string
+	headers   map[string]string
+	ratio     float64
+	propagate bool
+	exclude   []string
+	resource  []attribute
+	client    *http.Client
+	queue     chan *Span
+	stop      chan struct{}
+	dropped   atomic.Int64
+}
+
+var (
+	active atomic.Pointer[tracer]
+	mu     sync.Mutex
+	last   config.TracingConfig
+)
+
+func current() *tracer { return active.Load() }
+
+// Configure 应用配置，配置变化时停止旧的导出并发送剩余的 span
+func Configure(cfg config.TracingConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	if old := active.Load(); old != nil && sameConfig(last, cfg) {
+		return
+	}
+	last = cfg
+	if old := active.Swap(nil); old != nil {
+		close(old.stop)
+	}
+	if !cfg.Enabled {
+		return
+	}
+
+	service := cfg.ServiceName
+	if service == "" {
+		service = "tvgate"
+	}
+	host, _ := os.Hostname()
+	t := &tracer{
+		endpoint:  cfg.Endpoint,
+		headers:   cfg.Headers,
+		ratio:     cfg.SampleRatio,
+		propagate: cfg.Propagate,
+		exclude:   cfg.Exclude,
+		resource: []attribute{
+			{"service.name", service},
+			{"service.version", config.Version},
+			{"host.name", host},
+		},
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan *Span, queueSize),
+		stop:   make(chan struct{}),
+	}
+	go t.run()
+	active.Store(t)
+	logger.LogPrintf("🔍 链路追踪已启用，导出到 %s，采样率 %g", cfg.Endpoint, cfg.SampleRatio)
+}
+
+func sameConfig(a, b config.TracingConfig) bool {
+	if a.Enabled != b.Enabled || a.Endpoint != b.Endpoint || a.ServiceName != b.ServiceName ||
+		a.SampleRatio != b.SampleRatio || a.Propagate != b.Propagate || a.Timeout != b.Timeout ||
+		len(a.Headers) != len(b.Headers) || !slices.Equal(a.Exclude, b.Exclude) {
+		return false
+	}
+	for k, v := range a.Headers {
+		if b.Headers[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run 攒批导出，停止时导出队列中剩余的 span
+func (t *tracer) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			logger.LogPrintf("⚠️ 链路追踪导出失败（%d 个 span）: %v", len(batch), err)
+		}
+		batch = batch[:0]
+		if n := t.dropped.Swap(0); n > 0 {
+			logger.LogPrintf("⚠️ 链路追踪导出队列已满，丢弃 %d 个 span", n)
+		}
+	}
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export 以 OTLP/HTTP JSON 编码发送一批 span
+func (t *tracer) export(batch []*Span) error {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttrs(t.resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/qist/tvgate", Version: config.Version}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("返回状态码 %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// OTLP JSON 编码：trace-id/span-id 为十六进制字符串，64 位整数为十进制字符串
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID:           fmt.Sprintf("%x", s.traceID),
+		SpanID:            fmt.Sprintf("%x", s.spanID),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+		Attributes:        otlpAttrs(s.attrs),
+		Status:            otlpStatus{Code: s.status, Message: s.statusMsg},
+	}
+	if s.parent != [8]byte{} {
+		o.ParentSpanID = fmt.Sprintf("%x", s.parent)
+	}
+	for _, e := range s.events {
+		o.Events = append(o.Events, otlpEvent{TimeUnixNano: unixNano(e.time), Name: e.name, Attributes: otlpAttrs(e.attrs)})
+	}
+	return o
+}
+
+func unixNano(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+func otlpAttrs(attrs []attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch x := a.value.(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case uint64:
+			v = map[string]any{"intValue": strconv.FormatUint(x, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": x}
+		case time.Duration:
+			v = map[string]any{"doubleValue": x.Seconds()}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, otlpKeyValue{Key: a.key, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,244 @@
+// Package tracing 链路追踪：记录请求在 handler、代理组选择、上游请求以及组播 Hub 各阶段的耗时，
+// 以 OpenTelemetry OTLP/HTTP（JSON 编码）格式导出，可直接发送到 OpenTelemetry Collector、Jaeger、Tempo 等。
+// 未启用或未被采样时 Start 返回 nil，*Span 的方法对 nil 均为空操作，调用方无需判断
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanKind 对应 OTLP 的 Span.SpanKind
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// 状态码对应 OTLP 的 Status.StatusCode
+const (
+	statusUnset = 0
+	statusOK    = 1
+	statusError = 2
+)
+
+type attribute struct {
+	key   string
+	value any
+}
+
+type event struct {
+	time  time.Time
+	name  string
+	attrs []attribute
+}
+
+// Span 一个处理阶段
+type Span struct {
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	kind    SpanKind
+	start   time.Time
+
+	mu        sync.Mutex
+	end       time.Time
+	attrs     []attribute
+	events    []event
+	status    int
+	statusMsg string
+}
+
+type spanKey struct{}
+
+// remoteParent 从请求头 traceparent 解析出的上级 span
+type remoteParent struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+type remoteKey struct{}
+
+// Enabled 返回是否追踪该路径
+func Enabled(path string) bool {
+	t := current()
+	if t == nil {
+		return false
+	}
+	for _, p := range t.exclude {
+		if strings.HasPrefix(path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Start 开始一个 span，上下文中有 span 时作为其子 span，否则按采样率决定是否开始新的链路
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	t := current()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	switch {
+	case SpanFromContext(ctx) != nil:
+		p := SpanFromContext(ctx)
+		s.traceID, s.parent = p.traceID, p.spanID
+	case ctx.Value(remoteKey{}) != nil:
+		rp := ctx.Value(remoteKey{}).(remoteParent)
+		if !rp.sampled {
+			return ctx, nil
+		}
+		s.traceID, s.parent = rp.traceID, rp.spanID
+	default:
+		if !t.sample() {
+			return ctx, nil
+		}
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext 返回上下文中的 span，没有时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithSpan 将 span 放入另一个上下文，用于不继承请求上下文取消的场景
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// Extract 解析请求头 traceparent（W3C Trace Context），之后开始的 span 归入调用方的链路
+func Extract(ctx context.Context, h http.Header) context.Context {
+	if current() == nil {
+		return ctx
+	}
+	// 格式: 00-<32 位 trace-id>-<16 位 parent-id>-<2 位 flags>
+	parts := strings.Split(strings.TrimSpace(h.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+	var rp remoteParent
+	flags, err1 := hex.DecodeString(parts[3])
+	_, err2 := hex.Decode(rp.traceID[:], []byte(parts[1]))
+	_, err3 := hex.Decode(rp.spanID[:], []byte(parts[2]))
+	if err1 != nil || err2 != nil || err3 != nil || rp.traceID == [16]byte{} || rp.spanID == [8]byte{} {
+		return ctx
+	}
+	rp.sampled = flags[0]&1 == 1
+	return context.WithValue(ctx, remoteKey{}, rp)
+}
+
+// Inject 在发往上游的请求中附加 traceparent，仅在配置了 propagate 时生效
+func Inject(ctx context.Context, h http.Header) {
+	t := current()
+	s := SpanFromContext(ctx)
+	if t == nil || s == nil || !t.propagate {
+		return
+	}
+	h.Set("traceparent", fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID))
+}
+
+// TraceID 返回链路 ID，未采样时为空
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SetAttr 设置属性，value 支持 string、bool、整数和浮点数，其他类型按 %v 转为字符串
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{key, value})
+	s.mu.Unlock()
+}
+
+// AddEvent 记录一个时间点，如收到第一个数据包
+func (s *Span) AddEvent(name string, kv ...any) {
+	if s == nil {
+		return
+	}
+	e := event{time: time.Now(), name: name}
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.attrs = append(e.attrs, attribute{fmt.Sprint(kv[i]), kv[i+1]})
+	}
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	s.mu.Unlock()
+}
+
+// SetError 标记失败并记录错误信息，err 为 nil 时忽略
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status, s.statusMsg = statusError, err.Error()
+	s.mu.Unlock()
+}
+
+// SetHTTPStatus 记录 HTTP 状态码，server span 5xx、client span 4xx 及以上标记为失败
+func (s *Span) SetHTTPStatus(code int) {
+	if s == nil {
+		return
+	}
+	s.SetAttr("http.response.status_code", code)
+	if code >= 500 || (s.kind == KindClient && code >= 400) {
+		s.mu.Lock()
+		if s.status != statusError {
+			s.status, s.statusMsg = statusError, http.StatusText(code)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// End 结束并提交导出，重复调用只有第一次生效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	if t := current(); t != nil {
+		t.enqueue(s)
+	}
+}
+
+// sample 按采样率决定是否记录新的链路
+func (t *tracer) sample() bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11)/float64(1<<53) < t.ratio
+}
@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// UpdateInfo 最近一次检查新版本的结果
+type UpdateInfo struct {
+	Current   string    `json:"current"`
+	Latest    string    `json:"latest"`
+	Available bool      `json:"available"` // 是否有比当前更新的版本
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	latestMu   sync.RWMutex
+	latestInfo UpdateInfo
+)
+
+// CheckLatest 检查最新的正式版本并记录结果，首次发现某个新版本时写日志
+func CheckLatest(cfg config.GithubConfig) UpdateInfo {
+	info := UpdateInfo{Current: config.Version, CheckedAt: time.Now()}
+	latest, err := LatestRelease(cfg)
+	if err != nil {
+		info.Error = err.Error()
+	} else {
+		info.Latest = latest
+		info.Available = CompareVersions(latest, config.Version) > 0
+	}
+
+	latestMu.Lock()
+	notified := latestInfo.Latest == info.Latest
+	latestInfo = info
+	latestMu.Unlock()
+
+	if info.Available && !notified {
+		logger.LogPrintf("🆕 发现新版本 %s（当前 %s），可在管理界面或执行 upgrade 命令升级", info.Latest, info.Current)
+	}
+	return info
+}
+
+// LatestInfo 返回最近一次检查的结果，尚未检查时 CheckedAt 为零值
+func LatestInfo() UpdateInfo {
+	latestMu.RLock()
+	defer latestMu.RUnlock()
+	info := latestInfo
+	info.Current = config.Version
+	return info
+}
+
+// StartAutoCheck 启用 github.auto_check 时按 check_interval 定期检查新版本，
+// 启动一分钟后进行首次检查；未启用时每分钟重新读取配置
+func StartAutoCheck(stop <-chan struct{}) {
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		config.CfgMu.RLock()
+		cfg := config.Cfg.Github
+		config.CfgMu.RUnlock()
+
+		next := time.Minute
+		if cfg.AutoCheck {
+			if info := CheckLatest(cfg); info.Error != "" {
+				logger.LogPrintf("⚠️ 检查新版本失败: %s", info.Error)
+			}
+			if cfg.CheckInterval > 0 {
+				next = cfg.CheckInterval
+			}
+		}
+		timer.Reset(next)
+	}
+}
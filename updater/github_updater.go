@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,7 +42,9 @@ func GetStatus() map[string]string {
 
 // --- Release 信息 ---
 type Release struct {
-	TagName string `json:"tag_name"`
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
 }
 
 func buildURL(base, target string) string {
@@ -170,35 +173,36 @@ func getDownloadURLs(cfg config.GithubConfig, version, zipFileName string) []str
 }
 
 // --------------------
-// 下载 + 解压 + 平滑升级
+// 下载 + 校验 + 解压 + 平滑升级
 // --------------------
 func UpdateFromGithub(cfg config.GithubConfig, version string) error {
 	SetStatus("starting", "开始升级流程")
 
-	arch, err := GetArchInfo()
+	newExecPath, tmpDestDir, err := PrepareBinary(cfg, version, false)
 	if err != nil {
-		SetStatus("error", fmt.Sprintf("获取系统架构信息失败: %v", err))
+		SetStatus("error", err.Error())
 		return err
 	}
 
-	zipFileName := fmt.Sprintf("TVGate-%s-%s.zip", arch.GOOS, arch.PackageArch)
-	urls := getDownloadURLs(cfg, version, zipFileName)
-	tmpFile := filepath.Join(os.TempDir(), zipFileName)
-
-	SetStatus("downloading", "开始下载")
-	success := false
-	var lastErr error
-	for _, u := range urls {
-		if err := downloadFile(u, tmpFile); err != nil {
-			lastErr = err
-			continue
+	// 已启动热更新监听时原子替换程序文件，再由监听以新程序平滑重启
+	if upgrade.Listening() {
+		execPath, err := ExecutablePath()
+		if err == nil {
+			SetStatus("backing_up", "备份并替换当前程序")
+			err = Install(newExecPath, execPath)
 		}
-		success = true
-		break
-	}
-	if !success {
-		SetStatus("error", fmt.Sprintf("所有下载源失败: %v", lastErr))
-		return lastErr
+		_ = os.RemoveAll(tmpDestDir)
+		if err != nil {
+			SetStatus("error", fmt.Sprintf("替换程序失败: %v", err))
+			return err
+		}
+		SetStatus("restarting", "重启新版本")
+		if err := upgrade.Trigger(); err != nil {
+			SetStatus("error", fmt.Sprintf("触发平滑重启失败: %v", err))
+			return err
+		}
+		SetStatus("success", "升级成功，正在重启")
+		return nil
 	}
 
 	SetStatus("backing_up", "备份当前程序")
@@ -207,20 +211,6 @@ func UpdateFromGithub(cfg config.GithubConfig, version string) error {
 	_ = copyFile(execPath, backupPath)
 	_ = os.Chmod(backupPath, 0755)
 
-	SetStatus("unzipping", "解压新版本")
-	tmpDestDir := filepath.Join(filepath.Dir(execPath), ".tmp_upgrade")
-	_ = os.RemoveAll(tmpDestDir)
-	_ = os.MkdirAll(tmpDestDir, 0755)
-
-	if err := unzip(tmpFile, tmpDestDir); err != nil {
-		return err
-	}
-	newapp := fmt.Sprintf("TVGate-%s-%s", arch.GOOS, arch.PackageArch)
-	newExecPath := filepath.Join(tmpDestDir, filepath.Base(newapp))
-	if runtime.GOOS != "windows" {
-		_ = os.Chmod(newExecPath, 0755)
-	}
-
 	SetStatus("restarting", "重启新版本")
 	// ⚡ 使用 tableflip 启动新进程，旧进程由 tableflip 接管
 	// 在退出前更新状态为成功
@@ -242,6 +232,9 @@ func downloadFile(url, dst string) error {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载 %s 返回错误状态码 %d", url, resp.StatusCode)
+	}
 
 	out, err := os.Create(dst)
 	if err != nil {
@@ -254,11 +247,17 @@ func downloadFile(url, dst string) error {
 }
 
 func copyFile(src, dst string) error {
-	in, _ := os.Open(src)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
 	defer in.Close()
-	out, _ := os.Create(dst)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
 	defer out.Close()
-	_, err := io.Copy(out, in)
+	_, err = io.Copy(out, in)
 	return err
 }
 
@@ -271,6 +270,10 @@ func unzip(src, dest string) error {
 
 	for _, f := range r.File {
 		path := filepath.Join(dest, f.Name)
+		// 拒绝解压到目标目录之外的条目
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("压缩包中的路径无效: %s", f.Name)
+		}
 		if f.FileInfo().IsDir() {
 			_ = os.MkdirAll(path, f.Mode())
 			continue
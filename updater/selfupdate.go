@@ -0,0 +1,303 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// LatestRelease 返回最新的正式版本号，跳过草稿与预发布版本
+func LatestRelease(cfg config.GithubConfig) (string, error) {
+	releases, err := FetchGithubReleases(cfg)
+	if err != nil {
+		return "", err
+	}
+	latest := ""
+	for _, r := range releases {
+		if r.Draft || r.Prerelease || r.TagName == "" {
+			continue
+		}
+		if latest == "" || CompareVersions(r.TagName, latest) > 0 {
+			latest = r.TagName
+		}
+	}
+	if latest == "" {
+		return "", errors.New("没有可用的正式版本")
+	}
+	return latest, nil
+}
+
+// CompareVersions 比较 v2.1.6 形式的版本号，a 较新返回 1，相同返回 0，较旧返回 -1；
+// 缺少的段按 0 处理，非数字的段按字符串比较
+func CompareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(strings.TrimSpace(a), "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(strings.TrimSpace(b), "v"), ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		x, y := "0", "0"
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		if errX == nil && errY == nil {
+			if nx != ny {
+				if nx > ny {
+					return 1
+				}
+				return -1
+			}
+			continue
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// packageName 发布包与其中程序文件的名称（不含扩展名），与 release 工作流一致
+func packageName(arch *ArchInfo) string {
+	return fmt.Sprintf("TVGate-%s-%s", arch.GOOS, arch.PackageArch)
+}
+
+// ReleasePublicKey 校验发布包签名的 Ed25519 公钥（base64），构建时通过
+// -ldflags "-X github.com/qist/tvgate/updater.ReleasePublicKey=..." 写入程序，与 release 工作流使用的私钥对应
+var ReleasePublicKey string
+
+// releaseKey 解析内置的发布包签名公钥
+func releaseKey() (ed25519.PublicKey, error) {
+	if ReleasePublicKey == "" {
+		return nil, errors.New("程序构建时没有写入发布包签名公钥，无法校验，确认来源可信后可跳过校验")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ReleasePublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("内置的发布包签名公钥格式错误")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// fetchSignature 下载发布包的 .sig 文件（base64 编码的 Ed25519 签名），依次尝试各下载源；
+// 签名由内置公钥校验，下载源本身不需要可信
+func fetchSignature(urls []string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	lastErr := errors.New("没有可用的下载源")
+	for _, u := range urls {
+		resp, err := client.Get(u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("下载签名文件返回错误状态码 %d", resp.StatusCode)
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			lastErr = fmt.Errorf("%s 不是有效的 Ed25519 签名", u)
+			continue
+		}
+		return sig, nil
+	}
+	return nil, lastErr
+}
+
+// verifyFile 用 key 校验文件内容的 Ed25519 签名
+func verifyFile(key ed25519.PublicKey, path string, sig []byte) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return errors.New("签名不匹配")
+	}
+	return nil
+}
+
+// PrepareBinary 下载版本对应平台的发布包，用内置公钥校验签名后解压到程序所在目录下的临时目录，
+// 返回新程序路径与临时目录；skipVerify 为 true 时跳过校验（发布包没有 .sig 文件或程序没有内置公钥时使用）
+func PrepareBinary(cfg config.GithubConfig, version string, skipVerify bool) (newExecPath, tmpDir string, err error) {
+	arch, err := GetArchInfo()
+	if err != nil {
+		return "", "", fmt.Errorf("获取系统架构信息失败: %w", err)
+	}
+	execPath, err := ExecutablePath()
+	if err != nil {
+		return "", "", err
+	}
+
+	zipFileName := packageName(arch) + ".zip"
+	var key ed25519.PublicKey
+	var sig []byte
+	if !skipVerify {
+		if key, err = releaseKey(); err != nil {
+			return "", "", err
+		}
+		SetStatus("downloading", "下载签名文件")
+		if sig, err = fetchSignature(getDownloadURLs(cfg, version, zipFileName+".sig")); err != nil {
+			return "", "", fmt.Errorf("获取签名失败: %w", err)
+		}
+	}
+
+	SetStatus("downloading", "开始下载")
+	f, err := os.CreateTemp("", "tvgate-upgrade-*.zip")
+	if err != nil {
+		return "", "", err
+	}
+	tmpFile := f.Name()
+	f.Close()
+	defer os.Remove(tmpFile)
+	var lastErr error
+	ok := false
+	for _, u := range getDownloadURLs(cfg, version, zipFileName) {
+		if err := downloadFile(u, tmpFile); err != nil {
+			lastErr = err
+			continue
+		}
+		if sig != nil {
+			SetStatus("verifying", "校验发布包签名")
+			if err := verifyFile(key, tmpFile, sig); err != nil {
+				lastErr = fmt.Errorf("%s 校验失败: %w", u, err)
+				continue
+			}
+		}
+		ok = true
+		break
+	}
+	if !ok {
+		return "", "", fmt.Errorf("所有下载源失败: %w", lastErr)
+	}
+
+	SetStatus("unzipping", "解压新版本")
+	tmpDir = filepath.Join(filepath.Dir(execPath), ".tmp_upgrade")
+	_ = os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", "", err
+	}
+	if err := unzip(tmpFile, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", fmt.Errorf("解压失败: %w", err)
+	}
+	newExecPath = filepath.Join(tmpDir, packageName(arch))
+	if runtime.GOOS == "windows" {
+		newExecPath += ".exe"
+	}
+	if _, err := os.Stat(newExecPath); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", "", fmt.Errorf("发布包中没有程序文件 %s", filepath.Base(newExecPath))
+	}
+	_ = os.Chmod(newExecPath, 0755)
+	return newExecPath, tmpDir, nil
+}
+
+// ExecutablePath 当前程序文件的实际路径（已解析符号链接）
+func ExecutablePath() (string, error) {
+	p, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(p)
+}
+
+// Install 以新程序替换 target，旧程序保留为 .bak。先复制到同目录的临时文件再改名覆盖，
+// 替换过程中 target 始终是完整的文件；Windows 不能覆盖运行中的程序，先把旧程序改名为 .bak
+func Install(newExecPath, target string) error {
+	mode := os.FileMode(0755)
+	if fi, err := os.Stat(target); err == nil {
+		mode = fi.Mode().Perm() | 0111
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".new-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	src, err := os.Open(newExecPath)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	_, err = io.Copy(tmp, src)
+	src.Close()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	backup := target + ".bak"
+	_ = os.Remove(backup)
+	if runtime.GOOS == "windows" {
+		if err := os.Rename(target, backup); err != nil {
+			return fmt.Errorf("备份当前程序失败: %w", err)
+		}
+		if err := os.Rename(tmpPath, target); err != nil {
+			_ = os.Rename(backup, target)
+			return err
+		}
+		return nil
+	}
+	// 硬链接作为备份，不需要复制文件
+	if err := os.Link(target, backup); err != nil {
+		if err := copyFile(target, backup); err != nil {
+			return fmt.Errorf("备份当前程序失败: %w", err)
+		}
+		_ = os.Chmod(backup, mode)
+	}
+	return os.Rename(tmpPath, target)
+}
+
+// RunningProcesses 返回正在运行同一程序文件的其他进程
+func RunningProcesses(execPath string) []int {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, p := range procs {
+		if int(p.Pid) == os.Getpid() {
+			continue
+		}
+		exe, err := p.Exe()
+		if err != nil {
+			continue
+		}
+		// 程序文件被替换后 Linux 在路径后加 " (deleted)"
+		exe = strings.TrimSuffix(exe, " (deleted)")
+		if real, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = real
+		}
+		if exe == execPath {
+			pids = append(pids, int(p.Pid))
+		}
+	}
+	return pids
+}
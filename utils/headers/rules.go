@@ -0,0 +1,176 @@
+package headers
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+)
+
+// Vars 头部模板变量，在规则值中以 ${name} 引用
+type Vars struct {
+	ClientIP string // ${client_ip}
+	Token    string // ${token}
+	Channel  string // ${channel}，请求路径最后一段（去掉扩展名）
+	Host     string // ${host}，客户端请求的 Host
+	Path     string // ${path}，客户端请求路径
+}
+
+// NewVars 根据客户端请求生成模板变量
+func NewVars(r *http.Request, clientIP, token string) Vars {
+	return Vars{
+		ClientIP: clientIP,
+		Token:    token,
+		Channel:  channelName(r.URL.Path),
+		Host:     r.Host,
+		Path:     r.URL.Path,
+	}
+}
+
+// channelName 取路径最后一段作为频道名，如 /live/cctv1.m3u8 -> cctv1
+func channelName(p string) string {
+	base := path.Base(strings.TrimRight(p, "/"))
+	if base == "." || base == "/" {
+		return ""
+	}
+	if ext := path.Ext(base); ext != "" && !strings.Contains(ext, ":") {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return base
+}
+
+func (v Vars) expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return strings.NewReplacer(
+		"${client_ip}", v.ClientIP,
+		"${token}", v.Token,
+		"${channel}", v.Channel,
+		"${host}", v.Host,
+		"${path}", v.Path,
+	).Replace(s)
+}
+
+// GlobalRules 返回全局头部改写规则
+func GlobalRules() []config.HeaderRule {
+	config.CfgMu.RLock()
+	defer config.CfgMu.RUnlock()
+	return config.Cfg.HeaderRules
+}
+
+// matches 规则路径前缀为空时匹配全部请求
+func matches(rule config.HeaderRule, reqPath string) bool {
+	return rule.Path == "" || strings.HasPrefix(reqPath, rule.Path)
+}
+
+// apply 按 删除 -> 设置 -> 追加 的顺序执行
+func apply(ops config.HeaderOperations, h http.Header, vars Vars) {
+	for _, k := range ops.Remove {
+		h.Del(k)
+	}
+	for k, v := range ops.Set {
+		h.Set(k, vars.expand(v))
+	}
+	for k, v := range ops.Append {
+		h.Add(k, vars.expand(v))
+	}
+}
+
+// hasOps 判断规则列表中是否有需要执行的请求/响应操作
+func hasOps(rules []config.HeaderRule, response bool) bool {
+	for _, rule := range rules {
+		ops := rule.Request
+		if response {
+			ops = rule.Response
+		}
+		if len(ops.Remove) > 0 || len(ops.Set) > 0 || len(ops.Append) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyRequest 对发往上游的请求头执行规则，返回改写后的副本，原 Header 不变
+func ApplyRequest(rules []config.HeaderRule, reqPath string, h http.Header, vars Vars) http.Header {
+	if !hasOps(rules, false) {
+		return h
+	}
+	out := h.Clone()
+	if out == nil {
+		out = make(http.Header)
+	}
+	for _, rule := range rules {
+		if matches(rule, reqPath) {
+			apply(rule.Request, out, vars)
+		}
+	}
+	return out
+}
+
+// WrapResponse 返回在写出响应头前执行响应规则的 ResponseWriter；无响应规则时原样返回
+func WrapResponse(w http.ResponseWriter, rules []config.HeaderRule, reqPath string, vars Vars) http.ResponseWriter {
+	if !hasOps(rules, true) {
+		return w
+	}
+	var matched []config.HeaderOperations
+	for _, rule := range rules {
+		if matches(rule, reqPath) {
+			matched = append(matched, rule.Response)
+		}
+	}
+	if len(matched) == 0 {
+		return w
+	}
+	return &ruleWriter{ResponseWriter: w, ops: matched, vars: vars}
+}
+
+// ruleWriter 在首次写出响应头时改写上游返回的响应头
+type ruleWriter struct {
+	http.ResponseWriter
+	ops     []config.HeaderOperations
+	vars    Vars
+	applied bool
+}
+
+func (rw *ruleWriter) applyOnce() {
+	if rw.applied {
+		return
+	}
+	rw.applied = true
+	for _, ops := range rw.ops {
+		apply(ops, rw.ResponseWriter.Header(), rw.vars)
+	}
+}
+
+func (rw *ruleWriter) WriteHeader(code int) {
+	rw.applyOnce()
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *ruleWriter) Write(b []byte) (int, error) {
+	rw.applyOnce()
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *ruleWriter) Flush() {
+	rw.applyOnce()
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *ruleWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (rw *ruleWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -18,8 +18,15 @@ const (
 )
 
 func NewHTTPClient(c *config.Config, transport *http.Transport) *http.Client {
+	return NewHTTPClientWithProtocol(c, transport, ProtoAuto)
+}
+
+// NewHTTPClientWithProtocol 创建指定默认上游协议的 client，http.protocols 中的域名规则优先。
+// 传入 transport 时视为经由代理，HTTP/3 会降级为 HTTP/2
+func NewHTTPClientWithProtocol(c *config.Config, transport *http.Transport, proto string) *http.Client {
 	// 获取自定义 Resolver 实例
 	resolver := dns.GetInstance()
+	allowH3 := transport == nil
 	if transport == nil {
 		// 基础 dialer
 		baseDialer := &net.Dialer{
@@ -64,7 +71,8 @@ func NewHTTPClient(c *config.Config, transport *http.Transport) *http.Client {
 
 	return &http.Client{
 		Timeout:   c.HTTP.Timeout,
-		Transport: transport,
+		Transport: newProfileTransport(newProtocolTransport(transport, proto, c.HTTP.Protocols, allowH3), c.HTTP.Profiles),
+		Jar:       cookieJarFor(c.HTTP.CookieJar),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			redirectCount := len(via)
 			if redirectCount >= maxRedirects {
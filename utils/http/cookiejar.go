@@ -0,0 +1,288 @@
+package http
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+// cookieSaveDelay Cookie 变化后延迟写入文件，合并短时间内的多次变化
+const cookieSaveDelay = 5 * time.Second
+
+// storedCookie 保存的 Cookie，Expires 为 Unix 秒，0 表示会话 Cookie（同样保存，重启后继续使用）
+type storedCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	HostOnly bool   `json:"host_only,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	Expires  int64  `json:"expires,omitempty"`
+}
+
+func (c *storedCookie) expired(now time.Time) bool {
+	return c.Expires > 0 && c.Expires <= now.Unix()
+}
+
+// matchHost Cookie 的域名是否适用于 host
+func (c *storedCookie) matchHost(host string) bool {
+	if c.HostOnly {
+		return host == c.Domain
+	}
+	return host == c.Domain || strings.HasSuffix(host, "."+c.Domain)
+}
+
+// matchPath 按 RFC 6265 5.1.4 判断 Cookie 的路径是否适用于请求路径
+func (c *storedCookie) matchPath(path string) bool {
+	if path == c.Path {
+		return true
+	}
+	if !strings.HasPrefix(path, c.Path) {
+		return false
+	}
+	return strings.HasSuffix(c.Path, "/") || path[len(c.Path)] == '/'
+}
+
+// persistentJar 按域名保存上游设置的 Cookie 并写入文件，所有 HTTP 客户端共用，配置重载后保留已有的 Cookie
+type persistentJar struct {
+	mu      sync.Mutex
+	file    string
+	domains []string
+	cookies map[string]*storedCookie // domain;path;name -> cookie
+	timer   *time.Timer
+}
+
+var upstreamCookies = &persistentJar{cookies: make(map[string]*storedCookie)}
+
+// cookieJarFor 按配置更新共用的 Cookie 保存并返回，未启用时返回 nil
+func cookieJarFor(cfg config.CookieJarConfig) http.CookieJar {
+	if !cfg.Enabled {
+		return nil
+	}
+	upstreamCookies.configure(cfg)
+	return upstreamCookies
+}
+
+// SaveCookies 立即写入上游 Cookie，用于退出前保存
+func SaveCookies() {
+	upstreamCookies.save()
+}
+
+// cookieFile 保存文件，相对路径相对于配置文件所在目录
+func cookieFile(file string) string {
+	dir := "."
+	if config.ConfigFilePath != nil && *config.ConfigFilePath != "" {
+		dir = filepath.Dir(*config.ConfigFilePath)
+	}
+	if file == "" {
+		file = "upstream_cookies.json"
+	}
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+func (j *persistentJar) configure(cfg config.CookieJarConfig) {
+	file := cookieFile(cfg.File)
+	domains := make([]string, 0, len(cfg.Domains))
+	for _, d := range cfg.Domains {
+		domains = append(domains, strings.ToLower(strings.TrimPrefix(d, "*.")))
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.domains = domains
+	if file == j.file {
+		return
+	}
+	j.file = file
+	j.cookies = make(map[string]*storedCookie)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogPrintf("⚠️ 读取上游 Cookie 失败: %v", err)
+		}
+		return
+	}
+	var saved []*storedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.LogPrintf("⚠️ 解析上游 Cookie 失败: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, c := range saved {
+		if !c.expired(now) {
+			j.cookies[c.Domain+";"+c.Path+";"+c.Name] = c
+		}
+	}
+	logger.LogPrintf("✅ 已恢复 %d 个上游 Cookie", len(j.cookies))
+}
+
+// allowed 是否保存该上游域名的 Cookie
+func (j *persistentJar) allowed(host string) bool {
+	if len(j.domains) == 0 {
+		return true
+	}
+	for _, d := range j.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCookies 实现 http.CookieJar，保存上游响应中的 Set-Cookie
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	if host == "" || len(cookies) == 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.allowed(host) {
+		return
+	}
+	now := time.Now()
+	changed := false
+	for _, hc := range cookies {
+		c := &storedCookie{Name: hc.Name, Value: hc.Value, Path: hc.Path, Secure: hc.Secure}
+		if domain := strings.ToLower(strings.TrimPrefix(hc.Domain, ".")); domain == "" {
+			c.Domain, c.HostOnly = host, true
+		} else if domain == host || net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain) && strings.Contains(domain, ".") {
+			c.Domain = domain
+		} else {
+			// 不能为其它域名设置 Cookie
+			continue
+		}
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case hc.MaxAge < 0:
+			c.Expires = now.Unix()
+		case hc.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second).Unix()
+		case !hc.Expires.IsZero():
+			c.Expires = hc.Expires.Unix()
+		}
+
+		key := c.Domain + ";" + c.Path + ";" + c.Name
+		if c.expired(now) {
+			if _, ok := j.cookies[key]; ok {
+				delete(j.cookies, key)
+				changed = true
+			}
+			continue
+		}
+		if old, ok := j.cookies[key]; !ok || *old != *c {
+			j.cookies[key] = c
+			changed = true
+		}
+	}
+	if changed && j.timer == nil {
+		j.timer = time.AfterFunc(cookieSaveDelay, j.save)
+	}
+}
+
+// Cookies 实现 http.CookieJar，返回发往上游时要带的 Cookie，路径更长的在前
+func (j *persistentJar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https"
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.allowed(host) {
+		return nil
+	}
+	now := time.Now()
+	var matched []*storedCookie
+	for _, c := range j.cookies {
+		if c.expired(now) || c.Secure && !secure || !c.matchHost(host) || !c.matchPath(path) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Name < matched[b].Name
+	})
+	out := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		out[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return out
+}
+
+// save 写入文件（先写临时文件再重命名），不保存已过期的 Cookie
+func (j *persistentJar) save() {
+	j.mu.Lock()
+	j.timer = nil
+	file := j.file
+	now := time.Now()
+	saved := make([]*storedCookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		cp := *c
+		saved = append(saved, &cp)
+	}
+	j.mu.Unlock()
+	if file == "" {
+		return
+	}
+
+	sort.Slice(saved, func(a, b int) bool {
+		x, y := saved[a], saved[b]
+		if x.Domain != y.Domain {
+			return x.Domain < y.Domain
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Name < y.Name
+	})
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		logger.LogPrintf("❌ 保存上游 Cookie 失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		logger.LogPrintf("❌ 保存上游 Cookie 失败: %v", err)
+	}
+}
+
+// defaultCookiePath 按 RFC 6265 5.1.4 计算没有指定 Path 时的默认路径
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/qist/tvgate/config"
+)
+
+// Presets 预设的播放器请求头，按名称（小写）查找
+var Presets = map[string]map[string]string{
+	"aptv": {
+		"User-Agent": "AptvPlayer/1.3.8",
+	},
+	"tivimate": {
+		"User-Agent": "TiviMate/5.1.6 (Android 11)",
+	},
+	"kodi": {
+		"User-Agent": "Kodi/21.0 (Linux; Android 11.0; Build/RQ3A.211001.001) Android/11.0.0 Sys_CPU/armv8l App_Bitness/64 Version/21.0-(21.0.0)-Git:20240407-5a3d4a6d5c",
+	},
+	"vlc": {
+		"User-Agent": "VLC/3.0.20 LibVLC/3.0.20",
+	},
+	"okhttp": {
+		"User-Agent": "okhttp/4.12.0",
+	},
+	"exoplayer": {
+		"User-Agent": "ExoPlayerLib/2.19.1",
+	},
+	"chrome": {
+		"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Accept":          "*/*",
+		"Accept-Language": "zh-CN,zh;q=0.9,en;q=0.8",
+	},
+	"safari": {
+		"User-Agent":      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+		"Accept":          "*/*",
+		"Accept-Language": "zh-CN,zh-Hans;q=0.9",
+	},
+}
+
+// upstreamProfile 整理后的请求头模板
+type upstreamProfile struct {
+	domains []string
+	paths   []string
+	set     map[string]string
+	cookies string
+}
+
+// matches 域名为空或匹配（含子域名），且路径前缀为空或匹配
+func (p *upstreamProfile) matches(host, path string) bool {
+	if len(p.domains) > 0 {
+		ok := false
+		for _, d := range p.domains {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(p.paths) == 0 {
+		return true
+	}
+	for _, prefix := range p.paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// profileTransport 按上游的域名与路径设置请求头
+type profileTransport struct {
+	next     http.RoundTripper
+	profiles []*upstreamProfile
+}
+
+// newProfileTransport 没有配置请求头模板时直接返回 next
+func newProfileTransport(next http.RoundTripper, profiles []config.UpstreamProfile) http.RoundTripper {
+	if len(profiles) == 0 {
+		return next
+	}
+	t := &profileTransport{next: next}
+	for _, cfg := range profiles {
+		p := &upstreamProfile{paths: cfg.Paths, set: make(map[string]string), cookies: cfg.Cookies}
+		for _, d := range cfg.Domains {
+			p.domains = append(p.domains, strings.ToLower(strings.TrimPrefix(d, "*.")))
+		}
+		for k, v := range Presets[strings.ToLower(cfg.Preset)] {
+			p.set[k] = v
+		}
+		for k, v := range map[string]string{"User-Agent": cfg.UserAgent, "Referer": cfg.Referer, "Origin": cfg.Origin} {
+			if v != "" {
+				p.set[k] = v
+			}
+		}
+		for k, v := range cfg.Headers {
+			p.set[http.CanonicalHeaderKey(k)] = v
+		}
+		t.profiles = append(t.profiles, p)
+	}
+	return t
+}
+
+func (t *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, p := range t.profiles {
+		if !p.matches(host, req.URL.Path) {
+			continue
+		}
+		// RoundTripper 不能修改传入的请求
+		req = req.Clone(req.Context())
+		for k, v := range p.set {
+			req.Header.Set(k, v)
+		}
+		if p.cookies != "" {
+			if c := req.Header.Get("Cookie"); c != "" {
+				req.Header.Set("Cookie", c+"; "+p.cookies)
+			} else {
+				req.Header.Set("Cookie", p.cookies)
+			}
+		}
+		break
+	}
+	return t.next.RoundTrip(req)
+}
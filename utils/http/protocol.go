@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/qist/tvgate/dns"
+	"github.com/qist/tvgate/logger"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// 上游协议
+const (
+	ProtoAuto = ""    // 默认：HTTPS 按 ALPN 协商，HTTP 使用 HTTP/1.1
+	ProtoH1   = "h1"  // 仅 HTTP/1.1
+	ProtoH2   = "h2"  // 优先 HTTP/2 (TLS)
+	ProtoH2C  = "h2c" // 明文 HTTP/2（prior knowledge），HTTPS 仍使用 h2
+	ProtoH3   = "h3"  // HTTP/3 (QUIC)，失败时回退 TCP
+)
+
+// NormalizeProtocol 规范化协议名称，未知值返回 ProtoAuto
+func NormalizeProtocol(proto string) string {
+	switch p := strings.ToLower(strings.TrimSpace(proto)); p {
+	case ProtoH1, "http1", "http/1.1":
+		return ProtoH1
+	case ProtoH2, "http2":
+		return ProtoH2
+	case ProtoH2C:
+		return ProtoH2C
+	case ProtoH3, "http3", "quic":
+		return ProtoH3
+	default:
+		return ProtoAuto
+	}
+}
+
+// protocolTransport 按目标域名规则或默认协议，将请求分派到对应的传输层
+type protocolTransport struct {
+	base         *http.Transport
+	defaultProto string
+	domains      map[string]string
+	allowH3      bool
+
+	mu       sync.Mutex
+	variants map[string]http.RoundTripper
+}
+
+func newProtocolTransport(base *http.Transport, defaultProto string, domains map[string]string, allowH3 bool) http.RoundTripper {
+	defaultProto = NormalizeProtocol(defaultProto)
+	if defaultProto == ProtoH3 && !allowH3 {
+		logger.LogPrintf("⚠️ 经由代理的连接不支持 HTTP/3，改用 HTTP/2")
+		defaultProto = ProtoH2
+	}
+	if defaultProto == ProtoAuto && len(domains) == 0 {
+		return base
+	}
+	rules := make(map[string]string, len(domains))
+	for domain, proto := range domains {
+		rules[strings.ToLower(strings.TrimPrefix(domain, "*."))] = NormalizeProtocol(proto)
+	}
+	return &protocolTransport{
+		base:         base,
+		defaultProto: defaultProto,
+		domains:      rules,
+		allowH3:      allowH3,
+		variants:     make(map[string]http.RoundTripper),
+	}
+}
+
+// protocolFor 域名规则优先（精确匹配或子域名匹配），否则使用默认协议
+func (t *protocolTransport) protocolFor(host string) string {
+	host = strings.ToLower(host)
+	for {
+		if proto, ok := t.domains[host]; ok {
+			if proto == ProtoH3 && !t.allowH3 {
+				return ProtoH2
+			}
+			return proto
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return t.defaultProto
+}
+
+func (t *protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proto := t.protocolFor(req.URL.Hostname())
+	rt := t.variant(proto)
+	if proto != ProtoH3 || req.URL.Scheme != "https" {
+		return rt.RoundTrip(req)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		return resp, nil
+	}
+	// UDP 被阻断等情况回退到 TCP（仅限可重放的请求）
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, err
+	}
+	logger.LogPrintf("⚠️ HTTP/3 请求 %s 失败，回退 TCP: %v", req.URL.Host, err)
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.variant(ProtoH2).RoundTrip(retry)
+}
+
+func (t *protocolTransport) variant(proto string) http.RoundTripper {
+	if proto == ProtoAuto {
+		return t.base
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rt, ok := t.variants[proto]; ok {
+		return rt
+	}
+	var rt http.RoundTripper
+	if proto == ProtoH3 {
+		rt = newHTTP3Transport(t.base)
+	} else {
+		tr := t.base.Clone()
+		if tr.TLSClientConfig != nil {
+			// ALPN 由 Protocols 决定
+			tr.TLSClientConfig.NextProtos = nil
+		}
+		tr.Protocols = new(http.Protocols)
+		switch proto {
+		case ProtoH1:
+			tr.Protocols.SetHTTP1(true)
+		case ProtoH2:
+			tr.Protocols.SetHTTP1(true)
+			tr.Protocols.SetHTTP2(true)
+		case ProtoH2C:
+			tr.Protocols.SetHTTP2(true)
+			tr.Protocols.SetUnencryptedHTTP2(true)
+		}
+		rt = tr
+	}
+	t.variants[proto] = rt
+	return rt
+}
+
+// newHTTP3Transport 创建 HTTP/3 传输，域名解析使用自定义 Resolver
+func newHTTP3Transport(base *http.Transport) http.RoundTripper {
+	var tlsConf *tls.Config
+	if base.TLSClientConfig != nil {
+		tlsConf = base.TLSClientConfig.Clone()
+	}
+	resolver := dns.GetInstance()
+	return &http3.Transport{
+		TLSClientConfig:    tlsConf,
+		DisableCompression: base.DisableCompression,
+		QUICConfig: &quic.Config{
+			HandshakeIdleTimeout: base.TLSHandshakeTimeout,
+			MaxIdleTimeout:       base.IdleConnTimeout,
+		},
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if ips, err := resolver.LookupIPAddr(ctx, host); err == nil && len(ips) > 0 {
+				addr = net.JoinHostPort(ips[0].IP.String(), port)
+			}
+			return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+		},
+	}
+}
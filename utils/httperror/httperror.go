@@ -0,0 +1,137 @@
+// Package httperror 统一的错误响应：请求 JSON 的客户端返回 {"error": "说明", "status": 状态码}，
+// 浏览器返回 HTML 错误页（可用自定义模板），播放器等其它客户端与 http.Error 相同返回纯文本
+package httperror
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/i18n"
+	"github.com/qist/tvgate/logger"
+)
+
+// Data 错误页模板可用的数据
+type Data struct {
+	Status     int    // 状态码
+	StatusText string // 状态码的标准说明，如 Not Found
+	Message    string // 错误说明
+	Path       string // 请求路径
+}
+
+// builtin 没有配置模板时的错误页
+var builtin = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.StatusText}}</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",sans-serif;background:#f5f6f8;color:#333;margin:0}
+main{max-width:560px;margin:15vh auto;padding:32px;background:#fff;border-radius:8px;box-shadow:0 2px 12px rgba(0,0,0,.08)}
+h1{margin:0 0 12px;font-size:48px;color:#d9534f}
+p{margin:8px 0;word-break:break-all}
+.path{color:#999;font-size:13px}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Status}}</h1>
+<p>{{.Message}}</p>
+<p class="path">{{.Path}}</p>
+</main>
+</body>
+</html>
+`))
+
+var (
+	mu       sync.RWMutex
+	fallback *template.Template
+	pages    map[int]*template.Template
+)
+
+// Configure 加载自定义错误页模板，加载失败的模板记录日志后使用内置页面
+func Configure(cfg config.ErrorPagesConfig) {
+	var tmpl *template.Template
+	if cfg.Template != "" {
+		tmpl = load(cfg.Template)
+	}
+	byStatus := make(map[int]*template.Template, len(cfg.Pages))
+	for code, file := range cfg.Pages {
+		if t := load(file); t != nil {
+			byStatus[code] = t
+		}
+	}
+	mu.Lock()
+	fallback, pages = tmpl, byStatus
+	mu.Unlock()
+}
+
+func load(file string) *template.Template {
+	t, err := template.ParseFiles(file)
+	if err != nil {
+		logger.LogPrintf("❌ 加载错误页模板 %s 失败，使用内置页面: %v", file, err)
+		return nil
+	}
+	return t
+}
+
+// page 返回状态码对应的模板
+func page(code int) *template.Template {
+	mu.RLock()
+	defer mu.RUnlock()
+	if t, ok := pages[code]; ok {
+		return t
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return builtin
+}
+
+// wantsJSON 客户端是否请求 JSON：Accept 包含 application/json 或页面脚本发起的请求
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		r.Header.Get("X-Requested-With") == "XMLHttpRequest"
+}
+
+// wantsHTML 客户端是否为浏览器直接访问
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// Error 按客户端写出错误响应，用法与 http.Error 相同，r 为 nil 时返回纯文本；
+// 响应头设置了 Content-Language 时翻译 msg
+func Error(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	h := w.Header()
+	if lang := h.Get("Content-Language"); lang != "" {
+		msg = i18n.T(lang, msg)
+	}
+	switch {
+	case r != nil && wantsJSON(r):
+		h.Del("Content-Length")
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		h.Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{"error": msg, "status": code})
+	case r != nil && wantsHTML(r):
+		data := Data{Status: code, StatusText: http.StatusText(code), Message: msg, Path: r.URL.Path}
+		var buf bytes.Buffer
+		if err := page(code).Execute(&buf, data); err != nil {
+			logger.LogPrintf("⚠️ 错误页模板执行失败，使用内置页面: %v", err)
+			buf.Reset()
+			builtin.Execute(&buf, data)
+		}
+		h.Del("Content-Length")
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		h.Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		w.Write(buf.Bytes())
+	default:
+		http.Error(w, msg, code)
+	}
+}
@@ -0,0 +1,96 @@
+package ipacl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// List 已解析的 IP/网段列表
+type List []*net.IPNet
+
+// Parse 解析 IP 或 CIDR 列表，单个 IP 视为 /32（IPv6 为 /128）
+func Parse(entries []string) (List, error) {
+	list := make(List, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("无效的网段: %s", entry)
+			}
+			list = append(list, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的IP: %s", entry)
+		}
+		bits := 128
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 32
+		}
+		list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return list, nil
+}
+
+// Contains 判断 IP 是否在列表中
+func (l List) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsString 同 Contains，ip 为字符串形式
+func (l List) ContainsString(ip string) bool {
+	return l.Contains(net.ParseIP(ip))
+}
+
+// ACL 允许/拒绝列表：deny 优先，allow 非空时仅允许列表中的地址
+type ACL struct {
+	Allow List
+	Deny  List
+}
+
+// NewACL 解析允许/拒绝列表
+func NewACL(allow, deny []string) (ACL, error) {
+	a, err := Parse(allow)
+	if err != nil {
+		return ACL{}, err
+	}
+	d, err := Parse(deny)
+	if err != nil {
+		return ACL{}, err
+	}
+	return ACL{Allow: a, Deny: d}, nil
+}
+
+// Empty 未配置任何规则
+func (a ACL) Empty() bool {
+	return len(a.Allow) == 0 && len(a.Deny) == 0
+}
+
+// Allowed 判断 IP 是否允许访问，无法解析的 IP 仅在未配置规则时放行
+func (a ACL) Allowed(ip string) bool {
+	if a.Empty() {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if a.Deny.Contains(parsed) {
+		return false
+	}
+	return len(a.Allow) == 0 || a.Allow.Contains(parsed)
+}
@@ -0,0 +1,80 @@
+package m3u
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/qist/tvgate/config"
+)
+
+// ApplyChannel 按配置中的频道信息补全分组、频道号、节目单 ID 与台标，配置中有的值优先
+func ApplyChannel(e *Entry, ch *config.ChannelsConfig) {
+	info := ch.Lookup(e.Name)
+	if info == nil {
+		return
+	}
+	if e.Attrs == nil {
+		e.Attrs = make(map[string]string)
+	}
+	if info.Group != "" {
+		e.Group = info.Group
+		e.Attrs["group-title"] = info.Group
+	}
+	if info.Number > 0 {
+		e.Attrs["tvg-chno"] = strconv.Itoa(info.Number)
+	}
+	if info.EPGID != "" {
+		e.Attrs["tvg-id"] = info.EPGID
+	}
+	if info.Logo != "" {
+		e.Attrs["tvg-logo"] = info.Logo
+	}
+}
+
+// Order 补全频道信息后返回排列顺序（entries 的下标）：分组按 groups 的顺序，未列出的分组按出现顺序排在后面，
+// 同一分组内有频道号的按频道号在前，其余保持原顺序。没有配置频道信息时保持原顺序
+func Order(entries []Entry, ch *config.ChannelsConfig) []int {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	if len(ch.Groups) == 0 && len(ch.List) == 0 {
+		return order
+	}
+
+	ranks := make(map[string]int)
+	numbers := make([]int, len(entries))
+	for i := range entries {
+		ApplyChannel(&entries[i], ch)
+		g := entries[i].Group
+		if _, ok := ranks[g]; !ok {
+			ranks[g] = ch.GroupRank(g)
+			if ranks[g] == len(ch.Groups) {
+				ranks[g] += len(ranks)
+			}
+		}
+		numbers[i], _ = strconv.Atoi(entries[i].Attrs["tvg-chno"])
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := order[a], order[b]
+		if rx, ry := ranks[entries[x].Group], ranks[entries[y].Group]; rx != ry {
+			return rx < ry
+		}
+		nx, ny := numbers[x], numbers[y]
+		if (nx > 0) != (ny > 0) {
+			return nx > 0
+		}
+		return nx > 0 && nx < ny
+	})
+	return order
+}
+
+// Arrange 按频道信息补全并排列频道，见 Order
+func Arrange(entries []Entry, ch *config.ChannelsConfig) []Entry {
+	order := Order(entries, ch)
+	out := make([]Entry, len(order))
+	for i, j := range order {
+		out[i] = entries[j]
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+// Package m3u 读取 m3u 与 "频道名,地址" 格式的 txt 播放列表，以及把上游地址改写为经 TVGate 代理访问的路径
+package m3u
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry 播放列表中的一个频道
+type Entry struct {
+	Name  string            // 频道名（#EXTINF 逗号后的部分或 txt 中逗号前的部分）
+	URL   string            // 播放地址
+	Group string            // m3u 的 group-title 或 txt 的 #genre# 分组
+	Attrs map[string]string // #EXTINF 中的属性，键为小写，如 tvg-id、tvg-name、tvg-logo
+}
+
+// extinfAttr #EXTINF 行中的 key="value" 属性
+var extinfAttr = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+
+// Parse 解析 m3u 或 txt 播放列表，忽略没有地址的条目
+func Parse(r io.Reader) ([]Entry, error) {
+	var (
+		entries []Entry
+		pending *Entry // m3u 中等待地址行的 #EXTINF
+		group   string // txt 的当前分组
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	first := true
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF"):
+			e := Entry{Attrs: make(map[string]string)}
+			for _, m := range extinfAttr.FindAllStringSubmatch(line, -1) {
+				e.Attrs[strings.ToLower(m[1])] = m[2]
+			}
+			if i := strings.LastIndex(line, ","); i >= 0 {
+				e.Name = strings.TrimSpace(line[i+1:])
+			}
+			e.Group = e.Attrs["group-title"]
+			pending = &e
+		case strings.HasPrefix(line, "#"):
+		case pending != nil:
+			pending.URL = line
+			entries = append(entries, *pending)
+			pending = nil
+		default:
+			name, addr, ok := strings.Cut(line, ",")
+			if !ok {
+				continue
+			}
+			name, addr = strings.TrimSpace(name), strings.TrimSpace(addr)
+			if addr == "#genre#" {
+				group = name
+				continue
+			}
+			if strings.Contains(addr, "://") {
+				entries = append(entries, Entry{Name: name, URL: addr, Group: group, Attrs: map[string]string{}})
+			}
+		}
+	}
+	return entries, sc.Err()
+}
+
+// Load 读取并解析 http(s) 地址或本地文件
+func Load(src string, timeout time.Duration) ([]Entry, error) {
+	rc, err := Open(src, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return Parse(rc)
+}
+
+// Open 打开 http(s) 地址或本地文件
+func Open(src string, timeout time.Duration) (io.ReadCloser, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.Open(src)
+	}
+	return Get(http.DefaultClient, src, timeout)
+}
+
+// Get 使用指定的 client 请求 http(s) 地址，非 200 状态码返回错误
+func Get(client *http.Client, src string, timeout time.Duration) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; TVGate)")
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("返回错误状态码 %d", resp.StatusCode)
+	}
+	return &cancelBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// cancelBody 关闭响应体时释放超时 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// ProxyPath 把上游地址改写为经 TVGate 代理访问的路径（与 playlist export 的改写规则一致）：
+// rtp://239.0.0.1:2000 -> /rtp/239.0.0.1:2000，udp:// 同理；rtsp://host/path -> /rtsp/host/path；
+// http://host/path -> /host/path；https://host/path -> /https://host/path；其他协议保持不变
+func ProxyPath(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return raw
+	}
+	rest := raw[len(u.Scheme)+len("://"):]
+	switch strings.ToLower(u.Scheme) {
+	case "rtp", "udp":
+		// 组播地址可能写作 rtp://@239.0.0.1:2000
+		return "/" + strings.ToLower(u.Scheme) + "/" + strings.TrimPrefix(rest, "@")
+	case "rtsp":
+		return "/rtsp/" + rest
+	case "http":
+		return "/" + rest
+	case "https":
+		return "/https://" + rest
+	}
+	return raw
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶限速器
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶，rate 为每秒令牌数，burst 为桶容量（<=0 时等于 rate）
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = rate
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   time.Now(),
+	}
+}
+
+// SetRate 动态调整速率和容量
+func (tb *TokenBucket) SetRate(rate float64, burst int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.refill(time.Now())
+	tb.rate = rate
+	tb.burst = float64(burst)
+	if tb.burst <= 0 {
+		tb.burst = rate
+	}
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
+
+// refill 按时间补充令牌，调用方需持有锁
+func (tb *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(tb.last).Seconds()
+	if elapsed > 0 {
+		tb.tokens += elapsed * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.last = now
+	}
+}
+
+// Allow 尝试立即取走 n 个令牌
+func (tb *TokenBucket) Allow(n int) bool {
+	if tb.rate <= 0 {
+		return true
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.refill(time.Now())
+	if tb.tokens >= float64(n) {
+		tb.tokens -= float64(n)
+		return true
+	}
+	return false
+}
+
+// reserve 取走 n 个令牌（允许透支），返回需要等待的时间
+func (tb *TokenBucket) reserve(n int) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.refill(time.Now())
+	tb.tokens -= float64(n)
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// Wait 阻塞直到可以取走 n 个令牌或 ctx 结束
+func (tb *TokenBucket) Wait(ctx context.Context, n int) error {
+	if tb.rate <= 0 {
+		return nil
+	}
+	wait := tb.reserve(n)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Limiter 按 key（如客户端 IP）维护独立令牌桶
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	bucket   *TokenBucket
+	lastSeen time.Time
+}
+
+// NewLimiter 创建按 key 限速的限速器
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*limiterEntry),
+	}
+}
+
+// Get 获取 key 对应的令牌桶，不存在时创建
+func (l *Limiter) Get(key string) *TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	e, ok := l.buckets[key]
+	if !ok {
+		e = &limiterEntry{bucket: NewTokenBucket(l.rate, l.burst)}
+		l.buckets[key] = e
+		// 数量较多时顺带清理长时间未使用的桶
+		if len(l.buckets) > 1024 {
+			for k, v := range l.buckets {
+				if now.Sub(v.lastSeen) > 10*time.Minute {
+					delete(l.buckets, k)
+				}
+			}
+		}
+	}
+	e.lastSeen = now
+	return e.bucket
+}
+
+// Allow 判断 key 是否允许通过一次请求
+func (l *Limiter) Allow(key string) bool {
+	return l.Get(key).Allow(1)
+}
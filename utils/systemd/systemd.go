@@ -0,0 +1,184 @@
+// Package systemd 与 systemd 集成：sd_notify 状态通知、看门狗与 socket 激活（LISTEN_FDS），
+// 未由 systemd 启动时全部为空操作
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+)
+
+// Notify 向 NOTIFY_SOCKET 发送状态（sd_notify），未设置 NOTIFY_SOCKET 时返回 false
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// 以 @ 开头为抽象命名空间
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ready 通知启动完成；热更新后由新进程发送，同时告知新的主进程 PID（需要 NotifyAccess=all）
+func Ready() {
+	if ok, err := Notify("READY=1\nMAINPID=" + strconv.Itoa(os.Getpid())); err != nil {
+		logger.LogPrintf("⚠️ systemd 通知失败: %v", err)
+	} else if ok {
+		logger.LogPrintf("✅ 已通知 systemd 启动完成")
+	}
+}
+
+// Stopping 通知正在退出
+func Stopping() {
+	Notify("STOPPING=1")
+}
+
+// Status 更新 systemctl status 中显示的状态文字
+func Status(text string) {
+	Notify("STATUS=" + text)
+}
+
+// WatchdogInterval 返回 WatchdogSec 配置的超时时间，未启用看门狗时返回 0。
+// 不校验 WATCHDOG_PID：热更新后的新进程继承了旧进程的环境变量，并通过 MAINPID 接替为主进程
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog 按超时时间的一半发送心跳，healthy 返回 false 时跳过本次心跳，
+// 进程卡住超过 WatchdogSec 后由 systemd 重启；未启用看门狗时直接返回
+func StartWatchdog(healthy func() bool, stop <-chan struct{}) {
+	timeout := WatchdogInterval()
+	if timeout == 0 {
+		return
+	}
+	logger.LogPrintf("🐶 已启用 systemd 看门狗，超时 %s", timeout)
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if healthy != nil && !healthy() {
+				logger.LogPrintf("⚠️ 自检未通过，跳过 systemd 看门狗心跳")
+				continue
+			}
+			Notify("WATCHDOG=1")
+		}
+	}
+}
+
+// activated socket 激活传入的监听；systemd 始终持有该 socket，端口无法重新绑定，
+// 因此保留文件，每次使用时复制一份，服务因配置变更重启后仍可继续使用
+type activated struct {
+	name string
+	file *os.File
+	addr net.Addr
+}
+
+var (
+	activationOnce sync.Once
+	activationMu   sync.Mutex
+	activations    []*activated
+)
+
+// listenFdsStart systemd 传入的第一个文件描述符
+const listenFdsStart = 3
+
+// loadActivated 读取 LISTEN_FDS 传入的监听，读取后清除相关环境变量，避免子进程重复使用
+func loadActivated() {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n; i++ {
+		f := os.NewFile(uintptr(listenFdsStart+i), "LISTEN_FD_"+strconv.Itoa(listenFdsStart+i))
+		ln, err := net.FileListener(f)
+		if err != nil {
+			f.Close()
+			logger.LogPrintf("⚠️ systemd 传入的第 %d 个 socket 不是监听 socket，已忽略: %v", i+1, err)
+			continue
+		}
+		a := &activated{file: f, addr: ln.Addr()}
+		ln.Close()
+		if i < len(names) {
+			a.name = names[i]
+		}
+		activations = append(activations, a)
+	}
+}
+
+// Listener 返回 socket 激活传入的、与监听地址对应的监听，没有时返回 nil。
+// 按以下顺序匹配：FileDescriptorName 与地址或 name 相同；Unix Socket 路径相同；
+// TCP 端口相同且地址相同或配置的地址未指定主机（如 :8888）
+func Listener(addr, name string) net.Listener {
+	activationOnce.Do(loadActivated)
+	activationMu.Lock()
+	defer activationMu.Unlock()
+
+	match := func(ok func(a *activated) bool) net.Listener {
+		for _, a := range activations {
+			if !ok(a) {
+				continue
+			}
+			ln, err := net.FileListener(a.file)
+			if err != nil {
+				logger.LogPrintf("⚠️ 使用 systemd 传入的 socket %s 失败: %v", a.addr, err)
+				return nil
+			}
+			logger.LogPrintf("📦 使用 systemd 传入的 socket %s 监听 %s", a.addr, addr)
+			return ln
+		}
+		return nil
+	}
+
+	if ln := match(func(a *activated) bool {
+		return a.name != "" && (a.name == addr || (name != "" && a.name == name))
+	}); ln != nil {
+		return ln
+	}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return match(func(a *activated) bool {
+			return a.addr.Network() == "unix" && a.addr.String() == path
+		})
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	return match(func(a *activated) bool {
+		tcp, ok := a.addr.(*net.TCPAddr)
+		if !ok || strconv.Itoa(tcp.Port) != port {
+			return false
+		}
+		return host == "" || net.ParseIP(host).Equal(tcp.IP)
+	})
+}
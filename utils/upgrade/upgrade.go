@@ -1,6 +1,7 @@
 package upgrade
 
 import (
+	"errors"
 	"io"
 	"runtime"
 
@@ -13,6 +14,7 @@ import (
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 )
 
@@ -20,6 +22,7 @@ var (
 	upgrader  *tableflip.Upgrader
 	once      sync.Once
 	isWindows = runtime.GOOS == "windows"
+	listening atomic.Bool
 )
 
 // var (
@@ -52,13 +55,21 @@ func Init() {
 	})
 }
 
+// Attach 使用主程序已创建的升级器，一个进程只能创建一个 tableflip 升级器
+func Attach(u *tableflip.Upgrader) {
+	once.Do(func() {
+		upgrader = u
+	})
+}
+
 // StartListener 监听 SIGHUP 信号执行热更新
 func StartListener(onUpgrade func()) {
 	Init()
-	if isWindows {
+	if isWindows || upgrader == nil {
 		// logger.LogPrintf("Windows 平台不支持 SIGHUP 热升级监听，跳过 tableflip")
 		return
 	}
+	listening.Store(true)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGHUP)
 
@@ -85,6 +96,23 @@ func StartListener(onUpgrade func()) {
 	}()
 }
 
+// Listening 是否已启动 SIGHUP 热更新监听
+func Listening() bool {
+	return listening.Load()
+}
+
+// Trigger 向当前进程发送 SIGHUP，由升级监听以新的程序文件平滑重启
+func Trigger() error {
+	if !Listening() {
+		return errors.New("未启动热更新监听")
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGHUP)
+}
+
 // StopUpgradeListener 停止升级监听
 func StopUpgradeListener() {
 	signal.Stop(make(chan os.Signal, 1))
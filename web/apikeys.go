@@ -0,0 +1,100 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/ipacl"
+)
+
+// apiKey 解析后的接口密钥
+type apiKey struct {
+	name   string
+	secret string
+	role   string
+	allow  ipacl.List // 为空不限制来源 IP
+}
+
+// apiKeyContextKey 请求上下文中保存接口密钥名称的键
+type apiKeyContextKey struct{}
+
+// newAPIKeys 解析 web.api_keys；web.api_token 作为名为 api_token 的管理员密钥保留
+func newAPIKeys(cfg WebConfig) []apiKey {
+	keys := make([]apiKey, 0, len(cfg.APIKeys)+1)
+	if cfg.APIToken != "" {
+		keys = append(keys, apiKey{name: "api_token", secret: cfg.APIToken, role: RoleAdmin})
+	}
+	for i, k := range cfg.APIKeys {
+		if k.Key == "" {
+			continue
+		}
+		name := k.Name
+		if name == "" {
+			name = "api_keys[" + strconv.Itoa(i) + "]"
+		}
+		allow, err := ipacl.Parse(k.Allow)
+		if err != nil {
+			// 来源限制写错时禁用该密钥，避免放开访问
+			logger.LogPrintf("❌ 接口密钥 %s 的 allow 配置错误，已禁用: %v", name, err)
+			continue
+		}
+		keys = append(keys, apiKey{name: name, secret: k.Key, role: normalizeRole(k.Role), allow: allow})
+	}
+	return keys
+}
+
+// requestAPIKey 读取请求携带的接口密钥，X-API-Key 优先
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return bearer
+}
+
+// matchAPIKey 查找密钥，逐个比较以免泄露匹配位置
+func (h *ConfigHandler) matchAPIKey(secret string) (apiKey, bool) {
+	var found apiKey
+	ok := false
+	for _, k := range h.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(k.secret)) == 1 && !ok {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+// allowed 来源 IP 是否可以使用该密钥
+func (k apiKey) allowed(ip string) bool {
+	return len(k.allow) == 0 || k.allow.ContainsString(ip)
+}
+
+// apiKeyName 返回请求使用的接口密钥名称，通过 Cookie 登录时为空
+func apiKeyName(r *http.Request) string {
+	name, _ := r.Context().Value(apiKeyContextKey{}).(string)
+	return name
+}
+
+// apiSource 配置版本历史中记录的来源，使用接口密钥时附带密钥名称
+func apiSource(r *http.Request) string {
+	if name := apiKeyName(r); name != "" {
+		return "api:" + name
+	}
+	return "api"
+}
+
+// apiRole 返回经 apiAuth 认证的请求的角色：接口密钥的角色，或 Cookie 登录用户的角色
+func (h *ConfigHandler) apiRole(r *http.Request) string {
+	if name := apiKeyName(r); name != "" {
+		for _, k := range h.apiKeys {
+			if k.name == name {
+				return k.role
+			}
+		}
+		return ""
+	}
+	return h.requestRole(r)
+}
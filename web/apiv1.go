@@ -0,0 +1,265 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/bruteforce"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/quota"
+)
+
+// 列表接口的分页参数
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// page 分页的列表响应
+type page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// writePage 按 offset、limit 参数分页写出列表，limit 默认 100，最大 1000
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	q := r.URL.Query()
+	offset, limit := 0, defaultPageLimit
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "offset 格式错误: "+v)
+			return
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit 格式错误: "+v)
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	start := min(offset, len(items))
+	end := min(start+limit, len(items))
+	result := page[T]{Items: items[start:end], Total: len(items), Offset: offset, Limit: limit}
+	if result.Items == nil {
+		result.Items = []T{}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// registerAPIv1 注册 /api/v1 接口：供自动化调用，使用接口密钥或登录 Cookie 认证，
+// 错误统一为 {"error": "..."}，列表统一分页
+func (h *ConfigHandler) registerAPIv1(mux *http.ServeMux, webPath string) {
+	v1 := webPath + "api/v1/"
+
+	// 运行状态与频道
+	mux.HandleFunc(v1+"status", h.apiAuth(RoleViewer, h.handleAPIStatus))
+	mux.HandleFunc(v1+"channels", h.apiAuth(RoleViewer, h.handleAPIChannels))
+	mux.HandleFunc(v1+"stats/series", h.apiAuth(RoleViewer, h.handleStatsSeries))
+	mux.HandleFunc(v1+"stats/query", h.apiAuth(RoleViewer, h.handleStatsQuery))
+
+	// 在线客户端与封禁
+	mux.HandleFunc(v1+"clients", h.apiAuth(RoleAdmin, h.handleAPIClients))
+	mux.HandleFunc(v1+"clients/kick", h.apiAuth(RoleAdmin, h.handleKickClient))
+	mux.HandleFunc(v1+"clients/streams", h.apiAuth(RoleAdmin, h.handleAPIClientStreams))
+	mux.HandleFunc(v1+"bans", h.apiAuth(RoleAdmin, h.handleAPIBans))
+	mux.HandleFunc(v1+"bans/unban", h.apiAuth(RoleAdmin, h.handleUnban))
+
+	// 组播 Hub：查看、关闭、预先启动与重新加入
+	mux.HandleFunc(v1+"hubs", h.apiAuth(RoleOperator, h.handleHubs))
+	mux.HandleFunc(v1+"hubs/close", h.apiAuth(RoleAdmin, h.handleHubClose))
+	mux.HandleFunc(v1+"hubs/start", h.apiAuth(RoleOperator, h.handleHubStart))
+	mux.HandleFunc(v1+"hubs/interfaces", h.apiAuth(RoleOperator, h.handleHubInterfaces))
+
+	// token 签发、吊销与流量配额
+	mux.HandleFunc(v1+"tokens", h.apiAuth(RoleAdmin, h.handleAPITokens))
+	mux.HandleFunc(v1+"tokens/extend", h.apiAuth(RoleAdmin, h.handleTokenExtend))
+	mux.HandleFunc(v1+"tokens/revoke", h.apiAuth(RoleAdmin, h.handleTokenRevoke))
+	mux.HandleFunc(v1+"tokens/export", h.apiAuth(RoleAdmin, h.handleTokenExport))
+	mux.HandleFunc(v1+"tokens/import", h.apiAuth(RoleAdmin, h.handleTokenImport))
+	mux.HandleFunc(v1+"quota", h.apiAuth(RoleOperator, h.handleAPIQuota))
+	mux.HandleFunc(v1+"quota/reset", h.apiAuth(RoleAdmin, h.handleQuotaReset))
+
+	// 运行时日志级别
+	mux.HandleFunc(v1+"log/level", h.apiAuth(RoleAdmin, h.handleLogLevel))
+
+	// 告警
+	mux.HandleFunc(v1+"alerts", h.apiAuth(RoleViewer, h.handleAlerts))
+	mux.HandleFunc(v1+"alerts/test", h.apiAuth(RoleAdmin, h.handleAlertTest))
+
+	// 节目单
+	mux.HandleFunc(v1+"epg", h.apiAuth(RoleViewer, h.handleEPG))
+	mux.HandleFunc(v1+"epg/refresh", h.apiAuth(RoleOperator, h.handleEPGRefresh))
+	mux.HandleFunc(v1+"epg/programmes", h.apiAuth(RoleViewer, h.handleEPGProgrammes))
+
+	// 定时录制
+	mux.HandleFunc(v1+"dvr/timers", h.apiAuth(RoleOperator, h.handleDVRTimers))
+	mux.HandleFunc(v1+"dvr/timers/update", h.apiAuth(RoleOperator, h.handleDVRTimerUpdate))
+	mux.HandleFunc(v1+"dvr/timers/delete", h.apiAuth(RoleOperator, h.handleDVRTimerDelete))
+	mux.HandleFunc(v1+"dvr/recordings", h.apiAuth(RoleOperator, h.handleDVRRecordings))
+	mux.HandleFunc(v1+"dvr/recordings/stop", h.apiAuth(RoleOperator, h.handleDVRRecordingStop))
+	mux.HandleFunc(v1+"dvr/recordings/delete", h.apiAuth(RoleAdmin, h.handleDVRRecordingDelete))
+
+	// 投屏
+	mux.HandleFunc(v1+"cast/devices", h.apiAuth(RoleOperator, h.handleCastDevices))
+	mux.HandleFunc(v1+"cast/sessions", h.apiAuth(RoleOperator, h.handleCastSessions))
+	mux.HandleFunc(v1+"cast/stop", h.apiAuth(RoleOperator, h.handleCastStop))
+	mux.HandleFunc(v1+"cast/volume", h.apiAuth(RoleOperator, h.handleCastVolume))
+
+	// 配置读写与版本历史
+	mux.HandleFunc(v1+"config", h.apiAuth(RoleAdmin, h.handleConfigAPI))
+	mux.HandleFunc(v1+"config/validate", h.apiAuth(RoleOperator, h.handleConfigAPIValidate))
+	mux.HandleFunc(v1+"config/apply", h.apiAuth(RoleAdmin, h.handleConfigAPIApply))
+	mux.HandleFunc(v1+"config/history", h.apiAuth(RoleAdmin, h.handleConfigHistory))
+	mux.HandleFunc(v1+"config/history/get", h.apiAuth(RoleAdmin, h.handleConfigHistoryGet))
+	mux.HandleFunc(v1+"config/history/diff", h.apiAuth(RoleAdmin, h.handleConfigHistoryDiff))
+	mux.HandleFunc(v1+"config/history/rollback", h.apiAuth(RoleAdmin, h.handleConfigHistoryRollback))
+	// 配置 JSON Schema 只描述结构、不含配置值，无需认证，编辑器可直接引用
+	mux.HandleFunc(v1+"config/schema", h.handleConfigSchema)
+	mux.HandleFunc(v1+"backup/export", h.apiAuth(RoleAdmin, h.handleBundleExport))
+	mux.HandleFunc(v1+"backup/import", h.apiAuth(RoleAdmin, h.handleBundleImport))
+
+	// 未知接口返回 JSON 格式的 404
+	mux.HandleFunc(v1, h.apiAuth(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, "接口不存在: "+r.URL.Path)
+	}))
+}
+
+// handleAPIStatus 运行状态概要：版本、运行时长、系统资源与流量、客户端与频道数
+func (h *ConfigHandler) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeJSON(w, http.StatusOK, monitor.Summary())
+}
+
+// apiChannel 频道信息
+type apiChannel struct {
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Clients     int                `json:"clients"`
+	Packets     uint64             `json:"packets"`
+	Bytes       uint64             `json:"bytes"`
+	Drops       uint64             `json:"drops"`
+	Rejoins     uint64             `json:"rejoins"` // 组播源看门狗重新加入组播组的次数
+	BufferBytes uint64             `json:"buffer_bytes"`
+	BusyTime    float64            `json:"busy_time"`        // 累计处理与分发耗时（秒），包含等待慢客户端的时间
+	BusyPercent float64            `json:"busy_percent"`     // 距上次调用以来处理与分发耗时的占比
+	Media       *monitor.MediaInfo `json:"media,omitempty"`  // 探测到的音视频参数，需启用 probe
+	Title       string             `json:"title,omitempty"`  // channels 配置中的频道名
+	Group       string             `json:"group,omitempty"`  // channels 配置中的分组
+	Number      int                `json:"number,omitempty"` // channels 配置中的频道号
+}
+
+// handleAPIChannels 列出正在播放的频道，按客户端数从多到少排序；参数 type（如 UDP、RTSP）、q 名称关键字、
+// group 分组（channels 配置）
+func (h *ConfigHandler) handleAPIChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	typ, keyword, group := r.URL.Query().Get("type"), r.URL.Query().Get("q"), r.URL.Query().Get("group")
+	config.CfgMu.RLock()
+	channels := config.Cfg.Channels
+	config.CfgMu.RUnlock()
+	list := make([]apiChannel, 0)
+	for _, hub := range monitor.GetTopHubs("api", 0) {
+		var info config.ChannelInfo
+		if p := channels.ByAddr(hub.Name); p != nil {
+			info = *p
+		}
+		if typ != "" && !strings.EqualFold(hub.Type, typ) {
+			continue
+		}
+		if keyword != "" && !strings.Contains(hub.Name, keyword) && !strings.Contains(info.Name, keyword) {
+			continue
+		}
+		if group != "" && info.Group != group {
+			continue
+		}
+		list = append(list, apiChannel{
+			Name:        hub.Name,
+			Type:        hub.Type,
+			Clients:     hub.Clients,
+			Packets:     hub.Packets,
+			Bytes:       hub.Bytes,
+			Drops:       hub.Drops,
+			Rejoins:     hub.Rejoins,
+			BufferBytes: hub.BufferBytes,
+			BusyTime:    hub.BusyTime.Round(time.Millisecond).Seconds(),
+			BusyPercent: hub.BusyPercent,
+			Media:       hub.Media,
+			Title:       info.Name,
+			Group:       info.Group,
+			Number:      info.Number,
+		})
+	}
+	writePage(w, r, list)
+}
+
+// handleAPIClients 分页列出当前连接的客户端
+func (h *ConfigHandler) handleAPIClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writePage(w, r, monitor.ActiveClients.List())
+}
+
+// handleAPIClientStreams 分页列出组播/RTP Hub 客户端的发送统计，丢包率高的排在前面，可按 hub 过滤
+func (h *ConfigHandler) handleAPIClientStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	list := monitor.GetHubClients()
+	if hub := r.URL.Query().Get("hub"); hub != "" {
+		filtered := list[:0]
+		for _, c := range list {
+			if c.Hub == hub {
+				filtered = append(filtered, c)
+			}
+		}
+		list = filtered
+	}
+	writePage(w, r, list)
+}
+
+// handleAPIBans 分页列出被封禁的 IP
+func (h *ConfigHandler) handleAPIBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writePage(w, r, bruteforce.Default.Bans())
+}
+
+// handleAPITokens GET 分页列出签发的 token，POST 签发新 token
+func (h *ConfigHandler) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.handleTokens(w, r)
+		return
+	}
+	if tm := tokenManagerForAPI(w); tm != nil {
+		writePage(w, r, tm.IssuedTokens())
+	}
+}
+
+// handleAPIQuota 查询 token 流量配额：指定 token 时返回单个，否则分页列出全部
+func (h *ConfigHandler) handleAPIQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Query().Get("token") != "" {
+		h.handleQuota(w, r)
+		return
+	}
+	writePage(w, r, quota.Default.Statuses())
+}
@@ -10,6 +10,7 @@ import (
 	"sort"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 // ConfigBackupHandler 处理配置备份管理
@@ -32,7 +33,7 @@ func (h *ConfigBackupHandler) handleListBackups(w http.ResponseWriter, r *http.R
 
 	files, err := filepath.Glob(filepath.Join(dir, "*.backup.*"))
 	if err != nil {
-		http.Error(w, "获取备份列表失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "获取备份列表失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -65,7 +66,7 @@ func (h *ConfigBackupHandler) handleListBackups(w http.ResponseWriter, r *http.R
 func (h *ConfigBackupHandler) handleDeleteBackup(w http.ResponseWriter, r *http.Request) {
 	file := r.URL.Query().Get("file")
 	if file == "" {
-		http.Error(w, "参数 file 必须提供", http.StatusBadRequest)
+		httperror.Error(w, r, "参数 file 必须提供", http.StatusBadRequest)
 		return
 	}
 
@@ -75,12 +76,12 @@ func (h *ConfigBackupHandler) handleDeleteBackup(w http.ResponseWriter, r *http.
 
 	// 确保文件在配置目录下
 	if !filepath.HasPrefix(absFile, dir) {
-		http.Error(w, "不允许删除目录外的文件", http.StatusForbidden)
+		httperror.Error(w, r, "不允许删除目录外的文件", http.StatusForbidden)
 		return
 	}
 
 	if err := os.Remove(absFile); err != nil {
-		http.Error(w, "删除备份失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "删除备份失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -92,7 +93,7 @@ func (h *ConfigBackupHandler) handleDeleteBackup(w http.ResponseWriter, r *http.
 func (h *ConfigBackupHandler) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
 	file := r.URL.Query().Get("file")
 	if file == "" {
-		http.Error(w, "参数 file 必须提供", http.StatusBadRequest)
+		httperror.Error(w, r, "参数 file 必须提供", http.StatusBadRequest)
 		return
 	}
 
@@ -101,13 +102,13 @@ func (h *ConfigBackupHandler) handleRestoreBackup(w http.ResponseWriter, r *http
 	absFile, _ := filepath.Abs(file)
 
 	if !filepath.HasPrefix(absFile, dir) {
-		http.Error(w, "不允许还原目录外的文件", http.StatusForbidden)
+		httperror.Error(w, r, "不允许还原目录外的文件", http.StatusForbidden)
 		return
 	}
 
 	data, err := os.ReadFile(absFile)
 	if err != nil {
-		http.Error(w, "读取备份失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取备份失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -118,7 +119,7 @@ func (h *ConfigBackupHandler) handleRestoreBackup(w http.ResponseWriter, r *http
 
 	// 写入备份内容到当前配置
 	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		http.Error(w, "还原失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "还原失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -130,7 +131,7 @@ func (h *ConfigBackupHandler) handleRestoreBackup(w http.ResponseWriter, r *http
 func (h *ConfigBackupHandler) handleDownloadBackup(w http.ResponseWriter, r *http.Request) {
 	file := r.URL.Query().Get("file")
 	if file == "" {
-		http.Error(w, "参数 file 必须提供", http.StatusBadRequest)
+		httperror.Error(w, r, "参数 file 必须提供", http.StatusBadRequest)
 		return
 	}
 
@@ -140,13 +141,13 @@ func (h *ConfigBackupHandler) handleDownloadBackup(w http.ResponseWriter, r *htt
 
 	// 确保文件在配置目录下
 	if !filepath.HasPrefix(absFile, dir) {
-		http.Error(w, "不允许下载目录外的文件", http.StatusForbidden)
+		httperror.Error(w, r, "不允许下载目录外的文件", http.StatusForbidden)
 		return
 	}
 
 	// 检查文件是否存在
 	if _, err := os.Stat(absFile); os.IsNotExist(err) {
-		http.Error(w, "文件不存在", http.StatusNotFound)
+		httperror.Error(w, r, "文件不存在", http.StatusNotFound)
 		return
 	}
 
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,7 +25,7 @@ func (h *ConfigHandler) handleServerEditor(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.renderTemplate(w, r, "server_editor", "templates/server_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -66,7 +68,7 @@ func (h *ConfigHandler) handleServerConfig(w http.ResponseWriter, r *http.Reques
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(serverConfig); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -75,14 +77,14 @@ func (h *ConfigHandler) handleServerConfig(w http.ResponseWriter, r *http.Reques
 func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -90,7 +92,7 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 	// 解析JSON数据
 	var serverConfig map[string]interface{}
 	if err := json.Unmarshal(body, &serverConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -98,14 +100,14 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -365,6 +367,13 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 										&yaml.Node{Kind: yaml.ScalarNode, Value: "true"})
 								}
 							}
+
+							// 编辑器不管理自动证书，保留原有配置
+							if acmeNode := serverTLSChild(doc.Content[i+1], "acme"); acmeNode != nil {
+								tlsNode.Content = append(tlsNode.Content,
+									&yaml.Node{Kind: yaml.ScalarNode, Value: "acme"},
+									acmeNode)
+							}
 							
 							if len(tlsNode.Content) > 0 {
 								newServerNode.Content = append(newServerNode.Content,
@@ -374,6 +383,15 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 						}
 					}
 
+					// 编辑器不管理额外监听地址，保留原有配置
+					if old := doc.Content[i+1]; old.Kind == yaml.MappingNode {
+						for j := 0; j+1 < len(old.Content); j += 2 {
+							if old.Content[j].Value == "listeners" {
+								newServerNode.Content = append(newServerNode.Content, old.Content[j], old.Content[j+1])
+							}
+						}
+					}
+
 					// 替换server节点
 					doc.Content[i+1] = newServerNode
 					serverFound = true
@@ -391,16 +409,16 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 	}
 
 	// 序列化为YAML格式
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -408,7 +426,7 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		// 恢复备份文件
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -417,3 +435,22 @@ func (h *ConfigHandler) handleServerConfigSave(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("配置保存成功"))
 }
+
+// serverTLSChild 返回原 server 节点中 tls 下指定键的值节点，不存在时返回 nil
+func serverTLSChild(serverNode *yaml.Node, key string) *yaml.Node {
+	if serverNode == nil || serverNode.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(serverNode.Content); i += 2 {
+		if serverNode.Content[i].Value != "tls" || serverNode.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		tls := serverNode.Content[i+1]
+		for j := 0; j+1 < len(tls.Content); j += 2 {
+			if tls.Content[j].Value == key {
+				return tls.Content[j+1]
+			}
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -21,8 +22,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/qist/tvgate/bruteforce"
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/i18n"
+	"github.com/qist/tvgate/config/load"
 	"github.com/qist/tvgate/monitor"
+	"github.com/qist/tvgate/utils/httperror"
 	"github.com/shirou/gopsutil/v3/mem"
 	"gopkg.in/yaml.v3"
 )
@@ -35,10 +40,13 @@ var staticFS embed.FS
 
 // WebConfig web管理界面配置
 type WebConfig struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Enabled  bool   `yaml:"enabled"`
-	Path     string `yaml:"path"` // Web管理界面的访问路径
+	Username string           `yaml:"username"`
+	Password string           `yaml:"password"`
+	Enabled  bool             `yaml:"enabled"`
+	Path     string           `yaml:"path"`      // Web管理界面的访问路径
+	APIToken string           `yaml:"api_token"` // 接口访问令牌
+	Users    []config.WebUser `yaml:"users"`     // 多账号
+	APIKeys  []config.APIKey  `yaml:"api_keys"`  // 接口密钥
 }
 
 // WebHandler web管理界面处理器
@@ -79,6 +87,7 @@ func formatBytes(bytes uint64) string {
 // ConfigHandler 配置文件管理处理器
 type ConfigHandler struct {
 	webConfig    WebConfig
+	apiKeys      []apiKey
 	currentTheme string
 	themeMutex   sync.RWMutex
 }
@@ -92,6 +101,7 @@ func (h *ConfigHandler) init() {
 func NewConfigHandler(webConfig WebConfig) *ConfigHandler {
 	handler := &ConfigHandler{
 		webConfig: webConfig,
+		apiKeys:   newAPIKeys(webConfig),
 	}
 	handler.init()
 	return handler
@@ -118,6 +128,55 @@ func (h *ConfigHandler) cookieAuth(handler http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// apiAuth 接口认证中间件：X-API-Key 或 Authorization: Bearer 携带的接口密钥（见 web.api_keys、web.api_token），
+// 或已登录且角色不低于 role 的 Cookie；错误均以 JSON 返回
+func (h *ConfigHandler) apiAuth(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 错误信息按协商的语言翻译，见 writeJSONError
+		w.Header().Set("Content-Language", i18n.Negotiate(r))
+		if !h.webConfig.Enabled {
+			writeJSONError(w, http.StatusNotFound, "未启用 Web 管理")
+			return
+		}
+
+		clientIP := monitor.TrustedClientIP(r)
+		if remaining, banned := bruteforce.Banned(clientIP); banned {
+			w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Round(time.Second)/time.Second)))
+			writeJSONError(w, http.StatusTooManyRequests, "失败次数过多，请稍后再试")
+			return
+		}
+
+		if secret := requestAPIKey(r); secret != "" {
+			key, ok := h.matchAPIKey(secret)
+			if !ok {
+				bruteforce.Fail(clientIP, bruteforce.ReasonAPIToken)
+				writeJSONError(w, http.StatusUnauthorized, "接口密钥无效")
+				return
+			}
+			if !key.allowed(clientIP) {
+				writeJSONError(w, http.StatusForbidden, "该接口密钥不允许从 "+clientIP+" 使用")
+				return
+			}
+			if roleLevels[key.role] < roleLevels[role] {
+				writeJSONError(w, http.StatusForbidden, "权限不足")
+				return
+			}
+			handler(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key.name)))
+			return
+		}
+		if !h.isAuthenticated(r) {
+			writeJSONError(w, http.StatusUnauthorized, "未认证")
+			return
+		}
+		if !h.hasRole(r, role) {
+			writeJSONError(w, http.StatusForbidden, "权限不足")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
 // renderTemplate 渲染指定的模板
 func (h *ConfigHandler) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName, filePath string, data map[string]interface{}) error {
 	// 从嵌入的文件系统读取模板
@@ -146,6 +205,9 @@ func (h *ConfigHandler) RegisterRoutes(mux *http.ServeMux) {
 	// 注册主题同步路由
 	mux.HandleFunc(webPath+"sync-theme", h.handleSyncTheme)
 
+	// 页面翻译脚本，按请求协商的语言输出语言包
+	mux.HandleFunc(webPath+"i18n.js", h.handleI18NScript)
+
 	// --- 静态文件 ---
 	subFS, _ := fs.Sub(staticFS, "static")
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(subFS))))
@@ -157,11 +219,13 @@ func (h *ConfigHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc(webPath+"logout", h.handleLogout)
 	mux.HandleFunc(webPath+"auth-status", h.handleAuthStatus)
 
-	// 注册GitHub升级相关路由
-	RegisterGithubRoutes(mux, webPath, h.cookieAuth)
+	// 注册GitHub升级相关路由（替换程序文件，仅管理员）
+	RegisterGithubRoutes(mux, webPath, func(handler http.HandlerFunc) http.HandlerFunc {
+		return h.roleAuth(RoleAdmin, handler)
+	})
 
 	// 基础编辑器页面路由
-	mux.HandleFunc(webPath+"editor", h.cookieAuth(h.handleEditor))
+	mux.HandleFunc(webPath+"editor", h.roleAuth(RoleAdmin, h.handleEditor))
 	mux.HandleFunc(webPath+"node", h.cookieAuth(h.handleNode))
 
 	// 特定配置编辑器页面路由
@@ -172,60 +236,113 @@ func (h *ConfigHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc(webPath+"jx-editor", h.cookieAuth(h.handleJXEditor))
 	mux.HandleFunc(webPath+"server-monitor-editor", h.cookieAuth(h.handleServerMonitorEditor))
 	mux.HandleFunc(webPath+"server-editor", h.cookieAuth(h.handleServerEditor))
-	mux.HandleFunc(webPath+"web-editor", h.cookieAuth(h.handleWebEditor))
+	mux.HandleFunc(webPath+"web-editor", h.roleAuth(RoleAdmin, h.handleWebEditor))
 	mux.HandleFunc(webPath+"reload-editor", h.cookieAuth(h.handleReloadEditor))
 	mux.HandleFunc(webPath+"http-editor", h.cookieAuth(h.handleHTTPEditor))
 	mux.HandleFunc(webPath+"log-editor", h.cookieAuth(http.HandlerFunc(h.handleLogEditor)))
 	mux.HandleFunc(webPath+"github-editor", h.cookieAuth(h.handleGithubEditor))
 
 	// 配置查看与保存路由
-	mux.HandleFunc(webPath+"config", h.cookieAuth(h.handleConfig))
-	mux.HandleFunc(webPath+"config/save", h.cookieAuth(h.handleConfigSave))
+	mux.HandleFunc(webPath+"config", h.roleAuth(RoleAdmin, h.handleConfig))
+	mux.HandleFunc(webPath+"config/save", h.roleAuth(RoleAdmin, h.handleConfigSave))
 	mux.HandleFunc(webPath+"config/validate", h.cookieAuth(h.handleConfigValidate))
 
 	// 组配置相关路由
 	mux.HandleFunc(webPath+"config/group", h.cookieAuth(h.handleGroupConfig))
-	mux.HandleFunc(webPath+"config/save-group", h.cookieAuth(h.handleConfigSaveGroup))
+	mux.HandleFunc(webPath+"config/save-group", h.roleAuth(RoleOperator, h.handleConfigSaveGroup))
 
 	// 其他节点配置路由
-	mux.HandleFunc(webPath+"config/domainmap", h.cookieAuth(h.handleDomainMapConfig))
-	mux.HandleFunc(webPath+"config/save-domainmap", h.cookieAuth(h.handleDomainMapConfigSave))
+	mux.HandleFunc(webPath+"config/domainmap", h.roleAuth(RoleOperator, h.handleDomainMapConfig))
+	mux.HandleFunc(webPath+"config/save-domainmap", h.roleAuth(RoleOperator, h.handleDomainMapConfigSave))
 	mux.HandleFunc(webPath+"config/proxygroups", h.cookieAuth(h.handleProxyGroupsConfig))
-	mux.HandleFunc(webPath+"config/save-proxygroups", h.cookieAuth(h.handleProxyGroupsConfigSave))
-	mux.HandleFunc(webPath+"config/global-auth", h.cookieAuth(h.handleGlobalAuthConfig))
-	mux.HandleFunc(webPath+"config/save-global-auth", h.cookieAuth(h.handleGlobalAuthConfigSave))
+	mux.HandleFunc(webPath+"config/save-proxygroups", h.roleAuth(RoleOperator, h.handleProxyGroupsConfigSave))
+	mux.HandleFunc(webPath+"config/proxygroups/test", h.roleAuth(RoleOperator, h.handleProxyGroupsTest))
+	mux.HandleFunc(webPath+"config/proxygroups/history", h.cookieAuth(h.handleProxyGroupsHistory))
+	mux.HandleFunc(webPath+"config/global-auth", h.roleAuth(RoleOperator, h.handleGlobalAuthConfig))
+	mux.HandleFunc(webPath+"config/save-global-auth", h.roleAuth(RoleAdmin, h.handleGlobalAuthConfigSave))
 	mux.HandleFunc(webPath+"config/jx", h.cookieAuth(h.handleJXConfig))
-	mux.HandleFunc(webPath+"config/save-jx", h.cookieAuth(h.handleJXConfigSave))
+	mux.HandleFunc(webPath+"config/save-jx", h.roleAuth(RoleOperator, h.handleJXConfigSave))
 	mux.HandleFunc(webPath+"config/server-monitor", h.cookieAuth(h.handleServerMonitorConfig))
-	mux.HandleFunc(webPath+"config/save-server-monitor", h.cookieAuth(h.handleServerMonitorConfigSave))
+	mux.HandleFunc(webPath+"config/save-server-monitor", h.roleAuth(RoleOperator, h.handleServerMonitorConfigSave))
 	mux.HandleFunc(webPath+"config/server", h.cookieAuth(h.handleServerConfig))
-	mux.HandleFunc(webPath+"config/save-server", h.cookieAuth(h.handleServerConfigSave))
-	mux.HandleFunc(webPath+"config/web", h.cookieAuth(h.handleWebConfig))
-	mux.HandleFunc(webPath+"config/save-web", h.cookieAuth(h.handleWebConfigSave))
+	mux.HandleFunc(webPath+"config/save-server", h.roleAuth(RoleOperator, h.handleServerConfigSave))
+	mux.HandleFunc(webPath+"config/web", h.roleAuth(RoleAdmin, h.handleWebConfig))
+	mux.HandleFunc(webPath+"config/save-web", h.roleAuth(RoleAdmin, h.handleWebConfigSave))
 	mux.HandleFunc(webPath+"config/reload", h.cookieAuth(h.handleReloadConfig))
-	mux.HandleFunc(webPath+"config/save-reload", h.cookieAuth(h.handleReloadConfigSave))
+	mux.HandleFunc(webPath+"config/save-reload", h.roleAuth(RoleOperator, h.handleReloadConfigSave))
 	mux.HandleFunc(webPath+"config/http", h.cookieAuth(h.handleHTTPConfig))
-	mux.HandleFunc(webPath+"config/save-http", h.cookieAuth(h.handleHTTPConfigSave))
+	mux.HandleFunc(webPath+"config/save-http", h.roleAuth(RoleOperator, h.handleHTTPConfigSave))
 	mux.HandleFunc(webPath+"config/log", h.cookieAuth(http.HandlerFunc(h.handleGetLogConfig)))
-	mux.HandleFunc(webPath+"config/save-log", h.cookieAuth(http.HandlerFunc(h.handleSaveLogConfig)))
+	mux.HandleFunc(webPath+"config/save-log", h.roleAuth(RoleOperator, http.HandlerFunc(h.handleSaveLogConfig)))
 
 	// 备份相关路由
 	mux.HandleFunc(webPath+"config/backup", h.cookieAuth(h.handleConfigBackupPage))
 	backupHandler := &ConfigBackupHandler{}
 	mux.HandleFunc(webPath+"config/backup/list", h.cookieAuth(backupHandler.handleListBackups))
-	mux.HandleFunc(webPath+"config/backup/delete", h.cookieAuth(backupHandler.handleDeleteBackup))
-	mux.HandleFunc(webPath+"config/backup/restore", h.cookieAuth(backupHandler.handleRestoreBackup))
-	mux.HandleFunc(webPath+"config/backup/download", h.cookieAuth(backupHandler.handleDownloadBackup))
+	mux.HandleFunc(webPath+"config/backup/delete", h.roleAuth(RoleAdmin, backupHandler.handleDeleteBackup))
+	mux.HandleFunc(webPath+"config/backup/restore", h.roleAuth(RoleAdmin, backupHandler.handleRestoreBackup))
+	mux.HandleFunc(webPath+"config/backup/download", h.roleAuth(RoleAdmin, backupHandler.handleDownloadBackup))
+	// 完整备份包：配置、签发的 token、流量统计与历史统计数据库，用于迁移到其他主机
+	mux.HandleFunc(webPath+"api/backup/export", h.apiAuth(RoleAdmin, h.handleBundleExport))
+	mux.HandleFunc(webPath+"api/backup/import", h.apiAuth(RoleAdmin, h.handleBundleImport))
+
+	// token 签发与吊销接口
+	mux.HandleFunc(webPath+"api/tokens", h.apiAuth(RoleAdmin, h.handleTokens))
+	mux.HandleFunc(webPath+"api/tokens/extend", h.apiAuth(RoleAdmin, h.handleTokenExtend))
+	mux.HandleFunc(webPath+"api/tokens/revoke", h.apiAuth(RoleAdmin, h.handleTokenRevoke))
+	mux.HandleFunc(webPath+"api/tokens/export", h.apiAuth(RoleAdmin, h.handleTokenExport))
+	mux.HandleFunc(webPath+"api/tokens/import", h.apiAuth(RoleAdmin, h.handleTokenImport))
+
+	// 暴力破解封禁列表接口
+	mux.HandleFunc(webPath+"api/bans", h.apiAuth(RoleAdmin, h.handleBans))
+	mux.HandleFunc(webPath+"api/bans/unban", h.apiAuth(RoleAdmin, h.handleUnban))
+
+	// 在线客户端：查看、踢出并封禁
+	mux.HandleFunc(webPath+"clients", h.roleAuth(RoleAdmin, h.handleClientsPage))
+	mux.HandleFunc(webPath+"api/clients", h.apiAuth(RoleAdmin, h.handleClients))
+	mux.HandleFunc(webPath+"api/clients/kick", h.apiAuth(RoleAdmin, h.handleKickClient))
+
+	// token 使用审计接口
+	mux.HandleFunc(webPath+"api/audit", h.apiAuth(RoleOperator, h.handleAudit))
+	mux.HandleFunc(webPath+"api/audit/summary", h.apiAuth(RoleOperator, h.handleAuditSummary))
+
+	// 实时日志
+	mux.HandleFunc(webPath+"logs", h.roleAuth(RoleOperator, h.handleLogsPage))
+	mux.HandleFunc(webPath+"api/logs/ws", h.apiAuth(RoleOperator, h.handleLogsWS))
+	mux.HandleFunc(webPath+"api/logs/download", h.apiAuth(RoleOperator, h.handleLogsDownload))
+	mux.HandleFunc(webPath+"api/logs/level", h.apiAuth(RoleAdmin, h.handleLogLevel))
+
+	// 频道与代理组历史统计
+	mux.HandleFunc(webPath+"stats", h.roleAuth(RoleViewer, h.handleStatsPage))
+	mux.HandleFunc(webPath+"api/stats/series", h.apiAuth(RoleViewer, h.handleStatsSeries))
+	mux.HandleFunc(webPath+"api/stats/query", h.apiAuth(RoleViewer, h.handleStatsQuery))
+
+	// 频道预览
+	mux.HandleFunc(webPath+"player", h.roleAuth(RoleOperator, h.handlePlayerPage))
+	mux.HandleFunc(webPath+"api/preview/stream", h.apiAuth(RoleOperator, h.handlePreviewStream))
+
+	// 定时录制，接口见 apiv1.go
+	mux.HandleFunc(webPath+"dvr", h.roleAuth(RoleOperator, h.handleDVRPage))
+
+	// token 流量配额接口
+	mux.HandleFunc(webPath+"api/quota", h.apiAuth(RoleOperator, h.handleQuota))
+	mux.HandleFunc(webPath+"api/quota/reset", h.apiAuth(RoleAdmin, h.handleQuotaReset))
+
+	// 版本化接口，供自动化调用（见 apiv1.go）
+	h.registerAPIv1(mux, webPath)
+
+	// 运行时诊断：pprof、expvar 与 goroutine 堆栈（见 handledebug.go）
+	h.registerDebug(mux, webPath)
 
 	// GitHub 配置相关路由
-	mux.HandleFunc(webPath+"github", h.handleGithubEditor)
-	mux.HandleFunc(webPath+"api/github/config", h.handleGithubConfig)
-	mux.HandleFunc(webPath+"api/github/config/save", h.handleGithubConfigSave)
+	mux.HandleFunc(webPath+"github", h.cookieAuth(h.handleGithubEditor))
+	mux.HandleFunc(webPath+"api/github/config", h.cookieAuth(h.handleGithubConfig))
+	mux.HandleFunc(webPath+"api/github/config/save", h.roleAuth(RoleOperator, h.handleGithubConfigSave))
 
 	// DNS 配置相关路由
-	mux.HandleFunc(webPath+"dns", h.handleDnsEditor)
-	mux.HandleFunc(webPath+"api/dns/config", h.handleDnsConfig)
-	mux.HandleFunc(webPath+"api/dns/config/save", h.handleDnsConfigSave)
+	mux.HandleFunc(webPath+"dns", h.cookieAuth(h.handleDnsEditor))
+	mux.HandleFunc(webPath+"api/dns/config", h.cookieAuth(h.handleDnsConfig))
+	mux.HandleFunc(webPath+"api/dns/config/save", h.roleAuth(RoleOperator, h.handleDnsConfigSave))
 
 	// 全局认证配置相关路由
 	// mux.HandleFunc(webPath+"globalauth", h.handleGlobalAuthEditor)
@@ -239,7 +356,7 @@ func (h *ConfigHandler) handleNode(w http.ResponseWriter, r *http.Request) {
 	// 从嵌入的文件系统读取模板
 	content, err := templatesFS.ReadFile("templates/node.html")
 	if err != nil {
-		http.Error(w, "Failed to read template file", http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to read template file", http.StatusInternalServerError)
 		return
 	}
 
@@ -275,7 +392,9 @@ func (h *ConfigHandler) handleNode(w http.ResponseWriter, r *http.Request) {
 			dm.Auth.DynamicTokens.Secret != "" ||
 			dm.Auth.DynamicTokens.Salt != "" ||
 			dm.Auth.StaticTokens.EnableStatic ||
-			dm.Auth.StaticTokens.Token != "" {
+			dm.Auth.StaticTokens.Token != "" ||
+			dm.Auth.JWTTokens.EnableJWT ||
+			dm.Auth.SignedURL.EnableSigned {
 			hasDomainMapAuth = true
 			break
 		}
@@ -289,7 +408,9 @@ func (h *ConfigHandler) handleNode(w http.ResponseWriter, r *http.Request) {
 		globalAuth.DynamicTokens.Secret != "" ||
 		globalAuth.DynamicTokens.Salt != "" ||
 		globalAuth.StaticTokens.EnableStatic ||
-		globalAuth.StaticTokens.Token != ""
+		globalAuth.StaticTokens.Token != "" ||
+		globalAuth.JWTTokens.EnableJWT ||
+		globalAuth.SignedURL.EnableSigned
 
 	// 检查proxygroups是否配置了有效内容
 	hasProxyGroups := len(config.Cfg.ProxyGroups) > 0
@@ -349,31 +470,39 @@ func (h *ConfigHandler) handleNode(w http.ResponseWriter, r *http.Request) {
 	// 创建模板并解析
 	tmpl, err := template.New("home").Parse(string(content))
 	if err != nil {
-		http.Error(w, "Failed to parse template", http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to parse template", http.StatusInternalServerError)
 		return
 	}
 
 	// 读取侧边栏模板
 	sidebarContent, err := templatesFS.ReadFile("templates/sidebar.html")
 	if err != nil {
-		http.Error(w, "Failed to read sidebar template file", http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to read sidebar template file", http.StatusInternalServerError)
 		return
 	}
 
 	// 解析侧边栏模板
 	_, err = tmpl.New("sidebar").Parse(string(sidebarContent))
 	if err != nil {
-		http.Error(w, "Failed to parse sidebar template", http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to parse sidebar template", http.StatusInternalServerError)
 		return
 	}
 
 	// 执行模板
 	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, "Failed to execute template", http.StatusInternalServerError)
+		httperror.Error(w, r, "Failed to execute template", http.StatusInternalServerError)
 		return
 	}
 }
 
+// handleI18NScript 输出页面翻译脚本
+func (h *ConfigHandler) handleI18NScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Accept-Language, Cookie")
+	w.Write(i18n.Script(i18n.Negotiate(r)))
+}
+
 // handleSyncTheme 处理主题同步请求
 func (h *ConfigHandler) handleSyncTheme(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -388,11 +517,11 @@ func (h *ConfigHandler) handleSyncTheme(w http.ResponseWriter, r *http.Request)
 			Theme string `json:"theme"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
 		if req.Theme != "dark" && req.Theme != "light" {
-			http.Error(w, "Invalid theme value", http.StatusBadRequest)
+			httperror.Error(w, r, "Invalid theme value", http.StatusBadRequest)
 			return
 		}
 		h.themeMutex.Lock()
@@ -400,7 +529,7 @@ func (h *ConfigHandler) handleSyncTheme(w http.ResponseWriter, r *http.Request)
 		h.themeMutex.Unlock()
 		w.WriteHeader(http.StatusOK)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -468,7 +597,9 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 				dm.Auth.DynamicTokens.Secret != "" ||
 				dm.Auth.DynamicTokens.Salt != "" ||
 				dm.Auth.StaticTokens.EnableStatic ||
-				dm.Auth.StaticTokens.Token != "" {
+				dm.Auth.StaticTokens.Token != "" ||
+				dm.Auth.JWTTokens.EnableJWT ||
+				dm.Auth.SignedURL.EnableSigned {
 				hasDomainMapAuth = true
 				break
 			}
@@ -482,7 +613,9 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 			globalAuth.DynamicTokens.Secret != "" ||
 			globalAuth.DynamicTokens.Salt != "" ||
 			globalAuth.StaticTokens.EnableStatic ||
-			globalAuth.StaticTokens.Token != ""
+			globalAuth.StaticTokens.Token != "" ||
+			globalAuth.JWTTokens.EnableJWT ||
+			globalAuth.SignedURL.EnableSigned
 
 		// 检查proxygroups是否配置了有效内容
 		hasProxyGroups := len(config.Cfg.ProxyGroups) > 0
@@ -575,7 +708,7 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 		content, err := templatesFS.ReadFile("templates/index.html")
 		if err != nil {
 			// log.Printf("读取模板文件失败: %v", err)
-			http.Error(w, "Failed to read template file", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read template file", http.StatusInternalServerError)
 			return
 		}
 
@@ -583,7 +716,7 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 		sidebarContent, err := templatesFS.ReadFile("templates/sidebar.html")
 		if err != nil {
 			// log.Printf("读取侧边栏模板文件失败: %v", err)
-			http.Error(w, "Failed to read sidebar template file", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read sidebar template file", http.StatusInternalServerError)
 			return
 		}
 
@@ -592,7 +725,7 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 		}).Parse(string(content))
 		if err != nil {
 			// log.Printf("解析模板失败: %v", err)
-			http.Error(w, "Failed to parse template", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse template", http.StatusInternalServerError)
 			return
 		}
 
@@ -600,7 +733,7 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 		_, err = tmpl.New("sidebar").Parse(string(sidebarContent))
 		if err != nil {
 			// log.Printf("解析侧边栏模板失败: %v", err)
-			http.Error(w, "Failed to parse sidebar template", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse sidebar template", http.StatusInternalServerError)
 			return
 		}
 
@@ -610,7 +743,7 @@ func (h *ConfigHandler) handleWeb(w http.ResponseWriter, r *http.Request) {
 		// 执行模板
 		if err := tmpl.Execute(w, data); err != nil {
 			// log.Printf("执行模板失败: %v", err)
-			http.Error(w, "Failed to execute template", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to execute template", http.StatusInternalServerError)
 			return
 		}
 
@@ -646,14 +779,14 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		content, err := templatesFS.ReadFile("templates/login.html")
 		if err != nil {
 			// log.Printf("读取登录模板文件失败: %v", err)
-			http.Error(w, "Failed to read template file", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read template file", http.StatusInternalServerError)
 			return
 		}
 
 		tmpl, err := template.New("login").Parse(string(content))
 		if err != nil {
 			// log.Printf("解析登录模板失败: %v", err)
-			http.Error(w, "Failed to parse template", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse template", http.StatusInternalServerError)
 			return
 		}
 
@@ -668,7 +801,7 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		// 执行模板
 		if err := tmpl.Execute(w, data); err != nil {
 			// log.Printf("执行登录模板失败: %v", err)
-			http.Error(w, "Failed to execute template", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to execute template", http.StatusInternalServerError)
 			return
 		}
 
@@ -686,9 +819,14 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 			Password string `json:"password"`
 		}
 
+		clientIP := monitor.TrustedClientIP(r)
+		if rejectBanned(w, r, clientIP) {
+			return
+		}
+
 		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
 			// log.Printf("解析登录请求体失败: %v", err)
-			http.Error(w, "无效的请求数据", http.StatusBadRequest)
+			httperror.Error(w, r, "无效的请求数据", http.StatusBadRequest)
 			return
 		}
 
@@ -696,12 +834,9 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 		// 验证用户名和密码
 
-		usernameMatch := subtle.ConstantTimeCompare([]byte(credentials.Username), []byte(h.webConfig.Username)) == 1
-		passwordMatch := subtle.ConstantTimeCompare([]byte(credentials.Password), []byte(h.webConfig.Password)) == 1
-
-		// log.Printf("用户名匹配: %t, 密码匹配: %t", usernameMatch, passwordMatch)
+		_, credentialsMatch := h.checkCredentials(credentials.Username, credentials.Password)
 
-		if h.webConfig.Enabled && usernameMatch && passwordMatch {
+		if h.webConfig.Enabled && credentialsMatch {
 			// 认证成功，设置会话cookie
 			// log.Printf("认证成功，设置认证Cookie")
 			http.SetCookie(w, &http.Cookie{
@@ -713,6 +848,7 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 				MaxAge:   3600, // 1小时
 			})
 
+			bruteforce.Success(clientIP)
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(`{"status": "success"}`))
 			return
@@ -720,12 +856,13 @@ func (h *ConfigHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 		// 认证失败
 		// log.Printf("认证失败")
-		http.Error(w, "用户名或密码错误", http.StatusUnauthorized)
+		bruteforce.Fail(clientIP, bruteforce.ReasonWebLogin)
+		httperror.Error(w, r, "用户名或密码错误", http.StatusUnauthorized)
 		return
 	}
 
 	// log.Printf("不支持的请求方法: %s", r.Method)
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
 // handleLogout 处理退出登录
@@ -734,7 +871,7 @@ func (h *ConfigHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != http.MethodGet {
 		// log.Printf("不支持的请求方法: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -761,7 +898,7 @@ func (h *ConfigHandler) handleAuthStatus(w http.ResponseWriter, r *http.Request)
 
 	if r.Method != http.MethodGet {
 		// log.Printf("不支持的请求方法: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -781,6 +918,7 @@ func (h *ConfigHandler) handleAuthStatus(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"authenticated": isAuthenticated,
 		"username":      username,
+		"role":          h.requestRole(r),
 	})
 }
 
@@ -839,11 +977,12 @@ func (h *ConfigHandler) getWebPath() string {
 
 // generateAuthCookieValue 生成认证cookie值
 func (h *ConfigHandler) generateAuthCookieValue(username string) string {
-	// 简单的认证机制：用户名+时间戳的哈希
+	// 简单的认证机制：用户名+时间戳的哈希，以该用户的密码作为密钥，修改密码后旧会话失效
+	user, _ := h.findUser(username)
 	data := username + "|" + strconv.FormatInt(time.Now().Unix(), 10)
 	// log.Printf("生成Cookie数据: %s", data)
 	// log.Printf("使用密码: %s", h.webConfig.Password)
-	hash := sha256.Sum256([]byte(data + h.webConfig.Password))
+	hash := sha256.Sum256([]byte(data + user.Password))
 	// log.Printf("生成的哈希: %x", hash)
 	return fmt.Sprintf("%s|%x", data, hash)
 }
@@ -884,10 +1023,14 @@ func (h *ConfigHandler) validateAuthCookie(cookieValue string) bool {
 	}
 
 	// 验证哈希
+	user, ok := h.findUser(username)
+	if !ok {
+		return false
+	}
 	data := username + "|" + timestamp
 	// log.Printf("用于验证的数据: %s", data)
 	// log.Printf("配置中的密码: %s", h.webConfig.Password)
-	expectedHash := sha256.Sum256([]byte(data + h.webConfig.Password))
+	expectedHash := sha256.Sum256([]byte(data + user.Password))
 	// log.Printf("期望的哈希: %x", expectedHash)
 	// log.Printf("实际的哈希: %s", hash)
 
@@ -932,7 +1075,7 @@ func (h *ConfigHandler) handleGroupEditor(w http.ResponseWriter, r *http.Request
 	if r.URL.Path == webPath+"group-editor" {
 		// 只允许GET方法
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -957,14 +1100,14 @@ func (h *ConfigHandler) handleGroupEditor(w http.ResponseWriter, r *http.Request
 		// 从嵌入的文件系统读取模板
 		content, err := templatesFS.ReadFile("templates/group_editor.html")
 		if err != nil {
-			http.Error(w, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析模板
 		tmpl, err := template.New("group_editor").Parse(string(content))
 		if err != nil {
-			http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -973,7 +1116,7 @@ func (h *ConfigHandler) handleGroupEditor(w http.ResponseWriter, r *http.Request
 
 		// 执行模板
 		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1005,12 +1148,12 @@ func (h *ConfigHandler) handleConfig(w http.ResponseWriter, r *http.Request) {
 		configPath := *config.ConfigFilePath
 		contentBytes, err := os.ReadFile(configPath)
 		if err != nil {
-			http.Error(w, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// 设置响应头为YAML格式
-		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		// 按配置文件格式设置响应头，编辑器据此切换语法
+		w.Header().Set("Content-Type", load.ContentType(configPath))
 		w.WriteHeader(http.StatusOK)
 		w.Write(contentBytes)
 		return
@@ -1039,46 +1182,31 @@ func (h *ConfigHandler) handleConfigSave(w http.ResponseWriter, r *http.Request)
 	if r.URL.Path == webPath+"config/save" {
 		// 只允许POST方法
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// 读取请求体中的配置内容，确保使用UTF-8编码
 		content, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		defer r.Body.Close()
 
-		// 验证YAML格式，使用yaml.Node保留注释
+		// 按配置文件格式验证
 		var temp yaml.Node
-		if err := yaml.Unmarshal(content, &temp); err != nil {
-			http.Error(w, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// 获取配置文件路径
-		configPath := *config.ConfigFilePath
-
-		// 备份当前配置文件
-		backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
-		if err := copyFile(configPath, backupPath); err != nil {
-			http.Error(w, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
+		if err := load.UnmarshalNode(*config.ConfigFilePath, content, &temp); err != nil {
+			httperror.Error(w, r, "配置格式错误: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// 尝试将新配置写入文件，确保使用正确的权限
-		if err := os.WriteFile(configPath, content, 0644); err != nil {
-			// 如果写入失败，尝试恢复备份
-			os.Rename(backupPath, configPath)
-			http.Error(w, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
+		// 备份并写入配置文件，与配置接口共用
+		if err := saveConfigFile(content, "web"); err != nil {
+			httperror.Error(w, r, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// 备份文件保留，不删除
-		// os.Remove(backupPath) // 注释掉这行，保留备份文件
-
 		// 返回成功响应
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -1109,29 +1237,34 @@ func (h *ConfigHandler) handleConfigValidate(w http.ResponseWriter, r *http.Requ
 	if r.URL.Path == webPath+"config/validate" {
 		// 只允许POST方法
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// 读取请求体中的配置内容
 		content, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		defer r.Body.Close()
 
-		// 验证YAML格式，使用yaml.Node保留注释
+		// 按配置文件格式验证
+		configPath := *config.ConfigFilePath
 		var temp yaml.Node
-		if err := yaml.Unmarshal(content, &temp); err != nil {
-			http.Error(w, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
+		if err := load.UnmarshalNode(configPath, content, &temp); err != nil {
+			httperror.Error(w, r, "配置格式错误: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// 尝试解析为配置结构体以进行更深入的验证
+		// 尝试解析为配置结构体以进行更深入的验证；非 admin 不替换 ${...} 引用，不能借此读取环境变量和文件
 		var newCfg config.Config
-		if err := yaml.Unmarshal(content, &newCfg); err != nil {
-			http.Error(w, "配置结构验证失败: "+err.Error(), http.StatusBadRequest)
+		parse := load.Parse
+		if !h.hasRole(r, RoleAdmin) {
+			parse = load.ParseRaw
+		}
+		if err := parse(configPath, content, &newCfg); err != nil {
+			httperror.Error(w, r, "配置结构验证失败: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -1165,14 +1298,14 @@ func (h *ConfigHandler) handleNodeConfig(w http.ResponseWriter, r *http.Request)
 	if r.URL.Path == webPath+"config/node" {
 		// 只允许GET方法
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// 获取节点参数
 		node := r.URL.Query().Get("node")
 		if node == "" {
-			http.Error(w, "Missing node parameter", http.StatusBadRequest)
+			httperror.Error(w, r, "Missing node parameter", http.StatusBadRequest)
 			return
 		}
 
@@ -1182,14 +1315,14 @@ func (h *ConfigHandler) handleNodeConfig(w http.ResponseWriter, r *http.Request)
 		// 读取完整配置文件
 		fullConfigData, err := os.ReadFile(configPath)
 		if err != nil {
-			http.Error(w, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析完整配置并保留注释
 		var fullNode yaml.Node
-		if err := yaml.Unmarshal(fullConfigData, &fullNode); err != nil {
-			http.Error(w, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
+		if err := load.UnmarshalNode(configPath, fullConfigData, &fullNode); err != nil {
+			httperror.Error(w, r, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1224,7 +1357,7 @@ func (h *ConfigHandler) handleNodeConfig(w http.ResponseWriter, r *http.Request)
 			// 序列化整个JX节点（包含api_groups）
 			nodeDataYAML, err := yaml.Marshal(nodeContent)
 			if err != nil {
-				http.Error(w, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
+				httperror.Error(w, r, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
@@ -1240,7 +1373,7 @@ func (h *ConfigHandler) handleNodeConfig(w http.ResponseWriter, r *http.Request)
 			// 序列化整个ProxyGroups节点
 			nodeDataYAML, err := yaml.Marshal(nodeContent)
 			if err != nil {
-				http.Error(w, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
+				httperror.Error(w, r, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
@@ -1254,7 +1387,7 @@ func (h *ConfigHandler) handleNodeConfig(w http.ResponseWriter, r *http.Request)
 		// 序列化节点数据（保留注释）
 		nodeDataYAML, err := yaml.Marshal(nodeContent)
 		if err != nil {
-			http.Error(w, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to serialize node data: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1288,21 +1421,21 @@ func (h *ConfigHandler) handleConfigSaveNode(w http.ResponseWriter, r *http.Requ
 	if r.URL.Path == webPath+"config/save-node" {
 		// 只允许POST方法
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// 获取节点参数
 		node := r.URL.Query().Get("node")
 		if node == "" {
-			http.Error(w, "Missing node parameter", http.StatusBadRequest)
+			httperror.Error(w, r, "Missing node parameter", http.StatusBadRequest)
 			return
 		}
 
 		// 读取请求体中的配置内容
 		content, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		defer r.Body.Close()
@@ -1310,7 +1443,7 @@ func (h *ConfigHandler) handleConfigSaveNode(w http.ResponseWriter, r *http.Requ
 		// 验证YAML格式，使用yaml.Node保留注释
 		var temp yaml.Node
 		if err := yaml.Unmarshal(content, &temp); err != nil {
-			http.Error(w, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -1320,21 +1453,21 @@ func (h *ConfigHandler) handleConfigSaveNode(w http.ResponseWriter, r *http.Requ
 		// 读取完整配置文件
 		fullConfigData, err := os.ReadFile(configPath)
 		if err != nil {
-			http.Error(w, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析完整配置并保留注释
 		var fullNode yaml.Node
-		if err := yaml.Unmarshal(fullConfigData, &fullNode); err != nil {
-			http.Error(w, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
+		if err := load.UnmarshalNode(configPath, fullConfigData, &fullNode); err != nil {
+			httperror.Error(w, r, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析新节点内容
 		var newNode yaml.Node
 		if err := yaml.Unmarshal(content, &newNode); err != nil {
-			http.Error(w, "Failed to parse node data: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to parse node data: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -1368,21 +1501,21 @@ func (h *ConfigHandler) handleConfigSaveNode(w http.ResponseWriter, r *http.Requ
 		}
 
 		if !replaced {
-			http.Error(w, "Failed to update node", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to update node", http.StatusInternalServerError)
 			return
 		}
 
 		// 重新序列化完整配置（保留注释）
-		newConfigData, err := yaml.Marshal(&fullNode)
+		newConfigData, err := load.MarshalEdited(configPath, fullConfigData, &fullNode)
 		if err != nil {
-			http.Error(w, "Failed to serialize config: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to serialize config: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 备份当前配置文件
 		backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 		if err := copyFile(configPath, backupPath); err != nil {
-			http.Error(w, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1390,7 +1523,7 @@ func (h *ConfigHandler) handleConfigSaveNode(w http.ResponseWriter, r *http.Requ
 		if err := os.WriteFile(configPath, newConfigData, 0644); err != nil {
 			// 如果写入失败，尝试恢复备份
 			os.Rename(backupPath, configPath)
-			http.Error(w, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1427,7 +1560,7 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 	if r.URL.Path == webPath+"config/group" {
 		// 只允许GET方法
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -1436,7 +1569,13 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 		groupName := r.URL.Query().Get("group")
 
 		if configType == "" || groupName == "" {
-			http.Error(w, "Missing config or group parameter", http.StatusBadRequest)
+			httperror.Error(w, r, "Missing config or group parameter", http.StatusBadRequest)
+			return
+		}
+
+		// 白名单外的配置节可能包含密码与 token 密钥，只允许 admin 读取
+		if !operatorGroupSections[configType] && !h.hasRole(r, RoleAdmin) {
+			httperror.Error(w, r, "权限不足", http.StatusForbidden)
 			return
 		}
 
@@ -1446,14 +1585,14 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 		// 读取完整配置文件
 		fullConfigData, err := os.ReadFile(configPath)
 		if err != nil {
-			http.Error(w, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析完整配置并保留注释
 		var fullNode yaml.Node
-		if err := yaml.Unmarshal(fullConfigData, &fullNode); err != nil {
-			http.Error(w, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
+		if err := load.UnmarshalNode(configPath, fullConfigData, &fullNode); err != nil {
+			httperror.Error(w, r, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1476,7 +1615,7 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 		}
 
 		if configContent == nil {
-			http.Error(w, "Config type not found", http.StatusNotFound)
+			httperror.Error(w, r, "Config type not found", http.StatusNotFound)
 			return
 		}
 
@@ -1527,14 +1666,14 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 		}
 
 		if groupContent == nil {
-			http.Error(w, "Group not found", http.StatusNotFound)
+			httperror.Error(w, r, "Group not found", http.StatusNotFound)
 			return
 		}
 
 		// 序列化组数据（保留注释）
 		groupDataYAML, err := yaml.Marshal(groupContent)
 		if err != nil {
-			http.Error(w, "Failed to serialize group data: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to serialize group data: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1548,6 +1687,12 @@ func (h *ConfigHandler) handleGroupConfig(w http.ResponseWriter, r *http.Request
 	http.NotFound(w, r)
 }
 
+// operatorGroupSections 非 admin 可通过 config/group 与 config/save-group 访问的顶级配置节
+var operatorGroupSections = map[string]bool{
+	"proxygroups": true,
+	"jx":          true,
+}
+
 // handleConfigSaveGroup 处理代理组配置保存请求
 func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Request) {
 	// 获取配置的Web路径，默认为/web/
@@ -1568,7 +1713,7 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 	if r.URL.Path == webPath+"config/save-group" {
 		// 只允许POST方法
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -1577,14 +1722,20 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		groupName := r.URL.Query().Get("group")
 
 		if configType == "" || groupName == "" {
-			http.Error(w, "Missing config or group parameter", http.StatusBadRequest)
+			httperror.Error(w, r, "Missing config or group parameter", http.StatusBadRequest)
+			return
+		}
+
+		// operator 只能修改白名单内的分组，web、global_auth、domainmap 等涉及认证的配置需要 admin
+		if !operatorGroupSections[configType] && !h.hasRole(r, RoleAdmin) {
+			httperror.Error(w, r, "权限不足", http.StatusForbidden)
 			return
 		}
 
 		// 读取请求体中的配置内容
 		content, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 		defer r.Body.Close()
@@ -1592,7 +1743,7 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		// 验证YAML格式，使用yaml.Node保留注释
 		var temp yaml.Node
 		if err := yaml.Unmarshal(content, &temp); err != nil {
-			http.Error(w, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "YAML格式错误: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -1602,21 +1753,21 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		// 读取完整配置文件
 		fullConfigData, err := os.ReadFile(configPath)
 		if err != nil {
-			http.Error(w, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析完整配置并保留注释
 		var fullNode yaml.Node
-		if err := yaml.Unmarshal(fullConfigData, &fullNode); err != nil {
-			http.Error(w, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
+		if err := load.UnmarshalNode(configPath, fullConfigData, &fullNode); err != nil {
+			httperror.Error(w, r, "Failed to parse config file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析新组内容
 		var newNode yaml.Node
 		if err := yaml.Unmarshal(content, &newNode); err != nil {
-			http.Error(w, "Failed to parse group data: "+err.Error(), http.StatusBadRequest)
+			httperror.Error(w, r, "Failed to parse group data: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -1654,7 +1805,7 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		}
 
 		if !configFound {
-			http.Error(w, "Failed to find or create config type", http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to find or create config type", http.StatusInternalServerError)
 			return
 		}
 
@@ -1750,16 +1901,16 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		}
 
 		// 重新序列化完整配置（保留注释）
-		newConfigData, err := yaml.Marshal(&fullNode)
+		newConfigData, err := load.MarshalEdited(configPath, fullConfigData, &fullNode)
 		if err != nil {
-			http.Error(w, "Failed to serialize config: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to serialize config: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 备份当前配置文件
 		backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 		if err := copyFile(configPath, backupPath); err != nil {
-			http.Error(w, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to create backup: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1767,7 +1918,7 @@ func (h *ConfigHandler) handleConfigSaveGroup(w http.ResponseWriter, r *http.Req
 		if err := os.WriteFile(configPath, newConfigData, 0644); err != nil {
 			// 如果写入失败，尝试恢复备份
 			os.Rename(backupPath, configPath)
-			http.Error(w, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to save config: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +24,7 @@ func (h *ConfigHandler) handleReloadEditor(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.renderTemplate(w, r, "reload_editor", "templates/reload_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -39,46 +41,46 @@ func (h *ConfigHandler) handleReloadConfig(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 	}
 }
 
 // handleReloadConfigSave 保存 reload 配置
 func (h *ConfigHandler) handleReloadConfigSave(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
 	var cfg map[string]interface{}
 	if err := json.Unmarshal(body, &cfg); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	reloadVal, ok := cfg["reload"]
 	if !ok {
-		http.Error(w, "缺少 reload 参数", http.StatusBadRequest)
+		httperror.Error(w, r, "缺少 reload 参数", http.StatusBadRequest)
 		return
 	}
 
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -103,21 +105,21 @@ func (h *ConfigHandler) handleReloadConfigSave(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
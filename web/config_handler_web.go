@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +24,7 @@ func (h *ConfigHandler) handleWebEditor(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.renderTemplate(w, r, "web_editor", "templates/web_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -35,47 +37,49 @@ func (h *ConfigHandler) handleWebConfig(w http.ResponseWriter, r *http.Request)
 	config.CfgMu.RUnlock()
 
 	resp := map[string]interface{}{
-		"enabled":  webCfg.Enabled,
-		"username": webCfg.Username,
-		"password": webCfg.Password,
-		"path":     webCfg.Path,
+		"enabled":   webCfg.Enabled,
+		"username":  webCfg.Username,
+		"password":  webCfg.Password,
+		"path":      webCfg.Path,
+		"api_token": webCfg.APIToken,
+		"users":     webUsersList(webCfg.Users),
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 	}
 }
 
 // handleWebConfigSave 保存 Web 配置
 func (h *ConfigHandler) handleWebConfigSave(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
 	var webConfig map[string]interface{}
 	if err := json.Unmarshal(body, &webConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -109,6 +113,26 @@ func (h *ConfigHandler) handleWebConfigSave(w http.ResponseWriter, r *http.Reque
 							&yaml.Node{Kind: yaml.ScalarNode, Value: "path"},
 							&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", path)})
 					}
+					if apiToken, ok := webConfig["api_token"]; ok && apiToken != nil && apiToken != "" {
+						newWebNode.Content = append(newWebNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "api_token"},
+							&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", apiToken)})
+					}
+
+					if usersNode := webUsersNode(webConfig["users"]); usersNode != nil {
+						newWebNode.Content = append(newWebNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "users"},
+							usersNode)
+					}
+
+					// 编辑器不管理接口密钥，保留原有配置
+					if old := doc.Content[i+1]; old.Kind == yaml.MappingNode {
+						for j := 0; j+1 < len(old.Content); j += 2 {
+							if old.Content[j].Value == "api_keys" {
+								newWebNode.Content = append(newWebNode.Content, old.Content[j], old.Content[j+1])
+							}
+						}
+					}
 
 					doc.Content[i+1] = newWebNode
 					webFound = true
@@ -126,24 +150,67 @@ func (h *ConfigHandler) handleWebConfigSave(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write([]byte("配置保存成功"))
 }
+
+// webUsersList 将账号列表转换为编辑器使用的 JSON 格式
+func webUsersList(users []config.WebUser) []map[string]string {
+	list := make([]map[string]string, 0, len(users))
+	for _, u := range users {
+		list = append(list, map[string]string{
+			"username": u.Username,
+			"password": u.Password,
+			"role":     u.Role,
+		})
+	}
+	return list
+}
+
+// webUsersNode 根据编辑器提交的 users 列表生成 YAML 节点，未配置时返回 nil
+func webUsersNode(value interface{}) *yaml.Node {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	seq := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, item := range list {
+		userMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		userNode := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range []string{"username", "password", "role"} {
+			if v, ok := userMap[key]; ok && v != nil && v != "" {
+				userNode.Content = append(userNode.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+					&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)})
+			}
+		}
+		if len(userNode.Content) > 0 {
+			seq.Content = append(seq.Content, userNode)
+		}
+	}
+	if len(seq.Content) == 0 {
+		return nil
+	}
+	return seq
+}
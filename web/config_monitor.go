@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -33,7 +35,7 @@ func (h *ConfigHandler) handleServerMonitorEditor(w http.ResponseWriter, r *http
 	}
 
 	if err := h.renderTemplate(w, r, "server_monitor_editor", "templates/server_monitor_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -51,7 +53,7 @@ func (h *ConfigHandler) handleServerMonitorConfig(w http.ResponseWriter, r *http
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(serverConfig); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -60,14 +62,14 @@ func (h *ConfigHandler) handleServerMonitorConfig(w http.ResponseWriter, r *http
 func (h *ConfigHandler) handleServerMonitorConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -75,7 +77,7 @@ func (h *ConfigHandler) handleServerMonitorConfigSave(w http.ResponseWriter, r *
 	// 解析JSON数据
 	var serverConfig map[string]interface{}
 	if err := json.Unmarshal(body, &serverConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -83,14 +85,14 @@ func (h *ConfigHandler) handleServerMonitorConfigSave(w http.ResponseWriter, r *
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -151,16 +153,16 @@ func (h *ConfigHandler) handleServerMonitorConfigSave(w http.ResponseWriter, r *
 	}
 
 	// 序列化为YAML格式
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -168,7 +170,7 @@ func (h *ConfigHandler) handleServerMonitorConfigSave(w http.ResponseWriter, r *
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		// 恢复备份文件
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -6,12 +6,28 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/qist/tvgate/config/load"
 )
 
 //go:embed config.yaml
 var embeddedConfig embed.FS
 
-// EnsureConfigFile 确保指定路径下有配置文件
+// 目录中查找的配置文件名，按顺序优先；都不存在时生成 config.yaml
+var configFileNames = []string{"config.yaml", "config.yml", "config.json", "config.toml"}
+
+// dirConfigFile 返回目录中已存在的配置文件，没有时返回 config.yaml
+func dirConfigFile(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+// EnsureConfigFile 确保指定路径下有配置文件，文件不存在时按扩展名（.yaml/.json/.toml）生成默认配置
 // 返回实际配置文件路径
 func EnsureConfigFile(configPath string) (string, error) {
 	if configPath == "" {
@@ -24,13 +40,13 @@ func EnsureConfigFile(configPath string) (string, error) {
 
 	if ext == "" || strings.HasSuffix(configPath, string(os.PathSeparator)) {
 		// 没有扩展名或以 "/" 结尾 → 当目录处理
-		configFilePath = filepath.Join(configPath, "config.yaml")
+		configFilePath = dirConfigFile(configPath)
 	} else {
 		info, err := os.Stat(configPath)
 		if err == nil {
 			if info.IsDir() {
 				// 已存在目录 → 当目录
-				configFilePath = filepath.Join(configPath, "config.yaml")
+				configFilePath = dirConfigFile(configPath)
 			} else {
 				// 已存在文件 → 当文件
 				configFilePath = configPath
@@ -57,6 +73,9 @@ func EnsureConfigFile(configPath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("读取嵌入配置文件失败: %w", err)
 	}
+	if content, err = load.Convert(configFilePath, content); err != nil {
+		return "", fmt.Errorf("转换默认配置格式失败: %w", err)
+	}
 
 	// 确保目录存在
 	if err := os.MkdirAll(configDir, 0755); err != nil {
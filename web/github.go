@@ -7,6 +7,7 @@ import (
 
 	"github.com/qist/tvgate/config"
 	"github.com/qist/tvgate/updater"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 // 注册 GitHub 升级接口
@@ -22,6 +23,7 @@ func RegisterGithubRoutes(mux *http.ServeMux, webPath string, cookieAuth func(ht
 	mux.HandleFunc(githubPath+"releases", cookieAuth(handleGithubReleases))
 	mux.HandleFunc(githubPath+"update", cookieAuth(handleGithubUpdate))
 	mux.HandleFunc(githubPath+"status", cookieAuth(handleGithubStatus))
+	mux.HandleFunc(githubPath+"latest", cookieAuth(handleGithubLatest))
 }
 
 // 获取 GitHub Releases 列表
@@ -29,7 +31,7 @@ func handleGithubReleases(w http.ResponseWriter, r *http.Request) {
 	cfg := config.Cfg.Github
 	releases, err := updater.FetchGithubReleases(cfg)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取版本列表失败: %v", err), http.StatusInternalServerError)
+		httperror.Error(w, r, fmt.Sprintf("获取版本列表失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -42,7 +44,7 @@ func handleGithubUpdate(w http.ResponseWriter, r *http.Request) {
 		Version string `json:"version"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == "" {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		httperror.Error(w, r, "请求参数错误", http.StatusBadRequest)
 		return
 	}
 
@@ -66,3 +68,16 @@ func handleGithubStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
+
+// 获取最新版本检查结果，refresh=1 时立即检查
+func handleGithubLatest(w http.ResponseWriter, r *http.Request) {
+	info := updater.LatestInfo()
+	if r.URL.Query().Get("refresh") == "1" {
+		config.CfgMu.RLock()
+		cfg := config.Cfg.Github
+		config.CfgMu.RUnlock()
+		info = updater.CheckLatest(cfg)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
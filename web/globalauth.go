@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,7 +25,7 @@ func (h *ConfigHandler) handleGlobalAuthEditor(w http.ResponseWriter, r *http.Re
 	}
 
 	if err := h.renderTemplate(w, r, "global_auth_editor", "templates/global_auth_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -46,17 +48,26 @@ func (h *ConfigHandler) handleGlobalAuthConfig(w http.ResponseWriter, r *http.Re
 			"dynamic_ttl":    formatDuration(globalAuth.DynamicTokens.DynamicTTL),
 			"secret":         globalAuth.DynamicTokens.Secret,
 			"salt":           globalAuth.DynamicTokens.Salt,
+			"allow":          globalAuth.DynamicTokens.Allow,
+			"deny":           globalAuth.DynamicTokens.Deny,
 		},
 		"static_tokens": map[string]interface{}{
 			"enable_static": globalAuth.StaticTokens.EnableStatic,
 			"token":         globalAuth.StaticTokens.Token,
 			"expire_hours":  formatDuration(globalAuth.StaticTokens.ExpireHours),
+			"allow":         globalAuth.StaticTokens.Allow,
+			"deny":          globalAuth.StaticTokens.Deny,
 		},
+		"jwt_tokens":           jwtTokensMap(globalAuth.JWTTokens),
+		"signed_url":           signedURLMap(globalAuth.SignedURL),
+		"max_sessions":         globalAuth.MaxSessions,
+		"max_sessions_per_ip":  globalAuth.MaxSessionsPerIP,
+		"session_limit_action": globalAuth.SessionLimitAction,
 	}
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(authConfig); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -66,14 +77,14 @@ func (h *ConfigHandler) handleGlobalAuthConfig(w http.ResponseWriter, r *http.Re
 func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -81,7 +92,7 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 	// 解析JSON数据
 	var authConfig map[string]interface{}
 	if err := json.Unmarshal(body, &authConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -89,14 +100,14 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -162,6 +173,8 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 									&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", salt)})
 							}
 
+							appendACLNodes(dtNode, dtMap)
+
 							if len(dtNode.Content) > 0 {
 								newAuthNode.Content = append(newAuthNode.Content,
 									&yaml.Node{Kind: yaml.ScalarNode, Value: "dynamic_tokens"},
@@ -200,6 +213,8 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 								}
 							}
 
+							appendACLNodes(stNode, stMap)
+
 							if len(stNode.Content) > 0 {
 								newAuthNode.Content = append(newAuthNode.Content,
 									&yaml.Node{Kind: yaml.ScalarNode, Value: "static_tokens"},
@@ -208,6 +223,23 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 						}
 					}
 
+					// 添加会话数限制
+					appendSessionLimitNodes(newAuthNode, authConfig)
+
+					// 添加jwt_tokens
+					if jtNode := jwtTokensNode(authConfig["jwt_tokens"]); jtNode != nil {
+						newAuthNode.Content = append(newAuthNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "jwt_tokens"},
+							jtNode)
+					}
+
+					// 添加signed_url
+					if suNode := signedURLNode(authConfig["signed_url"]); suNode != nil {
+						newAuthNode.Content = append(newAuthNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "signed_url"},
+							suNode)
+					}
+
 					// 替换global_auth节点
 					doc.Content[i+1] = newAuthNode
 					globalAuthFound = true
@@ -225,16 +257,16 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 	}
 
 	// 序列化为YAML格式
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -242,7 +274,7 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		// 恢复备份文件
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -251,3 +283,119 @@ func (h *ConfigHandler) handleGlobalAuthConfigSave(w http.ResponseWriter, r *htt
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("配置保存成功"))
 }
+
+// jwtTokensMap 将 JWT 配置转换为编辑器使用的 JSON 格式
+func jwtTokensMap(jt config.JWTToken) map[string]interface{} {
+	return map[string]interface{}{
+		"enable_jwt":      jt.EnableJWT,
+		"algorithm":       jt.Algorithm,
+		"secret":          jt.Secret,
+		"public_key_file": jt.PublicKeyFile,
+		"issuer":          jt.Issuer,
+		"audience":        jt.Audience,
+		"leeway":          formatDuration(jt.Leeway),
+	}
+}
+
+// jwtTokensNode 根据编辑器提交的 jwt_tokens 生成 YAML 节点，未配置时返回 nil
+func jwtTokensNode(value interface{}) *yaml.Node {
+	jtMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	if enableJWT, ok := jtMap["enable_jwt"]; ok {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "enable_jwt"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", enableJWT)})
+	}
+	for _, key := range []string{"algorithm", "secret", "public_key_file", "issuer", "audience"} {
+		if v, ok := jtMap[key]; ok && v != nil && v != "" {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)})
+		}
+	}
+	if leeway, ok := jtMap["leeway"].(string); ok && leeway != "" {
+		formatted := formatDurationString(leeway)
+		if _, err := time.ParseDuration(formatted); err == nil {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "leeway"},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: formatted})
+		}
+	}
+	if len(node.Content) == 0 {
+		return nil
+	}
+	return node
+}
+
+// signedURLMap 将签名 URL 配置转换为编辑器使用的 JSON 格式
+func signedURLMap(su config.SignedURL) map[string]interface{} {
+	return map[string]interface{}{
+		"enable_signed": su.EnableSigned,
+		"secret":        su.Secret,
+		"sig_param":     su.SigParam,
+		"expires_param": su.ExpiresParam,
+		"bind_ip":       su.BindIP,
+	}
+}
+
+// signedURLNode 根据编辑器提交的 signed_url 生成 YAML 节点，未配置时返回 nil
+func signedURLNode(value interface{}) *yaml.Node {
+	suMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range []string{"enable_signed", "bind_ip"} {
+		if v, ok := suMap[key]; ok && v != nil {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)})
+		}
+	}
+	for _, key := range []string{"secret", "sig_param", "expires_param"} {
+		if v, ok := suMap[key]; ok && v != nil && v != "" {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)})
+		}
+	}
+	if len(node.Content) == 0 {
+		return nil
+	}
+	return node
+}
+
+// appendACLNodes 将编辑器提交的 allow/deny 列表追加到 token 配置节点
+func appendACLNodes(node *yaml.Node, tokenMap map[string]interface{}) {
+	for _, key := range []string{"allow", "deny"} {
+		list, ok := tokenMap[key].([]interface{})
+		if !ok {
+			continue
+		}
+		listNode := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, v := range list {
+			if s := fmt.Sprintf("%v", v); s != "" {
+				listNode.Content = append(listNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s})
+			}
+		}
+		if len(listNode.Content) > 0 {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				listNode)
+		}
+	}
+}
+
+// appendSessionLimitNodes 将编辑器提交的会话数限制配置追加到 auth 节点
+func appendSessionLimitNodes(node *yaml.Node, authMap map[string]interface{}) {
+	for _, key := range []string{"max_sessions", "max_sessions_per_ip", "session_limit_action"} {
+		if v, ok := authMap[key]; ok && v != nil && v != "" && v != float64(0) {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)})
+		}
+	}
+}
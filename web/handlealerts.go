@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/qist/tvgate/alert"
+	"github.com/qist/tvgate/config"
+)
+
+// handleAlerts 当前存在的异常，firing 为 true 的已发送告警，其余尚未达到 for 持续时间
+func (h *ConfigHandler) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	config.CfgMu.RLock()
+	enabled := config.Cfg.Alert.Enabled
+	config.CfgMu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": enabled,
+		"alerts":  alert.Default.Active(),
+	})
+}
+
+// handleAlertTest 向所有通知方式发送测试消息，返回各通知方式的发送结果
+func (h *ConfigHandler) handleAlertTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	config.CfgMu.RLock()
+	sinks := config.Cfg.Alert.Sinks
+	config.CfgMu.RUnlock()
+	if len(sinks) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "未配置告警通知方式")
+		return
+	}
+	errs := alert.Default.Test()
+	results := make(map[string]string, len(errs))
+	for name, err := range errs {
+		results[name] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sent":   len(sinks) - len(errs),
+		"failed": results,
+	})
+}
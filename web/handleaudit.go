@@ -0,0 +1,74 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/qist/tvgate/audit"
+)
+
+// parseAuditTime 支持 RFC3339 时间或相对时长（如 24h 表示 24 小时前）
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(formatDurationString(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("时间格式错误: %s", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// handleAudit 查询审计事件，参数 token ip channel type since until limit
+func (h *ConfigHandler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	q := r.URL.Query()
+	f := audit.Filter{
+		Token:   q.Get("token"),
+		IP:      q.Get("ip"),
+		Channel: q.Get("channel"),
+		Type:    q.Get("type"),
+	}
+	var err error
+	if f.Since, err = parseAuditTime(q.Get("since")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.Until, err = parseAuditTime(q.Get("until")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if f.Limit, err = strconv.Atoi(limit); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "limit 格式错误: "+limit)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, audit.Default.Query(f))
+}
+
+// handleAuditSummary 按 token 汇总，参数 since 默认 24h
+func (h *ConfigHandler) handleAuditSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	sinceValue := r.URL.Query().Get("since")
+	if sinceValue == "" {
+		sinceValue = "24h"
+	}
+	since, err := parseAuditTime(sinceValue)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, audit.Default.Summary(since))
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/qist/tvgate/bruteforce"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// rejectBanned IP 处于封禁中时返回 429 并写出 Retry-After
+func rejectBanned(w http.ResponseWriter, r *http.Request, clientIP string) bool {
+	remaining, banned := bruteforce.Banned(clientIP)
+	if !banned {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Round(time.Second)/time.Second)))
+	httperror.Error(w, r, "失败次数过多，请稍后再试", http.StatusTooManyRequests)
+	return true
+}
+
+// handleBans 列出当前被封禁的 IP
+func (h *ConfigHandler) handleBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeJSON(w, http.StatusOK, bruteforce.Default.Bans())
+}
+
+// handleUnban 解除 IP 封禁
+func (h *ConfigHandler) handleUnban(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req struct {
+		IP string `json:"ip"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	if !bruteforce.Default.Unban(req.IP) {
+		writeJSONError(w, http.StatusNotFound, "该 IP 未被封禁")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"ip": req.IP})
+}
@@ -0,0 +1,397 @@
+package web
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/check"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/quota"
+	"github.com/qist/tvgate/stats"
+)
+
+// maxBundleBody 导入的备份包大小上限
+const maxBundleBody = 512 << 20
+
+// bundleManifest 备份包中的 manifest.json
+type bundleManifest struct {
+	Version   string    `json:"version"`    // 导出时的程序版本
+	CreatedAt time.Time `json:"created_at"` // 导出时间
+	Config    string    `json:"config"`     // 主配置文件名，位于 config/ 下
+	Files     []string  `json:"files"`      // 包内的其他文件
+}
+
+// bundlePart 备份包中 data/ 下的一项运行数据。export 返回 errSkipPart 时不导出该项；
+// restore 的 cfg 为即将生效的新配置
+type bundlePart struct {
+	name    string
+	desc    string
+	export  func(w io.Writer) error
+	restore func(r io.Reader, cfg *config.Config) (string, error)
+}
+
+// errSkipPart 该项数据未启用或为空，不写入备份包
+var errSkipPart = errors.New("skip")
+
+// bundleParts 备份包包含的运行数据
+var bundleParts = []bundlePart{
+	{
+		name: "tokens.json",
+		desc: "签发的 token",
+		export: func(w io.Writer) error {
+			tm := auth.GetGlobalTokenManager()
+			if tm == nil || !tm.HasIssuedTokens() {
+				return errSkipPart
+			}
+			return json.NewEncoder(w).Encode(tm.IssuedTokens())
+		},
+		restore: func(r io.Reader, _ *config.Config) (string, error) {
+			var list []auth.IssuedToken
+			if err := json.NewDecoder(r).Decode(&list); err != nil {
+				return "", err
+			}
+			tm := auth.GetGlobalTokenManager()
+			if tm == nil {
+				return "", errors.New("全局认证未初始化")
+			}
+			return fmt.Sprintf("%d/%d 个", tm.RestoreIssuedTokens(list), len(list)), nil
+		},
+	},
+	{
+		name: "token_quota.json",
+		desc: "token 流量统计",
+		export: func(w io.Writer) error {
+			list := quota.Default.Usages()
+			if len(list) == 0 {
+				return errSkipPart
+			}
+			return json.NewEncoder(w).Encode(list)
+		},
+		restore: func(r io.Reader, _ *config.Config) (string, error) {
+			var list []quota.Usage
+			if err := json.NewDecoder(r).Decode(&list); err != nil {
+				return "", err
+			}
+			n := quota.Default.Restore(list)
+			quota.Default.Save()
+			return fmt.Sprintf("%d 个", n), nil
+		},
+	},
+	{
+		name: "stats.db",
+		desc: "历史统计数据库",
+		export: func(w io.Writer) error {
+			if err := stats.Default.Backup(w); !errors.Is(err, stats.ErrDisabled) {
+				return err
+			}
+			return errSkipPart
+		},
+		restore: func(r io.Reader, cfg *config.Config) (string, error) {
+			file := stats.FilePath(cfg.Stats)
+			return file, stats.Default.Restore(file, r)
+		},
+	},
+	// 录像索引等其他运行数据在此注册
+}
+
+// bundleConfigFiles 返回主配置文件及位于配置目录下的引入文件，键为相对配置目录的路径
+func bundleConfigFiles() (string, map[string]string) {
+	configPath, _ := filepath.Abs(*config.ConfigFilePath)
+	dir := filepath.Dir(configPath)
+	mainName := filepath.Base(configPath)
+	files := map[string]string{mainName: configPath}
+	included, _ := load.Included()
+	for _, f := range included {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			// 配置目录外的文件不打包，导入时按原路径引用
+			continue
+		}
+		files[filepath.ToSlash(rel)] = f
+	}
+	return mainName, files
+}
+
+// handleBundleExport 下载备份包（tar.gz）：config/ 下为主配置及引入文件，data/ 下为签发的 token、
+// token 流量统计与历史统计数据库，manifest.json 记录包内容
+func (h *ConfigHandler) handleBundleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	// 运行数据先导出到临时文件，出错时仍可返回 JSON 错误
+	type entry struct {
+		name string
+		file string
+		data []byte
+	}
+	var entries []entry
+	mainName, files := bundleConfigFiles()
+	manifest := bundleManifest{Version: config.Version, CreatedAt: time.Now(), Config: mainName}
+	for rel, f := range files {
+		entries = append(entries, entry{name: "config/" + rel, file: f})
+	}
+	for _, p := range bundleParts {
+		tmp, err := os.CreateTemp("", "tvgate-bundle-*")
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "创建临时文件失败: "+err.Error())
+			return
+		}
+		defer os.Remove(tmp.Name())
+		err = p.export(tmp)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if errors.Is(err, errSkipPart) {
+			continue
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("导出%s失败: %v", p.desc, err))
+			return
+		}
+		entries = append(entries, entry{name: "data/" + p.name, file: tmp.Name()})
+	}
+	for _, e := range entries {
+		manifest.Files = append(manifest.Files, e.name)
+	}
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+	entries = append([]entry{{name: "manifest.json", data: manifestData}}, entries...)
+
+	filename := "tvgate-backup-" + manifest.CreatedAt.Format("20060102150405") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if err := writeBundleEntry(tw, e.name, e.file, e.data, manifest.CreatedAt); err != nil {
+			// 响应头已发送，只能中断
+			logger.LogPrintf("❌ 导出备份包失败: %s: %v", e.name, err)
+			return
+		}
+	}
+	tw.Close()
+	gz.Close()
+	logger.LogPrintf("📦 Web 导出备份包: %d 个文件", len(entries))
+}
+
+// writeBundleEntry 写入一个文件，data 为 nil 时读取 file
+func writeBundleEntry(tw *tar.Writer, name, file string, data []byte, modTime time.Time) error {
+	var src io.Reader = bytes.NewReader(data)
+	size := int64(len(data))
+	if data == nil {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		src, size, modTime = f, info.Size(), info.ModTime()
+	}
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.CopyN(tw, src, size)
+	return err
+}
+
+// extractBundle 将备份包解压到 dir，只接受 manifest.json 及 config/、data/ 下的普通文件
+func extractBundle(r io.Reader, dir string) (*bundleManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份包: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *bundleManifest
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取备份包失败: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(name) || strings.HasPrefix(name, "../") ||
+			(name != "manifest.json" && !strings.HasPrefix(name, "config/") && !strings.HasPrefix(name, "data/")) {
+			return nil, fmt.Errorf("备份包包含不允许的文件: %s", hdr.Name)
+		}
+		if name == "manifest.json" {
+			manifest = &bundleManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("解析 manifest.json 失败: %w", err)
+			}
+			continue
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解压 %s 失败: %w", name, err)
+		}
+	}
+	if manifest == nil || manifest.Config == "" || strings.ContainsAny(manifest.Config, `/\`) {
+		return nil, errors.New("备份包缺少 manifest.json 或主配置文件")
+	}
+	return manifest, nil
+}
+
+// handleBundleImport 从备份包恢复：请求体为导出的 tar.gz。先解压到配置目录下的临时目录并校验配置，
+// 校验通过后恢复运行数据和引入文件，最后写入主配置并重新加载；被覆盖的配置文件均先备份
+func (h *ConfigHandler) handleBundleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	configPath, _ := filepath.Abs(*config.ConfigFilePath)
+	dir := filepath.Dir(configPath)
+	// 临时目录放在配置目录下，使引入文件的相对路径按实际位置解析
+	tmpDir, err := os.MkdirTemp(dir, ".restore-")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "创建临时目录失败: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := extractBundle(http.MaxBytesReader(w, r.Body, maxBundleBody), tmpDir)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filepath.Ext(manifest.Config) != filepath.Ext(configPath) {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("备份中的配置文件格式(%s)与当前配置文件(%s)不一致",
+			manifest.Config, filepath.Base(configPath)))
+		return
+	}
+
+	// 在临时目录中校验，引入文件使用备份包中的版本
+	configDir := filepath.Join(tmpDir, "config")
+	mainFile := filepath.Join(configDir, manifest.Config)
+	content, err := os.ReadFile(mainFile)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "备份包缺少主配置文件: "+manifest.Config)
+		return
+	}
+	issues, err := check.File(mainFile)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// 问题位置改为相对配置目录的路径，主配置文件为空
+	for i := range issues {
+		if issues[i].File == mainFile {
+			issues[i].File = ""
+		} else if rel, err := filepath.Rel(configDir, issues[i].File); err == nil && !strings.HasPrefix(rel, "..") {
+			issues[i].File = filepath.ToSlash(rel)
+		}
+	}
+	if check.HasErrors(issues) {
+		writeJSON(w, http.StatusUnprocessableEntity, &configResult{Valid: false, Issues: issues})
+		return
+	}
+	var newCfg config.Config
+	if err := load.ParseFile(mainFile, &newCfg); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	newCfg.SetDefaults()
+
+	// 运行数据在写入配置之前恢复，重新加载时新的 token 管理器会保留已恢复的 token
+	restored := make(map[string]string)
+	failed := make(map[string]string)
+	for _, p := range bundleParts {
+		f, err := os.Open(filepath.Join(tmpDir, "data", p.name))
+		if err != nil {
+			continue
+		}
+		detail, err := p.restore(f, &newCfg)
+		f.Close()
+		if err != nil {
+			failed[p.desc] = err.Error()
+			logger.LogPrintf("❌ 恢复%s失败: %v", p.desc, err)
+			continue
+		}
+		restored[p.desc] = detail
+	}
+
+	// 引入文件：覆盖前备份原文件
+	stamp := time.Now().Format("20060102150405")
+	err = filepath.Walk(configDir, func(src string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || src == mainFile {
+			return err
+		}
+		rel, _ := filepath.Rel(configDir, src)
+		dest := filepath.Join(dir, rel)
+		if _, err := os.Stat(dest); err == nil {
+			if err := copyFile(dest, dest+".backup."+stamp); err != nil {
+				return fmt.Errorf("备份 %s 失败: %w", rel, err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		if err := copyFile(src, dest); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", rel, err)
+		}
+		restored["config/"+filepath.ToSlash(rel)] = dest
+		return nil
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := saveConfigFile(content, "restore"); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	restored["config/"+manifest.Config] = configPath
+	logger.LogPrintf("📦 Web 导入备份包: 版本 %s, 导出于 %s, 恢复 %d 项",
+		manifest.Version, manifest.CreatedAt.Format(time.RFC3339), len(restored))
+
+	if issues == nil {
+		issues = []check.Issue{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":    manifest.Version,
+		"created_at": manifest.CreatedAt,
+		"restored":   restored,
+		"failed":     failed,
+		"issues":     issues,
+	})
+}
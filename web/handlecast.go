@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/qist/tvgate/cast"
+	"github.com/qist/tvgate/config"
+)
+
+// castRequest 投屏接口请求体
+type castRequest struct {
+	ID     string   `json:"id"`     // 会话 ID，停止与调节音量时使用
+	Device string   `json:"device"` // 设备 ID，开始投屏时使用
+	Src    string   `json:"src"`    // 频道，与播放器中的频道相同
+	Title  string   `json:"title"`
+	Volume *float64 `json:"volume"` // 0 到 1
+}
+
+func decodeCastRequest(w http.ResponseWriter, r *http.Request) (*castRequest, bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return nil, false
+	}
+	var req castRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return nil, false
+	}
+	return &req, true
+}
+
+// castContext 与设备通信的超时
+func castContext(r *http.Request) (context.Context, context.CancelFunc) {
+	config.CfgMu.RLock()
+	timeout := config.Cfg.Cast.Timeout + config.Cfg.Cast.Discovery
+	config.CfgMu.RUnlock()
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+func writeCastError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cast.ErrDisabled):
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, cast.ErrNoDevice), errors.Is(err, cast.ErrNoSession):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	default:
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+// handleCastDevices 搜索局域网内的 Chromecast 与 AirPlay 设备
+func (h *ConfigHandler) handleCastDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	ctx, cancel := castContext(r)
+	defer cancel()
+	list, err := cast.Default.Devices(ctx)
+	if err != nil {
+		writeCastError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleCastSessions GET 列出正在进行的投屏，POST 开始投屏
+func (h *ConfigHandler) handleCastSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writePage(w, r, cast.Default.Sessions())
+		return
+	}
+	req, ok := decodeCastRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := castContext(r)
+	defer cancel()
+	sess, err := cast.Default.Start(ctx, req.Device, req.Src, req.Title)
+	if err != nil {
+		writeCastError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+// handleCastStop 停止投屏
+func (h *ConfigHandler) handleCastStop(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeCastRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := castContext(r)
+	defer cancel()
+	if err := cast.Default.Stop(ctx, req.ID); err != nil {
+		writeCastError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已停止投屏"})
+}
+
+// handleCastVolume 调节设备音量
+func (h *ConfigHandler) handleCastVolume(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeCastRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Volume == nil || *req.Volume < 0 || *req.Volume > 1 {
+		writeJSONError(w, http.StatusBadRequest, "音量应在 0 到 1 之间")
+		return
+	}
+	ctx, cancel := castContext(r)
+	defer cancel()
+	if err := cast.Default.SetVolume(ctx, req.ID, *req.Volume); err != nil {
+		writeCastError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"volume": *req.Volume})
+}
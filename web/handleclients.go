@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/qist/tvgate/bruteforce"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/monitor"
+)
+
+// defaultKickBanDuration 踢出并封禁时未指定时长的默认封禁时长
+const defaultKickBanDuration = time.Hour
+
+// handleClientsPage 渲染在线客户端页面
+func (h *ConfigHandler) handleClientsPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":   "在线客户端",
+		"webPath": h.getWebPath(),
+	}
+	h.renderTemplate(w, r, "clients", "templates/clients.html", data)
+}
+
+// handleClients 列出当前连接的客户端
+func (h *ConfigHandler) handleClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeJSON(w, http.StatusOK, monitor.ActiveClients.List())
+}
+
+// handleKickClient 断开客户端连接。指定 id 时断开单个连接，指定 ip 时断开该 IP 的所有连接；
+// ban 为 true 时同时封禁该 IP，duration 为封禁时长，默认 1h
+func (h *ConfigHandler) handleKickClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req struct {
+		ID       string `json:"id"`
+		IP       string `json:"ip"`
+		Ban      bool   `json:"ban"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+
+	ip := req.IP
+	if req.ID != "" {
+		conn := monitor.ActiveClients.GetConnectionByID(req.ID)
+		if conn == nil {
+			writeJSONError(w, http.StatusNotFound, "连接不存在或已断开")
+			return
+		}
+		ip = conn.IP
+	}
+	if ip == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少 id 或 ip")
+		return
+	}
+
+	// 先封禁，避免客户端在断开后立即重连
+	if req.Ban {
+		duration := defaultKickBanDuration
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "duration 格式错误: "+err.Error())
+				return
+			}
+			duration = d
+		}
+		if err := bruteforce.BanIP(ip, duration); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	kicked := 0
+	if req.ID != "" && !req.Ban {
+		if monitor.ActiveClients.Kick(req.ID) {
+			kicked = 1
+		}
+	} else {
+		kicked = monitor.ActiveClients.KickIP(ip)
+	}
+	logger.LogPrintf("🚫 Web 断开客户端: %s, 连接数: %d, 封禁: %v", ip, kicked, req.Ban)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ip":     ip,
+		"kicked": kicked,
+		"banned": req.Ban,
+	})
+}
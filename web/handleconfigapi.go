@@ -0,0 +1,355 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/check"
+	"github.com/qist/tvgate/config/history"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/config/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置接口请求体大小上限
+const maxConfigBody = 1 << 20
+
+// configResult 写入或校验配置的结果
+type configResult struct {
+	Valid   bool          `json:"valid"`
+	Changed []string      `json:"changed,omitempty"` // 与当前运行配置相比变化的配置项
+	Issues  []check.Issue `json:"issues"`
+}
+
+// handleConfigAPI GET 返回配置，PATCH 按配置项合并修改
+//
+//	GET   参数 section=server,web 只返回指定配置项；effective=1 返回生效中的配置（含默认值，引用已替换）
+//	PATCH 请求体为 JSON 对象，键为顶层配置项，按 JSON Merge Patch 合并，值为 null 删除该键
+func (h *ConfigHandler) handleConfigAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.handleConfigAPIGet(w, r)
+	case http.MethodPatch:
+		h.handleConfigAPIPatch(w, r)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+	}
+}
+
+func (h *ConfigHandler) handleConfigAPIGet(w http.ResponseWriter, r *http.Request) {
+	var value interface{}
+	if r.URL.Query().Get("effective") == "1" {
+		config.CfgMu.RLock()
+		data, err := yaml.Marshal(&config.Cfg)
+		config.CfgMu.RUnlock()
+		if err == nil {
+			err = yaml.Unmarshal(data, &value)
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "序列化配置失败: "+err.Error())
+			return
+		}
+	} else {
+		// 合并 includes 后的配置内容，${...} 引用保持原样，不会泄露其中的密钥
+		doc, _, err := load.ReadDocument(*config.ConfigFilePath)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "读取配置文件失败: "+err.Error())
+			return
+		}
+		if len(doc.Content) > 0 {
+			if err := doc.Content[0].Decode(&value); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "解析配置文件失败: "+err.Error())
+				return
+			}
+		}
+	}
+
+	all, _ := value.(map[string]interface{})
+	if all == nil {
+		all = map[string]interface{}{}
+	}
+	if s := r.URL.Query().Get("section"); s != "" {
+		part := make(map[string]interface{})
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				if v, ok := all[name]; ok {
+					part[name] = v
+				}
+			}
+		}
+		all = part
+	}
+	writeJSON(w, http.StatusOK, all)
+}
+
+func (h *ConfigHandler) handleConfigAPIPatch(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConfigBody))
+	dec.UseNumber()
+	var patch map[string]interface{}
+	if err := dec.Decode(&patch); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	if len(patch) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "未指定要修改的配置项")
+		return
+	}
+
+	configPath := *config.ConfigFilePath
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "读取配置文件失败: "+err.Error())
+		return
+	}
+	var doc yaml.Node
+	if err := load.UnmarshalNode(configPath, data, &doc); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "解析配置文件失败: "+err.Error())
+		return
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		writeJSONError(w, http.StatusInternalServerError, "配置文件顶层不是键值映射")
+		return
+	}
+	mergePatch(root, patch)
+
+	content, err := load.MarshalNode(configPath, &doc)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "序列化配置失败: "+err.Error())
+		return
+	}
+	h.writeConfigResult(w, content, apiSource(r))
+}
+
+// handleConfigAPIValidate 校验完整配置（与配置文件格式相同，YAML 配置也接受 JSON），不写入文件
+func (h *ConfigHandler) handleConfigAPIValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "读取请求体失败: "+err.Error())
+		return
+	}
+	var result *configResult
+	if h.apiRole(r) == RoleAdmin {
+		result, err = validateConfig(content)
+	} else {
+		result = validateUntrusted(content)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleConfigAPIApply 校验并写入完整配置（与配置文件格式相同，YAML 配置也接受 JSON），随后立即重新加载
+func (h *ConfigHandler) handleConfigAPIApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "读取请求体失败: "+err.Error())
+		return
+	}
+	h.writeConfigResult(w, content, apiSource(r))
+}
+
+// writeConfigResult 校验配置，没有错误时备份并写入配置文件，返回校验结果
+func (h *ConfigHandler) writeConfigResult(w http.ResponseWriter, content []byte, source string) {
+	result, err := validateConfig(content)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !result.Valid {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	if err := saveConfigFile(content, source); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// validateConfig 在配置文件所在目录写入临时文件后校验，使 includes 与相对路径按实际位置解析
+func validateConfig(content []byte) (*configResult, error) {
+	configPath := *config.ConfigFilePath
+	// 临时文件保持扩展名，按配置文件格式解析
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ".config-validate-*"+filepath.Ext(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	issues, err := check.File(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	// 问题位置指向主配置文件而不是临时文件
+	for i := range issues {
+		if issues[i].File == tmp.Name() {
+			issues[i].File = ""
+		}
+	}
+	result := &configResult{Valid: !check.HasErrors(issues), Issues: issues}
+	if result.Issues == nil {
+		result.Issues = []check.Issue{}
+	}
+
+	if result.Valid {
+		var newCfg config.Config
+		if err := load.ParseFile(tmp.Name(), &newCfg); err == nil {
+			newCfg.SetDefaults()
+			config.CfgMu.RLock()
+			result.Changed = config.ChangedSections(&config.Cfg, &newCfg)
+			config.CfgMu.RUnlock()
+		}
+	}
+	return result, nil
+}
+
+// validateUntrusted 校验非 admin 提交的配置，不处理 includes 与 ${...} 引用，也不比较变更的配置项
+func validateUntrusted(content []byte) *configResult {
+	issues := check.Untrusted(*config.ConfigFilePath, content)
+	if issues == nil {
+		issues = []check.Issue{}
+	}
+	return &configResult{Valid: !check.HasErrors(issues), Issues: issues}
+}
+
+// saveConfigFile 备份当前配置后写入新内容（先写临时文件再重命名），并请求立即重新加载；
+// source 记录到配置版本历史中
+func saveConfigFile(content []byte, source string) error {
+	configPath := *config.ConfigFilePath
+	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
+	if err := copyFile(configPath, backupPath); err != nil {
+		return fmt.Errorf("创建备份文件失败: %w", err)
+	}
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("写入配置失败: %w", err)
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		// 单文件挂载（如 Docker）时无法重命名，直接覆盖写入
+		os.Remove(tmp)
+		if err := os.WriteFile(configPath, content, 0644); err != nil {
+			return fmt.Errorf("写入配置失败: %w", err)
+		}
+	}
+	history.Default.SetNextSource(source)
+	config.RequestReload()
+	return nil
+}
+
+// 配置 Schema 由结构体生成，运行期间不变，只生成一次
+var (
+	schemaOnce sync.Once
+	schemaData []byte
+	schemaErr  error
+)
+
+// handleConfigSchema 返回配置文件的 JSON Schema
+func (h *ConfigHandler) handleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	schemaOnce.Do(func() { schemaData, schemaErr = schema.JSON() })
+	if schemaErr != nil {
+		writeJSONError(w, http.StatusInternalServerError, "生成配置 Schema 失败: "+schemaErr.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(schemaData)
+}
+
+// mergePatch 按 JSON Merge Patch（RFC 7386）将 patch 合并到映射节点，保留其余内容和注释
+func mergePatch(m *yaml.Node, patch map[string]interface{}) {
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patch[key]
+		idx := -1
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if m.Content[i].Value == key {
+				idx = i
+				break
+			}
+		}
+		if value == nil {
+			if idx >= 0 {
+				m.Content = append(m.Content[:idx], m.Content[idx+2:]...)
+			}
+			continue
+		}
+		if sub, ok := value.(map[string]interface{}); ok && idx >= 0 && m.Content[idx+1].Kind == yaml.MappingNode {
+			mergePatch(m.Content[idx+1], sub)
+			continue
+		}
+		node := valueNode(value)
+		if idx >= 0 {
+			// 保留原值的注释
+			old := m.Content[idx+1]
+			node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			m.Content[idx+1] = node
+		} else {
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+		}
+	}
+}
+
+// valueNode 将 JSON 值转换为 YAML 节点，对象中为 null 的键会被省略
+func valueNode(v interface{}) *yaml.Node {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		mergePatch(n, val)
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range val {
+			n.Content = append(n.Content, valueNode(item))
+		}
+		return n
+	case json.Number:
+		// 数字不加引号，解码时按目标字段类型解析
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: val.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(val)}
+	case string:
+		n := &yaml.Node{}
+		n.SetString(val)
+		return n
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
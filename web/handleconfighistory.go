@@ -0,0 +1,131 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/history"
+)
+
+// handleConfigHistory 列出配置版本，按时间从新到旧
+func (h *ConfigHandler) handleConfigHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeJSON(w, http.StatusOK, history.Default.List())
+}
+
+// handleConfigHistoryGet 返回指定版本的配置内容
+func (h *ConfigHandler) handleConfigHistoryGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	_, data, ok := historyVersion(w, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Write(data)
+}
+
+// handleConfigHistoryDiff 比较两个版本，参数 from 为空时取 to 的上一版本，to 为空时与当前配置文件比较
+func (h *ConfigHandler) handleConfigHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	fromID, toID := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromID == "" && toID == "" {
+		writeJSONError(w, http.StatusBadRequest, "from 与 to 至少指定一个")
+		return
+	}
+
+	var toData []byte
+	toName := "current"
+	if toID == "" {
+		data, err := os.ReadFile(*config.ConfigFilePath)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "读取配置文件失败: "+err.Error())
+			return
+		}
+		toData = data
+	} else {
+		_, data, ok := historyVersion(w, toID)
+		if !ok {
+			return
+		}
+		toData, toName = data, toID
+	}
+
+	var fromData []byte
+	if fromID == "" {
+		// 第一个版本没有上一版本，与空内容比较
+		v, data, err := history.Default.Previous(toID)
+		if err != nil && !errors.Is(err, history.ErrNotFound) {
+			writeJSONError(w, http.StatusInternalServerError, "读取配置版本失败: "+err.Error())
+			return
+		}
+		fromData, fromID = data, v.ID
+		if fromID == "" {
+			fromID = "/dev/null"
+		}
+	} else {
+		_, data, ok := historyVersion(w, fromID)
+		if !ok {
+			return
+		}
+		fromData = data
+	}
+
+	added, removed := history.CountChanges(fromData, toData)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    fromID,
+		"to":      toName,
+		"added":   added,
+		"removed": removed,
+		"diff":    history.Diff(fromData, toData, fromID, toName),
+	})
+}
+
+// handleConfigHistoryRollback 校验指定版本后写回配置文件并立即重新加载
+func (h *ConfigHandler) handleConfigHistoryRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	_, data, ok := historyVersion(w, req.ID)
+	if !ok {
+		return
+	}
+	h.writeConfigResult(w, data, "rollback:"+req.ID)
+}
+
+// historyVersion 读取版本内容，失败时写出错误
+func historyVersion(w http.ResponseWriter, id string) (history.Version, []byte, bool) {
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "id 不能为空")
+		return history.Version{}, nil, false
+	}
+	v, data, err := history.Default.Get(id)
+	if errors.Is(err, history.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return v, nil, false
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "读取配置版本失败: "+err.Error())
+		return v, nil, false
+	}
+	return v, data, true
+}
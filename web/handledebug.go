@@ -0,0 +1,96 @@
+package web
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+)
+
+func init() {
+	expvar.Publish("tvgate", expvar.Func(func() any {
+		return map[string]any{
+			"version":    config.Version,
+			"start_time": config.StartTime,
+			"uptime":     time.Since(config.StartTime).Round(time.Second).String(),
+			"goroutines": runtime.NumGoroutine(),
+		}
+	}))
+}
+
+var debugRates struct {
+	block, mutex int
+}
+
+// ApplyDebug 按配置设置阻塞与锁竞争的采样率，诊断接口关闭时一并关闭采样
+func ApplyDebug(cfg config.DebugConfig) {
+	block, mutex := 0, 0
+	if cfg.Enabled {
+		block, mutex = cfg.BlockProfileRate, cfg.MutexProfileFraction
+	}
+	if block != debugRates.block {
+		runtime.SetBlockProfileRate(block)
+	}
+	if mutex != debugRates.mutex {
+		runtime.SetMutexProfileFraction(mutex)
+	}
+	if (block > 0 || mutex > 0) && (block != debugRates.block || mutex != debugRates.mutex) {
+		logger.LogPrintf("🔧 已开启诊断采样: block_profile_rate=%d mutex_profile_fraction=%d", block, mutex)
+	}
+	debugRates.block, debugRates.mutex = block, mutex
+}
+
+// registerDebug 注册诊断接口：
+// debug/pprof/ 下为标准 pprof（可用 go tool pprof 分析下载的文件），debug/vars 为 expvar，debug/goroutines 为全部 goroutine 堆栈
+func (h *ConfigHandler) registerDebug(mux *http.ServeMux, webPath string) {
+	prefix := webPath + "debug/"
+	// pprof.Index 按 /debug/pprof/ 之后的部分识别分析类型，去掉 Web 路径前缀后交给它处理
+	strip := strings.TrimSuffix(webPath, "/")
+	mux.HandleFunc(prefix+"pprof/", h.debugAuth(http.StripPrefix(strip, http.HandlerFunc(pprof.Index)).ServeHTTP))
+	mux.HandleFunc(prefix+"pprof/cmdline", h.debugAuth(pprof.Cmdline))
+	mux.HandleFunc(prefix+"pprof/profile", h.debugAuth(pprof.Profile))
+	mux.HandleFunc(prefix+"pprof/symbol", h.debugAuth(pprof.Symbol))
+	mux.HandleFunc(prefix+"pprof/trace", h.debugAuth(pprof.Trace))
+	mux.HandleFunc(prefix+"vars", h.debugAuth(expvar.Handler().ServeHTTP))
+	mux.HandleFunc(prefix+"goroutines", h.debugAuth(handleGoroutineDump))
+}
+
+// debugAuth 诊断接口仅管理员可用，未开启 debug.enabled 时返回 404
+func (h *ConfigHandler) debugAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return h.apiAuth(RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		config.CfgMu.RLock()
+		enabled := config.Cfg.Debug.Enabled
+		config.CfgMu.RUnlock()
+		if !enabled {
+			writeJSONError(w, http.StatusNotFound, "未开启诊断接口（debug.enabled）")
+			return
+		}
+		logger.LogPrintf("🔧 诊断接口访问: %s", r.URL.Path)
+		handler(w, r)
+	})
+}
+
+// handleGoroutineDump 输出全部 goroutine 的堆栈，堆栈很多时按需扩大缓冲区
+func handleGoroutineDump(w http.ResponseWriter, r *http.Request) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64<<20 {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="goroutines-%s.txt"`, time.Now().Format("20060102-150405")))
+	}
+	fmt.Fprintf(w, "goroutines: %d\n\n", runtime.NumGoroutine())
+	w.Write(buf)
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +24,7 @@ func (h *ConfigHandler) handleDnsEditor(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.renderTemplate(w, r, "dns_editor", "templates/dns_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -45,7 +47,7 @@ func (h *ConfigHandler) handleDnsConfig(w http.ResponseWriter, r *http.Request)
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(dnsConfig); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -54,14 +56,14 @@ func (h *ConfigHandler) handleDnsConfig(w http.ResponseWriter, r *http.Request)
 func (h *ConfigHandler) handleDnsConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -69,7 +71,7 @@ func (h *ConfigHandler) handleDnsConfigSave(w http.ResponseWriter, r *http.Reque
 	// 解析JSON数据
 	var dnsConfig map[string]interface{}
 	if err := json.Unmarshal(body, &dnsConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -77,40 +79,40 @@ func (h *ConfigHandler) handleDnsConfigSave(w http.ResponseWriter, r *http.Reque
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 查找并更新dns配置节点
 	if err := updateDnsConfigNode(&fullNode, dnsConfig); err != nil {
-		http.Error(w, "更新配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "更新配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 序列化更新后的配置
-	updatedData, err := yaml.Marshal(&fullNode)
+	updatedData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 写入配置文件
 	if err := os.WriteFile(configPath, updatedData, 0644); err != nil {
-		http.Error(w, "写入配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -141,7 +143,7 @@ func (h *ConfigHandler) handleDnsConfigSave(w http.ResponseWriter, r *http.Reque
 		"message": "配置保存成功",
 	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "返回响应失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "返回响应失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
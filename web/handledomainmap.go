@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -28,7 +30,9 @@ func (h *ConfigHandler) handleDomainMapEditor(w http.ResponseWriter, r *http.Req
 			dm.Auth.DynamicTokens.Secret != "" ||
 			dm.Auth.DynamicTokens.Salt != "" ||
 			dm.Auth.StaticTokens.EnableStatic ||
-			dm.Auth.StaticTokens.Token != "" {
+			dm.Auth.StaticTokens.Token != "" ||
+			dm.Auth.JWTTokens.EnableJWT ||
+			dm.Auth.SignedURL.EnableSigned {
 			hasAuthConfig = true
 			break
 		}
@@ -42,7 +46,7 @@ func (h *ConfigHandler) handleDomainMapEditor(w http.ResponseWriter, r *http.Req
 	}
 
 	if err := h.renderTemplate(w, r, "domainmap_editor", "templates/domainmap_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -67,29 +71,8 @@ func (h *ConfigHandler) handleDomainMapConfig(w http.ResponseWriter, r *http.Req
 		}
 
 		// 添加auth配置（如果存在）
-		if dm.Auth.TokensEnabled ||
-			dm.Auth.TokenParamName != "" ||
-			dm.Auth.DynamicTokens.EnableDynamic ||
-			dm.Auth.DynamicTokens.Secret != "" ||
-			dm.Auth.DynamicTokens.Salt != "" ||
-			dm.Auth.StaticTokens.EnableStatic ||
-			dm.Auth.StaticTokens.Token != "" {
-
-			dmMap["auth"] = map[string]interface{}{
-				"tokens_enabled":   dm.Auth.TokensEnabled,
-				"token_param_name": dm.Auth.TokenParamName,
-				"dynamic_tokens": map[string]interface{}{
-					"enable_dynamic": dm.Auth.DynamicTokens.EnableDynamic,
-					"dynamic_ttl":    formatDuration(dm.Auth.DynamicTokens.DynamicTTL),
-					"secret":         dm.Auth.DynamicTokens.Secret,
-					"salt":           dm.Auth.DynamicTokens.Salt,
-				},
-				"static_tokens": map[string]interface{}{
-					"enable_static": dm.Auth.StaticTokens.EnableStatic,
-					"token":         dm.Auth.StaticTokens.Token,
-					"expire_hours":  formatDuration(dm.Auth.StaticTokens.ExpireHours),
-				},
-			}
+		if authMap := domainMapAuthMap(dm); authMap != nil {
+			dmMap["auth"] = authMap
 		}
 
 		// 添加client_headers和server_headers
@@ -99,13 +82,51 @@ func (h *ConfigHandler) handleDomainMapConfig(w http.ResponseWriter, r *http.Req
 		if len(dm.ServerHeaders) > 0 {
 			dmMap["server_headers"] = dm.ServerHeaders
 		}
+		// 路径路由配置，原样回传以便保存时保留
+		if dm.PathPrefix != "" {
+			dmMap["path_prefix"] = dm.PathPrefix
+		}
+		if dm.StripPrefix {
+			dmMap["strip_prefix"] = dm.StripPrefix
+		}
+		if dm.TargetPath != "" {
+			dmMap["target_path"] = dm.TargetPath
+		}
+		// 后端超时、重试与 TLS 配置，原样回传以便保存时保留
+		for key, d := range map[string]time.Duration{
+			"connect_timeout":         dm.ConnectTimeout,
+			"response_header_timeout": dm.ResponseHeaderTimeout,
+			"timeout":                 dm.Timeout,
+			"retry_delay":             dm.RetryDelay,
+			"failover_cooldown":       dm.FailoverCooldown,
+		} {
+			if d > 0 {
+				dmMap[key] = formatDuration(d)
+			}
+		}
+		if dm.Retries > 0 {
+			dmMap["retries"] = dm.Retries
+		}
+		if dm.TLS != nil {
+			dmMap["tls"] = dm.TLS
+		}
+		if len(dm.Targets) > 0 {
+			dmMap["targets"] = dm.Targets
+		}
+		// header_rules 编辑器不支持修改，原样回传以便保存时保留
+		if len(dm.HeaderRules) > 0 {
+			dmMap["header_rules"] = dm.HeaderRules
+		}
+		if len(dm.BodyRewrite) > 0 {
+			dmMap["body_rewrite"] = dm.BodyRewrite
+		}
 
 		domainMapList[i] = dmMap
 	}
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(domainMapList); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -114,14 +135,14 @@ func (h *ConfigHandler) handleDomainMapConfig(w http.ResponseWriter, r *http.Req
 func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -129,22 +150,27 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 	// 解析JSON数据
 	var domainMaps []map[string]interface{}
 	if err := json.Unmarshal(body, &domainMaps); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// 非管理员不能修改认证配置，沿用当前配置中同名映射的 auth
+	if !h.hasRole(r, RoleAdmin) {
+		keepDomainMapAuth(domainMaps)
+	}
+
 	// 读取配置文件
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -193,6 +219,75 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 			)
 		}
 
+		// 保留路径路由、超时与重试配置
+		for _, key := range []string{"path_prefix", "strip_prefix", "target_path",
+			"connect_timeout", "response_header_timeout", "timeout", "retries", "retry_delay", "failover_cooldown"} {
+			if v, ok := dm[key]; ok && v != nil && v != "" && v != false {
+				domainMapNode.Content = append(domainMapNode.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+					&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", v)},
+				)
+			}
+		}
+
+		// 保留备用目标
+		if targets, ok := dm["targets"].([]interface{}); ok && len(targets) > 0 {
+			targetsNode := &yaml.Node{Kind: yaml.SequenceNode}
+			for _, t := range targets {
+				if ts := fmt.Sprintf("%v", t); ts != "" {
+					targetsNode.Content = append(targetsNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: ts})
+				}
+			}
+			if len(targetsNode.Content) > 0 {
+				domainMapNode.Content = append(domainMapNode.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: "targets"},
+					targetsNode,
+				)
+			}
+		}
+
+		// 保留tls
+		if tlsValue, ok := dm["tls"]; ok && tlsValue != nil {
+			var tlsConf config.DomainMapTLSConfig
+			if raw, err := json.Marshal(tlsValue); err == nil && json.Unmarshal(raw, &tlsConf) == nil {
+				tlsNode := &yaml.Node{}
+				if err := tlsNode.Encode(tlsConf); err == nil {
+					domainMapNode.Content = append(domainMapNode.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: "tls"},
+						tlsNode,
+					)
+				}
+			}
+		}
+
+		// 保留header_rules
+		if headerRules, ok := dm["header_rules"]; ok && headerRules != nil {
+			var rules []config.HeaderRule
+			if raw, err := json.Marshal(headerRules); err == nil && json.Unmarshal(raw, &rules) == nil && len(rules) > 0 {
+				rulesNode := &yaml.Node{}
+				if err := rulesNode.Encode(rules); err == nil {
+					domainMapNode.Content = append(domainMapNode.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: "header_rules"},
+						rulesNode,
+					)
+				}
+			}
+		}
+
+		// 保留body_rewrite
+		if bodyRewrite, ok := dm["body_rewrite"]; ok && bodyRewrite != nil {
+			var rewrites []config.BodyRewriteRule
+			if raw, err := json.Marshal(bodyRewrite); err == nil && json.Unmarshal(raw, &rewrites) == nil && len(rewrites) > 0 {
+				rewriteNode := &yaml.Node{}
+				if err := rewriteNode.Encode(rewrites); err == nil {
+					domainMapNode.Content = append(domainMapNode.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: "body_rewrite"},
+						rewriteNode,
+					)
+				}
+			}
+		}
+
 		// 添加协议字段（如果存在且非空）
 		if protocol, ok := dm["protocol"]; ok && protocol != "" && protocol != nil {
 			domainMapNode.Content = append(domainMapNode.Content,
@@ -262,6 +357,8 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 							)
 						}
 
+						appendACLNodes(dtNode, dtMap)
+
 						if len(dtNode.Content) > 0 {
 							authNode.Content = append(authNode.Content,
 								&yaml.Node{Kind: yaml.ScalarNode, Value: "dynamic_tokens"},
@@ -304,6 +401,8 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 							}
 						}
 
+						appendACLNodes(stNode, stMap)
+
 						if len(stNode.Content) > 0 {
 							authNode.Content = append(authNode.Content,
 								&yaml.Node{Kind: yaml.ScalarNode, Value: "static_tokens"},
@@ -313,6 +412,25 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 					}
 				}
 
+				// 添加会话数限制
+				appendSessionLimitNodes(authNode, authMap)
+
+				// 添加jwt_tokens
+				if jtNode := jwtTokensNode(authMap["jwt_tokens"]); jtNode != nil {
+					authNode.Content = append(authNode.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: "jwt_tokens"},
+						jtNode,
+					)
+				}
+
+				// 添加signed_url
+				if suNode := signedURLNode(authMap["signed_url"]); suNode != nil {
+					authNode.Content = append(authNode.Content,
+						&yaml.Node{Kind: yaml.ScalarNode, Value: "signed_url"},
+						suNode,
+					)
+				}
+
 				if len(authNode.Content) > 0 {
 					domainMapNode.Content = append(domainMapNode.Content,
 						&yaml.Node{Kind: yaml.ScalarNode, Value: "auth"},
@@ -345,16 +463,16 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 	}
 
 	// 序列化为YAML格式
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -362,7 +480,7 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		// 恢复备份文件
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -371,3 +489,68 @@ func (h *ConfigHandler) handleDomainMapConfigSave(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("配置保存成功"))
 }
+
+// domainMapAuthMap 将映射的 auth 配置转换为编辑器使用的 JSON 格式，未配置时返回 nil
+func domainMapAuthMap(dm *config.DomainMapConfig) map[string]interface{} {
+	if !(dm.Auth.TokensEnabled ||
+		dm.Auth.TokenParamName != "" ||
+		dm.Auth.DynamicTokens.EnableDynamic ||
+		dm.Auth.DynamicTokens.Secret != "" ||
+		dm.Auth.DynamicTokens.Salt != "" ||
+		dm.Auth.StaticTokens.EnableStatic ||
+		dm.Auth.StaticTokens.Token != "" ||
+		dm.Auth.JWTTokens.EnableJWT ||
+		dm.Auth.SignedURL.EnableSigned) {
+		return nil
+	}
+	return map[string]interface{}{
+		"tokens_enabled":   dm.Auth.TokensEnabled,
+		"token_param_name": dm.Auth.TokenParamName,
+		"dynamic_tokens": map[string]interface{}{
+			"enable_dynamic": dm.Auth.DynamicTokens.EnableDynamic,
+			"dynamic_ttl":    formatDuration(dm.Auth.DynamicTokens.DynamicTTL),
+			"secret":         dm.Auth.DynamicTokens.Secret,
+			"salt":           dm.Auth.DynamicTokens.Salt,
+			"allow":          dm.Auth.DynamicTokens.Allow,
+			"deny":           dm.Auth.DynamicTokens.Deny,
+		},
+		"static_tokens": map[string]interface{}{
+			"enable_static": dm.Auth.StaticTokens.EnableStatic,
+			"token":         dm.Auth.StaticTokens.Token,
+			"expire_hours":  formatDuration(dm.Auth.StaticTokens.ExpireHours),
+			"allow":         dm.Auth.StaticTokens.Allow,
+			"deny":          dm.Auth.StaticTokens.Deny,
+		},
+		"jwt_tokens":           jwtTokensMap(dm.Auth.JWTTokens),
+		"signed_url":           signedURLMap(dm.Auth.SignedURL),
+		"max_sessions":         dm.Auth.MaxSessions,
+		"max_sessions_per_ip":  dm.Auth.MaxSessionsPerIP,
+		"session_limit_action": dm.Auth.SessionLimitAction,
+	}
+}
+
+// keepDomainMapAuth 用当前配置中同名映射的 auth 覆盖提交的内容，新增的映射不带 auth
+func keepDomainMapAuth(domainMaps []map[string]interface{}) {
+	current := make(map[string]map[string]interface{})
+	config.CfgMu.RLock()
+	for _, dm := range config.Cfg.DomainMap {
+		if authMap := domainMapAuthMap(dm); authMap != nil {
+			current[dm.Name] = authMap
+		}
+	}
+	config.CfgMu.RUnlock()
+
+	for _, dmMap := range domainMaps {
+		name, _ := dmMap["name"].(string)
+		authMap, ok := current[name]
+		if !ok {
+			delete(dmMap, "auth")
+			continue
+		}
+		// 经过一次 JSON 编解码，与编辑器提交的数据类型保持一致
+		var decoded map[string]interface{}
+		if data, err := json.Marshal(authMap); err == nil && json.Unmarshal(data, &decoded) == nil {
+			dmMap["auth"] = decoded
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/qist/tvgate/dvr"
+	"github.com/qist/tvgate/epg"
+)
+
+// decodeDVRRequest 解析定时录制接口的请求体：定时任务字段，修改、删除与停止时 id 为定时任务或录制的 ID
+func decodeDVRRequest(w http.ResponseWriter, r *http.Request) (*dvr.Timer, bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return nil, false
+	}
+	var req dvr.Timer
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return nil, false
+	}
+	return &req, true
+}
+
+func writeDVRError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dvr.ErrDisabled):
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, dvr.ErrNoTimer), errors.Is(err, dvr.ErrNoRecording):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	default:
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// handleDVRPage 渲染定时录制页面
+func (h *ConfigHandler) handleDVRPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":   "定时录制",
+		"webPath": h.getWebPath(),
+	}
+	h.renderTemplate(w, r, "dvr", "templates/dvr.html", data)
+}
+
+// handleDVRTimers GET 分页列出定时任务及下一次录制时间，POST 添加定时任务，返回的 warnings 为时间重叠的提示
+func (h *ConfigHandler) handleDVRTimers(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		list, err := dvr.Default.Timers()
+		if err != nil {
+			writeDVRError(w, err)
+			return
+		}
+		writePage(w, r, list)
+		return
+	}
+	req, ok := decodeDVRRequest(w, r)
+	if !ok {
+		return
+	}
+	st, warnings, err := dvr.Default.AddTimer(*req)
+	if err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timer": st, "warnings": warnings})
+}
+
+// handleDVRTimerUpdate 修改定时任务
+func (h *ConfigHandler) handleDVRTimerUpdate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDVRRequest(w, r)
+	if !ok {
+		return
+	}
+	st, warnings, err := dvr.Default.UpdateTimer(req.ID, *req)
+	if err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timer": st, "warnings": warnings})
+}
+
+// handleDVRTimerDelete 删除定时任务，进行中的录制随之停止
+func (h *ConfigHandler) handleDVRTimerDelete(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDVRRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := dvr.Default.DeleteTimer(req.ID); err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": req.ID})
+}
+
+// handleDVRRecordings 分页列出录制记录，最新的在前
+func (h *ConfigHandler) handleDVRRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	list, err := dvr.Default.Recordings()
+	if err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writePage(w, r, list)
+}
+
+// handleDVRRecordingStop 停止进行中的录制
+func (h *ConfigHandler) handleDVRRecordingStop(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDVRRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := dvr.Default.StopRecording(req.ID); err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": req.ID})
+}
+
+// handleDVRRecordingDelete 删除已结束的录制及其文件
+func (h *ConfigHandler) handleDVRRecordingDelete(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDVRRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := dvr.Default.DeleteRecording(req.ID); err != nil {
+		writeDVRError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": req.ID})
+}
+
+// handleEPGProgrammes 列出节目单中的节目，供按节目添加定时录制；参数 channel 节目单频道 id，hours 从现在起的小时数，默认 24
+func (h *ConfigHandler) handleEPGProgrammes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "hours 格式错误: "+v)
+			return
+		}
+		hours = n
+	}
+	now := time.Now()
+	writePage(w, r, epg.Default.Programmes(r.URL.Query().Get("channel"), now, now.Add(time.Duration(hours)*time.Hour)))
+}
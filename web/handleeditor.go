@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 // handleEditor 处理配置编辑器页面请求
@@ -29,7 +31,7 @@ func (h *ConfigHandler) handleEditor(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == webPath+"editor" {
 		// 只允许GET方法
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		// 计算服务器运行时间
@@ -58,6 +60,7 @@ func (h *ConfigHandler) handleEditor(w http.ResponseWriter, r *http.Request) {
 			"hasDomainMap":   hasDomainMap,
 			"hasProxyGroups": hasProxyGroups,
 			"configPath":     *config.ConfigFilePath, // 添加配置文件路径到模板数据
+			"configFormat":   load.Format(*config.ConfigFilePath),
 			"monitorPath":    monitorPath,
 			// "uptime":         uptime,
 		}
@@ -65,28 +68,28 @@ func (h *ConfigHandler) handleEditor(w http.ResponseWriter, r *http.Request) {
 		// 从嵌入的文件系统读取模板
 		content, err := templatesFS.ReadFile("templates/editor.html")
 		if err != nil {
-			http.Error(w, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析模板
 		tmpl, err := template.New("editor").Parse(string(content))
 		if err != nil {
-			http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 读取侧边栏模板
 		sidebarContent, err := templatesFS.ReadFile("templates/sidebar.html")
 		if err != nil {
-			http.Error(w, "Failed to read sidebar template file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read sidebar template file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析侧边栏模板
 		_, err = tmpl.New("sidebar").Parse(string(sidebarContent))
 		if err != nil {
-			http.Error(w, "Failed to parse sidebar template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse sidebar template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -95,7 +98,7 @@ func (h *ConfigHandler) handleEditor(w http.ResponseWriter, r *http.Request) {
 
 		// 执行模板
 		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -125,7 +128,7 @@ func (h *ConfigHandler) handleNodeEditor(w http.ResponseWriter, r *http.Request)
 	if r.URL.Path == webPath+"node-editor" {
 		// 只允许GET方法
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -150,14 +153,14 @@ func (h *ConfigHandler) handleNodeEditor(w http.ResponseWriter, r *http.Request)
 		// 从嵌入的文件系统读取模板
 		content, err := templatesFS.ReadFile("templates/node_editor.html")
 		if err != nil {
-			http.Error(w, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to read template file: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// 解析模板
 		tmpl, err := template.New("node_editor").Parse(string(content))
 		if err != nil {
-			http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -166,7 +169,7 @@ func (h *ConfigHandler) handleNodeEditor(w http.ResponseWriter, r *http.Request)
 
 		// 执行模板
 		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
+			httperror.Error(w, r, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/qist/tvgate/epg"
+)
+
+// handleEPG 节目单状态：最近一次更新时间、频道与节目数以及各源的拉取结果
+func (h *ConfigHandler) handleEPG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeJSON(w, http.StatusOK, epg.Default.Status())
+}
+
+// handleEPGRefresh 立即重新拉取节目单，拉取在后台进行，结果通过 handleEPG 查看
+func (h *ConfigHandler) handleEPGRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	if err := epg.Default.Refresh(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": "已开始更新节目单"})
+}
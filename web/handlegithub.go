@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +24,7 @@ func (h *ConfigHandler) handleGithubEditor(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.renderTemplate(w, r, "github_editor", "templates/github_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -43,11 +45,14 @@ func (h *ConfigHandler) handleGithubConfig(w http.ResponseWriter, r *http.Reques
 		"backup_urls": github.BackupURLs,
 		"timeout":     formatDuration(github.Timeout),
 		"retry":       github.Retry,
+
+		"auto_check":     github.AutoCheck,
+		"check_interval": formatDuration(github.CheckInterval),
 	}
 
 	// 返回JSON格式的配置
 	if err := json.NewEncoder(w).Encode(githubConfig); err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -56,14 +61,14 @@ func (h *ConfigHandler) handleGithubConfig(w http.ResponseWriter, r *http.Reques
 func (h *ConfigHandler) handleGithubConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -71,7 +76,7 @@ func (h *ConfigHandler) handleGithubConfigSave(w http.ResponseWriter, r *http.Re
 	// 解析JSON数据
 	var githubConfig map[string]interface{}
 	if err := json.Unmarshal(body, &githubConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -79,38 +84,38 @@ func (h *ConfigHandler) handleGithubConfigSave(w http.ResponseWriter, r *http.Re
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 查找并更新github配置节点
 	if err := updateGithubConfigNode(&fullNode, githubConfig); err != nil {
-		http.Error(w, "更新配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "更新配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 序列化更新后的配置
-	updatedData, err := yaml.Marshal(&fullNode)
+	updatedData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	// 写入配置文件
 	if err := os.WriteFile(configPath, updatedData, 0644); err != nil {
-		http.Error(w, "写入配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -138,6 +143,14 @@ func (h *ConfigHandler) handleGithubConfigSave(w http.ResponseWriter, r *http.Re
 	if retry, ok := githubConfig["retry"].(float64); ok {
 		config.Cfg.Github.Retry = int(retry)
 	}
+	if autoCheck, ok := githubConfig["auto_check"].(bool); ok {
+		config.Cfg.Github.AutoCheck = autoCheck
+	}
+	if intervalStr, ok := githubConfig["check_interval"].(string); ok {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Cfg.Github.CheckInterval = interval
+		}
+	}
 	config.CfgMu.Unlock()
 
 	// 返回成功响应
@@ -147,7 +160,7 @@ func (h *ConfigHandler) handleGithubConfigSave(w http.ResponseWriter, r *http.Re
 		"message": "配置保存成功",
 	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "返回响应失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "返回响应失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -200,6 +213,10 @@ func updateGithubConfigNode(node *yaml.Node, githubConfig map[string]interface{}
 	updateField(githubNode, "url", githubConfig["url"])
 	updateField(githubNode, "timeout", githubConfig["timeout"])
 	updateField(githubNode, "retry", githubConfig["retry"])
+	updateField(githubNode, "auto_check", githubConfig["auto_check"])
+	if interval, ok := githubConfig["check_interval"].(string); ok && interval != "" {
+		updateField(githubNode, "check_interval", interval)
+	}
 
 	// 特殊处理backup_urls数组
 	if backupUrls, ok := githubConfig["backup_urls"].([]interface{}); ok {
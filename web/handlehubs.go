@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/stream"
+)
+
+// hubRequest 组播 Hub 管理接口的请求参数
+type hubRequest struct {
+	Key    string   `json:"key"`    // Hub 标识，见列表接口
+	Addr   string   `json:"addr"`   // 组播地址 ip:port，预先启动时使用
+	Ifaces []string `json:"ifaces"` // 网卡列表
+}
+
+// decodeHubRequest 解析 POST 请求体，失败时写出错误并返回 false
+func decodeHubRequest(w http.ResponseWriter, r *http.Request, req *hubRequest) bool {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// handleHubs 分页列出组播/RTP Hub：地址、网卡、客户端数与收包统计
+func (h *ConfigHandler) handleHubs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	writePage(w, r, stream.ListHubs())
+}
+
+// handleHubClose 强制关闭 Hub，离开组播组并断开其全部客户端
+func (h *ConfigHandler) handleHubClose(w http.ResponseWriter, r *http.Request) {
+	var req hubRequest
+	if !decodeHubRequest(w, r, &req) {
+		return
+	}
+	if req.Key == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少 key")
+		return
+	}
+	if !stream.CloseHub(req.Key) {
+		writeJSONError(w, http.StatusNotFound, stream.ErrHubNotFound.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"key": req.Key})
+}
+
+// handleHubStart 预先加入组播组，客户端请求时无需等待加入；ifaces 为空时使用 server.multicast_ifaces
+func (h *ConfigHandler) handleHubStart(w http.ResponseWriter, r *http.Request) {
+	var req hubRequest
+	if !decodeHubRequest(w, r, &req) {
+		return
+	}
+	if _, err := net.ResolveUDPAddr("udp", req.Addr); err != nil || req.Addr == "" {
+		writeJSONError(w, http.StatusBadRequest, "addr 格式错误，应为 ip:port: "+req.Addr)
+		return
+	}
+	info, err := stream.StartHub(req.Addr, req.Ifaces)
+	if err != nil {
+		logger.LogPrintf("❌ 预先启动 Hub %s 失败: %v", req.Addr, err)
+		writeJSONError(w, http.StatusBadGateway, "加入组播组失败: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleHubInterfaces 在指定网卡上重新加入组播组，客户端不断开；ifaces 为空时在原网卡上重新加入
+func (h *ConfigHandler) handleHubInterfaces(w http.ResponseWriter, r *http.Request) {
+	var req hubRequest
+	if !decodeHubRequest(w, r, &req) {
+		return
+	}
+	if req.Key == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少 key")
+		return
+	}
+	info, err := stream.UpdateHubInterfaces(req.Key, req.Ifaces)
+	if errors.Is(err, stream.ErrHubNotFound) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
@@ -10,6 +10,8 @@ import (
 	"net/http"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,7 +25,7 @@ func (h *ConfigHandler) handleJXEditor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.renderTemplate(w, r, "jx_editor", "templates/jx_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -78,14 +80,14 @@ func (h *ConfigHandler) handleJXConfig(w http.ResponseWriter, r *http.Request) {
 func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Request) {
 	// 检查请求方法
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -96,7 +98,7 @@ func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Reques
 	// 解析JSON数据
 	var jxConfig map[string]interface{}
 	if err := json.Unmarshal(body, &jxConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -116,14 +118,14 @@ func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Reques
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -151,8 +153,16 @@ func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Reques
 				jxNode = &yaml.Node{Kind: yaml.MappingNode}
 				root.Content = append(root.Content, jxNode)
 			} else {
-				// 清空现有的jx节点内容
-				jxNode.Content = []*yaml.Node{}
+				// 清空现有jx节点中由页面编辑的字段，保留 resolvers 等页面不编辑的字段
+				var kept []*yaml.Node
+				for i := 0; i+1 < len(jxNode.Content); i += 2 {
+					switch jxNode.Content[i].Value {
+					case "path", "default_id", "api_groups":
+					default:
+						kept = append(kept, jxNode.Content[i], jxNode.Content[i+1])
+					}
+				}
+				jxNode.Content = kept
 			}
 
 			// 添加path字段
@@ -279,16 +289,16 @@ func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Reques
 	}
 
 	// 将更新后的配置写回文件
-	output, err := yaml.Marshal(&fullNode)
+	output, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 创建备份文件
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -296,7 +306,7 @@ func (h *ConfigHandler) handleJXConfigSave(w http.ResponseWriter, r *http.Reques
 	if err := os.WriteFile(configPath, output, 0644); err != nil {
 		// 恢复备份文件
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,217 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/qist/tvgate/logger"
+)
+
+const (
+	// logsPingInterval WebSocket 心跳间隔，防止空闲连接被代理断开
+	logsPingInterval = 30 * time.Second
+	// logsWriteTimeout 单条消息写超时，客户端长时间不读取时断开
+	logsWriteTimeout = 10 * time.Second
+)
+
+// 同源检查使用默认规则，禁止其他站点借用登录 Cookie 连接
+var logsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+var levelRank = map[string]int{
+	logger.LevelDebug: 0,
+	logger.LevelInfo:  1,
+	logger.LevelWarn:  2,
+	logger.LevelError: 3,
+}
+
+// logFilter 实时日志过滤条件：level 为最低级别，module 为模块前缀，keyword 为消息包含的文本（不区分大小写）
+type logFilter struct {
+	level   int
+	module  string
+	keyword string
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	q := r.URL.Query()
+	f := logFilter{
+		module:  strings.Trim(q.Get("module"), "/"),
+		keyword: strings.ToLower(q.Get("q")),
+	}
+	if level := q.Get("level"); level != "" {
+		rank, ok := levelRank[level]
+		if !ok {
+			return f, fmt.Errorf("level 应为 debug、info、warn 或 error: %s", level)
+		}
+		f.level = rank
+	}
+	return f, nil
+}
+
+func (f logFilter) match(e logger.Entry) bool {
+	if levelRank[e.Level] < f.level {
+		return false
+	}
+	if f.module != "" && e.Module != f.module && !strings.HasPrefix(e.Module, f.module+"/") {
+		return false
+	}
+	return f.keyword == "" || strings.Contains(strings.ToLower(e.Message), f.keyword)
+}
+
+// handleLogsPage 渲染实时日志页面
+func (h *ConfigHandler) handleLogsPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":   "实时日志",
+		"webPath": h.getWebPath(),
+		"logFile": logger.CurrentFile(),
+	}
+	h.renderTemplate(w, r, "logs", "templates/logs.html", data)
+}
+
+// handleLogsWS 通过 WebSocket 推送日志：先发送内存中最近的日志，再实时推送新日志，每条为一个 JSON 对象。
+// 参数 level（最低级别）、module（模块前缀，如 stream）、q（关键字）
+func (h *ConfigHandler) handleLogsWS(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLogFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	conn, err := logsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已写出错误响应
+		return
+	}
+	defer conn.Close()
+
+	backlog, ch, cancel := logger.Subscribe(256)
+	defer cancel()
+
+	// 读取客户端消息以处理关闭帧和 pong，连接断开时结束推送
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(logsPingInterval * 2))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(logsPingInterval * 2))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(e logger.Entry) error {
+		if !filter.match(e) {
+			return nil
+		}
+		conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+		return conn.WriteJSON(e)
+	}
+	for _, e := range backlog {
+		if send(e) != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(logsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e := <-ch:
+			if send(e) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(logsWriteTimeout)) != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// handleLogsDownload 下载当前日志文件；日志输出到标准输出时下载内存中最近的日志
+func (h *ConfigHandler) handleLogsDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	if path := logger.CurrentFile(); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "打开日志文件失败: "+err.Error())
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		// 日志仍在写入，只发送打开时已有的内容
+		if info, err := f.Stat(); err == nil {
+			w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+			io.CopyN(w, f, info.Size())
+			return
+		}
+		io.Copy(w, f)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tvgate-recent.log"`)
+	for _, e := range logger.Recent() {
+		fmt.Fprintf(w, "%s %s\n", e.Time.Format("2006/01/02 15:04:05"), e.Message)
+	}
+}
+
+// logLevels 运行时日志级别
+type logLevels struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// handleLogLevel GET 查看当前日志级别；PUT/POST 在运行时修改，不写入配置文件，重新加载配置后恢复为 log 中的设置。
+// 请求体 {"level": "debug", "modules": {"stream": "debug"}}，省略 level 保持不变，modules 与当前设置合并，值为空时删除该模块的覆盖
+func (h *ConfigHandler) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Level   string            `json:"level"`
+			Modules map[string]string `json:"modules"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+			return
+		}
+		level, modules := logger.Levels()
+		if req.Level != "" {
+			level = req.Level
+		}
+		for module, l := range req.Modules {
+			modules[strings.Trim(module, "/")] = l
+		}
+		if err := logger.SetLevels(level, modules); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		level, _ = logger.Levels()
+		logger.LogPrintf("🔧 日志级别已修改为 %s，模块覆盖: %v", level, req.Modules)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	level, modules := logger.Levels()
+	writeJSON(w, http.StatusOK, logLevels{Level: level, Modules: modules})
+}
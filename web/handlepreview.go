@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/stream"
+)
+
+// previewInitTimeout 等待频道出现第一个关键帧的最长时间
+const previewInitTimeout = 15 * time.Second
+
+// handlePlayerPage 渲染频道预览播放器，src 为状态页中的频道标识（组播地址 / RTSP 地址）或 HLS 地址
+func (h *ConfigHandler) handlePlayerPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":   "频道预览",
+		"webPath": h.getWebPath(),
+		"src":     r.URL.Query().Get("src"),
+	}
+	h.renderTemplate(w, r, "player", "templates/player.html", data)
+}
+
+// handlePreviewStream 将正在播放的频道转封装为 fMP4 输出，供播放器通过 MSE 播放。
+// 参数 src 为频道标识，audio 为音轨序号；MIME 与轨道信息以 JSON 放在响应头 X-Preview-Info 中
+func (h *ConfigHandler) handlePreviewStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	src := q.Get("src")
+	if src == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少 src 参数")
+		return
+	}
+	audio := -1
+	if s := q.Get("audio"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "audio 应为音轨序号")
+			return
+		}
+		audio = n
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	rc, err := stream.OpenPreview(ctx, src)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error()+": "+src)
+		return
+	}
+	defer rc.Close()
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(previewInitTimeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	defer timer.Stop()
+
+	started := false
+	err = stream.RemuxFMP4(rc, w, audio, func(info stream.PreviewInfo) error {
+		if !timer.Stop() {
+			return context.DeadlineExceeded
+		}
+		b, _ := json.Marshal(info)
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Preview-Info", string(b))
+		w.WriteHeader(http.StatusOK)
+		started = true
+		logger.LogPrintf("📺 Web 预览频道: %s (%s)", src, info.MIME)
+		return nil
+	})
+
+	if started {
+		return
+	}
+	switch {
+	case timedOut.Load():
+		writeJSONError(w, http.StatusGatewayTimeout, "等待频道关键帧超时")
+	case errors.Is(err, context.Canceled):
+		// 浏览器已断开
+	case err != nil:
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+	}
+}
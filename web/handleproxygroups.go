@@ -7,10 +7,14 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/groupstats"
 	// "github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -35,7 +39,7 @@ func (h *ConfigHandler) handleProxyGroupsEditor(w http.ResponseWriter, r *http.R
 	}
 
 	if err := h.renderTemplate(w, r, "proxygroups_editor", "templates/proxygroups_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -66,6 +70,8 @@ func (h *ConfigHandler) handleProxyGroupsConfig(w http.ResponseWriter, r *http.R
 				"udp":      proxy.UDP,
 				"username": proxy.Username,
 				"password": proxy.Password,
+				"weight":   proxy.Weight,
+				"disabled": proxy.Disabled,
 			}
 
 			// 添加 headers 配置（如果存在）
@@ -92,6 +98,9 @@ func (h *ConfigHandler) handleProxyGroupsConfig(w http.ResponseWriter, r *http.R
 					"FailCount":     proxyStats.FailCount,
 					"CooldownUntil": proxyStats.CooldownUntil,
 					"StatusCode":    proxyStats.StatusCode,
+					"HealthStatus":  proxyStats.HealthStatus,
+					"HealthRT":      proxyStats.HealthRT,
+					"HealthError":   proxyStats.HealthError,
 				}
 			}
 			pg.Stats.RUnlock()
@@ -123,7 +132,7 @@ func (h *ConfigHandler) handleProxyGroupsConfig(w http.ResponseWriter, r *http.R
 
 	// 返回 JSON 格式的配置
 	if err := json.NewEncoder(w).Encode(proxyGroupsMap); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -135,7 +144,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 	// 检查请求方法
 	if r.Method != http.MethodPost {
 		// logger.LogPrintf("错误：方法不允许: %s", r.Method)
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -145,7 +154,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		// logger.LogPrintf("错误：读取请求体失败: %v", err)
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
@@ -156,7 +165,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 	var proxyGroups map[string]map[string]interface{}
 	if err := json.Unmarshal(body, &proxyGroups); err != nil {
 		// logger.LogPrintf("错误：解析JSON失败: %v", err)
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -190,7 +199,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		// logger.LogPrintf("错误：读取配置文件失败: %v", err)
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -198,9 +207,9 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 
 	// 使用yaml.Node解析YAML配置以保持注释和格式
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
 		// logger.LogPrintf("错误：解析配置文件失败: %v", err)
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -299,6 +308,22 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 							// logger.LogPrintf("添加密码: [HIDDEN]")
 						}
 
+						// 权重大于 0 时保存（weighted 策略使用）
+						if weightVal, ok := proxyMap["weight"].(float64); ok && weightVal > 0 {
+							proxyNode.Content = append(proxyNode.Content,
+								&yaml.Node{Kind: yaml.ScalarNode, Value: "weight"},
+								&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%d", int(weightVal))},
+							)
+						}
+
+						// 停用的代理保留配置，不参与选择
+						if disabled, ok := proxyMap["disabled"].(bool); ok && disabled {
+							proxyNode.Content = append(proxyNode.Content,
+								&yaml.Node{Kind: yaml.ScalarNode, Value: "disabled"},
+								&yaml.Node{Kind: yaml.ScalarNode, Value: "true"},
+							)
+						}
+
 						// 添加headers字段（如果存在）
 						if headers, ok := proxyMap["headers"]; ok {
 							// logger.LogPrintf("处理Headers: %v (类型: %T)", headers, headers)
@@ -459,6 +484,8 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 			for i := 0; i < len(doc.Content); i += 2 {
 				keyNode := doc.Content[i]
 				if keyNode.Kind == yaml.ScalarNode && keyNode.Value == "proxygroups" {
+					// 编辑器不涉及的配置项（health_check、fallback 等）沿用文件中的值
+					preserveGroupKeys(doc.Content[i+1], yamlProxyGroups)
 					// 直接替换整个proxygroups节点的值
 					proxyGroupsNode := &yaml.Node{Kind: yaml.MappingNode}
 					for name, node := range yamlProxyGroups {
@@ -508,10 +535,10 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 
 	// 序列化为YAML格式
 	// logger.LogPrintf("开始序列化YAML配置")
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
 		// logger.LogPrintf("错误：序列化配置失败: %v", err)
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -522,7 +549,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 	// logger.LogPrintf("创建备份文件: %s", backupPath)
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
 		// logger.LogPrintf("错误：创建备份文件失败: %v", err)
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -532,7 +559,7 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 		// 恢复备份文件
 		// logger.LogPrintf("错误：写入配置文件失败，尝试恢复备份: %v", err)
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置文件失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -551,3 +578,110 @@ func (h *ConfigHandler) handleProxyGroupsConfigSave(w http.ResponseWriter, r *ht
 
 	// logger.LogPrintf("代理组配置保存完成")
 }
+
+// editorGroupKeys 代理组编辑器管理的配置项，其余配置项保存时沿用文件中的值
+var editorGroupKeys = map[string]bool{
+	"proxies": true, "domains": true, "ipv6": true, "interval": true,
+	"loadbalance": true, "max_retries": true, "retry_delay": true, "max_rt": true,
+}
+
+// preserveGroupKeys 将文件中已有代理组里编辑器不管理的配置项追加到新节点，避免保存时丢失
+func preserveGroupKeys(oldGroups *yaml.Node, newGroups map[string]*yaml.Node) {
+	if oldGroups == nil || oldGroups.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(oldGroups.Content); i += 2 {
+		newNode, ok := newGroups[oldGroups.Content[i].Value]
+		oldNode := oldGroups.Content[i+1]
+		if !ok || oldNode.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(oldNode.Content); j += 2 {
+			if !editorGroupKeys[oldNode.Content[j].Value] {
+				newNode.Content = append(newNode.Content, oldNode.Content[j], oldNode.Content[j+1])
+			}
+		}
+	}
+}
+
+// proxyTestRequest 代理测试请求，proxies 为空时测试组内全部代理，url 为空时使用健康检查地址
+type proxyTestRequest struct {
+	Group   string   `json:"group"`
+	Proxies []string `json:"proxies"`
+	URL     string   `json:"url"`
+}
+
+// proxyTestResult 单个代理的测试结果
+type proxyTestResult struct {
+	Proxy string `json:"proxy"`
+	groupstats.ProbeRecord
+}
+
+// handleProxyGroupsTest 通过已保存的代理配置访问参考地址，返回各代理的延迟和结果
+func (h *ConfigHandler) handleProxyGroupsTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req proxyTestRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	if req.URL != "" && !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		writeJSONError(w, http.StatusBadRequest, "测试地址应以 http:// 或 https:// 开头")
+		return
+	}
+
+	config.CfgMu.RLock()
+	group := config.Cfg.ProxyGroups[req.Group]
+	var proxies []config.ProxyConfig
+	if group != nil {
+		want := make(map[string]bool)
+		for _, name := range req.Proxies {
+			want[name] = true
+		}
+		for _, proxy := range group.Proxies {
+			if proxy != nil && (len(want) == 0 || want[proxy.Name]) {
+				proxies = append(proxies, *proxy)
+			}
+		}
+	}
+	config.CfgMu.RUnlock()
+
+	if group == nil {
+		writeJSONError(w, http.StatusNotFound, "代理组不存在（新添加的代理组需先保存）: "+req.Group)
+		return
+	}
+	if len(proxies) == 0 {
+		writeJSONError(w, http.StatusNotFound, "没有可测试的代理（新添加的代理需先保存）")
+		return
+	}
+
+	results := make([]proxyTestResult, len(proxies))
+	var wg sync.WaitGroup
+	for i, proxy := range proxies {
+		wg.Add(1)
+		go func(i int, proxy config.ProxyConfig) {
+			defer wg.Done()
+			results[i] = proxyTestResult{Proxy: proxy.Name, ProbeRecord: groupstats.TestProxy(req.Group, group, proxy, req.URL)}
+		}(i, proxy)
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"url":     groupstats.TestURL(group, req.URL),
+		"results": results,
+	})
+}
+
+// handleProxyGroupsHistory 返回代理最近的测速、健康检查和手动测试记录，参数 group proxy
+func (h *ConfigHandler) handleProxyGroupsHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	group, proxy := q.Get("group"), q.Get("proxy")
+	if group == "" || proxy == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少参数 group 或 proxy")
+		return
+	}
+	writeJSON(w, http.StatusOK, groupstats.ProbeHistory(group, proxy))
+}
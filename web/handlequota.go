@@ -0,0 +1,42 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/qist/tvgate/quota"
+)
+
+// handleQuota 查询 token 流量配额，参数 token 为空时返回全部
+func (h *ConfigHandler) handleQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		writeJSON(w, http.StatusOK, quota.Default.Status(token))
+		return
+	}
+	writeJSON(w, http.StatusOK, quota.Default.Statuses())
+}
+
+// handleQuotaReset 清零 token 当日与当月的流量
+func (h *ConfigHandler) handleQuotaReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	if req.Token == "" {
+		writeJSONError(w, http.StatusBadRequest, "token 不能为空")
+		return
+	}
+	quota.Default.Reset(req.Token)
+	writeJSON(w, http.StatusOK, quota.Default.Status(req.Token))
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/qist/tvgate/stats"
+)
+
+// statsMaxPoints 未指定 step 时每条曲线最多返回的点数
+const statsMaxPoints = 360
+
+// handleStatsPage 渲染历史统计页面
+func (h *ConfigHandler) handleStatsPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"title":   "历史统计",
+		"webPath": h.getWebPath(),
+	}
+	h.renderTemplate(w, r, "stats", "templates/stats.html", data)
+}
+
+// statsKind 校验序列类型，默认为频道
+func statsKind(kind string) (string, bool) {
+	switch kind {
+	case "":
+		return stats.KindChannel, true
+	case stats.KindChannel, stats.KindGroup:
+		return kind, true
+	}
+	return "", false
+}
+
+// writeStatsError 未启用历史统计时返回 404，其余为 500
+func writeStatsError(w http.ResponseWriter, err error) {
+	if errors.Is(err, stats.ErrDisabled) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, err.Error())
+}
+
+// handleStatsSeries 列出有历史数据的频道或代理组，参数 kind(channel/group) since（默认 24h）
+func (h *ConfigHandler) handleStatsSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	q := r.URL.Query()
+	kind, ok := statsKind(q.Get("kind"))
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "kind 应为 channel 或 group")
+		return
+	}
+	sinceValue := q.Get("since")
+	if sinceValue == "" {
+		sinceValue = "24h"
+	}
+	since, err := parseAuditTime(sinceValue)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	list, err := stats.Default.List(kind, since)
+	if err != nil {
+		writeStatsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleStatsQuery 查询一条曲线，参数 kind name since（默认 24h）until step；
+// step 为空时按时间范围自动聚合，最多返回 statsMaxPoints 个点
+func (h *ConfigHandler) handleStatsQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	q := r.URL.Query()
+	kind, ok := statsKind(q.Get("kind"))
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "kind 应为 channel 或 group")
+		return
+	}
+	name := q.Get("name")
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少 name 参数")
+		return
+	}
+
+	sinceValue := q.Get("since")
+	if sinceValue == "" {
+		sinceValue = "24h"
+	}
+	since, err := parseAuditTime(sinceValue)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := parseAuditTime(q.Get("until"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	var step time.Duration
+	if s := q.Get("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "step 格式错误: "+s)
+			return
+		}
+	} else {
+		step = (until.Sub(since) / statsMaxPoints).Truncate(time.Minute)
+	}
+
+	points, err := stats.Default.Query(kind, name, since, until, step)
+	if err != nil {
+		writeStatsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"kind":   kind,
+		"name":   name,
+		"step":   step.Seconds(),
+		"points": points,
+	})
+}
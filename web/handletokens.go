@@ -0,0 +1,217 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qist/tvgate/auth"
+	"github.com/qist/tvgate/i18n"
+	"github.com/qist/tvgate/logger"
+)
+
+// tokenRequest token 接口请求体
+type tokenRequest struct {
+	Token string `json:"token"` // 签发时可指定，为空随机生成
+	Note  string `json:"note"`  // 备注
+	TTL   string `json:"ttl"`   // 有效期，如 24h；为空或 0 表示永不过期
+	auth.TokenACL
+}
+
+func (req *tokenRequest) ttl() (time.Duration, error) {
+	if req.TTL == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(formatDurationString(req.TTL))
+	if err != nil {
+		return 0, errors.New("ttl 格式错误: " + req.TTL)
+	}
+	return d, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	if lang := w.Header().Get("Content-Language"); lang != "" {
+		msg = i18n.T(lang, msg)
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// tokenManagerForAPI 返回全局 token 管理器，未启用全局认证时写出错误并返回 nil
+func tokenManagerForAPI(w http.ResponseWriter) *auth.TokenManager {
+	tm := auth.GetGlobalTokenManager()
+	if tm == nil || !tm.Enabled {
+		writeJSONError(w, http.StatusServiceUnavailable, "全局认证未启用（global_auth.tokens_enabled）")
+		return nil
+	}
+	return tm
+}
+
+// decodeTokenRequest 解析 POST 请求体
+func decodeTokenRequest(w http.ResponseWriter, r *http.Request) (*tokenRequest, bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return nil, false
+	}
+	var req tokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return nil, false
+	}
+	return &req, true
+}
+
+// handleTokens GET 列出签发的 token，POST 签发新 token
+func (h *ConfigHandler) handleTokens(w http.ResponseWriter, r *http.Request) {
+	tm := tokenManagerForAPI(w)
+	if tm == nil {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, tm.IssuedTokens())
+		return
+	}
+
+	req, ok := decodeTokenRequest(w, r)
+	if !ok {
+		return
+	}
+	ttl, err := req.ttl()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	it, err := tm.IssueToken(req.Token, req.Note, ttl, req.TokenACL)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	logger.LogPrintf("✅ 签发token: %s, 备注: %s, 有效期: %s", logger.MaskToken(it.Token), it.Note, ttl)
+	writeJSON(w, http.StatusCreated, it)
+}
+
+// handleTokenExtend 延长 token 有效期
+func (h *ConfigHandler) handleTokenExtend(w http.ResponseWriter, r *http.Request) {
+	tm := tokenManagerForAPI(w)
+	if tm == nil {
+		return
+	}
+	req, ok := decodeTokenRequest(w, r)
+	if !ok {
+		return
+	}
+	ttl, err := req.ttl()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	it, err := tm.ExtendToken(req.Token, ttl)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	logger.LogPrintf("🔄 延长token有效期: %s, 到期时间: %s", logger.MaskToken(it.Token), it.ExpiresAt.Format("2006-01-02 15:04:05"))
+	writeJSON(w, http.StatusOK, it)
+}
+
+// handleTokenRevoke 吊销 token
+func (h *ConfigHandler) handleTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	tm := tokenManagerForAPI(w)
+	if tm == nil {
+		return
+	}
+	req, ok := decodeTokenRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := tm.RevokeToken(req.Token); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	logger.LogPrintf("🚫 吊销token: %s", logger.MaskToken(req.Token))
+	writeJSON(w, http.StatusOK, map[string]string{"token": req.Token})
+}
+
+// maxTokenImportBody 批量导入的请求体大小上限
+const maxTokenImportBody = 32 << 20
+
+// tokenFormat 按 format 参数确定导入导出的格式，未指定时按 Content-Type 判断，默认 JSON
+func tokenFormat(r *http.Request) (string, bool) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		format := auth.FormatOf(v)
+		return format, format != ""
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+		return auth.FormatCSV, true
+	}
+	return auth.FormatJSON, true
+}
+
+// handleTokenExport 导出全部未过期的签发 token，参数 format 为 json（默认）或 csv
+func (h *ConfigHandler) handleTokenExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	tm := tokenManagerForAPI(w)
+	if tm == nil {
+		return
+	}
+	format, ok := tokenFormat(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "format 只支持 json 或 csv")
+		return
+	}
+	list := tm.IssuedTokens()
+	contentType := "application/json; charset=utf-8"
+	if format == auth.FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=tokens-"+time.Now().Format("20060102150405")+"."+format)
+	if err := auth.WriteTokens(w, format, list); err != nil {
+		logger.LogPrintf("❌ 导出token失败: %v", err)
+		return
+	}
+	logger.LogPrintf("✅ 导出token: %d 个，格式 %s", len(list), format)
+}
+
+// handleTokenImport 批量导入 token，请求体为导出的 JSON 或 CSV 文件；
+// 参数 format 为 json（默认，Content-Type 含 csv 时为 csv）或 csv，replace=true 时覆盖已存在的 token
+func (h *ConfigHandler) handleTokenImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "方法不允许")
+		return
+	}
+	tm := tokenManagerForAPI(w)
+	if tm == nil {
+		return
+	}
+	format, ok := tokenFormat(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "format 只支持 json 或 csv")
+		return
+	}
+	list, err := auth.ReadTokens(http.MaxBytesReader(w, r.Body, maxTokenImportBody), format)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	replace, _ := strconv.ParseBool(r.URL.Query().Get("replace"))
+	res, err := tm.ImportTokens(list, replace)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	logger.LogPrintf("✅ 导入token: %d/%d 个，跳过 %d 个", res.Imported, res.Total, len(res.Skipped))
+	writeJSON(w, http.StatusOK, res)
+}
@@ -6,9 +6,12 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/config/load"
+	"github.com/qist/tvgate/utils/httperror"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,7 +25,7 @@ func (h *ConfigHandler) handleLogEditor(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.renderTemplate(w, r, "log_editor", "templates/log_editor.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -41,10 +44,13 @@ func (h *ConfigHandler) handleGetLogConfig(w http.ResponseWriter, r *http.Reques
 		"maxbackups":  logCfg.MaxBackups,
 		"maxage":      logCfg.MaxAgeDays,
 		"compress":    logCfg.Compress,
+		"level":       logCfg.Level,
+		"format":      logCfg.Format,
+		"modules":     logCfg.Modules,
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "序列化日志配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化日志配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -52,33 +58,33 @@ func (h *ConfigHandler) handleGetLogConfig(w http.ResponseWriter, r *http.Reques
 // handleSaveLogConfig 保存日志配置
 func (h *ConfigHandler) handleSaveLogConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "读取请求体失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
 	var logConfig map[string]interface{}
 	if err := json.Unmarshal(body, &logConfig); err != nil {
-		http.Error(w, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, "解析JSON失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	configPath := *config.ConfigFilePath
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "读取配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var fullNode yaml.Node
-	if err := yaml.Unmarshal(data, &fullNode); err != nil {
-		http.Error(w, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
+	if err := load.UnmarshalNode(configPath, data, &fullNode); err != nil {
+		httperror.Error(w, r, "解析配置文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -136,6 +142,46 @@ func (h *ConfigHandler) handleSaveLogConfig(w http.ResponseWriter, r *http.Reque
 							&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", compress)})
 					}
 
+					// level、format
+					for _, key := range []string{"level", "format"} {
+						if v, ok := logConfig[key]; ok {
+							if str := fmt.Sprintf("%v", v); str != "" {
+								newLogNode.Content = append(newLogNode.Content,
+									&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+									&yaml.Node{Kind: yaml.ScalarNode, Value: str})
+							}
+						}
+					}
+
+					// modules：请求中未包含时保留原有设置
+					modulesNode := logChild(doc.Content[i+1], "modules")
+					if modules, ok := logConfig["modules"].(map[string]interface{}); ok {
+						modulesNode = nil
+						if len(modules) > 0 {
+							modulesNode = &yaml.Node{Kind: yaml.MappingNode}
+							names := make([]string, 0, len(modules))
+							for name := range modules {
+								names = append(names, name)
+							}
+							sort.Strings(names)
+							for _, name := range names {
+								modulesNode.Content = append(modulesNode.Content,
+									&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+									&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("%v", modules[name])})
+							}
+						}
+					}
+					if modulesNode != nil {
+						newLogNode.Content = append(newLogNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "modules"}, modulesNode)
+					}
+
+					// 页面未编辑的 dedup 保留原有设置
+					if dedupNode := logChild(doc.Content[i+1], "dedup"); dedupNode != nil {
+						newLogNode.Content = append(newLogNode.Content,
+							&yaml.Node{Kind: yaml.ScalarNode, Value: "dedup"}, dedupNode)
+					}
+
 					doc.Content[i+1] = newLogNode
 					logFound = true
 					break
@@ -149,25 +195,38 @@ func (h *ConfigHandler) handleSaveLogConfig(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	newData, err := yaml.Marshal(&fullNode)
+	newData, err := load.MarshalEdited(configPath, data, &fullNode)
 	if err != nil {
-		http.Error(w, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "序列化配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	backupPath := configPath + ".backup." + time.Now().Format("20060102150405")
 	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		http.Error(w, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "创建备份文件失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err := os.WriteFile(configPath, newData, 0644); err != nil {
 		os.WriteFile(configPath, data, 0644)
-		http.Error(w, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
+		httperror.Error(w, r, "写入配置失败，已恢复备份: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("日志配置保存成功"))
-}
\ No newline at end of file
+}
+
+// logChild 返回原 log 节点中指定键的值节点，不存在时返回 nil
+func logChild(logNode *yaml.Node, key string) *yaml.Node {
+	if logNode == nil || logNode.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(logNode.Content); i += 2 {
+		if logNode.Content[i].Value == key {
+			return logNode.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -66,6 +66,9 @@ document.addEventListener("DOMContentLoaded", () => {
                     case "downloading":
                         progress = 30;
                         break;
+                    case "verifying":
+                        progress = 40;
+                        break;
                     case "backing_up":
                         progress = 50;
                         break;
@@ -177,6 +180,26 @@ document.addEventListener("DOMContentLoaded", () => {
         });
     }
 
+    // 自动检查发现新版本时在版本号旁提示
+    const latestBadge = async () => {
+        if (!currentVersionElement) return;
+        try {
+            const res = await fetch(window.webPath + "github/latest");
+            if (!res.ok) return;
+            const info = await res.json();
+            if (!info.available) return;
+            const badge = document.createElement("span");
+            badge.style.cssText = "margin-left: 8px; padding: 2px 6px; border-radius: 4px; background-color: #F44336; color: white; font-size: 12px; cursor: pointer;";
+            badge.title = info.latest;
+            badge.innerHTML = `<span>发现新版本</span> ${info.latest}`;
+            badge.addEventListener("click", () => currentVersionElement.click());
+            currentVersionElement.insertAdjacentElement("afterend", badge);
+        } catch (err) {
+            console.error("获取最新版本失败:", err);
+        }
+    };
+    latestBadge();
+
     if (closeModalBtn) {
         closeModalBtn.addEventListener("click", closeModal);
     }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+
+	"github.com/qist/tvgate/utils/httperror"
 )
 
 var (
@@ -27,7 +29,7 @@ func (h *ThemeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		h.setTheme(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httperror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -48,12 +50,12 @@ func (h *ThemeHandler) setTheme(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httperror.Error(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if req.Theme != "dark" && req.Theme != "light" {
-		http.Error(w, "Invalid theme value", http.StatusBadRequest)
+		httperror.Error(w, r, "Invalid theme value", http.StatusBadRequest)
 		return
 	}
 
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/qist/tvgate/config"
+	"github.com/qist/tvgate/logger"
+	"github.com/qist/tvgate/utils/httperror"
+)
+
+// Web 管理角色
+const (
+	RoleViewer   = "viewer"   // 只读：查看状态与配置
+	RoleOperator = "operator" // 可修改除认证相关外的配置
+	RoleAdmin    = "admin"    // 全部权限
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// normalizeRole 未配置或未知的角色按 viewer 处理
+func normalizeRole(role string) string {
+	if role == "" {
+		return RoleViewer
+	}
+	if _, ok := roleLevels[role]; !ok {
+		logger.LogPrintf("⚠️ 未知的 Web 用户角色 %q，按 viewer 处理", role)
+		return RoleViewer
+	}
+	return role
+}
+
+// findUser 按用户名查找账号，web.username/password 视为管理员账号
+func (h *ConfigHandler) findUser(username string) (config.WebUser, bool) {
+	if username == "" {
+		return config.WebUser{}, false
+	}
+	if h.webConfig.Username != "" && username == h.webConfig.Username {
+		return config.WebUser{Username: username, Password: h.webConfig.Password, Role: RoleAdmin}, true
+	}
+	for _, u := range h.webConfig.Users {
+		if u.Username == username {
+			u.Role = normalizeRole(u.Role)
+			return u, true
+		}
+	}
+	return config.WebUser{}, false
+}
+
+// checkCredentials 校验用户名和密码
+func (h *ConfigHandler) checkCredentials(username, password string) (config.WebUser, bool) {
+	user, ok := h.findUser(username)
+	if !ok || user.Password == "" {
+		return config.WebUser{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+		return config.WebUser{}, false
+	}
+	return user, true
+}
+
+// requestRole 返回当前请求的角色，未登录返回空；未启用 Web 认证时视为管理员（与 isAuthenticated 保持一致）
+func (h *ConfigHandler) requestRole(r *http.Request) string {
+	if !h.webConfig.Enabled {
+		return RoleAdmin
+	}
+	cookie, err := r.Cookie("tvgate_auth")
+	if err != nil || !h.validateAuthCookie(cookie.Value) {
+		return ""
+	}
+	user, ok := h.findUser(h.getUsernameFromCookie(cookie.Value))
+	if !ok {
+		return ""
+	}
+	return user.Role
+}
+
+// hasRole 当前请求的角色是否不低于 role
+func (h *ConfigHandler) hasRole(r *http.Request, role string) bool {
+	return roleLevels[h.requestRole(r)] >= roleLevels[role]
+}
+
+// roleAuth 在 cookieAuth 基础上要求最低角色，权限不足返回 403
+func (h *ConfigHandler) roleAuth(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return h.cookieAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !h.hasRole(r, role) {
+			httperror.Error(w, r, "权限不足", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	})
+}